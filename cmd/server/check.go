@@ -0,0 +1,204 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"acetate/internal/albums"
+	"acetate/internal/config"
+	"acetate/internal/database"
+	"acetate/internal/server"
+)
+
+// checkOptions is the subset of startup configuration validated by -check.
+type checkOptions struct {
+	DataPath          string
+	AlbumPath         string
+	AlbumExtensions   []string
+	AdminUsername     string
+	AdminPassword     string
+	AdminPasswordHash string
+	PasswordPolicy    server.PasswordPolicy
+}
+
+// checkReport collects -check results. Problems are conditions that would
+// stop startup or leave listeners with broken albums; warnings are worth
+// knowing about but the server would still run.
+type checkReport struct {
+	w        io.Writer
+	problems int
+	warnings int
+}
+
+func (r *checkReport) ok(format string, args ...any) {
+	fmt.Fprintf(r.w, "  ok       %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *checkReport) warn(format string, args ...any) {
+	r.warnings++
+	fmt.Fprintf(r.w, "  warning  %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *checkReport) problem(format string, args ...any) {
+	r.problems++
+	fmt.Fprintf(r.w, "  problem  %s\n", fmt.Sprintf(format, args...))
+}
+
+// runCheck validates the database, admin bootstrap state, and album files
+// without starting the listener. It writes a report to w and returns the
+// number of problems found. Nothing is written: the database is opened
+// read-only, and pending migrations are reported as a warning.
+func runCheck(w io.Writer, opts checkOptions) int {
+	r := &checkReport{w: w}
+	fmt.Fprintln(w, "acetate configuration check")
+
+	db, closeDB, err := openCheckDatabase(r, opts.DataPath)
+	if err != nil {
+		r.problem("database: %v", err)
+		fmt.Fprintf(w, "%d problem(s), %d warning(s)\n", r.problems, r.warnings)
+		return r.problems
+	}
+	defer closeDB()
+
+	needed, err := server.CheckAdminBootstrap(db, opts.AdminUsername, opts.AdminPassword, opts.AdminPasswordHash, opts.PasswordPolicy)
+	switch {
+	case errors.Is(err, server.ErrAdminBootstrapMissing):
+		r.warn("admin: no admin users and no bootstrap credentials; first-time setup will be required at /admin")
+	case err != nil:
+		r.problem("admin: %v", err)
+	case !needed:
+		r.ok("admin: admin users exist")
+	default:
+		r.ok("admin: bootstrap admin will be created on startup")
+	}
+
+	store := albums.NewStore(db)
+	allAlbums, err := store.ListAlbums()
+	if err != nil {
+		r.problem("albums: %v", err)
+	} else if len(allAlbums) == 0 {
+		checkLegacyConfig(r, opts.DataPath, opts.AlbumPath)
+	} else {
+		checkAlbumFiles(r, store, allAlbums, opts.AlbumExtensions)
+	}
+
+	fmt.Fprintf(w, "%d problem(s), %d warning(s)\n", r.problems, r.warnings)
+	return r.problems
+}
+
+// openCheckDatabase opens the database for -check without changing it. When
+// there is no database yet or it has pending migrations, the remaining
+// checks run against a migrated scratch copy, which is what startup would
+// see. The returned func closes the database and removes any copy.
+func openCheckDatabase(r *checkReport, dataPath string) (*sql.DB, func(), error) {
+	live, err := database.OpenReadOnly(dataPath)
+	if errors.Is(err, os.ErrNotExist) {
+		r.ok("database: none yet in %s; it will be created on startup", dataPath)
+		return openCheckScratch(nil)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pending, err := database.PendingMigrations(live)
+	if err != nil {
+		live.Close()
+		return nil, nil, err
+	}
+	if len(pending) == 0 {
+		r.ok("database: %s", dataPath)
+		return live, func() { live.Close() }, nil
+	}
+	defer live.Close()
+	r.warn("database: %d pending migration(s) will run on startup: %s", len(pending), strings.Join(pending, ", "))
+	return openCheckScratch(live)
+}
+
+// openCheckScratch opens a migrated copy of live in a temporary directory, or
+// a fresh database when live is nil.
+func openCheckScratch(live *sql.DB) (*sql.DB, func(), error) {
+	dir, err := os.MkdirTemp("", "acetate-check-")
+	if err != nil {
+		return nil, nil, err
+	}
+	var db *sql.DB
+	if live == nil {
+		db, err = database.Open(dir)
+	} else {
+		db, err = database.Snapshot(live, dir)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}, nil
+}
+
+// checkLegacyConfig validates a config.json that would be migrated on first
+// boot, since nothing is in the database yet.
+func checkLegacyConfig(r *checkReport, dataPath, albumPath string) {
+	data, err := os.ReadFile(filepath.Join(dataPath, "config.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			r.warn("albums: none configured; create one from the admin panel")
+			return
+		}
+		r.problem("config.json: %v", err)
+		return
+	}
+	var cfg config.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		r.problem("config.json: %v", err)
+		return
+	}
+	if info, err := os.Stat(albumPath); err != nil || !info.IsDir() {
+		r.problem("config.json: album folder %s does not exist", albumPath)
+		return
+	}
+	r.ok("config.json: %q with %d track(s) will be migrated on startup", cfg.Title, len(cfg.Tracks))
+}
+
+func checkAlbumFiles(r *checkReport, store *albums.Store, allAlbums []albums.Album, extensions []string) {
+	missing, err := server.FindMissingTracks(store, extensions)
+	if err != nil {
+		r.problem("tracks: %v", err)
+		return
+	}
+	missingBySlug := make(map[string][]string, len(missing))
+	for _, m := range missing {
+		missingBySlug[m.Slug] = m.Stems
+	}
+
+	for _, alb := range allAlbums {
+		if info, err := os.Stat(alb.AlbumPath); err != nil || !info.IsDir() {
+			r.problem("album %q: folder %s does not exist", alb.Slug, alb.AlbumPath)
+			continue
+		}
+		albumOK := true
+		if stems := missingBySlug[alb.Slug]; len(stems) > 0 {
+			albumOK = false
+			r.problem("album %q: %d configured track(s) with no audio file: %s", alb.Slug, len(stems), strings.Join(stems, ", "))
+		}
+		checked, issues, err := config.CheckAlbumFiles(alb.AlbumPath, extensions...)
+		if err != nil {
+			r.problem("album %q: %v", alb.Slug, err)
+			continue
+		}
+		for _, issue := range issues {
+			albumOK = false
+			r.problem("album %q: %s: %s", alb.Slug, issue.Path, issue.Problem)
+		}
+		if albumOK {
+			r.ok("album %q: %d audio file(s) checked", alb.Slug, checked)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"acetate/internal/albums"
+	"acetate/internal/database"
+	"acetate/internal/server"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// validMP3 is an MPEG-1 Layer III frame header followed by padding.
+var validMP3 = append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 64)...)
+
+func setupCheckEnv(t *testing.T, stems []string, files map[string][]byte) checkOptions {
+	t.Helper()
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "data")
+	albumDir := filepath.Join(root, "album")
+	if err := os.MkdirAll(albumDir, 0755); err != nil {
+		t.Fatalf("mkdir album: %v", err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(albumDir, name), content, 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	db, err := database.Open(dataDir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	store := albums.NewStore(db)
+	alb, err := store.CreateAlbum("Check Album", "Tester", albumDir)
+	if err != nil {
+		t.Fatalf("create album: %v", err)
+	}
+	tracks := make([]albums.Track, 0, len(stems))
+	for _, stem := range stems {
+		tracks = append(tracks, albums.Track{Stem: stem, Title: stem})
+	}
+	if err := store.SetTracks(alb.ID, tracks); err != nil {
+		t.Fatalf("set tracks: %v", err)
+	}
+
+	return checkOptions{
+		DataPath:       dataDir,
+		AlbumPath:      albumDir,
+		AdminUsername:  "admin",
+		PasswordPolicy: server.DefaultPasswordPolicy(),
+	}
+}
+
+func TestRunCheckPassesForGoodSetup(t *testing.T) {
+	opts := setupCheckEnv(t, []string{"01-intro"}, map[string][]byte{"01-intro.mp3": validMP3})
+	hash, err := bcrypt.GenerateFromPassword([]byte("check-admin-123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	opts.AdminPasswordHash = string(hash)
+
+	var out bytes.Buffer
+	if problems := runCheck(&out, opts); problems != 0 {
+		t.Fatalf("runCheck problems = %d, want 0:\n%s", problems, out.String())
+	}
+	if !strings.Contains(out.String(), "bootstrap admin will be created") {
+		t.Fatalf("report missing admin bootstrap line:\n%s", out.String())
+	}
+
+	// The check must not create the admin user itself.
+	db, err := database.Open(opts.DataPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM admin_users").Scan(&count); err != nil {
+		t.Fatalf("count admins: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("admin users after check = %d, want 0", count)
+	}
+}
+
+func TestRunCheckReportsProblems(t *testing.T) {
+	opts := setupCheckEnv(t, []string{"01-intro", "02-missing"}, map[string][]byte{"01-intro.mp3": []byte("not audio at all")})
+	opts.AdminPasswordHash = "not-a-bcrypt-hash"
+
+	var out bytes.Buffer
+	problems := runCheck(&out, opts)
+	report := out.String()
+	if problems != 3 {
+		t.Fatalf("runCheck problems = %d, want 3:\n%s", problems, report)
+	}
+	for _, want := range []string{"ADMIN_PASSWORD_HASH", "02-missing", "01-intro.mp3: no_mp3_frames"} {
+		if !strings.Contains(report, want) {
+			t.Fatalf("report missing %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestRunCheckReportsPendingMigrationsWithoutApplying(t *testing.T) {
+	opts := setupCheckEnv(t, []string{"01-intro"}, map[string][]byte{"01-intro.mp3": validMP3})
+	opts.AdminPassword = "check-admin-123"
+
+	db, err := database.Open(opts.DataPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if _, err := db.Exec("ALTER TABLE albums DROP COLUMN logo_url"); err != nil {
+		t.Fatalf("drop column: %v", err)
+	}
+	db.Close()
+
+	var out bytes.Buffer
+	if problems := runCheck(&out, opts); problems != 0 {
+		t.Fatalf("runCheck problems = %d, want 0:\n%s", problems, out.String())
+	}
+	if !strings.Contains(out.String(), "add column albums.logo_url") {
+		t.Fatalf("report missing pending migration:\n%s", out.String())
+	}
+
+	db, err = database.OpenReadOnly(opts.DataPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	pending, err := database.PendingMigrations(db)
+	if err != nil {
+		t.Fatalf("pending migrations: %v", err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("pending after check = %v, want only the dropped column", pending)
+	}
+}
+
+func TestRunCheckWithoutDatabaseCreatesNothing(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "data")
+	opts := checkOptions{
+		DataPath:       dataDir,
+		AlbumPath:      t.TempDir(),
+		AdminUsername:  "admin",
+		AdminPassword:  "check-admin-123",
+		PasswordPolicy: server.DefaultPasswordPolicy(),
+	}
+
+	var out bytes.Buffer
+	if problems := runCheck(&out, opts); problems != 0 {
+		t.Fatalf("runCheck problems = %d, want 0:\n%s", problems, out.String())
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "acetate.db")); !os.IsNotExist(err) {
+		t.Fatalf("check created the database: %v", err)
+	}
+}
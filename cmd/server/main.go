@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
@@ -12,26 +13,139 @@ import (
 	"time"
 
 	"acetate/internal/albums"
+	"acetate/internal/analytics"
+	"acetate/internal/auth"
+	"acetate/internal/config"
 	"acetate/internal/database"
 	"acetate/internal/server"
 )
 
 func main() {
+	checkOnly := flag.Bool("check", false, "validate configuration, database, and album files, then exit without serving")
+	flag.Parse()
+
 	// Environment configuration
-	listenAddr := envOr("LISTEN_ADDR", ":8080")
+	listenAddr, err := config.NormalizeListenAddr(envOr("LISTEN_ADDR", ":8080"))
+	if err != nil {
+		log.Fatalf("LISTEN_ADDR: %v", err)
+	}
 	albumPath := envOr("ALBUM_PATH", "./album")
 	dataPath := envOr("DATA_PATH", "./data")
 	adminUsername := envOr("ADMIN_USERNAME", "admin")
-	adminPassword := os.Getenv("ADMIN_PASSWORD")
-	adminPasswordHash := os.Getenv("ADMIN_PASSWORD_HASH")
+	adminPassword := secretEnv("ADMIN_PASSWORD")
+	adminPasswordHash := secretEnv("ADMIN_PASSWORD_HASH")
+	albumPasswordHash := secretEnv("ALBUM_PASSWORD_HASH")
 	legacyAdminToken := os.Getenv("ADMIN_TOKEN")
 	analyticsRetentionDays := envInt("ANALYTICS_RETENTION_DAYS", 0)
+	auditRetentionDays := envInt("ADMIN_AUDIT_RETENTION_DAYS", analytics.DefaultAuditRetentionDays)
+	if auditRetentionDays <= 0 {
+		auditRetentionDays = -1 // keep forever
+	}
+	adminInactiveDays := envInt("ADMIN_INACTIVE_DEACTIVATE_DAYS", 0)
 	maintenanceInterval := envDuration("ANALYTICS_MAINTENANCE_INTERVAL", 12*time.Hour)
+	maintenanceJitter := envFloat("ANALYTICS_MAINTENANCE_JITTER", server.DefaultMaintenanceJitter)
+	maintenanceOnStart := envBool("ANALYTICS_MAINTENANCE_ON_START", false)
+	albumExtensions := config.ParseAlbumExtensions(os.Getenv("ALBUM_EXTENSIONS"))
+	trackCheckFatal := envBool("TRACK_CHECK_FATAL", false)
+	albumWatchInterval := envDuration("ALBUM_WATCH_INTERVAL", 0)
+	staticMaxAge := envDuration("STATIC_MAX_AGE", 0)
+	transcodeEnabled := envBool("TRANSCODE_ENABLED", false)
+	referrerPolicy := os.Getenv("REFERRER_POLICY")
+	permissionsPolicy := os.Getenv("PERMISSIONS_POLICY")
+	cspReportEnabled := envBool("CSP_REPORT_ENABLED", false)
+	shareSigningKey := secretEnv("SHARE_SIGNING_KEY")
+	shareMaxTTL := envDuration("SHARE_MAX_TTL", server.DefaultShareMaxTTL)
+	scanDetectTracks := envInt("SCAN_DETECT_TRACKS", 0)
+	scanDetectWindow := envDuration("SCAN_DETECT_WINDOW", server.DefaultScanWindow)
+	scanThrottle := envBool("SCAN_THROTTLE", false)
+	maxConcurrentPerIP := envInt("MAX_CONCURRENT_PER_IP", 0)
+	apiWriteTimeout := envDuration("API_WRITE_TIMEOUT", server.DefaultAPIWriteTimeout)
+	streamWriteTimeout := envDuration("STREAM_WRITE_TIMEOUT", server.DefaultStreamWriteTimeout)
+	requireCloudflareReady := envBool("READYZ_REQUIRE_CLOUDFLARE", false)
+	clientIPHeader := envOr("CLIENT_IP_HEADER", auth.DefaultClientIPHeader)
+	trustedProxies, invalidProxies := auth.ParseTrustedProxies(os.Getenv("TRUSTED_PROXY_CIDRS"))
+	for _, entry := range invalidProxies {
+		log.Printf("WARNING: ignoring invalid TRUSTED_PROXY_CIDRS entry %q", entry)
+	}
+	if !strings.EqualFold(clientIPHeader, auth.DefaultClientIPHeader) && len(trustedProxies) == 0 {
+		log.Printf("WARNING: CLIENT_IP_HEADER %s is only read from TRUSTED_PROXY_CIDRS peers and none are set; clients are identified by the peer address", clientIPHeader)
+	}
+	ipv6Prefix := envInt("IPV6_PREFIX_LENGTH", 0)
+	if ipv6Prefix < 0 || ipv6Prefix > 128 {
+		log.Printf("WARNING: IPV6_PREFIX_LENGTH %d is outside 0-128, keying IPv6 clients by full address", ipv6Prefix)
+		ipv6Prefix = 0
+	}
+	adminBasicAuth := envBool("ADMIN_BASIC_AUTH", false)
+	lockoutWebhookURL := os.Getenv("ADMIN_LOCKOUT_WEBHOOK_URL")
+	sharedRateLimits := envBool("SHARED_RATE_LIMITS", false)
+	adminMutationRateLimit := envInt("ADMIN_MUTATION_RATE_LIMIT", server.DefaultAdminMutationRateLimit)
+	defaultBodyLimits := server.DefaultBodyLimits()
+	bodyLimits := server.BodyLimits{
+		Auth:        int64(envInt("BODY_LIMIT_AUTH", int(defaultBodyLimits.Auth))),
+		Form:        int64(envInt("BODY_LIMIT_FORM", int(defaultBodyLimits.Form))),
+		Tracks:      int64(envInt("BODY_LIMIT_TRACKS", int(defaultBodyLimits.Tracks))),
+		TrackImport: int64(envInt("BODY_LIMIT_TRACK_IMPORT", int(defaultBodyLimits.TrackImport))),
+		Cover:       int64(envInt("BODY_LIMIT_COVER", int(defaultBodyLimits.Cover))),
+		Logo:        int64(envInt("BODY_LIMIT_LOGO", int(defaultBodyLimits.Logo))),
+	}
+	passwordPolicy := server.PasswordPolicy{
+		MinLength:        envInt("ADMIN_PASSWORD_MIN_LENGTH", server.DefaultPasswordPolicy().MinLength),
+		RequireSymbol:    envBool("ADMIN_PASSWORD_REQUIRE_SYMBOL", false),
+		RequireMixedCase: envBool("ADMIN_PASSWORD_REQUIRE_MIXED_CASE", false),
+	}
+	pwnedCheck := envBool("PWNED_CHECK", false)
+	pwnedRangeURL := envOr("PWNED_RANGE_URL", auth.DefaultPwnedRangeURL)
+	anonymousSessions := envBool("ANONYMOUS_SESSIONS", false)
+	adminFingerprint, err := auth.ParseFingerprintBinding(os.Getenv("ADMIN_FINGERPRINT_BINDING"))
+	if err != nil {
+		log.Printf("WARNING: %v, using %q", err, adminFingerprint)
+	}
+	sessionBackend, err := auth.ParseSessionBackendKind(os.Getenv("SESSION_BACKEND"))
+	if err != nil {
+		log.Printf("WARNING: %v, using %q", err, sessionBackend)
+	}
+	adminSessionSliding := envBool("ADMIN_SESSION_SLIDING", false)
+	adminIdleTimeout := envDuration("ADMIN_SESSION_IDLE_TIMEOUT", auth.AdminSessionExpiry)
+	adminMaxLifetime := envDuration("ADMIN_SESSION_MAX_LIFETIME", auth.DefaultAdminSessionMaxLifetime)
+	sessionTouchWindow := envDuration("SESSION_TOUCH_WINDOW", auth.SessionTouchWindow)
+	adminTouchWindow := envDuration("ADMIN_SESSION_TOUCH_WINDOW", auth.AdminTouchWindow)
+	analyticsEnabled := envBool("ANALYTICS_ENABLED", true)
+	analyticsAggregateOnly := envBool("ANALYTICS_AGGREGATE_ONLY", false)
+	exportMaxRows := envInt("EXPORT_MAX_ROWS", server.DefaultExportMaxRows)
+	jsonAllowUnknownFields := envBool("JSON_ALLOW_UNKNOWN_FIELDS", false)
+	analyticsMaxBodyBytes := int64(envInt("ANALYTICS_MAX_BODY_BYTES", analytics.DefaultMaxBodyBytes))
+	analyticsMaxBatchSize := envInt("ANALYTICS_MAX_BATCH_EVENTS", analytics.MaxBatchSize)
+	analyticsBufferSize := envInt("ANALYTICS_BUFFER_SIZE", analytics.ChannelBuffer)
+	analyticsSpillMaxBytes := int64(envInt("ANALYTICS_SPILL_MAX_BYTES", 0))
+	analyticsBatchIDTTL := envDuration("ANALYTICS_BATCH_ID_TTL", analytics.DefaultBatchIDTTL)
+	analyticsFlushStall := envDuration("ANALYTICS_FLUSH_STALL_THRESHOLD", analytics.DefaultFlushStallThreshold)
+	completeMinFraction := envFloat("ANALYTICS_COMPLETE_MIN_FRACTION", analytics.DefaultCompleteMinFraction)
+	if err := analytics.ValidateIngestLimits(analyticsMaxBodyBytes, analyticsMaxBatchSize); err != nil {
+		log.Printf("WARNING: invalid analytics ingest limits (%v), using defaults", err)
+		analyticsMaxBodyBytes = analytics.DefaultMaxBodyBytes
+		analyticsMaxBatchSize = analytics.MaxBatchSize
+	}
 
 	if strings.TrimSpace(legacyAdminToken) != "" {
 		log.Println("WARNING: ADMIN_TOKEN is deprecated and ignored; use ADMIN_USERNAME + ADMIN_PASSWORD_HASH")
 	}
 
+	if *checkOnly {
+		problems := runCheck(os.Stdout, checkOptions{
+			DataPath:          dataPath,
+			AlbumPath:         albumPath,
+			AlbumExtensions:   albumExtensions,
+			AdminUsername:     adminUsername,
+			AdminPassword:     adminPassword,
+			AdminPasswordHash: adminPasswordHash,
+			PasswordPolicy:    passwordPolicy,
+		})
+		if problems > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Open database
 	db, err := database.Open(dataPath)
 	if err != nil {
@@ -39,7 +153,7 @@ func main() {
 	}
 	defer db.Close()
 
-	if err := server.EnsureAdminBootstrap(db, adminUsername, adminPassword, adminPasswordHash); err != nil {
+	if err := server.EnsureAdminBootstrapWithPolicy(db, adminUsername, adminPassword, adminPasswordHash, passwordPolicy); err != nil {
 		if errors.Is(err, server.ErrAdminBootstrapMissing) {
 			log.Println("WARNING: no bootstrap admin credentials provided and no admin users exist")
 			log.Println("WARNING: open /admin and complete first-time setup to create the initial admin account")
@@ -56,6 +170,12 @@ func main() {
 	// Create album store
 	albumStore := albums.NewStore(db)
 
+	if created, err := albumStore.BootstrapPassword(albumPasswordHash); err != nil {
+		log.Fatalf("bootstrap album password: %v", err)
+	} else if created {
+		log.Println("created default listener password from ALBUM_PASSWORD_HASH")
+	}
+
 	allAlbums, err := albumStore.ListAlbums()
 	if err != nil {
 		log.Fatalf("list albums: %v", err)
@@ -66,13 +186,74 @@ func main() {
 		log.Printf("  album %q (%s) — %d tracks", a.Title, a.Slug, len(tracks))
 	}
 
+	missing, err := server.FindMissingTracks(albumStore, albumExtensions)
+	if err != nil {
+		log.Fatalf("check track files: %v", err)
+	}
+	for _, m := range missing {
+		log.Printf("WARNING: album %q has %d configured track(s) with no audio file: %s", m.Slug, len(m.Stems), strings.Join(m.Stems, ", "))
+	}
+	if len(missing) > 0 && trackCheckFatal {
+		log.Fatalf("missing track files (TRACK_CHECK_FATAL=true)")
+	}
+
 	// Create and start server
 	srv := server.New(server.Config{
 		ListenAddr:             listenAddr,
 		DataPath:               dataPath,
 		AlbumBasePath:          albumPath,
+		AlbumExtensions:        albumExtensions,
+		AlbumWatchInterval:     albumWatchInterval,
+		StaticMaxAge:           staticMaxAge,
+		TranscodeEnabled:       transcodeEnabled,
+		ReferrerPolicy:         referrerPolicy,
+		PermissionsPolicy:      permissionsPolicy,
+		CSPReportEnabled:       cspReportEnabled,
+		ShareSigningKey:        shareSigningKey,
+		ShareMaxTTL:            shareMaxTTL,
+		ScanDetectTracks:       scanDetectTracks,
+		ScanDetectWindow:       scanDetectWindow,
+		ScanThrottle:           scanThrottle,
+		MaxConcurrentPerIP:     maxConcurrentPerIP,
 		AnalyticsRetentionDays: analyticsRetentionDays,
+		AuditRetentionDays:     auditRetentionDays,
+		AdminInactiveDays:      adminInactiveDays,
 		MaintenanceInterval:    maintenanceInterval,
+		MaintenanceJitter:      maintenanceJitter,
+		MaintenanceOnStart:     maintenanceOnStart,
+		APIWriteTimeout:        apiWriteTimeout,
+		StreamWriteTimeout:     streamWriteTimeout,
+		RequireCloudflareReady: requireCloudflareReady,
+		ClientIPHeader:         clientIPHeader,
+		TrustedProxies:         trustedProxies,
+		IPv6Prefix:             ipv6Prefix,
+		AdminBasicAuth:         adminBasicAuth,
+		AdminMutationRateLimit: adminMutationRateLimit,
+		LockoutWebhookURL:      lockoutWebhookURL,
+		SharedRateLimits:       sharedRateLimits,
+		AnonymousSessions:      anonymousSessions,
+		AdminFingerprint:       adminFingerprint,
+		SessionBackend:         sessionBackend,
+		AdminSessionSliding:    adminSessionSliding,
+		AdminIdleTimeout:       adminIdleTimeout,
+		AdminMaxLifetime:       adminMaxLifetime,
+		SessionTouchWindow:     sessionTouchWindow,
+		AdminTouchWindow:       adminTouchWindow,
+		AnalyticsDisabled:      !analyticsEnabled,
+		AnalyticsAggregateOnly: analyticsAggregateOnly,
+		AnalyticsMaxBodyBytes:  analyticsMaxBodyBytes,
+		AnalyticsMaxBatchSize:  analyticsMaxBatchSize,
+		AnalyticsBufferSize:    analyticsBufferSize,
+		AnalyticsSpillMaxBytes: analyticsSpillMaxBytes,
+		AnalyticsBatchIDTTL:    analyticsBatchIDTTL,
+		AnalyticsFlushStall:    analyticsFlushStall,
+		CompleteMinFraction:    completeMinFraction,
+		ExportMaxRows:          exportMaxRows,
+		JSONAllowUnknownFields: jsonAllowUnknownFields,
+		BodyLimits:             bodyLimits,
+		PasswordPolicy:         passwordPolicy,
+		PwnedCheck:             pwnedCheck,
+		PwnedRangeURL:          pwnedRangeURL,
 		DB:                     db,
 		AlbumStore:             albumStore,
 	})
@@ -98,13 +279,28 @@ func main() {
 	}
 
 	// Graceful shutdown with timeout
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
 	defer cancel()
 
 	srv.Shutdown(shutdownCtx)
 	log.Println("shutdown complete")
 }
 
+// defaultShutdownTimeout bounds graceful shutdown when SHUTDOWN_TIMEOUT is
+// unset.
+const defaultShutdownTimeout = 15 * time.Second
+
+// shutdownTimeout reads SHUTDOWN_TIMEOUT, the time allowed for in-flight
+// requests, maintenance, and the analytics flush to finish on shutdown.
+func shutdownTimeout() time.Duration {
+	timeout := envDuration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
+	if timeout <= 0 {
+		log.Printf("WARNING: SHUTDOWN_TIMEOUT must be positive, using %s", defaultShutdownTimeout)
+		return defaultShutdownTimeout
+	}
+	return timeout
+}
+
 func envOr(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -112,6 +308,16 @@ func envOr(key, fallback string) string {
 	return fallback
 }
 
+// secretEnv reads a secret from key or key_FILE, exiting if the file is
+// unreadable.
+func secretEnv(key string) string {
+	v, err := config.ReadSecretEnv(key)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return v
+}
+
 func envInt(key string, fallback int) int {
 	raw := os.Getenv(key)
 	if raw == "" {
@@ -125,6 +331,32 @@ func envInt(key string, fallback int) int {
 	return v
 }
 
+func envFloat(key string, fallback float64) float64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		log.Printf("WARNING: invalid %s=%q, using %g", key, raw, fallback)
+		return fallback
+	}
+	return v
+}
+
+func envBool(key string, fallback bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Printf("WARNING: invalid %s=%q, using %t", key, raw, fallback)
+		return fallback
+	}
+	return v
+}
+
 func envDuration(key string, fallback time.Duration) time.Duration {
 	raw := os.Getenv(key)
 	if raw == "" {
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShutdownTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		want time.Duration
+	}{
+		{"", defaultShutdownTimeout},
+		{"45s", 45 * time.Second},
+		{"2m", 2 * time.Minute},
+		{"0", defaultShutdownTimeout},
+		{"-5s", defaultShutdownTimeout},
+		{"soon", defaultShutdownTimeout},
+	} {
+		t.Setenv("SHUTDOWN_TIMEOUT", tc.raw)
+		if got := shutdownTimeout(); got != tc.want {
+			t.Errorf("SHUTDOWN_TIMEOUT=%q: got %s, want %s", tc.raw, got, tc.want)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,6 +11,8 @@ import (
 	"strings"
 
 	"acetate/internal/config"
+	"acetate/internal/database"
+	"acetate/internal/server"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -22,52 +26,75 @@ type envLine struct {
 	value string
 }
 
-func main() {
-	reader := bufio.NewReader(os.Stdin)
-
-	fmt.Println("Acetate Setup Wizard")
-	fmt.Println("====================")
-	fmt.Println()
+// wizardFlags holds the command-line options. Without -non-interactive the
+// wizard prompts for everything and ignores the other flags.
+type wizardFlags struct {
+	nonInteractive    bool
+	album             string
+	data              string
+	listen            string
+	title             string
+	artist            string
+	passphrase        string
+	adminUsername     string
+	adminPasswordHash string
+}
 
-	cwd, err := os.Getwd()
-	if err != nil {
-		fatalf("resolve working directory: %v", err)
+func parseFlags(args []string) (wizardFlags, error) {
+	var f wizardFlags
+	fs := flag.NewFlagSet("setupwizard", flag.ContinueOnError)
+	fs.BoolVar(&f.nonInteractive, "non-interactive", false, "run without prompts using the flags below")
+	fs.StringVar(&f.album, "album", "", "album folder (required with -non-interactive)")
+	fs.StringVar(&f.data, "data", "", "data folder (required with -non-interactive)")
+	fs.StringVar(&f.listen, "listen", ":8080", "listen address")
+	fs.StringVar(&f.title, "title", "", "album title (required with -non-interactive)")
+	fs.StringVar(&f.artist, "artist", "", "artist (required with -non-interactive)")
+	fs.StringVar(&f.passphrase, "passphrase", "", "listener passphrase; hashed before saving (empty keeps the current one)")
+	fs.StringVar(&f.adminUsername, "admin-username", "admin", "admin username")
+	fs.StringVar(&f.adminPasswordHash, "admin-password-hash", "", "bcrypt hash for the first admin user (optional)")
+	if err := fs.Parse(args); err != nil {
+		return f, err
 	}
+	if fs.NArg() > 0 {
+		return f, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return f, nil
+}
 
-	albumPath := promptPath(reader, "Album folder", "./album")
-	dataPath := promptPath(reader, "Data folder", "./data")
-	listenAddr := promptText(reader, "Listen address", ":8080")
-
-	albumAbs, err := filepath.Abs(albumPath)
+func main() {
+	flags, err := parseFlags(os.Args[1:])
 	if err != nil {
-		fatalf("resolve album path: %v", err)
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fatalf("%v", err)
 	}
-	dataAbs, err := filepath.Abs(dataPath)
+
+	cwd, err := os.Getwd()
 	if err != nil {
-		fatalf("resolve data path: %v", err)
+		fatalf("resolve working directory: %v", err)
 	}
 
-	info, err := os.Stat(albumAbs)
-	if err != nil || !info.IsDir() {
-		fatalf("album folder does not exist or is not a directory: %s", albumAbs)
+	if flags.nonInteractive {
+		if err := runNonInteractive(flags, cwd); err != nil {
+			fatalf("%v", err)
+		}
+		return
 	}
 
-	if err := os.MkdirAll(dataAbs, 0755); err != nil {
-		fatalf("create data folder: %v", err)
-	}
+	reader := bufio.NewReader(os.Stdin)
 
-	stems, err := scanAlbum(albumAbs)
-	if err != nil {
-		fatalf("scan album folder: %v", err)
-	}
-	if len(stems) == 0 {
-		fmt.Println()
-		fmt.Println("WARNING: no .mp3 files found yet. You can still continue, but listeners will see no tracks.")
-	}
+	fmt.Println("Acetate Setup Wizard")
+	fmt.Println("====================")
+	fmt.Println()
 
-	cfgMgr, err := config.NewManager(dataAbs, albumAbs)
+	albumPath := promptPath(reader, "Album folder", "./album")
+	dataPath := promptPath(reader, "Data folder", "./data")
+	listenAddr := promptListenAddr(reader, ":8080")
+
+	cfgMgr, dataAbs, err := prepareConfig(albumPath, dataPath)
 	if err != nil {
-		fatalf("load/create config: %v", err)
+		fatalf("%v", err)
 	}
 
 	cfg := cfgMgr.Get()
@@ -90,19 +117,144 @@ func main() {
 
 	adminUsername, adminPasswordHash := promptAdminBootstrap(reader)
 
+	adminInDB := false
+	if promptYesNo(reader, "Create the admin user in the database now?", true) {
+		created, err := bootstrapAdminInDB(dataAbs, adminUsername, adminPasswordHash)
+		if err != nil {
+			fatalf("create admin user: %v", err)
+		}
+		if created {
+			adminInDB = true
+			fmt.Printf("Admin user %q created; ADMIN_PASSWORD_HASH is not needed in .env.\n", adminUsername)
+		} else {
+			fmt.Println("An admin user already exists in the database; leaving it unchanged.")
+		}
+	}
+
 	envPath := filepath.Join(cwd, ".env")
 	updates := map[string]string{
-		"LISTEN_ADDR":         listenAddr,
-		"ALBUM_PATH":          albumPath,
-		"DATA_PATH":           dataPath,
-		"ADMIN_USERNAME":      adminUsername,
-		"ADMIN_PASSWORD_HASH": adminPasswordHash,
+		"LISTEN_ADDR": listenAddr,
+		"ALBUM_PATH":  albumPath,
+		"DATA_PATH":   dataPath,
+	}
+	if !adminInDB {
+		updates["ADMIN_USERNAME"] = adminUsername
+		updates["ADMIN_PASSWORD_HASH"] = adminPasswordHash
 	}
 
 	if err := writeEnvFile(envPath, updates, true); err != nil {
 		fatalf("write .env: %v", err)
 	}
 
+	printSummary(dataAbs, envPath)
+}
+
+// runNonInteractive performs the same setup as the prompts, driven entirely
+// by flags. An admin user is created in the database when a password hash is
+// given; otherwise admin credentials are left to the environment.
+func runNonInteractive(f wizardFlags, cwd string) error {
+	var missing []string
+	for _, req := range []struct{ name, value string }{
+		{"-album", f.album}, {"-data", f.data}, {"-title", f.title}, {"-artist", f.artist},
+	} {
+		if strings.TrimSpace(req.value) == "" {
+			missing = append(missing, req.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required flags: %s", strings.Join(missing, ", "))
+	}
+	listenAddr := strings.TrimSpace(f.listen)
+	if listenAddr == "" {
+		listenAddr = ":8080"
+	}
+	listenAddr, err := config.NormalizeListenAddr(listenAddr)
+	if err != nil {
+		return err
+	}
+
+	cfgMgr, dataAbs, err := prepareConfig(f.album, f.data)
+	if err != nil {
+		return err
+	}
+
+	cfg := cfgMgr.Get()
+	cfg.Title = strings.TrimSpace(f.title)
+	cfg.Artist = strings.TrimSpace(f.artist)
+	if pass := strings.TrimSpace(f.passphrase); pass != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("hash passphrase: %w", err)
+		}
+		cfg.Password = string(hash)
+	}
+	if err := cfgMgr.Update(cfg); err != nil {
+		return fmt.Errorf("save config: %w", err)
+	}
+
+	updates := map[string]string{
+		"LISTEN_ADDR": listenAddr,
+		"ALBUM_PATH":  f.album,
+		"DATA_PATH":   f.data,
+	}
+	if hash := strings.TrimSpace(f.adminPasswordHash); hash != "" {
+		created, err := bootstrapAdminInDB(dataAbs, f.adminUsername, hash)
+		if err != nil {
+			return fmt.Errorf("create admin user: %w", err)
+		}
+		if !created {
+			updates["ADMIN_USERNAME"] = f.adminUsername
+			updates["ADMIN_PASSWORD_HASH"] = hash
+		}
+	}
+
+	envPath := filepath.Join(cwd, ".env")
+	if err := writeEnvFile(envPath, updates, true); err != nil {
+		return fmt.Errorf("write .env: %w", err)
+	}
+
+	printSummary(dataAbs, envPath)
+	return nil
+}
+
+// prepareConfig validates the album folder, creates the data folder, and
+// loads (or generates) the album config.
+func prepareConfig(albumPath, dataPath string) (*config.Manager, string, error) {
+	albumAbs, err := filepath.Abs(albumPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("resolve album path: %w", err)
+	}
+	dataAbs, err := filepath.Abs(dataPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("resolve data path: %w", err)
+	}
+
+	info, err := os.Stat(albumAbs)
+	if err != nil || !info.IsDir() {
+		return nil, "", fmt.Errorf("album folder does not exist or is not a directory: %s", albumAbs)
+	}
+
+	if err := os.MkdirAll(dataAbs, 0755); err != nil {
+		return nil, "", fmt.Errorf("create data folder: %w", err)
+	}
+
+	stems, err := scanAlbum(albumAbs)
+	if err != nil {
+		return nil, "", fmt.Errorf("scan album folder: %w", err)
+	}
+	if len(stems) == 0 {
+		fmt.Println()
+		fmt.Println("WARNING: no .mp3 files found yet. You can still continue, but listeners will see no tracks.")
+	}
+
+	cfgMgr, err := config.NewManager(dataAbs, albumAbs)
+	if err != nil {
+		return nil, "", fmt.Errorf("load/create config: %w", err)
+	}
+	return cfgMgr, dataAbs, nil
+}
+
+func printSummary(dataAbs, envPath string) {
 	fmt.Println()
 	fmt.Println("Setup complete.")
 	fmt.Printf("Config: %s\n", filepath.Join(dataAbs, "config.json"))
@@ -141,6 +293,16 @@ func promptText(reader *bufio.Reader, label, def string) string {
 	return line
 }
 
+func promptListenAddr(reader *bufio.Reader, def string) string {
+	for {
+		addr, err := config.NormalizeListenAddr(promptText(reader, "Listen address", def))
+		if err == nil {
+			return addr
+		}
+		fmt.Println(err)
+	}
+}
+
 func promptYesNo(reader *bufio.Reader, question string, defYes bool) bool {
 	def := "y/N"
 	if defYes {
@@ -247,6 +409,29 @@ func promptAdminBootstrap(reader *bufio.Reader) (string, string) {
 	}
 }
 
+// bootstrapAdminInDB creates the first admin user directly in the database
+// under dataPath. It reports false, without changing anything, when an admin
+// user already exists.
+func bootstrapAdminInDB(dataPath, username, passwordHash string) (bool, error) {
+	db, err := database.Open(dataPath)
+	if err != nil {
+		return false, fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM admin_users").Scan(&count); err != nil {
+		return false, fmt.Errorf("count admin users: %w", err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+	if err := server.EnsureAdminBootstrap(db, username, "", passwordHash); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func scanAlbum(albumPath string) ([]string, error) {
 	entries, err := os.ReadDir(albumPath)
 	if err != nil {
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"acetate/internal/config"
+	"acetate/internal/database"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBootstrapAdminInDB(t *testing.T) {
+	dataDir := t.TempDir()
+	hash, err := bcrypt.GenerateFromPassword([]byte("wizard-admin-123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+
+	created, err := bootstrapAdminInDB(dataDir, "Wizard", string(hash))
+	if err != nil || !created {
+		t.Fatalf("bootstrapAdminInDB = %v, %v; want created", created, err)
+	}
+
+	// A second run must not add or modify admin users.
+	otherHash, _ := bcrypt.GenerateFromPassword([]byte("other-admin-456"), bcrypt.MinCost)
+	created, err = bootstrapAdminInDB(dataDir, "someone", string(otherHash))
+	if err != nil || created {
+		t.Fatalf("second bootstrapAdminInDB = %v, %v; want already configured", created, err)
+	}
+
+	db, err := database.Open(dataDir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	var username, storedHash string
+	if err := db.QueryRow("SELECT COUNT(*), MIN(username), MIN(password_hash) FROM admin_users").Scan(&count, &username, &storedHash); err != nil {
+		t.Fatalf("query admin users: %v", err)
+	}
+	if count != 1 || username != "wizard" || storedHash != string(hash) {
+		t.Fatalf("admin users = %d (%q), hash match = %v", count, username, storedHash == string(hash))
+	}
+}
+
+func TestBootstrapAdminInDBRejectsInvalidHash(t *testing.T) {
+	if _, err := bootstrapAdminInDB(t.TempDir(), "admin", "not-a-bcrypt-hash"); err == nil {
+		t.Fatal("expected an error for an invalid password hash")
+	}
+}
+
+func TestRunNonInteractiveWritesConfigAndEnv(t *testing.T) {
+	root := t.TempDir()
+	albumDir := filepath.Join(root, "album")
+	if err := os.MkdirAll(albumDir, 0755); err != nil {
+		t.Fatalf("mkdir album: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(albumDir, "01-opener.mp3"), []byte("not really audio"), 0644); err != nil {
+		t.Fatalf("write track: %v", err)
+	}
+	adminHash, _ := bcrypt.GenerateFromPassword([]byte("wizard-admin-123"), bcrypt.MinCost)
+
+	flags, err := parseFlags([]string{
+		"-non-interactive",
+		"-album", albumDir,
+		"-data", filepath.Join(root, "data"),
+		"-listen", ":9090",
+		"-title", "Night Drive",
+		"-artist", "The Testers",
+		"-passphrase", "listen-closely",
+		"-admin-password-hash", string(adminHash),
+	})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	if !flags.nonInteractive {
+		t.Fatal("expected -non-interactive to be set")
+	}
+	if err := runNonInteractive(flags, root); err != nil {
+		t.Fatalf("runNonInteractive: %v", err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(root, "data", "config.json"))
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	var cfg config.Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	if cfg.Title != "Night Drive" || cfg.Artist != "The Testers" {
+		t.Fatalf("config title/artist = %q/%q", cfg.Title, cfg.Artist)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(cfg.Password), []byte("listen-closely")) != nil {
+		t.Fatal("config password does not match the -passphrase flag")
+	}
+	if len(cfg.Tracks) != 1 || cfg.Tracks[0].Stem != "01-opener" {
+		t.Fatalf("config tracks = %+v", cfg.Tracks)
+	}
+
+	env, err := os.ReadFile(filepath.Join(root, ".env"))
+	if err != nil {
+		t.Fatalf("read .env: %v", err)
+	}
+	for _, want := range []string{"LISTEN_ADDR=:9090", "ALBUM_PATH=" + albumDir, "DATA_PATH=" + filepath.Join(root, "data")} {
+		if !strings.Contains(string(env), want) {
+			t.Fatalf(".env missing %q:\n%s", want, env)
+		}
+	}
+	// The admin was created in the database, so no credentials land in .env.
+	if strings.Contains(string(env), "ADMIN_PASSWORD_HASH") {
+		t.Fatalf(".env should not contain admin credentials:\n%s", env)
+	}
+}
+
+func TestRunNonInteractiveRequiresFlags(t *testing.T) {
+	flags, err := parseFlags([]string{"-non-interactive", "-album", t.TempDir()})
+	if err != nil {
+		t.Fatalf("parseFlags: %v", err)
+	}
+	err = runNonInteractive(flags, t.TempDir())
+	if err == nil {
+		t.Fatal("expected an error for missing flags")
+	}
+	for _, name := range []string{"-data", "-title", "-artist"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Fatalf("error %q does not mention %s", err, name)
+		}
+	}
+}
+
+func TestRunNonInteractiveValidatesListenAddr(t *testing.T) {
+	root := t.TempDir()
+	albumDir := filepath.Join(root, "album")
+	if err := os.MkdirAll(albumDir, 0755); err != nil {
+		t.Fatalf("mkdir album: %v", err)
+	}
+	base := []string{"-non-interactive", "-album", albumDir, "-data", filepath.Join(root, "data"), "-title", "T", "-artist", "A"}
+
+	flags, _ := parseFlags(append(base, "-listen", "localhost"))
+	if err := runNonInteractive(flags, root); err == nil || !strings.Contains(err.Error(), "listen address") {
+		t.Fatalf("runNonInteractive with bad listen = %v, want listen address error", err)
+	}
+
+	flags, _ = parseFlags(append(base, "-listen", "8080"))
+	if err := runNonInteractive(flags, root); err != nil {
+		t.Fatalf("runNonInteractive: %v", err)
+	}
+	env, err := os.ReadFile(filepath.Join(root, ".env"))
+	if err != nil {
+		t.Fatalf("read .env: %v", err)
+	}
+	if !strings.Contains(string(env), "LISTEN_ADDR=:8080") {
+		t.Fatalf(".env should contain normalized LISTEN_ADDR:\n%s", env)
+	}
+}
@@ -2,7 +2,11 @@ package album
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -21,6 +25,12 @@ type TrackInfo struct {
 	Title        string `json:"title"`
 	DisplayIndex string `json:"display_index,omitempty"`
 	LyricFormat  string `json:"lyric_format,omitempty"`
+	Likes        *int64 `json:"likes,omitempty"`
+	// AllowDownload marks a track downloadable even when the album's
+	// downloads are disabled.
+	AllowDownload bool `json:"allow_download,omitempty"`
+	// DurationSeconds is the track length when known from a scan.
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
 }
 
 func ValidateStem(stem string) bool {
@@ -30,6 +40,51 @@ func ValidateStem(stem string) bool {
 	return stemRegexp.MatchString(stem)
 }
 
+// ValidateSubdir checks a track's slash-separated subdirectory relative to the
+// album root (e.g. "disc1"). Every segment must itself be a valid stem, so
+// absolute paths, "..", and backslashes are rejected. Empty means top level.
+func ValidateSubdir(subdir string) bool {
+	if subdir == "" {
+		return true
+	}
+	if len(subdir) > 255 {
+		return false
+	}
+	for _, segment := range strings.Split(subdir, "/") {
+		if !ValidateStem(segment) {
+			return false
+		}
+	}
+	return true
+}
+
+// TrackDir resolves the directory holding a track's audio and lyric files.
+// It returns false if the subdirectory is invalid or escapes the album root.
+func TrackDir(albumPath, subdir string) (string, bool) {
+	if !ValidateSubdir(subdir) {
+		return "", false
+	}
+	if subdir == "" {
+		return albumPath, true
+	}
+	dir := filepath.Join(albumPath, filepath.FromSlash(subdir))
+	rel, err := filepath.Rel(albumPath, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return dir, true
+}
+
+// FindTrack returns the track with the given stem from the list.
+func FindTrack(stem string, tracks []albums.Track) (albums.Track, bool) {
+	for _, t := range tracks {
+		if t.Stem == stem {
+			return t, true
+		}
+	}
+	return albums.Track{}, false
+}
+
 // StemInTracks checks if the stem exists in the given track list.
 func StemInTracks(stem string, tracks []albums.Track) bool {
 	for _, t := range tracks {
@@ -45,10 +100,14 @@ func GetTrackList(tracks []albums.Track, albumPath string) []TrackInfo {
 	out := make([]TrackInfo, 0, len(tracks))
 	for _, t := range tracks {
 		info := TrackInfo{
-			Stem:         t.Stem,
-			Title:        t.Title,
-			DisplayIndex: t.DisplayIndex,
-			LyricFormat:  detectLyricFormat(albumPath, t.Stem),
+			Stem:            t.Stem,
+			Title:           t.Title,
+			DisplayIndex:    t.DisplayIndex,
+			AllowDownload:   t.AllowDownload,
+			DurationSeconds: t.DurationSeconds,
+		}
+		if dir, ok := TrackDir(albumPath, t.Subdir); ok {
+			info.LyricFormat = detectLyricFormat(dir, t.Stem)
 		}
 		out = append(out, info)
 	}
@@ -72,32 +131,85 @@ func detectLyricFormat(albumPath, stem string) string {
 }
 
 func ServeCover(w http.ResponseWriter, r *http.Request, albumPath, dataPath string, albumID ...int64) {
+	coverPath, info, ok := ResolveCoverPath(albumPath, dataPath, albumID...)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	serveCoverFile(w, r, coverPath, info)
+}
+
+// ResolveCoverPath returns the cover file that ServeCover would serve, if any.
+// Lookup order: per-album override, legacy global override, album directory cover.
+func ResolveCoverPath(albumPath, dataPath string, albumID ...int64) (string, os.FileInfo, bool) {
 	// Check for per-album admin-uploaded override first.
 	if len(albumID) > 0 && albumID[0] > 0 {
 		overridePath := filepath.Join(dataPath, "covers", strconv.FormatInt(albumID[0], 10), "cover_override.jpg")
 		if info, err := os.Stat(overridePath); err == nil {
-			serveCoverFile(w, r, overridePath, info)
-			return
+			return overridePath, info, true
 		}
 	}
 
 	// Legacy global override (for pre-migration albums).
 	overridePath := filepath.Join(dataPath, "cover_override.jpg")
 	if info, err := os.Stat(overridePath); err == nil {
-		serveCoverFile(w, r, overridePath, info)
-		return
+		return overridePath, info, true
 	}
 
 	// Fall back to album directory cover.
 	for _, name := range []string{"cover.jpg", "cover.jpeg", "cover.png"} {
 		coverPath := filepath.Join(albumPath, name)
 		if info, err := os.Stat(coverPath); err == nil {
-			serveCoverFile(w, r, coverPath, info)
-			return
+			return coverPath, info, true
 		}
 	}
 
-	http.NotFound(w, r)
+	return "", nil, false
+}
+
+// ErrNoCover is returned by CheckCover when no cover file exists.
+var ErrNoCover = errors.New("no cover found")
+
+// CheckCover reports whether the cover ServeCover would pick is present and
+// decodes as a JPEG or PNG image, without serving it.
+func CheckCover(albumPath, dataPath string, albumID ...int64) error {
+	coverPath, _, ok := ResolveCoverPath(albumPath, dataPath, albumID...)
+	if !ok {
+		return ErrNoCover
+	}
+	f, err := os.Open(coverPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return fmt.Errorf("decode %s: %w", filepath.Base(coverPath), err)
+	}
+	if format != "jpeg" && format != "png" {
+		return fmt.Errorf("%s: unsupported format %q", filepath.Base(coverPath), format)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return fmt.Errorf("%s: empty image", filepath.Base(coverPath))
+	}
+	return nil
+}
+
+// LogoPath is where an album's uploaded brand logo is stored.
+func LogoPath(dataPath string, albumID int64) string {
+	return filepath.Join(dataPath, "logos", strconv.FormatInt(albumID, 10), "logo.png")
+}
+
+// ServeLogo serves an album's uploaded logo, or 404 when none is set.
+func ServeLogo(w http.ResponseWriter, r *http.Request, dataPath string, albumID int64) {
+	path := LogoPath(dataPath, albumID)
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	serveCoverFile(w, r, path, info)
 }
 
 func serveCoverFile(w http.ResponseWriter, r *http.Request, path string, info os.FileInfo) {
@@ -120,10 +232,88 @@ func serveCoverFile(w http.ResponseWriter, r *http.Request, path string, info os
 	http.ServeContent(w, r, filepath.Base(path), info.ModTime(), f)
 }
 
-func StreamTrack(w http.ResponseWriter, r *http.Request, albumPath, stem string) {
-	mp3Path := filepath.Join(albumPath, stem+".mp3")
-	info, err := os.Stat(mp3Path)
-	if err != nil {
+// audioContentTypes maps scanned audio extensions to their MIME types.
+var audioContentTypes = map[string]string{
+	"mp3":  "audio/mpeg",
+	"flac": "audio/flac",
+	"opus": "audio/ogg",
+	"ogg":  "audio/ogg",
+	"oga":  "audio/ogg",
+	"m4a":  "audio/mp4",
+	"aac":  "audio/aac",
+	"wav":  "audio/wav",
+}
+
+// FindTrackFile returns the audio file for stem in dir, trying extensions in
+// order (mp3 when none are given).
+func FindTrackFile(dir, stem string, extensions ...string) (string, os.FileInfo, bool) {
+	if len(extensions) == 0 {
+		extensions = []string{"mp3"}
+	}
+	for _, ext := range extensions {
+		path := filepath.Join(dir, stem+"."+ext)
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			return path, info, true
+		}
+	}
+	return "", nil, false
+}
+
+// lyricExtensions lists every sidecar extension read for a track's lyrics.
+var lyricExtensions = []string{".lrc", ".srt", ".txt", ".md"}
+
+// DeleteTrackFiles removes stem's audio files (for each extension, mp3 when
+// none are given) and lyric sidecars from dir. Only regular files and
+// symlinks named exactly <stem>.<ext> are touched; a rejected stem removes
+// nothing. It returns the base names of the removed files.
+func DeleteTrackFiles(dir, stem string, extensions ...string) ([]string, error) {
+	if !ValidateStem(stem) {
+		return nil, fmt.Errorf("invalid stem %q", stem)
+	}
+	if len(extensions) == 0 {
+		extensions = []string{"mp3"}
+	}
+	names := make([]string, 0, len(extensions)+len(lyricExtensions))
+	for _, ext := range extensions {
+		names = append(names, stem+"."+ext)
+	}
+	for _, ext := range lyricExtensions {
+		names = append(names, stem+ext)
+	}
+
+	removed := make([]string, 0, len(names))
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		info, err := os.Lstat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return removed, err
+		}
+		if !info.Mode().IsRegular() && info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
+
+// AudioContentType returns the MIME type for an audio file path.
+func AudioContentType(path string) string {
+	if ct, ok := audioContentTypes[strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))]; ok {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+func StreamTrack(w http.ResponseWriter, r *http.Request, albumPath, stem string, extensions ...string) {
+	mp3Path, info, ok := FindTrackFile(albumPath, stem, extensions...)
+	if !ok {
 		http.NotFound(w, r)
 		return
 	}
@@ -146,7 +336,7 @@ func StreamTrack(w http.ResponseWriter, r *http.Request, albumPath, stem string)
 	}
 	defer f.Close()
 
-	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Header().Set("Content-Type", AudioContentType(mp3Path))
 	w.Header().Set("Accept-Ranges", "bytes")
-	http.ServeContent(w, r, stem+".mp3", time.Time{}, f)
+	http.ServeContent(w, r, filepath.Base(mp3Path), time.Time{}, f)
 }
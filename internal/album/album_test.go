@@ -1,6 +1,10 @@
 package album
 
 import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
 	"os"
 	"path/filepath"
 	"testing"
@@ -24,6 +28,37 @@ func TestValidateStem(t *testing.T) {
 	}
 }
 
+func TestValidateSubdir(t *testing.T) {
+	valid := []string{"", "disc1", "Disc 2", "cd1/side a"}
+	invalid := []string{"..", "../disc1", "disc1/..", "/disc1", "disc1/", `disc1\x`, "disc1//x", ".hidden"}
+
+	for _, s := range valid {
+		if !ValidateSubdir(s) {
+			t.Errorf("ValidateSubdir(%q) = false, want true", s)
+		}
+	}
+	for _, s := range invalid {
+		if ValidateSubdir(s) {
+			t.Errorf("ValidateSubdir(%q) = true, want false", s)
+		}
+	}
+}
+
+func TestTrackDir(t *testing.T) {
+	root := t.TempDir()
+
+	dir, ok := TrackDir(root, "disc1")
+	if !ok || dir != filepath.Join(root, "disc1") {
+		t.Errorf("TrackDir(disc1) = %q, %v", dir, ok)
+	}
+	if dir, ok := TrackDir(root, ""); !ok || dir != root {
+		t.Errorf("TrackDir(\"\") = %q, %v", dir, ok)
+	}
+	if _, ok := TrackDir(root, "../escape"); ok {
+		t.Error("TrackDir should reject traversal")
+	}
+}
+
 func TestStemInTracks(t *testing.T) {
 	tracks := []albums.Track{
 		{Stem: "01-gathering", Title: "Gathering"},
@@ -101,3 +136,46 @@ func TestLyricFormatPriority(t *testing.T) {
 		t.Errorf("expected lrc to take priority, got %q", got)
 	}
 }
+
+func TestLyricFormatMatchesTrackList(t *testing.T) {
+	dir := t.TempDir()
+
+	os.WriteFile(filepath.Join(dir, "both.md"), []byte("# markdown"), 0644)
+	os.WriteFile(filepath.Join(dir, "both.txt"), []byte("plain"), 0644)
+	os.WriteFile(filepath.Join(dir, "synced.srt"), []byte("1\n00:00:00,000 --> 00:00:01,000\nline"), 0644)
+
+	tracks := []albums.Track{{Stem: "both"}, {Stem: "synced"}}
+	for _, info := range GetTrackList(tracks, dir) {
+		if got := LyricFormat(dir, info.Stem); got != info.LyricFormat {
+			t.Errorf("LyricFormat(%q) = %q, track list reports %q", info.Stem, got, info.LyricFormat)
+		}
+	}
+	if got := LyricFormat(dir, "synced"); got != "lrc" {
+		t.Errorf("LyricFormat(synced) = %q, want lrc for .srt", got)
+	}
+}
+
+func TestCheckCover(t *testing.T) {
+	albumDir := t.TempDir()
+	dataDir := t.TempDir()
+
+	if err := CheckCover(albumDir, dataDir, 1); !errors.Is(err, ErrNoCover) {
+		t.Fatalf("missing cover err = %v, want ErrNoCover", err)
+	}
+
+	os.WriteFile(filepath.Join(albumDir, "cover.jpg"), []byte("not an image"), 0644)
+	if err := CheckCover(albumDir, dataDir, 1); err == nil {
+		t.Fatal("undecodable cover err = nil")
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	overrideDir := filepath.Join(dataDir, "covers", "1")
+	os.MkdirAll(overrideDir, 0755)
+	os.WriteFile(filepath.Join(overrideDir, "cover_override.jpg"), buf.Bytes(), 0644)
+	if err := CheckCover(albumDir, dataDir, 1); err != nil {
+		t.Fatalf("override cover err = %v", err)
+	}
+}
@@ -20,6 +20,12 @@ type LyricsResponse struct {
 	StructureContent string `json:"structure_content,omitempty"`
 }
 
+// LyricFormat reports the lyric format for stem without reading the file,
+// or "" when no lyrics exist. It is the format the track list reports.
+func LyricFormat(albumPath, stem string) string {
+	return detectLyricFormat(albumPath, stem)
+}
+
 // ServeLyrics finds and serves lyrics for a track stem.
 func ServeLyrics(w http.ResponseWriter, albumPath, stem string) *LyricsResponse {
 	// Priority: lrc > txt > md
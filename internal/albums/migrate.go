@@ -40,9 +40,10 @@ func MigrateFromConfigJSON(db *sql.DB, dataPath, albumPath string) error {
 		Artist   string `json:"artist"`
 		Password string `json:"password"`
 		Tracks   []struct {
-			Stem         string `json:"stem"`
-			Title        string `json:"title"`
-			DisplayIndex string `json:"display_index"`
+			Stem          string `json:"stem"`
+			Title         string `json:"title"`
+			DisplayIndex  string `json:"display_index"`
+			AllowDownload bool   `json:"allow_download"`
 		} `json:"tracks"`
 	}
 	if err := json.Unmarshal(data, &cfg); err != nil {
@@ -72,8 +73,8 @@ func MigrateFromConfigJSON(db *sql.DB, dataPath, albumPath string) error {
 	// 2. Create tracks
 	for i, t := range cfg.Tracks {
 		if _, err := tx.Exec(
-			"INSERT INTO album_tracks (album_id, stem, title, display_index, sort_order) VALUES (?, ?, ?, ?, ?)",
-			albumID, t.Stem, t.Title, t.DisplayIndex, i,
+			"INSERT INTO album_tracks (album_id, stem, title, display_index, sort_order, allow_download) VALUES (?, ?, ?, ?, ?, ?)",
+			albumID, t.Stem, t.Title, t.DisplayIndex, i, t.AllowDownload,
 		); err != nil {
 			return fmt.Errorf("insert track %q: %w", t.Stem, err)
 		}
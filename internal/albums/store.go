@@ -12,14 +12,23 @@ import (
 
 // Album represents an album in the database.
 type Album struct {
-	ID               int64  `json:"id"`
-	Slug             string `json:"slug"`
-	Title            string `json:"title"`
-	Artist           string `json:"artist"`
-	AlbumPath        string `json:"album_path"`
-	DownloadsEnabled bool   `json:"downloads_enabled"`
-	CreatedAt        string `json:"created_at"`
-	UpdatedAt        string `json:"updated_at"`
+	ID               int64    `json:"id"`
+	Slug             string   `json:"slug"`
+	Title            string   `json:"title"`
+	Artist           string   `json:"artist"`
+	AlbumPath        string   `json:"album_path"`
+	DownloadsEnabled bool     `json:"downloads_enabled"`
+	FeedbackEnabled  bool     `json:"feedback_enabled"`
+	LikesEnabled     bool     `json:"likes_enabled"`
+	Branding         Branding `json:"branding"`
+	CreatedAt        string   `json:"created_at"`
+	UpdatedAt        string   `json:"updated_at"`
+}
+
+// Branding is optional per-album theming shown to listeners.
+type Branding struct {
+	AccentColor string `json:"accent_color,omitempty"` // "#rrggbb"
+	LogoURL     string `json:"logo_url,omitempty"`
 }
 
 // Track represents a track within an album.
@@ -30,6 +39,14 @@ type Track struct {
 	Title        string `json:"title"`
 	DisplayIndex string `json:"display_index"`
 	SortOrder    int    `json:"sort_order"`
+	Subdir       string `json:"subdir,omitempty"`
+	// AllowDownload permits downloading this track even when the album has
+	// downloads disabled.
+	AllowDownload bool `json:"allow_download"`
+	// DurationSeconds caches the MP3 length read at scan time; ModTime is
+	// the file modification time (Unix nanoseconds) it was read at.
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	ModTime         int64   `json:"-"`
 }
 
 // Password represents a listener password.
@@ -76,8 +93,8 @@ func (s *Store) CreateAlbum(title, artist, albumPath string) (*Album, error) {
 func (s *Store) GetAlbum(id int64) (*Album, error) {
 	a := &Album{}
 	err := s.db.QueryRow(
-		"SELECT id, slug, title, artist, album_path, downloads_enabled, created_at, updated_at FROM albums WHERE id = ?", id,
-	).Scan(&a.ID, &a.Slug, &a.Title, &a.Artist, &a.AlbumPath, &a.DownloadsEnabled, &a.CreatedAt, &a.UpdatedAt)
+		"SELECT id, slug, title, artist, album_path, downloads_enabled, feedback_enabled, likes_enabled, accent_color, logo_url, created_at, updated_at FROM albums WHERE id = ?", id,
+	).Scan(&a.ID, &a.Slug, &a.Title, &a.Artist, &a.AlbumPath, &a.DownloadsEnabled, &a.FeedbackEnabled, &a.LikesEnabled, &a.Branding.AccentColor, &a.Branding.LogoURL, &a.CreatedAt, &a.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -91,8 +108,8 @@ func (s *Store) GetAlbum(id int64) (*Album, error) {
 func (s *Store) GetAlbumBySlug(slug string) (*Album, error) {
 	a := &Album{}
 	err := s.db.QueryRow(
-		"SELECT id, slug, title, artist, album_path, downloads_enabled, created_at, updated_at FROM albums WHERE slug = ?", slug,
-	).Scan(&a.ID, &a.Slug, &a.Title, &a.Artist, &a.AlbumPath, &a.DownloadsEnabled, &a.CreatedAt, &a.UpdatedAt)
+		"SELECT id, slug, title, artist, album_path, downloads_enabled, feedback_enabled, likes_enabled, accent_color, logo_url, created_at, updated_at FROM albums WHERE slug = ?", slug,
+	).Scan(&a.ID, &a.Slug, &a.Title, &a.Artist, &a.AlbumPath, &a.DownloadsEnabled, &a.FeedbackEnabled, &a.LikesEnabled, &a.Branding.AccentColor, &a.Branding.LogoURL, &a.CreatedAt, &a.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -104,7 +121,7 @@ func (s *Store) GetAlbumBySlug(slug string) (*Album, error) {
 
 // ListAlbums returns all albums ordered by ID.
 func (s *Store) ListAlbums() ([]Album, error) {
-	rows, err := s.db.Query("SELECT id, slug, title, artist, album_path, downloads_enabled, created_at, updated_at FROM albums ORDER BY id")
+	rows, err := s.db.Query("SELECT id, slug, title, artist, album_path, downloads_enabled, feedback_enabled, likes_enabled, accent_color, logo_url, created_at, updated_at FROM albums ORDER BY id")
 	if err != nil {
 		return nil, fmt.Errorf("list albums: %w", err)
 	}
@@ -113,7 +130,7 @@ func (s *Store) ListAlbums() ([]Album, error) {
 	var albums []Album
 	for rows.Next() {
 		var a Album
-		if err := rows.Scan(&a.ID, &a.Slug, &a.Title, &a.Artist, &a.AlbumPath, &a.DownloadsEnabled, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.Slug, &a.Title, &a.Artist, &a.AlbumPath, &a.DownloadsEnabled, &a.FeedbackEnabled, &a.LikesEnabled, &a.Branding.AccentColor, &a.Branding.LogoURL, &a.CreatedAt, &a.UpdatedAt); err != nil {
 			return nil, err
 		}
 		albums = append(albums, a)
@@ -161,6 +178,44 @@ func (s *Store) SetDownloadsEnabled(id int64, enabled bool) error {
 	return err
 }
 
+// SetFeedbackEnabled updates the feedback_enabled flag for an album.
+func (s *Store) SetFeedbackEnabled(id int64, enabled bool) error {
+	val := 0
+	if enabled {
+		val = 1
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := s.db.Exec(
+		"UPDATE albums SET feedback_enabled = ?, updated_at = ? WHERE id = ?",
+		val, now, id,
+	)
+	return err
+}
+
+// SetLikesEnabled updates the likes_enabled flag for an album.
+func (s *Store) SetLikesEnabled(id int64, enabled bool) error {
+	val := 0
+	if enabled {
+		val = 1
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := s.db.Exec(
+		"UPDATE albums SET likes_enabled = ?, updated_at = ? WHERE id = ?",
+		val, now, id,
+	)
+	return err
+}
+
+// SetBranding replaces an album's branding metadata.
+func (s *Store) SetBranding(id int64, branding Branding) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := s.db.Exec(
+		"UPDATE albums SET accent_color = ?, logo_url = ?, updated_at = ? WHERE id = ?",
+		branding.AccentColor, branding.LogoURL, now, id,
+	)
+	return err
+}
+
 // DeleteAlbum removes an album and its tracks and password links.
 func (s *Store) DeleteAlbum(id int64) error {
 	tx, err := s.db.Begin()
@@ -171,6 +226,8 @@ func (s *Store) DeleteAlbum(id int64) error {
 
 	tx.Exec("DELETE FROM album_tracks WHERE album_id = ?", id)
 	tx.Exec("DELETE FROM password_album_access WHERE album_id = ?", id)
+	tx.Exec("DELETE FROM listener_feedback WHERE album_id = ?", id)
+	tx.Exec("DELETE FROM track_likes WHERE album_id = ?", id)
 	tx.Exec("DELETE FROM albums WHERE id = ?", id)
 	return tx.Commit()
 }
@@ -187,7 +244,7 @@ func (s *Store) AlbumCount() (int, error) {
 // GetTracks returns tracks for an album ordered by sort_order.
 func (s *Store) GetTracks(albumID int64) ([]Track, error) {
 	rows, err := s.db.Query(
-		"SELECT id, album_id, stem, title, display_index, sort_order FROM album_tracks WHERE album_id = ? ORDER BY sort_order",
+		"SELECT id, album_id, stem, title, display_index, sort_order, subdir, allow_download, duration_seconds, duration_mtime FROM album_tracks WHERE album_id = ? ORDER BY sort_order",
 		albumID,
 	)
 	if err != nil {
@@ -198,7 +255,7 @@ func (s *Store) GetTracks(albumID int64) ([]Track, error) {
 	var tracks []Track
 	for rows.Next() {
 		var t Track
-		if err := rows.Scan(&t.ID, &t.AlbumID, &t.Stem, &t.Title, &t.DisplayIndex, &t.SortOrder); err != nil {
+		if err := rows.Scan(&t.ID, &t.AlbumID, &t.Stem, &t.Title, &t.DisplayIndex, &t.SortOrder, &t.Subdir, &t.AllowDownload, &t.DurationSeconds, &t.ModTime); err != nil {
 			return nil, err
 		}
 		tracks = append(tracks, t)
@@ -219,7 +276,7 @@ func (s *Store) SetTracks(albumID int64, tracks []Track) error {
 	}
 
 	stmt, err := tx.Prepare(
-		"INSERT INTO album_tracks (album_id, stem, title, display_index, sort_order) VALUES (?, ?, ?, ?, ?)",
+		"INSERT INTO album_tracks (album_id, stem, title, display_index, sort_order, subdir, allow_download, duration_seconds, duration_mtime) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
 	)
 	if err != nil {
 		return err
@@ -227,7 +284,7 @@ func (s *Store) SetTracks(albumID int64, tracks []Track) error {
 	defer stmt.Close()
 
 	for i, t := range tracks {
-		if _, err := stmt.Exec(albumID, t.Stem, t.Title, t.DisplayIndex, i); err != nil {
+		if _, err := stmt.Exec(albumID, t.Stem, t.Title, t.DisplayIndex, i, t.Subdir, t.AllowDownload, t.DurationSeconds, t.ModTime); err != nil {
 			return fmt.Errorf("insert track %q: %w", t.Stem, err)
 		}
 	}
@@ -235,6 +292,19 @@ func (s *Store) SetTracks(albumID int64, tracks []Track) error {
 	return tx.Commit()
 }
 
+// SetTrackDuration records a track's decoded duration and the file
+// modification time it was read at.
+func (s *Store) SetTrackDuration(albumID int64, stem string, seconds float64, modTime int64) error {
+	_, err := s.db.Exec(
+		"UPDATE album_tracks SET duration_seconds = ?, duration_mtime = ? WHERE album_id = ? AND stem = ?",
+		seconds, modTime, albumID, stem,
+	)
+	if err != nil {
+		return fmt.Errorf("set track duration: %w", err)
+	}
+	return nil
+}
+
 // StemInAlbum checks if a stem exists in an album's track list.
 func (s *Store) StemInAlbum(albumID int64, stem string) (bool, error) {
 	var count int
@@ -314,6 +384,40 @@ func (s *Store) CreatePasswordWithHash(label, passwordHash string, albumIDs []in
 	return &Password{ID: id, Label: label, AlbumIDs: retIDs, CreatedAt: now, UpdatedAt: now}, nil
 }
 
+// BootstrapPassword creates a "Default" listener password from a bcrypt hash
+// with access to every album, but only when no listener passwords exist yet.
+// It reports whether a password was created.
+func (s *Store) BootstrapPassword(passwordHash string) (bool, error) {
+	passwordHash = strings.TrimSpace(passwordHash)
+	if passwordHash == "" {
+		return false, nil
+	}
+	if _, err := bcrypt.Cost([]byte(passwordHash)); err != nil {
+		return false, fmt.Errorf("invalid album password hash")
+	}
+
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM listener_passwords").Scan(&count); err != nil {
+		return false, fmt.Errorf("count passwords: %w", err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	albums, err := s.ListAlbums()
+	if err != nil {
+		return false, err
+	}
+	albumIDs := make([]int64, 0, len(albums))
+	for _, a := range albums {
+		albumIDs = append(albumIDs, a.ID)
+	}
+	if _, err := s.CreatePasswordWithHash("Default", passwordHash, albumIDs); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // ListPasswords returns all passwords with their linked album IDs.
 func (s *Store) ListPasswords() ([]Password, error) {
 	rows, err := s.db.Query(
@@ -440,7 +544,7 @@ func (s *Store) VerifyPassword(passphrase string) (int64, []int64, error) {
 // GetAlbumsForPassword returns the albums a password grants access to.
 func (s *Store) GetAlbumsForPassword(passwordID int64) ([]Album, error) {
 	rows, err := s.db.Query(
-		`SELECT a.id, a.slug, a.title, a.artist, a.album_path, a.downloads_enabled, a.created_at, a.updated_at
+		`SELECT a.id, a.slug, a.title, a.artist, a.album_path, a.downloads_enabled, a.feedback_enabled, a.likes_enabled, a.accent_color, a.logo_url, a.created_at, a.updated_at
 		 FROM albums a
 		 INNER JOIN password_album_access pa ON pa.album_id = a.id
 		 WHERE pa.password_id = ?
@@ -454,7 +558,7 @@ func (s *Store) GetAlbumsForPassword(passwordID int64) ([]Album, error) {
 	var albums []Album
 	for rows.Next() {
 		var a Album
-		if err := rows.Scan(&a.ID, &a.Slug, &a.Title, &a.Artist, &a.AlbumPath, &a.DownloadsEnabled, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.Slug, &a.Title, &a.Artist, &a.AlbumPath, &a.DownloadsEnabled, &a.FeedbackEnabled, &a.LikesEnabled, &a.Branding.AccentColor, &a.Branding.LogoURL, &a.CreatedAt, &a.UpdatedAt); err != nil {
 			return nil, err
 		}
 		albums = append(albums, a)
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"strings"
@@ -27,6 +28,78 @@ const (
 	MaxStringSize = 512
 )
 
+// Ingest limits for analytics batch requests. The body limit and event cap
+// are configured together; see ValidateIngestLimits.
+const (
+	DefaultMaxBodyBytes = 100 * 1024
+	MinMaxBodyBytes     = 1024
+	MaxMaxBodyBytes     = 8 << 20
+	MaxMaxBatchSize     = 10000
+	// MinEventJSONBytes approximates the smallest encoded event, e.g.
+	// {"event_type":"play"} plus a separating comma.
+	MinEventJSONBytes = 22
+)
+
+// ValidateIngestLimits checks an analytics body size limit and per-batch
+// event cap for consistency: both must be in range and a body of the given
+// size must be able to hold a full batch of minimal events.
+func ValidateIngestLimits(maxBodyBytes int64, maxBatchSize int) error {
+	if maxBodyBytes < MinMaxBodyBytes || maxBodyBytes > MaxMaxBodyBytes {
+		return fmt.Errorf("max body bytes %d out of range [%d, %d]", maxBodyBytes, MinMaxBodyBytes, MaxMaxBodyBytes)
+	}
+	if maxBatchSize < 1 || maxBatchSize > MaxMaxBatchSize {
+		return fmt.Errorf("max batch size %d out of range [1, %d]", maxBatchSize, MaxMaxBatchSize)
+	}
+	if int64(maxBatchSize)*MinEventJSONBytes > maxBodyBytes {
+		return fmt.Errorf("max batch size %d cannot fit in %d body bytes", maxBatchSize, maxBodyBytes)
+	}
+	return nil
+}
+
+// DefaultCompleteMinFraction is the share of a track a listener must have
+// reached for a complete event to be accepted, when the length is known.
+const DefaultCompleteMinFraction = 0.9
+
+// DefaultFlushStallThreshold is how long the flush loop may go without a
+// successful flush before FlushHealth reports it stalled.
+const DefaultFlushStallThreshold = 2 * time.Minute
+
+// Bounds for runtime flush tuning via SetFlushSize and SetFlushInterval.
+const (
+	MinFlushInterval = 100 * time.Millisecond
+	MaxFlushInterval = 5 * time.Minute
+)
+
+// CollectorStats is a point-in-time snapshot of collector state.
+type CollectorStats struct {
+	BufferDepth     int   `json:"buffer_depth"`
+	BufferCapacity  int   `json:"buffer_capacity"`
+	FlushSize       int   `json:"flush_size"`
+	FlushIntervalMS int64 `json:"flush_interval_ms"`
+	Dropped         int64 `json:"dropped"`
+	Rejected        int64 `json:"rejected"`
+	DeadLettered    int64 `json:"dead_lettered"`
+	DeadLetterLost  int64 `json:"dead_letter_lost"`
+	Spilled         int64 `json:"spilled"`
+	SpillBytes      int64 `json:"spill_bytes"`
+	Duplicates      int64 `json:"duplicate_batches"`
+}
+
+// FlushHealth reports whether the flush loop is still making progress.
+type FlushHealth struct {
+	LastFlushAt       time.Time `json:"last_flush_at"`
+	SecondsSinceFlush float64   `json:"seconds_since_flush"`
+	ThresholdSeconds  float64   `json:"threshold_seconds"`
+	Stalled           bool      `json:"stalled"`
+}
+
+// Flush retry policy for transient lock contention. Backoff grows linearly
+// per attempt, keeping the worst case well under DrainTimeout.
+const (
+	FlushMaxAttempts  = 3
+	FlushRetryBackoff = 50 * time.Millisecond
+)
+
 // Event represents an analytics event from the client.
 type Event struct {
 	SessionID       string  `json:"session_id"`
@@ -35,6 +108,22 @@ type Event struct {
 	PositionSeconds float64 `json:"position_seconds,omitempty"`
 	Metadata        string  `json:"metadata,omitempty"`
 	AlbumID         int64   `json:"album_id,omitempty"`
+
+	// RecordedAt is when the event reached the collector; Record sets it
+	// if zero. It becomes the row's created_at, and rollups count the event
+	// toward this day, so events written late (spilled, or replayed from the
+	// pending file) keep their original time.
+	RecordedAt time.Time `json:"recorded_at,omitzero"`
+}
+
+// recordedDay returns the UTC day e counts toward, falling back to today
+// for events that carry no record time.
+func (e Event) recordedDay() string {
+	at := e.RecordedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return at.UTC().Format(sqliteDayLayout)
 }
 
 // highValueEvents are worth brief backpressure when the channel is full.
@@ -57,24 +146,135 @@ var validEventTypes = map[string]bool{
 }
 
 // Collector manages buffered analytics event ingestion.
+//
+// Only flushLoop ever writes to the database, so flushes never overlap.
+// FlushNow callers that arrive before the loop picks up a pending request
+// share that request instead of queueing another flush.
 type Collector struct {
 	db       *sql.DB
 	events   chan Event
-	flushReq chan chan struct{}
+	flushSig chan struct{}
 	done     chan struct{}
 	wg       sync.WaitGroup
 	once     sync.Once
 	dropped  atomic.Int64
 	rejected atomic.Int64
+	spilled  atomic.Int64
+	dupes    atomic.Int64
+
+	deadLetter    *deadLetterSink
+	pendingPath   string
+	spill         *spillQueue
+	batches       *batchDedup
+	drainTimeout  time.Duration
+	maxBatch      int
+	aggregateOnly bool
+
+	// Tunable at runtime; retune wakes flushLoop to reset its ticker.
+	flushSize     atomic.Int64
+	flushInterval atomic.Int64
+	retune        chan struct{}
+
+	// Completion check; see SetCompletionCheck.
+	completeFraction float64
+	trackDuration    func(albumID int64, stem string) (float64, bool)
+
+	// commit finishes a flush transaction; replaceable in tests.
+	commit func(*sql.Tx) error
+
+	// streamBytes buffers RecordStreamBytes counts until the next flush.
+	streamBytes streamBytesBuffer
+
+	// closeCtx bounds the final write; set by CloseContext before done is
+	// closed.
+	closeCtx context.Context
+
+	// lastFlush is when flushLoop last wrote a batch or found nothing to
+	// write, in Unix nanoseconds; see FlushHealth.
+	lastFlush atomic.Int64
+
+	flushMu      sync.Mutex
+	pendingFlush chan struct{} // closed once the pending flush completes; nil when none is pending
+}
+
+// CollectorOptions configures optional Collector behavior.
+type CollectorOptions struct {
+	// DeadLetterPath is a JSON-lines file receiving events that fail to
+	// insert. Empty disables the dead-letter sink.
+	DeadLetterPath string
+	// DeadLetterMaxBytes bounds the dead-letter file size; events beyond
+	// it are dropped and counted. Defaults to DefaultDeadLetterMaxBytes.
+	DeadLetterMaxBytes int64
+	// MaxBatchSize caps events per RecordBatch call. Defaults to MaxBatchSize.
+	MaxBatchSize int
+	// AggregateOnly counts events straight into analytics_rollups_daily
+	// instead of storing raw rows in events. Events that fail to write are
+	// dropped rather than dead-lettered.
+	AggregateOnly bool
+	// PendingPath is a JSON-lines file receiving events still unwritten
+	// when Close gives up on the database or the drain times out; they are
+	// replayed when the next collector starts. Empty keeps the old behavior
+	// of logging and losing them.
+	PendingPath string
+	// BufferSize is the in-memory event queue capacity. Defaults to
+	// ChannelBuffer.
+	BufferSize int
+	// SpillPath enables a disk-backed overflow queue: events that find the
+	// in-memory queue full are appended here instead of being dropped and
+	// written to the database as the queue frees up. Empty disables it.
+	SpillPath string
+	// SpillMaxBytes caps the overflow queue on disk; events beyond it are
+	// dropped. Defaults to DefaultSpillMaxBytes.
+	SpillMaxBytes int64
+	// BatchIDTTL is how long a client-supplied batch ID is remembered for
+	// duplicate detection. Defaults to DefaultBatchIDTTL.
+	BatchIDTTL time.Duration
 }
 
 // NewCollector creates an analytics collector with a buffered channel and flush goroutine.
 func NewCollector(db *sql.DB) *Collector {
+	return NewCollectorWithOptions(db, CollectorOptions{})
+}
+
+// NewCollectorWithOptions creates a collector with the given options.
+func NewCollectorWithOptions(db *sql.DB, opts CollectorOptions) *Collector {
+	bufferSize := opts.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = ChannelBuffer
+	}
 	c := &Collector{
-		db:       db,
-		events:   make(chan Event, ChannelBuffer),
-		flushReq: make(chan chan struct{}),
-		done:     make(chan struct{}),
+		db:            db,
+		events:        make(chan Event, bufferSize),
+		flushSig:      make(chan struct{}, 1),
+		done:          make(chan struct{}),
+		commit:        (*sql.Tx).Commit,
+		maxBatch:      opts.MaxBatchSize,
+		aggregateOnly: opts.AggregateOnly,
+		pendingPath:   opts.PendingPath,
+		drainTimeout:  DrainTimeout,
+		retune:        make(chan struct{}, 1),
+		batches:       newBatchDedup(opts.BatchIDTTL),
+	}
+	c.lastFlush.Store(time.Now().UnixNano())
+	c.flushSize.Store(int64(min(FlushSize, bufferSize)))
+	c.flushInterval.Store(int64(FlushInterval))
+	if c.maxBatch <= 0 {
+		c.maxBatch = MaxBatchSize
+	}
+	if opts.DeadLetterPath != "" {
+		c.deadLetter = newDeadLetterSink(opts.DeadLetterPath, opts.DeadLetterMaxBytes)
+	}
+	if opts.SpillPath != "" {
+		c.spill = newSpillQueue(opts.SpillPath, opts.SpillMaxBytes)
+	}
+	if c.pendingPath != "" {
+		res, err := c.replayPending()
+		if err != nil {
+			log.Printf("analytics: %v", err)
+		}
+		if res.Replayed > 0 || res.Corrupt > 0 {
+			log.Printf("analytics: replayed %d pending events, skipped %d corrupt lines", res.Replayed, res.Corrupt)
+		}
 	}
 	c.wg.Add(1)
 	go c.flushLoop()
@@ -83,18 +283,22 @@ func NewCollector(db *sql.DB) *Collector {
 
 // Record submits an event to the analytics channel.
 // High-value events block briefly (100ms); low-value events are dropped immediately if full.
+// With a spill queue configured, events that would be dropped go to disk instead.
 func (c *Collector) Record(e Event) {
+	if e.RecordedAt.IsZero() {
+		e.RecordedAt = time.Now()
+	}
 	if highValueEvents[e.EventType] {
 		select {
 		case c.events <- e:
 		case <-time.After(100 * time.Millisecond):
-			c.dropped.Add(1)
+			c.overflow(e)
 		}
 	} else {
 		select {
 		case c.events <- e:
 		default:
-			c.dropped.Add(1)
+			c.overflow(e)
 		}
 	}
 }
@@ -109,16 +313,120 @@ func (c *Collector) RejectedCount() int64 {
 	return c.rejected.Load()
 }
 
-// FlushNow forces a synchronous flush of currently buffered events.
-func (c *Collector) FlushNow(ctx context.Context) error {
-	ack := make(chan struct{})
+// Stats returns current buffer depth, flush settings, and counters.
+func (c *Collector) Stats() CollectorStats {
+	written, lost := c.DeadLetterCount()
+	var spillBytes int64
+	if c.spill != nil {
+		spillBytes = c.spill.size()
+	}
+	return CollectorStats{
+		BufferDepth:     len(c.events),
+		BufferCapacity:  cap(c.events),
+		FlushSize:       int(c.flushSize.Load()),
+		FlushIntervalMS: time.Duration(c.flushInterval.Load()).Milliseconds(),
+		Dropped:         c.dropped.Load(),
+		Rejected:        c.rejected.Load(),
+		DeadLettered:    written,
+		DeadLetterLost:  lost,
+		Spilled:         c.spilled.Load(),
+		SpillBytes:      spillBytes,
+		Duplicates:      c.dupes.Load(),
+	}
+}
+
+// SetFlushSize changes how many buffered events trigger a flush.
+func (c *Collector) SetFlushSize(n int) error {
+	if n < 1 || n > cap(c.events) {
+		return fmt.Errorf("flush size %d out of range [1, %d]", n, cap(c.events))
+	}
+	c.flushSize.Store(int64(n))
+	return nil
+}
+
+// SetCompletionCheck makes RecordBatch reject complete events whose
+// position is below fraction of the track's duration, as reported by
+// duration in seconds. Events for tracks of unknown length are accepted.
+// A non-positive fraction disables the check. Call before recording.
+func (c *Collector) SetCompletionCheck(fraction float64, duration func(albumID int64, stem string) (float64, bool)) {
+	c.completeFraction = min(fraction, 1)
+	c.trackDuration = duration
+}
+
+// completeFloor returns the minimum position in seconds for a complete event
+// on stem, or zero when it is not checked.
+func (c *Collector) completeFloor(albumID int64, stem string) float64 {
+	if c.completeFraction <= 0 || c.trackDuration == nil {
+		return 0
+	}
+	seconds, ok := c.trackDuration(albumID, stem)
+	if !ok || seconds <= 0 {
+		return 0
+	}
+	return seconds * c.completeFraction
+}
+
+// SetFlushInterval changes the periodic flush interval. The new interval
+// takes effect immediately.
+func (c *Collector) SetFlushInterval(d time.Duration) error {
+	if d < MinFlushInterval || d > MaxFlushInterval {
+		return fmt.Errorf("flush interval %s out of range [%s, %s]", d, MinFlushInterval, MaxFlushInterval)
+	}
+	c.flushInterval.Store(int64(d))
 	select {
-	case c.flushReq <- ack:
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-c.done:
-		return errors.New("collector closed")
+	case c.retune <- struct{}{}:
+	default:
 	}
+	return nil
+}
+
+// DeadLetterCount returns the number of failed events written to the
+// dead-letter sink, and the number lost because the sink was full or failing.
+func (c *Collector) DeadLetterCount() (written, lost int64) {
+	if c.deadLetter == nil {
+		return 0, 0
+	}
+	return c.deadLetter.written.Load(), c.deadLetter.lost.Load()
+}
+
+// FlushHealth reports how long ago the flush loop last completed a flush.
+// Idle ticks with nothing to write count as successful, so only a loop that
+// is hung (e.g. on a wedged database) or failing every write goes stale.
+// The loop is stalled once the gap exceeds threshold, which is never taken
+// as less than twice the flush interval; non-positive means
+// DefaultFlushStallThreshold.
+func (c *Collector) FlushHealth(threshold time.Duration) FlushHealth {
+	if threshold <= 0 {
+		threshold = DefaultFlushStallThreshold
+	}
+	threshold = max(threshold, 2*time.Duration(c.flushInterval.Load()))
+	last := time.Unix(0, c.lastFlush.Load()).UTC()
+	since := time.Since(last)
+	return FlushHealth{
+		LastFlushAt:       last,
+		SecondsSinceFlush: since.Seconds(),
+		ThresholdSeconds:  threshold.Seconds(),
+		Stalled:           since > threshold,
+	}
+}
+
+func (c *Collector) markFlushed() {
+	c.lastFlush.Store(time.Now().UnixNano())
+}
+
+// FlushNow forces a synchronous flush of events recorded before the call.
+// Concurrent callers coalesce onto a single pending flush.
+func (c *Collector) FlushNow(ctx context.Context) error {
+	c.flushMu.Lock()
+	ack := c.pendingFlush
+	if ack == nil {
+		ack = make(chan struct{})
+		c.pendingFlush = ack
+		// flushSig has room: a nil pendingFlush means the loop already
+		// consumed any earlier signal.
+		c.flushSig <- struct{}{}
+	}
+	c.flushMu.Unlock()
 
 	select {
 	case <-ack:
@@ -130,9 +438,21 @@ func (c *Collector) FlushNow(ctx context.Context) error {
 	}
 }
 
-// Close stops the collector and drains remaining events.
+// Close stops the collector and drains remaining events, giving the final
+// write up to DrainTimeout.
 func (c *Collector) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.drainTimeout)
+	defer cancel()
+	c.CloseContext(ctx)
+}
+
+// CloseContext is Close with the final write bounded by ctx instead of
+// DrainTimeout. Events it cannot write before ctx is done go to the pending
+// file, if one is configured. It returns only once nothing is left writing
+// to the database, so the caller may close it.
+func (c *Collector) CloseContext(ctx context.Context) {
 	c.once.Do(func() {
+		c.closeCtx = ctx
 		close(c.done)
 		c.wg.Wait()
 
@@ -143,13 +463,19 @@ func (c *Collector) Close() {
 		if r := c.rejected.Load(); r > 0 {
 			log.Printf("analytics: %d events rejected by validation", r)
 		}
+		if c.spill != nil {
+			c.spill.close()
+			if n := c.spill.size(); n > 0 {
+				log.Printf("analytics: %d bytes of spilled events left for the next start", n)
+			}
+		}
 	})
 }
 
 func (c *Collector) flushLoop() {
 	defer c.wg.Done()
 
-	ticker := time.NewTicker(FlushInterval)
+	ticker := time.NewTicker(time.Duration(c.flushInterval.Load()))
 	defer ticker.Stop()
 
 	var batch []Event
@@ -158,66 +484,136 @@ func (c *Collector) flushLoop() {
 		select {
 		case e := <-c.events:
 			batch = append(batch, e)
-			if len(batch) >= FlushSize {
+			if int64(len(batch)) >= c.flushSize.Load() {
 				c.flush(batch)
 				batch = batch[:0]
 			}
 
+		case <-c.retune:
+			ticker.Reset(time.Duration(c.flushInterval.Load()))
+
 		case <-ticker.C:
 			if len(batch) > 0 {
 				c.flush(batch)
 				batch = batch[:0]
+			} else {
+				c.markFlushed()
+			}
+			c.flushStreamBytes(context.Background())
+			// Spilled events wait until the live queue has room to spare.
+			if c.spill != nil && len(c.events) < cap(c.events)/2 {
+				c.drainSpill()
 			}
 
-		case ack := <-c.flushReq:
+		case <-c.flushSig:
+			c.flushMu.Lock()
+			ack := c.pendingFlush
+			c.pendingFlush = nil
+			c.flushMu.Unlock()
+
+			// Include everything already queued so events recorded before
+			// FlushNow was called are written by the time it returns.
+		drain:
+			for {
+				select {
+				case e := <-c.events:
+					batch = append(batch, e)
+				default:
+					break drain
+				}
+			}
 			if len(batch) > 0 {
 				c.flush(batch)
 				batch = batch[:0]
+			} else {
+				c.markFlushed()
+			}
+			c.flushStreamBytes(context.Background())
+			if ack != nil {
+				close(ack)
 			}
-			close(ack)
 
 		case <-c.done:
-			// Drain remaining events with timeout
-			drainDone := make(chan struct{})
-			go func() {
-				defer close(drainDone)
-				for {
-					select {
-					case e := <-c.events:
-						batch = append(batch, e)
-					default:
-						if len(batch) > 0 {
-							c.flush(batch)
-						}
-						return
-					}
+			// Drain remaining events and write them with a timeout.
+		final:
+			for {
+				select {
+				case e := <-c.events:
+					batch = append(batch, e)
+				default:
+					break final
 				}
-			}()
-
-			select {
-			case <-drainDone:
-			case <-time.After(DrainTimeout):
-				log.Printf("analytics: drain timeout, %d events may be lost", len(batch))
 			}
+			if len(batch) > 0 {
+				c.shutdownFlush(c.closeCtx, batch)
+			}
+			c.flushStreamBytes(c.closeCtx)
 			return
 		}
 	}
 }
 
 func (c *Collector) flush(batch []Event) {
-	tx, err := c.db.Begin()
+	c.flushContext(context.Background(), batch)
+}
+
+// flushContext is flush with the write abandoned once ctx is done.
+func (c *Collector) flushContext(ctx context.Context, batch []Event) {
+	failed, err := c.writeWithRetry(ctx, batch)
 	if err != nil {
-		log.Printf("analytics: begin tx: %v", err)
+		log.Printf("analytics: flush: %v", err)
+		c.deadLetterEvents(batch, err)
 		return
 	}
+	c.markFlushed()
+	for _, f := range failed {
+		c.deadLetterEvents([]Event{f.event}, f.err)
+	}
+}
+
+// writeWithRetry runs writeBatch, retrying transient lock contention until
+// ctx is done.
+func (c *Collector) writeWithRetry(ctx context.Context, batch []Event) (failed []failedEvent, err error) {
+	for attempt := 1; ; attempt++ {
+		failed, err = c.writeBatch(ctx, batch)
+		if err == nil || !isRetryableDBError(err) || attempt >= FlushMaxAttempts {
+			return failed, err
+		}
+		log.Printf("analytics: flush attempt %d failed, retrying: %v", attempt, err)
+		select {
+		case <-time.After(time.Duration(attempt) * FlushRetryBackoff):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("retry abandoned: %w", ctx.Err())
+		}
+	}
+}
+
+type failedEvent struct {
+	event Event
+	err   error
+}
+
+// writeBatch inserts batch in one transaction. Transaction-level failures are
+// returned as err (and the whole batch is rolled back); individual rows that
+// fail to insert are returned in failed and skipped. Lock contention on a row
+// fails the whole batch so writeWithRetry can try again: a deferred
+// transaction only takes the write lock at its first insert.
+func (c *Collector) writeBatch(ctx context.Context, batch []Event) (failed []failedEvent, err error) {
+	if c.aggregateOnly {
+		return c.writeRollups(ctx, batch)
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
 
 	stmt, err := tx.Prepare(
-		"INSERT INTO events (session_id, event_type, track_stem, position_seconds, metadata, album_id) VALUES (?, ?, ?, ?, ?, ?)",
+		"INSERT INTO events (session_id, event_type, track_stem, position_seconds, metadata, album_id, created_at) VALUES (?, ?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP))",
 	)
 	if err != nil {
-		log.Printf("analytics: prepare: %v", err)
 		tx.Rollback()
-		return
+		return nil, fmt.Errorf("prepare: %w", err)
 	}
 	defer stmt.Close()
 
@@ -230,22 +626,123 @@ func (c *Collector) flush(batch []Event) {
 		if e.AlbumID > 0 {
 			albumID = e.AlbumID
 		}
-		_, err := stmt.Exec(e.SessionID, e.EventType, e.TrackStem, e.PositionSeconds, metadata, albumID)
+		var createdAt interface{}
+		if !e.RecordedAt.IsZero() {
+			createdAt = formatSQLiteTime(e.RecordedAt)
+		}
+		_, err := stmt.ExecContext(ctx, e.SessionID, e.EventType, e.TrackStem, e.PositionSeconds, metadata, albumID, createdAt)
+		if err != nil && isRetryableDBError(err) {
+			tx.Rollback()
+			return nil, fmt.Errorf("insert event: %w", err)
+		}
 		if err != nil {
 			log.Printf("analytics: insert event: %v", err)
+			failed = append(failed, failedEvent{event: e, err: err})
+		}
+	}
+
+	if err := c.commit(tx); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return failed, nil
+}
+
+// writeRollups adds batch to the daily rollup counters for the day each
+// event was recorded, without keeping any per-session rows. Failures are reported like writeBatch.
+func (c *Collector) writeRollups(ctx context.Context, batch []Event) (failed []failedEvent, err error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO analytics_rollups_daily (day, album_id, track_stem, event_type, total_count)
+		VALUES (?, ?, ?, ?, 1)
+		ON CONFLICT(day, album_id, track_stem, event_type)
+		DO UPDATE SET total_count = total_count + 1
+	`)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("prepare: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range batch {
+		_, err := stmt.ExecContext(ctx, e.recordedDay(), e.AlbumID, e.TrackStem, e.EventType)
+		if err != nil && isRetryableDBError(err) {
+			tx.Rollback()
+			return nil, fmt.Errorf("upsert rollup: %w", err)
+		}
+		if err != nil {
+			log.Printf("analytics: upsert rollup: %v", err)
+			failed = append(failed, failedEvent{event: e, err: err})
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		log.Printf("analytics: commit: %v", err)
+	if err := c.commit(tx); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return failed, nil
+}
+
+// isRetryableDBError reports whether err looks like transient SQLite lock
+// contention rather than a permanent failure.
+func isRetryableDBError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "SQLITE_BUSY") ||
+		strings.Contains(msg, "SQLITE_LOCKED") ||
+		strings.Contains(msg, "database is locked") ||
+		strings.Contains(msg, "database table is locked")
+}
+
+// deadLetterEvents keeps events that failed to write. Aggregate-only
+// collectors drop them instead, since the sink would keep per-session rows
+// on disk that the mode exists to avoid.
+func (c *Collector) deadLetterEvents(events []Event, cause error) {
+	if c.deadLetter == nil || len(events) == 0 {
+		return
 	}
+	if c.aggregateOnly {
+		log.Printf("analytics: dropping %d unwritable event(s) in aggregate-only mode: %v", len(events), cause)
+		return
+	}
+	c.deadLetter.write(events, cause)
 }
 
 // RecordBatch parses and records a batch of events from JSON.
 func (c *Collector) RecordBatch(sessionID string, data []byte, albumID int64) error {
+	_, err := c.RecordBatchWithID(sessionID, "", data, albumID)
+	return err
+}
+
+// RecordBatchWithID is RecordBatch with an optional client-supplied batch
+// ID. A batch whose ID this session already sent within the TTL is skipped
+// and counted as a duplicate, reporting true. An empty ID disables the check.
+func (c *Collector) RecordBatchWithID(sessionID, batchID string, data []byte, albumID int64) (bool, error) {
 	if !validSessionID(sessionID) {
-		return errors.New("invalid session")
+		return false, errors.New("invalid session")
+	}
+	if len(batchID) > MaxBatchIDLength {
+		return false, errors.New("batch id too long")
 	}
+	if batchID != "" {
+		key := batchKey(sessionID, batchID)
+		if !c.batches.claim(key, time.Now()) {
+			c.dupes.Add(1)
+			return true, nil
+		}
+		if err := c.recordBatch(sessionID, data, albumID); err != nil {
+			c.batches.release(key)
+			return false, err
+		}
+		return false, nil
+	}
+	return false, c.recordBatch(sessionID, data, albumID)
+}
+
+func (c *Collector) recordBatch(sessionID string, data []byte, albumID int64) error {
 
 	var events []struct {
 		EventType       string          `json:"event_type"`
@@ -257,13 +754,15 @@ func (c *Collector) RecordBatch(sessionID string, data []byte, albumID int64) er
 	if err := json.Unmarshal(data, &events); err != nil {
 		return err
 	}
-	if len(events) > MaxBatchSize {
+	if len(events) > c.maxBatch {
 		return errors.New("too many events")
 	}
 
 	var rejected int64
 	for _, e := range events {
-		normalized, ok := normalizeBatchEvent(e)
+		normalized, ok := normalizeBatchEvent(e, func(stem string) float64 {
+			return c.completeFloor(albumID, stem)
+		})
 		if !ok {
 			rejected++
 			continue
@@ -306,7 +805,7 @@ func normalizeBatchEvent(raw struct {
 	TrackStem       string          `json:"track_stem,omitempty"`
 	PositionSeconds float64         `json:"position_seconds,omitempty"`
 	Metadata        json.RawMessage `json:"metadata,omitempty"`
-}) (Event, bool) {
+}, completeFloor func(stem string) float64) (Event, bool) {
 	eventType := strings.TrimSpace(raw.EventType)
 	if !validEventTypes[eventType] {
 		return Event{}, false
@@ -330,7 +829,11 @@ func normalizeBatchEvent(raw struct {
 		return Event{}, false
 	}
 
-	if !validEventByType(eventType, trackStem, raw.PositionSeconds, metaObj) {
+	var minComplete float64
+	if eventType == "complete" && completeFloor != nil {
+		minComplete = completeFloor(trackStem)
+	}
+	if !validEventByType(eventType, trackStem, raw.PositionSeconds, metaObj, minComplete) {
 		return Event{}, false
 	}
 
@@ -355,8 +858,15 @@ func requiresTrackStem(eventType string) bool {
 	}
 }
 
-func validEventByType(eventType, trackStem string, position float64, metadata map[string]interface{}) bool {
+// validEventByType applies per-type rules. minComplete is the lowest
+// position a complete event may report; zero when the track length is
+// unknown or the check is disabled.
+func validEventByType(eventType, trackStem string, position float64, metadata map[string]interface{}, minComplete float64) bool {
 	switch eventType {
+	case "complete":
+		if minComplete > 0 && position < minComplete {
+			return false
+		}
 	case "seek":
 		if !hasNumericField(metadata, "from_position") || !hasNumericField(metadata, "to_position") {
 			return false
@@ -1,7 +1,16 @@
 package analytics
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -234,3 +243,885 @@ func TestRecordBatchRejectsInvalidSessionID(t *testing.T) {
 		t.Fatal("expected invalid session error")
 	}
 }
+
+func TestFlushNowConcurrentCallersCoalesce(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	c := NewCollector(db)
+	defer c.Close()
+
+	const workers = 16
+	const perWorker = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				c.Record(Event{SessionID: "flush-sess", EventType: "play", TrackStem: "01-gathering"})
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			errs <- c.FlushNow(ctx)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("FlushNow: %v", err)
+		}
+	}
+
+	// Every event recorded before its caller's FlushNow returned must be written exactly once.
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM events WHERE session_id = 'flush-sess'").Scan(&count)
+	if count != workers*perWorker {
+		t.Fatalf("expected %d events after concurrent flushes, got %d", workers*perWorker, count)
+	}
+	if dropped := c.DroppedCount(); dropped != 0 {
+		t.Fatalf("unexpected dropped events: %d", dropped)
+	}
+}
+
+func TestFlushWritesFailedInsertsToDeadLetter(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	// Simulate a per-row insert failure for one session.
+	if _, err := db.Exec(`CREATE TRIGGER fail_dead_letter BEFORE INSERT ON events
+		WHEN NEW.session_id = 'poison' BEGIN SELECT RAISE(ABORT, 'simulated insert failure'); END`); err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+
+	deadLetterPath := filepath.Join(dir, "deadletter.jsonl")
+	c := NewCollectorWithOptions(db, CollectorOptions{DeadLetterPath: deadLetterPath})
+	c.Record(Event{SessionID: "ok", EventType: "play", TrackStem: "01-gathering"})
+	c.Record(Event{SessionID: "poison", EventType: "play", TrackStem: "02-hollow"})
+	c.Close()
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM events").Scan(&count)
+	if count != 1 {
+		t.Fatalf("expected 1 inserted event, got %d", count)
+	}
+
+	data, err := os.ReadFile(deadLetterPath)
+	if err != nil {
+		t.Fatalf("read dead-letter file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 dead-letter record, got %d: %q", len(lines), data)
+	}
+	var rec DeadLetterRecord
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("decode dead-letter record: %v", err)
+	}
+	if rec.Event.SessionID != "poison" || rec.Event.TrackStem != "02-hollow" || !strings.Contains(rec.Error, "simulated insert failure") {
+		t.Fatalf("unexpected dead-letter record: %+v", rec)
+	}
+	if written, lost := c.DeadLetterCount(); written != 1 || lost != 0 {
+		t.Fatalf("DeadLetterCount = %d, %d; want 1, 0", written, lost)
+	}
+}
+
+func TestDeadLetterRespectsSizeBound(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deadletter.jsonl")
+	sink := newDeadLetterSink(path, 200)
+
+	events := make([]Event, 5)
+	for i := range events {
+		events[i] = Event{SessionID: "s", EventType: "play", TrackStem: "01-gathering"}
+	}
+	sink.write(events, errors.New("boom"))
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Size() > 200 {
+		t.Fatalf("dead-letter file size %d exceeds bound", info.Size())
+	}
+	if sink.written.Load()+sink.lost.Load() != 5 || sink.lost.Load() == 0 {
+		t.Fatalf("written=%d lost=%d", sink.written.Load(), sink.lost.Load())
+	}
+}
+
+func TestFlushRetriesBusyCommit(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	c := NewCollector(db)
+	var attempts atomic.Int32
+	c.commit = func(tx *sql.Tx) error {
+		if attempts.Add(1) == 1 {
+			return errors.New("database is locked (5) (SQLITE_BUSY)")
+		}
+		return tx.Commit()
+	}
+
+	c.Record(Event{SessionID: "retry-sess", EventType: "play", TrackStem: "01-gathering"})
+	c.Close()
+
+	if got := attempts.Load(); got != 2 {
+		t.Fatalf("commit attempts = %d, want 2", got)
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM events WHERE session_id = 'retry-sess'").Scan(&count)
+	if count != 1 {
+		t.Fatalf("expected 1 event after retry, got %d", count)
+	}
+}
+
+func TestFlushRetriesBusyRowInsert(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	// The collector gets its own handle that does not wait on locks, so the
+	// first insert of its deferred transaction fails with SQLITE_BUSY while
+	// another writer holds the database.
+	contended, err := sql.Open("sqlite", filepath.Join(dir, "acetate.db")+"?_pragma=busy_timeout(0)")
+	if err != nil {
+		t.Fatalf("open contended handle: %v", err)
+	}
+	defer contended.Close()
+
+	lock, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin lock tx: %v", err)
+	}
+	if _, err := lock.Exec("DELETE FROM events WHERE session_id = 'nobody'"); err != nil {
+		t.Fatalf("take write lock: %v", err)
+	}
+
+	c := NewCollector(contended)
+	c.Record(Event{SessionID: "busy-row-sess", EventType: "play", TrackStem: "01-gathering"})
+
+	// Release the lock during the first retry backoff.
+	go func() {
+		time.Sleep(FlushRetryBackoff / 2)
+		lock.Commit()
+	}()
+	c.Close()
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM events WHERE session_id = 'busy-row-sess'").Scan(&count)
+	if count != 1 {
+		t.Fatalf("expected the event to be written after retry, got %d", count)
+	}
+}
+
+func TestFlushDoesNotRetryFatalCommit(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	c := NewCollector(db)
+	var attempts atomic.Int32
+	c.commit = func(tx *sql.Tx) error {
+		attempts.Add(1)
+		return errors.New("disk I/O error")
+	}
+
+	c.Record(Event{SessionID: "fatal-sess", EventType: "play", TrackStem: "01-gathering"})
+	c.Close()
+
+	if got := attempts.Load(); got != 1 {
+		t.Fatalf("commit attempts = %d, want 1", got)
+	}
+}
+
+func readPendingEvents(t *testing.T, path string) []Event {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read pending file: %v", err)
+	}
+	var events []Event
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("decode pending line %q: %v", line, err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestCloseSavesUnwrittenEventsToPendingFile(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	pending := filepath.Join(dir, "pending.jsonl")
+	deadLetter := filepath.Join(dir, "deadletter.jsonl")
+	c := NewCollectorWithOptions(db, CollectorOptions{PendingPath: pending, DeadLetterPath: deadLetter})
+	c.commit = func(tx *sql.Tx) error {
+		tx.Rollback()
+		return errors.New("disk I/O error")
+	}
+
+	c.Record(Event{SessionID: "close-sess", EventType: "play", TrackStem: "01-gathering", AlbumID: 3})
+	c.Record(Event{SessionID: "close-sess", EventType: "complete", TrackStem: "01-gathering", AlbumID: 3})
+	c.Close()
+
+	events := readPendingEvents(t, pending)
+	if len(events) != 2 || events[0].EventType != "play" || events[1].AlbumID != 3 {
+		t.Fatalf("pending events = %+v", events)
+	}
+	if _, err := os.Stat(deadLetter); !os.IsNotExist(err) {
+		t.Fatalf("events saved for replay should not be dead-lettered: %v", err)
+	}
+}
+
+func TestAggregateOnlyCollectorSkipsDeadLetter(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	deadLetter := filepath.Join(dir, "deadletter.jsonl")
+	c := NewCollectorWithOptions(db, CollectorOptions{AggregateOnly: true, DeadLetterPath: deadLetter})
+	c.commit = func(tx *sql.Tx) error {
+		tx.Rollback()
+		return errors.New("disk I/O error")
+	}
+	c.Record(Event{SessionID: testSessionID, EventType: "play", TrackStem: "01-a", AlbumID: 7})
+	c.Close()
+
+	if _, err := os.Stat(deadLetter); !os.IsNotExist(err) {
+		t.Fatalf("aggregate-only events should not be dead-lettered: %v", err)
+	}
+}
+
+func TestAggregateOnlyRollupUsesRecordedDay(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	// An event recorded just before midnight but flushed after it still
+	// counts toward the day it happened.
+	c := NewCollectorWithOptions(db, CollectorOptions{AggregateOnly: true})
+	recorded := time.Date(2026, 2, 9, 23, 59, 59, 0, time.UTC)
+	c.Record(Event{SessionID: testSessionID, EventType: "play", TrackStem: "01-a", AlbumID: 7, RecordedAt: recorded})
+	c.Record(Event{SessionID: testSessionID, EventType: "play", TrackStem: "01-a", AlbumID: 7})
+	c.Close()
+
+	var late, today int
+	if err := db.QueryRow("SELECT COALESCE(SUM(total_count), 0) FROM analytics_rollups_daily WHERE day = '2026-02-09'").Scan(&late); err != nil {
+		t.Fatalf("query rollup: %v", err)
+	}
+	if err := db.QueryRow("SELECT COALESCE(SUM(total_count), 0) FROM analytics_rollups_daily WHERE day = ?", time.Now().UTC().Format(sqliteDayLayout)).Scan(&today); err != nil {
+		t.Fatalf("query rollup: %v", err)
+	}
+	if late != 1 || today != 1 {
+		t.Fatalf("rollups: 2026-02-09 = %d, today = %d; want 1 each", late, today)
+	}
+}
+
+func TestCloseDrainTimeoutSavesEventsWithoutCommitting(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	// With one connection held by another transaction, the final flush
+	// cannot even begin until the drain has timed out.
+	db.SetMaxOpenConns(1)
+	blocker, err := db.Begin()
+	if err != nil {
+		t.Fatalf("begin blocker: %v", err)
+	}
+
+	pending := filepath.Join(dir, "pending.jsonl")
+	c := NewCollectorWithOptions(db, CollectorOptions{PendingPath: pending})
+	c.drainTimeout = 50 * time.Millisecond
+	c.Record(Event{SessionID: "slow-sess", EventType: "play", TrackStem: "01-gathering"})
+	c.Close()
+
+	if events := readPendingEvents(t, pending); len(events) != 1 || events[0].SessionID != "slow-sess" {
+		t.Fatalf("pending events = %+v", events)
+	}
+
+	// Once the database frees up, the abandoned flush must not commit a
+	// second copy of the saved events.
+	blocker.Rollback()
+	for i := 0; i < 20; i++ {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM events WHERE session_id = 'slow-sess'").Scan(&count); err != nil {
+			t.Fatalf("count events: %v", err)
+		}
+		if count != 0 {
+			t.Fatalf("abandoned flush committed %d events", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCloseContextPastDeadlineSavesEventsToPending(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	pending := filepath.Join(dir, "pending.jsonl")
+	c := NewCollectorWithOptions(db, CollectorOptions{PendingPath: pending})
+	c.Record(Event{SessionID: "late-sess", EventType: "play", TrackStem: "01-gathering"})
+
+	// A shutdown budget already spent elsewhere still saves the events.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.CloseContext(ctx)
+
+	if events := readPendingEvents(t, pending); len(events) != 1 || events[0].SessionID != "late-sess" {
+		t.Fatalf("pending events = %+v", events)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM events").Scan(&count); err != nil {
+		t.Fatalf("count events: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("events written past the deadline = %d, want 0", count)
+	}
+}
+
+func TestCollectorReplaysPendingFileOnStart(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	pending := filepath.Join(dir, "pending.jsonl")
+	if err := writePending(pending, []Event{
+		{SessionID: "replay-sess", EventType: "play", TrackStem: "01-gathering", AlbumID: 2},
+		{SessionID: "replay-sess", EventType: "seek", TrackStem: "01-gathering", Metadata: `{"from_position":1,"to_position":5}`},
+	}); err != nil {
+		t.Fatalf("seed pending file: %v", err)
+	}
+
+	c := NewCollectorWithOptions(db, CollectorOptions{PendingPath: pending})
+	defer c.Close()
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM events WHERE session_id = 'replay-sess'").Scan(&count)
+	if count != 2 {
+		t.Fatalf("replayed events = %d, want 2", count)
+	}
+	var albumID int64
+	db.QueryRow("SELECT album_id FROM events WHERE session_id = 'replay-sess' AND event_type = 'play'").Scan(&albumID)
+	if albumID != 2 {
+		t.Fatalf("replayed album_id = %d, want 2", albumID)
+	}
+	if _, err := os.Stat(pending); !os.IsNotExist(err) {
+		t.Fatalf("pending file should be removed after replay: %v", err)
+	}
+}
+
+func TestCollectorReplayKeepsRecordedTime(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	// Saved at one shutdown, replayed at a later start: the row keeps the
+	// time the event was originally recorded.
+	pending := filepath.Join(dir, "pending.jsonl")
+	recorded := time.Date(2026, 2, 9, 23, 59, 30, 0, time.UTC)
+	if err := writePending(pending, []Event{
+		{SessionID: "replay-sess", EventType: "play", TrackStem: "01-gathering", RecordedAt: recorded},
+	}); err != nil {
+		t.Fatalf("seed pending file: %v", err)
+	}
+	if events := readPendingEvents(t, pending); len(events) != 1 || !events[0].RecordedAt.Equal(recorded) {
+		t.Fatalf("pending events = %+v, want record time %s", events, recorded)
+	}
+
+	c := NewCollectorWithOptions(db, CollectorOptions{PendingPath: pending})
+	defer c.Close()
+
+	var createdAt string
+	if err := db.QueryRow("SELECT created_at || '' FROM events WHERE session_id = 'replay-sess'").Scan(&createdAt); err != nil {
+		t.Fatalf("query event: %v", err)
+	}
+	if createdAt != "2026-02-09 23:59:30" {
+		t.Fatalf("replayed created_at = %q, want 2026-02-09 23:59:30", createdAt)
+	}
+}
+
+func TestCollectorReplaySkipsCorruptPendingLines(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	pending := filepath.Join(dir, "pending.jsonl")
+	lines := strings.Join([]string{
+		`{"session_id":"ok-sess","event_type":"play","track_stem":"01-gathering"}`,
+		`not json`,
+		`{"session_id":"","event_type":"play"}`,
+		`{"session_id":"bad-sess","event_type":"play","track_stem":"../etc"}`,
+		``,
+		`{"session_id":"ok-sess","event_type":"complete","track_stem":"01-gathering"}`,
+		`{"session_id":"ok-sess","event_ty`,
+	}, "\n")
+	if err := os.WriteFile(pending, []byte(lines), 0600); err != nil {
+		t.Fatalf("seed pending file: %v", err)
+	}
+
+	c := NewCollectorWithOptions(db, CollectorOptions{PendingPath: pending})
+	res, err := c.replayPending()
+	c.Close()
+	if err != nil || res != (replayResult{}) {
+		t.Fatalf("second replay = %+v, %v; want nothing left", res, err)
+	}
+
+	var count, bad int
+	db.QueryRow("SELECT COUNT(*) FROM events WHERE session_id = 'ok-sess'").Scan(&count)
+	db.QueryRow("SELECT COUNT(*) FROM events WHERE session_id = 'bad-sess'").Scan(&bad)
+	if count != 2 || bad != 0 {
+		t.Fatalf("replayed ok=%d bad=%d, want 2 and 0", count, bad)
+	}
+	if _, err := os.Stat(pending + ".corrupt"); err != nil {
+		t.Fatalf("corrupt pending file should be kept: %v", err)
+	}
+}
+
+func TestCollectorReplayKeepsPendingFileWhenDatabaseFails(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	pending := filepath.Join(dir, "pending.jsonl")
+	events := []Event{{SessionID: "keep-sess", EventType: "play", TrackStem: "01-gathering"}}
+	if err := writePending(pending, events); err != nil {
+		t.Fatalf("seed pending file: %v", err)
+	}
+
+	c := &Collector{db: db, pendingPath: pending, maxBatch: MaxBatchSize}
+	c.commit = func(tx *sql.Tx) error {
+		tx.Rollback()
+		return errors.New("disk I/O error")
+	}
+	if _, err := c.replayPending(); err == nil {
+		t.Fatal("replay should report the database failure")
+	}
+	if got := readPendingEvents(t, pending); len(got) != 1 || got[0].SessionID != "keep-sess" {
+		t.Fatalf("pending file after failed replay = %+v", got)
+	}
+}
+
+func TestSpillQueueCatchesOverflowAndDrains(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	spill := filepath.Join(dir, "spill.jsonl")
+	c := NewCollectorWithOptions(db, CollectorOptions{BufferSize: 2, SpillPath: spill})
+	defer c.Close()
+
+	// Stall the flush loop inside its first commit so the queue fills up.
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	c.commit = func(tx *sql.Tx) error {
+		once.Do(func() {
+			close(entered)
+			<-release
+		})
+		return tx.Commit()
+	}
+	if err := c.SetFlushSize(1); err != nil {
+		t.Fatalf("set flush size: %v", err)
+	}
+	c.Record(Event{SessionID: "spill-sess", EventType: "heartbeat"})
+	<-entered
+
+	for i := 0; i < 10; i++ {
+		c.Record(Event{SessionID: "spill-sess", EventType: "heartbeat", PositionSeconds: float64(i + 1)})
+	}
+	stats := c.Stats()
+	if stats.Dropped != 0 || stats.Spilled != 8 || stats.SpillBytes == 0 {
+		t.Fatalf("stats after overflow = %+v, want 8 spilled and none dropped", stats)
+	}
+
+	close(release)
+	if err := c.SetFlushInterval(MinFlushInterval); err != nil {
+		t.Fatalf("set flush interval: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var count int
+		db.QueryRow("SELECT COUNT(*) FROM events WHERE session_id = 'spill-sess'").Scan(&count)
+		if count == 11 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("events written = %d, want 11", count)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := c.Stats().SpillBytes; n != 0 {
+		t.Fatalf("spill bytes after drain = %d, want 0", n)
+	}
+	for _, path := range []string{spill, spill + ".draining"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s should be gone after draining: %v", path, err)
+		}
+	}
+}
+
+func TestSpillQueueRespectsSizeCap(t *testing.T) {
+	q := newSpillQueue(filepath.Join(t.TempDir(), "spill.jsonl"), 200)
+	e := Event{SessionID: "cap-sess", EventType: "heartbeat"}
+	added := 0
+	for i := 0; i < 10; i++ {
+		if q.add(e) {
+			added++
+		}
+	}
+	if added == 0 || added == 10 || q.size() > 200 {
+		t.Fatalf("added %d events, size %d; want the cap to stop some", added, q.size())
+	}
+}
+
+func TestSpillQueueKeepsOneHandleAndFlushesOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spill.jsonl")
+	q := newSpillQueue(path, 0)
+	e := Event{SessionID: "spill-sess", EventType: "heartbeat"}
+
+	if !q.add(e) {
+		t.Fatal("first add failed")
+	}
+	f := q.file
+	for i := 0; i < 4; i++ {
+		if !q.add(e) {
+			t.Fatalf("add %d failed", i)
+		}
+	}
+	if q.file != f {
+		t.Fatal("spill file reopened between adds")
+	}
+
+	q.close()
+	if events := readPendingEvents(t, path); len(events) != 5 {
+		t.Fatalf("spill file holds %d events after close, want 5", len(events))
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != q.size() {
+		t.Fatalf("spill file size = %v (%v), want %d", info, err, q.size())
+	}
+	if q.add(e) {
+		t.Fatal("add after close should fail")
+	}
+}
+
+func TestRecordBatchWithIDSkipsDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	c := NewCollector(db)
+	data := []byte(`[{"event_type": "play", "track_stem": "01-gathering"}]`)
+
+	if dup, err := c.RecordBatchWithID(testSessionID, "batch-1", data, 0); err != nil || dup {
+		t.Fatalf("first batch: dup=%v err=%v", dup, err)
+	}
+	if dup, err := c.RecordBatchWithID(testSessionID, "batch-1", data, 0); err != nil || !dup {
+		t.Fatalf("resent batch: dup=%v err=%v, want duplicate", dup, err)
+	}
+	// The ID is scoped to the session; another session may reuse it.
+	other := strings.Repeat("b", len(testSessionID))
+	if dup, err := c.RecordBatchWithID(other, "batch-1", data, 0); err != nil || dup {
+		t.Fatalf("other session: dup=%v err=%v", dup, err)
+	}
+	// A batch that fails to parse does not burn its ID.
+	if _, err := c.RecordBatchWithID(testSessionID, "batch-2", []byte(`{`), 0); err == nil {
+		t.Fatal("expected parse error")
+	}
+	if dup, err := c.RecordBatchWithID(testSessionID, "batch-2", data, 0); err != nil || dup {
+		t.Fatalf("retry after parse error: dup=%v err=%v", dup, err)
+	}
+
+	if got := c.Stats().Duplicates; got != 1 {
+		t.Fatalf("duplicates = %d, want 1", got)
+	}
+	c.Close()
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM events").Scan(&count)
+	if count != 3 {
+		t.Fatalf("stored %d events, want 3", count)
+	}
+}
+
+func TestBatchDedupExpires(t *testing.T) {
+	d := newBatchDedup(time.Minute)
+	key := batchKey("session", "batch")
+	now := time.Now()
+	if !d.claim(key, now) {
+		t.Fatal("first claim refused")
+	}
+	if d.claim(key, now.Add(30*time.Second)) {
+		t.Fatal("claim within TTL accepted")
+	}
+	if !d.claim(key, now.Add(2*time.Minute)) {
+		t.Fatal("claim after TTL refused")
+	}
+
+	// Expired IDs are dropped as later claims arrive, not only when full.
+	for i := 0; i < 10; i++ {
+		d.claim(batchKey("session", fmt.Sprint(i)), now.Add(2*time.Minute))
+	}
+	d.release(batchKey("session", "3"))
+	d.claim(batchKey("session", "later"), now.Add(4*time.Minute))
+	if len(d.seen) != 1 || len(d.order)-d.head != 1 {
+		t.Fatalf("tracked %d IDs (%d queued), want only the latest", len(d.seen), len(d.order)-d.head)
+	}
+	if !d.claim(key, now.Add(4*time.Minute)) {
+		t.Fatal("expired ID still refused")
+	}
+}
+
+func TestRecordBatchRejectsPrematureComplete(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	c := NewCollector(db)
+	c.SetCompletionCheck(0.9, func(albumID int64, stem string) (float64, bool) {
+		if albumID == 1 && stem == "01-gathering" {
+			return 200, true
+		}
+		return 0, false
+	})
+
+	data := []byte(`[
+		{"event_type": "complete", "track_stem": "01-gathering", "position_seconds": 190},
+		{"event_type": "complete", "track_stem": "01-gathering", "position_seconds": 40},
+		{"event_type": "complete", "track_stem": "02-hollow"}
+	]`)
+	if err := c.RecordBatch(testSessionID, data, 1); err != nil {
+		t.Fatalf("RecordBatch: %v", err)
+	}
+	c.Close()
+
+	if got := c.RejectedCount(); got != 1 {
+		t.Fatalf("rejected = %d, want 1 (the 20%% completion)", got)
+	}
+	rows, err := db.Query("SELECT track_stem, position_seconds FROM events WHERE event_type = 'complete' ORDER BY track_stem")
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var stem string
+		var pos float64
+		rows.Scan(&stem, &pos)
+		got = append(got, fmt.Sprintf("%s@%g", stem, pos))
+	}
+	// The 95% completion is kept, and a track of unknown length is not checked.
+	if want := "01-gathering@190,02-hollow@0"; strings.Join(got, ",") != want {
+		t.Fatalf("stored completes = %v, want %s", got, want)
+	}
+}
+
+func TestValidateIngestLimits(t *testing.T) {
+	if err := ValidateIngestLimits(DefaultMaxBodyBytes, MaxBatchSize); err != nil {
+		t.Fatalf("defaults should validate: %v", err)
+	}
+	bad := []struct {
+		body  int64
+		batch int
+	}{
+		{512, 10}, // body too small
+		{MaxMaxBodyBytes + 1, 10},
+		{DefaultMaxBodyBytes, 0},
+		{DefaultMaxBodyBytes, MaxMaxBatchSize + 1},
+		{2048, 1000}, // batch cannot fit in body
+	}
+	for _, tt := range bad {
+		if err := ValidateIngestLimits(tt.body, tt.batch); err == nil {
+			t.Errorf("ValidateIngestLimits(%d, %d) = nil, want error", tt.body, tt.batch)
+		}
+	}
+}
+
+func TestRecordBatchHonorsConfiguredMaxBatch(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	c := NewCollectorWithOptions(db, CollectorOptions{MaxBatchSize: 2})
+	defer c.Close()
+
+	data := []byte(`[{"event_type":"play","track_stem":"a"},{"event_type":"play","track_stem":"b"},{"event_type":"play","track_stem":"c"}]`)
+	if err := c.RecordBatch(testSessionID, data, 0); err == nil {
+		t.Fatal("expected batch over configured max to be rejected")
+	}
+}
+
+func TestSetFlushSizeTriggersEarlierFlush(t *testing.T) {
+	c := testCollector(t)
+	if err := c.SetFlushSize(2); err != nil {
+		t.Fatalf("SetFlushSize: %v", err)
+	}
+	if err := c.SetFlushSize(0); err == nil {
+		t.Fatal("expected SetFlushSize(0) to fail")
+	}
+
+	c.Record(Event{SessionID: "tune-sess", EventType: "play", TrackStem: "a"})
+	c.Record(Event{SessionID: "tune-sess", EventType: "play", TrackStem: "b"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var count int
+		c.db.QueryRow("SELECT COUNT(*) FROM events WHERE session_id = 'tune-sess'").Scan(&count)
+		if count == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected size-triggered flush, got %d events", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMarshalEventsTSVFlattensMetadata(t *testing.T) {
+	events := []ExportEvent{
+		{ID: 1, SessionID: "s1", EventType: "seek", TrackStem: "01-a", PositionSeconds: 42, Metadata: `{"from_position":12.5,"to_position":42,"source":"scrub\tbar","extra":{"x":1}}`, CreatedAt: "2026-01-01 00:00:00"},
+		{ID: 2, SessionID: "s1", EventType: "play", TrackStem: "01-a", Metadata: `{}`, CreatedAt: "2026-01-01 00:00:01"},
+	}
+
+	payload, err := MarshalEventsTSV(events)
+	if err != nil {
+		t.Fatalf("MarshalEventsTSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(payload), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("lines = %d, want 3: %q", len(lines), payload)
+	}
+
+	header := strings.Split(lines[0], "\t")
+	want := []string{"id", "session_id", "event_type", "track_stem", "position_seconds", "from_position", "to_position", "source", "created_at"}
+	if strings.Join(header, ",") != strings.Join(want, ",") {
+		t.Fatalf("header = %v, want %v", header, want)
+	}
+
+	seek := strings.Split(lines[1], "\t")
+	if len(seek) != len(want) {
+		t.Fatalf("seek columns = %d, want %d: %q", len(seek), len(want), lines[1])
+	}
+	if seek[5] != "12.500" || seek[6] != "42.000" || seek[7] != "scrub bar" {
+		t.Fatalf("seek metadata columns = %v", seek[5:8])
+	}
+	if strings.Contains(lines[1], "extra") {
+		t.Fatalf("unknown metadata key leaked into TSV: %q", lines[1])
+	}
+
+	play := strings.Split(lines[2], "\t")
+	if play[5] != "" || play[6] != "" || play[7] != "" {
+		t.Fatalf("play metadata columns = %v, want empty", play[5:8])
+	}
+}
+
+func TestFlushHealthReportsStalledFlush(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	c := NewCollector(db)
+	defer c.Close()
+	if err := c.SetFlushInterval(MinFlushInterval); err != nil {
+		t.Fatalf("SetFlushInterval: %v", err)
+	}
+	if h := c.FlushHealth(0); h.Stalled || h.ThresholdSeconds != DefaultFlushStallThreshold.Seconds() {
+		t.Fatalf("fresh collector health = %+v", h)
+	}
+
+	// Wedge the database: the next commit blocks until released.
+	release := make(chan struct{})
+	c.commit = func(tx *sql.Tx) error {
+		<-release
+		return tx.Commit()
+	}
+	c.Record(Event{SessionID: "stall-sess", EventType: "play", TrackStem: "01-gathering"})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.FlushNow(ctx); err == nil {
+		t.Fatal("FlushNow should time out while the commit is blocked")
+	}
+
+	// The threshold is raised to twice the 100ms flush interval.
+	time.Sleep(300 * time.Millisecond)
+	h := c.FlushHealth(time.Millisecond)
+	if !h.Stalled || h.ThresholdSeconds != 0.2 || h.SecondsSinceFlush < 0.2 {
+		t.Fatalf("stalled health = %+v, want stalled past 0.2s", h)
+	}
+
+	close(release)
+	if err := c.FlushNow(context.Background()); err != nil {
+		t.Fatalf("FlushNow after release: %v", err)
+	}
+	if h := c.FlushHealth(time.Millisecond); h.Stalled {
+		t.Fatalf("health after recovery = %+v, want not stalled", h)
+	}
+}
@@ -0,0 +1,144 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StreamBytes totals the audio bytes served for one track.
+type StreamBytes struct {
+	Stem       string `json:"stem"`
+	TotalBytes int64  `json:"total_bytes"`
+	Requests   int    `json:"requests"`
+}
+
+// streamBytesKey identifies one stream_bytes_daily row.
+type streamBytesKey struct {
+	day     string
+	albumID int64
+	stem    string
+}
+
+type streamBytesCount struct {
+	bytes    int64
+	requests int64
+}
+
+// streamBytesBuffer accumulates stream byte counts between flushes, so the
+// stream handler never waits on the database.
+type streamBytesBuffer struct {
+	mu     sync.Mutex
+	counts map[streamBytesKey]streamBytesCount
+}
+
+func (b *streamBytesBuffer) add(key streamBytesKey, count streamBytesCount) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.counts == nil {
+		b.counts = make(map[streamBytesKey]streamBytesCount)
+	}
+	total := b.counts[key]
+	total.bytes += count.bytes
+	total.requests += count.requests
+	b.counts[key] = total
+}
+
+// take returns the buffered counts and empties the buffer.
+func (b *streamBytesBuffer) take() map[streamBytesKey]streamBytesCount {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	counts := b.counts
+	b.counts = nil
+	return counts
+}
+
+// RecordStreamBytes adds n served bytes for one stream request to the
+// track's counter for the current UTC day. Counts are buffered in memory and
+// written with the next flush.
+func (c *Collector) RecordStreamBytes(albumID int64, stem string, n int64) {
+	key := streamBytesKey{day: time.Now().UTC().Format(sqliteDayLayout), albumID: albumID, stem: stem}
+	c.streamBytes.add(key, streamBytesCount{bytes: n, requests: 1})
+}
+
+// flushStreamBytes writes the buffered stream byte counts. Counts that fail
+// to write go back into the buffer for the next flush.
+func (c *Collector) flushStreamBytes(ctx context.Context) {
+	counts := c.streamBytes.take()
+	if len(counts) == 0 {
+		return
+	}
+	if err := writeStreamBytes(ctx, c.db, counts); err != nil {
+		log.Printf("analytics: %v", err)
+		for key, count := range counts {
+			c.streamBytes.add(key, count)
+		}
+	}
+}
+
+func writeStreamBytes(ctx context.Context, db *sql.DB, counts map[streamBytesKey]streamBytesCount) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("record stream bytes: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO stream_bytes_daily (day, album_id, track_stem, total_bytes, requests)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(day, album_id, track_stem)
+		DO UPDATE SET total_bytes = total_bytes + excluded.total_bytes, requests = requests + excluded.requests
+	`)
+	if err != nil {
+		return fmt.Errorf("record stream bytes: prepare: %w", err)
+	}
+	defer stmt.Close()
+
+	for key, count := range counts {
+		if _, err := stmt.ExecContext(ctx, key.day, key.albumID, key.stem, count.bytes, count.requests); err != nil {
+			return fmt.Errorf("record stream bytes: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("record stream bytes: commit: %w", err)
+	}
+	return nil
+}
+
+// GetStreamBytesFiltered returns per-track byte totals, largest first. Only
+// the date, stem and album parts of filter apply.
+func GetStreamBytesFiltered(db *sql.DB, filter QueryFilter) ([]StreamBytes, error) {
+	filter = normalizeFilter(filter)
+
+	where := []string{"track_stem != ''"}
+	args := make([]interface{}, 0, 8)
+	appendDayFilter(&where, &args, filter)
+	appendStemFilter(&where, &args, "track_stem", filter.Stems)
+	appendAlbumFilter(&where, &args, "album_id", filter.AlbumID)
+
+	rows, err := db.Query(`
+		SELECT track_stem, SUM(total_bytes), SUM(requests)
+		FROM stream_bytes_daily
+		WHERE `+strings.Join(where, " AND ")+`
+		GROUP BY track_stem
+		ORDER BY SUM(total_bytes) DESC, track_stem
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query stream bytes: %w", err)
+	}
+	defer rows.Close()
+
+	totals := []StreamBytes{}
+	for rows.Next() {
+		var sb StreamBytes
+		if err := rows.Scan(&sb.Stem, &sb.TotalBytes, &sb.Requests); err != nil {
+			return nil, fmt.Errorf("scan stream bytes: %w", err)
+		}
+		totals = append(totals, sb)
+	}
+	return totals, rows.Err()
+}
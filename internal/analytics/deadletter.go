@@ -0,0 +1,79 @@
+package analytics
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDeadLetterMaxBytes bounds the dead-letter file when no limit is set.
+const DefaultDeadLetterMaxBytes = 10 << 20
+
+// DeadLetterRecord is one line of the dead-letter file.
+type DeadLetterRecord struct {
+	FailedAt string `json:"failed_at"`
+	Error    string `json:"error"`
+	Event    Event  `json:"event"`
+}
+
+// deadLetterSink appends events that failed to insert to a JSON-lines file
+// so they can be inspected or replayed instead of being silently lost.
+type deadLetterSink struct {
+	path     string
+	maxBytes int64
+
+	mu      sync.Mutex
+	written atomic.Int64
+	lost    atomic.Int64
+}
+
+func newDeadLetterSink(path string, maxBytes int64) *deadLetterSink {
+	if maxBytes <= 0 {
+		maxBytes = DefaultDeadLetterMaxBytes
+	}
+	return &deadLetterSink{path: path, maxBytes: maxBytes}
+}
+
+func (d *deadLetterSink) write(events []Event, cause error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	f, err := os.OpenFile(d.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("analytics: open dead-letter file: %v", err)
+		d.lost.Add(int64(len(events)))
+		return
+	}
+	defer f.Close()
+
+	size := int64(0)
+	if info, err := f.Stat(); err == nil {
+		size = info.Size()
+	}
+
+	failedAt := time.Now().UTC().Format(time.RFC3339)
+	for i, e := range events {
+		line, err := json.Marshal(DeadLetterRecord{FailedAt: failedAt, Error: cause.Error(), Event: e})
+		if err != nil {
+			d.lost.Add(1)
+			continue
+		}
+		line = append(line, '\n')
+		if size+int64(len(line)) > d.maxBytes {
+			remaining := int64(len(events) - i)
+			d.lost.Add(remaining)
+			log.Printf("analytics: dead-letter file full, %d events lost", remaining)
+			return
+		}
+		if _, err := f.Write(line); err != nil {
+			log.Printf("analytics: write dead-letter: %v", err)
+			d.lost.Add(int64(len(events) - i))
+			return
+		}
+		size += int64(len(line))
+		d.written.Add(1)
+	}
+}
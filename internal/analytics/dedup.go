@@ -0,0 +1,92 @@
+package analytics
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// Batch ID deduplication defaults. A retried batch normally arrives within
+// seconds; the cap bounds memory if clients send a flood of unique IDs.
+const (
+	DefaultBatchIDTTL = 10 * time.Minute
+	MaxBatchIDLength  = 128
+	maxTrackedBatches = 100000
+)
+
+// batchDedup remembers recently accepted batch IDs so a client resending a
+// batch after a lost response is not counted twice. IDs are stored as a
+// hash of session and ID, so one session cannot suppress another's batches
+// and raw client values are never kept.
+//
+// order lists claims oldest first. Each claim drops the expired entries at
+// its front, so expiry costs O(1) amortized per claim instead of a scan of
+// the whole map.
+type batchDedup struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	seen  map[[sha256.Size]byte]time.Time
+	order []batchClaim
+	head  int // index of the oldest live entry in order
+}
+
+type batchClaim struct {
+	key [sha256.Size]byte
+	at  time.Time
+}
+
+func newBatchDedup(ttl time.Duration) *batchDedup {
+	if ttl <= 0 {
+		ttl = DefaultBatchIDTTL
+	}
+	return &batchDedup{ttl: ttl, seen: make(map[[sha256.Size]byte]time.Time)}
+}
+
+func batchKey(sessionID, batchID string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(sessionID + "\x00" + batchID))
+}
+
+// claim records key as seen at now, reporting false when it was already
+// seen within the TTL.
+func (d *batchDedup) claim(key [sha256.Size]byte, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.purgeExpired(now)
+	if at, ok := d.seen[key]; ok && now.Sub(at) < d.ttl {
+		return false
+	}
+	if len(d.seen) >= maxTrackedBatches {
+		// Still full of live IDs: accept without tracking rather than
+		// grow without bound. A retry of this batch may then be counted.
+		return true
+	}
+	d.seen[key] = now
+	d.order = append(d.order, batchClaim{key: key, at: now})
+	return true
+}
+
+// release forgets key so a batch that failed to parse can be retried.
+func (d *batchDedup) release(key [sha256.Size]byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.seen, key)
+}
+
+// purgeExpired drops IDs older than the TTL from the front of order. An
+// entry whose key was released or claimed again since no longer matches
+// seen and is skipped. Callers hold mu.
+func (d *batchDedup) purgeExpired(now time.Time) {
+	for d.head < len(d.order) && now.Sub(d.order[d.head].at) >= d.ttl {
+		c := d.order[d.head]
+		if at, ok := d.seen[c.key]; ok && at.Equal(c.at) {
+			delete(d.seen, c.key)
+		}
+		d.order[d.head] = batchClaim{}
+		d.head++
+	}
+	// Reclaim the consumed prefix once it is at least half the slice.
+	if d.head > 0 && d.head*2 >= len(d.order) {
+		d.order = append(d.order[:0], d.order[d.head:]...)
+		d.head = 0
+	}
+}
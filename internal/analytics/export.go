@@ -22,6 +22,34 @@ type ExportEvent struct {
 
 // GetEventsForExport returns raw events ordered by creation time with optional filters.
 func GetEventsForExport(db *sql.DB, filter QueryFilter, limit int) ([]ExportEvent, error) {
+	return queryExportEvents(db, filter, nil, limit)
+}
+
+// GetEventsForExportAfter returns one page of raw events with IDs greater
+// than afterID, ordered by ID so the last ID is the cursor for the next page.
+func GetEventsForExportAfter(db *sql.DB, filter QueryFilter, afterID int64, limit int) ([]ExportEvent, error) {
+	return queryExportEvents(db, filter, &afterID, limit)
+}
+
+// CountEventsForExport returns how many events GetEventsForExport would
+// return for filter without a limit.
+func CountEventsForExport(db *sql.DB, filter QueryFilter) (int, error) {
+	filter = normalizeFilter(filter)
+
+	where := []string{"1=1"}
+	args := make([]interface{}, 0, 8)
+	appendTimeFilter(&where, &args, "created_at", filter)
+	appendStemFilter(&where, &args, "track_stem", filter.Stems)
+	appendEventTypeFilter(&where, &args, "event_type", filter.EventTypes)
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM events WHERE "+strings.Join(where, " AND "), args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count export events: %w", err)
+	}
+	return n, nil
+}
+
+func queryExportEvents(db *sql.DB, filter QueryFilter, afterID *int64, limit int) ([]ExportEvent, error) {
 	filter = normalizeFilter(filter)
 
 	where := []string{"1=1"}
@@ -30,12 +58,18 @@ func GetEventsForExport(db *sql.DB, filter QueryFilter, limit int) ([]ExportEven
 	appendStemFilter(&where, &args, "track_stem", filter.Stems)
 	appendEventTypeFilter(&where, &args, "event_type", filter.EventTypes)
 
+	order := "created_at ASC, id ASC"
+	if afterID != nil {
+		where = append(where, "id > ?")
+		args = append(args, *afterID)
+		order = "id ASC"
+	}
+
 	query := `
 		SELECT id, session_id, event_type, COALESCE(track_stem, ''), COALESCE(position_seconds, 0), COALESCE(metadata, '{}'), created_at
 		FROM events
 		WHERE ` + strings.Join(where, " AND ") + `
-		ORDER BY created_at ASC, id ASC
-	`
+		ORDER BY ` + order
 	if limit > 0 {
 		query += " LIMIT ?"
 		args = append(args, limit)
@@ -101,3 +135,66 @@ func MarshalEventsCSV(events []ExportEvent) ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// tsvMetadataColumns are the metadata keys flattened into their own TSV
+// columns. Other metadata keys are dropped from the TSV export.
+var tsvMetadataColumns = []string{"from_position", "to_position", "source"}
+
+// MarshalEventsTSV serializes events as tab-separated values with known
+// metadata keys flattened into typed columns. Numeric columns are empty when
+// the key is absent or not a number.
+func MarshalEventsTSV(events []ExportEvent) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	header := append([]string{"id", "session_id", "event_type", "track_stem", "position_seconds"}, tsvMetadataColumns...)
+	header = append(header, "created_at")
+	writeTSVRow(buf, header)
+
+	for _, e := range events {
+		var meta map[string]interface{}
+		if e.Metadata != "" {
+			if err := json.Unmarshal([]byte(e.Metadata), &meta); err != nil {
+				meta = nil
+			}
+		}
+		writeTSVRow(buf, []string{
+			fmt.Sprintf("%d", e.ID),
+			e.SessionID,
+			e.EventType,
+			e.TrackStem,
+			fmt.Sprintf("%.3f", e.PositionSeconds),
+			tsvNumber(meta["from_position"]),
+			tsvNumber(meta["to_position"]),
+			tsvString(meta["source"]),
+			e.CreatedAt,
+		})
+	}
+	return buf.Bytes(), nil
+}
+
+func tsvNumber(v interface{}) string {
+	if n, ok := v.(float64); ok {
+		return fmt.Sprintf("%.3f", n)
+	}
+	return ""
+}
+
+func tsvString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
+
+// writeTSVRow writes one line, replacing tabs and line breaks inside fields
+// with spaces so every row keeps the same column count.
+func writeTSVRow(buf *bytes.Buffer, fields []string) {
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte('\t')
+		}
+		buf.WriteString(tsvFieldReplacer.Replace(f))
+	}
+	buf.WriteByte('\n')
+}
+
+var tsvFieldReplacer = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
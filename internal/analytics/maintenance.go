@@ -11,20 +11,43 @@ const (
 	sqliteTimeLayout = "2006-01-02 15:04:05"
 )
 
+// DefaultAuditRetentionDays is how long admin auth and change audit rows are kept.
+const DefaultAuditRetentionDays = 90
+
 // MaintenanceResult summarizes a maintenance run.
 type MaintenanceResult struct {
-	RanAtUTC      string `json:"ran_at_utc"`
-	RetentionDays int    `json:"retention_days"`
-	RolledDays    int    `json:"rolled_days"`
-	RollupRows    int64  `json:"rollup_rows"`
-	PrunedRows    int64  `json:"pruned_rows"`
+	RanAtUTC           string `json:"ran_at_utc"`
+	RetentionDays      int    `json:"retention_days"`
+	RolledDays         int    `json:"rolled_days"`
+	RollupRows         int64  `json:"rollup_rows"`
+	PrunedRows         int64  `json:"pruned_rows"`
+	AuditRetentionDays int    `json:"audit_retention_days"`
+	PrunedAuditRows    int64  `json:"pruned_audit_rows"`
+	// PrunedStreamBytesRows counts stream_bytes_daily rows dropped for days
+	// past RetentionDays.
+	PrunedStreamBytesRows int64 `json:"pruned_stream_bytes_rows"`
+}
+
+// MaintenanceOptions configures a maintenance run. Zero or negative
+// retention keeps the corresponding rows forever.
+type MaintenanceOptions struct {
+	RetentionDays      int // raw events
+	AuditRetentionDays int // admin_auth_audit and admin_change_audit rows
 }
 
 // RunMaintenance materializes daily rollups for completed days and optionally prunes old raw events.
 func RunMaintenance(db *sql.DB, now time.Time, retentionDays int) (MaintenanceResult, error) {
+	return RunMaintenanceWithOptions(db, now, MaintenanceOptions{RetentionDays: retentionDays})
+}
+
+// RunMaintenanceWithOptions is RunMaintenance that can also prune the admin
+// audit logs.
+func RunMaintenanceWithOptions(db *sql.DB, now time.Time, opts MaintenanceOptions) (MaintenanceResult, error) {
+	retentionDays := opts.RetentionDays
 	res := MaintenanceResult{
-		RanAtUTC:      now.UTC().Format(time.RFC3339),
-		RetentionDays: retentionDays,
+		RanAtUTC:           now.UTC().Format(time.RFC3339),
+		RetentionDays:      retentionDays,
+		AuditRetentionDays: max(opts.AuditRetentionDays, 0),
 	}
 
 	days, rows, err := rollupClosedDays(db, now)
@@ -40,77 +63,114 @@ func RunMaintenance(db *sql.DB, now time.Time, retentionDays int) (MaintenanceRe
 	}
 	res.PrunedRows = pruned
 
+	prunedBytes, err := pruneOldStreamBytes(db, now, retentionDays)
+	if err != nil {
+		return res, err
+	}
+	res.PrunedStreamBytesRows = prunedBytes
+
+	prunedAudit, err := pruneOldAuditRows(db, now, opts.AuditRetentionDays)
+	if err != nil {
+		return res, err
+	}
+	res.PrunedAuditRows = prunedAudit
+
 	return res, nil
 }
 
+// rollupClosedDays adds raw events from before today that no earlier run
+// has counted to the daily rollups. analytics_rollup_state records the
+// highest event id already added, so every event is counted exactly once
+// and counts written directly by an aggregate-only collector are kept.
 func rollupClosedDays(db *sql.DB, now time.Time) (int, int64, error) {
-	startDay, ok, err := nextRollupDay(db)
+	tx, err := db.Begin()
 	if err != nil {
-		return 0, 0, err
-	}
-	if !ok {
-		return 0, 0, nil
+		return 0, 0, fmt.Errorf("begin rollup: %w", err)
 	}
+	defer tx.Rollback()
 
-	endDay := dayStartUTC(now).AddDate(0, 0, -1)
-	if startDay.After(endDay) {
-		return 0, 0, nil
+	lastID, err := rollupWatermark(tx)
+	if err != nil {
+		return 0, 0, err
 	}
 
-	totalDays := 0
-	var totalRows int64
-	for day := startDay; !day.After(endDay); day = day.AddDate(0, 0, 1) {
-		dayStart := day.UTC()
-		dayEnd := dayStart.AddDate(0, 0, 1)
+	var maxID sql.NullInt64
+	if err := tx.QueryRow(
+		"SELECT MAX(id) FROM events WHERE id > ? AND created_at < ?",
+		lastID, formatSQLiteTime(dayStartUTC(now)),
+	).Scan(&maxID); err != nil {
+		return 0, 0, fmt.Errorf("query rollup range: %w", err)
+	}
+	if !maxID.Valid {
+		// Still record the watermark, so a legacy database is only
+		// inferred from its rollup days once.
+		return 0, 0, saveRollupWatermark(tx, lastID)
+	}
 
-		result, err := db.Exec(`
-			INSERT INTO analytics_rollups_daily (day, track_stem, event_type, total_count)
-			SELECT ?, COALESCE(track_stem, ''), event_type, COUNT(*)
-			FROM events
-			WHERE created_at >= ? AND created_at < ?
-			GROUP BY COALESCE(track_stem, ''), event_type
-			ON CONFLICT(day, track_stem, event_type)
-			DO UPDATE SET total_count = excluded.total_count
-		`, dayStart.Format(sqliteDayLayout), formatSQLiteTime(dayStart), formatSQLiteTime(dayEnd))
-		if err != nil {
-			return totalDays, totalRows, fmt.Errorf("rollup day %s: %w", dayStart.Format(sqliteDayLayout), err)
-		}
+	var days int
+	if err := tx.QueryRow(
+		"SELECT COUNT(DISTINCT substr(created_at, 1, 10)) FROM events WHERE id > ? AND id <= ?",
+		lastID, maxID.Int64,
+	).Scan(&days); err != nil {
+		return 0, 0, fmt.Errorf("count rollup days: %w", err)
+	}
 
-		if rows, err := result.RowsAffected(); err == nil {
-			totalRows += rows
-		}
-		totalDays++
+	result, err := tx.Exec(`
+		INSERT INTO analytics_rollups_daily (day, album_id, track_stem, event_type, total_count)
+		SELECT substr(created_at, 1, 10), COALESCE(album_id, 0), COALESCE(track_stem, ''), event_type, COUNT(*)
+		FROM events
+		WHERE id > ? AND id <= ?
+		GROUP BY substr(created_at, 1, 10), COALESCE(album_id, 0), COALESCE(track_stem, ''), event_type
+		ON CONFLICT(day, album_id, track_stem, event_type)
+		DO UPDATE SET total_count = total_count + excluded.total_count
+	`, lastID, maxID.Int64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("rollup events %d-%d: %w", lastID+1, maxID.Int64, err)
+	}
+	var rows int64
+	if n, err := result.RowsAffected(); err == nil {
+		rows = n
 	}
 
-	return totalDays, totalRows, nil
+	if err := saveRollupWatermark(tx, maxID.Int64); err != nil {
+		return 0, 0, err
+	}
+	return days, rows, nil
 }
 
-func nextRollupDay(db *sql.DB) (time.Time, bool, error) {
-	var maxDay sql.NullString
-	if err := db.QueryRow("SELECT MAX(day) FROM analytics_rollups_daily").Scan(&maxDay); err != nil {
-		return time.Time{}, false, fmt.Errorf("query max rollup day: %w", err)
+// saveRollupWatermark records lastID as rolled up and commits tx.
+func saveRollupWatermark(tx *sql.Tx, lastID int64) error {
+	if _, err := tx.Exec(`
+		INSERT INTO analytics_rollup_state (id, last_event_id) VALUES (1, ?)
+		ON CONFLICT(id) DO UPDATE SET last_event_id = excluded.last_event_id
+	`, lastID); err != nil {
+		return fmt.Errorf("update rollup state: %w", err)
 	}
-	if maxDay.Valid && maxDay.String != "" {
-		parsed, err := time.ParseInLocation(sqliteDayLayout, maxDay.String, time.UTC)
-		if err != nil {
-			return time.Time{}, false, fmt.Errorf("parse max rollup day: %w", err)
-		}
-		return parsed.AddDate(0, 0, 1), true, nil
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit rollup: %w", err)
 	}
+	return nil
+}
 
-	var minDay sql.NullString
-	if err := db.QueryRow("SELECT MIN(substr(created_at, 1, 10)) FROM events").Scan(&minDay); err != nil {
-		return time.Time{}, false, fmt.Errorf("query min event day: %w", err)
+// rollupWatermark returns the highest event id already rolled up. Databases
+// rolled up before the watermark existed were rolled a whole day at a time,
+// so every event on a day that already has rollups counts as rolled.
+func rollupWatermark(tx *sql.Tx) (int64, error) {
+	var lastID int64
+	err := tx.QueryRow("SELECT last_event_id FROM analytics_rollup_state WHERE id = 1").Scan(&lastID)
+	if err == nil {
+		return lastID, nil
 	}
-	if !minDay.Valid || minDay.String == "" {
-		return time.Time{}, false, nil
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("query rollup state: %w", err)
 	}
-
-	parsed, err := time.ParseInLocation(sqliteDayLayout, minDay.String, time.UTC)
-	if err != nil {
-		return time.Time{}, false, fmt.Errorf("parse min event day: %w", err)
+	if err := tx.QueryRow(`
+		SELECT COALESCE(MAX(id), 0) FROM events
+		WHERE substr(created_at, 1, 10) <= (SELECT MAX(day) FROM analytics_rollups_daily)
+	`).Scan(&lastID); err != nil {
+		return 0, fmt.Errorf("query legacy rollup range: %w", err)
 	}
-	return parsed, true, nil
+	return lastID, nil
 }
 
 func pruneOldEvents(db *sql.DB, now time.Time, retentionDays int) (int64, error) {
@@ -131,6 +191,44 @@ func pruneOldEvents(db *sql.DB, now time.Time, retentionDays int) (int64, error)
 	return rows, nil
 }
 
+// pruneOldStreamBytes drops per-day bandwidth counters older than the raw
+// event retention.
+func pruneOldStreamBytes(db *sql.DB, now time.Time, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+
+	cutoff := dayStartUTC(now).AddDate(0, 0, -retentionDays)
+	result, err := db.Exec("DELETE FROM stream_bytes_daily WHERE day < ?", cutoff.Format(sqliteDayLayout))
+	if err != nil {
+		return 0, fmt.Errorf("prune stream bytes older than %d days: %w", retentionDays, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, nil
+	}
+	return rows, nil
+}
+
+func pruneOldAuditRows(db *sql.DB, now time.Time, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+
+	cutoff := now.UTC().AddDate(0, 0, -retentionDays)
+	var total int64
+	for _, table := range []string{"admin_auth_audit", "admin_change_audit"} {
+		result, err := db.Exec("DELETE FROM "+table+" WHERE occurred_at < ?", formatSQLiteTime(cutoff))
+		if err != nil {
+			return total, fmt.Errorf("prune %s older than %d days: %w", table, retentionDays, err)
+		}
+		if rows, err := result.RowsAffected(); err == nil {
+			total += rows
+		}
+	}
+	return total, nil
+}
+
 func dayStartUTC(t time.Time) time.Time {
 	utc := t.UTC()
 	return time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.UTC)
@@ -0,0 +1,166 @@
+package analytics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+)
+
+// writePending appends events to the pending file as JSON lines, one Event
+// per line, so they can be replayed on the next start.
+func writePending(path string, events []Event) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open pending file: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return fmt.Errorf("write pending file: %w", err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("sync pending file: %w", err)
+	}
+	return f.Close()
+}
+
+// shutdownFlush writes the final batch at Close, giving up once ctx is
+// done. When a pending file is configured, a batch that cannot be committed
+// — because the database fails or ctx runs out — is saved there instead of
+// being dead-lettered or lost. The write runs on the calling goroutine, so
+// nothing touches the database after shutdownFlush returns.
+func (c *Collector) shutdownFlush(ctx context.Context, batch []Event) {
+	if c.pendingPath == "" {
+		c.flushContext(ctx, batch)
+		return
+	}
+
+	failed, err := c.writeWithRetry(ctx, batch)
+	if err == nil {
+		for _, f := range failed {
+			c.deadLetterEvents([]Event{f.event}, f.err)
+		}
+		return
+	}
+	log.Printf("analytics: shutdown flush: %v", err)
+
+	// A failed or abandoned transaction is rolled back, so none of the batch
+	// was committed and saving it cannot duplicate events.
+	if perr := writePending(c.pendingPath, batch); perr != nil {
+		log.Printf("analytics: %v; %d events lost", perr, len(batch))
+		c.deadLetterEvents(batch, err)
+		return
+	}
+	log.Printf("analytics: saved %d unwritten events to %s (%v)", len(batch), c.pendingPath, err)
+}
+
+// maxPendingLine bounds one pending-file line; real events are far smaller.
+const maxPendingLine = 64 << 10
+
+// replayResult reports a pending-file replay.
+type replayResult struct {
+	Replayed int // events written back to the database
+	Corrupt  int // lines that were not a valid event and were skipped
+}
+
+// replayPending writes events saved by an earlier shutdown back to the
+// database; see replayFile.
+func (c *Collector) replayPending() (replayResult, error) {
+	return c.replayFile(c.pendingPath)
+}
+
+// replayFile writes a JSON-lines file of events to the database and removes
+// it. Lines that are not a valid event are skipped; if any were found the
+// file is kept as path.corrupt for inspection. When the database write
+// fails the file is left holding the unwritten events so a later call can
+// try again.
+func (c *Collector) replayFile(path string) (replayResult, error) {
+	var res replayResult
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return res, nil
+	}
+	if err != nil {
+		return res, fmt.Errorf("open pending file: %w", err)
+	}
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 4096), maxPendingLine)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil || !validPendingEvent(e) {
+			res.Corrupt++
+			continue
+		}
+		events = append(events, e)
+	}
+	err = scanner.Err()
+	f.Close()
+	if err != nil {
+		// An overlong or truncated final line; keep what parsed before it.
+		res.Corrupt++
+	}
+
+	for start := 0; start < len(events); start += c.maxBatch {
+		end := min(start+c.maxBatch, len(events))
+		failed, err := c.writeWithRetry(context.Background(), events[start:end])
+		if err != nil {
+			if start > 0 {
+				// Keep only what is still unwritten so a retry cannot
+				// duplicate the batches that already committed.
+				if rerr := rewritePending(path, events[start:]); rerr != nil {
+					log.Printf("analytics: %v", rerr)
+				}
+			}
+			return res, fmt.Errorf("replay pending events: %w", err)
+		}
+		for _, fe := range failed {
+			c.deadLetterEvents([]Event{fe.event}, fe.err)
+		}
+		res.Replayed += end - start - len(failed)
+	}
+
+	if res.Corrupt > 0 {
+		return res, os.Rename(path, path+".corrupt")
+	}
+	return res, os.Remove(path)
+}
+
+// validPendingEvent rejects lines that decoded but cannot be a saved event.
+func validPendingEvent(e Event) bool {
+	if e.SessionID == "" || e.EventType == "" || len(e.EventType) > 64 {
+		return false
+	}
+	if e.TrackStem != "" && !validTrackStem(e.TrackStem) {
+		return false
+	}
+	if e.Metadata != "" && (len(e.Metadata) > MaxMetaBytes || !json.Valid([]byte(e.Metadata))) {
+		return false
+	}
+	return e.PositionSeconds >= 0 && e.AlbumID >= 0
+}
+
+// rewritePending replaces the pending file with events.
+func rewritePending(path string, events []Event) error {
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if err := writePending(tmp, events); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
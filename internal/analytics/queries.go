@@ -29,13 +29,14 @@ type SessionInfo struct {
 	StartedAt   string `json:"started_at"`
 	LastSeenAt  string `json:"last_seen_at"`
 	TracksHeard int    `json:"tracks_heard"`
-	IPHash      string `json:"ip_hash"`
+	IPHash      string `json:"ip_hash,omitempty"`
 }
 
 // OverallStats holds aggregate analytics.
 type OverallStats struct {
 	TotalSessions    int     `json:"total_sessions"`
 	AvgTracksPerSess float64 `json:"avg_tracks_per_session"`
+	AvgSessionSecs   float64 `json:"avg_session_seconds"`
 	MostCompleted    string  `json:"most_completed"`
 	LeastCompleted   string  `json:"least_completed"`
 }
@@ -264,17 +265,17 @@ func GetOverallStatsFiltered(db *sql.DB, filter QueryFilter) (*OverallStats, err
 	stats := &OverallStats{}
 
 	// Total sessions (session start time-based filter, scoped to album if set).
-	whereSessions := []string{"1=1"}
-	argsSessions := make([]interface{}, 0, 4)
-	appendTimeFilter(&whereSessions, &argsSessions, "started_at", filter)
-	if filter.AlbumID != nil {
-		whereSessions = append(whereSessions, "id IN (SELECT DISTINCT session_id FROM events WHERE album_id = ?)")
-		argsSessions = append(argsSessions, *filter.AlbumID)
-	}
-	if err := db.QueryRow("SELECT COUNT(*) FROM sessions WHERE "+strings.Join(whereSessions, " AND "), argsSessions...).Scan(&stats.TotalSessions); err != nil {
+	whereSessions, argsSessions := sessionFilter(filter)
+	if err := db.QueryRow("SELECT COUNT(*) FROM sessions WHERE "+whereSessions, argsSessions...).Scan(&stats.TotalSessions); err != nil {
 		return nil, fmt.Errorf("query total sessions: %w", err)
 	}
 
+	avgSecs, err := GetAverageSessionDuration(db, filter)
+	if err != nil {
+		return nil, err
+	}
+	stats.AvgSessionSecs = avgSecs.Seconds()
+
 	// Build event filter once for aggregate event queries.
 	eventWhere := []string{"1=1"}
 	eventArgs := make([]interface{}, 0, 8)
@@ -326,6 +327,40 @@ func GetOverallStatsFiltered(db *sql.DB, filter QueryFilter) (*OverallStats, err
 	return stats, nil
 }
 
+// GetAverageSessionDuration returns the mean time between a session's start
+// and its last activity across sessions matching filter. Sessions that never
+// did anything after starting count as zero length; zero is returned when
+// no sessions match.
+func GetAverageSessionDuration(db *sql.DB, filter QueryFilter) (time.Duration, error) {
+	filter = normalizeFilter(filter)
+	where, args := sessionFilter(filter)
+
+	// Timestamps are stored as Go time strings in UTC; their first 19
+	// characters are a datetime SQLite can parse.
+	var secs float64
+	if err := db.QueryRow(`
+		SELECT COALESCE(AVG(MAX(0, COALESCE(
+			(julianday(substr(last_seen_at, 1, 19)) - julianday(substr(started_at, 1, 19))) * 86400, 0))), 0)
+		FROM sessions
+		WHERE `+where, args...).Scan(&secs); err != nil {
+		return 0, fmt.Errorf("query avg session duration: %w", err)
+	}
+	return time.Duration(secs * float64(time.Second)).Round(time.Millisecond), nil
+}
+
+// sessionFilter builds the WHERE clause selecting sessions that started in
+// the filter's time range and, when scoped to an album, touched it.
+func sessionFilter(filter QueryFilter) (string, []interface{}) {
+	where := []string{"1=1"}
+	args := make([]interface{}, 0, 4)
+	appendTimeFilter(&where, &args, "started_at", filter)
+	if filter.AlbumID != nil {
+		where = append(where, "id IN (SELECT DISTINCT session_id FROM events WHERE album_id = ?)")
+		args = append(args, *filter.AlbumID)
+	}
+	return strings.Join(where, " AND "), args
+}
+
 func normalizeFilter(filter QueryFilter) QueryFilter {
 	out := QueryFilter{}
 	if filter.From != nil {
@@ -425,3 +460,69 @@ func cloneInterfaces(in []interface{}) []interface{} {
 	copy(out, in)
 	return out
 }
+
+// FunnelStep is one track position in the album funnel.
+type FunnelStep struct {
+	Position int    `json:"position"` // 1-based, in track order
+	Stem     string `json:"stem"`
+	Sessions int    `json:"sessions"`
+}
+
+// GetAlbumFunnel counts, for each position in trackOrder, the sessions whose
+// furthest played track is at or beyond it, so the first step is everyone
+// who played anything and the last is everyone who reached the final track.
+// Plays of stems not in trackOrder are ignored. The stem and event type
+// parts of filter do not apply.
+func GetAlbumFunnel(db *sql.DB, filter QueryFilter, trackOrder []string) ([]FunnelStep, error) {
+	filter = normalizeFilter(filter)
+
+	steps := make([]FunnelStep, len(trackOrder))
+	position := make(map[string]int, len(trackOrder))
+	for i, stem := range trackOrder {
+		steps[i] = FunnelStep{Position: i + 1, Stem: stem}
+		if _, dup := position[stem]; !dup {
+			position[stem] = i
+		}
+	}
+	if len(trackOrder) == 0 {
+		return steps, nil
+	}
+
+	where := []string{"event_type = 'play'", "track_stem IS NOT NULL", "track_stem != ''"}
+	args := make([]interface{}, 0, 4)
+	appendTimeFilter(&where, &args, "created_at", filter)
+	appendAlbumFilter(&where, &args, "album_id", filter.AlbumID)
+
+	rows, err := db.Query(`
+		SELECT DISTINCT session_id, track_stem FROM events
+		WHERE `+strings.Join(where, " AND "), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query album funnel: %w", err)
+	}
+	defer rows.Close()
+
+	furthest := make(map[string]int)
+	for rows.Next() {
+		var sessionID, stem string
+		if err := rows.Scan(&sessionID, &stem); err != nil {
+			return nil, fmt.Errorf("scan album funnel: %w", err)
+		}
+		pos, ok := position[stem]
+		if !ok {
+			continue
+		}
+		if prev, seen := furthest[sessionID]; !seen || pos > prev {
+			furthest[sessionID] = pos
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scan album funnel: %w", err)
+	}
+
+	for _, pos := range furthest {
+		for i := 0; i <= pos; i++ {
+			steps[i].Sessions++
+		}
+	}
+	return steps, nil
+}
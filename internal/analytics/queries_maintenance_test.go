@@ -1,6 +1,8 @@
 package analytics
 
 import (
+	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,6 +29,108 @@ func TestGetTrackStatsFiltered(t *testing.T) {
 	}
 }
 
+func TestGetAverageSessionDuration(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	if d, err := GetAverageSessionDuration(db, QueryFilter{}); err != nil || d != 0 {
+		t.Fatalf("empty average = %v, %v; want 0", d, err)
+	}
+
+	// Stored the way the session store writes them: time.Time values.
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, s := range []struct {
+		id   string
+		span time.Duration
+	}{
+		{"s1", 90 * time.Second},
+		{"s2", 30 * time.Minute},
+		{"s3", 0}, // never did anything after starting
+		{"s4", 10 * time.Minute},
+	} {
+		if _, err := db.Exec("INSERT INTO sessions (id, started_at, last_seen_at) VALUES (?, ?, ?)", s.id, start, start.Add(s.span)); err != nil {
+			t.Fatalf("insert session: %v", err)
+		}
+	}
+	for _, id := range []string{"s1", "s2", "s3"} {
+		_, _ = db.Exec("INSERT INTO events (session_id, event_type, album_id) VALUES (?, 'session_start', 7)", id)
+	}
+
+	d, err := GetAverageSessionDuration(db, QueryFilter{})
+	if err != nil {
+		t.Fatalf("GetAverageSessionDuration: %v", err)
+	}
+	if want := (90*time.Second + 30*time.Minute + 10*time.Minute) / 4; d != want {
+		t.Fatalf("average = %v, want %v", d, want)
+	}
+
+	albumID := int64(7)
+	stats, err := GetOverallStatsFiltered(db, QueryFilter{AlbumID: &albumID})
+	if err != nil {
+		t.Fatalf("GetOverallStatsFiltered: %v", err)
+	}
+	if want := (90 + 1800.0) / 3; stats.AvgSessionSecs != want {
+		t.Fatalf("album avg_session_seconds = %v, want %v", stats.AvgSessionSecs, want)
+	}
+}
+
+func TestGetAlbumFunnel(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	play := func(session, stem string, albumID int64) {
+		t.Helper()
+		if _, err := db.Exec("INSERT INTO events (session_id, event_type, track_stem, album_id) VALUES (?, 'play', ?, ?)", session, stem, albumID); err != nil {
+			t.Fatalf("insert play: %v", err)
+		}
+	}
+	// s1 stops after track 1, s2 after track 2 (replaying it), s3 finishes,
+	// s4 skips straight to track 3, s5 only plays a stem that is not in the
+	// order, and s6 belongs to another album.
+	play("s1", "01-a", 1)
+	play("s2", "01-a", 1)
+	play("s2", "02-b", 1)
+	play("s2", "02-b", 1)
+	play("s3", "01-a", 1)
+	play("s3", "02-b", 1)
+	play("s3", "03-c", 1)
+	play("s4", "03-c", 1)
+	play("s5", "99-bonus", 1)
+	play("s6", "03-c", 2)
+	_, _ = db.Exec("INSERT INTO events (session_id, event_type, track_stem, album_id) VALUES ('s1', 'complete', '03-c', 1)")
+
+	albumID := int64(1)
+	steps, err := GetAlbumFunnel(db, QueryFilter{AlbumID: &albumID}, []string{"01-a", "02-b", "03-c"})
+	if err != nil {
+		t.Fatalf("GetAlbumFunnel: %v", err)
+	}
+	want := []FunnelStep{
+		{Position: 1, Stem: "01-a", Sessions: 4},
+		{Position: 2, Stem: "02-b", Sessions: 3},
+		{Position: 3, Stem: "03-c", Sessions: 2},
+	}
+	if len(steps) != len(want) {
+		t.Fatalf("steps = %+v, want %+v", steps, want)
+	}
+	for i := range want {
+		if steps[i] != want[i] {
+			t.Fatalf("steps = %+v, want %+v", steps, want)
+		}
+	}
+
+	if steps, err := GetAlbumFunnel(db, QueryFilter{}, nil); err != nil || len(steps) != 0 {
+		t.Fatalf("empty track order = %+v, %v", steps, err)
+	}
+}
+
 func TestRunMaintenanceRollupAndPrune(t *testing.T) {
 	dir := t.TempDir()
 	db, err := database.Open(dir)
@@ -63,3 +167,196 @@ func TestRunMaintenanceRollupAndPrune(t *testing.T) {
 		t.Fatalf("expected pruned events, remaining=%d", remaining)
 	}
 }
+
+func TestRunMaintenanceRollupAddsToExistingCounts(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	// Counts written directly by an aggregate-only collector, then raw
+	// events for the same day after the mode was turned off.
+	if _, err := db.Exec("INSERT INTO analytics_rollups_daily (day, album_id, track_stem, event_type, total_count) VALUES ('2026-02-09', 7, '01-a', 'play', 5)"); err != nil {
+		t.Fatalf("seed rollup: %v", err)
+	}
+	if _, err := RunMaintenance(db, time.Date(2026, 2, 9, 12, 0, 0, 0, time.UTC), 0); err != nil {
+		t.Fatalf("RunMaintenance before raw events: %v", err)
+	}
+	for _, createdAt := range []string{"2026-02-09 13:00:00", "2026-02-09 14:00:00"} {
+		if _, err := db.Exec("INSERT INTO events (session_id, event_type, track_stem, album_id, created_at) VALUES ('s1', 'play', '01-a', 7, ?)", createdAt); err != nil {
+			t.Fatalf("seed event: %v", err)
+		}
+	}
+
+	total := func() int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow("SELECT total_count FROM analytics_rollups_daily WHERE day = '2026-02-09' AND album_id = 7 AND track_stem = '01-a' AND event_type = 'play'").Scan(&n); err != nil {
+			t.Fatalf("query rollup: %v", err)
+		}
+		return n
+	}
+
+	now := time.Date(2026, 2, 11, 12, 0, 0, 0, time.UTC)
+	res, err := RunMaintenance(db, now, 0)
+	if err != nil {
+		t.Fatalf("RunMaintenance: %v", err)
+	}
+	if res.RolledDays != 1 || total() != 7 {
+		t.Fatalf("rolled days = %d, total = %d; want 1, 7", res.RolledDays, total())
+	}
+
+	// Events already counted are not added again.
+	if res, err := RunMaintenance(db, now, 0); err != nil || res.RolledDays != 0 {
+		t.Fatalf("second run = %+v, %v; want nothing rolled", res, err)
+	}
+	if got := total(); got != 7 {
+		t.Fatalf("total after second run = %d, want 7", got)
+	}
+}
+
+func TestAggregateOnlyCollectorWritesRollups(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	c := NewCollectorWithOptions(db, CollectorOptions{AggregateOnly: true})
+	batch := []byte(`[
+		{"event_type":"session_start"},
+		{"event_type":"play","track_stem":"01-a"},
+		{"event_type":"play","track_stem":"01-a"},
+		{"event_type":"complete","track_stem":"01-a"},
+		{"event_type":"play","track_stem":"02-b"}
+	]`)
+	if err := c.RecordBatch(testSessionID, batch, 7); err != nil {
+		t.Fatalf("RecordBatch: %v", err)
+	}
+	if err := c.RecordBatch(testSessionID, []byte(`[{"event_type":"play","track_stem":"01-a"}]`), 8); err != nil {
+		t.Fatalf("RecordBatch other album: %v", err)
+	}
+	c.Close()
+
+	var events int
+	if err := db.QueryRow("SELECT COUNT(*) FROM events").Scan(&events); err != nil {
+		t.Fatalf("count events: %v", err)
+	}
+	if events != 0 {
+		t.Fatalf("events rows = %d, want 0", events)
+	}
+
+	albumID := int64(7)
+	stats, err := GetTrackStatsFromRollups(db, QueryFilter{AlbumID: &albumID})
+	if err != nil {
+		t.Fatalf("GetTrackStatsFromRollups: %v", err)
+	}
+	if len(stats) != 2 || stats[0].Stem != "01-a" || stats[0].TotalPlays != 2 || stats[0].Completions != 1 || stats[1].TotalPlays != 1 {
+		t.Fatalf("unexpected rollup stats: %+v", stats)
+	}
+
+	overall, err := GetOverallStatsFromRollups(db, QueryFilter{AlbumID: &albumID})
+	if err != nil {
+		t.Fatalf("GetOverallStatsFromRollups: %v", err)
+	}
+	if overall.TotalSessions != 1 || overall.MostCompleted != "01-a" || overall.LeastCompleted != "02-b" {
+		t.Fatalf("unexpected rollup overall: %+v", overall)
+	}
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+	stats, err = GetTrackStatsFromRollups(db, QueryFilter{AlbumID: &albumID, From: &tomorrow})
+	if err != nil || len(stats) != 0 {
+		t.Fatalf("future-filtered stats = %+v, err = %v", stats, err)
+	}
+}
+
+func TestStreamBytesBufferedUntilFlushAndPruned(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	c := NewCollector(db)
+	defer c.Close()
+	c.RecordStreamBytes(7, "01-a", 100)
+	c.RecordStreamBytes(7, "01-a", 50)
+	c.RecordStreamBytes(8, "01-a", 999)
+
+	albumID := int64(7)
+	if totals, err := GetStreamBytesFiltered(db, QueryFilter{AlbumID: &albumID}); err != nil || len(totals) != 0 {
+		t.Fatalf("totals before flush = %+v, %v; want none", totals, err)
+	}
+	if err := c.FlushNow(context.Background()); err != nil {
+		t.Fatalf("FlushNow: %v", err)
+	}
+	totals, err := GetStreamBytesFiltered(db, QueryFilter{AlbumID: &albumID})
+	if err != nil || len(totals) != 1 || totals[0].TotalBytes != 150 || totals[0].Requests != 2 {
+		t.Fatalf("totals = %+v, %v; want 150 bytes over 2 requests", totals, err)
+	}
+
+	if _, err := db.Exec("INSERT INTO stream_bytes_daily (day, album_id, track_stem, total_bytes, requests) VALUES ('2025-01-01', 7, '01-a', 10, 1)"); err != nil {
+		t.Fatalf("seed old row: %v", err)
+	}
+	res, err := RunMaintenance(db, time.Now().UTC(), 30)
+	if err != nil {
+		t.Fatalf("RunMaintenance: %v", err)
+	}
+	if res.PrunedStreamBytesRows != 1 {
+		t.Fatalf("pruned stream bytes rows = %d, want 1", res.PrunedStreamBytesRows)
+	}
+}
+
+func TestRunMaintenancePrunesOldAuditRows(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.Open(dir)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2026, 2, 11, 12, 0, 0, 0, time.UTC)
+	for _, occurredAt := range []string{"2025-06-01 09:00:00", "2025-11-12 11:59:59", "2026-01-20 08:00:00", "2026-02-11 11:00:00"} {
+		if _, err := db.Exec("INSERT INTO admin_auth_audit (occurred_at, attempted_username, outcome, reason) VALUES (?, 'admin', 'success', 'ok')", occurredAt); err != nil {
+			t.Fatalf("seed audit row: %v", err)
+		}
+	}
+
+	// Plain RunMaintenance leaves the audit log alone.
+	res, err := RunMaintenance(db, now, 0)
+	if err != nil {
+		t.Fatalf("RunMaintenance: %v", err)
+	}
+	if res.PrunedAuditRows != 0 {
+		t.Fatalf("RunMaintenance pruned %d audit rows, want 0", res.PrunedAuditRows)
+	}
+
+	res, err = RunMaintenanceWithOptions(db, now, MaintenanceOptions{AuditRetentionDays: DefaultAuditRetentionDays})
+	if err != nil {
+		t.Fatalf("RunMaintenanceWithOptions: %v", err)
+	}
+	if res.PrunedAuditRows != 2 || res.AuditRetentionDays != DefaultAuditRetentionDays {
+		t.Fatalf("unexpected maintenance result: %+v", res)
+	}
+
+	var remaining []string
+	rows, err := db.Query("SELECT occurred_at FROM admin_auth_audit ORDER BY occurred_at")
+	if err != nil {
+		t.Fatalf("query audit rows: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var occurredAt string
+		if err := rows.Scan(&occurredAt); err != nil {
+			t.Fatalf("scan audit row: %v", err)
+		}
+		remaining = append(remaining, occurredAt)
+	}
+	if len(remaining) != 2 || !strings.HasPrefix(remaining[0], "2026-01-20") || !strings.HasPrefix(remaining[1], "2026-02-11") {
+		t.Fatalf("remaining audit rows = %v, want only the recent two", remaining)
+	}
+}
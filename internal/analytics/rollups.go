@@ -0,0 +1,112 @@
+package analytics
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GetTrackStatsFromRollups returns per-track analytics computed from daily
+// rollups. Rollups carry no session data, so UniqueSessions is always zero.
+func GetTrackStatsFromRollups(db *sql.DB, filter QueryFilter) ([]TrackStats, error) {
+	filter = normalizeFilter(filter)
+
+	where := []string{
+		"track_stem != ''",
+		"event_type IN ('play', 'complete')",
+	}
+	args := make([]interface{}, 0, 8)
+	appendDayFilter(&where, &args, filter)
+	appendStemFilter(&where, &args, "track_stem", filter.Stems)
+	appendAlbumFilter(&where, &args, "album_id", filter.AlbumID)
+
+	rows, err := db.Query(`
+		SELECT
+			track_stem,
+			COALESCE(SUM(CASE WHEN event_type = 'play' THEN total_count END), 0) as total_plays,
+			COALESCE(SUM(CASE WHEN event_type = 'complete' THEN total_count END), 0) as completions
+		FROM analytics_rollups_daily
+		WHERE `+strings.Join(where, " AND ")+`
+		GROUP BY track_stem
+		ORDER BY total_plays DESC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query rollup track stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []TrackStats
+	for rows.Next() {
+		var s TrackStats
+		if err := rows.Scan(&s.Stem, &s.TotalPlays, &s.Completions); err != nil {
+			return nil, fmt.Errorf("scan rollup track stats: %w", err)
+		}
+		if s.TotalPlays > 0 {
+			s.CompletionRate = float64(s.Completions) / float64(s.TotalPlays)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// GetOverallStatsFromRollups returns aggregate analytics computed from daily
+// rollups. TotalSessions counts session_start events; AvgTracksPerSess and
+// AvgSessionSecs are unavailable without per-session rows and are left at
+// zero.
+func GetOverallStatsFromRollups(db *sql.DB, filter QueryFilter) (*OverallStats, error) {
+	filter = normalizeFilter(filter)
+	stats := &OverallStats{}
+
+	where := []string{"1=1"}
+	args := make([]interface{}, 0, 8)
+	appendDayFilter(&where, &args, filter)
+	appendAlbumFilter(&where, &args, "album_id", filter.AlbumID)
+
+	sessionWhere := append(cloneStrings(where), "event_type = 'session_start'")
+	if err := db.QueryRow(
+		"SELECT COALESCE(SUM(total_count), 0) FROM analytics_rollups_daily WHERE "+strings.Join(sessionWhere, " AND "),
+		args...,
+	).Scan(&stats.TotalSessions); err != nil {
+		return nil, fmt.Errorf("query rollup sessions: %w", err)
+	}
+
+	trackWhere := append(cloneStrings(where), "track_stem != ''")
+	trackArgs := cloneInterfaces(args)
+	appendStemFilter(&trackWhere, &trackArgs, "track_stem", filter.Stems)
+
+	_ = db.QueryRow(`
+		SELECT track_stem FROM analytics_rollups_daily
+		WHERE `+strings.Join(trackWhere, " AND ")+` AND event_type = 'complete'
+		GROUP BY track_stem
+		ORDER BY SUM(total_count) DESC LIMIT 1
+	`, trackArgs...).Scan(&stats.MostCompleted)
+
+	_ = db.QueryRow(`
+		SELECT track_stem FROM (
+			SELECT track_stem,
+				CAST(SUM(CASE WHEN event_type = 'complete' THEN total_count ELSE 0 END) AS REAL) /
+				NULLIF(SUM(CASE WHEN event_type = 'play' THEN total_count ELSE 0 END), 0) as rate
+			FROM analytics_rollups_daily
+			WHERE `+strings.Join(trackWhere, " AND ")+`
+			GROUP BY track_stem
+			HAVING SUM(CASE WHEN event_type = 'play' THEN total_count ELSE 0 END) > 0
+		) ORDER BY rate ASC LIMIT 1
+	`, trackArgs...).Scan(&stats.LeastCompleted)
+
+	return stats, nil
+}
+
+// appendDayFilter narrows rollup rows to the days overlapping the filter's
+// time range. A partial day at either end is included whole.
+func appendDayFilter(where *[]string, args *[]interface{}, filter QueryFilter) {
+	if filter.From != nil {
+		*where = append(*where, "day >= ?")
+		*args = append(*args, filter.From.UTC().Format(sqliteDayLayout))
+	}
+	if filter.To != nil {
+		*where = append(*where, "day < ?")
+		end := dayStartUTC(filter.To.Add(-time.Nanosecond)).AddDate(0, 0, 1)
+		*args = append(*args, end.Format(sqliteDayLayout))
+	}
+}
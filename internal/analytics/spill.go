@@ -0,0 +1,165 @@
+package analytics
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+)
+
+// DefaultSpillMaxBytes bounds the overflow queue when no limit is set.
+const DefaultSpillMaxBytes = 50 << 20
+
+// spillQueue is a disk-backed overflow for events that arrive while the
+// in-memory channel is full. New events are appended to path through one
+// buffered handle kept open between adds; the flush loop flushes and closes
+// it, renames path to path.draining, and writes that file to the database,
+// so appends never race with a drain in progress.
+type spillQueue struct {
+	path     string
+	maxBytes int64
+
+	mu            sync.Mutex
+	file          *os.File      // append handle for path, nil until the next add
+	buf           *bufio.Writer // buffers writes to file
+	closed        bool
+	spillBytes    int64 // size of path, including buffered bytes
+	drainingBytes int64 // size of path.draining
+}
+
+func newSpillQueue(path string, maxBytes int64) *spillQueue {
+	if maxBytes <= 0 {
+		maxBytes = DefaultSpillMaxBytes
+	}
+	q := &spillQueue{path: path, maxBytes: maxBytes}
+	// Overflow left by an earlier run counts against the cap and is
+	// drained like any other.
+	if info, err := os.Stat(path); err == nil {
+		q.spillBytes = info.Size()
+	}
+	if info, err := os.Stat(q.drainingPath()); err == nil {
+		q.drainingBytes = info.Size()
+	}
+	return q
+}
+
+func (q *spillQueue) drainingPath() string {
+	return q.path + ".draining"
+}
+
+// add appends e, reporting false when the queue is full, closed, or
+// unwritable.
+func (q *spillQueue) add(e Event) bool {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return false
+	}
+	line = append(line, '\n')
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || q.spillBytes+q.drainingBytes+int64(len(line)) > q.maxBytes {
+		return false
+	}
+	if q.file == nil {
+		f, err := os.OpenFile(q.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			log.Printf("analytics: open spill file: %v", err)
+			return false
+		}
+		q.file, q.buf = f, bufio.NewWriter(f)
+	}
+	if _, err := q.buf.Write(line); err != nil {
+		log.Printf("analytics: write spill file: %v", err)
+		q.closeFile()
+		return false
+	}
+	q.spillBytes += int64(len(line))
+	return true
+}
+
+// closeFile flushes and closes the append handle, if open. The next add
+// reopens path. Callers hold mu.
+func (q *spillQueue) closeFile() {
+	if q.file == nil {
+		return
+	}
+	err := q.buf.Flush()
+	if cerr := q.file.Close(); err == nil {
+		err = cerr
+	}
+	q.file, q.buf = nil, nil
+	if err != nil {
+		log.Printf("analytics: write spill file: %v", err)
+		// Count only what reached the file.
+		if info, serr := os.Stat(q.path); serr == nil {
+			q.spillBytes = info.Size()
+		}
+	}
+}
+
+// close flushes buffered events to disk, leaving them for the next start,
+// and stops further adds.
+func (q *spillQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closeFile()
+	q.closed = true
+}
+
+// size returns the bytes currently queued on disk.
+func (q *spillQueue) size() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.spillBytes + q.drainingBytes
+}
+
+// overflow handles an event the channel had no room for: it is spilled to
+// disk when a spill queue is configured and has room, and dropped otherwise.
+func (c *Collector) overflow(e Event) {
+	if c.spill != nil && c.spill.add(e) {
+		c.spilled.Add(1)
+		return
+	}
+	c.dropped.Add(1)
+}
+
+// drainSpill writes spilled events to the database. It runs on the flush
+// loop only, so it never overlaps a regular flush. A draining file that
+// fails to write is kept and retried on the next call.
+func (c *Collector) drainSpill() {
+	q := c.spill
+	draining := q.drainingPath()
+
+	q.mu.Lock()
+	if q.drainingBytes == 0 {
+		if q.spillBytes == 0 {
+			q.mu.Unlock()
+			return
+		}
+		q.closeFile()
+		if err := os.Rename(q.path, draining); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			q.mu.Unlock()
+			log.Printf("analytics: rotate spill file: %v", err)
+			return
+		}
+		q.drainingBytes, q.spillBytes = q.spillBytes, 0
+	}
+	q.mu.Unlock()
+
+	res, err := c.replayFile(draining)
+	if err != nil {
+		log.Printf("analytics: drain spill file: %v", err)
+		return
+	}
+	if res.Corrupt > 0 {
+		log.Printf("analytics: skipped %d corrupt spill lines", res.Corrupt)
+	}
+
+	q.mu.Lock()
+	q.drainingBytes = 0
+	q.mu.Unlock()
+}
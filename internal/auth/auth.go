@@ -8,6 +8,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -19,20 +20,99 @@ const (
 	SessionExpiry      = 7 * 24 * time.Hour
 	AdminSessionExpiry = 1 * time.Hour
 	CleanupInterval    = 1 * time.Hour
+	// Default minimum intervals between last_seen_at writes for an active
+	// session; see SessionStoreOptions.
 	SessionTouchWindow = 1 * time.Minute
 	AdminTouchWindow   = 5 * time.Minute
+
+	// DefaultAdminSessionMaxLifetime bounds a sliding admin session no
+	// matter how active it stays.
+	DefaultAdminSessionMaxLifetime = 12 * time.Hour
+)
+
+// FingerprintBinding controls which client fingerprints an admin session is
+// bound to after login. A mismatch revokes the session.
+type FingerprintBinding string
+
+const (
+	// FingerprintStrict binds admin sessions to both client IP and user agent.
+	FingerprintStrict FingerprintBinding = "strict"
+	// FingerprintUAOnly binds to the user agent and tolerates IP changes,
+	// e.g. on mobile networks with rotating addresses.
+	FingerprintUAOnly FingerprintBinding = "ua_only"
+	// FingerprintOff disables fingerprint binding.
+	FingerprintOff FingerprintBinding = "off"
 )
 
-// SessionStore manages listener and admin sessions in SQLite.
+// ParseFingerprintBinding parses a binding name. Empty means strict.
+func ParseFingerprintBinding(value string) (FingerprintBinding, error) {
+	switch b := FingerprintBinding(strings.ToLower(strings.TrimSpace(value))); b {
+	case "":
+		return FingerprintStrict, nil
+	case FingerprintStrict, FingerprintUAOnly, FingerprintOff:
+		return b, nil
+	default:
+		return FingerprintStrict, fmt.Errorf("unknown fingerprint binding %q", value)
+	}
+}
+
+// SessionStore manages listener and admin sessions. Session records live
+// in a SessionBackend; admin users and known devices are always read from
+// the database.
 type SessionStore struct {
-	db   *sql.DB
-	salt string
-	done chan struct{}
-	once sync.Once
+	db        *sql.DB
+	backend   SessionBackend
+	salt      string
+	anonymous bool
+	binding   FingerprintBinding
+	done      chan struct{}
+	once      sync.Once
+
+	adminSliding     bool
+	adminIdleTimeout time.Duration
+	adminMaxLifetime time.Duration
+
+	touchWindow      time.Duration
+	adminTouchWindow time.Duration
+}
+
+// SessionStoreOptions configures optional SessionStore behavior.
+type SessionStoreOptions struct {
+	// Anonymous stores no IP-derived data: client IPs are never hashed, and
+	// admin sessions are bound to the user agent only.
+	Anonymous bool
+	// AdminFingerprint selects admin session fingerprint binding; empty
+	// means FingerprintStrict.
+	AdminFingerprint FingerprintBinding
+	// AdminSliding keeps admin sessions alive while they are used: a session
+	// expires after AdminIdleTimeout without activity or AdminMaxLifetime
+	// after login, whichever comes first. When false, admin sessions end
+	// AdminSessionExpiry after login regardless of activity.
+	AdminSliding bool
+	// AdminIdleTimeout defaults to AdminSessionExpiry.
+	AdminIdleTimeout time.Duration
+	// AdminMaxLifetime defaults to DefaultAdminSessionMaxLifetime and is
+	// never shorter than AdminIdleTimeout.
+	AdminMaxLifetime time.Duration
+	// TouchWindow and AdminTouchWindow are the minimum intervals between
+	// last_seen_at writes for listener and admin sessions. Larger windows
+	// mean fewer writes on constrained storage. They default to
+	// SessionTouchWindow and AdminTouchWindow and are capped at half the
+	// matching idle timeout so an active session cannot lapse between writes.
+	TouchWindow      time.Duration
+	AdminTouchWindow time.Duration
+	// Backend stores session records; nil means a SQLiteSessionBackend on
+	// the store's database.
+	Backend SessionBackend
 }
 
 // NewSessionStore creates a session store and starts the cleanup goroutine.
 func NewSessionStore(db *sql.DB) *SessionStore {
+	return NewSessionStoreWithOptions(db, SessionStoreOptions{})
+}
+
+// NewSessionStoreWithOptions creates a session store with the given options.
+func NewSessionStoreWithOptions(db *sql.DB, opts SessionStoreOptions) *SessionStore {
 	// Generate a random salt for IP hashing
 	saltBytes := make([]byte, 16)
 	if _, err := rand.Read(saltBytes); err != nil {
@@ -42,10 +122,43 @@ func NewSessionStore(db *sql.DB) *SessionStore {
 	}
 
 	s := &SessionStore{
-		db:   db,
-		salt: hex.EncodeToString(saltBytes),
-		done: make(chan struct{}),
+		db:        db,
+		backend:   opts.Backend,
+		salt:      hex.EncodeToString(saltBytes),
+		anonymous: opts.Anonymous,
+		binding:   opts.AdminFingerprint,
+		done:      make(chan struct{}),
+
+		adminSliding:     opts.AdminSliding,
+		adminIdleTimeout: opts.AdminIdleTimeout,
+		adminMaxLifetime: opts.AdminMaxLifetime,
+
+		touchWindow:      opts.TouchWindow,
+		adminTouchWindow: opts.AdminTouchWindow,
 	}
+	if s.backend == nil {
+		s.backend = NewSQLiteSessionBackend(db)
+	}
+	if s.binding == "" {
+		s.binding = FingerprintStrict
+	}
+	if s.adminIdleTimeout <= 0 {
+		s.adminIdleTimeout = AdminSessionExpiry
+	}
+	if s.adminMaxLifetime <= 0 {
+		s.adminMaxLifetime = DefaultAdminSessionMaxLifetime
+	}
+	if s.adminMaxLifetime < s.adminIdleTimeout {
+		s.adminMaxLifetime = s.adminIdleTimeout
+	}
+	if s.touchWindow <= 0 {
+		s.touchWindow = SessionTouchWindow
+	}
+	s.touchWindow = min(s.touchWindow, SessionExpiry/2)
+	if s.adminTouchWindow <= 0 {
+		s.adminTouchWindow = AdminTouchWindow
+	}
+	s.adminTouchWindow = min(s.adminTouchWindow, s.adminIdleTimeout/2)
 	go s.cleanupLoop()
 	return s
 }
@@ -64,13 +177,14 @@ func (s *SessionStore) CreateSession(ip string, passwordID int64) (string, error
 		return "", err
 	}
 
-	ipHash := hashIP(ip, s.salt)
 	now := time.Now().UTC()
-
-	_, err = s.db.Exec(
-		"INSERT INTO sessions (id, started_at, last_seen_at, ip_hash, password_id) VALUES (?, ?, ?, ?, ?)",
-		id, now, now, ipHash, passwordID,
-	)
+	err = s.backend.CreateSession(ListenerSession{
+		ID:         id,
+		StartedAt:  now,
+		LastSeenAt: now,
+		IPHash:     s.ipHash(ip),
+		PasswordID: passwordID,
+	})
 	if err != nil {
 		return "", fmt.Errorf("create session: %w", err)
 	}
@@ -81,44 +195,36 @@ func (s *SessionStore) CreateSession(ip string, passwordID int64) (string, error
 // ValidateSession checks if a session ID is valid and not expired.
 // On success, it updates last_seen_at (sliding window) and returns the password_id.
 func (s *SessionStore) ValidateSession(id string) (bool, int64, error) {
-	var lastSeen time.Time
-	var passwordID sql.NullInt64
-	err := s.db.QueryRow(
-		"SELECT last_seen_at, password_id FROM sessions WHERE id = ?", id,
-	).Scan(&lastSeen, &passwordID)
-	if err == sql.ErrNoRows {
-		return false, 0, nil
-	}
+	sess, found, err := s.backend.GetSession(id)
 	if err != nil {
 		return false, 0, fmt.Errorf("query session: %w", err)
 	}
+	if !found {
+		return false, 0, nil
+	}
 
 	now := time.Now().UTC()
-	if now.Sub(lastSeen.UTC()) > SessionExpiry {
-		if _, err := s.db.Exec("DELETE FROM sessions WHERE id = ?", id); err != nil {
+	lastSeen := sess.LastSeenAt.UTC()
+	if now.Sub(lastSeen) > SessionExpiry {
+		if err := s.backend.DeleteSession(id); err != nil {
 			return false, 0, fmt.Errorf("delete expired session: %w", err)
 		}
 		return false, 0, nil
 	}
 
-	// Update sliding window at most once per minute to reduce write amplification.
-	if now.Sub(lastSeen.UTC()) >= SessionTouchWindow {
-		if _, err := s.db.Exec("UPDATE sessions SET last_seen_at = ? WHERE id = ?", now, id); err != nil {
+	// Update sliding window at most once per touch window to reduce write amplification.
+	if now.Sub(lastSeen) >= s.touchWindow {
+		if err := s.backend.TouchSession(id, now); err != nil {
 			return false, 0, fmt.Errorf("touch session: %w", err)
 		}
 	}
 
-	var pwID int64
-	if passwordID.Valid {
-		pwID = passwordID.Int64
-	}
-	return true, pwID, nil
+	return true, sess.PasswordID, nil
 }
 
 // DeleteSession removes a listener session.
 func (s *SessionStore) DeleteSession(id string) error {
-	_, err := s.db.Exec("DELETE FROM sessions WHERE id = ?", id)
-	return err
+	return s.backend.DeleteSession(id)
 }
 
 // CreateAdminSession generates a new admin session.
@@ -138,13 +244,14 @@ func (s *SessionStore) CreateAdminSessionWithContext(userID int64, ip, userAgent
 	}
 
 	now := time.Now().UTC()
-	ipHash := hashIP(strings.TrimSpace(ip), s.salt)
-	uaHash := hashIP(strings.TrimSpace(userAgent), s.salt)
-
-	_, err = s.db.Exec(
-		"INSERT INTO admin_sessions (id, created_at, last_seen_at, ip_hash, user_agent_hash, user_id) VALUES (?, ?, ?, ?, ?, ?)",
-		id, now, now, ipHash, uaHash, userID,
-	)
+	err = s.backend.CreateAdminSession(AdminSession{
+		ID:            id,
+		UserID:        userID,
+		CreatedAt:     now,
+		LastSeenAt:    now,
+		IPHash:        s.ipHash(strings.TrimSpace(ip)),
+		UserAgentHash: hashIP(strings.TrimSpace(userAgent), s.salt),
+	})
 	if err != nil {
 		return "", fmt.Errorf("create admin session: %w", err)
 	}
@@ -152,6 +259,42 @@ func (s *SessionStore) CreateAdminSessionWithContext(userID int64, ip, userAgent
 	return id, nil
 }
 
+// RecordAdminDevice notes the device an admin signed in from and reports
+// whether the user has not signed in from it before. Devices are keyed by
+// user agent only, so changing networks does not count as a new device; the
+// fingerprint is scoped to the user and survives restarts, unlike the
+// per-process salt used for session binding.
+func (s *SessionStore) RecordAdminDevice(userID int64, userAgent string) (bool, error) {
+	if userID <= 0 {
+		return false, fmt.Errorf("record admin device: invalid user id")
+	}
+
+	fingerprint := adminDeviceFingerprint(userID, strings.TrimSpace(userAgent))
+	now := time.Now().UTC()
+	res, err := s.db.Exec(
+		"INSERT OR IGNORE INTO admin_known_devices (user_id, fingerprint, first_seen_at, last_seen_at) VALUES (?, ?, ?, ?)",
+		userID, fingerprint, now, now,
+	)
+	if err != nil {
+		return false, fmt.Errorf("record admin device: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return true, nil
+	}
+	if _, err := s.db.Exec(
+		"UPDATE admin_known_devices SET last_seen_at = ? WHERE user_id = ? AND fingerprint = ?",
+		now, userID, fingerprint,
+	); err != nil {
+		return false, fmt.Errorf("record admin device: %w", err)
+	}
+	return false, nil
+}
+
+func adminDeviceFingerprint(userID int64, userAgent string) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("admin-device:%d:%s", userID, userAgent)))
+	return hex.EncodeToString(h[:])
+}
+
 // ValidateAdminSession checks if an admin session is valid (1 hour, no sliding).
 func (s *SessionStore) ValidateAdminSession(id string) (bool, error) {
 	valid, _, _, err := s.ValidateAdminSessionWithContext(id, "", "")
@@ -160,66 +303,94 @@ func (s *SessionStore) ValidateAdminSession(id string) (bool, error) {
 
 // ValidateAdminSessionWithContext checks if an admin session is valid and optionally verifies client fingerprints.
 func (s *SessionStore) ValidateAdminSessionWithContext(id, ip, userAgent string) (bool, int64, bool, error) {
-	var createdAt time.Time
-	var lastSeenAt sql.NullTime
-	var storedIPHash sql.NullString
-	var storedUAHash sql.NullString
-	var userID sql.NullInt64
-	var isActive sql.NullInt64
-	var requirePasswordReset sql.NullInt64
-	err := s.db.QueryRow(
-		"SELECT s.created_at, s.last_seen_at, s.ip_hash, s.user_agent_hash, s.user_id, u.is_active, u.require_password_reset FROM admin_sessions s LEFT JOIN admin_users u ON s.user_id = u.id WHERE s.id = ?",
-		id,
-	).Scan(&createdAt, &lastSeenAt, &storedIPHash, &storedUAHash, &userID, &isActive, &requirePasswordReset)
-	if err == sql.ErrNoRows {
-		return false, 0, false, nil
-	}
+	sess, found, err := s.backend.GetAdminSession(id)
 	if err != nil {
 		return false, 0, false, fmt.Errorf("query admin session: %w", err)
 	}
+	if !found {
+		return false, 0, false, nil
+	}
 
-	if !userID.Valid || userID.Int64 <= 0 || !isActive.Valid || isActive.Int64 != 1 {
-		_, _ = s.db.Exec("DELETE FROM admin_sessions WHERE id = ?", id)
+	var isActive, requirePasswordReset int64
+	err = s.db.QueryRow(
+		"SELECT is_active, require_password_reset FROM admin_users WHERE id = ?", sess.UserID,
+	).Scan(&isActive, &requirePasswordReset)
+	if err != nil && err != sql.ErrNoRows {
+		return false, 0, false, fmt.Errorf("query admin session user: %w", err)
+	}
+	if sess.UserID <= 0 || err == sql.ErrNoRows || isActive != 1 {
+		_ = s.backend.DeleteAdminSession(id)
 		return false, 0, false, nil
 	}
 
-	if time.Since(createdAt) > AdminSessionExpiry {
-		if _, err := s.db.Exec("DELETE FROM admin_sessions WHERE id = ?", id); err != nil {
+	if s.adminSessionExpired(sess) {
+		if err := s.backend.DeleteAdminSession(id); err != nil {
 			return false, 0, false, fmt.Errorf("delete expired admin session: %w", err)
 		}
 		return false, 0, false, nil
 	}
 
-	if strings.TrimSpace(ip) != "" && storedIPHash.Valid && storedIPHash.String != "" {
+	bindIP := s.binding == FingerprintStrict && !s.anonymous
+	bindUA := s.binding != FingerprintOff
+
+	if bindIP && strings.TrimSpace(ip) != "" && sess.IPHash != "" {
 		reqIPHash := hashIP(strings.TrimSpace(ip), s.salt)
-		if !secureHashEqual(storedIPHash.String, reqIPHash) {
-			_, _ = s.db.Exec("DELETE FROM admin_sessions WHERE id = ?", id)
+		if !secureHashEqual(sess.IPHash, reqIPHash) {
+			_ = s.backend.DeleteAdminSession(id)
 			return false, 0, false, nil
 		}
 	}
 
-	if strings.TrimSpace(userAgent) != "" && storedUAHash.Valid && storedUAHash.String != "" {
+	if bindUA && strings.TrimSpace(userAgent) != "" && sess.UserAgentHash != "" {
 		reqUAHash := hashIP(strings.TrimSpace(userAgent), s.salt)
-		if !secureHashEqual(storedUAHash.String, reqUAHash) {
-			_, _ = s.db.Exec("DELETE FROM admin_sessions WHERE id = ?", id)
+		if !secureHashEqual(sess.UserAgentHash, reqUAHash) {
+			_ = s.backend.DeleteAdminSession(id)
 			return false, 0, false, nil
 		}
 	}
 
-	if !lastSeenAt.Valid || time.Since(lastSeenAt.Time.UTC()) >= AdminTouchWindow {
-		if _, err := s.db.Exec("UPDATE admin_sessions SET last_seen_at = ? WHERE id = ?", time.Now().UTC(), id); err != nil {
+	if sess.LastSeenAt.IsZero() || time.Since(sess.LastSeenAt.UTC()) >= s.adminTouchWindow {
+		if err := s.backend.TouchAdminSession(id, time.Now().UTC()); err != nil {
 			return false, 0, false, fmt.Errorf("touch admin session: %w", err)
 		}
 	}
 
-	needsReset := requirePasswordReset.Valid && requirePasswordReset.Int64 == 1
-	return true, userID.Int64, needsReset, nil
+	return true, sess.UserID, requirePasswordReset == 1, nil
+}
+
+// adminSessionExpired applies the hard cap, or in sliding mode the idle
+// timeout and absolute lifetime.
+func (s *SessionStore) adminSessionExpired(sess AdminSession) bool {
+	if !s.adminSliding {
+		return time.Since(sess.CreatedAt) > AdminSessionExpiry
+	}
+	if time.Since(sess.CreatedAt) > s.adminMaxLifetime {
+		return true
+	}
+	return time.Since(sess.lastActive()) > s.adminIdleTimeout
+}
+
+// AdminCookieMaxAge is how long browsers should keep the admin session
+// cookie: the hard cap, or the absolute lifetime in sliding mode.
+func (s *SessionStore) AdminCookieMaxAge() int {
+	if s.adminSliding {
+		return int(s.adminMaxLifetime.Seconds())
+	}
+	return int(AdminSessionExpiry.Seconds())
+}
+
+// adminCreatedCutoff is the oldest created_at an admin session can have and
+// still be valid.
+func (s *SessionStore) adminCreatedCutoff(now time.Time) time.Time {
+	if s.adminSliding {
+		return now.Add(-s.adminMaxLifetime)
+	}
+	return now.Add(-AdminSessionExpiry)
 }
 
 // DeleteAdminSession removes an admin session.
 func (s *SessionStore) DeleteAdminSession(id string) error {
-	_, err := s.db.Exec("DELETE FROM admin_sessions WHERE id = ?", id)
-	return err
+	return s.backend.DeleteAdminSession(id)
 }
 
 // DeleteAdminSessionsForUser revokes all sessions for a specific admin user.
@@ -227,8 +398,86 @@ func (s *SessionStore) DeleteAdminSessionsForUser(userID int64) error {
 	if userID <= 0 {
 		return nil
 	}
-	_, err := s.db.Exec("DELETE FROM admin_sessions WHERE user_id = ?", userID)
-	return err
+	return s.backend.DeleteAdminSessionsForUser(userID)
+}
+
+// CountSessions reports how many listener and admin sessions are stored,
+// including expired ones not yet cleaned up.
+func (s *SessionStore) CountSessions() (listener, admin int64, err error) {
+	return s.backend.CountSessions()
+}
+
+// AdminSessionInfo describes an admin session without exposing its secret ID.
+type AdminSessionInfo struct {
+	Handle        string `json:"id"`
+	CreatedAt     string `json:"created_at"`
+	LastSeenAt    string `json:"last_seen_at,omitempty"`
+	IPHash        string `json:"ip_hash,omitempty"`
+	UserAgentHash string `json:"user_agent_hash,omitempty"`
+}
+
+// AdminSessionHandle derives the public identifier for an admin session.
+// The session ID itself is a bearer secret and is never listed.
+func AdminSessionHandle(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ListAdminSessions returns a user's unexpired admin sessions, newest first,
+// with fingerprint hashes truncated.
+func (s *SessionStore) ListAdminSessions(userID int64) ([]AdminSessionInfo, error) {
+	stored, err := s.backend.ListAdminSessions(userID)
+	if err != nil {
+		return nil, fmt.Errorf("query admin sessions: %w", err)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].CreatedAt.After(stored[j].CreatedAt) })
+
+	sessions := make([]AdminSessionInfo, 0, len(stored))
+	for _, sess := range stored {
+		if s.adminSessionExpired(sess) {
+			continue
+		}
+		info := AdminSessionInfo{
+			Handle:        AdminSessionHandle(sess.ID),
+			CreatedAt:     sess.CreatedAt.UTC().Format(time.RFC3339),
+			IPHash:        truncateHash(sess.IPHash),
+			UserAgentHash: truncateHash(sess.UserAgentHash),
+		}
+		if !sess.LastSeenAt.IsZero() {
+			info.LastSeenAt = sess.LastSeenAt.UTC().Format(time.RFC3339)
+		}
+		sessions = append(sessions, info)
+	}
+	return sessions, nil
+}
+
+// DeleteAdminSessionByHandle revokes one of a user's admin sessions by its
+// public handle. It reports whether a session was removed.
+func (s *SessionStore) DeleteAdminSessionByHandle(userID int64, handle string) (bool, error) {
+	stored, err := s.backend.ListAdminSessions(userID)
+	if err != nil {
+		return false, fmt.Errorf("query admin sessions: %w", err)
+	}
+	var match string
+	for _, sess := range stored {
+		if secureHashEqual(AdminSessionHandle(sess.ID), handle) {
+			match = sess.ID
+		}
+	}
+	if match == "" {
+		return false, nil
+	}
+	if err := s.DeleteAdminSession(match); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func truncateHash(h string) string {
+	if len(h) > 12 {
+		return h[:12] + "..."
+	}
+	return h
 }
 
 // VerifyPassphrase compares a plaintext passphrase against either a bcrypt hash
@@ -268,14 +517,20 @@ func (s *SessionStore) cleanupLoop() {
 
 func (s *SessionStore) cleanup() {
 	cutoff := time.Now().UTC().Add(-SessionExpiry)
-	if _, err := s.db.Exec("DELETE FROM sessions WHERE last_seen_at < ?", cutoff); err != nil {
+	if err := s.backend.DeleteSessionsSeenBefore(cutoff); err != nil {
 		log.Printf("session cleanup error: %v", err)
 	}
 
-	adminCutoff := time.Now().UTC().Add(-AdminSessionExpiry)
-	if _, err := s.db.Exec("DELETE FROM admin_sessions WHERE created_at < ?", adminCutoff); err != nil {
+	now := time.Now().UTC()
+	if err := s.backend.DeleteAdminSessionsCreatedBefore(s.adminCreatedCutoff(now)); err != nil {
 		log.Printf("admin session cleanup error: %v", err)
 	}
+	if s.adminSliding {
+		idleCutoff := now.Add(-s.adminIdleTimeout)
+		if err := s.backend.DeleteAdminSessionsIdleBefore(idleCutoff); err != nil {
+			log.Printf("admin session idle cleanup error: %v", err)
+		}
+	}
 }
 
 func generateSessionID() (string, error) {
@@ -286,6 +541,20 @@ func generateSessionID() (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
+// Anonymous reports whether the store keeps no IP-derived data.
+func (s *SessionStore) Anonymous() bool {
+	return s.anonymous
+}
+
+// ipHash returns the value stored for a client IP: its salted hash, or
+// empty (SQL NULL) in anonymous mode, where the IP is never hashed at all.
+func (s *SessionStore) ipHash(ip string) string {
+	if s.anonymous {
+		return ""
+	}
+	return hashIP(ip, s.salt)
+}
+
 func hashIP(ip, salt string) string {
 	h := sha256.Sum256([]byte(salt + ip))
 	return hex.EncodeToString(h[:])
@@ -1,6 +1,13 @@
 package auth
 
 import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -23,6 +30,19 @@ func testDB(t *testing.T) *SessionStore {
 	return store
 }
 
+// forEachBackend runs a session test against every SessionBackend.
+func forEachBackend(t *testing.T, test func(t *testing.T, store *SessionStore)) {
+	t.Run("sqlite", func(t *testing.T) {
+		test(t, testDB(t))
+	})
+	t.Run("memory", func(t *testing.T) {
+		base := testDB(t)
+		store := NewSessionStoreWithOptions(base.db, SessionStoreOptions{Backend: NewMemorySessionBackend()})
+		t.Cleanup(func() { store.Close() })
+		test(t, store)
+	})
+}
+
 func seedAdminUser(t *testing.T, store *SessionStore) int64 {
 	t.Helper()
 
@@ -45,87 +65,117 @@ func seedAdminUser(t *testing.T, store *SessionStore) int64 {
 }
 
 func TestCreateAndValidateSession(t *testing.T) {
-	store := testDB(t)
-
-	id, err := store.CreateSession("127.0.0.1", 0)
-	if err != nil {
-		t.Fatalf("CreateSession: %v", err)
-	}
-
-	valid, _, err := store.ValidateSession(id)
-	if err != nil {
-		t.Fatalf("ValidateSession: %v", err)
-	}
-	if !valid {
-		t.Error("session should be valid")
-	}
+	forEachBackend(t, func(t *testing.T, store *SessionStore) {
+		id, err := store.CreateSession("127.0.0.1", 0)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+
+		valid, _, err := store.ValidateSession(id)
+		if err != nil {
+			t.Fatalf("ValidateSession: %v", err)
+		}
+		if !valid {
+			t.Error("session should be valid")
+		}
+	})
 }
 
 func TestDeleteSession(t *testing.T) {
-	store := testDB(t)
-
-	id, err := store.CreateSession("127.0.0.1", 0)
-	if err != nil {
-		t.Fatalf("CreateSession: %v", err)
-	}
-
-	if err := store.DeleteSession(id); err != nil {
-		t.Fatalf("DeleteSession: %v", err)
-	}
-
-	valid, _, err := store.ValidateSession(id)
-	if err != nil {
-		t.Fatalf("ValidateSession: %v", err)
-	}
-	if valid {
-		t.Error("session should be invalid after delete")
-	}
+	forEachBackend(t, func(t *testing.T, store *SessionStore) {
+		id, err := store.CreateSession("127.0.0.1", 0)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+
+		if err := store.DeleteSession(id); err != nil {
+			t.Fatalf("DeleteSession: %v", err)
+		}
+
+		valid, _, err := store.ValidateSession(id)
+		if err != nil {
+			t.Fatalf("ValidateSession: %v", err)
+		}
+		if valid {
+			t.Error("session should be invalid after delete")
+		}
+	})
 }
 
 func TestInvalidSession(t *testing.T) {
-	store := testDB(t)
-
-	valid, _, err := store.ValidateSession("nonexistent")
-	if err != nil {
-		t.Fatalf("ValidateSession: %v", err)
-	}
-	if valid {
-		t.Error("nonexistent session should be invalid")
-	}
+	forEachBackend(t, func(t *testing.T, store *SessionStore) {
+		valid, _, err := store.ValidateSession("nonexistent")
+		if err != nil {
+			t.Fatalf("ValidateSession: %v", err)
+		}
+		if valid {
+			t.Error("nonexistent session should be invalid")
+		}
+	})
 }
 
 func TestAdminSession(t *testing.T) {
-	store := testDB(t)
-	adminUserID := seedAdminUser(t, store)
-
-	id, err := store.CreateAdminSessionWithContext(adminUserID, "127.0.0.1", "test-agent")
-	if err != nil {
-		t.Fatalf("CreateAdminSessionWithContext: %v", err)
-	}
-
-	valid, err := store.ValidateAdminSession(id)
-	if err != nil {
-		t.Fatalf("ValidateAdminSession: %v", err)
-	}
-	if !valid {
-		t.Error("admin session should be valid")
-	}
-
-	if err := store.DeleteAdminSession(id); err != nil {
-		t.Fatalf("DeleteAdminSession: %v", err)
-	}
-
-	valid, err = store.ValidateAdminSession(id)
-	if err != nil {
-		t.Fatalf("ValidateAdminSession after delete: %v", err)
-	}
-	if valid {
-		t.Error("admin session should be invalid after delete")
-	}
+	forEachBackend(t, func(t *testing.T, store *SessionStore) {
+		adminUserID := seedAdminUser(t, store)
+
+		id, err := store.CreateAdminSessionWithContext(adminUserID, "127.0.0.1", "test-agent")
+		if err != nil {
+			t.Fatalf("CreateAdminSessionWithContext: %v", err)
+		}
+
+		valid, err := store.ValidateAdminSession(id)
+		if err != nil {
+			t.Fatalf("ValidateAdminSession: %v", err)
+		}
+		if !valid {
+			t.Error("admin session should be valid")
+		}
+
+		if err := store.DeleteAdminSession(id); err != nil {
+			t.Fatalf("DeleteAdminSession: %v", err)
+		}
+
+		valid, err = store.ValidateAdminSession(id)
+		if err != nil {
+			t.Fatalf("ValidateAdminSession after delete: %v", err)
+		}
+		if valid {
+			t.Error("admin session should be invalid after delete")
+		}
+	})
 }
 
 func TestAdminSessionFingerprintBinding(t *testing.T) {
-	store := testDB(t)
+	forEachBackend(t, func(t *testing.T, store *SessionStore) {
+		adminUserID := seedAdminUser(t, store)
+
+		id, err := store.CreateAdminSessionWithContext(adminUserID, "127.0.0.1", "test-agent")
+		if err != nil {
+			t.Fatalf("CreateAdminSessionWithContext: %v", err)
+		}
+
+		valid, _, _, err := store.ValidateAdminSessionWithContext(id, "127.0.0.1", "test-agent")
+		if err != nil {
+			t.Fatalf("ValidateAdminSessionWithContext: %v", err)
+		}
+		if !valid {
+			t.Fatal("session should validate for same fingerprint")
+		}
+
+		valid, _, _, err = store.ValidateAdminSessionWithContext(id, "127.0.0.2", "test-agent")
+		if err != nil {
+			t.Fatalf("ValidateAdminSessionWithContext mismatch: %v", err)
+		}
+		if valid {
+			t.Fatal("session should be invalid for mismatched fingerprint")
+		}
+	})
+}
+
+func TestAdminSessionFingerprintBindingUAOnly(t *testing.T) {
+	base := testDB(t)
+	store := NewSessionStoreWithOptions(base.db, SessionStoreOptions{AdminFingerprint: FingerprintUAOnly})
+	t.Cleanup(func() { store.Close() })
 	adminUserID := seedAdminUser(t, store)
 
 	id, err := store.CreateAdminSessionWithContext(adminUserID, "127.0.0.1", "test-agent")
@@ -133,20 +183,37 @@ func TestAdminSessionFingerprintBinding(t *testing.T) {
 		t.Fatalf("CreateAdminSessionWithContext: %v", err)
 	}
 
-	valid, _, _, err := store.ValidateAdminSessionWithContext(id, "127.0.0.1", "test-agent")
+	valid, _, _, err := store.ValidateAdminSessionWithContext(id, "10.1.2.3", "test-agent")
 	if err != nil {
-		t.Fatalf("ValidateAdminSessionWithContext: %v", err)
+		t.Fatalf("ValidateAdminSessionWithContext new ip: %v", err)
 	}
 	if !valid {
-		t.Fatal("session should validate for same fingerprint")
+		t.Fatal("ua_only session should tolerate an IP change")
 	}
 
-	valid, _, _, err = store.ValidateAdminSessionWithContext(id, "127.0.0.2", "test-agent")
+	valid, _, _, err = store.ValidateAdminSessionWithContext(id, "10.1.2.3", "other-agent")
 	if err != nil {
-		t.Fatalf("ValidateAdminSessionWithContext mismatch: %v", err)
+		t.Fatalf("ValidateAdminSessionWithContext new ua: %v", err)
 	}
 	if valid {
-		t.Fatal("session should be invalid for mismatched fingerprint")
+		t.Fatal("ua_only session should be invalid for a different user agent")
+	}
+}
+
+func TestParseFingerprintBinding(t *testing.T) {
+	for input, want := range map[string]FingerprintBinding{
+		"":          FingerprintStrict,
+		"strict":    FingerprintStrict,
+		" UA_ONLY ": FingerprintUAOnly,
+		"off":       FingerprintOff,
+	} {
+		got, err := ParseFingerprintBinding(input)
+		if err != nil || got != want {
+			t.Errorf("ParseFingerprintBinding(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if got, err := ParseFingerprintBinding("loose"); err == nil || got != FingerprintStrict {
+		t.Errorf("ParseFingerprintBinding(loose) = %q, %v; want strict with error", got, err)
 	}
 }
 
@@ -169,21 +236,21 @@ func TestVerifyPassphrase(t *testing.T) {
 }
 
 func TestSessionRotation(t *testing.T) {
-	store := testDB(t)
-
-	id1, _ := store.CreateSession("127.0.0.1", 0)
-	id2, _ := store.CreateSession("127.0.0.1", 0)
-
-	if id1 == id2 {
-		t.Error("session IDs should be unique (rotation)")
-	}
-
-	// Both sessions should be valid
-	v1, _, _ := store.ValidateSession(id1)
-	v2, _, _ := store.ValidateSession(id2)
-	if !v1 || !v2 {
-		t.Error("both sessions should be valid")
-	}
+	forEachBackend(t, func(t *testing.T, store *SessionStore) {
+		id1, _ := store.CreateSession("127.0.0.1", 0)
+		id2, _ := store.CreateSession("127.0.0.1", 0)
+
+		if id1 == id2 {
+			t.Error("session IDs should be unique (rotation)")
+		}
+
+		// Both sessions should be valid
+		v1, _, _ := store.ValidateSession(id1)
+		v2, _, _ := store.ValidateSession(id2)
+		if !v1 || !v2 {
+			t.Error("both sessions should be valid")
+		}
+	})
 }
 
 func TestIPHashing(t *testing.T) {
@@ -216,3 +283,286 @@ func TestCleanup(t *testing.T) {
 		t.Error("expired session should have been cleaned up")
 	}
 }
+
+func TestSessionBackendLifecycle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store *SessionStore) {
+		adminUserID := seedAdminUser(t, store)
+
+		expired := time.Now().UTC().Add(-8 * 24 * time.Hour)
+		if err := store.backend.CreateSession(ListenerSession{ID: "expired-session", StartedAt: expired, LastSeenAt: expired}); err != nil {
+			t.Fatalf("seed expired session: %v", err)
+		}
+		live, err := store.CreateSession("127.0.0.1", 7)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		first, err := store.CreateAdminSessionWithContext(adminUserID, "127.0.0.1", "test-agent")
+		if err != nil {
+			t.Fatalf("CreateAdminSessionWithContext: %v", err)
+		}
+		second, err := store.CreateAdminSessionWithContext(adminUserID, "127.0.0.1", "test-agent")
+		if err != nil {
+			t.Fatalf("CreateAdminSessionWithContext: %v", err)
+		}
+
+		if listener, admin, err := store.CountSessions(); err != nil || listener != 2 || admin != 2 {
+			t.Fatalf("CountSessions = %d, %d, %v; want 2, 2", listener, admin, err)
+		}
+		store.cleanup()
+		if listener, _, err := store.CountSessions(); err != nil || listener != 1 {
+			t.Fatalf("listener sessions after cleanup = %d, %v; want 1", listener, err)
+		}
+		if valid, passwordID, err := store.ValidateSession(live); err != nil || !valid || passwordID != 7 {
+			t.Fatalf("ValidateSession = %v, %d, %v; want valid with password 7", valid, passwordID, err)
+		}
+
+		sessions, err := store.ListAdminSessions(adminUserID)
+		if err != nil || len(sessions) != 2 {
+			t.Fatalf("ListAdminSessions = %+v, %v; want 2 sessions", sessions, err)
+		}
+		removed, err := store.DeleteAdminSessionByHandle(adminUserID, AdminSessionHandle(first))
+		if err != nil || !removed {
+			t.Fatalf("DeleteAdminSessionByHandle = %v, %v", removed, err)
+		}
+		if valid, _ := store.ValidateAdminSession(first); valid {
+			t.Fatal("revoked admin session should be invalid")
+		}
+
+		// Deactivating the user invalidates sessions still in the backend.
+		if _, err := store.db.Exec("UPDATE admin_users SET is_active = 0 WHERE id = ?", adminUserID); err != nil {
+			t.Fatalf("deactivate admin: %v", err)
+		}
+		if valid, _ := store.ValidateAdminSession(second); valid {
+			t.Fatal("session of a deactivated admin should be invalid")
+		}
+		if _, admin, err := store.CountSessions(); err != nil || admin != 0 {
+			t.Fatalf("admin sessions = %d, %v; want 0", admin, err)
+		}
+	})
+}
+
+func TestSessionTouchWindowIsConfigurable(t *testing.T) {
+	defaults := testDB(t)
+	wide := NewSessionStoreWithOptions(defaults.db, SessionStoreOptions{
+		TouchWindow:      time.Hour,
+		AdminTouchWindow: 20 * time.Minute,
+	})
+	t.Cleanup(func() { wide.Close() })
+	adminUserID := seedAdminUser(t, defaults)
+
+	// touched reports whether validating a session last seen ago rewrote
+	// its last_seen_at.
+	touched := func(table string, ago time.Duration, validate func(id string) bool, id string) bool {
+		t.Helper()
+		before := time.Now().UTC().Add(-ago).Truncate(time.Second)
+		if _, err := defaults.db.Exec("UPDATE "+table+" SET last_seen_at = ? WHERE id = ?", before, id); err != nil {
+			t.Fatalf("age session: %v", err)
+		}
+		if !validate(id) {
+			t.Fatalf("%s session %s unexpectedly invalid", table, id)
+		}
+		var after time.Time
+		if err := defaults.db.QueryRow("SELECT last_seen_at FROM "+table+" WHERE id = ?", id).Scan(&after); err != nil {
+			t.Fatalf("read last_seen_at: %v", err)
+		}
+		return !after.Equal(before)
+	}
+	listener := func(store *SessionStore) (func(string) bool, string) {
+		id, err := store.CreateSession("127.0.0.1", 0)
+		if err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		return func(id string) bool {
+			valid, _, err := store.ValidateSession(id)
+			if err != nil {
+				t.Fatalf("ValidateSession: %v", err)
+			}
+			return valid
+		}, id
+	}
+	admin := func(store *SessionStore) (func(string) bool, string) {
+		id, err := store.CreateAdminSessionWithContext(adminUserID, "127.0.0.1", "test-agent")
+		if err != nil {
+			t.Fatalf("CreateAdminSessionWithContext: %v", err)
+		}
+		return func(id string) bool {
+			valid, err := store.ValidateAdminSession(id)
+			if err != nil {
+				t.Fatalf("ValidateAdminSession: %v", err)
+			}
+			return valid
+		}, id
+	}
+
+	validate, id := listener(defaults)
+	if !touched("sessions", 10*time.Minute, validate, id) {
+		t.Fatal("default listener window should touch a session seen 10m ago")
+	}
+	validate, id = listener(wide)
+	if touched("sessions", 10*time.Minute, validate, id) {
+		t.Fatal("1h listener window should not touch a session seen 10m ago")
+	}
+	if !touched("sessions", 2*time.Hour, validate, id) {
+		t.Fatal("1h listener window should touch a session seen 2h ago")
+	}
+
+	validate, id = admin(defaults)
+	if !touched("admin_sessions", 10*time.Minute, validate, id) {
+		t.Fatal("default admin window should touch a session seen 10m ago")
+	}
+	validate, id = admin(wide)
+	if touched("admin_sessions", 10*time.Minute, validate, id) {
+		t.Fatal("20m admin window should not touch a session seen 10m ago")
+	}
+
+	// Windows are capped at half the idle timeout.
+	capped := NewSessionStoreWithOptions(defaults.db, SessionStoreOptions{AdminTouchWindow: 24 * time.Hour})
+	t.Cleanup(func() { capped.Close() })
+	validate, id = admin(capped)
+	if !touched("admin_sessions", 40*time.Minute, validate, id) {
+		t.Fatal("admin window should be capped at half the 1h idle timeout")
+	}
+}
+
+func TestAdminSessionSlidingExpiry(t *testing.T) {
+	hardCap := testDB(t)
+	sliding := NewSessionStoreWithOptions(hardCap.db, SessionStoreOptions{
+		AdminSliding:     true,
+		AdminIdleTimeout: time.Hour,
+		AdminMaxLifetime: 3 * time.Hour,
+	})
+	t.Cleanup(func() { sliding.Close() })
+	adminUserID := seedAdminUser(t, hardCap)
+
+	// age backdates a session: created createdAgo and last active lastSeenAgo.
+	age := func(id string, createdAgo, lastSeenAgo time.Duration) {
+		t.Helper()
+		now := time.Now().UTC()
+		if _, err := hardCap.db.Exec(
+			"UPDATE admin_sessions SET created_at = ?, last_seen_at = ? WHERE id = ?",
+			now.Add(-createdAgo), now.Add(-lastSeenAgo), id,
+		); err != nil {
+			t.Fatalf("age session: %v", err)
+		}
+	}
+	check := func(store *SessionStore, createdAgo, lastSeenAgo time.Duration, want bool) {
+		t.Helper()
+		id, err := store.CreateAdminSessionWithContext(adminUserID, "127.0.0.1", "test-agent")
+		if err != nil {
+			t.Fatalf("CreateAdminSessionWithContext: %v", err)
+		}
+		age(id, createdAgo, lastSeenAgo)
+		valid, err := store.ValidateAdminSession(id)
+		if err != nil {
+			t.Fatalf("ValidateAdminSession: %v", err)
+		}
+		if valid != want {
+			t.Fatalf("created %s ago, seen %s ago: valid = %v, want %v", createdAgo, lastSeenAgo, valid, want)
+		}
+	}
+
+	// Recent activity keeps a sliding session alive past the hard cap...
+	check(sliding, 2*time.Hour, 10*time.Minute, true)
+	check(hardCap, 2*time.Hour, 10*time.Minute, false)
+	// ...but not once it goes idle or passes the absolute lifetime.
+	check(sliding, 2*time.Hour, 90*time.Minute, false)
+	check(sliding, 4*time.Hour, time.Minute, false)
+
+	// Validation records activity, which is what extends the session.
+	id, err := sliding.CreateAdminSessionWithContext(adminUserID, "127.0.0.1", "test-agent")
+	if err != nil {
+		t.Fatalf("CreateAdminSessionWithContext: %v", err)
+	}
+	age(id, 2*time.Hour, 50*time.Minute)
+	if valid, err := sliding.ValidateAdminSession(id); err != nil || !valid {
+		t.Fatalf("active session valid = %v, err = %v", valid, err)
+	}
+	var lastSeen time.Time
+	if err := hardCap.db.QueryRow("SELECT last_seen_at FROM admin_sessions WHERE id = ?", id).Scan(&lastSeen); err != nil {
+		t.Fatalf("read last_seen_at: %v", err)
+	}
+	if time.Since(lastSeen) > time.Minute {
+		t.Fatalf("last_seen_at not refreshed: %s ago", time.Since(lastSeen))
+	}
+
+	if got := sliding.AdminCookieMaxAge(); got != int((3 * time.Hour).Seconds()) {
+		t.Fatalf("sliding cookie max age = %d", got)
+	}
+	if got := hardCap.AdminCookieMaxAge(); got != 3600 {
+		t.Fatalf("hard-cap cookie max age = %d", got)
+	}
+}
+
+func TestListAdminSessionsSkipsIdleSlidingSessions(t *testing.T) {
+	env := testDB(t)
+	sliding := NewSessionStoreWithOptions(env.db, SessionStoreOptions{
+		AdminSliding:     true,
+		AdminIdleTimeout: time.Hour,
+		AdminMaxLifetime: 3 * time.Hour,
+	})
+	t.Cleanup(func() { sliding.Close() })
+	adminUserID := seedAdminUser(t, env)
+
+	create := func(createdAgo, lastSeenAgo time.Duration) string {
+		t.Helper()
+		id, err := sliding.CreateAdminSessionWithContext(adminUserID, "127.0.0.1", "test-agent")
+		if err != nil {
+			t.Fatalf("CreateAdminSessionWithContext: %v", err)
+		}
+		now := time.Now().UTC()
+		if _, err := env.db.Exec(
+			"UPDATE admin_sessions SET created_at = ?, last_seen_at = ? WHERE id = ?",
+			now.Add(-createdAgo), now.Add(-lastSeenAgo), id,
+		); err != nil {
+			t.Fatalf("age session: %v", err)
+		}
+		return id
+	}
+	active := create(2*time.Hour, 10*time.Minute)
+	create(2*time.Hour, 90*time.Minute) // idle past the timeout
+	create(4*time.Hour, time.Minute)    // past the absolute lifetime
+
+	sessions, err := sliding.ListAdminSessions(adminUserID)
+	if err != nil {
+		t.Fatalf("ListAdminSessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Handle != AdminSessionHandle(active) {
+		t.Fatalf("ListAdminSessions = %+v, want only the active session", sessions)
+	}
+}
+
+func TestPwnedCheckerRangeQuery(t *testing.T) {
+	const breached = "hunter2hunter2"
+	sum := sha1.Sum([]byte(breached))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	var gotPaths []string
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPaths = append(gotPaths, r.URL.Path)
+		fmt.Fprintf(w, "0018A45C4D1DEF81644B54AB7F969B88D65:0\r\n")
+		if r.URL.Path == "/range/"+digest[:5] {
+			fmt.Fprintf(w, "%s:42\r\n", digest[5:])
+		}
+	}))
+	defer mock.Close()
+
+	checker := NewPwnedChecker(mock.URL + "/range/")
+	found, err := checker.Breached(context.Background(), breached)
+	if err != nil || !found {
+		t.Fatalf("Breached(match) = %v, %v; want true", found, err)
+	}
+	found, err = checker.Breached(context.Background(), "a-much-less-common-passphrase-9")
+	if err != nil || found {
+		t.Fatalf("Breached(non-match) = %v, %v; want false", found, err)
+	}
+	for _, p := range gotPaths {
+		if len(strings.TrimPrefix(p, "/range/")) != 5 {
+			t.Fatalf("range request path %q should carry only a 5-character prefix", p)
+		}
+	}
+
+	mock.Close()
+	if _, err := checker.Breached(context.Background(), breached); err == nil {
+		t.Fatal("Breached should report an error when the service is unreachable")
+	}
+}
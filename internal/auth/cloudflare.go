@@ -2,30 +2,58 @@ package auth
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	cfRefreshInterval = 24 * time.Hour
+	// cfRetryInterval is used instead while no ranges have loaded yet.
+	cfRetryInterval = time.Minute
+)
+
 var cfIPURLs = []string{
 	"https://www.cloudflare.com/ips-v4/",
 	"https://www.cloudflare.com/ips-v6/",
 }
 
+// DefaultClientIPHeader is the header trusted peers use to pass the
+// original client address.
+const DefaultClientIPHeader = "CF-Connecting-IP"
+
 // CloudflareIPs holds the known Cloudflare IP ranges for trusted header extraction.
 type CloudflareIPs struct {
-	mu   sync.RWMutex
-	nets []*net.IPNet
-	done chan struct{}
-	once sync.Once
+	mu      sync.RWMutex
+	nets    []*net.IPNet
+	lastErr string
+	header  string       // client IP header honoured from trusted peers
+	proxies []*net.IPNet // TRUSTED_PROXY_CIDRS; may supply any header
+	v6Bits  int          // IPv6 keying prefix length; zero keys on the full address
+	urls    []string     // range lists to fetch; empty means cfIPURLs
+	ready   atomic.Bool
+	done    chan struct{}
+	once    sync.Once
 }
 
 // NewCloudflareIPs fetches Cloudflare IP ranges and starts a refresh goroutine.
+// The initial fetch is synchronous; if it fails the instance starts not ready
+// and retries every cfRetryInterval until ranges load.
 func NewCloudflareIPs() *CloudflareIPs {
-	cf := &CloudflareIPs{done: make(chan struct{})}
+	return NewCloudflareIPsFromURLs(nil)
+}
+
+// NewCloudflareIPsFromURLs is NewCloudflareIPs fetching ranges from urls
+// instead of Cloudflare's published lists. Empty urls means the published
+// lists.
+func NewCloudflareIPsFromURLs(urls []string) *CloudflareIPs {
+	cf := &CloudflareIPs{done: make(chan struct{}), header: DefaultClientIPHeader, urls: urls}
 	cf.refresh()
 	go cf.refreshLoop()
 	return cf
@@ -38,6 +66,91 @@ func (cf *CloudflareIPs) Close() {
 	})
 }
 
+// SetClientIPHeader changes the header read from trusted peers, e.g.
+// True-Client-IP or X-Real-IP behind another CDN. An empty name restores
+// DefaultClientIPHeader.
+func (cf *CloudflareIPs) SetClientIPHeader(name string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = DefaultClientIPHeader
+	}
+	cf.mu.Lock()
+	cf.header = name
+	cf.mu.Unlock()
+}
+
+// SetTrustedProxies sets the reverse proxies allowed to supply the client IP
+// header, whatever it is named. Cloudflare addresses are only trusted for
+// CF-Connecting-IP, which Cloudflare always overwrites; headers such as
+// X-Real-IP pass through Cloudflare as the client sent them.
+func (cf *CloudflareIPs) SetTrustedProxies(nets []*net.IPNet) {
+	cf.mu.Lock()
+	cf.proxies = nets
+	cf.mu.Unlock()
+}
+
+// ParseTrustedProxies parses a comma-separated list of CIDRs or bare IP
+// addresses, as in TRUSTED_PROXY_CIDRS. Entries that do not parse are
+// returned in invalid.
+func ParseTrustedProxies(list string) (nets []*net.IPNet, invalid []string) {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, n, err := net.ParseCIDR(entry); err == nil {
+			nets = append(nets, n)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			invalid = append(invalid, entry)
+			continue
+		}
+		bits := 128
+		if v4 := ip.To4(); v4 != nil {
+			ip, bits = v4, 32
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, invalid
+}
+
+// SetIPv6Prefix makes GetClientIP key IPv6 clients by their enclosing
+// network of the given prefix length (64 groups a typical home or mobile
+// allocation), so a client rotating addresses within it shares one rate
+// limit and session hash. IPv4 clients are always keyed by the full
+// address. Zero, or a length outside 1-128, keys on the full address.
+func (cf *CloudflareIPs) SetIPv6Prefix(bits int) {
+	if bits < 0 || bits > 128 {
+		bits = 0
+	}
+	cf.mu.Lock()
+	cf.v6Bits = bits
+	cf.mu.Unlock()
+}
+
+// ClientIPHeader returns the header read from trusted peers.
+func (cf *CloudflareIPs) ClientIPHeader() string {
+	cf.mu.RLock()
+	defer cf.mu.RUnlock()
+	return cf.header
+}
+
+// Ready reports whether Cloudflare ranges have loaded at least once. Until
+// then CF-Connecting-IP is ignored and clients are identified by RemoteAddr.
+func (cf *CloudflareIPs) Ready() bool {
+	return cf.ready.Load()
+}
+
+// Status returns readiness, the number of loaded ranges, and the most recent
+// refresh error (empty after a successful refresh).
+func (cf *CloudflareIPs) Status() (bool, int, string) {
+	cf.mu.RLock()
+	defer cf.mu.RUnlock()
+	return cf.ready.Load(), len(cf.nets), cf.lastErr
+}
+
 // IsTrusted checks if the given IP is a known Cloudflare IP.
 func (cf *CloudflareIPs) IsTrusted(ipStr string) bool {
 	ip := net.ParseIP(ipStr)
@@ -57,21 +170,93 @@ func (cf *CloudflareIPs) IsTrusted(ipStr string) bool {
 }
 
 // GetClientIP extracts the real client IP from a request.
-// If the request comes from a trusted Cloudflare IP, use CF-Connecting-IP.
-// Otherwise, fall back to RemoteAddr.
+// If the request comes from a peer trusted for the client IP header, use
+// that header; otherwise fall back to RemoteAddr. Trusted proxies may supply
+// any header, Cloudflare addresses only CF-Connecting-IP.
 func (cf *CloudflareIPs) GetClientIP(r *http.Request) string {
+	return cf.ResolveClientIP(r).Key
+}
+
+// ClientIPResolution describes how GetClientIP arrived at its answer.
+type ClientIPResolution struct {
+	ClientIP string `json:"client_ip"`
+	// Key is ClientIP after IPv6 prefix normalization; it is what
+	// GetClientIP returns for rate limiting and session hashing.
+	Key        string `json:"key"`
+	RemoteAddr string `json:"remote_addr"`
+	PeerIP     string `json:"peer_ip"`
+	// PeerTrusted reports whether the peer may supply Header: it is a
+	// trusted proxy, or Header is CF-Connecting-IP and the peer is a
+	// Cloudflare address.
+	PeerTrusted bool `json:"peer_trusted"`
+	// Header is the client IP header consulted for trusted peers.
+	Header string `json:"header"`
+	// Source is "header" when Header was honoured, otherwise "remote_addr".
+	Source string `json:"source"`
+}
+
+// ResolveClientIP applies the GetClientIP rules and reports each step.
+func (cf *CloudflareIPs) ResolveClientIP(r *http.Request) ClientIPResolution {
 	remoteIP := extractIP(r.RemoteAddr)
+	res := ClientIPResolution{
+		ClientIP:   remoteIP,
+		RemoteAddr: r.RemoteAddr,
+		PeerIP:     remoteIP,
+		Header:     cf.ClientIPHeader(),
+		Source:     "remote_addr",
+	}
+	res.PeerTrusted = cf.trustedForHeader(remoteIP, res.Header)
 
-	if cf.IsTrusted(remoteIP) {
-		if cfIP := r.Header.Get("CF-Connecting-IP"); cfIP != "" {
-			cfIP = strings.TrimSpace(cfIP)
-			if net.ParseIP(cfIP) != nil {
-				return cfIP
+	if res.PeerTrusted {
+		if headerIP := r.Header.Get(res.Header); headerIP != "" {
+			headerIP = strings.TrimSpace(headerIP)
+			if net.ParseIP(headerIP) != nil {
+				res.ClientIP = headerIP
+				res.Source = "header"
 			}
 		}
 	}
 
-	return remoteIP
+	cf.mu.RLock()
+	v6Bits := cf.v6Bits
+	cf.mu.RUnlock()
+	res.Key = NormalizeIP(res.ClientIP, v6Bits)
+	return res
+}
+
+// trustedForHeader reports whether the peer at ipStr may supply header.
+func (cf *CloudflareIPs) trustedForHeader(ipStr, header string) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+	cf.mu.RLock()
+	proxies := cf.proxies
+	cf.mu.RUnlock()
+	for _, n := range proxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return http.CanonicalHeaderKey(header) == http.CanonicalHeaderKey(DefaultClientIPHeader) && cf.IsTrusted(ipStr)
+}
+
+// NormalizeIP masks an IPv6 address to its v6Bits-long prefix and returns
+// the network address. IPv4 addresses, including IPv4-mapped IPv6 ones,
+// come back in dotted form. With v6Bits zero, or unparseable input, the
+// string is returned unchanged.
+func NormalizeIP(ipStr string, v6Bits int) string {
+	if v6Bits <= 0 || v6Bits > 128 {
+		return ipStr
+	}
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return ipStr
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.String()
+	}
+	return ip.Mask(net.CIDRMask(v6Bits, 128)).String()
 }
 
 func extractIP(addr string) string {
@@ -83,18 +268,27 @@ func extractIP(addr string) string {
 }
 
 func (cf *CloudflareIPs) refresh() {
-	var nets []*net.IPNet
+	var (
+		nets []*net.IPNet
+		errs []error
+	)
 
 	client := &http.Client{Timeout: 10 * time.Second}
 
-	for _, url := range cfIPURLs {
+	urls := cf.urls
+	if len(urls) == 0 {
+		urls = cfIPURLs
+	}
+	for _, url := range urls {
 		resp, err := client.Get(url)
 		if err != nil {
 			log.Printf("cloudflare: failed to fetch %s: %v", url, err)
+			errs = append(errs, fmt.Errorf("fetch %s: %w", url, err))
 			continue
 		}
 		if resp.StatusCode != http.StatusOK {
 			log.Printf("cloudflare: unexpected status from %s: %s", url, resp.Status)
+			errs = append(errs, fmt.Errorf("fetch %s: status %s", url, resp.Status))
 			resp.Body.Close()
 			continue
 		}
@@ -119,20 +313,35 @@ func (cf *CloudflareIPs) refresh() {
 	if len(nets) > 0 {
 		cf.mu.Lock()
 		cf.nets = nets
+		cf.lastErr = ""
 		cf.mu.Unlock()
+		cf.ready.Store(true)
 		log.Printf("cloudflare: loaded %d IP ranges", len(nets))
+		return
 	}
+
+	// Keep any previously loaded ranges; only the error is updated.
+	err := errors.Join(errs...)
+	if err == nil {
+		err = errors.New("no IP ranges returned")
+	}
+	cf.mu.Lock()
+	cf.lastErr = err.Error()
+	cf.mu.Unlock()
 }
 
 func (cf *CloudflareIPs) refreshLoop() {
-	ticker := time.NewTicker(24 * time.Hour)
-	defer ticker.Stop()
-
 	for {
+		interval := cfRefreshInterval
+		if !cf.Ready() {
+			interval = cfRetryInterval
+		}
+		timer := time.NewTimer(interval)
 		select {
-		case <-ticker.C:
+		case <-timer.C:
 			cf.refresh()
 		case <-cf.done:
+			timer.Stop()
 			return
 		}
 	}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func withCloudflareURLs(t *testing.T, urls ...string) {
+	t.Helper()
+	orig := cfIPURLs
+	cfIPURLs = urls
+	t.Cleanup(func() { cfIPURLs = orig })
+}
+
+func TestCloudflareIPsNotReadyWhenFetchFails(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	withCloudflareURLs(t, ts.URL)
+
+	cf := NewCloudflareIPs()
+	defer cf.Close()
+
+	ready, ranges, lastErr := cf.Status()
+	if ready || cf.Ready() {
+		t.Fatal("expected not ready after failed fetch")
+	}
+	if ranges != 0 || lastErr == "" {
+		t.Fatalf("Status() = %v, %d, %q; want no ranges and an error", ready, ranges, lastErr)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "173.245.48.1:1234"
+	req.Header.Set("CF-Connecting-IP", "203.0.113.9")
+	if got := cf.GetClientIP(req); got != "173.245.48.1" {
+		t.Fatalf("GetClientIP = %q, want RemoteAddr while not ready", got)
+	}
+}
+
+func TestCloudflareIPsReadyAfterFetch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "173.245.48.0/20")
+	}))
+	defer ts.Close()
+	withCloudflareURLs(t, ts.URL)
+
+	cf := NewCloudflareIPs()
+	defer cf.Close()
+
+	ready, ranges, lastErr := cf.Status()
+	if !ready || ranges != 1 || lastErr != "" {
+		t.Fatalf("Status() = %v, %d, %q; want ready with 1 range", ready, ranges, lastErr)
+	}
+
+	// A later failed refresh keeps the loaded ranges and readiness.
+	withCloudflareURLs(t, "http://127.0.0.1:1/unreachable")
+	cf.refresh()
+	ready, ranges, lastErr = cf.Status()
+	if !ready || ranges != 1 || lastErr == "" {
+		t.Fatalf("after failed refresh Status() = %v, %d, %q", ready, ranges, lastErr)
+	}
+}
+
+func TestResolveClientIP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "173.245.48.0/20")
+	}))
+	defer ts.Close()
+	withCloudflareURLs(t, ts.URL)
+
+	cf := NewCloudflareIPs()
+	defer cf.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "173.245.48.1:1234"
+	req.Header.Set("CF-Connecting-IP", " 203.0.113.9 ")
+	got := cf.ResolveClientIP(req)
+	want := ClientIPResolution{ClientIP: "203.0.113.9", Key: "203.0.113.9", RemoteAddr: "173.245.48.1:1234", PeerIP: "173.245.48.1", PeerTrusted: true, Header: "CF-Connecting-IP", Source: "header"}
+	if got != want {
+		t.Fatalf("trusted peer = %+v, want %+v", got, want)
+	}
+
+	// A trusted peer with an unparseable header falls back to the peer.
+	req.Header.Set("CF-Connecting-IP", "not-an-ip")
+	if got := cf.ResolveClientIP(req); got.ClientIP != "173.245.48.1" || !got.PeerTrusted || got.Source != "remote_addr" {
+		t.Fatalf("bad header = %+v", got)
+	}
+
+	// Headers from an untrusted peer are ignored.
+	req.RemoteAddr = "198.51.100.7:4321"
+	req.Header.Set("CF-Connecting-IP", "203.0.113.9")
+	if got := cf.ResolveClientIP(req); got.ClientIP != "198.51.100.7" || got.PeerTrusted || got.Source != "remote_addr" {
+		t.Fatalf("untrusted peer = %+v", got)
+	}
+	if ip := cf.GetClientIP(req); ip != "198.51.100.7" {
+		t.Fatalf("GetClientIP = %q", ip)
+	}
+}
+
+func TestResolveClientIPConfiguredHeader(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "173.245.48.0/20")
+	}))
+	defer ts.Close()
+	withCloudflareURLs(t, ts.URL)
+
+	cf := NewCloudflareIPs()
+	defer cf.Close()
+	cf.SetClientIPHeader("true-client-ip")
+
+	proxies, invalid := ParseTrustedProxies("10.0.0.0/8, 192.0.2.10")
+	if len(invalid) != 0 {
+		t.Fatalf("invalid = %v", invalid)
+	}
+	cf.SetTrustedProxies(proxies)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.1.2.3:1234"
+	req.Header.Set("CF-Connecting-IP", "203.0.113.9")
+	req.Header.Set("True-Client-IP", "203.0.113.20")
+	if got := cf.GetClientIP(req); got != "203.0.113.20" {
+		t.Fatalf("trusted proxy GetClientIP = %q, want True-Client-IP value", got)
+	}
+	req.RemoteAddr = "192.0.2.10:1234"
+	if got := cf.GetClientIP(req); got != "203.0.113.20" {
+		t.Fatalf("trusted proxy address GetClientIP = %q, want True-Client-IP value", got)
+	}
+
+	// Cloudflare passes True-Client-IP through as the client sent it, so a
+	// Cloudflare peer is only trusted for CF-Connecting-IP.
+	req.RemoteAddr = "173.245.48.1:1234"
+	if got := cf.GetClientIP(req); got != "173.245.48.1" {
+		t.Fatalf("Cloudflare peer GetClientIP = %q, want RemoteAddr", got)
+	}
+
+	req.RemoteAddr = "198.51.100.7:4321"
+	if got := cf.GetClientIP(req); got != "198.51.100.7" {
+		t.Fatalf("untrusted peer GetClientIP = %q, want RemoteAddr", got)
+	}
+
+	cf.SetClientIPHeader("")
+	if got := cf.ClientIPHeader(); got != DefaultClientIPHeader {
+		t.Fatalf("reset header = %q, want %q", got, DefaultClientIPHeader)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	nets, invalid := ParseTrustedProxies(" 10.0.0.0/8 ,,2001:db8::/32, 192.0.2.1, ::1, bogus, 10.0.0.0/99")
+	var got []string
+	for _, n := range nets {
+		got = append(got, n.String())
+	}
+	want := []string{"10.0.0.0/8", "2001:db8::/32", "192.0.2.1/32", "::1/128"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("nets = %v, want %v", got, want)
+	}
+	if strings.Join(invalid, " ") != "bogus 10.0.0.0/99" {
+		t.Fatalf("invalid = %v", invalid)
+	}
+}
+
+func TestNormalizeIP(t *testing.T) {
+	cases := []struct {
+		ip   string
+		bits int
+		want string
+	}{
+		{"2001:db8:1:2:aaaa::1", 0, "2001:db8:1:2:aaaa::1"},
+		{"2001:db8:1:2:aaaa::1", 64, "2001:db8:1:2::"},
+		{"2001:db8:1:2:bbbb:cccc:dddd:eeee", 64, "2001:db8:1:2::"},
+		{"2001:db8:1:3::1", 64, "2001:db8:1:3::"},
+		{"2001:db8:1:2:aaaa::1", 48, "2001:db8:1::"},
+		{"203.0.113.9", 64, "203.0.113.9"},
+		{"::ffff:203.0.113.9", 64, "203.0.113.9"},
+		{"not-an-ip", 64, "not-an-ip"},
+	}
+	for _, c := range cases {
+		if got := NormalizeIP(c.ip, c.bits); got != c.want {
+			t.Errorf("NormalizeIP(%q, %d) = %q, want %q", c.ip, c.bits, got, c.want)
+		}
+	}
+}
+
+func TestGetClientIPIPv6Prefix(t *testing.T) {
+	withCloudflareURLs(t, "http://127.0.0.1:1/unreachable")
+	cf := NewCloudflareIPs()
+	defer cf.Close()
+
+	a := httptest.NewRequest(http.MethodGet, "/", nil)
+	a.RemoteAddr = "[2001:db8:1:2::10]:1234"
+	b := httptest.NewRequest(http.MethodGet, "/", nil)
+	b.RemoteAddr = "[2001:db8:1:2:ffff::20]:5678"
+
+	if cf.GetClientIP(a) == cf.GetClientIP(b) {
+		t.Fatal("distinct addresses share a key with normalization off")
+	}
+
+	cf.SetIPv6Prefix(64)
+	if ka, kb := cf.GetClientIP(a), cf.GetClientIP(b); ka != kb || ka != "2001:db8:1:2::" {
+		t.Fatalf("keys = %q, %q; want both 2001:db8:1:2::", ka, kb)
+	}
+	if res := cf.ResolveClientIP(a); res.ClientIP != "2001:db8:1:2::10" {
+		t.Fatalf("ClientIP = %q, want the full address", res.ClientIP)
+	}
+}
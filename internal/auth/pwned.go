@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPwnedRangeURL is the Have I Been Pwned k-anonymity range API.
+const DefaultPwnedRangeURL = "https://api.pwnedpasswords.com/range/"
+
+// pwnedCheckTimeout bounds a single range lookup so a slow service cannot
+// stall password changes.
+const pwnedCheckTimeout = 3 * time.Second
+
+// PwnedChecker looks passwords up in a breach corpus via a range query: only
+// the first five hex characters of the password's SHA-1 leave the process.
+type PwnedChecker struct {
+	rangeURL string
+	client   *http.Client
+}
+
+// NewPwnedChecker creates a checker for the given range endpoint; the hash
+// prefix is appended to rangeURL. Empty means DefaultPwnedRangeURL.
+func NewPwnedChecker(rangeURL string) *PwnedChecker {
+	rangeURL = strings.TrimSpace(rangeURL)
+	if rangeURL == "" {
+		rangeURL = DefaultPwnedRangeURL
+	}
+	return &PwnedChecker{
+		rangeURL: rangeURL,
+		client:   &http.Client{Timeout: pwnedCheckTimeout},
+	}
+}
+
+// Breached reports whether password appears in the breach corpus. Callers
+// decide how to treat errors; a nil error with false means not found.
+func (c *PwnedChecker) Breached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.rangeURL+prefix, nil)
+	if err != nil {
+		return false, fmt.Errorf("pwned range request: %w", err)
+	}
+	// Padding hides the real result-set size from observers.
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("pwned range request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("pwned range request: status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		candidate, countStr, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || !strings.EqualFold(candidate, suffix) {
+			continue
+		}
+		// Padding entries carry a zero count.
+		count, err := strconv.Atoi(strings.TrimSpace(countStr))
+		return err == nil && count > 0, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("pwned range response: %w", err)
+	}
+	return false, nil
+}
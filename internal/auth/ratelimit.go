@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"database/sql"
+	"log"
 	"sync"
 	"time"
 )
@@ -10,12 +12,20 @@ const (
 	RateWindow = 1 * time.Minute
 )
 
-// RateLimiter implements a per-IP sliding window rate limiter.
+// RateLimiter implements a per-IP sliding window rate limiter. Windows are
+// kept in memory unless the limiter is shared, in which case they live in
+// the rate_limit_hits table so every instance using the database enforces
+// one limit.
 type RateLimiter struct {
 	mu      sync.Mutex
 	windows map[string]*window
+	limit   int
+	period  time.Duration
 	done    chan struct{}
 	once    sync.Once
+
+	db    *sql.DB // nil for an in-memory limiter
+	scope string  // separates shared limiters in rate_limit_hits
 }
 
 type window struct {
@@ -24,14 +34,39 @@ type window struct {
 
 // NewRateLimiter creates a rate limiter and starts the cleanup goroutine.
 func NewRateLimiter() *RateLimiter {
+	return NewRateLimiterWithLimit(RateLimit, RateWindow)
+}
+
+// NewRateLimiterWithLimit creates a rate limiter allowing limit attempts per period.
+// Non-positive values fall back to RateLimit and RateWindow.
+func NewRateLimiterWithLimit(limit int, period time.Duration) *RateLimiter {
+	if limit <= 0 {
+		limit = RateLimit
+	}
+	if period <= 0 {
+		period = RateWindow
+	}
 	rl := &RateLimiter{
 		windows: make(map[string]*window),
+		limit:   limit,
+		period:  period,
 		done:    make(chan struct{}),
 	}
 	go rl.cleanupLoop()
 	return rl
 }
 
+// NewSharedRateLimiter creates a rate limiter whose attempts are stored in
+// db under scope, so limiters on several instances with the same scope
+// share one limit. Non-positive values fall back as in
+// NewRateLimiterWithLimit.
+func NewSharedRateLimiter(db *sql.DB, scope string, limit int, period time.Duration) *RateLimiter {
+	rl := NewRateLimiterWithLimit(limit, period)
+	rl.db = db
+	rl.scope = scope
+	return rl
+}
+
 // Close stops the cleanup goroutine.
 func (rl *RateLimiter) Close() {
 	rl.once.Do(func() {
@@ -42,11 +77,15 @@ func (rl *RateLimiter) Close() {
 // Allow checks if the given IP is within the rate limit.
 // Returns true if the request is allowed.
 func (rl *RateLimiter) Allow(ip string) bool {
+	if rl.db != nil {
+		return rl.allowShared(ip)
+	}
+
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
 	now := time.Now()
-	cutoff := now.Add(-RateWindow)
+	cutoff := now.Add(-rl.period)
 
 	w, ok := rl.windows[ip]
 	if !ok {
@@ -63,7 +102,7 @@ func (rl *RateLimiter) Allow(ip string) bool {
 	}
 	w.attempts = valid
 
-	if len(w.attempts) >= RateLimit {
+	if len(w.attempts) >= rl.limit {
 		return false
 	}
 
@@ -71,6 +110,27 @@ func (rl *RateLimiter) Allow(ip string) bool {
 	return true
 }
 
+// allowShared records an attempt only while the key is under the limit. The
+// count and insert are one statement, so concurrent instances cannot both
+// take the last slot. Database errors allow the attempt, as for the admin
+// login guard: the limiter must not take the site down with the database.
+func (rl *RateLimiter) allowShared(key string) bool {
+	now := time.Now()
+	res, err := rl.db.Exec(
+		`INSERT INTO rate_limit_hits (scope, key, hit_at)
+		SELECT ?, ?, ?
+		WHERE (SELECT COUNT(*) FROM rate_limit_hits WHERE scope = ? AND key = ? AND hit_at > ?) < ?`,
+		rl.scope, key, now.UnixNano(),
+		rl.scope, key, now.Add(-rl.period).UnixNano(), rl.limit,
+	)
+	if err != nil {
+		log.Printf("shared rate limit error (scope %s): %v", rl.scope, err)
+		return true
+	}
+	n, err := res.RowsAffected()
+	return err == nil && n > 0
+}
+
 func (rl *RateLimiter) cleanupLoop() {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
@@ -86,10 +146,18 @@ func (rl *RateLimiter) cleanupLoop() {
 }
 
 func (rl *RateLimiter) purgeStale() {
+	if rl.db != nil {
+		cutoff := time.Now().Add(-rl.period).UnixNano()
+		if _, err := rl.db.Exec("DELETE FROM rate_limit_hits WHERE scope = ? AND hit_at <= ?", rl.scope, cutoff); err != nil {
+			log.Printf("shared rate limit cleanup error (scope %s): %v", rl.scope, err)
+		}
+		return
+	}
+
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	cutoff := time.Now().Add(-RateWindow)
+	cutoff := time.Now().Add(-rl.period)
 	for ip, w := range rl.windows {
 		// Remove entries with no recent attempts
 		allStale := true
@@ -2,6 +2,9 @@ package auth
 
 import (
 	"testing"
+	"time"
+
+	"acetate/internal/database"
 )
 
 func TestRateLimiterAllow(t *testing.T) {
@@ -49,3 +52,69 @@ func TestRateLimiterPurge(t *testing.T) {
 		t.Error("stale window should have been purged")
 	}
 }
+
+func TestSharedRateLimiterEnforcesOneLimit(t *testing.T) {
+	db, err := database.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+
+	// Two instances behind a load balancer, each with its own limiter.
+	first := NewSharedRateLimiter(db, "login", 3, time.Minute)
+	defer first.Close()
+	second := NewSharedRateLimiter(db, "login", 3, time.Minute)
+	defer second.Close()
+
+	ip := "192.168.1.1"
+	for i, rl := range []*RateLimiter{first, second, first} {
+		if !rl.Allow(ip) {
+			t.Fatalf("attempt %d should be allowed", i+1)
+		}
+	}
+	if first.Allow(ip) || second.Allow(ip) {
+		t.Fatal("attempts past the shared limit should be denied on every instance")
+	}
+	if !second.Allow("10.0.0.1") {
+		t.Fatal("different IP should be allowed")
+	}
+
+	// Scopes are independent.
+	other := NewSharedRateLimiter(db, "feedback", 3, time.Minute)
+	defer other.Close()
+	if !other.Allow(ip) {
+		t.Fatal("another scope should not share the login window")
+	}
+
+	// Attempts age out of the window and are purged.
+	if _, err := db.Exec("UPDATE rate_limit_hits SET hit_at = ? WHERE scope = 'login'", time.Now().Add(-2*time.Minute).UnixNano()); err != nil {
+		t.Fatalf("age hits: %v", err)
+	}
+	if !second.Allow(ip) {
+		t.Fatal("attempt after the window should be allowed")
+	}
+	first.purgeStale()
+	var remaining int
+	if err := db.QueryRow("SELECT COUNT(*) FROM rate_limit_hits WHERE scope = 'login'").Scan(&remaining); err != nil {
+		t.Fatalf("count hits: %v", err)
+	}
+	if remaining != 1 {
+		t.Fatalf("remaining login hits = %d, want 1", remaining)
+	}
+}
+
+func TestSharedRateLimiterFailsOpen(t *testing.T) {
+	db, err := database.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	rl := NewSharedRateLimiter(db, "login", 1, time.Minute)
+	defer rl.Close()
+	db.Close()
+
+	for i := 0; i < 3; i++ {
+		if !rl.Allow("192.168.1.1") {
+			t.Fatalf("attempt %d denied while the database is unavailable", i+1)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SessionBackendKind names a SessionBackend implementation.
+type SessionBackendKind string
+
+const (
+	// SessionBackendSQLite keeps sessions in the sessions and admin_sessions
+	// tables of the main database.
+	SessionBackendSQLite SessionBackendKind = "sqlite"
+	// SessionBackendMemory keeps sessions in process memory. Sessions do not
+	// survive a restart, are not shared between instances, and listener
+	// sessions are missing from the session analytics that read the
+	// sessions table.
+	SessionBackendMemory SessionBackendKind = "memory"
+)
+
+// ParseSessionBackendKind parses a SESSION_BACKEND value. Empty means
+// SessionBackendSQLite; unknown values return SessionBackendSQLite with an
+// error.
+func ParseSessionBackendKind(value string) (SessionBackendKind, error) {
+	switch k := SessionBackendKind(strings.ToLower(strings.TrimSpace(value))); k {
+	case "":
+		return SessionBackendSQLite, nil
+	case SessionBackendSQLite, SessionBackendMemory:
+		return k, nil
+	default:
+		return SessionBackendSQLite, fmt.Errorf("unknown session backend %q", value)
+	}
+}
+
+// NewSessionBackend returns the backend for kind; db is used by the SQLite
+// backend.
+func NewSessionBackend(kind SessionBackendKind, db *sql.DB) SessionBackend {
+	if kind == SessionBackendMemory {
+		return NewMemorySessionBackend()
+	}
+	return NewSQLiteSessionBackend(db)
+}
+
+// ListenerSession is a stored listener session.
+type ListenerSession struct {
+	ID         string
+	StartedAt  time.Time
+	LastSeenAt time.Time
+	IPHash     string // empty in anonymous mode
+	PasswordID int64
+}
+
+// AdminSession is a stored admin session.
+type AdminSession struct {
+	ID            string
+	UserID        int64
+	CreatedAt     time.Time
+	LastSeenAt    time.Time // zero if never touched
+	IPHash        string    // empty in anonymous mode
+	UserAgentHash string
+}
+
+// lastActive is the later of creation and the last recorded activity.
+func (a AdminSession) lastActive() time.Time {
+	if a.LastSeenAt.After(a.CreatedAt) {
+		return a.LastSeenAt
+	}
+	return a.CreatedAt
+}
+
+// SessionBackend persists listener and admin sessions for a SessionStore.
+// Backends only store records: expiry, fingerprint binding, and touch
+// windows are applied by the SessionStore, so every backend validates
+// sessions the same way. Lookups of unknown IDs report found=false, not an
+// error.
+type SessionBackend interface {
+	CreateSession(sess ListenerSession) error
+	GetSession(id string) (ListenerSession, bool, error)
+	TouchSession(id string, at time.Time) error
+	DeleteSession(id string) error
+	// DeleteSessionsSeenBefore removes listener sessions last seen before
+	// cutoff.
+	DeleteSessionsSeenBefore(cutoff time.Time) error
+
+	CreateAdminSession(sess AdminSession) error
+	GetAdminSession(id string) (AdminSession, bool, error)
+	TouchAdminSession(id string, at time.Time) error
+	DeleteAdminSession(id string) error
+	DeleteAdminSessionsForUser(userID int64) error
+	// ListAdminSessions returns every stored session of a user, expired or
+	// not, in any order.
+	ListAdminSessions(userID int64) ([]AdminSession, error)
+	// DeleteAdminSessionsCreatedBefore removes admin sessions created
+	// before cutoff; DeleteAdminSessionsIdleBefore removes those whose last
+	// activity (or creation, if never touched) is before cutoff.
+	DeleteAdminSessionsCreatedBefore(cutoff time.Time) error
+	DeleteAdminSessionsIdleBefore(cutoff time.Time) error
+
+	// CountSessions reports how many listener and admin sessions are stored.
+	CountSessions() (listener, admin int64, err error)
+}
+
+// SQLiteSessionBackend stores sessions in the sessions and admin_sessions
+// tables.
+type SQLiteSessionBackend struct {
+	db *sql.DB
+}
+
+// NewSQLiteSessionBackend returns a backend using db.
+func NewSQLiteSessionBackend(db *sql.DB) *SQLiteSessionBackend {
+	return &SQLiteSessionBackend{db: db}
+}
+
+// nullIfEmpty stores empty hashes as SQL NULL.
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func (b *SQLiteSessionBackend) CreateSession(sess ListenerSession) error {
+	_, err := b.db.Exec(
+		"INSERT INTO sessions (id, started_at, last_seen_at, ip_hash, password_id) VALUES (?, ?, ?, ?, ?)",
+		sess.ID, sess.StartedAt, sess.LastSeenAt, nullIfEmpty(sess.IPHash), sess.PasswordID,
+	)
+	return err
+}
+
+func (b *SQLiteSessionBackend) GetSession(id string) (ListenerSession, bool, error) {
+	sess := ListenerSession{ID: id}
+	var (
+		ipHash     sql.NullString
+		passwordID sql.NullInt64
+	)
+	err := b.db.QueryRow(
+		"SELECT started_at, last_seen_at, ip_hash, password_id FROM sessions WHERE id = ?", id,
+	).Scan(&sess.StartedAt, &sess.LastSeenAt, &ipHash, &passwordID)
+	if err == sql.ErrNoRows {
+		return sess, false, nil
+	}
+	if err != nil {
+		return sess, false, err
+	}
+	sess.IPHash = ipHash.String
+	sess.PasswordID = passwordID.Int64
+	return sess, true, nil
+}
+
+func (b *SQLiteSessionBackend) TouchSession(id string, at time.Time) error {
+	_, err := b.db.Exec("UPDATE sessions SET last_seen_at = ? WHERE id = ?", at, id)
+	return err
+}
+
+func (b *SQLiteSessionBackend) DeleteSession(id string) error {
+	_, err := b.db.Exec("DELETE FROM sessions WHERE id = ?", id)
+	return err
+}
+
+func (b *SQLiteSessionBackend) DeleteSessionsSeenBefore(cutoff time.Time) error {
+	_, err := b.db.Exec("DELETE FROM sessions WHERE last_seen_at < ?", cutoff)
+	return err
+}
+
+func (b *SQLiteSessionBackend) CreateAdminSession(sess AdminSession) error {
+	var lastSeen interface{}
+	if !sess.LastSeenAt.IsZero() {
+		lastSeen = sess.LastSeenAt
+	}
+	_, err := b.db.Exec(
+		"INSERT INTO admin_sessions (id, created_at, last_seen_at, ip_hash, user_agent_hash, user_id) VALUES (?, ?, ?, ?, ?, ?)",
+		sess.ID, sess.CreatedAt, lastSeen, nullIfEmpty(sess.IPHash), nullIfEmpty(sess.UserAgentHash), sess.UserID,
+	)
+	return err
+}
+
+func (b *SQLiteSessionBackend) GetAdminSession(id string) (AdminSession, bool, error) {
+	rows, err := b.db.Query(
+		"SELECT id, created_at, last_seen_at, ip_hash, user_agent_hash, user_id FROM admin_sessions WHERE id = ?", id,
+	)
+	if err != nil {
+		return AdminSession{}, false, err
+	}
+	sessions, err := scanAdminSessions(rows)
+	if err != nil || len(sessions) == 0 {
+		return AdminSession{}, false, err
+	}
+	return sessions[0], true, nil
+}
+
+func (b *SQLiteSessionBackend) TouchAdminSession(id string, at time.Time) error {
+	_, err := b.db.Exec("UPDATE admin_sessions SET last_seen_at = ? WHERE id = ?", at, id)
+	return err
+}
+
+func (b *SQLiteSessionBackend) DeleteAdminSession(id string) error {
+	_, err := b.db.Exec("DELETE FROM admin_sessions WHERE id = ?", id)
+	return err
+}
+
+func (b *SQLiteSessionBackend) DeleteAdminSessionsForUser(userID int64) error {
+	_, err := b.db.Exec("DELETE FROM admin_sessions WHERE user_id = ?", userID)
+	return err
+}
+
+func (b *SQLiteSessionBackend) ListAdminSessions(userID int64) ([]AdminSession, error) {
+	rows, err := b.db.Query(
+		"SELECT id, created_at, last_seen_at, ip_hash, user_agent_hash, user_id FROM admin_sessions WHERE user_id = ?", userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanAdminSessions(rows)
+}
+
+func (b *SQLiteSessionBackend) DeleteAdminSessionsCreatedBefore(cutoff time.Time) error {
+	_, err := b.db.Exec("DELETE FROM admin_sessions WHERE created_at < ?", cutoff)
+	return err
+}
+
+func (b *SQLiteSessionBackend) DeleteAdminSessionsIdleBefore(cutoff time.Time) error {
+	_, err := b.db.Exec("DELETE FROM admin_sessions WHERE COALESCE(last_seen_at, created_at) < ?", cutoff)
+	return err
+}
+
+func (b *SQLiteSessionBackend) CountSessions() (int64, int64, error) {
+	var listener, admin int64
+	if err := b.db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&listener); err != nil {
+		return 0, 0, err
+	}
+	if err := b.db.QueryRow("SELECT COUNT(*) FROM admin_sessions").Scan(&admin); err != nil {
+		return 0, 0, err
+	}
+	return listener, admin, nil
+}
+
+func scanAdminSessions(rows *sql.Rows) ([]AdminSession, error) {
+	defer rows.Close()
+	sessions := make([]AdminSession, 0)
+	for rows.Next() {
+		var (
+			sess       AdminSession
+			lastSeenAt sql.NullTime
+			ipHash     sql.NullString
+			uaHash     sql.NullString
+			userID     sql.NullInt64
+		)
+		if err := rows.Scan(&sess.ID, &sess.CreatedAt, &lastSeenAt, &ipHash, &uaHash, &userID); err != nil {
+			return nil, err
+		}
+		if lastSeenAt.Valid {
+			sess.LastSeenAt = lastSeenAt.Time
+		}
+		sess.IPHash = ipHash.String
+		sess.UserAgentHash = uaHash.String
+		sess.UserID = userID.Int64
+		sessions = append(sessions, sess)
+	}
+	return sessions, rows.Err()
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// MemorySessionBackend keeps sessions in process memory.
+type MemorySessionBackend struct {
+	mu       sync.Mutex
+	sessions map[string]ListenerSession
+	admin    map[string]AdminSession
+}
+
+// NewMemorySessionBackend returns an empty in-memory backend.
+func NewMemorySessionBackend() *MemorySessionBackend {
+	return &MemorySessionBackend{
+		sessions: make(map[string]ListenerSession),
+		admin:    make(map[string]AdminSession),
+	}
+}
+
+func (b *MemorySessionBackend) CreateSession(sess ListenerSession) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sessions[sess.ID] = sess
+	return nil
+}
+
+func (b *MemorySessionBackend) GetSession(id string) (ListenerSession, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sess, ok := b.sessions[id]
+	return sess, ok, nil
+}
+
+func (b *MemorySessionBackend) TouchSession(id string, at time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if sess, ok := b.sessions[id]; ok {
+		sess.LastSeenAt = at
+		b.sessions[id] = sess
+	}
+	return nil
+}
+
+func (b *MemorySessionBackend) DeleteSession(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sessions, id)
+	return nil
+}
+
+func (b *MemorySessionBackend) DeleteSessionsSeenBefore(cutoff time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, sess := range b.sessions {
+		if sess.LastSeenAt.Before(cutoff) {
+			delete(b.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (b *MemorySessionBackend) CreateAdminSession(sess AdminSession) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.admin[sess.ID] = sess
+	return nil
+}
+
+func (b *MemorySessionBackend) GetAdminSession(id string) (AdminSession, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sess, ok := b.admin[id]
+	return sess, ok, nil
+}
+
+func (b *MemorySessionBackend) TouchAdminSession(id string, at time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if sess, ok := b.admin[id]; ok {
+		sess.LastSeenAt = at
+		b.admin[id] = sess
+	}
+	return nil
+}
+
+func (b *MemorySessionBackend) DeleteAdminSession(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.admin, id)
+	return nil
+}
+
+func (b *MemorySessionBackend) DeleteAdminSessionsForUser(userID int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, sess := range b.admin {
+		if sess.UserID == userID {
+			delete(b.admin, id)
+		}
+	}
+	return nil
+}
+
+func (b *MemorySessionBackend) ListAdminSessions(userID int64) ([]AdminSession, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sessions := make([]AdminSession, 0)
+	for _, sess := range b.admin {
+		if sess.UserID == userID {
+			sessions = append(sessions, sess)
+		}
+	}
+	return sessions, nil
+}
+
+func (b *MemorySessionBackend) DeleteAdminSessionsCreatedBefore(cutoff time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, sess := range b.admin {
+		if sess.CreatedAt.Before(cutoff) {
+			delete(b.admin, id)
+		}
+	}
+	return nil
+}
+
+func (b *MemorySessionBackend) DeleteAdminSessionsIdleBefore(cutoff time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, sess := range b.admin {
+		if sess.lastActive().Before(cutoff) {
+			delete(b.admin, id)
+		}
+	}
+	return nil
+}
+
+func (b *MemorySessionBackend) CountSessions() (int64, int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return int64(len(b.sessions)), int64(len(b.admin)), nil
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File problems reported by CheckAlbumFiles.
+const (
+	FileProblemEmpty       = "empty"
+	FileProblemUnreadable  = "unreadable"
+	FileProblemNoMP3Frames = "no_mp3_frames"
+)
+
+// mp3SyncSearchBytes bounds how far past any ID3v2 tag we look for the first
+// MPEG audio frame header.
+const mp3SyncSearchBytes = 64 * 1024
+
+// FileIssue describes an audio file that failed the scan diagnostic.
+type FileIssue struct {
+	Path    string `json:"path"`
+	Stem    string `json:"stem"`
+	Problem string `json:"problem"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// CheckAlbumFiles scans an album like ScanAlbumTracks and reports files that
+// are empty, unreadable, or (for .mp3) lack a valid MPEG frame header. Paths
+// are slash-separated and relative to albumPath. It returns the number of
+// files checked alongside any issues.
+func CheckAlbumFiles(albumPath string, extensions ...string) (int, []FileIssue, error) {
+	if len(extensions) == 0 {
+		extensions = DefaultAlbumExtensions
+	}
+	tracks, err := ScanAlbumTracks(albumPath, extensions...)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	issues := make([]FileIssue, 0)
+	checked := 0
+	for _, t := range tracks {
+		relPath, ok := findScannedFile(albumPath, t, extensions)
+		if !ok {
+			continue
+		}
+		checked++
+		if problem, detail := checkAudioFile(filepath.Join(albumPath, filepath.FromSlash(relPath))); problem != "" {
+			issues = append(issues, FileIssue{Path: relPath, Stem: t.Stem, Problem: problem, Detail: detail})
+		}
+	}
+	return checked, issues, nil
+}
+
+func findScannedFile(albumPath string, t Track, extensions []string) (string, bool) {
+	dir := filepath.Join(albumPath, filepath.FromSlash(t.Subdir))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || strings.TrimSuffix(name, filepath.Ext(name)) != t.Stem {
+				continue
+			}
+			if strings.ToLower(strings.TrimPrefix(filepath.Ext(name), ".")) != ext {
+				continue
+			}
+			if t.Subdir == "" {
+				return name, true
+			}
+			return t.Subdir + "/" + name, true
+		}
+	}
+	return "", false
+}
+
+func checkAudioFile(path string) (string, string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return FileProblemUnreadable, err.Error()
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return FileProblemUnreadable, err.Error()
+	}
+	if stat.Size() == 0 {
+		return FileProblemEmpty, ""
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".mp3") {
+		return "", ""
+	}
+
+	ok, err := hasMP3Frame(f)
+	if err != nil {
+		return FileProblemUnreadable, err.Error()
+	}
+	if !ok {
+		return FileProblemNoMP3Frames, ""
+	}
+	return "", ""
+}
+
+// hasMP3Frame skips a leading ID3v2 tag and looks for a plausible MPEG audio
+// frame header within the next mp3SyncSearchBytes.
+func hasMP3Frame(rs io.ReadSeeker) (bool, error) {
+	header := make([]byte, 10)
+	n, err := io.ReadFull(rs, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+
+	offset := int64(0)
+	if n == 10 && bytes.Equal(header[:3], []byte("ID3")) {
+		offset = 10 + int64(decodeSyncSafeInt(header[6:10]))
+		if header[5]&0x10 != 0 {
+			offset += 10 // footer present
+		}
+	}
+	if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	buf := make([]byte, mp3SyncSearchBytes)
+	n, err = io.ReadFull(rs, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	buf = buf[:n]
+	for i := 0; i+4 <= len(buf); i++ {
+		if validMPEGFrameHeader(buf[i : i+4]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func validMPEGFrameHeader(h []byte) bool {
+	if h[0] != 0xFF || h[1]&0xE0 != 0xE0 {
+		return false
+	}
+	version := (h[1] >> 3) & 0x03
+	layer := (h[1] >> 1) & 0x03
+	bitrate := h[2] >> 4
+	sampleRate := (h[2] >> 2) & 0x03
+	return version != 0x01 && layer != 0x00 && bitrate != 0x0F && bitrate != 0x00 && sampleRate != 0x03
+}
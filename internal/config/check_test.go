@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckAlbumFiles(t *testing.T) {
+	albumDir := t.TempDir()
+
+	// A minimal valid frame: MPEG-1 Layer III, 128 kbps, 44.1 kHz.
+	valid := append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 400)...)
+	files := map[string][]byte{
+		"01-good.mp3":    valid,
+		"02-empty.mp3":   {},
+		"03-garbage.mp3": []byte("this is definitely not audio data at all"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(albumDir, name), data, 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	checked, issues, err := CheckAlbumFiles(albumDir)
+	if err != nil {
+		t.Fatalf("CheckAlbumFiles: %v", err)
+	}
+	if checked != 3 {
+		t.Fatalf("checked = %d, want 3", checked)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("expected 2 issues, got %+v", issues)
+	}
+	if issues[0].Path != "02-empty.mp3" || issues[0].Problem != FileProblemEmpty {
+		t.Errorf("issues[0] = %+v, want empty 02-empty.mp3", issues[0])
+	}
+	if issues[1].Path != "03-garbage.mp3" || issues[1].Problem != FileProblemNoMP3Frames {
+		t.Errorf("issues[1] = %+v, want no_mp3_frames 03-garbage.mp3", issues[1])
+	}
+}
+
+func TestCheckAlbumFilesSkipsID3Tag(t *testing.T) {
+	albumDir := t.TempDir()
+
+	// ID3v2 header declaring a 20-byte tag, followed by a valid frame header.
+	data := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, 20}
+	data = append(data, make([]byte, 20)...)
+	data = append(data, 0xFF, 0xFB, 0x90, 0x00)
+	if err := os.WriteFile(filepath.Join(albumDir, "01-tagged.mp3"), data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	_, issues, err := CheckAlbumFiles(albumDir)
+	if err != nil {
+		t.Fatalf("CheckAlbumFiles: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("unexpected issues: %+v", issues)
+	}
+}
@@ -20,6 +20,15 @@ type Track struct {
 	Stem         string `json:"stem"`
 	Title        string `json:"title"`
 	DisplayIndex string `json:"display_index,omitempty"`
+	Subdir       string `json:"subdir,omitempty"`
+	// AllowDownload permits downloading this track even when the album has
+	// downloads disabled (e.g. a bonus track).
+	AllowDownload bool `json:"allow_download,omitempty"`
+	// DurationSeconds is the decoded length of an MP3 track, read when the
+	// album is scanned. ModTime is the file's modification time (Unix
+	// nanoseconds) at that scan; a different time means the value is stale.
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	ModTime         int64   `json:"mod_time,omitempty"`
 }
 
 // Config represents the album configuration.
@@ -78,7 +87,8 @@ func (m *Manager) Update(cfg Config) error {
 	return nil
 }
 
-// Reload re-reads config.json from disk.
+// Reload re-reads config.json from disk. If the file cannot be read or
+// parsed, the previously loaded configuration is kept and the error returned.
 func (m *Manager) Reload() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -139,33 +149,220 @@ func (m *Manager) generateDefault(albumPath string) error {
 	return m.save(&cfg)
 }
 
-// ScanAlbumTracks reads MP3 files from disk and returns a sorted default track list.
-func ScanAlbumTracks(albumPath string) ([]Track, error) {
-	entries, err := os.ReadDir(albumPath)
-	if err != nil {
-		return nil, fmt.Errorf("scan album directory: %w", err)
+// DefaultAlbumExtensions lists the audio file extensions scanned when none
+// are configured.
+var DefaultAlbumExtensions = []string{"mp3"}
+
+var albumExtensionRe = regexp.MustCompile(`^[a-z0-9]{1,10}$`)
+
+// ParseAlbumExtensions parses a comma-separated list such as "mp3,flac,.opus".
+// Entries are lowercased and deduplicated and invalid entries are dropped.
+// An empty result falls back to DefaultAlbumExtensions.
+func ParseAlbumExtensions(raw string) []string {
+	var out []string
+	seen := make(map[string]struct{})
+	for _, part := range strings.Split(raw, ",") {
+		ext := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(part), "."))
+		if !albumExtensionRe.MatchString(ext) {
+			continue
+		}
+		if _, ok := seen[ext]; ok {
+			continue
+		}
+		seen[ext] = struct{}{}
+		out = append(out, ext)
+	}
+	if len(out) == 0 {
+		return append([]string(nil), DefaultAlbumExtensions...)
+	}
+	return out
+}
+
+// maxScanDepth bounds how many directory levels below the album root are
+// scanned for tracks (e.g. "disc1/01-foo.mp3" is one level).
+const maxScanDepth = 2
+
+// subdirSegmentRe mirrors album.ValidateStem so scanned subdirectories can
+// always be resolved again when streaming.
+var subdirSegmentRe = regexp.MustCompile(`^[a-zA-Z0-9 _'()\-]+$`)
+
+// DuplicateStemError reports a track stem found in more than one folder of
+// an album. Stems identify tracks across the whole album, so the files must
+// be renamed before the album can be scanned.
+type DuplicateStemError struct {
+	Stem  string
+	Paths []string // relative to the album folder, slash-separated
+}
+
+func (e *DuplicateStemError) Error() string {
+	return fmt.Sprintf("track stem %q appears in more than one folder (%s); stems must be unique across the album",
+		e.Stem, strings.Join(e.Paths, ", "))
+}
+
+// ScanAlbumTracks reads audio files from disk and returns a sorted default track list.
+// Only the given extensions are scanned (DefaultAlbumExtensions when none).
+// Files in nested folders such as "disc1/" are included with Subdir set to the
+// slash-separated relative directory. Symlinked directories are not followed.
+// A stem in two folders returns a *DuplicateStemError; the same stem with
+// several extensions in one folder is a single track.
+func ScanAlbumTracks(albumPath string, extensions ...string) ([]Track, error) {
+	if len(extensions) == 0 {
+		extensions = DefaultAlbumExtensions
+	}
+	allowed := make(map[string]struct{}, len(extensions))
+	for _, ext := range extensions {
+		allowed[strings.ToLower(strings.TrimPrefix(ext, "."))] = struct{}{}
 	}
 
 	var tracks []Track
+	if err := scanAlbumDir(albumPath, "", 0, allowed, &tracks); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(tracks, func(i, j int) bool {
+		if tracks[i].Subdir != tracks[j].Subdir {
+			return NaturalLess(tracks[i].Subdir, tracks[j].Subdir)
+		}
+		return NaturalLess(tracks[i].Stem, tracks[j].Stem)
+	})
+
+	seen := make(map[string]Track, len(tracks))
+	unique := tracks[:0]
+	for _, t := range tracks {
+		if first, ok := seen[t.Stem]; ok {
+			if first.Subdir != t.Subdir {
+				return nil, &DuplicateStemError{Stem: t.Stem, Paths: []string{trackRelPath(first), trackRelPath(t)}}
+			}
+			continue
+		}
+		seen[t.Stem] = t
+		unique = append(unique, t)
+	}
+
+	return unique, nil
+}
+
+// trackRelPath names a scanned track's folder and stem for messages.
+func trackRelPath(t Track) string {
+	if t.Subdir == "" {
+		return t.Stem
+	}
+	return t.Subdir + "/" + t.Stem
+}
+
+func scanAlbumDir(albumPath, subdir string, depth int, allowed map[string]struct{}, tracks *[]Track) error {
+	dir := filepath.Join(albumPath, filepath.FromSlash(subdir))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("scan album directory: %w", err)
+	}
+
 	for _, entry := range entries {
+		name := entry.Name()
 		if entry.IsDir() {
+			if depth >= maxScanDepth || !validSubdirSegment(name) {
+				continue
+			}
+			child := name
+			if subdir != "" {
+				child = subdir + "/" + name
+			}
+			if err := scanAlbumDir(albumPath, child, depth+1, allowed, tracks); err != nil {
+				return err
+			}
 			continue
 		}
-		name := entry.Name()
-		if !strings.HasSuffix(strings.ToLower(name), ".mp3") {
+		ext := filepath.Ext(name)
+		if _, ok := allowed[strings.ToLower(strings.TrimPrefix(ext, "."))]; !ok || ext == "" {
 			continue
 		}
 
-		stem := strings.TrimSuffix(name, filepath.Ext(name))
-		title := deriveTitleFromMetadata(filepath.Join(albumPath, name), stem)
-		tracks = append(tracks, Track{Stem: stem, Title: title})
+		stem := strings.TrimSuffix(name, ext)
+		path := filepath.Join(dir, name)
+		track := Track{Stem: stem, Title: deriveTitleFromMetadata(path, stem), Subdir: subdir}
+		if strings.EqualFold(ext, ".mp3") {
+			track.DurationSeconds, track.ModTime = readTrackDuration(path)
+		}
+		*tracks = append(*tracks, track)
 	}
+	return nil
+}
 
-	sort.Slice(tracks, func(i, j int) bool {
-		return tracks[i].Stem < tracks[j].Stem
-	})
+// readTrackDuration returns an MP3's duration and modification time, or
+// zeros when the file cannot be decoded.
+func readTrackDuration(path string) (float64, int64) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0
+	}
+	decoded, err := ReadMP3Info(path)
+	if err != nil || decoded.DurationSeconds <= 0 {
+		return 0, 0
+	}
+	return decoded.DurationSeconds, info.ModTime().UnixNano()
+}
+
+func validSubdirSegment(name string) bool {
+	return !strings.Contains(name, "..") && subdirSegmentRe.MatchString(name)
+}
+
+// NaturalLess orders strings with embedded digit runs compared numerically,
+// so "2-foo" sorts before "10-foo". Non-digit runs compare byte-wise.
+func NaturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		aDigit, bDigit := isDigit(a[0]), isDigit(b[0])
+		if aDigit && bDigit {
+			aRun, bRun := leadingRun(a, true), leadingRun(b, true)
+			aNum, bNum := strings.TrimLeft(aRun, "0"), strings.TrimLeft(bRun, "0")
+			if len(aNum) != len(bNum) {
+				return len(aNum) < len(bNum)
+			}
+			if aNum != bNum {
+				return aNum < bNum
+			}
+			// Equal values: fewer leading zeros first keeps the order stable.
+			if len(aRun) != len(bRun) {
+				return len(aRun) < len(bRun)
+			}
+			a, b = a[len(aRun):], b[len(bRun):]
+			continue
+		}
+		if aDigit != bDigit {
+			return a[0] < b[0]
+		}
+		aRun, bRun := leadingRun(a, false), leadingRun(b, false)
+		n := min(len(aRun), len(bRun))
+		if aRun[:n] != bRun[:n] {
+			return aRun[:n] < bRun[:n]
+		}
+		a, b = a[n:], b[n:]
+	}
+	return len(a) < len(b)
+}
+
+func leadingRun(s string, digits bool) string {
+	i := 0
+	for i < len(s) && isDigit(s[i]) == digits {
+		i++
+	}
+	return s[:i]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// DeriveTitle returns the title a stem gets when its audio file carries no
+// usable metadata, e.g. "01-gathering" becomes "Gathering".
+func DeriveTitle(stem string) string {
+	return deriveTitle(stem)
+}
 
-	return tracks, nil
+// ReadMetadataTitle returns the trimmed ID3v2 (or ID3v1) title of an audio
+// file, or "" if it has none.
+func ReadMetadataTitle(path string) (string, error) {
+	title, err := readMP3Title(path)
+	return strings.TrimSpace(title), err
 }
 
 func deriveTitleFromMetadata(mp3Path, stem string) string {
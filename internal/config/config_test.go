@@ -2,9 +2,13 @@ package config
 
 import (
 	"encoding/binary"
+	"errors"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDeriveTitle(t *testing.T) {
@@ -177,3 +181,233 @@ func makeID3v23TaggedMP3(title string) []byte {
 	data = append(data, []byte{0x00, 0x00, 0x00, 0x00}...) // fake audio bytes
 	return data
 }
+
+func TestScanAlbumTracksNaturalOrder(t *testing.T) {
+	albumDir := t.TempDir()
+	for _, name := range []string{"10.mp3", "2.mp3", "11.mp3", "1.mp3"} {
+		os.WriteFile(filepath.Join(albumDir, name), []byte("fake"), 0644)
+	}
+
+	tracks, err := ScanAlbumTracks(albumDir)
+	if err != nil {
+		t.Fatalf("ScanAlbumTracks: %v", err)
+	}
+
+	want := []string{"1", "2", "10", "11"}
+	if len(tracks) != len(want) {
+		t.Fatalf("expected %d tracks, got %d", len(want), len(tracks))
+	}
+	for i, stem := range want {
+		if tracks[i].Stem != stem {
+			t.Errorf("tracks[%d].Stem = %q, want %q", i, tracks[i].Stem, stem)
+		}
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"2-foo", "10-foo", true},
+		{"10-foo", "2-foo", false},
+		{"1-a", "01-a", true},
+		{"alpha", "beta", true},
+		{"track2", "track10", true},
+		{"intro", "01-intro", false},
+		{"same", "same", false},
+	}
+	for _, tt := range tests {
+		if got := NaturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("NaturalLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestScanAlbumTracksDiscFolders(t *testing.T) {
+	albumDir := t.TempDir()
+	files := []string{
+		"disc2/01-return.mp3",
+		"disc2/02-home.mp3",
+		"disc1/02-away.mp3",
+		"disc1/01-depart.mp3",
+		"disc1/notes.txt",
+		".hidden/01-secret.mp3",
+	}
+	for _, name := range files {
+		path := filepath.Join(albumDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		os.WriteFile(path, []byte("fake"), 0644)
+	}
+
+	tracks, err := ScanAlbumTracks(albumDir)
+	if err != nil {
+		t.Fatalf("ScanAlbumTracks: %v", err)
+	}
+
+	want := []Track{
+		{Stem: "01-depart", Subdir: "disc1"},
+		{Stem: "02-away", Subdir: "disc1"},
+		{Stem: "01-return", Subdir: "disc2"},
+		{Stem: "02-home", Subdir: "disc2"},
+	}
+	if len(tracks) != len(want) {
+		t.Fatalf("expected %d tracks, got %+v", len(want), tracks)
+	}
+	for i, w := range want {
+		if tracks[i].Stem != w.Stem || tracks[i].Subdir != w.Subdir {
+			t.Errorf("tracks[%d] = %s/%s, want %s/%s", i, tracks[i].Subdir, tracks[i].Stem, w.Subdir, w.Stem)
+		}
+	}
+}
+
+func TestScanAlbumTracksRejectsDuplicateStemsAcrossFolders(t *testing.T) {
+	albumDir := t.TempDir()
+	for _, name := range []string{"disc1/01-intro.mp3", "disc2/01-intro.mp3", "disc2/02-outro.mp3"} {
+		path := filepath.Join(albumDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		os.WriteFile(path, []byte("fake"), 0644)
+	}
+
+	_, err := ScanAlbumTracks(albumDir)
+	var dup *DuplicateStemError
+	if !errors.As(err, &dup) {
+		t.Fatalf("ScanAlbumTracks error = %v, want *DuplicateStemError", err)
+	}
+	if dup.Stem != "01-intro" || strings.Join(dup.Paths, " ") != "disc1/01-intro disc2/01-intro" {
+		t.Fatalf("duplicate = %+v", dup)
+	}
+
+	// One stem with several extensions in the same folder is one track.
+	sameDir := t.TempDir()
+	for _, name := range []string{"01-a.mp3", "01-a.flac"} {
+		os.WriteFile(filepath.Join(sameDir, name), []byte("fake"), 0644)
+	}
+	tracks, err := ScanAlbumTracks(sameDir, "mp3", "flac")
+	if err != nil || len(tracks) != 1 || tracks[0].Stem != "01-a" {
+		t.Fatalf("same-folder extensions = %+v, %v", tracks, err)
+	}
+}
+
+func TestScanAlbumTracksConfiguredExtensions(t *testing.T) {
+	albumDir := t.TempDir()
+	for _, name := range []string{"01-a.mp3", "02-b.FLAC", "03-c.opus", "04-d.wav", "cover.jpg"} {
+		os.WriteFile(filepath.Join(albumDir, name), []byte("fake"), 0644)
+	}
+
+	tracks, err := ScanAlbumTracks(albumDir, ParseAlbumExtensions("flac, .opus")...)
+	if err != nil {
+		t.Fatalf("ScanAlbumTracks: %v", err)
+	}
+	if len(tracks) != 2 || tracks[0].Stem != "02-b" || tracks[1].Stem != "03-c" {
+		t.Fatalf("unexpected tracks: %+v", tracks)
+	}
+
+	// Default remains MP3-only.
+	tracks, err = ScanAlbumTracks(albumDir)
+	if err != nil {
+		t.Fatalf("ScanAlbumTracks default: %v", err)
+	}
+	if len(tracks) != 1 || tracks[0].Stem != "01-a" {
+		t.Fatalf("unexpected default tracks: %+v", tracks)
+	}
+}
+
+func TestScanAlbumTracksReadsDuration(t *testing.T) {
+	albumDir := t.TempDir()
+	path := filepath.Join(albumDir, "01-gathering.mp3")
+	if err := os.WriteFile(path, cbrMP3(100), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	os.WriteFile(filepath.Join(albumDir, "02-hollow.mp3"), []byte("fake"), 0644)
+
+	tracks, err := ScanAlbumTracks(albumDir)
+	if err != nil {
+		t.Fatalf("ScanAlbumTracks: %v", err)
+	}
+	// 100 frames * 417 bytes at 128 kbps.
+	if want := 41700.0 * 8 / 128000; len(tracks) != 2 || math.Abs(tracks[0].DurationSeconds-want) > 0.001 {
+		t.Fatalf("tracks = %+v, want first duration %f", tracks, want)
+	}
+	info, _ := os.Stat(path)
+	if tracks[0].ModTime != info.ModTime().UnixNano() {
+		t.Fatalf("ModTime = %d, want %d", tracks[0].ModTime, info.ModTime().UnixNano())
+	}
+	if tracks[1].DurationSeconds != 0 || tracks[1].ModTime != 0 {
+		t.Fatalf("undecodable track = %+v, want no duration", tracks[1])
+	}
+
+	// Replacing the file is picked up on the next scan.
+	if err := os.WriteFile(path, cbrMP3(200), 0644); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	later := info.ModTime().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	tracks, err = ScanAlbumTracks(albumDir)
+	if err != nil {
+		t.Fatalf("rescan: %v", err)
+	}
+	if want := 83400.0 * 8 / 128000; math.Abs(tracks[0].DurationSeconds-want) > 0.001 || tracks[0].ModTime != later.UnixNano() {
+		t.Fatalf("rescanned track = %+v, want duration %f at %d", tracks[0], want, later.UnixNano())
+	}
+}
+
+func TestParseAlbumExtensions(t *testing.T) {
+	got := ParseAlbumExtensions(" MP3,flac,,.opus,flac,bad/ext")
+	want := []string{"mp3", "flac", "opus"}
+	if len(got) != len(want) {
+		t.Fatalf("ParseAlbumExtensions = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ParseAlbumExtensions = %v, want %v", got, want)
+		}
+	}
+
+	if got := ParseAlbumExtensions(""); len(got) != 1 || got[0] != "mp3" {
+		t.Fatalf("empty ParseAlbumExtensions = %v, want [mp3]", got)
+	}
+}
+
+func TestReloadPicksUpExternalEdit(t *testing.T) {
+	albumDir := t.TempDir()
+	dataDir := t.TempDir()
+	os.WriteFile(filepath.Join(albumDir, "01-test.mp3"), []byte("fake"), 0644)
+
+	mgr, err := NewManager(dataDir, albumDir)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	configPath := filepath.Join(dataDir, "config.json")
+	edited := `{"title":"Edited On Disk","artist":"Someone","password":"","tracks":[{"stem":"01-test","title":"Renamed"}]}`
+	if err := os.WriteFile(configPath, []byte(edited), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if got := mgr.Get().Title; got == "Edited On Disk" {
+		t.Fatal("manager picked up the edit before Reload")
+	}
+
+	if err := mgr.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	got := mgr.Get()
+	if got.Title != "Edited On Disk" || len(got.Tracks) != 1 || got.Tracks[0].Title != "Renamed" {
+		t.Fatalf("reloaded config = %+v", got)
+	}
+
+	// A broken file must leave the last good config in place.
+	if err := os.WriteFile(configPath, []byte(`{"title":`), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := mgr.Reload(); err == nil {
+		t.Fatal("expected Reload to fail on invalid JSON")
+	}
+	if got := mgr.Get().Title; got != "Edited On Disk" {
+		t.Fatalf("title after failed reload = %q, want previous config kept", got)
+	}
+}
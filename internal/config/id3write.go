@@ -0,0 +1,201 @@
+package config
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"unicode/utf16"
+)
+
+// ErrUnsupportedID3 is returned by WriteID3Tags for tags it cannot rewrite
+// safely: ID3v2.2 and unsynchronised tags.
+var ErrUnsupportedID3 = errors.New("unsupported ID3v2 tag")
+
+// ID3Tags holds the text frames WriteID3Tags sets. Empty fields leave the
+// existing frame untouched.
+type ID3Tags struct {
+	Title  string // TIT2
+	Album  string // TALB
+	Artist string // TPE1
+}
+
+// WriteID3Tags rewrites path's ID3v2 tag with the given text frames. Other
+// frames (cover art, comments, ...) and the audio data are kept byte for
+// byte. A file without a tag gets a new ID3v2.3 tag. The file is replaced
+// atomically via a temporary file in the same directory.
+func WriteID3Tags(path string, tags ID3Tags) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	version := byte(3)
+	var frames []byte
+	audioStart := int64(0)
+
+	header := make([]byte, 10)
+	if n, _ := io.ReadFull(f, header); n == 10 && bytes.Equal(header[:3], []byte("ID3")) {
+		version = header[3]
+		flags := header[5]
+		if version < 3 || version > 4 || flags&0x80 != 0 {
+			return ErrUnsupportedID3
+		}
+		tagSize := int64(decodeSyncSafeInt(header[6:10]))
+		audioStart = 10 + tagSize
+		if flags&0x10 != 0 {
+			audioStart += 10 // footer present
+		}
+		tagData := make([]byte, tagSize)
+		if _, err := io.ReadFull(f, tagData); err != nil {
+			return fmt.Errorf("read ID3 tag: %w", err)
+		}
+		if flags&0x40 != 0 {
+			if tagData, err = skipID3ExtendedHeader(version, tagData); err != nil {
+				return err
+			}
+		}
+		if frames, err = keepID3Frames(version, tagData, tags); err != nil {
+			return err
+		}
+	}
+
+	for _, frame := range []struct{ id, text string }{
+		{"TIT2", tags.Title}, {"TALB", tags.Album}, {"TPE1", tags.Artist},
+	} {
+		if frame.text != "" {
+			frames = append(frames, encodeID3TextFrame(version, frame.id, frame.text)...)
+		}
+	}
+
+	tag := make([]byte, 10, 10+len(frames))
+	copy(tag, []byte{'I', 'D', '3', version, 0, 0})
+	putSyncSafeInt(tag[6:10], len(frames))
+	tag = append(tag, frames...)
+
+	if _, err := f.Seek(audioStart, io.SeekStart); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".retag-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(tag); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := io.Copy(tmp, f); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(stat.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
+
+func skipID3ExtendedHeader(version byte, data []byte) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, ErrUnsupportedID3
+	}
+	var size int
+	if version == 4 {
+		size = decodeSyncSafeInt(data[:4]) // includes the size field
+	} else {
+		size = int(binary.BigEndian.Uint32(data[:4])) + 4
+	}
+	if size > len(data) {
+		return nil, ErrUnsupportedID3
+	}
+	return data[size:], nil
+}
+
+// keepID3Frames returns the raw frames of an ID3v2.3/2.4 tag body, minus
+// those about to be replaced. Padding is dropped.
+func keepID3Frames(version byte, data []byte, tags ID3Tags) ([]byte, error) {
+	replace := map[string]bool{"TIT2": tags.Title != "", "TALB": tags.Album != "", "TPE1": tags.Artist != ""}
+	var out []byte
+	pos := 0
+	for pos+10 <= len(data) {
+		if data[pos] == 0 {
+			break // padding
+		}
+		id := string(data[pos : pos+4])
+		var size int
+		if version == 4 {
+			size = decodeSyncSafeInt(data[pos+4 : pos+8])
+		} else {
+			size = int(binary.BigEndian.Uint32(data[pos+4 : pos+8]))
+		}
+		end := pos + 10 + size
+		if size < 0 || end > len(data) {
+			return nil, fmt.Errorf("%w: frame %q overruns tag", ErrUnsupportedID3, id)
+		}
+		if !replace[id] {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+	return out, nil
+}
+
+// encodeID3TextFrame builds a text frame. ASCII is stored as ISO-8859-1;
+// anything else as UTF-8 in v2.4 or UTF-16 with BOM in v2.3.
+func encodeID3TextFrame(version byte, id, text string) []byte {
+	var body []byte
+	switch {
+	case isASCII(text):
+		body = append([]byte{0}, text...)
+	case version == 4:
+		body = append([]byte{3}, text...)
+	default:
+		body = []byte{1, 0xFF, 0xFE}
+		for _, u := range utf16.Encode([]rune(text)) {
+			body = binary.LittleEndian.AppendUint16(body, u)
+		}
+	}
+
+	frame := make([]byte, 10, 10+len(body))
+	copy(frame, id)
+	if version == 4 {
+		putSyncSafeInt(frame[4:8], len(body))
+	} else {
+		binary.BigEndian.PutUint32(frame[4:8], uint32(len(body)))
+	}
+	return append(frame, body...)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func putSyncSafeInt(b []byte, v int) {
+	b[0] = byte(v>>21) & 0x7f
+	b[1] = byte(v>>14) & 0x7f
+	b[2] = byte(v>>7) & 0x7f
+	b[3] = byte(v) & 0x7f
+}
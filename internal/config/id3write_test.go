@@ -0,0 +1,176 @@
+package config
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// id3v23Frame builds a raw ID3v2.3 frame.
+func id3v23Frame(id string, body []byte) []byte {
+	frame := make([]byte, 10, 10+len(body))
+	copy(frame, id)
+	binary.BigEndian.PutUint32(frame[4:8], uint32(len(body)))
+	return append(frame, body...)
+}
+
+// id3v2Tag wraps frames in an ID3v2 header of the given version, followed
+// by padding.
+func id3v2Tag(version byte, frames []byte, padding int) []byte {
+	tag := []byte{'I', 'D', '3', version, 0, 0, 0, 0, 0, 0}
+	putSyncSafeInt(tag[6:10], len(frames)+padding)
+	tag = append(tag, frames...)
+	return append(tag, make([]byte, padding)...)
+}
+
+func readTagFrames(t *testing.T, data []byte) (byte, map[string][]byte) {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("ID3")) {
+		t.Fatalf("file does not start with an ID3 tag")
+	}
+	version := data[3]
+	body := data[10 : 10+decodeSyncSafeInt(data[6:10])]
+	frames := make(map[string][]byte)
+	for pos := 0; pos+10 <= len(body) && body[pos] != 0; {
+		size := int(binary.BigEndian.Uint32(body[pos+4 : pos+8]))
+		if version == 4 {
+			size = decodeSyncSafeInt(body[pos+4 : pos+8])
+		}
+		frames[string(body[pos:pos+4])] = body[pos+10 : pos+10+size]
+		pos += 10 + size
+	}
+	return version, frames
+}
+
+func TestWriteID3TagsReplacesTextFramesAndKeepsTheRest(t *testing.T) {
+	audio := cbrMP3(3)[20:]
+	comment := id3v23Frame("COMM", []byte("\x00engkeep me"))
+	frames := append(id3v23Frame("TIT2", []byte("\x00Old Title")), comment...)
+	frames = append(frames, id3v23Frame("TPE1", []byte("\x00Old Artist"))...)
+	data := append(id3v2Tag(3, frames, 64), audio...)
+
+	path := filepath.Join(t.TempDir(), "01-track.mp3")
+	if err := os.WriteFile(path, data, 0640); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	err := WriteID3Tags(path, ID3Tags{Title: "New Title", Album: "The Album", Artist: "The Artist"})
+	if err != nil {
+		t.Fatalf("WriteID3Tags: %v", err)
+	}
+
+	title, err := ReadMetadataTitle(path)
+	if err != nil || title != "New Title" {
+		t.Fatalf("title = %q, %v; want New Title", title, err)
+	}
+
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	version, got := readTagFrames(t, out)
+	if version != 3 {
+		t.Fatalf("version = %d, want 3", version)
+	}
+	if !bytes.Equal(got["COMM"], []byte("\x00engkeep me")) {
+		t.Fatalf("COMM = %q, want it preserved", got["COMM"])
+	}
+	if decodeID3TextFrame(got["TALB"]) != "The Album" || decodeID3TextFrame(got["TPE1"]) != "The Artist" {
+		t.Fatalf("TALB = %q, TPE1 = %q", got["TALB"], got["TPE1"])
+	}
+	if !bytes.HasSuffix(out, audio) || len(out)-len(audio) != 10+decodeSyncSafeInt(out[6:10]) {
+		t.Fatalf("audio data was not preserved")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Fatalf("mode = %v, want 0640", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %d entries", len(entries))
+	}
+}
+
+func TestWriteID3TagsAddsTagToUntaggedFile(t *testing.T) {
+	audio := cbrMP3(2)[20:]
+	path := filepath.Join(t.TempDir(), "02-track.mp3")
+	if err := os.WriteFile(path, audio, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if err := WriteID3Tags(path, ID3Tags{Title: "Café Ünïcødé ♫"}); err != nil {
+		t.Fatalf("WriteID3Tags: %v", err)
+	}
+
+	title, err := ReadMetadataTitle(path)
+	if err != nil || title != "Café Ünïcødé ♫" {
+		t.Fatalf("title = %q, %v", title, err)
+	}
+	out, _ := os.ReadFile(path)
+	version, frames := readTagFrames(t, out)
+	if version != 3 || frames["TIT2"][0] != 1 {
+		t.Fatalf("version = %d, encoding = %d; want v2.3 UTF-16", version, frames["TIT2"][0])
+	}
+	if _, ok := frames["TALB"]; ok {
+		t.Fatalf("empty album should not add a TALB frame")
+	}
+	if !bytes.HasSuffix(out, audio) {
+		t.Fatalf("audio data was not preserved")
+	}
+	if info, err := ReadMP3Info(path); err != nil || info.ID3v2Version != "2.3.0" {
+		t.Fatalf("ReadMP3Info = %+v, %v", info, err)
+	}
+}
+
+func TestWriteID3TagsV24UsesSyncSafeSizesAndUTF8(t *testing.T) {
+	frame := make([]byte, 10)
+	copy(frame, "TIT2")
+	putSyncSafeInt(frame[4:8], 4)
+	frame = append(frame, "\x03Old"...)
+	audio := cbrMP3(1)[20:]
+	path := filepath.Join(t.TempDir(), "03-track.mp3")
+	if err := os.WriteFile(path, append(id3v2Tag(4, frame, 0), audio...), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// 200 bytes of multi-byte text gives a frame size whose low byte has the
+	// high bit set, which only round-trips when encoded syncsafe.
+	long := string(bytes.Repeat([]byte("é"), 100))
+	if err := WriteID3Tags(path, ID3Tags{Title: long}); err != nil {
+		t.Fatalf("WriteID3Tags: %v", err)
+	}
+	title, err := ReadMetadataTitle(path)
+	if err != nil || title != long {
+		t.Fatalf("title = %q, %v", title, err)
+	}
+	out, _ := os.ReadFile(path)
+	if version, frames := readTagFrames(t, out); version != 4 || frames["TIT2"][0] != 3 {
+		t.Fatalf("version = %d, want v2.4 UTF-8 frame", version)
+	}
+}
+
+func TestWriteID3TagsRejectsUnsupportedTags(t *testing.T) {
+	dir := t.TempDir()
+	for name, header := range map[string][]byte{
+		"v22.mp3":    {'I', 'D', '3', 2, 0, 0, 0, 0, 0, 0},
+		"unsync.mp3": {'I', 'D', '3', 3, 0, 0x80, 0, 0, 0, 0},
+	} {
+		path := filepath.Join(dir, name)
+		data := append(header, cbrMP3(1)[20:]...)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if err := WriteID3Tags(path, ID3Tags{Title: "x"}); !errors.Is(err, ErrUnsupportedID3) {
+			t.Fatalf("%s: err = %v, want ErrUnsupportedID3", name, err)
+		}
+		if out, _ := os.ReadFile(path); !bytes.Equal(out, data) {
+			t.Fatalf("%s: file was modified", name)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// NormalizeListenAddr validates a LISTEN_ADDR value and returns it in the
+// host:port form net.Listen expects. A bare port such as "8080" becomes
+// ":8080"; anything else must already be host:port with a numeric port in
+// 0-65535.
+func NormalizeListenAddr(addr string) (string, error) {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return "", fmt.Errorf("listen address is empty")
+	}
+	if _, err := strconv.Atoi(addr); err == nil {
+		addr = ":" + addr
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address %q: want host:port or a bare port like 8080", addr)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return "", fmt.Errorf("invalid listen address %q: port must be a number between 0 and 65535", addr)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(n)), nil
+}
@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestNormalizeListenAddr(t *testing.T) {
+	cases := map[string]string{
+		"8080":         ":8080",
+		":8080":        ":8080",
+		"0.0.0.0:8080": "0.0.0.0:8080",
+		" [::1]:9000 ": "[::1]:9000",
+		"localhost:80": "localhost:80",
+	}
+	for in, want := range cases {
+		got, err := NormalizeListenAddr(in)
+		if err != nil || got != want {
+			t.Fatalf("NormalizeListenAddr(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	for _, bad := range []string{"", "localhost", "0.0.0.0:http", ":99999", "1.2.3.4:80:90"} {
+		if got, err := NormalizeListenAddr(bad); err == nil {
+			t.Fatalf("NormalizeListenAddr(%q) = %q, want error", bad, got)
+		}
+	}
+}
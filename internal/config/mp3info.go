@@ -0,0 +1,205 @@
+package config
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrNoMP3Frames is returned by ReadMP3Info when no MPEG audio frame header
+// is found.
+var ErrNoMP3Frames = errors.New("no MPEG audio frames found")
+
+// MP3Info is the technical description of an MP3 file, decoded from its
+// first frame header and any Xing/Info or VBRI header.
+type MP3Info struct {
+	MPEGVersion     string  `json:"mpeg_version"`
+	Layer           int     `json:"layer"`
+	BitrateKbps     int     `json:"bitrate_kbps"` // average for VBR files
+	SampleRate      int     `json:"sample_rate"`
+	ChannelMode     string  `json:"channel_mode"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	VBR             bool    `json:"vbr"`
+	ID3v2Version    string  `json:"id3v2_version,omitempty"` // e.g. "2.3.0"
+	ID3v1           bool    `json:"id3v1"`
+}
+
+var (
+	// Bitrates in kbps indexed by [MPEG-1?][layer-1][index].
+	mpeg1Bitrates = [3][16]int{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	}
+	mpeg2Bitrates = [3][16]int{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	}
+	channelModes = [4]string{"stereo", "joint_stereo", "dual_channel", "mono"}
+)
+
+// mpegFrameHeader is a decoded 4-byte MPEG audio frame header.
+type mpegFrameHeader struct {
+	version    string // "1", "2" or "2.5"
+	layer      int
+	bitrate    int // kbps
+	sampleRate int
+	channels   string
+}
+
+func parseMPEGFrameHeader(h []byte) (mpegFrameHeader, bool) {
+	if !validMPEGFrameHeader(h) {
+		return mpegFrameHeader{}, false
+	}
+	versionBits := (h[1] >> 3) & 0x03
+	layer := 4 - int((h[1]>>1)&0x03)
+	bitrateIdx := h[2] >> 4
+	rateIdx := (h[2] >> 2) & 0x03
+
+	out := mpegFrameHeader{layer: layer, channels: channelModes[h[3]>>6]}
+	switch versionBits {
+	case 0x03:
+		out.version = "1"
+		out.bitrate = mpeg1Bitrates[layer-1][bitrateIdx]
+		out.sampleRate = [3]int{44100, 48000, 32000}[rateIdx]
+	case 0x02:
+		out.version = "2"
+		out.bitrate = mpeg2Bitrates[layer-1][bitrateIdx]
+		out.sampleRate = [3]int{22050, 24000, 16000}[rateIdx]
+	default:
+		out.version = "2.5"
+		out.bitrate = mpeg2Bitrates[layer-1][bitrateIdx]
+		out.sampleRate = [3]int{11025, 12000, 8000}[rateIdx]
+	}
+	return out, true
+}
+
+func (h mpegFrameHeader) samplesPerFrame() int {
+	switch {
+	case h.layer == 1:
+		return 384
+	case h.layer == 3 && h.version != "1":
+		return 576
+	default:
+		return 1152
+	}
+}
+
+// xingOffset is where a Xing/Info tag starts, counted from the frame header:
+// right after the Layer III side information.
+func (h mpegFrameHeader) xingOffset() int {
+	mono := h.channels == "mono"
+	switch {
+	case h.version == "1" && mono:
+		return 4 + 17
+	case h.version == "1":
+		return 4 + 32
+	case mono:
+		return 4 + 9
+	default:
+		return 4 + 17
+	}
+}
+
+// ReadMP3Info decodes bitrate, sample rate, channel mode, duration, VBR and
+// tag versions from an MP3 file. Duration comes from the Xing/Info or VBRI
+// frame count when present, otherwise from the audio size at the first
+// frame's bitrate.
+func ReadMP3Info(path string) (MP3Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return MP3Info{}, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return MP3Info{}, err
+	}
+	size := stat.Size()
+
+	var info MP3Info
+	audioStart := int64(0)
+	header := make([]byte, 10)
+	if n, _ := io.ReadFull(f, header); n == 10 && bytes.Equal(header[:3], []byte("ID3")) {
+		info.ID3v2Version = fmt.Sprintf("2.%d.%d", header[3], header[4])
+		audioStart = 10 + int64(decodeSyncSafeInt(header[6:10]))
+		if header[5]&0x10 != 0 {
+			audioStart += 10 // footer present
+		}
+	}
+	audioEnd := size
+	if size >= 128 {
+		tail := make([]byte, 3)
+		if _, err := f.ReadAt(tail, size-128); err == nil && bytes.Equal(tail, []byte("TAG")) {
+			info.ID3v1 = true
+			audioEnd -= 128
+		}
+	}
+
+	buf := make([]byte, mp3SyncSearchBytes)
+	n, err := f.ReadAt(buf, audioStart)
+	if err != nil && err != io.EOF {
+		return MP3Info{}, err
+	}
+	buf = buf[:n]
+
+	frameAt := -1
+	var frame mpegFrameHeader
+	for i := 0; i+4 <= len(buf); i++ {
+		if h, ok := parseMPEGFrameHeader(buf[i : i+4]); ok {
+			frameAt, frame = i, h
+			break
+		}
+	}
+	if frameAt < 0 {
+		return MP3Info{}, ErrNoMP3Frames
+	}
+
+	info.MPEGVersion = frame.version
+	info.Layer = frame.layer
+	info.SampleRate = frame.sampleRate
+	info.ChannelMode = frame.channels
+	info.BitrateKbps = frame.bitrate
+	audioBytes := audioEnd - audioStart - int64(frameAt)
+
+	frames := 0
+	if frame.layer == 3 {
+		frames, info.VBR = readVBRHeader(buf[frameAt:], frame)
+	}
+	if frames > 0 {
+		info.DurationSeconds = float64(frames) * float64(frame.samplesPerFrame()) / float64(frame.sampleRate)
+		if info.VBR && info.DurationSeconds > 0 {
+			info.BitrateKbps = int(float64(audioBytes) * 8 / info.DurationSeconds / 1000)
+		}
+	} else if frame.bitrate > 0 && audioBytes > 0 {
+		info.DurationSeconds = float64(audioBytes) * 8 / float64(frame.bitrate*1000)
+	}
+	return info, nil
+}
+
+// readVBRHeader looks for a Xing/Info or VBRI tag in the first frame and
+// returns its frame count (0 when absent) and whether it marks a VBR file.
+// "Info" is the LAME variant of Xing written for CBR files.
+func readVBRHeader(frame []byte, h mpegFrameHeader) (int, bool) {
+	if off := h.xingOffset(); len(frame) >= off+12 {
+		tag := string(frame[off : off+4])
+		if tag == "Xing" || tag == "Info" {
+			flags := binary.BigEndian.Uint32(frame[off+4 : off+8])
+			frames := 0
+			if flags&0x01 != 0 {
+				frames = int(binary.BigEndian.Uint32(frame[off+8 : off+12]))
+			}
+			return frames, tag == "Xing"
+		}
+	}
+	const vbriOffset = 4 + 32
+	if len(frame) >= vbriOffset+18 && string(frame[vbriOffset:vbriOffset+4]) == "VBRI" {
+		return int(binary.BigEndian.Uint32(frame[vbriOffset+14 : vbriOffset+18])), true
+	}
+	return 0, false
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// cbrMP3 builds an ID3v2.3-tagged MPEG-1 Layer III file of n frames at
+// 128 kbps, 44.1 kHz stereo. Each frame is 417 bytes.
+func cbrMP3(n int) []byte {
+	data := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, 10}
+	data = append(data, make([]byte, 10)...)
+	for i := 0; i < n; i++ {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+		data = append(data, frame...)
+	}
+	return data
+}
+
+func TestReadMP3InfoCBR(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cbr.mp3")
+	if err := os.WriteFile(path, cbrMP3(100), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	info, err := ReadMP3Info(path)
+	if err != nil {
+		t.Fatalf("ReadMP3Info: %v", err)
+	}
+	if info.BitrateKbps != 128 || info.SampleRate != 44100 || info.ChannelMode != "stereo" {
+		t.Fatalf("info = %+v, want 128 kbps 44100 Hz stereo", info)
+	}
+	if info.VBR || info.MPEGVersion != "1" || info.Layer != 3 || info.ID3v2Version != "2.3.0" || info.ID3v1 {
+		t.Fatalf("info = %+v", info)
+	}
+	// 100 frames * 417 bytes at 128 kbps.
+	if want := 41700.0 * 8 / 128000; math.Abs(info.DurationSeconds-want) > 0.001 {
+		t.Fatalf("duration = %f, want %f", info.DurationSeconds, want)
+	}
+}
+
+func TestReadMP3InfoXingVBR(t *testing.T) {
+	// MPEG-1 Layer III, mono, 44.1 kHz; the Xing tag sits after 17 bytes of
+	// side information and declares 1000 frames.
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0xC0})
+	copy(frame[21:], "Xing")
+	binary.BigEndian.PutUint32(frame[25:], 0x01)
+	binary.BigEndian.PutUint32(frame[29:], 1000)
+	data := append(frame, make([]byte, 200000)...)
+	tag := make([]byte, 128)
+	copy(tag, "TAG")
+	data = append(data, tag...)
+
+	path := filepath.Join(t.TempDir(), "vbr.mp3")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	info, err := ReadMP3Info(path)
+	if err != nil {
+		t.Fatalf("ReadMP3Info: %v", err)
+	}
+	if !info.VBR || info.ChannelMode != "mono" || !info.ID3v1 || info.ID3v2Version != "" {
+		t.Fatalf("info = %+v", info)
+	}
+	if want := 1000.0 * 1152 / 44100; math.Abs(info.DurationSeconds-want) > 0.001 {
+		t.Fatalf("duration = %f, want %f", info.DurationSeconds, want)
+	}
+	if want := int(float64(len(frame)+200000) * 8 / info.DurationSeconds / 1000); info.BitrateKbps != want {
+		t.Fatalf("average bitrate = %d, want %d", info.BitrateKbps, want)
+	}
+}
+
+func TestReadMP3InfoRejectsNonAudio(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "junk.mp3")
+	if err := os.WriteFile(path, []byte("definitely not audio"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := ReadMP3Info(path); err != ErrNoMP3Frames {
+		t.Fatalf("err = %v, want ErrNoMP3Frames", err)
+	}
+}
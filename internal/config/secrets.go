@@ -0,0 +1,23 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadSecretEnv returns the value of the environment variable name, or the
+// contents of the file named by name+"_FILE" when that is set. The file takes
+// precedence so secrets mounted by an orchestrator never need to appear in
+// the process environment. Surrounding whitespace, including the trailing
+// newline most secret files end with, is trimmed.
+func ReadSecretEnv(name string) (string, error) {
+	if path := strings.TrimSpace(os.Getenv(name + "_FILE")); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read %s_FILE: %w", name, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return os.Getenv(name), nil
+}
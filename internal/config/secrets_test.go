@@ -0,0 +1,30 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSecretEnvPrefersFile(t *testing.T) {
+	t.Setenv("ACETATE_TEST_SECRET", "from-env")
+	got, err := ReadSecretEnv("ACETATE_TEST_SECRET")
+	if err != nil || got != "from-env" {
+		t.Fatalf("ReadSecretEnv without file = %q, %v", got, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("  $2a$10$fromfile\n"), 0600); err != nil {
+		t.Fatalf("write secret file: %v", err)
+	}
+	t.Setenv("ACETATE_TEST_SECRET_FILE", path)
+	got, err = ReadSecretEnv("ACETATE_TEST_SECRET")
+	if err != nil || got != "$2a$10$fromfile" {
+		t.Fatalf("ReadSecretEnv with file = %q, %v; want file contents", got, err)
+	}
+
+	t.Setenv("ACETATE_TEST_SECRET_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := ReadSecretEnv("ACETATE_TEST_SECRET"); err == nil {
+		t.Fatal("ReadSecretEnv should fail when the secret file is missing")
+	}
+}
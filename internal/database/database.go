@@ -39,3 +39,31 @@ func Open(dataPath string) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// OpenReadOnly opens the existing SQLite database at the given data path
+// without creating, migrating, or writing to it. The error wraps
+// os.ErrNotExist when there is no database yet.
+func OpenReadOnly(dataPath string) (*sql.DB, error) {
+	dbPath := filepath.Join(dataPath, "acetate.db")
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	db, err := sql.Open("sqlite", "file:"+dbPath+"?mode=ro&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping database: %w", err)
+	}
+	return db, nil
+}
+
+// Snapshot copies db into dir and opens the copy with Open, so it is
+// migrated while db itself is left untouched.
+func Snapshot(db *sql.DB, dir string) (*sql.DB, error) {
+	if _, err := db.Exec("VACUUM INTO ?", filepath.Join(dir, "acetate.db")); err != nil {
+		return nil, fmt.Errorf("copy database: %w", err)
+	}
+	return Open(dir)
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"database/sql"
 	"os"
 	"path/filepath"
 	"testing"
@@ -60,3 +61,84 @@ func TestMigrateIdempotent(t *testing.T) {
 	}
 	db.Close()
 }
+
+func TestMigrateRekeysLegacyRollups(t *testing.T) {
+	dir := t.TempDir()
+	legacy, err := sql.Open("sqlite", filepath.Join(dir, "acetate.db"))
+	if err != nil {
+		t.Fatalf("open legacy db: %v", err)
+	}
+	_, err = legacy.Exec(`
+		CREATE TABLE analytics_rollups_daily (
+			day TEXT NOT NULL,
+			track_stem TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			total_count INTEGER NOT NULL,
+			PRIMARY KEY (day, track_stem, event_type)
+		);
+		INSERT INTO analytics_rollups_daily VALUES ('2026-01-01', '01-a', 'play', 3);
+	`)
+	legacy.Close()
+	if err != nil {
+		t.Fatalf("create legacy rollups: %v", err)
+	}
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer db.Close()
+
+	keyed, err := columnInPrimaryKey(db, "analytics_rollups_daily", "album_id")
+	if err != nil || !keyed {
+		t.Fatalf("album_id in primary key = %v, err = %v", keyed, err)
+	}
+	var total int
+	if err := db.QueryRow("SELECT total_count FROM analytics_rollups_daily WHERE day = '2026-01-01' AND album_id = 0").Scan(&total); err != nil || total != 3 {
+		t.Fatalf("migrated total = %d, err = %v", total, err)
+	}
+
+	// Same stem in two albums no longer collides.
+	for _, albumID := range []int{1, 2} {
+		if _, err := db.Exec("INSERT INTO analytics_rollups_daily (day, album_id, track_stem, event_type, total_count) VALUES ('2026-01-02', ?, '01-a', 'play', 1)", albumID); err != nil {
+			t.Fatalf("insert album %d rollup: %v", albumID, err)
+		}
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer db.Close()
+
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		t.Fatalf("PendingMigrations: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("pending after Migrate = %v, want none", pending)
+	}
+
+	if _, err := db.Exec("DROP INDEX idx_events_track"); err != nil {
+		t.Fatalf("drop index: %v", err)
+	}
+	if _, err := db.Exec("DROP TABLE track_likes"); err != nil {
+		t.Fatalf("drop table: %v", err)
+	}
+	pending, err = PendingMigrations(db)
+	if err != nil {
+		t.Fatalf("PendingMigrations: %v", err)
+	}
+	want := map[string]bool{"create table track_likes": true, "create index idx_events_track": true, "create index idx_track_likes_album_stem": true}
+	if len(pending) != len(want) {
+		t.Fatalf("pending = %v, want %v", pending, want)
+	}
+	for _, p := range pending {
+		if !want[p] {
+			t.Fatalf("unexpected pending migration %q in %v", p, pending)
+		}
+	}
+}
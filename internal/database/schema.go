@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"regexp"
 )
 
 const schemaTables = `
@@ -33,6 +34,36 @@ CREATE TABLE IF NOT EXISTS admin_users (
     last_login_at DATETIME
 );
 
+CREATE TABLE IF NOT EXISTS admin_api_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES admin_users(id) ON DELETE CASCADE,
+    label TEXT NOT NULL DEFAULT '',
+    token_hash TEXT NOT NULL UNIQUE,
+    token_prefix TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME,
+    revoked_at DATETIME
+);
+
+-- Bytes actually written by the stream endpoint, per track per UTC day.
+-- Ranged requests count only the partial body they served.
+CREATE TABLE IF NOT EXISTS stream_bytes_daily (
+    day TEXT NOT NULL,
+    album_id INTEGER NOT NULL DEFAULT 0,
+    track_stem TEXT NOT NULL,
+    total_bytes INTEGER NOT NULL DEFAULT 0,
+    requests INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, album_id, track_stem)
+);
+
+CREATE TABLE IF NOT EXISTS admin_known_devices (
+    user_id INTEGER NOT NULL REFERENCES admin_users(id) ON DELETE CASCADE,
+    fingerprint TEXT NOT NULL,
+    first_seen_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, fingerprint)
+);
+
 CREATE TABLE IF NOT EXISTS events (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     session_id TEXT NOT NULL,
@@ -45,10 +76,17 @@ CREATE TABLE IF NOT EXISTS events (
 
 CREATE TABLE IF NOT EXISTS analytics_rollups_daily (
     day TEXT NOT NULL,
+    album_id INTEGER NOT NULL DEFAULT 0,
     track_stem TEXT NOT NULL,
     event_type TEXT NOT NULL,
     total_count INTEGER NOT NULL,
-    PRIMARY KEY (day, track_stem, event_type)
+    PRIMARY KEY (day, album_id, track_stem, event_type)
+);
+
+-- Highest events.id already added to analytics_rollups_daily.
+CREATE TABLE IF NOT EXISTS analytics_rollup_state (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    last_event_id INTEGER NOT NULL
 );
 
 CREATE TABLE IF NOT EXISTS admin_auth_audit (
@@ -61,6 +99,33 @@ CREATE TABLE IF NOT EXISTS admin_auth_audit (
     reason TEXT
 );
 
+-- Successful mutating admin requests: who called which endpoint.
+CREATE TABLE IF NOT EXISTS admin_change_audit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    occurred_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    admin_user_id INTEGER,
+    method TEXT NOT NULL,
+    endpoint TEXT NOT NULL,
+    summary TEXT NOT NULL DEFAULT '',
+    status INTEGER NOT NULL
+);
+
+-- Shared rate limiting (SHARED_RATE_LIMITS): one row per allowed attempt,
+-- timestamps in Unix nanoseconds.
+CREATE TABLE IF NOT EXISTS rate_limit_hits (
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL,
+    hit_at INTEGER NOT NULL
+);
+
+-- Shared admin login lockout state (SHARED_RATE_LIMITS), Unix nanoseconds.
+CREATE TABLE IF NOT EXISTS admin_login_guard (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure INTEGER NOT NULL,
+    lock_until INTEGER NOT NULL DEFAULT 0
+);
+
 CREATE TABLE IF NOT EXISTS albums (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     slug TEXT NOT NULL UNIQUE,
@@ -89,6 +154,33 @@ CREATE TABLE IF NOT EXISTS listener_passwords (
     updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE TABLE IF NOT EXISTS listener_feedback (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    album_id INTEGER NOT NULL REFERENCES albums(id) ON DELETE CASCADE,
+    session_id TEXT NOT NULL,
+    track_stem TEXT NOT NULL DEFAULT '',
+    message TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS track_likes (
+    album_id INTEGER NOT NULL REFERENCES albums(id) ON DELETE CASCADE,
+    session_id TEXT NOT NULL,
+    stem TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(album_id, session_id, stem)
+);
+
+-- Issued share links. expires_at is unix seconds, matching the signed exp.
+CREATE TABLE IF NOT EXISTS share_links (
+    id TEXT PRIMARY KEY,
+    album_id INTEGER NOT NULL REFERENCES albums(id) ON DELETE CASCADE,
+    stem TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at INTEGER NOT NULL,
+    revoked_at DATETIME
+);
+
 CREATE TABLE IF NOT EXISTS password_album_access (
     password_id INTEGER NOT NULL REFERENCES listener_passwords(id) ON DELETE CASCADE,
     album_id INTEGER NOT NULL REFERENCES albums(id) ON DELETE CASCADE,
@@ -96,84 +188,208 @@ CREATE TABLE IF NOT EXISTS password_album_access (
 );
 `
 
+// schemaColumns lists columns added after their table was first created.
+// Migrate adds any that are missing.
+var schemaColumns = []struct {
+	table, column, def string
+}{
+	{"admin_sessions", "last_seen_at", "DATETIME"},
+	{"admin_sessions", "ip_hash", "TEXT"},
+	{"admin_sessions", "user_agent_hash", "TEXT"},
+	{"admin_sessions", "user_id", "INTEGER"},
+	{"admin_auth_audit", "attempted_username", "TEXT"},
+	{"admin_users", "require_password_reset", "INTEGER NOT NULL DEFAULT 0"},
+
+	// Album feature flags
+	{"albums", "downloads_enabled", "INTEGER NOT NULL DEFAULT 0"},
+	{"albums", "feedback_enabled", "INTEGER NOT NULL DEFAULT 0"},
+	{"albums", "likes_enabled", "INTEGER NOT NULL DEFAULT 0"},
+	{"albums", "accent_color", "TEXT NOT NULL DEFAULT ''"},
+	{"albums", "logo_url", "TEXT NOT NULL DEFAULT ''"},
+
+	// Nested track layouts (e.g. disc folders)
+	{"album_tracks", "subdir", "TEXT NOT NULL DEFAULT ''"},
+	// Per-track download permission
+	{"album_tracks", "allow_download", "INTEGER NOT NULL DEFAULT 0"},
+
+	// Cached MP3 durations
+	{"album_tracks", "duration_seconds", "REAL NOT NULL DEFAULT 0"},
+	{"album_tracks", "duration_mtime", "INTEGER NOT NULL DEFAULT 0"},
+
+	// Share link owner, for per-session limits
+	{"share_links", "session_id", "TEXT NOT NULL DEFAULT ''"},
+
+	// Multi-album columns on existing tables
+	{"sessions", "password_id", "INTEGER"},
+	{"events", "album_id", "INTEGER"},
+	{"analytics_rollups_daily", "album_id", "INTEGER"},
+}
+
 // Migrate applies the database schema.
 func Migrate(db *sql.DB) error {
 	if _, err := db.Exec(schemaTables); err != nil {
 		return err
 	}
 
-	if err := ensureColumnExists(db, "admin_sessions", "last_seen_at", "DATETIME"); err != nil {
-		return err
+	for _, c := range schemaColumns {
+		if err := ensureColumnExists(db, c.table, c.column, c.def); err != nil {
+			return err
+		}
 	}
-	if err := ensureColumnExists(db, "admin_sessions", "ip_hash", "TEXT"); err != nil {
+	if err := ensureRollupAlbumKey(db); err != nil {
 		return err
 	}
-	if err := ensureColumnExists(db, "admin_sessions", "user_agent_hash", "TEXT"); err != nil {
+
+	if err := ensureIndexes(db); err != nil {
 		return err
 	}
-	if err := ensureColumnExists(db, "admin_sessions", "user_id", "INTEGER"); err != nil {
-		return err
+
+	return nil
+}
+
+// PendingMigrations reports what Migrate would change in db without
+// changing it: missing tables, columns, and indexes, and a rollup table
+// still on the pre-album primary key.
+func PendingMigrations(db *sql.DB) ([]string, error) {
+	var pending []string
+	missingTables := make(map[string]bool)
+	for _, m := range createTablePattern.FindAllStringSubmatch(schemaTables, -1) {
+		exists, err := schemaObjectExists(db, "table", m[1])
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missingTables[m[1]] = true
+			pending = append(pending, "create table "+m[1])
+		}
 	}
-	if err := ensureColumnExists(db, "admin_auth_audit", "attempted_username", "TEXT"); err != nil {
-		return err
+
+	for _, c := range schemaColumns {
+		if missingTables[c.table] {
+			continue
+		}
+		exists, err := columnExists(db, c.table, c.column)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			pending = append(pending, "add column "+c.table+"."+c.column)
+		}
 	}
-	if err := ensureColumnExists(db, "admin_users", "require_password_reset", "INTEGER NOT NULL DEFAULT 0"); err != nil {
-		return err
+	if !missingTables["analytics_rollups_daily"] {
+		keyed, err := columnInPrimaryKey(db, "analytics_rollups_daily", "album_id")
+		if err != nil {
+			return nil, err
+		}
+		if !keyed {
+			pending = append(pending, "rebuild analytics_rollups_daily keyed by album_id")
+		}
 	}
 
-	// Album feature flags
-	if err := ensureColumnExists(db, "albums", "downloads_enabled", "INTEGER NOT NULL DEFAULT 0"); err != nil {
-		return err
+	for _, stmt := range schemaIndexes {
+		m := createIndexPattern.FindStringSubmatch(stmt)
+		if m == nil {
+			continue
+		}
+		exists, err := schemaObjectExists(db, "index", m[1])
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			pending = append(pending, "create index "+m[1])
+		}
 	}
+	return pending, nil
+}
 
-	// Multi-album columns on existing tables
-	if err := ensureColumnExists(db, "sessions", "password_id", "INTEGER"); err != nil {
-		return err
-	}
-	if err := ensureColumnExists(db, "events", "album_id", "INTEGER"); err != nil {
-		return err
+var (
+	createTablePattern = regexp.MustCompile(`CREATE TABLE IF NOT EXISTS (\w+)`)
+	createIndexPattern = regexp.MustCompile(`CREATE INDEX IF NOT EXISTS (\w+)`)
+)
+
+func schemaObjectExists(db *sql.DB, kind, name string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = ? AND name = ?", kind, name).Scan(&count)
+	return count > 0, err
+}
+
+var schemaIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_events_track ON events(track_stem)",
+	"CREATE INDEX IF NOT EXISTS idx_events_type ON events(event_type)",
+	"CREATE INDEX IF NOT EXISTS idx_events_session ON events(session_id)",
+	"CREATE INDEX IF NOT EXISTS idx_events_created_at ON events(created_at)",
+	"CREATE INDEX IF NOT EXISTS idx_sessions_last_seen ON sessions(last_seen_at)",
+	"CREATE INDEX IF NOT EXISTS idx_admin_sessions_user ON admin_sessions(user_id)",
+	"CREATE INDEX IF NOT EXISTS idx_admin_users_username ON admin_users(username)",
+	"CREATE INDEX IF NOT EXISTS idx_admin_users_active ON admin_users(is_active)",
+	"CREATE INDEX IF NOT EXISTS idx_admin_api_tokens_user ON admin_api_tokens(user_id)",
+	"CREATE INDEX IF NOT EXISTS idx_rollups_day ON analytics_rollups_daily(day)",
+	"CREATE INDEX IF NOT EXISTS idx_rollups_track ON analytics_rollups_daily(track_stem)",
+	"CREATE INDEX IF NOT EXISTS idx_admin_auth_audit_occurred ON admin_auth_audit(occurred_at)",
+	"CREATE INDEX IF NOT EXISTS idx_admin_change_audit_occurred ON admin_change_audit(occurred_at)",
+	"CREATE INDEX IF NOT EXISTS idx_admin_change_audit_user ON admin_change_audit(admin_user_id)",
+	"CREATE INDEX IF NOT EXISTS idx_rate_limit_hits_key ON rate_limit_hits(scope, key, hit_at)",
+	// Multi-album indexes
+	"CREATE INDEX IF NOT EXISTS idx_albums_slug ON albums(slug)",
+	"CREATE INDEX IF NOT EXISTS idx_album_tracks_album ON album_tracks(album_id)",
+	"CREATE INDEX IF NOT EXISTS idx_album_tracks_album_sort ON album_tracks(album_id, sort_order)",
+	"CREATE INDEX IF NOT EXISTS idx_password_album_access_password ON password_album_access(password_id)",
+	"CREATE INDEX IF NOT EXISTS idx_password_album_access_album ON password_album_access(album_id)",
+	"CREATE INDEX IF NOT EXISTS idx_sessions_password ON sessions(password_id)",
+	"CREATE INDEX IF NOT EXISTS idx_events_album ON events(album_id)",
+	"CREATE INDEX IF NOT EXISTS idx_listener_feedback_album ON listener_feedback(album_id, created_at)",
+	"CREATE INDEX IF NOT EXISTS idx_track_likes_album_stem ON track_likes(album_id, stem)",
+	"CREATE INDEX IF NOT EXISTS idx_share_links_expires ON share_links(expires_at)",
+	"CREATE INDEX IF NOT EXISTS idx_share_links_session ON share_links(session_id, expires_at)",
+}
+
+func ensureIndexes(db *sql.DB) error {
+	for _, stmt := range schemaIndexes {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
 	}
-	if err := ensureColumnExists(db, "analytics_rollups_daily", "album_id", "INTEGER"); err != nil {
+
+	return nil
+}
+
+// ensureRollupAlbumKey rebuilds analytics_rollups_daily from the pre-album
+// layout, whose primary key omitted album_id, so counts for tracks sharing a
+// stem across albums are kept apart.
+func ensureRollupAlbumKey(db *sql.DB) error {
+	keyed, err := columnInPrimaryKey(db, "analytics_rollups_daily", "album_id")
+	if err != nil || keyed {
 		return err
 	}
 
-	if err := ensureIndexes(db); err != nil {
+	tx, err := db.Begin()
+	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	return nil
-}
-
-func ensureIndexes(db *sql.DB) error {
 	stmts := []string{
-		"CREATE INDEX IF NOT EXISTS idx_events_track ON events(track_stem)",
-		"CREATE INDEX IF NOT EXISTS idx_events_type ON events(event_type)",
-		"CREATE INDEX IF NOT EXISTS idx_events_session ON events(session_id)",
-		"CREATE INDEX IF NOT EXISTS idx_events_created_at ON events(created_at)",
-		"CREATE INDEX IF NOT EXISTS idx_sessions_last_seen ON sessions(last_seen_at)",
-		"CREATE INDEX IF NOT EXISTS idx_admin_sessions_user ON admin_sessions(user_id)",
-		"CREATE INDEX IF NOT EXISTS idx_admin_users_username ON admin_users(username)",
-		"CREATE INDEX IF NOT EXISTS idx_admin_users_active ON admin_users(is_active)",
-		"CREATE INDEX IF NOT EXISTS idx_rollups_day ON analytics_rollups_daily(day)",
-		"CREATE INDEX IF NOT EXISTS idx_rollups_track ON analytics_rollups_daily(track_stem)",
-		"CREATE INDEX IF NOT EXISTS idx_admin_auth_audit_occurred ON admin_auth_audit(occurred_at)",
-		// Multi-album indexes
-		"CREATE INDEX IF NOT EXISTS idx_albums_slug ON albums(slug)",
-		"CREATE INDEX IF NOT EXISTS idx_album_tracks_album ON album_tracks(album_id)",
-		"CREATE INDEX IF NOT EXISTS idx_album_tracks_album_sort ON album_tracks(album_id, sort_order)",
-		"CREATE INDEX IF NOT EXISTS idx_password_album_access_password ON password_album_access(password_id)",
-		"CREATE INDEX IF NOT EXISTS idx_password_album_access_album ON password_album_access(album_id)",
-		"CREATE INDEX IF NOT EXISTS idx_sessions_password ON sessions(password_id)",
-		"CREATE INDEX IF NOT EXISTS idx_events_album ON events(album_id)",
+		`CREATE TABLE analytics_rollups_daily_new (
+    day TEXT NOT NULL,
+    album_id INTEGER NOT NULL DEFAULT 0,
+    track_stem TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    total_count INTEGER NOT NULL,
+    PRIMARY KEY (day, album_id, track_stem, event_type)
+)`,
+		`INSERT INTO analytics_rollups_daily_new (day, album_id, track_stem, event_type, total_count)
+		 SELECT day, COALESCE(album_id, 0), track_stem, event_type, SUM(total_count)
+		 FROM analytics_rollups_daily
+		 GROUP BY day, COALESCE(album_id, 0), track_stem, event_type`,
+		"DROP TABLE analytics_rollups_daily",
+		"ALTER TABLE analytics_rollups_daily_new RENAME TO analytics_rollups_daily",
 	}
-
 	for _, stmt := range stmts {
-		if _, err := db.Exec(stmt); err != nil {
-			return err
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("rebuild analytics_rollups_daily: %w", err)
 		}
 	}
-
-	return nil
+	return tx.Commit()
 }
 
 func ensureColumnExists(db *sql.DB, table, column, def string) error {
@@ -216,3 +432,30 @@ func columnExists(db *sql.DB, table, column string) (bool, error) {
 
 	return false, rows.Err()
 }
+
+func columnInPrimaryKey(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defValue   sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defValue, &primaryKey); err != nil {
+			return false, err
+		}
+		if name == column {
+			return primaryKey > 0, nil
+		}
+	}
+
+	return false, rows.Err()
+}
@@ -1,14 +1,31 @@
 package server
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+
+	"acetate/internal/album"
+	"acetate/internal/albums"
+	"acetate/internal/config"
 )
 
 // --- Album CRUD ---
@@ -59,7 +76,7 @@ func (s *Server) handleAdminCreateAlbum(w http.ResponseWriter, r *http.Request)
 		Artist    string `json:"artist"`
 		AlbumPath string `json:"album_path"`
 	}
-	if err := decodeJSONBody(r, &req); err != nil {
+	if err := s.decodeCompatJSONBody(r, &req); err != nil {
 		jsonError(w, "bad request", http.StatusBadRequest)
 		return
 	}
@@ -106,12 +123,38 @@ func (s *Server) handleAdminUpdateAlbum(w http.ResponseWriter, r *http.Request)
 		Title            string `json:"title"`
 		Artist           string `json:"artist"`
 		DownloadsEnabled *bool  `json:"downloads_enabled"`
+		FeedbackEnabled  *bool  `json:"feedback_enabled"`
+		LikesEnabled     *bool  `json:"likes_enabled"`
+		Branding         *struct {
+			AccentColor *string `json:"accent_color"`
+			LogoURL     *string `json:"logo_url"`
+		} `json:"branding"`
 	}
-	if err := decodeJSONBody(r, &req); err != nil {
+	if err := s.decodeCompatJSONBody(r, &req); err != nil {
 		jsonError(w, "bad request", http.StatusBadRequest)
 		return
 	}
 
+	branding := alb.Branding
+	if req.Branding != nil {
+		if req.Branding.AccentColor != nil {
+			color, ok := normalizeAccentColor(*req.Branding.AccentColor)
+			if !ok {
+				jsonError(w, "invalid accent color", http.StatusBadRequest)
+				return
+			}
+			branding.AccentColor = color
+		}
+		if req.Branding.LogoURL != nil {
+			logoURL, ok := normalizeLogoURL(*req.Branding.LogoURL)
+			if !ok {
+				jsonError(w, "invalid logo url", http.StatusBadRequest)
+				return
+			}
+			branding.LogoURL = logoURL
+		}
+	}
+
 	title := alb.Title
 	artist := alb.Artist
 	if req.Title != "" {
@@ -135,9 +178,69 @@ func (s *Server) handleAdminUpdateAlbum(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	if req.FeedbackEnabled != nil {
+		if err := s.albumStore.SetFeedbackEnabled(alb.ID, *req.FeedbackEnabled); err != nil {
+			log.Printf("update feedback_enabled error: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.LikesEnabled != nil {
+		if err := s.albumStore.SetLikesEnabled(alb.ID, *req.LikesEnabled); err != nil {
+			log.Printf("update likes_enabled error: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if req.Branding != nil {
+		if err := s.albumStore.SetBranding(alb.ID, branding); err != nil {
+			log.Printf("update branding error: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	jsonOK(w, map[string]string{"status": "ok"})
 }
 
+var accentColorRe = regexp.MustCompile(`^#(?:[0-9a-f]{3}|[0-9a-f]{6})$`)
+
+const maxLogoURLLen = 2048
+
+// normalizeAccentColor lowercases a "#rgb" or "#rrggbb" hex color. An empty
+// string clears the color.
+func normalizeAccentColor(raw string) (string, bool) {
+	color := strings.ToLower(strings.TrimSpace(raw))
+	if color == "" {
+		return "", true
+	}
+	return color, accentColorRe.MatchString(color)
+}
+
+// normalizeLogoURL accepts a site-relative path such as an uploaded logo's
+// /api/public/{slug}/logo. External URLs are refused: the CSP only allows
+// images from this origin, so the listener page could never show them. An
+// empty string clears the logo.
+func normalizeLogoURL(raw string) (string, bool) {
+	v := strings.TrimSpace(raw)
+	if v == "" {
+		return "", true
+	}
+	if len(v) > maxLogoURLLen {
+		return "", false
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme != "" || u.Host != "" {
+		return "", false
+	}
+	return v, strings.HasPrefix(v, "/") && !strings.HasPrefix(v, "//")
+}
+
 func (s *Server) handleAdminDeleteAlbum(w http.ResponseWriter, r *http.Request) {
 	alb := s.adminAlbumFromRequest(w, r)
 	if alb == nil {
@@ -206,6 +309,11 @@ func (s *Server) handleAdminCreatePassword(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if s.passwordBreached(r.Context(), passphrase) {
+		jsonError(w, "passphrase appears in a known data breach", http.StatusBadRequest)
+		return
+	}
+
 	label := strings.TrimSpace(req.Label)
 	if label == "" {
 		label = "Password"
@@ -247,6 +355,10 @@ func (s *Server) handleAdminUpdatePassword(w http.ResponseWriter, r *http.Reques
 			jsonError(w, "passphrase cannot be empty", http.StatusBadRequest)
 			return
 		}
+		if s.passwordBreached(r.Context(), p) {
+			jsonError(w, "passphrase appears in a known data breach", http.StatusBadRequest)
+			return
+		}
 		passphrase = &p
 	}
 
@@ -298,3 +410,764 @@ func (s *Server) handleAdminListAlbumFolders(w http.ResponseWriter, r *http.Requ
 
 	jsonOK(w, map[string]interface{}{"folders": folders})
 }
+
+// --- Track helpers ---
+
+const (
+	defaultRenumberWidth = 2
+	maxRenumberWidth     = 6
+	maxRenumberStart     = 9999
+)
+
+func (s *Server) handleAdminRenumberTracks(w http.ResponseWriter, r *http.Request) {
+	alb := s.adminAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+
+	var req struct {
+		Width *int `json:"width,omitempty"`
+		Start *int `json:"start,omitempty"`
+	}
+	// An empty body renumbers with the defaults.
+	if err := decodeJSONBody(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	width := defaultRenumberWidth
+	if req.Width != nil {
+		width = *req.Width
+	}
+	start := 1
+	if req.Start != nil {
+		start = *req.Start
+	}
+	if width < 0 || width > maxRenumberWidth || start < 0 || start > maxRenumberStart {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	tracks, err := s.albumStore.GetTracks(alb.ID)
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	renumbered := renumberTracks(tracks, start, width)
+	if err := s.albumStore.SetTracks(alb.ID, renumbered); err != nil {
+		log.Printf("renumber tracks error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"status": "ok",
+		"tracks": renumbered,
+	})
+}
+
+// renumberTracks assigns sequential display indices in current order,
+// zero-padded to width digits (0 disables padding).
+func renumberTracks(tracks []albums.Track, start, width int) []albums.Track {
+	out := make([]albums.Track, len(tracks))
+	for i, t := range tracks {
+		t.DisplayIndex = fmt.Sprintf("%0*d", width, start+i)
+		t.SortOrder = i
+		out[i] = t
+	}
+	return out
+}
+
+// checksumCache memoizes file SHA-256 sums keyed by path, invalidated when the
+// file's size or modification time changes.
+type checksumCache struct {
+	mu      sync.Mutex
+	entries map[string]checksumEntry
+}
+
+type checksumEntry struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+func newChecksumCache() *checksumCache {
+	return &checksumCache{entries: make(map[string]checksumEntry)}
+}
+
+func (c *checksumCache) sum(path string, info os.FileInfo) (string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.sum, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	c.mu.Lock()
+	c.entries[path] = checksumEntry{size: info.Size(), modTime: info.ModTime(), sum: sum}
+	c.mu.Unlock()
+	return sum, nil
+}
+
+func (s *Server) handleAdminTrackChecksum(w http.ResponseWriter, r *http.Request) {
+	alb := s.adminAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+
+	stem, err := normalizeStemParam(chi.URLParam(r, "stem"))
+	if err != nil || !album.ValidateStem(stem) {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	tracks, err := s.albumStore.GetTracks(alb.ID)
+	if err != nil {
+		log.Printf("track checksum tracks error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	track, ok := album.FindTrack(stem, tracks)
+	if !ok {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+	trackDir, ok := album.TrackDir(alb.AlbumPath, track.Subdir)
+	if !ok {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	path, info, ok := album.FindTrackFile(trackDir, stem, s.albumExtensions...)
+	if !ok {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	sum, err := s.checksums.sum(path, info)
+	if err != nil {
+		log.Printf("track checksum error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"stem":        stem,
+		"file":        filepath.Base(path),
+		"sha256":      sum,
+		"size":        info.Size(),
+		"modified_at": info.ModTime().UTC().Format(time.RFC3339),
+	})
+}
+
+// mp3InfoCache memoizes decoded MP3 technical info keyed by path, invalidated
+// when the file's size or modification time changes.
+type mp3InfoCache struct {
+	mu      sync.Mutex
+	entries map[string]mp3InfoEntry
+}
+
+type mp3InfoEntry struct {
+	size    int64
+	modTime time.Time
+	info    config.MP3Info
+}
+
+func newMP3InfoCache() *mp3InfoCache {
+	return &mp3InfoCache{entries: make(map[string]mp3InfoEntry)}
+}
+
+func (c *mp3InfoCache) get(path string, info os.FileInfo) (config.MP3Info, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.info, nil
+	}
+
+	decoded, err := config.ReadMP3Info(path)
+	if err != nil {
+		return config.MP3Info{}, err
+	}
+
+	c.mu.Lock()
+	c.entries[path] = mp3InfoEntry{size: info.Size(), modTime: info.ModTime(), info: decoded}
+	c.mu.Unlock()
+	return decoded, nil
+}
+
+// trackDurationSeconds returns the decoded length of an album's MP3 track,
+// reporting false when the track, its file, or its duration is unknown.
+func (s *Server) trackDurationSeconds(albumID int64, stem string) (float64, bool) {
+	alb, err := s.albumStore.GetAlbum(albumID)
+	if err != nil || alb == nil {
+		return 0, false
+	}
+	tracks, err := s.albumStore.GetTracks(albumID)
+	if err != nil {
+		return 0, false
+	}
+	track, ok := album.FindTrack(stem, tracks)
+	if !ok {
+		return 0, false
+	}
+	return s.currentTrackDuration(alb, track)
+}
+
+// currentTrackDuration returns track's cached duration when it was read from
+// the file as it is now, decoding (and caching) it again when the file's
+// modtime has changed.
+func (s *Server) currentTrackDuration(alb *albums.Album, track albums.Track) (float64, bool) {
+	trackDir, ok := album.TrackDir(alb.AlbumPath, track.Subdir)
+	if !ok {
+		return 0, false
+	}
+	path, info, ok := album.FindTrackFile(trackDir, track.Stem, s.albumExtensions...)
+	if !ok || !strings.EqualFold(filepath.Ext(path), ".mp3") {
+		return 0, false
+	}
+	modTime := info.ModTime().UnixNano()
+	if track.DurationSeconds > 0 && track.ModTime == modTime {
+		return track.DurationSeconds, true
+	}
+
+	// Missing or stale: decode the file and store the result for next time.
+	decoded, err := s.mp3Info.get(path, info)
+	if err != nil || decoded.DurationSeconds <= 0 {
+		return 0, false
+	}
+	if err := s.albumStore.SetTrackDuration(alb.ID, track.Stem, decoded.DurationSeconds, modTime); err != nil {
+		log.Printf("cache track duration error: %v", err)
+	}
+	return decoded.DurationSeconds, true
+}
+
+// withCurrentDurations replaces the cached durations in tracks with ones
+// checked against each file's modtime, so track lists never report the
+// length of a file that has since been replaced.
+func (s *Server) withCurrentDurations(alb *albums.Album, tracks []albums.Track) []albums.Track {
+	for i := range tracks {
+		d, _ := s.currentTrackDuration(alb, tracks[i])
+		tracks[i].DurationSeconds = d
+	}
+	return tracks
+}
+
+// handleAdminTrackInfo reports a track file's decoded MP3 technical details.
+// Non-MP3 files and MP3s without a recognizable frame are rejected with 422.
+func (s *Server) handleAdminTrackInfo(w http.ResponseWriter, r *http.Request) {
+	alb := s.adminAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+
+	stem, err := normalizeStemParam(chi.URLParam(r, "stem"))
+	if err != nil || !album.ValidateStem(stem) {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	tracks, err := s.albumStore.GetTracks(alb.ID)
+	if err != nil {
+		log.Printf("track info tracks error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	track, ok := album.FindTrack(stem, tracks)
+	if !ok {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+	trackDir, ok := album.TrackDir(alb.AlbumPath, track.Subdir)
+	if !ok {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	path, info, ok := album.FindTrackFile(trackDir, stem, s.albumExtensions...)
+	if !ok {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".mp3") {
+		jsonError(w, "not an mp3 file", http.StatusUnprocessableEntity)
+		return
+	}
+
+	decoded, err := s.mp3Info.get(path, info)
+	if err != nil {
+		if errors.Is(err, config.ErrNoMP3Frames) {
+			jsonError(w, "no mp3 frames found", http.StatusUnprocessableEntity)
+			return
+		}
+		log.Printf("track info error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"stem":        stem,
+		"file":        filepath.Base(path),
+		"size":        info.Size(),
+		"modified_at": info.ModTime().UTC().Format(time.RFC3339),
+		"info":        decoded,
+	})
+}
+
+// handleAdminRetagTrack writes the track's configured title and the album's
+// title and artist into the MP3's ID3v2 tag, leaving other frames and the
+// audio data untouched.
+func (s *Server) handleAdminRetagTrack(w http.ResponseWriter, r *http.Request) {
+	alb := s.adminAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+
+	stem, err := normalizeStemParam(chi.URLParam(r, "stem"))
+	if err != nil || !album.ValidateStem(stem) {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	tracks, err := s.albumStore.GetTracks(alb.ID)
+	if err != nil {
+		log.Printf("retag tracks error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	track, ok := album.FindTrack(stem, tracks)
+	if !ok {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+	trackDir, ok := album.TrackDir(alb.AlbumPath, track.Subdir)
+	if !ok {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	path, info, ok := album.FindTrackFile(trackDir, stem, s.albumExtensions...)
+	if !ok {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".mp3") {
+		jsonError(w, "not an mp3 file", http.StatusUnprocessableEntity)
+		return
+	}
+	// Refuse to prepend a tag to something that is not MPEG audio.
+	if _, err := s.mp3Info.get(path, info); err != nil {
+		if errors.Is(err, config.ErrNoMP3Frames) {
+			jsonError(w, "no mp3 frames found", http.StatusUnprocessableEntity)
+			return
+		}
+		log.Printf("retag info error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	tags := config.ID3Tags{Title: track.Title, Album: alb.Title, Artist: alb.Artist}
+	if err := config.WriteID3Tags(path, tags); err != nil {
+		if errors.Is(err, config.ErrUnsupportedID3) {
+			jsonError(w, "unsupported id3 tag", http.StatusUnprocessableEntity)
+			return
+		}
+		log.Printf("retag error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	title, err := config.ReadMetadataTitle(path)
+	if err != nil {
+		log.Printf("retag read back error: %v", err)
+	}
+	jsonOK(w, map[string]interface{}{
+		"stem":   stem,
+		"file":   filepath.Base(path),
+		"title":  title,
+		"album":  alb.Title,
+		"artist": alb.Artist,
+	})
+}
+
+// handleAdminDeleteTrack removes one track from an album's list. With
+// ?delete_file=true its audio and lyric files are also removed from disk.
+func (s *Server) handleAdminDeleteTrack(w http.ResponseWriter, r *http.Request) {
+	alb := s.adminAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+
+	stem, err := normalizeStemParam(chi.URLParam(r, "stem"))
+	if err != nil || !album.ValidateStem(stem) {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	deleteFile := false
+	if raw := r.URL.Query().Get("delete_file"); raw != "" {
+		deleteFile, err = strconv.ParseBool(raw)
+		if err != nil {
+			jsonError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tracks, err := s.albumStore.GetTracks(alb.ID)
+	if err != nil {
+		log.Printf("delete track tracks error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	track, ok := album.FindTrack(stem, tracks)
+	if !ok {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+	trackDir, ok := album.TrackDir(alb.AlbumPath, track.Subdir)
+	if deleteFile && !ok {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	remaining := make([]albums.Track, 0, len(tracks)-1)
+	for _, t := range tracks {
+		if t.Stem != stem {
+			t.SortOrder = len(remaining)
+			remaining = append(remaining, t)
+		}
+	}
+	if err := s.albumStore.SetTracks(alb.ID, remaining); err != nil {
+		log.Printf("delete track error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	deleted := []string{}
+	if deleteFile {
+		deleted, err = album.DeleteTrackFiles(trackDir, stem, s.albumExtensions...)
+		if err != nil {
+			log.Printf("delete track files error: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"status":        "ok",
+		"deleted_files": deleted,
+	})
+}
+
+// Reasons an import row is reported as unmatched.
+const (
+	importRowInvalid  = "invalid_row"
+	importRowNotFound = "not_on_disk"
+	importRowRepeated = "duplicate_stem"
+)
+
+// importRow is one stem→title(→display_index) mapping from an import manifest.
+// Line is 1-based within the manifest (CSV line or JSON array position).
+type importRow struct {
+	Line int
+	adminTrackInput
+}
+
+type importUnmatched struct {
+	Line   int    `json:"line"`
+	Stem   string `json:"stem"`
+	Reason string `json:"reason"`
+}
+
+// handleAdminImportTracks applies titles (and optionally display indices)
+// from a CSV or JSON manifest. Rows for listed tracks update them in place;
+// rows for unlisted stems found on disk are appended. The merged list goes
+// through the same validation as a manual track update.
+func (s *Server) handleAdminImportTracks(w http.ResponseWriter, r *http.Request) {
+	alb := s.adminAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var rows []importRow
+	var err error
+	if mediaType == "text/csv" {
+		rows, err = parseImportCSV(r.Body)
+	} else {
+		rows, err = parseImportJSON(r)
+	}
+	if err != nil || len(rows) == 0 {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := s.albumStore.GetTracks(alb.ID)
+	if err != nil {
+		log.Printf("import tracks error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	onDisk, err := config.ScanAlbumTracks(alb.AlbumPath, s.albumExtensions...)
+	if err != nil {
+		writeAlbumScanError(w, err)
+		return
+	}
+	diskStems := make(map[string]struct{}, len(onDisk))
+	for _, t := range onDisk {
+		diskStems[t.Stem] = struct{}{}
+	}
+
+	update := make([]adminTrackInput, len(existing))
+	position := make(map[string]int, len(existing))
+	for i, t := range existing {
+		update[i] = adminTrackInput{Stem: t.Stem, Title: t.Title, DisplayIndex: t.DisplayIndex}
+		position[t.Stem] = i
+	}
+
+	unmatched := make([]importUnmatched, 0)
+	seen := make(map[string]struct{}, len(rows))
+	applied := 0
+	for _, row := range rows {
+		stem := strings.TrimSpace(row.Stem)
+		reason := ""
+		if _, dup := seen[stem]; dup {
+			reason = importRowRepeated
+		} else if !album.ValidateStem(stem) {
+			reason = importRowInvalid
+		}
+		if reason != "" {
+			unmatched = append(unmatched, importUnmatched{Line: row.Line, Stem: stem, Reason: reason})
+			continue
+		}
+		seen[stem] = struct{}{}
+
+		i, listed := position[stem]
+		if !listed {
+			if _, ok := diskStems[stem]; !ok {
+				unmatched = append(unmatched, importUnmatched{Line: row.Line, Stem: stem, Reason: importRowNotFound})
+				continue
+			}
+			update = append(update, adminTrackInput{Stem: stem, Title: stem})
+			i = len(update) - 1
+			position[stem] = i
+		}
+		if title := strings.TrimSpace(row.Title); title != "" {
+			update[i].Title = title
+		}
+		if display := strings.TrimSpace(row.DisplayIndex); display != "" {
+			update[i].DisplayIndex = display
+		}
+		applied++
+	}
+
+	normalized, err := normalizeAdminTrackUpdate(update, existing, alb.AlbumPath, s.albumExtensions, true)
+	if err != nil {
+		var verr *trackValidationError
+		if errors.As(err, &verr) {
+			jsonStatus(w, http.StatusBadRequest, map[string]interface{}{
+				"error":     "bad request: " + verr.Error(),
+				"details":   verr.Issues,
+				"unmatched": unmatched,
+			})
+			return
+		}
+		jsonError(w, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.albumStore.SetTracks(alb.ID, normalized); err != nil {
+		log.Printf("import tracks error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"status":    "ok",
+		"applied":   applied,
+		"unmatched": unmatched,
+		"tracks":    normalized,
+	})
+}
+
+// parseImportCSV reads stem,title[,display_index] rows. A first row whose
+// first column is "stem" is treated as a header.
+func parseImportCSV(body io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows []importRow
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "stem") {
+			continue
+		}
+		if len(record) < 2 || len(record) > 3 {
+			return nil, fmt.Errorf("line %d: want 2 or 3 columns, got %d", line, len(record))
+		}
+		row := importRow{Line: line, adminTrackInput: adminTrackInput{Stem: record[0], Title: record[1]}}
+		if len(record) == 3 {
+			row.DisplayIndex = record[2]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseImportJSON reads {"tracks": [{"stem", "title", "display_index"}, ...]}.
+func parseImportJSON(r *http.Request) ([]importRow, error) {
+	var req struct {
+		Tracks []adminTrackInput `json:"tracks"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		return nil, err
+	}
+	rows := make([]importRow, len(req.Tracks))
+	for i, t := range req.Tracks {
+		rows[i] = importRow{Line: i + 1, adminTrackInput: t}
+	}
+	return rows, nil
+}
+
+// handleAdminDeriveTitle previews the titles a track would get from its ID3
+// tag and from the stem heuristic. It accepts ?stem= or ?file= (a filename
+// whose extension is dropped); the file need not be listed in the album yet.
+func (s *Server) handleAdminDeriveTitle(w http.ResponseWriter, r *http.Request) {
+	alb := s.adminAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	stem := strings.TrimSpace(query.Get("stem"))
+	if file := strings.TrimSpace(query.Get("file")); stem == "" && file != "" {
+		stem = strings.TrimSuffix(file, filepath.Ext(file))
+	}
+	if !album.ValidateStem(stem) {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"stem":           stem,
+		"derived_title":  config.DeriveTitle(stem),
+		"metadata_title": "",
+		"file":           "",
+	}
+
+	subdir, found, err := s.locateTrackSubdir(alb, stem)
+	if err != nil {
+		log.Printf("derive title lookup error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if found {
+		trackDir, ok := album.TrackDir(alb.AlbumPath, subdir)
+		if ok {
+			if path, _, ok := album.FindTrackFile(trackDir, stem, s.albumExtensions...); ok {
+				title, err := config.ReadMetadataTitle(path)
+				if err != nil {
+					log.Printf("derive title metadata error: %v", err)
+				}
+				resp["metadata_title"] = title
+				resp["file"] = filepath.Base(path)
+			}
+		}
+	}
+
+	jsonOK(w, resp)
+}
+
+// locateTrackSubdir finds the subdirectory holding stem, preferring the
+// album's track list and falling back to a scan for unlisted files.
+func (s *Server) locateTrackSubdir(alb *albums.Album, stem string) (string, bool, error) {
+	tracks, err := s.albumStore.GetTracks(alb.ID)
+	if err != nil {
+		return "", false, err
+	}
+	if t, ok := album.FindTrack(stem, tracks); ok {
+		return t.Subdir, true, nil
+	}
+	scanned, err := config.ScanAlbumTracks(alb.AlbumPath, s.albumExtensions...)
+	if err != nil {
+		return "", false, err
+	}
+	for _, t := range scanned {
+		if t.Stem == stem {
+			return t.Subdir, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// maxLogoDimension bounds uploaded logo width and height in pixels.
+const maxLogoDimension = 2048
+
+// handleAdminUploadLogo stores an album's brand logo. Uploads are re-encoded
+// as PNG so transparency survives and any embedded metadata is dropped.
+func (s *Server) handleAdminUploadLogo(w http.ResponseWriter, r *http.Request) {
+	alb := s.adminAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+
+	img, err := readUploadedImage(r, "logo", maxLogoDimension)
+	if err != nil {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	logoPath := album.LogoPath(s.dataPath, alb.ID)
+	if err := os.MkdirAll(filepath.Dir(logoPath), 0755); err != nil {
+		log.Printf("create logo dir error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(logoPath, encoded.Bytes(), 0644); err != nil {
+		log.Printf("write logo error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonOK(w, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleAdminDeleteLogo(w http.ResponseWriter, r *http.Request) {
+	alb := s.adminAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+
+	if err := os.Remove(album.LogoPath(s.dataPath, alb.ID)); err != nil {
+		if os.IsNotExist(err) {
+			jsonError(w, "not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("delete logo error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonOK(w, map[string]string{"status": "ok"})
+}
@@ -1,9 +1,11 @@
 package server
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
 	"regexp"
 	"strings"
 	"time"
@@ -24,6 +26,7 @@ var (
 	dummyAdminPasswordHash    = "$2a$10$7EqJtq98hPqEX7fNZaFWo.O9JmMbiY4rQ6hJ7PV5K56ZtPcNI0fS"
 	errAdminInvalidCreds      = errors.New("invalid admin credentials")
 	errAdminWeakPassword      = errors.New("weak admin password")
+	errAdminBreachedPassword  = errors.New("breached admin password")
 	errAdminBootstrapMissing  = errors.New("admin bootstrap credentials not configured")
 	errAdminAlreadyConfigured = errors.New("admin already configured")
 )
@@ -41,43 +44,67 @@ type adminUserRecord struct {
 
 // EnsureAdminBootstrap creates the first admin account when the database has no admin users.
 func EnsureAdminBootstrap(db *sql.DB, username, password, passwordHash string) error {
+	return EnsureAdminBootstrapWithPolicy(db, username, password, passwordHash, DefaultPasswordPolicy())
+}
+
+// EnsureAdminBootstrapWithPolicy is EnsureAdminBootstrap with a custom
+// password policy applied to a plaintext bootstrap password.
+func EnsureAdminBootstrapWithPolicy(db *sql.DB, username, password, passwordHash string, policy PasswordPolicy) error {
+	normalizedUsername, hash, needed, err := prepareAdminBootstrap(db, username, password, passwordHash, policy)
+	if err != nil || !needed {
+		return err
+	}
+
+	now := time.Now().UTC()
+	_, err = db.Exec(
+		"INSERT INTO admin_users (username, password_hash, is_active, created_at, updated_at) VALUES (?, ?, 1, ?, ?)",
+		normalizedUsername, hash, now, now,
+	)
+	if err != nil {
+		return fmt.Errorf("create bootstrap admin user: %w", err)
+	}
+
+	return nil
+}
+
+// CheckAdminBootstrap reports whether EnsureAdminBootstrapWithPolicy would
+// create an admin user, and the error it would return, without writing to
+// the database.
+func CheckAdminBootstrap(db *sql.DB, username, password, passwordHash string, policy PasswordPolicy) (bool, error) {
+	_, _, needed, err := prepareAdminBootstrap(db, username, password, passwordHash, policy)
+	return needed, err
+}
+
+// prepareAdminBootstrap resolves the bootstrap credentials. needed is false
+// when admin users already exist.
+func prepareAdminBootstrap(db *sql.DB, username, password, passwordHash string, policy PasswordPolicy) (string, string, bool, error) {
 	count, err := adminUserCount(db)
 	if err != nil {
-		return err
+		return "", "", false, err
 	}
 	if count > 0 {
-		return nil
+		return "", "", false, nil
 	}
 
 	normalizedUsername, err := normalizeAdminUsername(username)
 	if err != nil {
-		return err
+		return "", "", true, err
 	}
 
-	hash, err := resolveAdminPasswordHash(password, passwordHash)
+	hash, err := resolveAdminPasswordHash(password, passwordHash, policy)
 	if err != nil {
 		if errors.Is(err, errAdminWeakPassword) {
-			return fmt.Errorf("invalid ADMIN_PASSWORD: %w", err)
+			return "", "", true, fmt.Errorf("invalid ADMIN_PASSWORD: %w", err)
 		}
 		if errors.Is(err, errAdminBootstrapMissing) {
-			return errAdminBootstrapMissing
+			return "", "", true, errAdminBootstrapMissing
 		}
-		return err
+		return "", "", true, err
 	}
-
-	now := time.Now().UTC()
-	_, err = db.Exec(
-		"INSERT INTO admin_users (username, password_hash, is_active, created_at, updated_at) VALUES (?, ?, 1, ?, ?)",
-		normalizedUsername, hash, now, now,
-	)
-	if err != nil {
-		return fmt.Errorf("create bootstrap admin user: %w", err)
-	}
-
-	return nil
+	return normalizedUsername, hash, true, nil
 }
 
-func resolveAdminPasswordHash(password, passwordHash string) (string, error) {
+func resolveAdminPasswordHash(password, passwordHash string, policy PasswordPolicy) (string, error) {
 	hash := strings.TrimSpace(passwordHash)
 	if hash != "" {
 		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
@@ -90,7 +117,7 @@ func resolveAdminPasswordHash(password, passwordHash string) (string, error) {
 	if pw == "" {
 		return "", errAdminBootstrapMissing
 	}
-	if err := validateAdminPassword(pw); err != nil {
+	if err := policy.validate(pw); err != nil {
 		return "", err
 	}
 	out, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
@@ -122,28 +149,93 @@ func normalizeAdminUsername(username string) (string, error) {
 	return u, nil
 }
 
-func validateAdminPassword(password string) error {
+// PasswordPolicy is the hard policy for admin passwords. Letters and digits
+// are always required. Changing the policy only affects passwords set
+// afterwards; existing passwords keep working until they are changed.
+type PasswordPolicy struct {
+	MinLength        int  `json:"min_length"`         // characters; zero means the default
+	RequireSymbol    bool `json:"require_symbol"`     // a character that is not a letter or digit
+	RequireMixedCase bool `json:"require_mixed_case"` // both upper and lower case letters
+}
+
+// DefaultPasswordPolicy returns the built-in admin password policy.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{MinLength: minAdminPasswordLen}
+}
+
+func (p PasswordPolicy) withDefaults() PasswordPolicy {
+	if p.MinLength <= 0 {
+		p.MinLength = DefaultPasswordPolicy().MinLength
+	}
+	if p.MinLength > maxAdminPasswordLen {
+		p.MinLength = maxAdminPasswordLen
+	}
+	return p
+}
+
+func (p PasswordPolicy) validate(password string) error {
+	p = p.withDefaults()
 	pw := strings.TrimSpace(password)
-	if len(pw) < minAdminPasswordLen || len(pw) > maxAdminPasswordLen {
+	if len(pw) < p.MinLength || len(pw) > maxAdminPasswordLen {
 		return errAdminWeakPassword
 	}
 
 	hasLetter := false
 	hasDigit := false
+	hasSymbol := false
+	hasUpper := false
+	hasLower := false
 	for _, r := range pw {
-		if unicode.IsLetter(r) {
+		switch {
+		case unicode.IsLetter(r):
 			hasLetter = true
-		}
-		if unicode.IsDigit(r) {
+			hasUpper = hasUpper || unicode.IsUpper(r)
+			hasLower = hasLower || unicode.IsLower(r)
+		case unicode.IsDigit(r):
 			hasDigit = true
+		default:
+			hasSymbol = true
 		}
 	}
 	if !hasLetter || !hasDigit {
 		return errAdminWeakPassword
 	}
+	if p.RequireSymbol && !hasSymbol {
+		return errAdminWeakPassword
+	}
+	if p.RequireMixedCase && (!hasUpper || !hasLower) {
+		return errAdminWeakPassword
+	}
+	return nil
+}
+
+// validateNewAdminPassword applies the password policy and, when enabled,
+// the breached-password check to a password being set.
+func (s *Server) validateNewAdminPassword(password string) error {
+	if err := s.passwordPolicy.validate(password); err != nil {
+		return err
+	}
+	if s.passwordBreached(context.Background(), strings.TrimSpace(password)) {
+		return errAdminBreachedPassword
+	}
 	return nil
 }
 
+// passwordBreached reports whether the breached-password check finds pw. It
+// fails open: when the check is disabled or the service is unreachable the
+// password is allowed, so an outage can never block a password change.
+func (s *Server) passwordBreached(ctx context.Context, pw string) bool {
+	if s.pwnedChecker == nil {
+		return false
+	}
+	breached, err := s.pwnedChecker.Breached(ctx, pw)
+	if err != nil {
+		log.Printf("breached password check error (allowing): %v", err)
+		return false
+	}
+	return breached
+}
+
 func (s *Server) authenticateAdminCredentials(username, password string) (adminUserRecord, error) {
 	user := adminUserRecord{}
 
@@ -183,12 +275,12 @@ func (s *Server) updateAdminPassword(userID int64, currentPassword, newPassword
 	if userID <= 0 {
 		return errAdminInvalidCreds
 	}
-	if err := validateAdminPassword(newPassword); err != nil {
+	if err := s.validateNewAdminPassword(newPassword); err != nil {
 		return err
 	}
 
-	var currentHash string
-	err := s.db.QueryRow("SELECT password_hash FROM admin_users WHERE id = ? AND is_active = 1", userID).Scan(&currentHash)
+	var currentHash, currentUpdatedAt string
+	err := s.db.QueryRow("SELECT password_hash, CAST(updated_at AS TEXT) FROM admin_users WHERE id = ? AND is_active = 1", userID).Scan(&currentHash, &currentUpdatedAt)
 	if err == sql.ErrNoRows {
 		return errAdminInvalidCreds
 	}
@@ -205,13 +297,18 @@ func (s *Server) updateAdminPassword(userID int64, currentPassword, newPassword
 		return fmt.Errorf("hash admin password: %w", err)
 	}
 
-	_, err = s.db.Exec(
-		"UPDATE admin_users SET password_hash = ?, require_password_reset = 0, updated_at = ? WHERE id = ?",
-		string(newHash), time.Now().UTC(), userID,
+	// The bcrypt work above leaves a window; only write if the account is
+	// unchanged since it was read.
+	res, err := s.db.Exec(
+		"UPDATE admin_users SET password_hash = ?, require_password_reset = 0, updated_at = ? WHERE id = ? AND CAST(updated_at AS TEXT) = ?",
+		string(newHash), time.Now().UTC(), userID, currentUpdatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("update admin password: %w", err)
 	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errAdminUserConflict
+	}
 
 	if err := s.sessions.DeleteAdminSessionsForUser(userID); err != nil {
 		return fmt.Errorf("revoke admin sessions: %w", err)
@@ -273,7 +370,7 @@ func (s *Server) createInitialAdminUser(username, password string) (adminUserRec
 	if err != nil {
 		return user, err
 	}
-	if err := validateAdminPassword(password); err != nil {
+	if err := s.validateNewAdminPassword(password); err != nil {
 		return user, err
 	}
 
@@ -2,9 +2,15 @@ package server
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"acetate/internal/albums"
+	"acetate/internal/config"
 	"acetate/internal/database"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestEnsureAdminBootstrapCreatesFirstUser(t *testing.T) {
@@ -42,3 +48,94 @@ func TestEnsureAdminBootstrapRequiresCredentials(t *testing.T) {
 		t.Fatalf("expected ErrAdminBootstrapMissing, got %v", err)
 	}
 }
+
+func TestAssessPasswordStrength(t *testing.T) {
+	weak := []string{"password", "Password123!", "aaaaaaaaaaaaaaaa", "short1"}
+	for _, pw := range weak {
+		got := assessPasswordStrength(pw, DefaultPasswordPolicy())
+		if got.Score > 1 {
+			t.Errorf("assessPasswordStrength(%q) score = %d (%s), want <= 1", pw, got.Score, got.Strength)
+		}
+		if len(got.Suggestions) == 0 {
+			t.Errorf("assessPasswordStrength(%q) gave no suggestions", pw)
+		}
+	}
+	// The check is advisory: a common password can still pass the hard policy.
+	if !assessPasswordStrength("Password123!", DefaultPasswordPolicy()).MeetsPolicy {
+		t.Error("Password123! should still meet the hard policy")
+	}
+
+	strong := assessPasswordStrength("correct Horse battery 7 staple!", DefaultPasswordPolicy())
+	if strong.Score != 4 || strong.Strength != "very_strong" || !strong.MeetsPolicy {
+		t.Fatalf("strong password assessment = %+v", strong)
+	}
+	if len(strong.Suggestions) != 0 {
+		t.Fatalf("strong password suggestions = %v, want none", strong.Suggestions)
+	}
+}
+
+func TestPasswordPolicyStricterRejectsPreviouslyValid(t *testing.T) {
+	const pw = "adminpass1234"
+	if err := DefaultPasswordPolicy().validate(pw); err != nil {
+		t.Fatalf("default policy rejected %q: %v", pw, err)
+	}
+
+	cases := []struct {
+		name   string
+		policy PasswordPolicy
+		ok     string
+	}{
+		{"min length", PasswordPolicy{MinLength: 20}, "adminpass1234adminpass"},
+		{"symbol", PasswordPolicy{RequireSymbol: true}, "admin-pass-1234"},
+		{"mixed case", PasswordPolicy{RequireMixedCase: true}, "AdminPass1234"},
+	}
+	for _, tc := range cases {
+		if err := tc.policy.validate(pw); !errors.Is(err, errAdminWeakPassword) {
+			t.Errorf("%s: validate(%q) = %v, want weak password", tc.name, pw, err)
+		}
+		if err := tc.policy.validate(tc.ok); err != nil {
+			t.Errorf("%s: validate(%q) = %v", tc.name, tc.ok, err)
+		}
+	}
+}
+
+func TestAlbumPasswordBootstrapFromSecretFile(t *testing.T) {
+	db, err := database.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	store := albums.NewStore(db)
+	if _, err := store.CreateAlbum("First", "Artist", "/tmp/first"); err != nil {
+		t.Fatalf("create album: %v", err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("listen-from-file"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "album_password_hash")
+	if err := os.WriteFile(path, append(hash, '\n'), 0600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	t.Setenv("ALBUM_PASSWORD_HASH", "$2a$10$ignoredbecausefileisset")
+	t.Setenv("ALBUM_PASSWORD_HASH_FILE", path)
+
+	secret, err := config.ReadSecretEnv("ALBUM_PASSWORD_HASH")
+	if err != nil {
+		t.Fatalf("ReadSecretEnv: %v", err)
+	}
+	created, err := store.BootstrapPassword(secret)
+	if err != nil || !created {
+		t.Fatalf("BootstrapPassword = %v, %v", created, err)
+	}
+	passwordID, albumIDs, err := store.VerifyPassword("listen-from-file")
+	if err != nil || passwordID == 0 || len(albumIDs) != 1 {
+		t.Fatalf("VerifyPassword = %d, %v, %v", passwordID, albumIDs, err)
+	}
+
+	// Existing passwords are never replaced.
+	if created, err := store.BootstrapPassword(secret); err != nil || created {
+		t.Fatalf("second BootstrapPassword = %v, %v; want no-op", created, err)
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// adminChangeAuditLimit caps how many rows the change audit endpoint returns.
+const adminChangeAuditLimit = 500
+
+// adminChangeAudit records every successful state-changing admin request
+// (who, which endpoint, and the route parameters it targeted) in
+// admin_change_audit. Request bodies are never stored, so passwords and
+// tokens stay out of the log. Logins and logouts are covered by
+// admin_auth_audit instead. Must run after requireAdmin.
+func (s *Server) adminChangeAudit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isMutatingMethod(r.Method) || r.URL.Path == "/admin/api/auth" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		wrapped := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(wrapped, r)
+		if wrapped.status >= http.StatusBadRequest {
+			return
+		}
+
+		endpoint, summary := r.URL.Path, ""
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				endpoint = pattern
+			}
+			summary = routeParamSummary(rctx)
+		}
+		var userID interface{}
+		if id, ok := adminUserIDFromContext(r); ok {
+			userID = id
+		}
+		if _, err := s.db.Exec(
+			"INSERT INTO admin_change_audit (admin_user_id, method, endpoint, summary, status) VALUES (?, ?, ?, ?, ?)",
+			userID, r.Method, endpoint, summary, wrapped.status,
+		); err != nil {
+			// Best effort; the change already happened.
+			log.Printf("admin change audit error: %v", err)
+		}
+	})
+}
+
+// routeParamSummary renders the matched URL parameters as "key=value"
+// pairs, e.g. "id=3 stem=01-intro".
+func routeParamSummary(rctx *chi.Context) string {
+	parts := make([]string, 0, len(rctx.URLParams.Keys))
+	for i, key := range rctx.URLParams.Keys {
+		if key == "*" || i >= len(rctx.URLParams.Values) {
+			continue
+		}
+		parts = append(parts, key+"="+rctx.URLParams.Values[i])
+	}
+	return strings.Join(parts, " ")
+}
+
+type adminChangeAuditEntry struct {
+	ID          int64  `json:"id"`
+	OccurredAt  string `json:"occurred_at"`
+	AdminUserID *int64 `json:"admin_user_id"`
+	Username    string `json:"username,omitempty"`
+	Method      string `json:"method"`
+	Endpoint    string `json:"endpoint"`
+	Summary     string `json:"summary"`
+	Status      int    `json:"status"`
+}
+
+// handleAdminListChangeAudit returns recent admin changes, newest first.
+// ?user_id= narrows to one admin; ?limit= defaults to 100.
+func (s *Server) handleAdminListChangeAudit(w http.ResponseWriter, r *http.Request) {
+	limit := clampInt(parseOptionalInt(r.URL.Query().Get("limit"), 100), 1, adminChangeAuditLimit)
+
+	query := `SELECT a.id, a.occurred_at, a.admin_user_id, COALESCE(u.username, ''), a.method, a.endpoint, a.summary, a.status
+		FROM admin_change_audit a LEFT JOIN admin_users u ON u.id = a.admin_user_id`
+	args := []interface{}{}
+	if raw := strings.TrimSpace(r.URL.Query().Get("user_id")); raw != "" {
+		userID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || userID <= 0 {
+			jsonError(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+		query += " WHERE a.admin_user_id = ?"
+		args = append(args, userID)
+	}
+	query += " ORDER BY a.id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Printf("list change audit error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	entries := []adminChangeAuditEntry{}
+	for rows.Next() {
+		var e adminChangeAuditEntry
+		if err := rows.Scan(&e.ID, &e.OccurredAt, &e.AdminUserID, &e.Username, &e.Method, &e.Endpoint, &e.Summary, &e.Status); err != nil {
+			log.Printf("scan change audit error: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("list change audit error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonOK(w, map[string]interface{}{"entries": entries})
+}
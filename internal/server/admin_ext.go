@@ -3,6 +3,7 @@ package server
 import (
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/sha256"
 	"database/sql"
@@ -11,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -20,6 +22,7 @@ import (
 	"strings"
 	"time"
 
+	"acetate/internal/album"
 	"acetate/internal/albums"
 	"acetate/internal/analytics"
 	"acetate/internal/config"
@@ -44,6 +47,19 @@ type reconcileReport struct {
 	AlbumCount      int                      `json:"album_count"`
 }
 
+// reconcileTitleMode controls how metadata titles are merged during reconcile.
+type reconcileTitleMode string
+
+const (
+	// reconcileTitlesFillEmpty fills empty titles only (the default).
+	reconcileTitlesFillEmpty reconcileTitleMode = "fill_empty"
+	// reconcileTitlesAdopt replaces titles that differ from metadata.
+	reconcileTitlesAdopt reconcileTitleMode = "adopt"
+	// reconcileTitlesPreferManual keeps titles an admin edited but replaces
+	// auto-derived ones (those matching the stem) with metadata titles.
+	reconcileTitlesPreferManual reconcileTitleMode = "prefer_manual"
+)
+
 type reconcileApplyResult struct {
 	Added         int `json:"added"`
 	Removed       int `json:"removed"`
@@ -51,8 +67,11 @@ type reconcileApplyResult struct {
 }
 
 func (s *Server) recordAdminAuthAttempt(r *http.Request, attemptedUsername, outcome, reason string) {
-	clientIP := s.cfIPs.GetClientIP(r)
-	ipHash := hashForAudit(clientIP)
+	// Anonymous deployments record no IP-derived data at all.
+	var ipHash interface{}
+	if !s.sessions.Anonymous() {
+		ipHash = hashForAudit(s.cfIPs.GetClientIP(r))
+	}
 	uaHash := hashForAudit(strings.TrimSpace(r.UserAgent()))
 
 	if _, err := s.db.Exec(
@@ -169,9 +188,9 @@ func (s *Server) handleAdminReconcilePreview(w http.ResponseWriter, r *http.Requ
 		jsonError(w, "internal error", http.StatusInternalServerError)
 		return
 	}
-	diskTracks, err := config.ScanAlbumTracks(alb.AlbumPath)
+	diskTracks, err := config.ScanAlbumTracks(alb.AlbumPath, s.albumExtensions...)
 	if err != nil {
-		jsonError(w, "internal error", http.StatusInternalServerError)
+		writeAlbumScanError(w, err)
 		return
 	}
 
@@ -180,6 +199,18 @@ func (s *Server) handleAdminReconcilePreview(w http.ResponseWriter, r *http.Requ
 	jsonOK(w, report)
 }
 
+// writeAlbumScanError answers a failed album folder scan. Duplicate stems
+// are for the admin to fix, so they get the message instead of a 500.
+func writeAlbumScanError(w http.ResponseWriter, err error) {
+	var dup *config.DuplicateStemError
+	if errors.As(err, &dup) {
+		jsonError(w, dup.Error(), http.StatusConflict)
+		return
+	}
+	log.Printf("album scan error: %v", err)
+	jsonError(w, "internal error", http.StatusInternalServerError)
+}
+
 func (s *Server) handleAdminReconcileApply(w http.ResponseWriter, r *http.Request) {
 	alb := s.adminAlbumFromRequest(w, r)
 	if alb == nil {
@@ -187,10 +218,24 @@ func (s *Server) handleAdminReconcileApply(w http.ResponseWriter, r *http.Reques
 	}
 
 	var req struct {
-		AdoptMetadataTitles bool `json:"adopt_metadata_titles"`
-		KeepMissing         bool `json:"keep_missing"`
+		AdoptMetadataTitles bool   `json:"adopt_metadata_titles"`
+		TitleMode           string `json:"title_mode,omitempty"`
+		KeepMissing         bool   `json:"keep_missing"`
 	}
-	if err := decodeJSONBody(r, &req); err != nil {
+	if err := s.decodeCompatJSONBody(r, &req); err != nil {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	titleMode := reconcileTitlesFillEmpty
+	if req.AdoptMetadataTitles {
+		titleMode = reconcileTitlesAdopt
+	}
+	switch mode := reconcileTitleMode(strings.TrimSpace(req.TitleMode)); mode {
+	case "":
+	case reconcileTitlesFillEmpty, reconcileTitlesAdopt, reconcileTitlesPreferManual:
+		titleMode = mode
+	default:
 		jsonError(w, "bad request", http.StatusBadRequest)
 		return
 	}
@@ -200,23 +245,27 @@ func (s *Server) handleAdminReconcileApply(w http.ResponseWriter, r *http.Reques
 		jsonError(w, "internal error", http.StatusInternalServerError)
 		return
 	}
-	diskTracks, err := config.ScanAlbumTracks(alb.AlbumPath)
+	diskTracks, err := config.ScanAlbumTracks(alb.AlbumPath, s.albumExtensions...)
 	if err != nil {
-		jsonError(w, "internal error", http.StatusInternalServerError)
+		writeAlbumScanError(w, err)
 		return
 	}
 
 	configTracks := albumTracksToConfigTracks(dbTracks)
-	updatedConfigTracks, applied := applyReconcile(configTracks, diskTracks, req.AdoptMetadataTitles, req.KeepMissing)
+	updatedConfigTracks, applied := applyReconcile(configTracks, diskTracks, titleMode, req.KeepMissing)
 
 	// Convert back to albums.Track and save
 	newTracks := make([]albums.Track, len(updatedConfigTracks))
 	for i, ct := range updatedConfigTracks {
 		newTracks[i] = albums.Track{
-			Stem:         ct.Stem,
-			Title:        ct.Title,
-			DisplayIndex: ct.DisplayIndex,
-			SortOrder:    i,
+			Stem:            ct.Stem,
+			Title:           ct.Title,
+			DisplayIndex:    ct.DisplayIndex,
+			SortOrder:       i,
+			Subdir:          ct.Subdir,
+			AllowDownload:   ct.AllowDownload,
+			DurationSeconds: ct.DurationSeconds,
+			ModTime:         ct.ModTime,
 		}
 	}
 	if err := s.albumStore.SetTracks(alb.ID, newTracks); err != nil {
@@ -236,7 +285,15 @@ func (s *Server) handleAdminReconcileApply(w http.ResponseWriter, r *http.Reques
 func albumTracksToConfigTracks(tracks []albums.Track) []config.Track {
 	out := make([]config.Track, len(tracks))
 	for i, t := range tracks {
-		out[i] = config.Track{Stem: t.Stem, Title: t.Title, DisplayIndex: t.DisplayIndex}
+		out[i] = config.Track{
+			Stem:            t.Stem,
+			Title:           t.Title,
+			DisplayIndex:    t.DisplayIndex,
+			Subdir:          t.Subdir,
+			AllowDownload:   t.AllowDownload,
+			DurationSeconds: t.DurationSeconds,
+			ModTime:         t.ModTime,
+		}
 	}
 	return out
 }
@@ -278,14 +335,16 @@ func buildReconcileReport(configTracks, albumTracks []config.Track) reconcileRep
 		}
 	}
 
-	sort.Slice(report.ConfigOnly, func(i, j int) bool { return report.ConfigOnly[i].Stem < report.ConfigOnly[j].Stem })
-	sort.Slice(report.AlbumOnly, func(i, j int) bool { return report.AlbumOnly[i].Stem < report.AlbumOnly[j].Stem })
-	sort.Slice(report.TitleMismatches, func(i, j int) bool { return report.TitleMismatches[i].Stem < report.TitleMismatches[j].Stem })
+	sort.Slice(report.ConfigOnly, func(i, j int) bool { return config.NaturalLess(report.ConfigOnly[i].Stem, report.ConfigOnly[j].Stem) })
+	sort.Slice(report.AlbumOnly, func(i, j int) bool { return config.NaturalLess(report.AlbumOnly[i].Stem, report.AlbumOnly[j].Stem) })
+	sort.Slice(report.TitleMismatches, func(i, j int) bool {
+		return config.NaturalLess(report.TitleMismatches[i].Stem, report.TitleMismatches[j].Stem)
+	})
 
 	return report
 }
 
-func applyReconcile(current, albumTracks []config.Track, adoptTitles, keepMissing bool) ([]config.Track, reconcileApplyResult) {
+func applyReconcile(current, albumTracks []config.Track, titleMode reconcileTitleMode, keepMissing bool) ([]config.Track, reconcileApplyResult) {
 	albumMap := make(map[string]config.Track, len(albumTracks))
 	for _, t := range albumTracks {
 		albumMap[t.Stem] = t
@@ -307,10 +366,12 @@ func applyReconcile(current, albumTracks []config.Track, adoptTitles, keepMissin
 		}
 
 		next := t
+		next.Subdir = albumTrack.Subdir
+		next.DurationSeconds, next.ModTime = albumTrack.DurationSeconds, albumTrack.ModTime
 		if strings.TrimSpace(next.Title) == "" {
 			next.Title = albumTrack.Title
 			result.TitlesUpdated++
-		} else if adoptTitles && strings.TrimSpace(albumTrack.Title) != "" && trimAndCollapseSpaces(next.Title) != trimAndCollapseSpaces(albumTrack.Title) {
+		} else if titleReplaceable(titleMode, next) && strings.TrimSpace(albumTrack.Title) != "" && trimAndCollapseSpaces(next.Title) != trimAndCollapseSpaces(albumTrack.Title) {
 			next.Title = albumTrack.Title
 			result.TitlesUpdated++
 		}
@@ -330,6 +391,19 @@ func applyReconcile(current, albumTracks []config.Track, adoptTitles, keepMissin
 	return updated, result
 }
 
+// titleReplaceable reports whether a non-empty title may be replaced by the
+// metadata title under mode.
+func titleReplaceable(mode reconcileTitleMode, t config.Track) bool {
+	switch mode {
+	case reconcileTitlesAdopt:
+		return true
+	case reconcileTitlesPreferManual:
+		return trimAndCollapseSpaces(t.Title) == config.DeriveTitle(t.Stem)
+	default:
+		return false
+	}
+}
+
 func (s *Server) handleAdminOpsHealth(w http.ResponseWriter, r *http.Request) {
 	status := "ok"
 
@@ -343,7 +417,16 @@ func (s *Server) handleAdminOpsHealth(w http.ResponseWriter, r *http.Request) {
 		status = "degraded"
 	}
 
+	// A hung flush loop silently fills the queue and starts dropping events.
+	flushHealth := s.collector.FlushHealth(s.flushStallThreshold)
+	if flushHealth.Stalled {
+		status = "degraded"
+	}
+
 	albumCount, _ := s.albumStore.AlbumCount()
+	coverOK, coverIssues := s.checkAlbumCovers()
+	deadLettered, deadLetterLost := s.collector.DeadLetterCount()
+	cfReady, cfRanges, cfErr := s.cfIPs.Status()
 
 	jsonOK(w, map[string]interface{}{
 		"status":                    status,
@@ -352,9 +435,14 @@ func (s *Server) handleAdminOpsHealth(w http.ResponseWriter, r *http.Request) {
 		"analytics_retention_days":  s.analyticsRetentionDays,
 		"maintenance_interval_secs": int(s.maintenanceInterval.Seconds()),
 		"album_count":               albumCount,
+		"cover_ok":                  coverOK,
+		"cover_issues":              coverIssues,
 		"analytics": map[string]interface{}{
-			"dropped_events":  s.collector.DroppedCount(),
-			"rejected_events": s.collector.RejectedCount(),
+			"dropped_events":   s.collector.DroppedCount(),
+			"rejected_events":  s.collector.RejectedCount(),
+			"dead_lettered":    deadLettered,
+			"dead_letter_lost": deadLetterLost,
+			"flush":            flushHealth,
 		},
 		"database": map[string]interface{}{
 			"ok":    dbErr == nil,
@@ -364,16 +452,174 @@ func (s *Server) handleAdminOpsHealth(w http.ResponseWriter, r *http.Request) {
 			"data_ok":  dataErr == nil,
 			"data_err": errorString(dataErr),
 		},
+		"cloudflare": map[string]interface{}{
+			"ready":  cfReady,
+			"ranges": cfRanges,
+			"error":  cfErr,
+		},
 	})
 }
 
-func (s *Server) handleAdminOpsStats(w http.ResponseWriter, r *http.Request) {
-	sessions, err := queryCount(s.db, "SELECT COUNT(*) FROM sessions")
+// forwardingHeaders are the proxy headers shown by the whoami endpoint.
+// Only the configured client IP header is honoured, and only from a
+// Cloudflare peer.
+var forwardingHeaders = []string{
+	"CF-Connecting-IP",
+	"X-Forwarded-For",
+	"X-Forwarded-Proto",
+	"X-Real-IP",
+	"True-Client-IP",
+	"Forwarded",
+}
+
+// handleAdminOpsWhoami shows how the server derived the caller's client IP,
+// so operators can check their proxy setup.
+func (s *Server) handleAdminOpsWhoami(w http.ResponseWriter, r *http.Request) {
+	res := s.cfIPs.ResolveClientIP(r)
+	headers := make(map[string]string, len(forwardingHeaders)+1)
+	seen := make(map[string]bool, len(forwardingHeaders)+1)
+	for _, name := range append([]string{res.Header}, forwardingHeaders...) {
+		if seen[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		seen[http.CanonicalHeaderKey(name)] = true
+		if v := r.Header.Values(name); len(v) > 0 {
+			headers[name] = strings.Join(v, ", ")
+		}
+	}
+	cfReady, cfRanges, _ := s.cfIPs.Status()
+
+	jsonOK(w, map[string]interface{}{
+		"client_ip":    res.ClientIP,
+		"key":          res.Key,
+		"source":       res.Source,
+		"header":       res.Header,
+		"remote_addr":  res.RemoteAddr,
+		"peer_ip":      res.PeerIP,
+		"peer_trusted": res.PeerTrusted,
+		"scheme":       requestScheme(r),
+		"headers":      headers,
+		"cloudflare": map[string]interface{}{
+			"ready":  cfReady,
+			"ranges": cfRanges,
+		},
+	})
+}
+
+type coverIssue struct {
+	ID    int64  `json:"id"`
+	Slug  string `json:"slug"`
+	Error string `json:"error"`
+}
+
+// checkAlbumCovers reports whether every album has a cover that can be
+// served, listing the albums that do not.
+func (s *Server) checkAlbumCovers() (bool, []coverIssue) {
+	issues := []coverIssue{}
+	allAlbums, err := s.albumStore.ListAlbums()
+	if err != nil {
+		return false, append(issues, coverIssue{Error: err.Error()})
+	}
+	for _, alb := range allAlbums {
+		if err := album.CheckCover(alb.AlbumPath, s.dataPath, alb.ID); err != nil {
+			issues = append(issues, coverIssue{ID: alb.ID, Slug: alb.Slug, Error: err.Error()})
+		}
+	}
+	return len(issues) == 0, issues
+}
+
+type albumCheckResult struct {
+	ID           int64              `json:"id"`
+	Slug         string             `json:"slug"`
+	Title        string             `json:"title"`
+	FilesChecked int                `json:"files_checked"`
+	Issues       []config.FileIssue `json:"issues"`
+	Error        string             `json:"error,omitempty"`
+}
+
+// handleAdminOpsAlbumCheck reports empty, unreadable, or frameless audio files
+// across all albums so bad uploads surface without waiting for playback errors.
+func (s *Server) handleAdminOpsAlbumCheck(w http.ResponseWriter, r *http.Request) {
+	allAlbums, err := s.albumStore.ListAlbums()
 	if err != nil {
+		log.Printf("album check list error: %v", err)
 		jsonError(w, "internal error", http.StatusInternalServerError)
 		return
 	}
-	adminSessions, err := queryCount(s.db, "SELECT COUNT(*) FROM admin_sessions")
+
+	results := make([]albumCheckResult, 0, len(allAlbums))
+	totalIssues := 0
+	for _, alb := range allAlbums {
+		result := albumCheckResult{ID: alb.ID, Slug: alb.Slug, Title: alb.Title, Issues: []config.FileIssue{}}
+		checked, issues, err := config.CheckAlbumFiles(alb.AlbumPath, s.albumExtensions...)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.FilesChecked = checked
+			result.Issues = issues
+			totalIssues += len(issues)
+		}
+		results = append(results, result)
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"albums":       results,
+		"total_issues": totalIssues,
+	})
+}
+
+func (s *Server) handleAdminOpsCollector(w http.ResponseWriter, r *http.Request) {
+	jsonOK(w, s.collector.Stats())
+}
+
+// handleAdminOpsCollectorTune adjusts analytics flush settings live. Changes
+// are not persisted and reset on restart.
+func (s *Server) handleAdminOpsCollectorTune(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		FlushSize       *int   `json:"flush_size,omitempty"`
+		FlushIntervalMS *int64 `json:"flush_interval_ms,omitempty"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.FlushSize == nil && req.FlushIntervalMS == nil {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	// Validate both before applying either so a bad request changes nothing.
+	interval := time.Duration(0)
+	if req.FlushIntervalMS != nil {
+		interval = time.Duration(*req.FlushIntervalMS) * time.Millisecond
+		if interval < analytics.MinFlushInterval || interval > analytics.MaxFlushInterval {
+			jsonError(w, "invalid flush_interval_ms", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FlushSize != nil && (*req.FlushSize < 1 || *req.FlushSize > s.collector.Stats().BufferCapacity) {
+		jsonError(w, "invalid flush_size", http.StatusBadRequest)
+		return
+	}
+
+	if req.FlushSize != nil {
+		if err := s.collector.SetFlushSize(*req.FlushSize); err != nil {
+			jsonError(w, "invalid flush_size", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FlushIntervalMS != nil {
+		if err := s.collector.SetFlushInterval(interval); err != nil {
+			jsonError(w, "invalid flush_interval_ms", http.StatusBadRequest)
+			return
+		}
+	}
+
+	jsonOK(w, s.collector.Stats())
+}
+
+func (s *Server) handleAdminOpsStats(w http.ResponseWriter, r *http.Request) {
+	sessions, adminSessions, err := s.sessions.CountSessions()
 	if err != nil {
 		jsonError(w, "internal error", http.StatusInternalServerError)
 		return
@@ -393,6 +639,12 @@ func (s *Server) handleAdminOpsStats(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	authFailures, err := recentAuthFailures(s.db, authFailureWindowHours)
+	if err != nil {
+		log.Printf("ops stats auth failures error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
 
 	dbStats := s.db.Stats()
 	dbFile := filepath.Join(s.dataPath, "acetate.db")
@@ -416,9 +668,87 @@ func (s *Server) handleAdminOpsStats(w http.ResponseWriter, r *http.Request) {
 		"server": map[string]interface{}{
 			"uptime_seconds": int(time.Since(s.startedAt).Seconds()),
 		},
+		"auth_failures":        authFailures,
+		"conditional_requests": s.conditional.snapshot(),
 	})
 }
 
+// authFailureWindowHours is the look-back for the ops failed-login summary.
+const authFailureWindowHours = 24
+
+// maxAuthFailureUsernames bounds how many attempted usernames are listed.
+const maxAuthFailureUsernames = 50
+
+type authFailureSummary struct {
+	WindowHours       int      `json:"window_hours"`
+	FailedAttempts    int64    `json:"failed_attempts"`
+	DistinctUsernames int64    `json:"distinct_usernames"`
+	Usernames         []string `json:"usernames"`
+}
+
+// recentAuthFailures summarizes rejected admin auth attempts in the window,
+// most-tried usernames first. Server-side errors are not counted.
+func recentAuthFailures(db *sql.DB, windowHours int) (authFailureSummary, error) {
+	summary := authFailureSummary{WindowHours: windowHours, Usernames: make([]string, 0)}
+	since := fmt.Sprintf("-%d hours", windowHours)
+
+	if err := db.QueryRow(
+		"SELECT COUNT(*), COUNT(DISTINCT NULLIF(attempted_username, '')) FROM admin_auth_audit WHERE outcome = 'rejected' AND occurred_at >= datetime('now', ?)",
+		since,
+	).Scan(&summary.FailedAttempts, &summary.DistinctUsernames); err != nil {
+		return summary, fmt.Errorf("count auth failures: %w", err)
+	}
+
+	rows, err := db.Query(
+		"SELECT attempted_username FROM admin_auth_audit WHERE outcome = 'rejected' AND occurred_at >= datetime('now', ?) AND COALESCE(attempted_username, '') != '' GROUP BY attempted_username ORDER BY COUNT(*) DESC, attempted_username ASC LIMIT ?",
+		since, maxAuthFailureUsernames,
+	)
+	if err != nil {
+		return summary, fmt.Errorf("list auth failure usernames: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return summary, fmt.Errorf("scan auth failure username: %w", err)
+		}
+		summary.Usernames = append(summary.Usernames, username)
+	}
+	return summary, rows.Err()
+}
+
+// maintenanceReport is an analytics maintenance result plus the idle admin
+// deactivation done in the same run.
+type maintenanceReport struct {
+	analytics.MaintenanceResult
+	AdminInactiveDays int      `json:"admin_inactive_days"`
+	DeactivatedAdmins []string `json:"deactivated_admins"`
+}
+
+// runMaintenance runs analytics maintenance with retentionDays and the
+// configured audit retention, then deactivates idle admin users. Callers
+// hold maintenanceMu.
+func (s *Server) runMaintenance(now time.Time, retentionDays int) (maintenanceReport, error) {
+	report := maintenanceReport{
+		AdminInactiveDays: max(s.adminInactiveDays, 0),
+		DeactivatedAdmins: []string{},
+	}
+	res, err := analytics.RunMaintenanceWithOptions(s.db, now, analytics.MaintenanceOptions{
+		RetentionDays:      retentionDays,
+		AuditRetentionDays: s.auditRetentionDays,
+	})
+	report.MaintenanceResult = res
+	if err != nil {
+		return report, err
+	}
+	deactivated, err := s.deactivateInactiveAdmins(now)
+	if err != nil {
+		return report, err
+	}
+	report.DeactivatedAdmins = deactivated
+	return report, nil
+}
+
 func (s *Server) handleAdminOpsMaintenance(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		RetentionDays *int `json:"retention_days,omitempty"`
@@ -445,18 +775,27 @@ func (s *Server) handleAdminOpsMaintenance(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Never overlap with the scheduled loop or another manual run.
+	if !s.maintenanceMu.TryLock() {
+		jsonError(w, "maintenance already running", http.StatusConflict)
+		return
+	}
+	defer s.maintenanceMu.Unlock()
+
+	started := time.Now()
 	flushCtx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	_ = s.collector.FlushNow(flushCtx)
 	cancel()
 
-	result, err := analytics.RunMaintenance(s.db, time.Now().UTC(), retentionDays)
+	result, err := s.runMaintenance(time.Now().UTC(), retentionDays)
 	if err != nil {
 		jsonError(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 	jsonOK(w, map[string]interface{}{
-		"status": "ok",
-		"result": result,
+		"status":      "ok",
+		"result":      result,
+		"duration_ms": time.Since(started).Milliseconds(),
 	})
 }
 
@@ -471,48 +810,142 @@ func (s *Server) handleAdminExportEvents(w http.ResponseWriter, r *http.Request)
 	if format == "" {
 		format = "json"
 	}
-	if format != "json" && format != "csv" {
+	if format != "json" && format != "csv" && format != "tsv" {
 		jsonError(w, "bad request", http.StatusBadRequest)
 		return
 	}
 
 	limit := parseOptionalInt(r.URL.Query().Get("limit"), 0)
-	if limit < 0 || limit > 200000 {
+	if limit < 0 || limit > s.exportMaxRows {
 		jsonError(w, "bad request", http.StatusBadRequest)
 		return
 	}
 
+	// ?after=<id> switches to cursor paging ordered by event ID.
+	var afterID *int64
+	if raw := strings.TrimSpace(r.URL.Query().Get("after")); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id < 0 {
+			jsonError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		afterID = &id
+		if limit == 0 {
+			limit = min(defaultExportPageSize, s.exportMaxRows)
+		}
+	}
+
 	flushCtx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	_ = s.collector.FlushNow(flushCtx)
 	cancel()
 
-	events, err := analytics.GetEventsForExport(s.db, filter, limit)
+	// An unbounded export must fit under the cap; refuse rather than
+	// silently truncate.
+	if afterID == nil && limit == 0 {
+		total, err := analytics.CountEventsForExport(s.db, filter)
+		if err != nil {
+			log.Printf("count export events error: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if total > s.exportMaxRows {
+			jsonStatus(w, http.StatusRequestEntityTooLarge, map[string]interface{}{
+				"error":    fmt.Sprintf("export has %d events, over the %d row limit; narrow the filter or page with ?after=0", total, s.exportMaxRows),
+				"total":    total,
+				"max_rows": s.exportMaxRows,
+			})
+			return
+		}
+	}
+
+	var events []analytics.ExportEvent
+	if afterID != nil {
+		events, err = analytics.GetEventsForExportAfter(s.db, filter, *afterID, limit)
+	} else {
+		events, err = analytics.GetEventsForExport(s.db, filter, limit)
+	}
 	if err != nil {
 		jsonError(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	// A full page may have more behind it; a short page is the last one.
+	if afterID != nil && len(events) == limit {
+		w.Header().Set("X-Next-Cursor", strconv.FormatInt(events[len(events)-1].ID, 10))
+	}
 
-	now := time.Now().UTC().Format("20060102-150405")
+	var (
+		payload     []byte
+		contentType string
+	)
 	switch format {
 	case "json":
-		payload, err := analytics.MarshalEventsJSON(events)
-		if err != nil {
-			jsonError(w, "internal error", http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"analytics-events-%s.json\"", now))
-		_, _ = w.Write(payload)
+		payload, err = analytics.MarshalEventsJSON(events)
+		contentType = "application/json"
 	case "csv":
-		payload, err := analytics.MarshalEventsCSV(events)
-		if err != nil {
-			jsonError(w, "internal error", http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"analytics-events-%s.csv\"", now))
+		payload, err = analytics.MarshalEventsCSV(events)
+		contentType = "text/csv; charset=utf-8"
+	case "tsv":
+		payload, err = analytics.MarshalEventsTSV(events)
+		contentType = "text/tab-separated-values; charset=utf-8"
+	}
+	if err != nil {
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC().Format("20060102-150405")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"analytics-events-%s.%s\"", now, format))
+	writeExportPayload(w, r, payload)
+}
+
+// defaultExportPageSize is the page size for cursor-paged exports that do
+// not pass a limit.
+const defaultExportPageSize = 10000
+
+// writeExportPayload writes an export body, gzip-compressed when the client
+// accepts gzip or asks for it with ?compress=true.
+func writeExportPayload(w http.ResponseWriter, r *http.Request, payload []byte) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !wantsGzip(r) {
 		_, _ = w.Write(payload)
+		return
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(payload); err != nil {
+		log.Printf("export gzip write error: %v", err)
+		return
+	}
+	if err := gz.Close(); err != nil {
+		log.Printf("export gzip close error: %v", err)
+	}
+}
+
+func wantsGzip(r *http.Request) bool {
+	if compress, err := strconv.ParseBool(strings.TrimSpace(r.URL.Query().Get("compress"))); err == nil {
+		return compress
+	}
+	return acceptsEncoding(r, "gzip")
+}
+
+// acceptsEncoding reports whether the request's Accept-Encoding lists coding
+// with a non-zero quality.
+func acceptsEncoding(r *http.Request, want string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), want) {
+			continue
+		}
+		// An explicit q=0 means the coding is not acceptable.
+		if raw, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+			return err == nil && q > 0
+		}
+		return true
 	}
+	return false
 }
 
 func (s *Server) handleAdminExportBackup(w http.ResponseWriter, r *http.Request) {
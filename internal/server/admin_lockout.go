@@ -1,6 +1,13 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
@@ -11,11 +18,19 @@ const (
 	adminLoginBackoffMax    = 5 * time.Minute
 	adminLoginResetWindow   = 30 * time.Minute
 	adminLoginEntryTTL      = 2 * time.Hour
+
+	// lockoutWebhookTimeout bounds each best-effort lockout notification.
+	lockoutWebhookTimeout = 5 * time.Second
 )
 
+// adminLoginGuard tracks failed admin logins per key and locks keys out
+// with exponential backoff. Entries live in memory, or in the
+// admin_login_guard table when db is set so every instance sharing the
+// database sees the same lockouts.
 type adminLoginGuard struct {
 	mu      sync.Mutex
 	entries map[string]adminLoginEntry
+	db      *sql.DB
 }
 
 type adminLoginEntry struct {
@@ -30,10 +45,20 @@ func newAdminLoginGuard() *adminLoginGuard {
 	}
 }
 
+// newSharedAdminLoginGuard returns a guard backed by db.
+func newSharedAdminLoginGuard(db *sql.DB) *adminLoginGuard {
+	g := newAdminLoginGuard()
+	g.db = db
+	return g
+}
+
 func (g *adminLoginGuard) allow(key string, now time.Time) (bool, time.Duration) {
 	if key == "" {
 		return true, 0
 	}
+	if g.db != nil {
+		return g.allowShared(key, now)
+	}
 
 	g.mu.Lock()
 	defer g.mu.Unlock()
@@ -58,6 +83,9 @@ func (g *adminLoginGuard) markFailure(key string, now time.Time) time.Duration {
 	if key == "" {
 		return 0
 	}
+	if g.db != nil {
+		return g.markFailureShared(key, now)
+	}
 
 	g.mu.Lock()
 	defer g.mu.Unlock()
@@ -81,16 +109,96 @@ func (g *adminLoginGuard) markFailure(key string, now time.Time) time.Duration {
 	return backoff
 }
 
+// failures returns the current failure count for key.
+func (g *adminLoginGuard) failures(key string) int {
+	if g.db != nil {
+		var failures int
+		if err := g.db.QueryRow("SELECT failures FROM admin_login_guard WHERE key = ?", key).Scan(&failures); err != nil && err != sql.ErrNoRows {
+			log.Printf("admin login guard error: %v", err)
+		}
+		return failures
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.entries[key].failures
+}
+
 func (g *adminLoginGuard) markSuccess(key string) {
 	if key == "" {
 		return
 	}
+	if g.db != nil {
+		if _, err := g.db.Exec("DELETE FROM admin_login_guard WHERE key = ?", key); err != nil {
+			log.Printf("admin login guard error: %v", err)
+		}
+		return
+	}
 
 	g.mu.Lock()
 	delete(g.entries, key)
 	g.mu.Unlock()
 }
 
+// allowShared is allow for a database-backed guard. Lookup errors allow the
+// attempt: the credential check that follows needs the same database.
+func (g *adminLoginGuard) allowShared(key string, now time.Time) (bool, time.Duration) {
+	var lastFailure, lockUntil int64
+	err := g.db.QueryRow("SELECT last_failure, lock_until FROM admin_login_guard WHERE key = ?", key).Scan(&lastFailure, &lockUntil)
+	if err == sql.ErrNoRows {
+		return true, 0
+	}
+	if err != nil {
+		log.Printf("admin login guard error: %v", err)
+		return true, 0
+	}
+	if now.Sub(time.Unix(0, lastFailure)) > adminLoginResetWindow {
+		if _, err := g.db.Exec("DELETE FROM admin_login_guard WHERE key = ? AND last_failure = ?", key, lastFailure); err != nil {
+			log.Printf("admin login guard error: %v", err)
+		}
+		return true, 0
+	}
+	if until := time.Unix(0, lockUntil); now.Before(until) {
+		return false, until.Sub(now)
+	}
+	return true, 0
+}
+
+// markFailureShared is markFailure for a database-backed guard. The failure
+// count is incremented in one statement so concurrent failures on different
+// instances are all counted.
+func (g *adminLoginGuard) markFailureShared(key string, now time.Time) time.Duration {
+	nowNanos := now.UnixNano()
+	if _, err := g.db.Exec("DELETE FROM admin_login_guard WHERE last_failure < ?", now.Add(-adminLoginEntryTTL).UnixNano()); err != nil {
+		log.Printf("admin login guard cleanup error: %v", err)
+	}
+
+	var failures int
+	err := g.db.QueryRow(`
+		INSERT INTO admin_login_guard (key, failures, last_failure, lock_until) VALUES (?, 1, ?, 0)
+		ON CONFLICT(key) DO UPDATE SET
+			failures = CASE WHEN excluded.last_failure - admin_login_guard.last_failure > ? THEN 1 ELSE admin_login_guard.failures + 1 END,
+			lock_until = CASE WHEN excluded.last_failure - admin_login_guard.last_failure > ? THEN 0 ELSE admin_login_guard.lock_until END,
+			last_failure = excluded.last_failure
+		RETURNING failures
+	`, key, nowNanos, int64(adminLoginResetWindow), int64(adminLoginResetWindow)).Scan(&failures)
+	if err != nil {
+		log.Printf("admin login guard error: %v", err)
+		return 0
+	}
+
+	backoff := adminLoginBackoffDuration(failures)
+	if backoff > 0 {
+		if _, err := g.db.Exec(
+			"UPDATE admin_login_guard SET lock_until = MAX(lock_until, ?) WHERE key = ?",
+			now.Add(backoff).UnixNano(), key,
+		); err != nil {
+			log.Printf("admin login guard error: %v", err)
+		}
+	}
+	return backoff
+}
+
 func (g *adminLoginGuard) purgeStale(now time.Time) {
 	for key, entry := range g.entries {
 		if now.Sub(entry.lastFailure) > adminLoginEntryTTL {
@@ -115,3 +223,71 @@ func adminLoginBackoffDuration(failures int) time.Duration {
 	}
 	return backoff
 }
+
+// lockoutEvent is the JSON body posted to the lockout webhook.
+type lockoutEvent struct {
+	Event           string `json:"event"`
+	Source          string `json:"source"`
+	Key             string `json:"key"`
+	Username        string `json:"username"`
+	ClientIP        string `json:"client_ip,omitempty"`
+	Failures        int    `json:"failures"`
+	DurationSeconds int    `json:"duration_seconds"`
+	LockedUntil     string `json:"locked_until"`
+}
+
+// markAdminLoginFailure records a failed admin login against the guard and,
+// when that failure locks the key, notifies the lockout webhook.
+func (s *Server) markAdminLoginFailure(key, username, clientIP, source string) {
+	now := time.Now().UTC()
+	backoff := s.adminLoginGuard.markFailure(key, now)
+	if backoff <= 0 || s.lockoutWebhookURL == "" {
+		return
+	}
+
+	event := lockoutEvent{
+		Event:           "admin_lockout",
+		Source:          source,
+		Key:             key,
+		Username:        strings.ToLower(strings.TrimSpace(username)),
+		ClientIP:        strings.TrimSpace(clientIP),
+		Failures:        s.adminLoginGuard.failures(key),
+		DurationSeconds: int(backoff / time.Second),
+		LockedUntil:     now.Add(backoff).Format(time.RFC3339),
+	}
+	// Anonymous deployments never send client IPs anywhere.
+	if s.sessions.Anonymous() {
+		event.Key = hashForAudit(key)
+		event.ClientIP = ""
+	}
+	go s.sendLockoutWebhook(event)
+}
+
+// sendLockoutWebhook posts event to the lockout webhook. It is best effort:
+// failures are logged and never affect the login response.
+func (s *Server) sendLockoutWebhook(event lockoutEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("lockout webhook marshal error: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lockoutWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.lockoutWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("lockout webhook request error: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("lockout webhook error: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("lockout webhook status: %d", resp.StatusCode)
+	}
+}
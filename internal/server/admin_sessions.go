@@ -0,0 +1,69 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"acetate/internal/auth"
+)
+
+type adminSessionView struct {
+	auth.AdminSessionInfo
+	Current bool `json:"current"`
+}
+
+// handleAdminListSessions lists the calling admin's own sessions, marking
+// the one making the request.
+func (s *Server) handleAdminListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := adminUserIDFromContext(r)
+	if !ok {
+		jsonError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := s.sessions.ListAdminSessions(userID)
+	if err != nil {
+		log.Printf("list admin sessions error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	current := ""
+	if cookie, err := r.Cookie("acetate_admin"); err == nil && cookie.Value != "" {
+		current = auth.AdminSessionHandle(cookie.Value)
+	}
+	views := make([]adminSessionView, 0, len(sessions))
+	for _, sess := range sessions {
+		views = append(views, adminSessionView{AdminSessionInfo: sess, Current: sess.Handle == current})
+	}
+	jsonOK(w, map[string]interface{}{"sessions": views})
+}
+
+// handleAdminRevokeSession signs out one of the calling admin's sessions.
+func (s *Server) handleAdminRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := adminUserIDFromContext(r)
+	if !ok {
+		jsonError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	handle := strings.TrimSpace(chi.URLParam(r, "id"))
+	if handle == "" {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	removed, err := s.sessions.DeleteAdminSessionByHandle(userID, handle)
+	if err != nil {
+		log.Printf("revoke admin session error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+	jsonOK(w, map[string]string{"status": "ok"})
+}
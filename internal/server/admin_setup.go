@@ -54,6 +54,8 @@ func (s *Server) handleAdminSetupBootstrap(w http.ResponseWriter, r *http.Reques
 			jsonError(w, "already configured", http.StatusConflict)
 		case errors.Is(err, errAdminWeakPassword):
 			jsonError(w, "password does not meet policy", http.StatusBadRequest)
+		case errors.Is(err, errAdminBreachedPassword):
+			jsonError(w, "password appears in a known data breach", http.StatusBadRequest)
 		default:
 			// normalizeAdminUsername returns plain errors for format issues.
 			if strings.Contains(strings.ToLower(err.Error()), "username") {
@@ -77,12 +79,16 @@ func (s *Server) handleAdminSetupBootstrap(w http.ResponseWriter, r *http.Reques
 		Name:     "acetate_admin",
 		Value:    sessionID,
 		Path:     "/admin",
-		MaxAge:   3600, // 1 hour
+		MaxAge:   s.sessions.AdminCookieMaxAge(),
 		HttpOnly: true,
 		Secure:   isSecureRequest(r),
 		SameSite: http.SameSiteStrictMode,
 	})
 
+	if _, err := s.sessions.RecordAdminDevice(user.ID, r.UserAgent()); err != nil {
+		log.Printf("admin setup record device error: %v", err)
+	}
+
 	s.recordAdminAuthAttempt(r, user.Username, "success", "bootstrap_setup")
 	jsonCreated(w, map[string]interface{}{
 		"status":                  "ok",
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	adminAPITokenPrefix     = "act_"
+	adminAPITokenBytes      = 32
+	adminAPITokenShownChars = 8
+	maxAdminAPITokenLabel   = 64
+)
+
+const adminAPITokenSelect = `SELECT t.id, t.user_id, COALESCE(u.username, ''), t.label, t.token_prefix, t.created_at,
+	COALESCE(t.last_used_at, ''), COALESCE(t.revoked_at, '')
+FROM admin_api_tokens t LEFT JOIN admin_users u ON u.id = t.user_id`
+
+var (
+	errAdminAPITokenInvalid  = errors.New("invalid api token")
+	errAdminAPITokenNotFound = errors.New("api token not found")
+)
+
+type adminAPITokenView struct {
+	ID         int64  `json:"id"`
+	UserID     int64  `json:"user_id"`
+	Username   string `json:"username"`
+	Label      string `json:"label"`
+	Prefix     string `json:"prefix"`
+	CreatedAt  string `json:"created_at"`
+	LastUsedAt string `json:"last_used_at,omitempty"`
+	RevokedAt  string `json:"revoked_at,omitempty"`
+}
+
+func (s *Server) handleAdminListAPITokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := s.listAdminAPITokens()
+	if err != nil {
+		log.Printf("list api tokens error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, map[string]interface{}{"tokens": tokens})
+}
+
+// handleAdminCreateAPIToken mints a token for the calling admin. The plaintext
+// token is only ever returned in this response.
+func (s *Server) handleAdminCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Label string `json:"label"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	label := trimAndCollapseSpaces(req.Label)
+	if len(label) > maxAdminAPITokenLabel {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := adminUserIDFromContext(r)
+	if !ok {
+		jsonError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	token, view, err := s.createAdminAPIToken(userID, label)
+	if err != nil {
+		log.Printf("create api token error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonCreated(w, map[string]interface{}{
+		"token":   token,
+		"details": view,
+	})
+}
+
+func (s *Server) handleAdminRevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := strconv.ParseInt(strings.TrimSpace(chi.URLParam(r, "id")), 10, 64)
+	if err != nil || tokenID <= 0 {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.revokeAdminAPIToken(tokenID); err != nil {
+		if errors.Is(err, errAdminAPITokenNotFound) {
+			jsonError(w, "not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("revoke api token error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, map[string]string{"status": "ok"})
+}
+
+// adminAPITokenUser resolves a bearer token to its active admin user. Every
+// attempt is audited; on failure it writes the response and returns false.
+func (s *Server) adminAPITokenUser(w http.ResponseWriter, r *http.Request, token string) (adminUserRecord, bool) {
+	clientIP := s.cfIPs.GetClientIP(r)
+	if !s.adminAPILimiter.Allow("admin-token:" + clientIP) {
+		s.recordAdminAuthAttempt(r, "", "rejected", "token_rate_limited")
+		jsonError(w, "rate limited", http.StatusTooManyRequests)
+		return adminUserRecord{}, false
+	}
+
+	user, err := s.authenticateAdminAPIToken(token)
+	if err != nil {
+		if errors.Is(err, errAdminAPITokenInvalid) {
+			s.recordAdminAuthAttempt(r, "", "rejected", "token_invalid")
+			jsonError(w, "unauthorized", http.StatusUnauthorized)
+			return adminUserRecord{}, false
+		}
+		log.Printf("admin api token error: %v", err)
+		s.recordAdminAuthAttempt(r, "", "error", "token_query_failed")
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return adminUserRecord{}, false
+	}
+	s.recordAdminAuthAttempt(r, user.Username, "success", "api_token")
+	return user, true
+}
+
+func (s *Server) createAdminAPIToken(userID int64, label string) (string, adminAPITokenView, error) {
+	b := make([]byte, adminAPITokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", adminAPITokenView{}, fmt.Errorf("generate api token: %w", err)
+	}
+	token := adminAPITokenPrefix + hex.EncodeToString(b)
+	// Keep a short prefix so listings can identify a token without storing it.
+	prefix := token[:len(adminAPITokenPrefix)+adminAPITokenShownChars]
+
+	res, err := s.db.Exec(
+		"INSERT INTO admin_api_tokens (user_id, label, token_hash, token_prefix, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, label, hashAdminAPIToken(token), prefix, time.Now().UTC(),
+	)
+	if err != nil {
+		return "", adminAPITokenView{}, fmt.Errorf("insert api token: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return "", adminAPITokenView{}, fmt.Errorf("api token id: %w", err)
+	}
+
+	var view adminAPITokenView
+	if err := scanAdminAPIToken(s.db.QueryRow(adminAPITokenSelect+" WHERE t.id = ?", id), &view); err != nil {
+		return "", adminAPITokenView{}, fmt.Errorf("query api token: %w", err)
+	}
+	return token, view, nil
+}
+
+func (s *Server) listAdminAPITokens() ([]adminAPITokenView, error) {
+	rows, err := s.db.Query(adminAPITokenSelect + " ORDER BY t.id")
+	if err != nil {
+		return nil, fmt.Errorf("query api tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := make([]adminAPITokenView, 0)
+	for rows.Next() {
+		var t adminAPITokenView
+		if err := scanAdminAPIToken(rows, &t); err != nil {
+			return nil, fmt.Errorf("scan api token: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate api tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func scanAdminAPIToken(row interface{ Scan(...interface{}) error }, t *adminAPITokenView) error {
+	return row.Scan(&t.ID, &t.UserID, &t.Username, &t.Label, &t.Prefix, &t.CreatedAt, &t.LastUsedAt, &t.RevokedAt)
+}
+
+func (s *Server) revokeAdminAPIToken(tokenID int64) error {
+	res, err := s.db.Exec(
+		"UPDATE admin_api_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), tokenID,
+	)
+	if err != nil {
+		return fmt.Errorf("revoke api token: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errAdminAPITokenNotFound
+	}
+	return nil
+}
+
+// authenticateAdminAPIToken looks up an unrevoked token belonging to an active
+// admin and records its use.
+func (s *Server) authenticateAdminAPIToken(token string) (adminUserRecord, error) {
+	user := adminUserRecord{}
+	token = strings.TrimSpace(token)
+	if !strings.HasPrefix(token, adminAPITokenPrefix) {
+		return user, errAdminAPITokenInvalid
+	}
+
+	var (
+		tokenID      int64
+		requireReset int
+	)
+	err := s.db.QueryRow(
+		`SELECT t.id, u.id, u.username, u.require_password_reset
+		FROM admin_api_tokens t JOIN admin_users u ON u.id = t.user_id
+		WHERE t.token_hash = ? AND t.revoked_at IS NULL AND u.is_active = 1`,
+		hashAdminAPIToken(token),
+	).Scan(&tokenID, &user.ID, &user.Username, &requireReset)
+	if err == sql.ErrNoRows {
+		return adminUserRecord{}, errAdminAPITokenInvalid
+	}
+	if err != nil {
+		return adminUserRecord{}, fmt.Errorf("query api token: %w", err)
+	}
+	user.RequirePasswordReset = requireReset == 1
+
+	_, _ = s.db.Exec("UPDATE admin_api_tokens SET last_used_at = ? WHERE id = ?", time.Now().UTC(), tokenID)
+	return user, nil
+}
+
+// hashAdminAPIToken stores tokens as unsalted SHA-256: they carry 256 bits of
+// randomness, so a slow hash adds nothing.
+func hashAdminAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerToken returns the token from an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len("Bearer "):])
+	return token, token != ""
+}
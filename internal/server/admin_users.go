@@ -21,6 +21,10 @@ var (
 	errAdminCannotDeactivateSelf = errors.New("cannot deactivate your own account")
 	errAdminLastActiveAdmin      = errors.New("at least one active admin is required")
 	errAdminFounderProtected     = errors.New("the original admin account cannot be deactivated")
+	errAdminCannotLogoutSelf     = errors.New("use DELETE /admin/api/auth to sign yourself out")
+	// errAdminUserConflict means the account changed since the caller read
+	// it; the caller should reload and retry.
+	errAdminUserConflict = errors.New("admin user was modified by another request")
 )
 
 type adminUserView struct {
@@ -65,6 +69,8 @@ func (s *Server) handleAdminCreateUser(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case errors.Is(err, errAdminWeakPassword):
 			jsonError(w, "password does not meet policy", http.StatusBadRequest)
+		case errors.Is(err, errAdminBreachedPassword):
+			jsonError(w, "password appears in a known data breach", http.StatusBadRequest)
 		case errors.Is(err, errAdminUserExists):
 			jsonError(w, "username already exists", http.StatusConflict)
 		default:
@@ -92,6 +98,9 @@ func (s *Server) handleAdminUpdateUser(w http.ResponseWriter, r *http.Request) {
 		Username             *string `json:"username,omitempty"`
 		IsActive             *bool   `json:"is_active,omitempty"`
 		RequirePasswordReset *bool   `json:"require_password_reset,omitempty"`
+		// ExpectedUpdatedAt is the updated_at the client last saw; when set,
+		// the update is refused if the account has changed since.
+		ExpectedUpdatedAt string `json:"expected_updated_at,omitempty"`
 	}
 	if err := decodeJSONBody(r, &req); err != nil {
 		jsonError(w, "bad request", http.StatusBadRequest)
@@ -104,11 +113,13 @@ func (s *Server) handleAdminUpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := s.updateAdminUser(actorID, targetID, req.Username, req.IsActive, req.RequirePasswordReset)
+	user, err := s.updateAdminUser(actorID, targetID, req.Username, req.IsActive, req.RequirePasswordReset, req.ExpectedUpdatedAt)
 	if err != nil {
 		switch {
 		case errors.Is(err, errAdminUserNotFound):
 			jsonError(w, "not found", http.StatusNotFound)
+		case errors.Is(err, errAdminUserConflict):
+			jsonError(w, err.Error(), http.StatusConflict)
 		case errors.Is(err, errAdminUserExists):
 			jsonError(w, "username already exists", http.StatusConflict)
 		case errors.Is(err, errAdminInvalidUserUpdate),
@@ -130,6 +141,41 @@ func (s *Server) handleAdminUpdateUser(w http.ResponseWriter, r *http.Request) {
 	jsonOK(w, user)
 }
 
+// handleAdminLogoutUser revokes every session of another admin user without
+// deactivating the account; they can sign in again straight away.
+func (s *Server) handleAdminLogoutUser(w http.ResponseWriter, r *http.Request) {
+	targetID, err := strconv.ParseInt(strings.TrimSpace(chi.URLParam(r, "id")), 10, 64)
+	if err != nil || targetID <= 0 {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	actorID, ok := adminUserIDFromContext(r)
+	if !ok {
+		jsonError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if actorID == targetID {
+		jsonError(w, errAdminCannotLogoutSelf.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.getAdminUserViewByID(targetID); err != nil {
+		if errors.Is(err, errAdminUserNotFound) {
+			jsonError(w, "not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("logout admin user error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.sessions.DeleteAdminSessionsForUser(targetID); err != nil {
+		log.Printf("logout admin user error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, map[string]string{"status": "ok"})
+}
+
 func (s *Server) listAdminUsers() ([]adminUserView, error) {
 	rows, err := s.db.Query(
 		"SELECT id, username, is_active, CASE WHEN id = (SELECT MIN(id) FROM admin_users) THEN 1 ELSE 0 END AS is_founder, require_password_reset, created_at, updated_at, COALESCE(last_login_at, '') FROM admin_users ORDER BY username ASC",
@@ -171,45 +217,67 @@ func (s *Server) listAdminUsers() ([]adminUserView, error) {
 }
 
 func (s *Server) createAdminUser(username, password string, requirePasswordReset bool) (adminUserView, error) {
-	user := adminUserView{}
+	userID, err := s.insertAdminUser(s.db, username, password, requirePasswordReset)
+	if err != nil {
+		return adminUserView{}, err
+	}
+	return s.getAdminUserViewByID(userID)
+}
+
+// sqlExecer is satisfied by both *sql.DB and *sql.Tx.
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
 
+// insertAdminUser validates and stores a new admin user through db, which
+// may be a transaction, and returns its id.
+func (s *Server) insertAdminUser(db sqlExecer, username, password string, requirePasswordReset bool) (int64, error) {
 	normalizedUsername, err := normalizeAdminUsername(username)
 	if err != nil {
-		return user, err
+		return 0, err
 	}
-	if err := validateAdminPassword(password); err != nil {
-		return user, err
+	if err := s.validateNewAdminPassword(password); err != nil {
+		return 0, err
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(strings.TrimSpace(password)), bcrypt.DefaultCost)
 	if err != nil {
-		return user, fmt.Errorf("hash admin password: %w", err)
+		return 0, fmt.Errorf("hash admin password: %w", err)
 	}
+	return insertAdminUserHash(db, normalizedUsername, string(hash), requirePasswordReset)
+}
 
+// insertAdminUserHash inserts an admin user whose username is already
+// normalized and whose password is already validated and hashed.
+func insertAdminUserHash(db sqlExecer, normalizedUsername, hash string, requirePasswordReset bool) (int64, error) {
 	now := time.Now().UTC()
-	res, err := s.db.Exec(
+	res, err := db.Exec(
 		"INSERT INTO admin_users (username, password_hash, is_active, require_password_reset, created_at, updated_at) VALUES (?, ?, 1, ?, ?, ?)",
 		normalizedUsername,
-		string(hash),
+		hash,
 		boolToInt(requirePasswordReset),
 		now,
 		now,
 	)
 	if err != nil {
 		if strings.Contains(strings.ToLower(err.Error()), "unique") {
-			return user, errAdminUserExists
+			return 0, errAdminUserExists
 		}
-		return user, fmt.Errorf("create admin user: %w", err)
+		return 0, fmt.Errorf("create admin user: %w", err)
 	}
 
 	userID, err := res.LastInsertId()
 	if err != nil {
-		return user, fmt.Errorf("admin user id: %w", err)
+		return 0, fmt.Errorf("admin user id: %w", err)
 	}
-	return s.getAdminUserViewByID(userID)
+	return userID, nil
 }
 
-func (s *Server) updateAdminUser(actorID, targetID int64, username *string, isActive, requirePasswordReset *bool) (adminUserView, error) {
+// updateAdminUser applies a partial update. A non-empty expectedUpdatedAt
+// must match the stored updated_at, and the write itself is conditional on
+// the value read, so a concurrent change fails with errAdminUserConflict
+// instead of being overwritten.
+func (s *Server) updateAdminUser(actorID, targetID int64, username *string, isActive, requirePasswordReset *bool, expectedUpdatedAt string) (adminUserView, error) {
 	user := adminUserView{}
 	if actorID <= 0 || targetID <= 0 {
 		return user, errAdminInvalidUserUpdate
@@ -225,19 +293,24 @@ func (s *Server) updateAdminUser(actorID, targetID int64, username *string, isAc
 	defer tx.Rollback()
 
 	var (
-		currentUsername string
-		currentActive   int
-		currentReset    int
+		currentUsername  string
+		currentActive    int
+		currentReset     int
+		currentUpdatedAt string
+		rawUpdatedAt     string // stored form, for the conditional write
 	)
 	if err := tx.QueryRow(
-		"SELECT username, is_active, require_password_reset FROM admin_users WHERE id = ?",
+		"SELECT username, is_active, require_password_reset, updated_at, CAST(updated_at AS TEXT) FROM admin_users WHERE id = ?",
 		targetID,
-	).Scan(&currentUsername, &currentActive, &currentReset); err != nil {
+	).Scan(&currentUsername, &currentActive, &currentReset, &currentUpdatedAt, &rawUpdatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return user, errAdminUserNotFound
 		}
 		return user, fmt.Errorf("query admin user for update: %w", err)
 	}
+	if expectedUpdatedAt = strings.TrimSpace(expectedUpdatedAt); expectedUpdatedAt != "" && expectedUpdatedAt != currentUpdatedAt {
+		return user, errAdminUserConflict
+	}
 
 	nextUsername := currentUsername
 	if username != nil {
@@ -294,17 +367,21 @@ func (s *Server) updateAdminUser(actorID, targetID int64, username *string, isAc
 		}
 	}
 
-	_, err = tx.Exec(
-		"UPDATE admin_users SET username = ?, is_active = ?, require_password_reset = ?, updated_at = ? WHERE id = ?",
+	res, err := tx.Exec(
+		"UPDATE admin_users SET username = ?, is_active = ?, require_password_reset = ?, updated_at = ? WHERE id = ? AND CAST(updated_at AS TEXT) = ?",
 		nextUsername,
 		boolToInt(nextActive),
 		boolToInt(nextReset),
 		time.Now().UTC(),
 		targetID,
+		rawUpdatedAt,
 	)
 	if err != nil {
 		return user, fmt.Errorf("update admin user: %w", err)
 	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return user, errAdminUserConflict
+	}
 
 	if err := tx.Commit(); err != nil {
 		return user, fmt.Errorf("commit admin user update: %w", err)
@@ -367,3 +444,98 @@ func boolToInt(v bool) int {
 	}
 	return 0
 }
+
+// deactivateInactiveAdmins deactivates admin users who have not logged in
+// (or, if they never have, were created) within s.adminInactiveDays and
+// revokes their sessions. The original (lowest id) admin is never touched,
+// matching the rule for manual updates, and at least one active admin always
+// remains: if every active admin is idle, the most recently seen one is kept.
+func (s *Server) deactivateInactiveAdmins(now time.Time) ([]string, error) {
+	deactivated := []string{}
+	if s.adminInactiveDays <= 0 {
+		return deactivated, nil
+	}
+	cutoff := now.UTC().AddDate(0, 0, -s.adminInactiveDays)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return deactivated, fmt.Errorf("begin admin inactivity check: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, username, last_login_at, created_at,
+			CASE WHEN id = (SELECT MIN(id) FROM admin_users) THEN 1 ELSE 0 END
+		FROM admin_users
+		WHERE is_active = 1
+		ORDER BY id
+	`)
+	if err != nil {
+		return deactivated, fmt.Errorf("query active admins: %w", err)
+	}
+	type candidate struct {
+		id       int64
+		username string
+		lastSeen time.Time
+	}
+	var (
+		idle        []candidate
+		activeCount int
+	)
+	for rows.Next() {
+		var (
+			c         candidate
+			lastLogin sql.NullTime
+			createdAt time.Time
+			isFounder int
+		)
+		if err := rows.Scan(&c.id, &c.username, &lastLogin, &createdAt, &isFounder); err != nil {
+			rows.Close()
+			return deactivated, fmt.Errorf("scan active admin: %w", err)
+		}
+		activeCount++
+		c.lastSeen = createdAt
+		if lastLogin.Valid {
+			c.lastSeen = lastLogin.Time
+		}
+		if isFounder == 0 && c.lastSeen.Before(cutoff) {
+			idle = append(idle, c)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return deactivated, fmt.Errorf("iterate active admins: %w", err)
+	}
+	if len(idle) == 0 {
+		return deactivated, nil
+	}
+	if len(idle) >= activeCount {
+		// Keep the most recently seen admin active.
+		keep := 0
+		for i, c := range idle {
+			if c.lastSeen.After(idle[keep].lastSeen) {
+				keep = i
+			}
+		}
+		idle = append(idle[:keep], idle[keep+1:]...)
+	}
+
+	for _, c := range idle {
+		if _, err := tx.Exec("UPDATE admin_users SET is_active = 0, updated_at = ? WHERE id = ?", now.UTC(), c.id); err != nil {
+			return []string{}, fmt.Errorf("deactivate admin %q: %w", c.username, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return []string{}, fmt.Errorf("commit admin deactivation: %w", err)
+	}
+
+	// Sessions go through the session store so any backend and its cache
+	// drop them, as for a manual deactivation.
+	for _, c := range idle {
+		if err := s.sessions.DeleteAdminSessionsForUser(c.id); err != nil {
+			log.Printf("revoke sessions for deactivated admin %q: %v", c.username, err)
+		}
+		deactivated = append(deactivated, c.username)
+	}
+	return deactivated, nil
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Bulk admin user creation limits.
+const (
+	adminBulkUsersMax          = 50
+	adminBulkUsersMaxBodyBytes = 64 << 10
+	// adminTempPasswordLen is the generated password length when the
+	// policy minimum is shorter.
+	adminTempPasswordLen = 20
+)
+
+// adminTempPasswordAlphabet mixes cases, digits, and symbols so generated
+// passwords satisfy any PasswordPolicy setting.
+const adminTempPasswordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789-_.!@#%+="
+
+type adminBulkUserInput struct {
+	Username string `json:"username"`
+	// Role is accepted for forward compatibility; every admin currently has
+	// full access, so only "admin" (or empty) is allowed.
+	Role                 string `json:"role,omitempty"`
+	RequirePasswordReset *bool  `json:"require_password_reset,omitempty"`
+}
+
+type adminBulkUserResult struct {
+	Username             string `json:"username"`
+	ID                   int64  `json:"id,omitempty"`
+	RequirePasswordReset bool   `json:"require_password_reset"`
+	TemporaryPassword    string `json:"temporary_password,omitempty"`
+	Error                string `json:"error,omitempty"`
+}
+
+// handleAdminBulkCreateUsers creates several admin users in one
+// transaction, each with a generated temporary password that is returned
+// once and never stored in clear. If any entry is invalid or collides with
+// an existing username, nothing is created and the per-user results say
+// which entries failed.
+func (s *Server) handleAdminBulkCreateUsers(w http.ResponseWriter, r *http.Request) {
+	var req []adminBulkUserInput
+	if err := decodeJSONBody(r, &req); err != nil {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if len(req) == 0 || len(req) > adminBulkUsersMax {
+		jsonError(w, fmt.Sprintf("between 1 and %d users required", adminBulkUsersMax), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]adminBulkUserResult, len(req))
+	seen := make(map[string]int, len(req))
+	failed := false
+	for i, in := range req {
+		results[i] = adminBulkUserResult{Username: strings.TrimSpace(in.Username), RequirePasswordReset: true}
+		if in.RequirePasswordReset != nil {
+			results[i].RequirePasswordReset = *in.RequirePasswordReset
+		}
+		if role := strings.ToLower(strings.TrimSpace(in.Role)); role != "" && role != "admin" {
+			results[i].Error = "unsupported role"
+			failed = true
+			continue
+		}
+		if results[i].Username == "" {
+			results[i].Error = "invalid username"
+			failed = true
+			continue
+		}
+		normalized, err := normalizeAdminUsername(results[i].Username)
+		if err != nil {
+			results[i].Error = "invalid username"
+			failed = true
+			continue
+		}
+		results[i].Username = normalized
+		if first, dup := seen[normalized]; dup {
+			results[i].Error = fmt.Sprintf("duplicate of entry %d", first)
+			failed = true
+			continue
+		}
+		seen[normalized] = i
+	}
+	if failed {
+		jsonStatus(w, http.StatusBadRequest, map[string]interface{}{"error": "invalid users", "results": results})
+		return
+	}
+
+	// Passwords are generated and hashed before the transaction opens, so
+	// the write lock is held only for the inserts. Generated passwords
+	// already meet the policy and are random, so the breach check is skipped.
+	hashes := make([]string, len(results))
+	for i := range results {
+		password, err := s.generateTemporaryAdminPassword()
+		if err != nil {
+			log.Printf("bulk create admin users error: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			log.Printf("bulk create admin users error: hash admin password: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		results[i].TemporaryPassword = password
+		hashes[i] = string(hash)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("bulk create admin users error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	for i := range results {
+		id, err := insertAdminUserHash(tx, results[i].Username, hashes[i], results[i].RequirePasswordReset)
+		if errors.Is(err, errAdminUserExists) {
+			results[i].Error = "username already exists"
+			failed = true
+			continue
+		}
+		if err != nil {
+			log.Printf("bulk create admin users error: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		results[i].ID = id
+	}
+	if failed {
+		// Nothing was committed, so no password from this batch is valid.
+		for i := range results {
+			results[i].ID = 0
+			results[i].TemporaryPassword = ""
+		}
+		jsonStatus(w, http.StatusConflict, map[string]interface{}{"error": "username already exists", "results": results})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("bulk create admin users commit error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonStatus(w, http.StatusCreated, map[string]interface{}{"users": results})
+}
+
+// generateTemporaryAdminPassword returns a random password that passes the
+// configured policy.
+func (s *Server) generateTemporaryAdminPassword() (string, error) {
+	length := max(s.passwordPolicy.withDefaults().MinLength, adminTempPasswordLen)
+	alphabetLen := big.NewInt(int64(len(adminTempPasswordAlphabet)))
+	buf := make([]byte, length)
+	// A random draw occasionally misses a character class; draw again.
+	for attempt := 0; attempt < 10; attempt++ {
+		for i := range buf {
+			n, err := rand.Int(rand.Reader, alphabetLen)
+			if err != nil {
+				return "", fmt.Errorf("generate temporary password: %w", err)
+			}
+			buf[i] = adminTempPasswordAlphabet[n.Int64()]
+		}
+		if s.passwordPolicy.validate(string(buf)) == nil {
+			return string(buf), nil
+		}
+	}
+	return "", errors.New("generate temporary password: no candidate met the policy")
+}
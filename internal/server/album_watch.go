@@ -0,0 +1,156 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"acetate/internal/album"
+	"acetate/internal/albums"
+	"acetate/internal/config"
+)
+
+// albumWatchState is what the album watcher remembers about one album folder
+// between polls.
+type albumWatchState struct {
+	signature string
+	settled   bool
+}
+
+// startAlbumWatcher polls every album folder at s.albumWatchInterval and
+// auto-reconciles changes. It shares the maintenance loop's stop channel.
+func (s *Server) startAlbumWatcher() {
+	s.maintenanceWG.Add(1)
+	go func() {
+		defer s.maintenanceWG.Done()
+
+		ticker := time.NewTicker(s.albumWatchInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.albumWatchTick()
+			case <-s.maintenanceDone:
+				return
+			}
+		}
+	}()
+}
+
+// albumWatchTick scans every album folder once. A change is reconciled only
+// after the folder looks the same on two consecutive polls, so files that are
+// still being copied in are not picked up half-written.
+func (s *Server) albumWatchTick() {
+	allAlbums, err := s.albumStore.ListAlbums()
+	if err != nil {
+		log.Printf("album watcher: list albums error: %v", err)
+		return
+	}
+
+	seen := make(map[int64]struct{}, len(allAlbums))
+	for _, alb := range allAlbums {
+		seen[alb.ID] = struct{}{}
+		diskTracks, err := config.ScanAlbumTracks(alb.AlbumPath, s.albumExtensions...)
+		if err != nil {
+			log.Printf("album watcher: scan %q error: %v", alb.Slug, err)
+			continue
+		}
+
+		signature := albumDiskSignature(alb.AlbumPath, diskTracks, s.albumExtensions)
+		state, ok := s.albumWatch[alb.ID]
+		if !ok || state.signature != signature {
+			s.albumWatch[alb.ID] = &albumWatchState{signature: signature}
+			continue
+		}
+		if state.settled {
+			continue
+		}
+		state.settled = true
+		if err := s.autoReconcileAlbum(alb, diskTracks); err != nil {
+			log.Printf("album watcher: reconcile %q error: %v", alb.Slug, err)
+		}
+	}
+	for id := range s.albumWatch {
+		if _, ok := seen[id]; !ok {
+			delete(s.albumWatch, id)
+		}
+	}
+}
+
+// autoReconcileAlbum applies a non-destructive reconcile: new files are added
+// and empty titles filled, but tracks whose files disappeared are kept and
+// only logged.
+func (s *Server) autoReconcileAlbum(alb albums.Album, diskTracks []config.Track) error {
+	dbTracks, err := s.albumStore.GetTracks(alb.ID)
+	if err != nil {
+		return err
+	}
+	current := albumTracksToConfigTracks(dbTracks)
+	updated, applied := applyReconcile(current, diskTracks, reconcileTitlesFillEmpty, true)
+
+	if report := buildReconcileReport(updated, diskTracks); len(report.ConfigOnly) > 0 {
+		stems := make([]string, 0, len(report.ConfigOnly))
+		for _, t := range report.ConfigOnly {
+			stems = append(stems, t.Stem)
+		}
+		log.Printf("album watcher: %q has %d track(s) with no audio file (kept): %s", alb.Slug, len(stems), strings.Join(stems, ", "))
+	}
+
+	if tracksEqual(current, updated) {
+		return nil
+	}
+
+	newTracks := make([]albums.Track, len(updated))
+	for i, ct := range updated {
+		newTracks[i] = albums.Track{
+			Stem:            ct.Stem,
+			Title:           ct.Title,
+			DisplayIndex:    ct.DisplayIndex,
+			SortOrder:       i,
+			Subdir:          ct.Subdir,
+			AllowDownload:   ct.AllowDownload,
+			DurationSeconds: ct.DurationSeconds,
+			ModTime:         ct.ModTime,
+		}
+	}
+	if err := s.albumStore.SetTracks(alb.ID, newTracks); err != nil {
+		return err
+	}
+	log.Printf("album watcher: %q added=%d titles_updated=%d", alb.Slug, applied.Added, applied.TitlesUpdated)
+	return nil
+}
+
+// albumDiskSignature summarizes a folder scan so polls can be compared. Each
+// audio file's size and modtime are included, so a file still being written
+// keeps changing the signature even though its stem stays the same.
+func albumDiskSignature(albumPath string, tracks []config.Track, extensions []string) string {
+	var b strings.Builder
+	for _, t := range tracks {
+		b.WriteString(t.Subdir)
+		b.WriteByte('/')
+		b.WriteString(t.Stem)
+		b.WriteByte('\x00')
+		b.WriteString(t.Title)
+		if dir, ok := album.TrackDir(albumPath, t.Subdir); ok {
+			if _, info, ok := album.FindTrackFile(dir, t.Stem, extensions...); ok {
+				fmt.Fprintf(&b, "\x00%d\x00%d", info.Size(), info.ModTime().UnixNano())
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func tracksEqual(a, b []config.Track) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+)
+
+// ipConcurrency caps in-flight requests per client IP so one client cannot
+// tie up every connection with slow or long-running requests.
+type ipConcurrency struct {
+	mu       sync.Mutex
+	limit    int
+	inflight map[string]int
+}
+
+// newIPConcurrency returns a limiter allowing limit in-flight requests per
+// IP. A non-positive limit disables limiting and returns nil.
+func newIPConcurrency(limit int) *ipConcurrency {
+	if limit <= 0 {
+		return nil
+	}
+	return &ipConcurrency{limit: limit, inflight: make(map[string]int)}
+}
+
+// acquire takes a slot for ip, reporting false when it already has limit
+// requests in flight. Every successful acquire must be paired with release.
+func (c *ipConcurrency) acquire(ip string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inflight[ip] >= c.limit {
+		return false
+	}
+	c.inflight[ip]++
+	return true
+}
+
+func (c *ipConcurrency) release(ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inflight[ip] <= 1 {
+		delete(c.inflight, ip)
+		return
+	}
+	c.inflight[ip]--
+}
+
+// limitConcurrency rejects requests with 429 while the client IP already
+// has the configured number of requests in flight. The readiness probe is
+// exempt so health checks keep working while a client is being limited.
+func (s *Server) limitConcurrency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.ipConcurrency == nil || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ip := s.cfIPs.GetClientIP(r)
+		if !s.ipConcurrency.acquire(ip) {
+			w.Header().Set("Retry-After", "1")
+			jsonError(w, "too many concurrent requests", http.StatusTooManyRequests)
+			return
+		}
+		defer s.ipConcurrency.release(ip)
+		next.ServeHTTP(w, r)
+	})
+}
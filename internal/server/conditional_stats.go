@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Endpoint classes whose conditional-request outcomes are counted.
+var conditionalClasses = []string{"cover", "stream", "tracks"}
+
+// conditionalCounter tallies how often clients revalidated a cached copy
+// (304) versus downloaded the body (200 or 206).
+type conditionalCounter struct {
+	notModified atomic.Int64
+	served      atomic.Int64
+}
+
+type conditionalStats map[string]*conditionalCounter
+
+func newConditionalStats() conditionalStats {
+	stats := make(conditionalStats, len(conditionalClasses))
+	for _, class := range conditionalClasses {
+		stats[class] = &conditionalCounter{}
+	}
+	return stats
+}
+
+func (c conditionalStats) observe(class string, status int) {
+	counter := c[class]
+	if counter == nil {
+		return
+	}
+	switch status {
+	case http.StatusNotModified:
+		counter.notModified.Add(1)
+	case http.StatusOK, http.StatusPartialContent:
+		counter.served.Add(1)
+	}
+}
+
+// snapshot reports per-class counts and the share of requests answered 304.
+func (c conditionalStats) snapshot() map[string]interface{} {
+	out := make(map[string]interface{}, len(c))
+	for class, counter := range c {
+		notModified, served := counter.notModified.Load(), counter.served.Load()
+		var hitRate float64
+		if total := notModified + served; total > 0 {
+			hitRate = float64(notModified) / float64(total)
+		}
+		out[class] = map[string]interface{}{
+			"not_modified": notModified,
+			"served":       served,
+			"hit_rate":     hitRate,
+		}
+	}
+	return out
+}
+
+// countConditional records the response status of requests in one endpoint
+// class. Errors are not counted either way.
+func (s *Server) countConditional(class string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wrapped := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(wrapped, r)
+			s.conditional.observe(class, wrapped.status)
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cspReportPath is where browsers send Content-Security-Policy violation
+// reports when CSP_REPORT_ENABLED is set.
+const cspReportPath = "/api/csp-report"
+
+// cspReportGroup names the Reporting-Endpoints entry the CSP report-to
+// directive refers to.
+const cspReportGroup = "csp-endpoint"
+
+// Limits for violation reports. Reports are unauthenticated, so both the
+// request size and the number kept in memory are capped.
+const (
+	cspReportMaxBodyBytes = 16 << 10
+	cspReportBufferSize   = 100
+	cspReportFieldMax     = 512
+)
+
+// cspViolation is the subset of a violation report worth keeping.
+type cspViolation struct {
+	ReceivedAt         time.Time `json:"received_at"`
+	DocumentURI        string    `json:"document_uri"`
+	BlockedURI         string    `json:"blocked_uri"`
+	EffectiveDirective string    `json:"effective_directive"`
+	Disposition        string    `json:"disposition,omitempty"`
+	SourceFile         string    `json:"source_file,omitempty"`
+	LineNumber         int       `json:"line_number,omitempty"`
+}
+
+// cspReportLog keeps the most recent violation reports in a fixed-size ring.
+type cspReportLog struct {
+	mu      sync.Mutex
+	reports []cspViolation
+	next    int
+	total   int64
+}
+
+func newCSPReportLog() *cspReportLog {
+	return &cspReportLog{reports: make([]cspViolation, 0, cspReportBufferSize)}
+}
+
+func (l *cspReportLog) add(v cspViolation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total++
+	if len(l.reports) < cspReportBufferSize {
+		l.reports = append(l.reports, v)
+		return
+	}
+	l.reports[l.next] = v
+	l.next = (l.next + 1) % cspReportBufferSize
+}
+
+// snapshot returns the stored reports, newest first, and the number
+// received since startup.
+func (l *cspReportLog) snapshot() ([]cspViolation, int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]cspViolation, 0, len(l.reports))
+	for i := len(l.reports) - 1; i >= 0; i-- {
+		out = append(out, l.reports[(l.next+i)%len(l.reports)])
+	}
+	return out, l.total
+}
+
+// cspReportBody covers both report formats: the legacy report-uri body
+// (hyphenated keys under "csp-report") and the Reporting API body
+// (camelCase keys under "body").
+type cspReportBody struct {
+	DocumentURI        string `json:"document-uri"`
+	BlockedURI         string `json:"blocked-uri"`
+	EffectiveDirective string `json:"effective-directive"`
+	ViolatedDirective  string `json:"violated-directive"`
+	Disposition        string `json:"disposition"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+
+	DocumentURL          string `json:"documentURL"`
+	BlockedURL           string `json:"blockedURL"`
+	EffectiveDirectiveV2 string `json:"effectiveDirective"`
+	SourceFileV2         string `json:"sourceFile"`
+	LineNumberV2         int    `json:"lineNumber"`
+}
+
+func (b cspReportBody) violation(now time.Time) cspViolation {
+	v := cspViolation{
+		ReceivedAt:         now,
+		DocumentURI:        firstNonEmpty(b.DocumentURI, b.DocumentURL),
+		BlockedURI:         firstNonEmpty(b.BlockedURI, b.BlockedURL),
+		EffectiveDirective: firstNonEmpty(b.EffectiveDirective, b.EffectiveDirectiveV2, b.ViolatedDirective),
+		Disposition:        b.Disposition,
+		SourceFile:         firstNonEmpty(b.SourceFile, b.SourceFileV2),
+		LineNumber:         b.LineNumber,
+	}
+	if v.LineNumber == 0 {
+		v.LineNumber = b.LineNumberV2
+	}
+	v.DocumentURI = truncateReportField(v.DocumentURI)
+	v.BlockedURI = truncateReportField(v.BlockedURI)
+	v.EffectiveDirective = truncateReportField(v.EffectiveDirective)
+	v.Disposition = truncateReportField(v.Disposition)
+	v.SourceFile = truncateReportField(v.SourceFile)
+	return v
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func truncateReportField(s string) string {
+	if len(s) > cspReportFieldMax {
+		return s[:cspReportFieldMax]
+	}
+	return s
+}
+
+// parseCSPReports accepts a legacy {"csp-report": {...}} object or a
+// Reporting API array, skipping entries that are not CSP violations.
+func parseCSPReports(data []byte) ([]cspReportBody, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		var entries []struct {
+			Type string        `json:"type"`
+			Body cspReportBody `json:"body"`
+		}
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, err
+		}
+		out := make([]cspReportBody, 0, len(entries))
+		for _, e := range entries {
+			if e.Type == "csp-violation" {
+				out = append(out, e.Body)
+			}
+		}
+		return out, nil
+	}
+
+	var legacy struct {
+		Report *cspReportBody `json:"csp-report"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+	if legacy.Report == nil {
+		return nil, errors.New("missing csp-report")
+	}
+	return []cspReportBody{*legacy.Report}, nil
+}
+
+// handleCSPReport records violation reports sent by browsers.
+func (s *Server) handleCSPReport(w http.ResponseWriter, r *http.Request) {
+	if !s.cspReportEnabled {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			jsonError(w, "payload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	bodies, err := parseCSPReports(data)
+	if err != nil {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, b := range bodies {
+		v := b.violation(now)
+		s.cspReports.add(v)
+		log.Printf("csp violation: directive=%q blocked=%q document=%q", v.EffectiveDirective, v.BlockedURI, v.DocumentURI)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAdminOpsCSPReports lists the most recent violation reports.
+func (s *Server) handleAdminOpsCSPReports(w http.ResponseWriter, r *http.Request) {
+	reports, total := s.cspReports.snapshot()
+	jsonOK(w, map[string]interface{}{
+		"enabled":  s.cspReportEnabled,
+		"total":    total,
+		"capacity": cspReportBufferSize,
+		"reports":  reports,
+	})
+}
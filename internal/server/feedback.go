@@ -0,0 +1,185 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+
+	"acetate/internal/album"
+)
+
+const (
+	maxFeedbackRunes     = 1000
+	feedbackRateLimit    = 5
+	defaultFeedbackLimit = 100
+	maxFeedbackLimit     = 500
+)
+
+type feedbackEntry struct {
+	ID        int64  `json:"id"`
+	TrackStem string `json:"track_stem,omitempty"`
+	Message   string `json:"message"`
+	CreatedAt string `json:"created_at"`
+}
+
+// handleSubmitFeedback stores a short listener message for albums that have
+// opted in. Messages are rate-limited per session.
+func (s *Server) handleSubmitFeedback(w http.ResponseWriter, r *http.Request) {
+	alb := albumFromContext(r)
+	if !alb.FeedbackEnabled {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Message   string `json:"message"`
+		TrackStem string `json:"track_stem,omitempty"`
+	}
+	if err := s.decodeCompatJSONBody(r, &req); err != nil {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	message, ok := sanitizeFeedbackMessage(req.Message)
+	if !ok {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	stem := strings.TrimSpace(req.TrackStem)
+	if stem != "" {
+		if !album.ValidateStem(stem) {
+			jsonError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		inAlbum, err := s.albumStore.StemInAlbum(alb.ID, stem)
+		if err != nil {
+			log.Printf("feedback stem lookup error: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !inAlbum {
+			jsonError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	sessionID := s.getSessionID(r)
+	if !s.feedbackLimiter.Allow("feedback:" + sessionID) {
+		jsonError(w, "rate limited", http.StatusTooManyRequests)
+		return
+	}
+
+	if _, err := s.db.Exec(
+		"INSERT INTO listener_feedback (album_id, session_id, track_stem, message, created_at) VALUES (?, ?, ?, ?, ?)",
+		alb.ID, sessionID, stem, message, time.Now().UTC(),
+	); err != nil {
+		log.Printf("insert feedback error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonCreated(w, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleAdminListFeedback(w http.ResponseWriter, r *http.Request) {
+	alb := s.adminAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+
+	limit := defaultFeedbackLimit
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxFeedbackLimit {
+			jsonError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+
+	entries, err := s.listFeedback(alb.ID, limit)
+	if err != nil {
+		log.Printf("list feedback error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, map[string]interface{}{"feedback": entries})
+}
+
+func (s *Server) handleAdminDeleteFeedback(w http.ResponseWriter, r *http.Request) {
+	alb := s.adminAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+	feedbackID, err := strconv.ParseInt(strings.TrimSpace(chi.URLParam(r, "feedbackID")), 10, 64)
+	if err != nil || feedbackID <= 0 {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	res, err := s.db.Exec("DELETE FROM listener_feedback WHERE id = ? AND album_id = ?", feedbackID, alb.ID)
+	if err != nil {
+		log.Printf("delete feedback error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+	jsonOK(w, map[string]string{"status": "ok"})
+}
+
+func (s *Server) listFeedback(albumID int64, limit int) ([]feedbackEntry, error) {
+	rows, err := s.db.Query(
+		"SELECT id, track_stem, message, created_at FROM listener_feedback WHERE album_id = ? ORDER BY created_at DESC, id DESC LIMIT ?",
+		albumID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query feedback: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]feedbackEntry, 0)
+	for rows.Next() {
+		var e feedbackEntry
+		var createdAt time.Time
+		if err := rows.Scan(&e.ID, &e.TrackStem, &e.Message, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan feedback: %w", err)
+		}
+		e.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate feedback: %w", err)
+	}
+	return entries, nil
+}
+
+// sanitizeFeedbackMessage trims the message, drops control characters other
+// than newlines and tabs, and enforces the length bound.
+func sanitizeFeedbackMessage(raw string) (string, bool) {
+	if !utf8.ValidString(raw) {
+		return "", false
+	}
+	cleaned := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, strings.ReplaceAll(raw, "\r\n", "\n"))
+	cleaned = strings.TrimSpace(cleaned)
+	if cleaned == "" || utf8.RuneCountInString(cleaned) > maxFeedbackRunes {
+		return "", false
+	}
+	return cleaned, true
+}
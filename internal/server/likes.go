@@ -0,0 +1,89 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"acetate/internal/album"
+)
+
+// handleLikeTrack records one like per session per track. Repeat likes from
+// the same session are accepted but do not change the count.
+func (s *Server) handleLikeTrack(w http.ResponseWriter, r *http.Request) {
+	alb := albumFromContext(r)
+	if !alb.LikesEnabled {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	stem, err := normalizeStemParam(chi.URLParam(r, "stem"))
+	if err != nil || !album.ValidateStem(stem) {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	inAlbum, err := s.albumStore.StemInAlbum(alb.ID, stem)
+	if err != nil {
+		log.Printf("like stem lookup error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !inAlbum {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	res, err := s.db.Exec(
+		"INSERT OR IGNORE INTO track_likes (album_id, session_id, stem, created_at) VALUES (?, ?, ?, ?)",
+		alb.ID, s.getSessionID(r), stem, time.Now().UTC(),
+	)
+	if err != nil {
+		log.Printf("insert like error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	added, _ := res.RowsAffected()
+
+	var likes int64
+	if err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM track_likes WHERE album_id = ? AND stem = ?", alb.ID, stem,
+	).Scan(&likes); err != nil {
+		log.Printf("count likes error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"stem":  stem,
+		"liked": added > 0,
+		"likes": likes,
+	})
+}
+
+// trackLikeCounts returns like totals for an album keyed by stem.
+func (s *Server) trackLikeCounts(albumID int64) (map[string]int64, error) {
+	rows, err := s.db.Query(
+		"SELECT stem, COUNT(*) FROM track_likes WHERE album_id = ? GROUP BY stem", albumID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query likes: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var stem string
+		var n int64
+		if err := rows.Scan(&stem, &n); err != nil {
+			return nil, fmt.Errorf("scan likes: %w", err)
+		}
+		counts[stem] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate likes: %w", err)
+	}
+	return counts, nil
+}
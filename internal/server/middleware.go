@@ -2,9 +2,15 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"log"
+	"math"
 	"net/http"
 	"net/url"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,6 +25,8 @@ const (
 	adminUserIDKey   contextKey = "admin_user_id"
 	sessionPwIDKey   contextKey = "session_password_id"
 	requestAlbumKey  contextKey = "request_album"
+	cspNonceKey      contextKey = "csp_nonce"
+	shareScanKey     contextKey = "share_scan_key"
 )
 
 // requireSession checks for a valid listener session cookie and stores password_id in context.
@@ -101,8 +109,24 @@ func (s *Server) requireAlbumAccess(next http.Handler) http.Handler {
 // requireAdmin checks for a valid admin session cookie.
 func (s *Server) requireAdmin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// API tokens are explicit, so they win over any cookie on the request.
+		if token, ok := bearerToken(r); ok {
+			user, ok := s.adminAPITokenUser(w, r, token)
+			if ok {
+				s.serveAdminUser(w, r, next, user)
+			}
+			return
+		}
+
 		cookie, err := r.Cookie("acetate_admin")
 		if err != nil || cookie.Value == "" {
+			if username, password, ok := r.BasicAuth(); ok && s.adminBasicAuth {
+				user, ok := s.adminBasicAuthUser(w, r, username, password)
+				if ok {
+					s.serveAdminUser(w, r, next, user)
+				}
+				return
+			}
 			jsonError(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
@@ -138,16 +162,111 @@ func (s *Server) requireAdmin(next http.Handler) http.Handler {
 	})
 }
 
-func allowDuringForcedPasswordReset(method, path string) bool {
-	return (method == http.MethodPut && path == "/admin/api/admin-password") ||
-		(method == http.MethodDelete && path == "/admin/api/auth") ||
-		(method == http.MethodGet && path == "/admin/api/config")
+// adminMutationRateLimit throttles state-changing admin requests per admin
+// user so a runaway script or hijacked session cannot hammer writes. Reads
+// and logout are never throttled. Must run after requireAdmin.
+func (s *Server) adminMutationRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isMutatingMethod(r.Method) || (r.Method == http.MethodDelete && r.URL.Path == "/admin/api/auth") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		userID, ok := adminUserIDFromContext(r)
+		if !ok {
+			jsonError(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !s.adminMutationLimiter.Allow("admin-mutation:" + strconv.FormatInt(userID, 10)) {
+			jsonError(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveAdminUser runs next as a user authenticated without a session cookie.
+func (s *Server) serveAdminUser(w http.ResponseWriter, r *http.Request, next http.Handler, user adminUserRecord) {
+	if user.RequirePasswordReset && !allowDuringForcedPasswordReset(r.Method, r.URL.Path) {
+		jsonError(w, "password reset required", http.StatusForbidden)
+		return
+	}
+	ctx := context.WithValue(r.Context(), adminUserIDKey, user.ID)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// adminBasicAuthUser verifies HTTP Basic credentials for programmatic admin
+// clients. Attempts share the login lockout and are audited like cookie
+// logins; on failure it writes the response and returns false. No
+// WWW-Authenticate challenge is sent, so browsers never cache credentials.
+func (s *Server) adminBasicAuthUser(w http.ResponseWriter, r *http.Request, username, password string) (adminUserRecord, bool) {
+	clientIP := s.cfIPs.GetClientIP(r)
+	username = strings.TrimSpace(username)
+
+	loginGuardKey := strings.ToLower(username) + "|" + strings.TrimSpace(clientIP)
+	if allowed, retryAfter := s.adminLoginGuard.allow(loginGuardKey, time.Now().UTC()); !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		s.recordAdminAuthAttempt(r, username, "rejected", "basic_lockout")
+		jsonError(w, "try again later", http.StatusTooManyRequests)
+		return adminUserRecord{}, false
+	}
+	if !s.adminAPILimiter.Allow("admin-basic:" + clientIP) {
+		s.recordAdminAuthAttempt(r, username, "rejected", "basic_rate_limited")
+		jsonError(w, "rate limited", http.StatusTooManyRequests)
+		return adminUserRecord{}, false
+	}
+
+	user, err := s.authenticateAdminCredentials(username, password)
+	if err != nil {
+		if errors.Is(err, errAdminInvalidCreds) {
+			s.markAdminLoginFailure(loginGuardKey, username, clientIP, "basic_auth")
+			s.recordAdminAuthAttempt(r, username, "rejected", "basic_invalid_credentials")
+			jsonError(w, "unauthorized", http.StatusUnauthorized)
+			return adminUserRecord{}, false
+		}
+		log.Printf("admin basic auth error: %v", err)
+		s.recordAdminAuthAttempt(r, username, "error", "basic_auth_query_failed")
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return adminUserRecord{}, false
+	}
+	s.adminLoginGuard.markSuccess(loginGuardKey)
+	s.recordAdminAuthAttempt(r, user.Username, "success", "basic_auth")
+	return user, true
+}
+
+// forcedPasswordResetRoutes lists the admin endpoints reachable while a
+// password reset is pending: enough to load the UI, rotate the password, and
+// sign out or revoke other sessions. Patterns use path.Match syntax.
+var forcedPasswordResetRoutes = []struct {
+	method  string
+	pattern string
+}{
+	{http.MethodGet, "/admin/api/config"},
+	{http.MethodPut, "/admin/api/admin-password"},
+	{http.MethodPost, "/admin/api/password/check"},
+	{http.MethodDelete, "/admin/api/auth"},
+	{http.MethodGet, "/admin/api/admin-sessions"},
+	{http.MethodDelete, "/admin/api/admin-sessions/*"},
+}
+
+func allowDuringForcedPasswordReset(method, urlPath string) bool {
+	for _, route := range forcedPasswordResetRoutes {
+		if method != route.method {
+			continue
+		}
+		if ok, _ := path.Match(route.pattern, urlPath); ok {
+			return true
+		}
+	}
+	return false
 }
 
 // csrfCheck validates the Origin header on state-mutating requests.
 func csrfCheck(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if isMutatingMethod(r.Method) && strings.HasPrefix(r.URL.Path, "/admin/api/") {
+		// Browsers never attach Authorization: Bearer on their own, so
+		// token-authenticated requests cannot be forged cross-site.
+		_, hasBearer := bearerToken(r)
+		if isMutatingMethod(r.Method) && strings.HasPrefix(r.URL.Path, "/admin/api/") && !hasBearer {
 			origin := strings.TrimSpace(r.Header.Get("Origin"))
 			if origin == "" || !sameOrigin(r, origin) {
 				jsonError(w, "forbidden", http.StatusForbidden)
@@ -190,17 +309,63 @@ func requestScheme(r *http.Request) string {
 	return "http"
 }
 
-// securityHeaders sets secure defaults for every response.
-func securityHeaders(next http.Handler) http.Handler {
-	const csp = "default-src 'self'; script-src 'self'; style-src 'self'; img-src 'self' data: blob:; media-src 'self'; connect-src 'self'; font-src 'self'; object-src 'none'; base-uri 'none'; frame-ancestors 'none'; form-action 'self'"
+// Default values for the configurable security headers.
+const (
+	DefaultReferrerPolicy    = "no-referrer"
+	DefaultPermissionsPolicy = "accelerometer=(), camera=(), geolocation=(), gyroscope=(), microphone=(), payment=(), usb=()"
+)
+
+// validReferrerPolicies are the Referrer-Policy tokens browsers understand.
+var validReferrerPolicies = map[string]bool{
+	"no-referrer":                     true,
+	"no-referrer-when-downgrade":      true,
+	"origin":                          true,
+	"origin-when-cross-origin":        true,
+	"same-origin":                     true,
+	"strict-origin":                   true,
+	"strict-origin-when-cross-origin": true,
+	"unsafe-url":                      true,
+}
+
+// normalizeReferrerPolicy returns the configured policy, or the default when
+// it is empty or not a token browsers understand.
+func normalizeReferrerPolicy(policy string) string {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	if policy == "" {
+		return DefaultReferrerPolicy
+	}
+	if !validReferrerPolicies[policy] {
+		log.Printf("WARNING: invalid Referrer-Policy %q, using %q", policy, DefaultReferrerPolicy)
+		return DefaultReferrerPolicy
+	}
+	return policy
+}
+
+// securityHeaders sets secure defaults for every response. Each request gets
+// a fresh CSP nonce, stored in the context, that inline scripts must carry.
+func (s *Server) securityHeaders(next http.Handler) http.Handler {
+	const cspRest = "style-src 'self'; img-src 'self' data: blob:; media-src 'self'; connect-src 'self'; font-src 'self'; object-src 'none'; base-uri 'none'; frame-ancestors 'none'; form-action 'self'"
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scriptSrc := "script-src 'self'"
+		if nonce, err := newCSPNonce(); err != nil {
+			log.Printf("csp nonce error: %v", err)
+		} else {
+			scriptSrc += " 'nonce-" + nonce + "'"
+			r = r.WithContext(context.WithValue(r.Context(), cspNonceKey, nonce))
+		}
+		csp := "default-src 'self'; " + scriptSrc + "; " + cspRest
+
 		h := w.Header()
+		if s.cspReportEnabled {
+			csp += "; report-uri " + cspReportPath + "; report-to " + cspReportGroup
+			h.Set("Reporting-Endpoints", cspReportGroup+`="`+cspReportPath+`"`)
+		}
 		h.Set("X-Content-Type-Options", "nosniff")
 		h.Set("X-Frame-Options", "DENY")
-		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Referrer-Policy", s.referrerPolicy)
 		h.Set("Cross-Origin-Resource-Policy", "same-origin")
-		h.Set("Permissions-Policy", "accelerometer=(), camera=(), geolocation=(), gyroscope=(), microphone=(), payment=(), usb=()")
+		h.Set("Permissions-Policy", s.permissionsPolicy)
 		h.Set("Content-Security-Policy", csp)
 		next.ServeHTTP(w, r)
 	})
@@ -229,6 +394,27 @@ func (w *statusWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// writeDeadline sets a per-request write deadline. Route-level uses replace
+// the global default, so long-running streams can extend it while ordinary
+// API handlers keep a short one. A non-positive duration leaves the server's
+// WriteTimeout in effect.
+func writeDeadline(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if d > 0 {
+				// Best effort: writers that do not support deadlines keep the server default.
+				_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // cacheControl sets the Cache-Control header.
 func cacheControl(value string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -254,6 +440,22 @@ func passwordIDFromContext(r *http.Request) int64 {
 	return id
 }
 
+// newCSPNonce returns 128 random bits, base64-encoded.
+func newCSPNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// cspNonceFromContext returns the request's CSP nonce, or "" when none was
+// generated (inline scripts must then be omitted).
+func cspNonceFromContext(r *http.Request) string {
+	nonce, _ := r.Context().Value(cspNonceKey).(string)
+	return nonce
+}
+
 func albumFromContext(r *http.Request) *albums.Album {
 	v := r.Context().Value(requestAlbumKey)
 	a, _ := v.(*albums.Album)
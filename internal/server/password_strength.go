@@ -0,0 +1,136 @@
+package server
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsList string
+
+var commonPasswords = parseCommonPasswords(commonPasswordsList)
+
+func parseCommonPasswords(list string) map[string]struct{} {
+	out := make(map[string]struct{})
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out[line] = struct{}{}
+	}
+	return out
+}
+
+// passwordStrengthLabels names each score from 0 to 4.
+var passwordStrengthLabels = []string{"very_weak", "weak", "fair", "strong", "very_strong"}
+
+// passwordStrength is advisory feedback for the admin UI. It never replaces
+// the PasswordPolicy, which remains the hard check.
+type passwordStrength struct {
+	Score       int      `json:"score"`
+	Strength    string   `json:"strength"`
+	MeetsPolicy bool     `json:"meets_policy"`
+	Suggestions []string `json:"suggestions"`
+}
+
+func assessPasswordStrength(password string, policy PasswordPolicy) passwordStrength {
+	policy = policy.withDefaults()
+	pw := strings.TrimSpace(password)
+	result := passwordStrength{
+		MeetsPolicy: policy.validate(password) == nil,
+		Suggestions: make([]string, 0),
+	}
+
+	length := len([]rune(pw))
+	switch {
+	case length < policy.MinLength:
+		result.Suggestions = append(result.Suggestions, fmt.Sprintf("Use at least %d characters.", policy.MinLength))
+	case length < max(16, policy.MinLength+4):
+		result.Score++
+		result.Suggestions = append(result.Suggestions, "Longer is stronger: 16 or more characters is better.")
+	default:
+		result.Score += 2
+	}
+
+	var lower, upper, digit, symbol bool
+	distinct := make(map[rune]struct{})
+	for _, r := range pw {
+		distinct[r] = struct{}{}
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	if classes >= 3 {
+		result.Score++
+	}
+	if classes == 4 {
+		result.Score++
+	}
+	if !lower || !upper {
+		result.Suggestions = append(result.Suggestions, "Mix upper and lower case letters.")
+	}
+	if !digit {
+		result.Suggestions = append(result.Suggestions, "Add a number.")
+	}
+	if !symbol {
+		result.Suggestions = append(result.Suggestions, "Add a symbol or space.")
+	}
+
+	if length > 0 && len(distinct) <= length/3 {
+		result.Score = min(result.Score, 1)
+		result.Suggestions = append(result.Suggestions, "Avoid repeating the same few characters.")
+	}
+	if isCommonPassword(pw) {
+		result.Score = 0
+		result.Suggestions = append(result.Suggestions, "This is a commonly used password; choose something less predictable.")
+	}
+
+	result.Score = min(result.Score, len(passwordStrengthLabels)-1)
+	result.Strength = passwordStrengthLabels[result.Score]
+	return result
+}
+
+// isCommonPassword matches the embedded list case-insensitively, ignoring
+// trailing digits and symbols ("Password123!" counts as "password").
+func isCommonPassword(pw string) bool {
+	candidate := strings.ToLower(pw)
+	if _, ok := commonPasswords[candidate]; ok {
+		return true
+	}
+	trimmed := strings.TrimRightFunc(candidate, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if trimmed == "" {
+		return false
+	}
+	_, ok := commonPasswords[trimmed]
+	return ok
+}
+
+func (s *Server) handleAdminCheckPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := decodeJSONBody(r, &req); err != nil {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	jsonOK(w, assessPasswordStrength(req.Password, s.passwordPolicy))
+}
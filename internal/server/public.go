@@ -0,0 +1,120 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/go-chi/chi/v5"
+
+	"acetate/internal/album"
+	"acetate/internal/albums"
+)
+
+// publicAlbumResponse is the full set of fields exposed without a session.
+// Keep this list deliberately small: no tracks, paths, IDs, or password data.
+type publicAlbumResponse struct {
+	Title            string           `json:"title"`
+	Artist           string           `json:"artist"`
+	RequiresPassword bool             `json:"requires_password"`
+	CoverURL         string           `json:"cover_url,omitempty"`
+	Branding         *albums.Branding `json:"branding,omitempty"`
+}
+
+// publicRateLimit throttles unauthenticated metadata requests per client IP.
+func (s *Server) publicRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIP := s.cfIPs.GetClientIP(r)
+		if !s.publicLimiter.Allow("public:" + clientIP) {
+			jsonError(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handlePublicAlbum(w http.ResponseWriter, r *http.Request) {
+	alb := s.publicAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+	jsonOK(w, s.publicAlbumInfo(r, alb))
+}
+
+// publicAlbumInfo builds the unauthenticated view of an album.
+func (s *Server) publicAlbumInfo(r *http.Request, alb *albums.Album) publicAlbumResponse {
+	resp := publicAlbumResponse{
+		Title:            alb.Title,
+		Artist:           alb.Artist,
+		RequiresPassword: !s.sessionHasAlbumAccess(r, alb.ID),
+	}
+	if alb.Branding != (albums.Branding{}) {
+		branding := alb.Branding
+		resp.Branding = &branding
+	}
+	if _, _, ok := album.ResolveCoverPath(alb.AlbumPath, s.dataPath, alb.ID); ok {
+		resp.CoverURL = "/api/public/" + url.PathEscape(alb.Slug) + "/cover"
+	}
+	// An explicit logo URL wins; otherwise point at an uploaded logo.
+	if resp.Branding == nil || resp.Branding.LogoURL == "" {
+		if info, err := os.Stat(album.LogoPath(s.dataPath, alb.ID)); err == nil && !info.IsDir() {
+			if resp.Branding == nil {
+				resp.Branding = &albums.Branding{}
+			}
+			resp.Branding.LogoURL = "/api/public/" + url.PathEscape(alb.Slug) + "/logo"
+		}
+	}
+	return resp
+}
+
+func (s *Server) handlePublicCover(w http.ResponseWriter, r *http.Request) {
+	alb := s.publicAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+	album.ServeCover(w, r, alb.AlbumPath, s.dataPath, alb.ID)
+}
+
+func (s *Server) handlePublicLogo(w http.ResponseWriter, r *http.Request) {
+	alb := s.publicAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+	album.ServeLogo(w, r, s.dataPath, alb.ID)
+}
+
+func (s *Server) publicAlbumFromRequest(w http.ResponseWriter, r *http.Request) *albums.Album {
+	slug := chi.URLParam(r, "slug")
+	if slug == "" {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return nil
+	}
+
+	alb, err := s.albumStore.GetAlbumBySlug(slug)
+	if err != nil {
+		log.Printf("public album lookup error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return nil
+	}
+	if alb == nil {
+		jsonError(w, "not found", http.StatusNotFound)
+		return nil
+	}
+	return alb
+}
+
+// sessionHasAlbumAccess reports whether the request carries a listener session
+// that already unlocks the album. Errors are treated as no access.
+func (s *Server) sessionHasAlbumAccess(r *http.Request, albumID int64) bool {
+	sessionID := s.getSessionID(r)
+	if sessionID == "" {
+		return false
+	}
+	valid, passwordID, err := s.sessions.ValidateSession(sessionID)
+	if err != nil || !valid || passwordID <= 0 {
+		return false
+	}
+	hasAccess, err := s.albumStore.PasswordHasAlbumAccess(passwordID, albumID)
+	return err == nil && hasAccess
+}
@@ -16,6 +16,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
 
@@ -24,20 +25,32 @@ import (
 	"acetate/internal/albums"
 	"acetate/internal/analytics"
 	"acetate/internal/auth"
+	"acetate/internal/config"
 )
 
 func (s *Server) routes() http.Handler {
 	r := chi.NewRouter()
 
 	// Global middleware
-	r.Use(securityHeaders)
+	r.Use(s.securityHeaders)
 	r.Use(requestLogger)
+	r.Use(s.limitConcurrency)
+	r.Use(writeDeadline(s.apiWriteTimeout))
 	r.Use(csrfCheck)
 
 	// Public API endpoints
 	r.Route("/api", func(r chi.Router) {
 		// Auth — no session required
-		r.With(bodyLimiter(1024)).Post("/auth", s.handleAuth)
+		r.With(bodyLimiter(s.bodyLimits.Auth)).Post("/auth", s.handleAuth)
+
+		// Public album metadata for the gate page — no session required
+		r.Group(func(r chi.Router) {
+			r.Use(s.publicRateLimit)
+			r.With(cacheControl("no-cache")).Get("/public/{slug}", s.handlePublicAlbum)
+			r.With(s.countConditional("cover")).Get("/public/{slug}/cover", s.handlePublicCover)
+			r.Get("/public/{slug}/logo", s.handlePublicLogo)
+			r.With(bodyLimiter(cspReportMaxBodyBytes)).Post("/csp-report", s.handleCSPReport)
+		})
 
 		// Session-gated endpoints
 		r.Group(func(r chi.Router) {
@@ -45,62 +58,100 @@ func (s *Server) routes() http.Handler {
 
 			r.Delete("/auth", s.handleLogout)
 			r.Get("/session", s.handleSessionCheck)
+			r.Post("/heartbeat", s.handleHeartbeat)
 			r.Get("/albums", s.handleListAccessibleAlbums)
 
 			// Album-scoped endpoints
 			r.Route("/albums/{slug}", func(r chi.Router) {
 				r.Use(s.requireAlbumAccess)
-				r.With(cacheControl("private, no-cache")).Get("/tracks", s.handleGetTracks)
-				r.Get("/cover", s.handleGetCover)
-				r.Get("/stream/{stem}", s.handleStreamTrack)
+				r.With(cacheControl("private, no-cache"), s.countConditional("tracks")).Get("/tracks", s.handleGetTracks)
+				r.With(s.countConditional("cover")).Get("/cover", s.handleGetCover)
 				r.With(cacheControl("private, max-age=3600")).Get("/lyrics/{stem}", s.handleGetLyrics)
-				r.With(bodyLimiter(102400)).Post("/analytics", s.handleAnalytics)
+				r.With(cacheControl("private, no-cache")).Head("/lyrics/{stem}", s.handleHeadLyrics)
+				r.With(bodyLimiter(s.analyticsMaxBodyBytes)).Post("/analytics", s.handleAnalytics)
+				r.With(bodyLimiter(s.bodyLimits.Form)).Post("/feedback", s.handleSubmitFeedback)
+				r.With(bodyLimiter(s.bodyLimits.Form)).Post("/tracks/{stem}/like", s.handleLikeTrack)
+				r.With(bodyLimiter(s.bodyLimits.Form)).Post("/tracks/{stem}/share", s.handleShareTrack)
 			})
 		})
+
+		// Streaming takes a session or a signed share link in place of one.
+		r.With(s.streamAccess, writeDeadline(s.streamWriteTimeout), s.countConditional("stream")).Get("/albums/{slug}/stream/{stem}", s.handleStreamTrack)
 	})
 
 	// Admin routes
 	r.Route("/admin", func(r chi.Router) {
-		r.With(bodyLimiter(1024)).Post("/api/auth", s.handleAdminAuth)
+		r.With(bodyLimiter(s.bodyLimits.Auth)).Post("/api/auth", s.handleAdminAuth)
 		r.Get("/api/setup/status", s.handleAdminSetupStatus)
-		r.With(bodyLimiter(4096)).Post("/api/setup", s.handleAdminSetupBootstrap)
+		r.With(bodyLimiter(s.bodyLimits.Form)).Post("/api/setup", s.handleAdminSetupBootstrap)
 
 		r.Group(func(r chi.Router) {
 			r.Use(s.requireAdmin)
+			r.Use(s.adminMutationRateLimit)
+			r.Use(s.adminChangeAudit)
 			r.Use(cacheControl("no-store"))
 
 			r.Delete("/api/auth", s.handleAdminLogout)
 			r.Get("/api/admin-users", s.handleAdminListUsers)
-			r.With(bodyLimiter(4096)).Post("/api/admin-users", s.handleAdminCreateUser)
-			r.With(bodyLimiter(4096)).Put("/api/admin-users/{id}", s.handleAdminUpdateUser)
-			r.With(bodyLimiter(4096)).Put("/api/admin-password", s.handleAdminUpdateAdminPassword)
+			r.With(bodyLimiter(s.bodyLimits.Form)).Post("/api/admin-users", s.handleAdminCreateUser)
+			r.With(bodyLimiter(adminBulkUsersMaxBodyBytes)).Post("/api/admin-users/bulk", s.handleAdminBulkCreateUsers)
+			r.With(bodyLimiter(s.bodyLimits.Form)).Put("/api/admin-users/{id}", s.handleAdminUpdateUser)
+			r.Post("/api/admin-users/{id}/logout", s.handleAdminLogoutUser)
+			r.With(bodyLimiter(s.bodyLimits.Form)).Put("/api/admin-password", s.handleAdminUpdateAdminPassword)
+			r.With(bodyLimiter(s.bodyLimits.Form)).Post("/api/password/check", s.handleAdminCheckPassword)
+			r.Get("/api/admin-sessions", s.handleAdminListSessions)
+			r.Delete("/api/admin-sessions/{id}", s.handleAdminRevokeSession)
+			r.Get("/api/tokens", s.handleAdminListAPITokens)
+			r.With(bodyLimiter(s.bodyLimits.Form)).Post("/api/tokens", s.handleAdminCreateAPIToken)
+			r.Delete("/api/tokens/{id}", s.handleAdminRevokeAPIToken)
+			r.Get("/api/shares", s.handleAdminListShares)
+			r.Delete("/api/shares/{id}", s.handleAdminRevokeShare)
 			r.Get("/api/config", s.handleAdminGetConfig)
+			r.Get("/api/config/raw", s.handleAdminGetRawConfig)
+			r.Get("/api/audit/changes", s.handleAdminListChangeAudit)
 			r.Get("/api/ops/health", s.handleAdminOpsHealth)
+			r.Get("/api/ops/whoami", s.handleAdminOpsWhoami)
 			r.Get("/api/ops/stats", s.handleAdminOpsStats)
-			r.With(bodyLimiter(4096)).Post("/api/ops/maintenance", s.handleAdminOpsMaintenance)
-			r.Get("/api/export/events", s.handleAdminExportEvents)
-			r.Get("/api/export/backup", s.handleAdminExportBackup)
+			r.Get("/api/ops/album-check", s.handleAdminOpsAlbumCheck)
+			r.Get("/api/ops/collector", s.handleAdminOpsCollector)
+			r.Get("/api/ops/csp-reports", s.handleAdminOpsCSPReports)
+			r.With(bodyLimiter(s.bodyLimits.Form)).Post("/api/ops/collector", s.handleAdminOpsCollectorTune)
+			r.With(bodyLimiter(s.bodyLimits.Form)).Post("/api/ops/maintenance", s.handleAdminOpsMaintenance)
+			r.With(writeDeadline(s.streamWriteTimeout)).Get("/api/export/events", s.handleAdminExportEvents)
+			r.With(writeDeadline(s.streamWriteTimeout)).Get("/api/export/backup", s.handleAdminExportBackup)
 
 			// Album CRUD
 			r.Get("/api/album-folders", s.handleAdminListAlbumFolders)
 			r.Get("/api/albums", s.handleAdminListAlbums)
-			r.With(bodyLimiter(4096)).Post("/api/albums", s.handleAdminCreateAlbum)
+			r.With(bodyLimiter(s.bodyLimits.Form)).Post("/api/albums", s.handleAdminCreateAlbum)
 			r.Get("/api/albums/{id}", s.handleAdminGetAlbum)
-			r.With(bodyLimiter(4096)).Put("/api/albums/{id}", s.handleAdminUpdateAlbum)
+			r.With(bodyLimiter(s.bodyLimits.Form)).Put("/api/albums/{id}", s.handleAdminUpdateAlbum)
 			r.Delete("/api/albums/{id}", s.handleAdminDeleteAlbum)
 
 			// Album-scoped admin operations
 			r.Get("/api/albums/{id}/tracks", s.handleAdminGetTracks)
-			r.With(bodyLimiter(102400)).Put("/api/albums/{id}/tracks", s.handleAdminUpdateTracks)
-			r.With(bodyLimiter(10<<20)).Post("/api/albums/{id}/cover", s.handleAdminUploadCover)
+			r.With(bodyLimiter(s.bodyLimits.Tracks)).Put("/api/albums/{id}/tracks", s.handleAdminUpdateTracks)
+			r.With(bodyLimiter(s.bodyLimits.Form)).Post("/api/albums/{id}/tracks/renumber", s.handleAdminRenumberTracks)
+			r.Get("/api/albums/{id}/derive-title", s.handleAdminDeriveTitle)
+			r.Get("/api/albums/{id}/tracks/{stem}/checksum", s.handleAdminTrackChecksum)
+			r.Get("/api/albums/{id}/tracks/{stem}/info", s.handleAdminTrackInfo)
+			r.Post("/api/albums/{id}/tracks/{stem}/retag", s.handleAdminRetagTrack)
+			r.With(bodyLimiter(s.bodyLimits.TrackImport)).Post("/api/albums/{id}/tracks/import", s.handleAdminImportTracks)
+			r.Delete("/api/albums/{id}/tracks/{stem}", s.handleAdminDeleteTrack)
+			r.With(bodyLimiter(s.bodyLimits.Cover)).Post("/api/albums/{id}/cover", s.handleAdminUploadCover)
+			r.With(bodyLimiter(s.bodyLimits.Logo)).Post("/api/albums/{id}/logo", s.handleAdminUploadLogo)
+			r.Delete("/api/albums/{id}/logo", s.handleAdminDeleteLogo)
+			r.Get("/api/albums/{id}/feedback", s.handleAdminListFeedback)
+			r.Delete("/api/albums/{id}/feedback/{feedbackID}", s.handleAdminDeleteFeedback)
 			r.Get("/api/albums/{id}/reconcile", s.handleAdminReconcilePreview)
-			r.With(bodyLimiter(4096)).Post("/api/albums/{id}/reconcile", s.handleAdminReconcileApply)
+			r.With(bodyLimiter(s.bodyLimits.Form)).Post("/api/albums/{id}/reconcile", s.handleAdminReconcileApply)
 			r.Get("/api/albums/{id}/analytics", s.handleAdminAnalytics)
+			r.Get("/api/albums/{id}/analytics/album-funnel", s.handleAdminAlbumFunnel)
 
 			// Password CRUD
 			r.Get("/api/passwords", s.handleAdminListPasswords)
-			r.With(bodyLimiter(4096)).Post("/api/passwords", s.handleAdminCreatePassword)
-			r.With(bodyLimiter(4096)).Put("/api/passwords/{id}", s.handleAdminUpdatePassword)
+			r.With(bodyLimiter(s.bodyLimits.Form)).Post("/api/passwords", s.handleAdminCreatePassword)
+			r.With(bodyLimiter(s.bodyLimits.Form)).Put("/api/passwords/{id}", s.handleAdminUpdatePassword)
 			r.Delete("/api/passwords/{id}", s.handleAdminDeletePassword)
 		})
 
@@ -108,12 +159,46 @@ func (s *Server) routes() http.Handler {
 		r.Get("/*", s.handleAdminStatic)
 	})
 
+	// Readiness probe (public, no sensitive details)
+	r.With(cacheControl("no-store")).Get("/readyz", s.handleReadyz)
+
 	// SPA static files (public)
 	r.Get("/*", s.handleSPA)
 
 	return r
 }
 
+// handleReadyz reports whether the server can serve traffic correctly: the
+// database answers and, unless disabled, Cloudflare ranges have loaded so
+// client IPs are attributed correctly.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ready := true
+
+	dbOK := s.db.PingContext(r.Context()) == nil
+	if !dbOK {
+		ready = false
+	}
+
+	cfReady, cfRanges, _ := s.cfIPs.Status()
+	if s.requireCloudflareReady && !cfReady {
+		ready = false
+	}
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+	jsonStatus(w, code, map[string]interface{}{
+		"status":   status,
+		"database": dbOK,
+		"cloudflare": map[string]interface{}{
+			"ready":    cfReady,
+			"ranges":   cfRanges,
+			"required": s.requireCloudflareReady,
+		},
+	})
+}
+
 // Ensure interfaces are used to prevent "imported and not used" errors.
 var _ = auth.VerifyPassphrase
 
@@ -158,21 +243,19 @@ func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "acetate_session",
-		Value:    sessionID,
-		Path:     "/",
-		MaxAge:   7 * 24 * 60 * 60, // 7 days
-		HttpOnly: true,
-		Secure:   isSecureRequest(r),
-		SameSite: http.SameSiteStrictMode,
-	})
+	setListenerSessionCookie(w, r, sessionID)
 
-	// Record session start
-	s.collector.Record(analytics.Event{
-		SessionID: sessionID,
-		EventType: "session_start",
-	})
+	// Record session start once per unlocked album so album-scoped session
+	// counts work in aggregate-only mode, where no session rows are kept.
+	if s.analyticsAllowed(r) {
+		for _, albumID := range albumIDs {
+			s.collector.Record(analytics.Event{
+				SessionID: sessionID,
+				EventType: "session_start",
+				AlbumID:   albumID,
+			})
+		}
+	}
 
 	// Build album list for response
 	accessibleAlbums, err := s.albumStore.GetAlbumsForPassword(passwordID)
@@ -191,7 +274,6 @@ func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request) {
 		albumList = append(albumList, albumResponse{Slug: a.Slug, Title: a.Title, Artist: a.Artist})
 	}
 
-	_ = albumIDs // album IDs already captured via GetAlbumsForPassword
 	jsonOK(w, map[string]interface{}{
 		"status": "ok",
 		"albums": albumList,
@@ -203,10 +285,12 @@ func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	if sessionID != "" {
 		s.sessions.DeleteSession(sessionID)
 
-		s.collector.Record(analytics.Event{
-			SessionID: sessionID,
-			EventType: "session_end",
-		})
+		if s.analyticsAllowed(r) {
+			s.collector.Record(analytics.Event{
+				SessionID: sessionID,
+				EventType: "session_end",
+			})
+		}
 	}
 
 	http.SetCookie(w, &http.Cookie{
@@ -222,6 +306,31 @@ func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	jsonOK(w, map[string]string{"status": "ok"})
 }
 
+// setListenerSessionCookie issues the listener session cookie with a full
+// SessionExpiry lifetime.
+func setListenerSessionCookie(w http.ResponseWriter, r *http.Request, sessionID string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "acetate_session",
+		Value:    sessionID,
+		Path:     "/",
+		MaxAge:   int(auth.SessionExpiry.Seconds()),
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// handleHeartbeat keeps an idle listener's session alive. requireSession has
+// already validated it and touched last_seen_at (at most once per
+// SessionTouchWindow); the cookie is re-issued so the browser's copy slides
+// along with it.
+func (s *Server) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie("acetate_session"); err == nil {
+		setListenerSessionCookie(w, r, cookie.Value)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (s *Server) handleSessionCheck(w http.ResponseWriter, r *http.Request) {
 	passwordID := passwordIDFromContext(r)
 	type albumResponse struct {
@@ -274,12 +383,26 @@ func (s *Server) handleGetTracks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	trackInfos := album.GetTrackList(tracks, alb.AlbumPath)
+	trackInfos := album.GetTrackList(s.withCurrentDurations(alb, tracks), alb.AlbumPath)
+	if alb.LikesEnabled {
+		counts, err := s.trackLikeCounts(alb.ID)
+		if err != nil {
+			log.Printf("track like counts error: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		for i := range trackInfos {
+			likes := counts[trackInfos[i].Stem]
+			trackInfos[i].Likes = &likes
+		}
+	}
 	jsonOK(w, map[string]interface{}{
 		"title":              alb.Title,
 		"artist":             alb.Artist,
 		"tracks":             trackInfos,
 		"downloads_enabled":  alb.DownloadsEnabled,
+		"feedback_enabled":   alb.FeedbackEnabled,
+		"likes_enabled":      alb.LikesEnabled,
 	})
 }
 
@@ -307,66 +430,245 @@ func (s *Server) handleStreamTrack(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "internal error", http.StatusInternalServerError)
 		return
 	}
-	if !album.StemInTracks(stem, tracks) {
+	track, ok := album.FindTrack(stem, tracks)
+	if !ok {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	trackDir, ok := album.TrackDir(alb.AlbumPath, track.Subdir)
+	if !ok {
 		jsonError(w, "bad request", http.StatusBadRequest)
 		return
 	}
+	if !s.checkStreamScan(w, r, alb.ID, stem) {
+		return
+	}
 
-	// Support ?dl=1 for download when downloads are enabled for this album.
-	if r.URL.Query().Get("dl") == "1" && alb.DownloadsEnabled {
-		// Find the track title for a friendly filename.
-		filename := stem + ".mp3"
-		for _, t := range tracks {
-			if t.Stem == stem && t.Title != "" {
-				filename = t.Title + ".mp3"
-				break
-			}
+	// ?format=mp3 transcodes non-MP3 sources for clients that only play MP3.
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format != "" && format != "mp3" {
+		jsonError(w, "unsupported format", http.StatusBadRequest)
+		return
+	}
+	srcPath, srcInfo, found := album.FindTrackFile(trackDir, stem, s.albumExtensions...)
+	transcode := format == "mp3" && found && !strings.EqualFold(filepath.Ext(srcPath), ".mp3")
+
+	// ?download=true (or the older ?dl=1) serves the track as an attachment
+	// named after its title. It is refused unless the album has downloads
+	// enabled or the track allows downloading on its own.
+	if wantsDownload(r) {
+		if !alb.DownloadsEnabled && !track.AllowDownload {
+			jsonError(w, "downloads disabled", http.StatusForbidden)
+			return
+		}
+		ext := ".mp3"
+		if found && !transcode {
+			ext = strings.ToLower(filepath.Ext(srcPath))
 		}
-		w.Header().Set("Content-Disposition", "attachment; filename=\""+strings.ReplaceAll(filename, "\"", "")+"\"")
+		w.Header().Set("Content-Disposition", attachmentDisposition(downloadFilename(track.Title, stem)+ext))
+	}
+
+	// Bandwidth is analytics too, so opted-out clients are not counted.
+	if s.analyticsAllowed(r) {
+		cw := &byteCountWriter{ResponseWriter: w, status: http.StatusOK}
+		defer s.recordStreamBytes(alb.ID, stem, cw)
+		w = cw
+	}
+	if transcode {
+		s.serveTranscodedMP3(w, r, alb.ID, track.Subdir, stem, srcPath, srcInfo)
+		return
+	}
+	album.StreamTrack(w, r, trackDir, stem, s.albumExtensions...)
+}
+
+// byteCountWriter counts the response body bytes actually written, so a
+// ranged or interrupted stream records only what the client received.
+type byteCountWriter struct {
+	http.ResponseWriter
+	status int
+	n      int64
+}
+
+func (w *byteCountWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *byteCountWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// ReadFrom keeps the underlying writer's io.ReaderFrom, and with it
+// sendfile, available to http.ServeContent.
+func (w *byteCountWriter) ReadFrom(src io.Reader) (int64, error) {
+	rf, ok := w.ResponseWriter.(io.ReaderFrom)
+	if !ok {
+		rf = writerOnly{w.ResponseWriter}
 	}
+	n, err := rf.ReadFrom(src)
+	w.n += n
+	return n, err
+}
 
-	album.StreamTrack(w, r, alb.AlbumPath, stem)
+// writerOnly hides everything but Write, so io.Copy cannot recurse into
+// ReadFrom.
+type writerOnly struct {
+	io.Writer
+}
+
+func (w writerOnly) ReadFrom(src io.Reader) (int64, error) {
+	return io.Copy(w.Writer, src)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *byteCountWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// recordStreamBytes adds a finished stream's audio bytes to the track's
+// bandwidth counter. Error bodies and empty responses are not counted.
+func (s *Server) recordStreamBytes(albumID int64, stem string, w *byteCountWriter) {
+	if w.n == 0 || (w.status != http.StatusOK && w.status != http.StatusPartialContent) {
+		return
+	}
+	s.collector.RecordStreamBytes(albumID, stem, w.n)
+}
+
+func wantsDownload(r *http.Request) bool {
+	q := r.URL.Query()
+	if q.Get("dl") == "1" {
+		return true
+	}
+	download, _ := strconv.ParseBool(q.Get("download"))
+	return download
+}
+
+// maxDownloadNameBytes keeps generated filenames under common filesystem
+// limits once the extension is added.
+const maxDownloadNameBytes = 200
+
+// downloadFilename turns a track title into a safe filename base: control
+// characters and characters reserved on common filesystems are dropped,
+// whitespace is collapsed and leading/trailing dots are trimmed. The stem is
+// used when nothing usable is left.
+func downloadFilename(title, stem string) string {
+	var b strings.Builder
+	for _, r := range title {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r) {
+			continue
+		}
+		if b.Len()+utf8.RuneLen(r) > maxDownloadNameBytes {
+			break
+		}
+		b.WriteRune(r)
+	}
+	name := strings.Trim(trimAndCollapseSpaces(b.String()), ". ")
+	if name == "" {
+		return stem
+	}
+	return name
+}
+
+// attachmentDisposition builds a Content-Disposition header for filename.
+// Non-ASCII names are sent RFC 2231-encoded via mime.FormatMediaType.
+func attachmentDisposition(filename string) string {
+	if v := mime.FormatMediaType("attachment", map[string]string{"filename": filename}); v != "" {
+		return v
+	}
+	return "attachment"
 }
 
 func (s *Server) handleGetLyrics(w http.ResponseWriter, r *http.Request) {
+	stem, trackDir, ok := s.lyricsTrackFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	resp := album.ServeLyrics(w, trackDir, stem)
+	if resp == nil {
+		jsonError(w, "no lyrics", http.StatusNotFound)
+		return
+	}
+
+	jsonOK(w, resp)
+}
+
+// handleHeadLyrics reports whether lyrics exist for a track via status code
+// and the X-Lyric-Format header, without reading or sending the file.
+func (s *Server) handleHeadLyrics(w http.ResponseWriter, r *http.Request) {
+	stem, trackDir, ok := s.lyricsTrackFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	format := album.LyricFormat(trackDir, stem)
+	if format == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("X-Lyric-Format", format)
+	w.WriteHeader(http.StatusOK)
+}
+
+// lyricsTrackFromRequest validates the {stem} param against the album's
+// track list and returns the directory holding its files.
+func (s *Server) lyricsTrackFromRequest(w http.ResponseWriter, r *http.Request) (string, string, bool) {
 	rawStem := chi.URLParam(r, "stem")
 	stem, err := normalizeStemParam(rawStem)
 	if err != nil {
 		jsonError(w, "bad request", http.StatusBadRequest)
-		return
+		return "", "", false
 	}
 
 	if !album.ValidateStem(stem) {
 		jsonError(w, "bad request", http.StatusBadRequest)
-		return
+		return "", "", false
 	}
 
 	alb := albumFromContext(r)
 	tracks, err := s.albumStore.GetTracks(alb.ID)
 	if err != nil {
 		jsonError(w, "internal error", http.StatusInternalServerError)
-		return
+		return "", "", false
 	}
-	if !album.StemInTracks(stem, tracks) {
+	track, ok := album.FindTrack(stem, tracks)
+	if !ok {
 		jsonError(w, "bad request", http.StatusBadRequest)
-		return
+		return "", "", false
 	}
-
-	resp := album.ServeLyrics(w, alb.AlbumPath, stem)
-	if resp == nil {
-		jsonError(w, "no lyrics", http.StatusNotFound)
-		return
+	trackDir, ok := album.TrackDir(alb.AlbumPath, track.Subdir)
+	if !ok {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return "", "", false
 	}
-
-	jsonOK(w, resp)
+	return stem, trackDir, true
 }
 
 func (s *Server) handleAnalytics(w http.ResponseWriter, r *http.Request) {
+	// Opted-out clients and disabled deployments get the same response as
+	// a recorded batch so the client cannot tell the difference.
+	if !s.analyticsAllowed(r) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
 	sessionID := s.getSessionID(r)
 	alb := albumFromContext(r)
 
+	// Reject oversized batches before reading or parsing anything.
+	if r.ContentLength > s.analyticsMaxBodyBytes {
+		jsonError(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			jsonError(w, "payload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		jsonError(w, "bad request", http.StatusBadRequest)
 		return
 	}
@@ -376,7 +678,10 @@ func (s *Server) handleAnalytics(w http.ResponseWriter, r *http.Request) {
 		albumID = alb.ID
 	}
 
-	if err := s.collector.RecordBatch(sessionID, body, albumID); err != nil {
+	// A resent batch carrying an ID already seen is acknowledged like the
+	// original so the client stops retrying.
+	batchID := strings.TrimSpace(r.Header.Get("X-Batch-ID"))
+	if _, err := s.collector.RecordBatchWithID(sessionID, batchID, body, albumID); err != nil {
 		jsonError(w, "bad request", http.StatusBadRequest)
 		return
 	}
@@ -384,6 +689,21 @@ func (s *Server) handleAnalytics(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// analyticsAllowed reports whether events from this request may be stored.
+func (s *Server) analyticsAllowed(r *http.Request) bool {
+	return s.analyticsEnabled && !analyticsOptedOut(r)
+}
+
+// analyticsOptedOut reports whether the client asked not to be tracked via
+// Do Not Track, Global Privacy Control, or the X-Analytics-Opt-Out header.
+func analyticsOptedOut(r *http.Request) bool {
+	if r.Header.Get("DNT") == "1" || r.Header.Get("Sec-GPC") == "1" {
+		return true
+	}
+	optOut, err := strconv.ParseBool(strings.TrimSpace(r.Header.Get("X-Analytics-Opt-Out")))
+	return err == nil && optOut
+}
+
 // --- Admin handlers ---
 
 func (s *Server) handleAdminAuth(w http.ResponseWriter, r *http.Request) {
@@ -424,7 +744,7 @@ func (s *Server) handleAdminAuth(w http.ResponseWriter, r *http.Request) {
 	user, err := s.authenticateAdminCredentials(username, req.Password)
 	if err != nil {
 		if errors.Is(err, errAdminInvalidCreds) {
-			s.adminLoginGuard.markFailure(loginGuardKey, time.Now().UTC())
+			s.markAdminLoginFailure(loginGuardKey, username, clientIP, "login")
 			s.recordAdminAuthAttempt(r, username, "rejected", "invalid_credentials")
 			jsonError(w, "unauthorized", http.StatusUnauthorized)
 			return
@@ -452,17 +772,24 @@ func (s *Server) handleAdminAuth(w http.ResponseWriter, r *http.Request) {
 		Name:     "acetate_admin",
 		Value:    sessionID,
 		Path:     "/admin",
-		MaxAge:   3600, // 1 hour
+		MaxAge:   s.sessions.AdminCookieMaxAge(),
 		HttpOnly: true,
 		Secure:   isSecureRequest(r),
 		SameSite: http.SameSiteStrictMode,
 	})
 
+	newDevice, err := s.sessions.RecordAdminDevice(user.ID, r.UserAgent())
+	if err != nil {
+		// Device tracking is informational; never fail a good login over it.
+		log.Printf("record admin device error: %v", err)
+	}
+
 	s.recordAdminAuthAttempt(r, user.Username, "success", "ok")
 	jsonOK(w, map[string]interface{}{
 		"status":                  "ok",
 		"username":                user.Username,
 		"password_reset_required": user.RequirePasswordReset,
+		"new_device":              newDevice,
 	})
 }
 
@@ -500,6 +827,11 @@ func (s *Server) handleAdminAnalytics(w http.ResponseWriter, r *http.Request) {
 
 	limit := clampInt(parseOptionalInt(r.URL.Query().Get("sessions_limit"), 50), 1, 200)
 
+	if s.analyticsAggregateOnly {
+		s.writeRollupAnalytics(w, filter)
+		return
+	}
+
 	trackStats, err := analytics.GetTrackStatsFiltered(s.db, filter)
 	if err != nil {
 		log.Printf("track stats error: %v", err)
@@ -529,11 +861,19 @@ func (s *Server) handleAdminAnalytics(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	streamBytes, err := analytics.GetStreamBytesFiltered(s.db, filter)
+	if err != nil {
+		log.Printf("stream bytes error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
 	jsonOK(w, map[string]interface{}{
-		"tracks":   trackStats,
-		"overall":  overall,
-		"sessions": sessions,
-		"heatmaps": heatmaps,
+		"tracks":       trackStats,
+		"overall":      overall,
+		"sessions":     sessions,
+		"heatmaps":     heatmaps,
+		"stream_bytes": streamBytes,
 		"filter": map[string]interface{}{
 			"from":        formatFilterTime(filter.From),
 			"to":          formatFilterTime(filter.To),
@@ -543,6 +883,90 @@ func (s *Server) handleAdminAnalytics(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// writeRollupAnalytics answers the album analytics request in aggregate-only
+// mode. Sessions and dropout heatmaps need raw events and are returned empty.
+func (s *Server) writeRollupAnalytics(w http.ResponseWriter, filter analytics.QueryFilter) {
+	trackStats, err := analytics.GetTrackStatsFromRollups(s.db, filter)
+	if err != nil {
+		log.Printf("rollup track stats error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	overall, err := analytics.GetOverallStatsFromRollups(s.db, filter)
+	if err != nil {
+		log.Printf("rollup overall stats error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	streamBytes, err := analytics.GetStreamBytesFiltered(s.db, filter)
+	if err != nil {
+		log.Printf("stream bytes error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"tracks":         trackStats,
+		"overall":        overall,
+		"sessions":       []analytics.SessionInfo{},
+		"heatmaps":       map[string][]analytics.DropoutBin{},
+		"stream_bytes":   streamBytes,
+		"aggregate_only": true,
+		"filter": map[string]interface{}{
+			"from":        formatFilterTime(filter.From),
+			"to":          formatFilterTime(filter.To),
+			"stems":       filter.Stems,
+			"event_types": filter.EventTypes,
+		},
+	})
+}
+
+// handleAdminAlbumFunnel reports how far through the album's configured
+// track order listening sessions got. The funnel needs raw events, so in
+// aggregate-only mode every step is zero.
+func (s *Server) handleAdminAlbumFunnel(w http.ResponseWriter, r *http.Request) {
+	alb := s.adminAlbumFromRequest(w, r)
+	if alb == nil {
+		return
+	}
+
+	filter, err := parseAnalyticsFilter(r.URL.Query())
+	if err != nil {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	filter.AlbumID = &alb.ID
+
+	tracks, err := s.albumStore.GetTracks(alb.ID)
+	if err != nil {
+		log.Printf("album funnel tracks error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	order := make([]string, len(tracks))
+	for i, t := range tracks {
+		order[i] = t.Stem
+	}
+
+	steps, err := analytics.GetAlbumFunnel(s.db, filter, order)
+	if err != nil {
+		log.Printf("album funnel error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"steps":          steps,
+		"aggregate_only": s.analyticsAggregateOnly,
+		"filter": map[string]interface{}{
+			"from": formatFilterTime(filter.From),
+			"to":   formatFilterTime(filter.To),
+		},
+	})
+}
+
 func (s *Server) handleAdminGetTracks(w http.ResponseWriter, r *http.Request) {
 	alb := s.adminAlbumFromRequest(w, r)
 	if alb == nil {
@@ -553,7 +977,7 @@ func (s *Server) handleAdminGetTracks(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "internal error", http.StatusInternalServerError)
 		return
 	}
-	trackInfos := album.GetTrackList(tracks, alb.AlbumPath)
+	trackInfos := album.GetTrackList(s.withCurrentDurations(alb, tracks), alb.AlbumPath)
 	jsonOK(w, trackInfos)
 }
 
@@ -564,15 +988,12 @@ func (s *Server) handleAdminUpdateTracks(w http.ResponseWriter, r *http.Request)
 	}
 
 	var req struct {
-		Title  string `json:"title"`
-		Artist string `json:"artist"`
-		Tracks []struct {
-			Stem         string `json:"stem"`
-			Title        string `json:"title"`
-			DisplayIndex string `json:"display_index,omitempty"`
-		} `json:"tracks"`
+		Title    string            `json:"title"`
+		Artist   string            `json:"artist"`
+		AllowNew bool              `json:"allow_new"`
+		Tracks   []adminTrackInput `json:"tracks"`
 	}
-	if err := decodeJSONBody(r, &req); err != nil {
+	if err := s.decodeCompatJSONBody(r, &req); err != nil {
 		jsonError(w, "bad request", http.StatusBadRequest)
 		return
 	}
@@ -600,8 +1021,16 @@ func (s *Server) handleAdminUpdateTracks(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
-		normalized, err := normalizeAdminTrackUpdate(req.Tracks, existingTracks, alb.AlbumPath)
+		normalized, err := normalizeAdminTrackUpdate(req.Tracks, existingTracks, alb.AlbumPath, s.albumExtensions, req.AllowNew)
 		if err != nil {
+			var verr *trackValidationError
+			if errors.As(err, &verr) {
+				jsonStatus(w, http.StatusBadRequest, map[string]interface{}{
+					"error":   "bad request: " + verr.Error(),
+					"details": verr.Issues,
+				})
+				return
+			}
 			jsonError(w, "bad request: "+err.Error(), http.StatusBadRequest)
 			return
 		}
@@ -638,6 +1067,10 @@ func (s *Server) handleAdminUpdateAdminPassword(w http.ResponseWriter, r *http.R
 			jsonError(w, "unauthorized", http.StatusUnauthorized)
 		case errors.Is(err, errAdminWeakPassword):
 			jsonError(w, "new password does not meet policy", http.StatusBadRequest)
+		case errors.Is(err, errAdminBreachedPassword):
+			jsonError(w, "new password appears in a known data breach", http.StatusBadRequest)
+		case errors.Is(err, errAdminUserConflict):
+			jsonError(w, err.Error(), http.StatusConflict)
 		default:
 			log.Printf("admin password update error: %v", err)
 			jsonError(w, "internal error", http.StatusInternalServerError)
@@ -665,38 +1098,8 @@ func (s *Server) handleAdminUploadCover(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	file, _, err := r.FormFile("cover")
-	if err != nil {
-		jsonError(w, "bad request", http.StatusBadRequest)
-		return
-	}
-	defer file.Close()
-
-	data, err := io.ReadAll(file)
+	img, err := readUploadedImage(r, "cover", 4096)
 	if err != nil {
-		jsonError(w, "read error", http.StatusBadRequest)
-		return
-	}
-
-	if len(data) == 0 {
-		jsonError(w, "bad request", http.StatusBadRequest)
-		return
-	}
-
-	contentType := http.DetectContentType(data)
-	if contentType != "image/jpeg" && contentType != "image/png" {
-		jsonError(w, "bad request", http.StatusBadRequest)
-		return
-	}
-
-	img, format, err := image.Decode(bytes.NewReader(data))
-	if err != nil || (format != "jpeg" && format != "png") {
-		jsonError(w, "bad request", http.StatusBadRequest)
-		return
-	}
-
-	b := img.Bounds()
-	if b.Dx() <= 0 || b.Dy() <= 0 || b.Dx() > 4096 || b.Dy() > 4096 {
 		jsonError(w, "bad request", http.StatusBadRequest)
 		return
 	}
@@ -723,6 +1126,42 @@ func (s *Server) handleAdminUploadCover(w http.ResponseWriter, r *http.Request)
 	jsonOK(w, map[string]string{"status": "ok"})
 }
 
+var errInvalidUploadImage = errors.New("invalid image upload")
+
+// readUploadedImage decodes a JPEG or PNG multipart upload from field,
+// rejecting empty files and images larger than maxDim on either side.
+func readUploadedImage(r *http.Request, field string, maxDim int) (image.Image, error) {
+	file, _, err := r.FormFile(field)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errInvalidUploadImage
+	}
+
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return nil, errInvalidUploadImage
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png") {
+		return nil, errInvalidUploadImage
+	}
+
+	b := img.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 || b.Dx() > maxDim || b.Dy() > maxDim {
+		return nil, errInvalidUploadImage
+	}
+	return img, nil
+}
+
 func (s *Server) handleAdminGetConfig(w http.ResponseWriter, r *http.Request) {
 	adminUsername := ""
 	passwordResetRequired := false
@@ -741,6 +1180,61 @@ func (s *Server) handleAdminGetConfig(w http.ResponseWriter, r *http.Request) {
 		"password_reset_required": passwordResetRequired,
 		"album_count":             albumCount,
 		"password_count":          len(passwords),
+		"password_policy":         s.passwordPolicy,
+	})
+}
+
+// rawConfigAlbum is an album with its full track list for the raw config dump.
+type rawConfigAlbum struct {
+	albums.Album
+	Tracks []albums.Track `json:"tracks"`
+}
+
+// rawConfigPassword mirrors albums.Password with the hash reduced to a flag.
+type rawConfigPassword struct {
+	albums.Password
+	HasPassword bool `json:"has_password"`
+}
+
+// handleAdminGetRawConfig returns every album, track, and listener password
+// record for debugging. Password hashes are never included.
+func (s *Server) handleAdminGetRawConfig(w http.ResponseWriter, r *http.Request) {
+	albumList, err := s.albumStore.ListAlbums()
+	if err != nil {
+		log.Printf("raw config albums error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	outAlbums := make([]rawConfigAlbum, 0, len(albumList))
+	for _, alb := range albumList {
+		tracks, err := s.albumStore.GetTracks(alb.ID)
+		if err != nil {
+			log.Printf("raw config tracks error: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if tracks == nil {
+			tracks = []albums.Track{}
+		}
+		outAlbums = append(outAlbums, rawConfigAlbum{Album: alb, Tracks: tracks})
+	}
+
+	passwords, err := s.albumStore.ListPasswords()
+	if err != nil {
+		log.Printf("raw config passwords error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	outPasswords := make([]rawConfigPassword, 0, len(passwords))
+	for _, p := range passwords {
+		hasPassword := p.PasswordHash != ""
+		p.PasswordHash = ""
+		outPasswords = append(outPasswords, rawConfigPassword{Password: p, HasPassword: hasPassword})
+	}
+
+	jsonOK(w, map[string]interface{}{
+		"albums":    outAlbums,
+		"passwords": outPasswords,
 	})
 }
 
@@ -767,14 +1261,19 @@ func (s *Server) handleSPA(w http.ResponseWriter, r *http.Request) {
 		path = "index.html"
 	}
 
-	// Set cache headers for static assets
-	if path != "index.html" && path != "sw.js" {
-		w.Header().Set("Cache-Control", "public, max-age=86400")
+	// Assets carry a content-hash ETag, so by default browsers revalidate
+	// and get a 304 until a deploy changes the file.
+	if path != "index.html" && path != "sw.js" && s.staticMaxAge > 0 {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.staticMaxAge.Seconds())))
 	} else {
 		w.Header().Set("Cache-Control", "no-cache")
 	}
 
-	serveEmbeddedFile(w, r, staticFS, path)
+	if path == "index.html" {
+		s.serveSPAIndex(w, r, staticFS)
+		return
+	}
+	s.serveEmbeddedFile(w, r, staticFS, "", path)
 }
 
 func (s *Server) handleAdminStatic(w http.ResponseWriter, r *http.Request) {
@@ -805,53 +1304,150 @@ func (s *Server) handleAdminStatic(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Cache-Control", "no-store")
-	serveEmbeddedFile(w, r, staticFS, path)
+	s.serveEmbeddedFile(w, r, staticFS, "admin/", path)
+}
+
+// Per-track validation reasons reported by normalizeAdminTrackUpdate.
+const (
+	trackIssueInvalidStem    = "invalid_stem"
+	trackIssueUnknownStem    = "unknown_stem"
+	trackIssueDuplicateStem  = "duplicate_stem"
+	trackIssueMissingAudio   = "missing_audio_file"
+	trackIssueEmptyTitle     = "empty_title"
+	trackIssueTitleTooLong   = "title_too_long"
+	trackIssueDisplayTooLong = "display_index_too_long"
+)
+
+const (
+	maxTrackTitleLen   = 256
+	maxDisplayIndexLen = 32
+)
+
+// trackIssue identifies one rejected entry in a track update by its position
+// in the request.
+type trackIssue struct {
+	Index  int    `json:"index"`
+	Stem   string `json:"stem"`
+	Reason string `json:"reason"`
+}
+
+// trackValidationError carries every per-track problem found in an update so
+// the admin UI can highlight all of them at once.
+type trackValidationError struct {
+	Issues []trackIssue
+}
+
+func (e *trackValidationError) Error() string {
+	return "invalid tracks"
 }
 
-func normalizeAdminTrackUpdate(input []struct {
+// adminTrackInput is one entry of an admin track list update.
+type adminTrackInput struct {
 	Stem         string `json:"stem"`
 	Title        string `json:"title"`
 	DisplayIndex string `json:"display_index,omitempty"`
-}, existing []albums.Track, albumPath string) ([]albums.Track, error) {
-	if len(input) == 0 || len(input) != len(existing) {
+	// AllowDownload is optional; when omitted the track keeps its setting.
+	AllowDownload *bool `json:"allow_download,omitempty"`
+}
+
+func normalizeAdminTrackUpdate(input []adminTrackInput, existing []albums.Track, albumPath string, extensions []string, allowNew bool) ([]albums.Track, error) {
+	if len(input) == 0 || len(input) < len(existing) || (!allowNew && len(input) != len(existing)) {
 		return nil, errors.New("invalid track count")
 	}
 
-	existingStems := make(map[string]struct{}, len(existing))
+	existingStems := make(map[string]albums.Track, len(existing))
 	for _, t := range existing {
-		existingStems[t.Stem] = struct{}{}
+		existingStems[t.Stem] = t
+	}
+
+	// New stems are only located on disk when the caller opted in, and the
+	// album is scanned at most once.
+	var onDisk map[string]config.Track
+	lookupNew := func(stem string) (albums.Track, bool) {
+		if onDisk == nil {
+			onDisk = make(map[string]config.Track)
+			scanned, err := config.ScanAlbumTracks(albumPath, extensions...)
+			if err != nil {
+				log.Printf("scan album for new tracks error: %v", err)
+			}
+			for _, t := range scanned {
+				onDisk[t.Stem] = t
+			}
+		}
+		t, ok := onDisk[stem]
+		return albums.Track{Stem: t.Stem, Subdir: t.Subdir, DurationSeconds: t.DurationSeconds, ModTime: t.ModTime}, ok
 	}
 
 	seen := make(map[string]struct{}, len(input))
 	normalized := make([]albums.Track, 0, len(input))
+	var issues []trackIssue
 
 	for i, t := range input {
 		stem := strings.TrimSpace(t.Stem)
 		title := trimAndCollapseSpaces(t.Title)
 		display := strings.TrimSpace(t.DisplayIndex)
 
-		if !album.ValidateStem(stem) || title == "" || len(title) > 256 || len(display) > 32 {
-			return nil, errors.New("invalid track fields")
+		reason := ""
+		current, known := existingStems[stem]
+		if !known && allowNew && album.ValidateStem(stem) {
+			current, known = lookupNew(stem)
 		}
-		if _, ok := existingStems[stem]; !ok {
-			return nil, errors.New("unknown stem")
+		_, dup := seen[stem]
+		switch {
+		case !album.ValidateStem(stem):
+			reason = trackIssueInvalidStem
+		case !known && allowNew:
+			reason = trackIssueMissingAudio
+		case !known:
+			reason = trackIssueUnknownStem
+		case dup:
+			reason = trackIssueDuplicateStem
+		case title == "":
+			reason = trackIssueEmptyTitle
+		case len(title) > maxTrackTitleLen:
+			reason = trackIssueTitleTooLong
+		case len(display) > maxDisplayIndexLen:
+			reason = trackIssueDisplayTooLong
+		default:
+			trackDir, ok := album.TrackDir(albumPath, current.Subdir)
+			if !ok {
+				reason = trackIssueInvalidStem
+			} else if _, _, ok := album.FindTrackFile(trackDir, stem, extensions...); !ok {
+				reason = trackIssueMissingAudio
+			}
 		}
-		if _, ok := seen[stem]; ok {
-			return nil, errors.New("duplicate stem")
+		if known {
+			seen[stem] = struct{}{}
 		}
-		if _, err := os.Stat(filepath.Join(albumPath, stem+".mp3")); err != nil {
-			return nil, errors.New("missing mp3")
+		if reason != "" {
+			issues = append(issues, trackIssue{Index: i, Stem: stem, Reason: reason})
+			continue
 		}
 
-		seen[stem] = struct{}{}
+		allowDownload := current.AllowDownload
+		if t.AllowDownload != nil {
+			allowDownload = *t.AllowDownload
+		}
 		normalized = append(normalized, albums.Track{
-			Stem:         stem,
-			Title:        title,
-			DisplayIndex: display,
-			SortOrder:    i,
+			Stem:            stem,
+			Title:           title,
+			DisplayIndex:    display,
+			SortOrder:       i,
+			Subdir:          current.Subdir,
+			AllowDownload:   allowDownload,
+			DurationSeconds: current.DurationSeconds,
+			ModTime:         current.ModTime,
 		})
 	}
 
+	if len(issues) > 0 {
+		return nil, &trackValidationError{Issues: issues}
+	}
+	for stem := range existingStems {
+		if _, ok := seen[stem]; !ok {
+			return nil, errors.New("missing existing stem")
+		}
+	}
 	return normalized, nil
 }
 
@@ -876,12 +1472,42 @@ func (s *Server) adminAlbumFromRequest(w http.ResponseWriter, r *http.Request) *
 	return alb
 }
 
-func serveEmbeddedFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, path string) {
-	data, err := fs.ReadFile(fsys, path)
+// precompressedEncodings lists the precompressed variants looked for next to
+// an embedded file, in order of preference.
+var precompressedEncodings = []struct{ coding, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// serveEmbeddedFile serves path from fsys, which is the embedded static tree
+// below root (for ETag lookup). When the client accepts it and a .br or .gz
+// sibling exists, that variant is sent with Content-Encoding instead. The
+// ETag lets http.ServeContent answer If-None-Match with 304.
+func (s *Server) serveEmbeddedFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, root, path string) {
+	servePath := path
+	encoding := ""
+	for _, enc := range precompressedEncodings {
+		if _, err := fs.Stat(fsys, path+enc.ext); err != nil {
+			continue
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		if acceptsEncoding(r, enc.coding) {
+			servePath, encoding = path+enc.ext, enc.coding
+			break
+		}
+	}
+
+	data, err := fs.ReadFile(fsys, servePath)
 	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
+	if etag := s.staticETags[root+servePath]; etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
 
 	if ctype := mime.TypeByExtension(filepath.Ext(path)); ctype != "" {
 		w.Header().Set("Content-Type", ctype)
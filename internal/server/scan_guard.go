@@ -0,0 +1,138 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"acetate/internal/analytics"
+)
+
+// DefaultScanWindow is the scan detection window used when none is set.
+// Detection itself is off unless a track threshold is configured.
+const DefaultScanWindow = time.Minute
+
+// scanGuard tracks which distinct tracks each listener session streamed
+// recently. Ordinary listening moves through an album at playback speed;
+// pulling many different tracks within a short window looks like a scraper
+// walking the track list.
+type scanGuard struct {
+	mu        sync.Mutex
+	threshold int
+	window    time.Duration
+	throttle  bool
+	entries   map[string]*scanEntry
+}
+
+type scanEntry struct {
+	seen    map[string]time.Time // stem -> last stream request
+	flagged time.Time            // zero until the session crosses the threshold
+}
+
+// newScanGuard returns a guard flagging threshold distinct tracks within
+// window. A non-positive threshold disables detection and returns nil.
+func newScanGuard(threshold int, window time.Duration, throttle bool) *scanGuard {
+	if threshold <= 0 {
+		return nil
+	}
+	if window <= 0 {
+		window = DefaultScanWindow
+	}
+	return &scanGuard{
+		threshold: threshold,
+		window:    window,
+		throttle:  throttle,
+		entries:   make(map[string]*scanEntry),
+	}
+}
+
+// observe records a stream of stem by session key. It returns the number of
+// distinct tracks streamed within the window, whether the session is
+// currently flagged, and whether this request is the one that flagged it.
+func (g *scanGuard) observe(key, stem string, now time.Time) (distinct int, flagged, newly bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.purgeStale(now)
+
+	entry, ok := g.entries[key]
+	if !ok {
+		entry = &scanEntry{seen: make(map[string]time.Time)}
+		g.entries[key] = entry
+	}
+	for s, at := range entry.seen {
+		if now.Sub(at) > g.window {
+			delete(entry.seen, s)
+		}
+	}
+	entry.seen[stem] = now
+	distinct = len(entry.seen)
+
+	if !entry.flagged.IsZero() && now.Sub(entry.flagged) > g.window {
+		entry.flagged = time.Time{}
+	}
+	if entry.flagged.IsZero() && distinct >= g.threshold {
+		entry.flagged = now
+		newly = true
+	}
+	return distinct, !entry.flagged.IsZero(), newly
+}
+
+// purgeStale drops sessions with no stream inside the window. Callers hold mu.
+func (g *scanGuard) purgeStale(now time.Time) {
+	for key, entry := range g.entries {
+		latest := entry.flagged
+		for _, at := range entry.seen {
+			if at.After(latest) {
+				latest = at
+			}
+		}
+		if now.Sub(latest) > g.window {
+			delete(g.entries, key)
+		}
+	}
+}
+
+// checkStreamScan runs scan detection for a stream. Session streams count
+// against the session; share link streams against the session that minted
+// the link.
+func (s *Server) checkStreamScan(w http.ResponseWriter, r *http.Request, albumID int64, stem string) bool {
+	key, ok := r.Context().Value(shareScanKey).(string)
+	if !ok {
+		key = s.getSessionID(r)
+	}
+	return s.checkScan(w, r, key, albumID, stem)
+}
+
+// checkScan records that key requested stem. The request that first crosses
+// the threshold records a scan_suspected analytics event. With throttling
+// on, flagged keys get 429 until the window passes; it reports false when
+// the request was refused.
+func (s *Server) checkScan(w http.ResponseWriter, r *http.Request, key string, albumID int64, stem string) bool {
+	if s.scanGuard == nil || key == "" {
+		return true
+	}
+
+	distinct, flagged, newly := s.scanGuard.observe(key, stem, time.Now())
+	if newly {
+		log.Printf("scan suspected: album=%d distinct_tracks=%d window=%s", albumID, distinct, s.scanGuard.window)
+		if s.analyticsAllowed(r) {
+			s.collector.Record(analytics.Event{
+				SessionID: key,
+				EventType: "scan_suspected",
+				TrackStem: stem,
+				AlbumID:   albumID,
+				Metadata:  fmt.Sprintf(`{"distinct_tracks":%d,"window_seconds":%d}`, distinct, int(s.scanGuard.window.Seconds())),
+			})
+		}
+	}
+	if flagged && s.scanGuard.throttle {
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, int(s.scanGuard.window.Seconds()))))
+		jsonError(w, "too many tracks requested", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
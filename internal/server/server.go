@@ -6,16 +6,22 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"io/fs"
 	"log"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	acetate "acetate"
 	"acetate/internal/albums"
 	"acetate/internal/analytics"
 	"acetate/internal/auth"
+	"acetate/internal/config"
 )
 
 // Server is the main HTTP server.
@@ -25,65 +31,316 @@ type Server struct {
 	albumStore             *albums.Store
 	sessions               *auth.SessionStore
 	rateLimiter            *auth.RateLimiter
+	publicLimiter          *auth.RateLimiter
+	adminAPILimiter        *auth.RateLimiter
+	adminMutationLimiter   *auth.RateLimiter
+	feedbackLimiter        *auth.RateLimiter
+	shareLimiter           *auth.RateLimiter
 	adminLoginGuard        *adminLoginGuard
+	lockoutWebhookURL      string
 	cfIPs                  *auth.CloudflareIPs
 	collector              *analytics.Collector
+	checksums              *checksumCache
+	mp3Info                *mp3InfoCache
+	transcodes             *transcodeCache // nil when transcoding is disabled or ffmpeg is missing
+	scanGuard              *scanGuard      // nil when scan detection is disabled
+	ipConcurrency          *ipConcurrency  // nil when per-IP concurrency is unlimited
+	conditional            conditionalStats
 	dataPath               string
 	albumBasePath          string
+	albumExtensions        []string
+	albumWatchInterval     time.Duration // zero disables the album folder watcher
+	albumWatch             map[int64]*albumWatchState
 	analyticsRetentionDays int
+	auditRetentionDays     int
+	flushStallThreshold    time.Duration
+	adminInactiveDays      int // zero disables idle admin deactivation
 	maintenanceInterval    time.Duration
+	maintenanceJitter      float64
+	maintenanceOnStart     bool
+	maintenanceRand        func() float64
+	apiWriteTimeout        time.Duration
+	streamWriteTimeout     time.Duration
+	requireCloudflareReady bool
+	adminBasicAuth         bool
+	analyticsEnabled       bool
+	analyticsAggregateOnly bool
+	analyticsMaxBodyBytes  int64
+	jsonAllowUnknownFields bool // relaxed decoding on decodeCompatJSONBody endpoints
+	exportMaxRows          int
+	bodyLimits             BodyLimits
+	passwordPolicy         PasswordPolicy
+	pwnedChecker           *auth.PwnedChecker // nil disables the breached-password check
+	staticETags            map[string]string  // content hashes of embedded files under static/
+	staticMaxAge           time.Duration      // zero makes browsers revalidate assets on every load
+	referrerPolicy         string
+	shareKey               []byte        // HMAC key for signed share links
+	shareMaxTTL            time.Duration // upper bound on share link lifetime
+	permissionsPolicy      string
+	cspReportEnabled       bool // add report-uri/report-to to the CSP and accept reports
+	cspReports             *cspReportLog
 	startedAt              time.Time
 	maintenanceDone        chan struct{}
 	maintenanceWG          sync.WaitGroup
 	maintenanceStopOnce    sync.Once
+	maintenanceMu          sync.Mutex // serializes scheduled and manual maintenance runs
 }
 
+// Default per-request write deadlines. Streaming and exports get the long
+// one; every other handler gets the short one.
+const (
+	DefaultAPIWriteTimeout    = 30 * time.Second
+	DefaultStreamWriteTimeout = 5 * time.Minute
+)
+
+// adminAPIAuthRateLimit caps HTTP Basic and API token admin requests per
+// client IP per minute. Basic auth pays a bcrypt comparison on every request,
+// so this is kept modest.
+const adminAPIAuthRateLimit = 120
+
+// DefaultAdminMutationRateLimit is the number of mutating admin requests each
+// admin user may make per minute.
+const DefaultAdminMutationRateLimit = 120
+
+// DefaultExportMaxRows caps how many events a single export request returns.
+const DefaultExportMaxRows = 200000
+
 // Config holds server configuration.
 type Config struct {
 	ListenAddr             string
 	DataPath               string
 	AlbumBasePath          string
+	AlbumExtensions        []string
+	AlbumWatchInterval     time.Duration
+	StaticMaxAge           time.Duration
+	TranscodeEnabled       bool   // serve ?format=mp3 via ffmpeg when it is on PATH
+	ReferrerPolicy         string // empty means DefaultReferrerPolicy
+	PermissionsPolicy      string // empty means DefaultPermissionsPolicy
+	CSPReportEnabled       bool   // ask browsers to POST CSP violations to /api/csp-report
+	ShareSigningKey        string // empty means a generated key under DataPath
+	ShareMaxTTL            time.Duration
+	ScanDetectTracks       int // zero or negative disables detection
+	ScanDetectWindow       time.Duration
+	ScanThrottle           bool
+	MaxConcurrentPerIP     int // zero or negative means unlimited
 	AnalyticsRetentionDays int
+	AuditRetentionDays     int // zero means analytics.DefaultAuditRetentionDays; negative keeps rows forever
+	AdminInactiveDays      int // deactivate admins idle this many days during maintenance; zero disables
 	MaintenanceInterval    time.Duration
+	MaintenanceJitter      float64
+	MaintenanceOnStart     bool // run maintenance immediately on boot instead of after a jittered delay
+	APIWriteTimeout        time.Duration
+	StreamWriteTimeout     time.Duration
+	// RequireCloudflareReady makes /readyz fail until Cloudflare ranges load.
+	RequireCloudflareReady bool
+	CloudflareIPURLs       []string     // empty means Cloudflare's published range lists
+	ClientIPHeader         string       // empty means auth.DefaultClientIPHeader
+	TrustedProxies         []*net.IPNet // peers allowed to supply ClientIPHeader; see auth.ParseTrustedProxies
+	IPv6Prefix             int          // key IPv6 clients by this prefix length; zero uses the full address
+	AdminBasicAuth         bool
+	AdminMutationRateLimit int
+	LockoutWebhookURL      string
+	// SharedRateLimits stores rate limit windows and admin login lockouts
+	// in the database so instances sharing it enforce one limit.
+	SharedRateLimits  bool
+	AnonymousSessions bool
+	AdminFingerprint  auth.FingerprintBinding
+	SessionBackend    auth.SessionBackendKind // empty means auth.SessionBackendSQLite
+	// Admin session sliding expiry; see auth.SessionStoreOptions.
+	AdminSessionSliding    bool
+	AdminIdleTimeout       time.Duration
+	AdminMaxLifetime       time.Duration
+	SessionTouchWindow     time.Duration
+	AdminTouchWindow       time.Duration
+	AnalyticsDisabled      bool
+	AnalyticsAggregateOnly bool
+	AnalyticsMaxBodyBytes  int64
+	AnalyticsMaxBatchSize  int
+	AnalyticsBufferSize    int
+	AnalyticsSpillMaxBytes int64 // zero disables the on-disk overflow queue
+	AnalyticsBatchIDTTL    time.Duration
+	AnalyticsFlushStall    time.Duration // zero means analytics.DefaultFlushStallThreshold
+	CompleteMinFraction    float64       // zero disables the complete-event position check
+	ExportMaxRows          int
+	JSONAllowUnknownFields bool // ignore unknown fields on compatibility endpoints
+	BodyLimits             BodyLimits
+	PasswordPolicy         PasswordPolicy
+	PwnedCheck             bool
+	PwnedRangeURL          string
 	DB                     *sql.DB
 	AlbumStore             *albums.Store
 }
 
 // New creates a new Server with all dependencies wired.
 func New(cfg Config) *Server {
-	sessions := auth.NewSessionStore(cfg.DB)
-	rateLimiter := auth.NewRateLimiter()
-	cfIPs := auth.NewCloudflareIPs()
-	collector := analytics.NewCollector(cfg.DB)
+	sessions := auth.NewSessionStoreWithOptions(cfg.DB, auth.SessionStoreOptions{
+		Anonymous:        cfg.AnonymousSessions,
+		AdminFingerprint: cfg.AdminFingerprint,
+		AdminSliding:     cfg.AdminSessionSliding,
+		AdminIdleTimeout: cfg.AdminIdleTimeout,
+		AdminMaxLifetime: cfg.AdminMaxLifetime,
+		TouchWindow:      cfg.SessionTouchWindow,
+		AdminTouchWindow: cfg.AdminTouchWindow,
+		Backend:          auth.NewSessionBackend(cfg.SessionBackend, cfg.DB),
+	})
+	// Limits are kept in memory, or in the database when several instances
+	// must enforce them together.
+	newLimiter := func(scope string, limit int, period time.Duration) *auth.RateLimiter {
+		if cfg.SharedRateLimits {
+			return auth.NewSharedRateLimiter(cfg.DB, scope, limit, period)
+		}
+		return auth.NewRateLimiterWithLimit(limit, period)
+	}
+	loginGuard := newAdminLoginGuard()
+	if cfg.SharedRateLimits {
+		loginGuard = newSharedAdminLoginGuard(cfg.DB)
+	}
+	rateLimiter := newLimiter("auth", auth.RateLimit, auth.RateWindow)
+	// Public metadata is cheap to serve, so its limit stays per instance
+	// rather than costing a database write on every request.
+	publicLimiter := auth.NewRateLimiterWithLimit(60, time.Minute)
+	cfIPs := auth.NewCloudflareIPsFromURLs(cfg.CloudflareIPURLs)
+	cfIPs.SetClientIPHeader(cfg.ClientIPHeader)
+	cfIPs.SetTrustedProxies(cfg.TrustedProxies)
+	cfIPs.SetIPv6Prefix(cfg.IPv6Prefix)
+	collectorOpts := analytics.CollectorOptions{
+		MaxBatchSize:  cfg.AnalyticsMaxBatchSize,
+		AggregateOnly: cfg.AnalyticsAggregateOnly,
+		BufferSize:    cfg.AnalyticsBufferSize,
+		BatchIDTTL:    cfg.AnalyticsBatchIDTTL,
+	}
+	if cfg.DataPath != "" {
+		collectorOpts.DeadLetterPath = filepath.Join(cfg.DataPath, "analytics-deadletter.jsonl")
+		collectorOpts.PendingPath = filepath.Join(cfg.DataPath, "analytics-pending.jsonl")
+		if cfg.AnalyticsSpillMaxBytes > 0 {
+			collectorOpts.SpillPath = filepath.Join(cfg.DataPath, "analytics-spill.jsonl")
+			collectorOpts.SpillMaxBytes = cfg.AnalyticsSpillMaxBytes
+		}
+	}
+	collector := analytics.NewCollectorWithOptions(cfg.DB, collectorOpts)
 
 	s := &Server{
 		db:                     cfg.DB,
 		albumStore:             cfg.AlbumStore,
 		sessions:               sessions,
 		rateLimiter:            rateLimiter,
-		adminLoginGuard:        newAdminLoginGuard(),
+		publicLimiter:          publicLimiter,
+		adminAPILimiter:        newLimiter("admin-api", adminAPIAuthRateLimit, time.Minute),
+		feedbackLimiter:        newLimiter("feedback", feedbackRateLimit, time.Minute),
+		shareLimiter:           newLimiter("share", shareRateLimit, time.Minute),
+		adminLoginGuard:        loginGuard,
+		lockoutWebhookURL:      strings.TrimSpace(cfg.LockoutWebhookURL),
 		cfIPs:                  cfIPs,
 		collector:              collector,
+		checksums:              newChecksumCache(),
+		mp3Info:                newMP3InfoCache(),
 		dataPath:               cfg.DataPath,
 		albumBasePath:          cfg.AlbumBasePath,
+		albumExtensions:        cfg.AlbumExtensions,
+		albumWatchInterval:     cfg.AlbumWatchInterval,
+		albumWatch:             make(map[int64]*albumWatchState),
 		analyticsRetentionDays: cfg.AnalyticsRetentionDays,
+		auditRetentionDays:     cfg.AuditRetentionDays,
+		flushStallThreshold:    cfg.AnalyticsFlushStall,
+		adminInactiveDays:      cfg.AdminInactiveDays,
 		maintenanceInterval:    cfg.MaintenanceInterval,
+		maintenanceJitter:      cfg.MaintenanceJitter,
+		maintenanceOnStart:     cfg.MaintenanceOnStart,
+		maintenanceRand:        rand.Float64,
+		apiWriteTimeout:        cfg.APIWriteTimeout,
+		streamWriteTimeout:     cfg.StreamWriteTimeout,
+		requireCloudflareReady: cfg.RequireCloudflareReady,
+		adminBasicAuth:         cfg.AdminBasicAuth,
+		analyticsEnabled:       !cfg.AnalyticsDisabled,
+		analyticsAggregateOnly: cfg.AnalyticsAggregateOnly,
+		analyticsMaxBodyBytes:  cfg.AnalyticsMaxBodyBytes,
+		exportMaxRows:          cfg.ExportMaxRows,
+		jsonAllowUnknownFields: cfg.JSONAllowUnknownFields,
+		bodyLimits:             cfg.BodyLimits.withDefaults(),
+		passwordPolicy:         cfg.PasswordPolicy.withDefaults(),
+		staticMaxAge:           cfg.StaticMaxAge,
+		referrerPolicy:         normalizeReferrerPolicy(cfg.ReferrerPolicy),
+		permissionsPolicy:      strings.TrimSpace(cfg.PermissionsPolicy),
+		cspReportEnabled:       cfg.CSPReportEnabled,
+		cspReports:             newCSPReportLog(),
+		shareMaxTTL:            cfg.ShareMaxTTL,
 		startedAt:              time.Now().UTC(),
 		maintenanceDone:        make(chan struct{}),
 	}
+	if cfg.PwnedCheck {
+		s.pwnedChecker = auth.NewPwnedChecker(cfg.PwnedRangeURL)
+	}
+	mutationLimit := cfg.AdminMutationRateLimit
+	if mutationLimit <= 0 {
+		mutationLimit = DefaultAdminMutationRateLimit
+	}
+	s.adminMutationLimiter = newLimiter("admin-mutation", mutationLimit, time.Minute)
+	if s.auditRetentionDays == 0 {
+		s.auditRetentionDays = analytics.DefaultAuditRetentionDays
+	}
 	if s.maintenanceInterval <= 0 {
 		s.maintenanceInterval = 12 * time.Hour
 	}
+	if s.maintenanceJitter < 0 || s.maintenanceJitter > 1 {
+		s.maintenanceJitter = DefaultMaintenanceJitter
+	}
+	if len(s.albumExtensions) == 0 {
+		s.albumExtensions = config.DefaultAlbumExtensions
+	}
+	if s.analyticsMaxBodyBytes <= 0 {
+		s.analyticsMaxBodyBytes = analytics.DefaultMaxBodyBytes
+	}
+	if s.exportMaxRows <= 0 {
+		s.exportMaxRows = DefaultExportMaxRows
+	}
+	if s.permissionsPolicy == "" {
+		s.permissionsPolicy = DefaultPermissionsPolicy
+	}
+	if s.shareMaxTTL <= 0 {
+		s.shareMaxTTL = DefaultShareMaxTTL
+	}
+	shareKey, err := loadShareKey(cfg.ShareSigningKey, cfg.DataPath)
+	if err != nil {
+		log.Printf("WARNING: share signing key: %v; share links will not survive a restart", err)
+		shareKey, _ = randomShareKey()
+	}
+	s.shareKey = shareKey
+	s.scanGuard = newScanGuard(cfg.ScanDetectTracks, cfg.ScanDetectWindow, cfg.ScanThrottle)
+	s.ipConcurrency = newIPConcurrency(cfg.MaxConcurrentPerIP)
+	s.conditional = newConditionalStats()
+	collector.SetCompletionCheck(cfg.CompleteMinFraction, s.trackDurationSeconds)
+	if s.apiWriteTimeout <= 0 {
+		s.apiWriteTimeout = DefaultAPIWriteTimeout
+	}
+	if s.streamWriteTimeout <= 0 {
+		s.streamWriteTimeout = DefaultStreamWriteTimeout
+	}
+	if cfg.TranscodeEnabled {
+		if tc := newFFmpegTranscoder(); tc == nil {
+			log.Println("WARNING: TRANSCODE_ENABLED is set but ffmpeg was not found on PATH; ?format=mp3 will return 501")
+		} else {
+			s.transcodes = newTranscodeCache(filepath.Join(cfg.DataPath, "transcode"), tc)
+		}
+	}
+
+	if staticFS, err := fs.Sub(acetate.StaticFS, "static"); err == nil {
+		if s.staticETags, err = hashEmbeddedFiles(staticFS); err != nil {
+			log.Printf("hash static files error: %v", err)
+		}
+	}
 
 	s.httpServer = &http.Server{
 		Addr:         cfg.ListenAddr,
 		Handler:      s.routes(),
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 5 * time.Minute, // Long for MP3 streaming
+		WriteTimeout: s.streamWriteTimeout, // Ceiling; handlers narrow it via writeDeadline
 		IdleTimeout:  120 * time.Second,
 	}
 
 	s.startMaintenanceLoop()
+	if s.albumWatchInterval > 0 {
+		s.startAlbumWatcher()
+	}
 
 	return s
 }
@@ -105,14 +362,24 @@ func (s *Server) Shutdown(ctx context.Context) {
 		log.Printf("HTTP shutdown error: %v", err)
 	}
 
-	s.stopMaintenanceLoop()
+	// A maintenance run still going when ctx's deadline passes is cut off
+	// when the process exits.
+	waitBeforeDeadline(ctx, "maintenance", s.stopMaintenanceLoop)
 
+	// The collector bounds its own final write by ctx and saves whatever it
+	// could not write to the pending file, so it is waited for in full: it
+	// must be done with the database before the caller closes it.
 	log.Println("flushing analytics...")
-	s.collector.Close()
+	s.collector.CloseContext(ctx)
 
 	log.Println("stopping background tasks...")
 	s.sessions.Close()
 	s.rateLimiter.Close()
+	s.publicLimiter.Close()
+	s.adminAPILimiter.Close()
+	s.adminMutationLimiter.Close()
+	s.feedbackLimiter.Close()
+	s.shareLimiter.Close()
 	s.cfIPs.Close()
 }
 
@@ -122,29 +389,42 @@ func (s *Server) startMaintenanceLoop() {
 		defer s.maintenanceWG.Done()
 
 		run := func() {
+			s.maintenanceMu.Lock()
+			defer s.maintenanceMu.Unlock()
+
 			flushCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 			_ = s.collector.FlushNow(flushCtx)
 			cancel()
 
-			res, err := analytics.RunMaintenance(s.db, time.Now().UTC(), s.analyticsRetentionDays)
+			if pruned, err := s.pruneExpiredShares(time.Now()); err != nil {
+				log.Printf("share link prune error: %v", err)
+			} else if pruned > 0 {
+				log.Printf("share link maintenance: pruned_rows=%d", pruned)
+			}
+
+			res, err := s.runMaintenance(time.Now().UTC(), s.analyticsRetentionDays)
 			if err != nil {
 				log.Printf("analytics maintenance error: %v", err)
 				return
 			}
-			if res.RolledDays > 0 || res.PrunedRows > 0 {
-				log.Printf("analytics maintenance: rolled_days=%d rollup_rows=%d pruned_rows=%d retention_days=%d",
-					res.RolledDays, res.RollupRows, res.PrunedRows, res.RetentionDays)
+			if res.RolledDays > 0 || res.PrunedRows > 0 || res.PrunedAuditRows > 0 || res.PrunedStreamBytesRows > 0 {
+				log.Printf("analytics maintenance: rolled_days=%d rollup_rows=%d pruned_rows=%d retention_days=%d pruned_audit_rows=%d pruned_stream_bytes_rows=%d",
+					res.RolledDays, res.RollupRows, res.PrunedRows, res.RetentionDays, res.PrunedAuditRows, res.PrunedStreamBytesRows)
+			}
+			if len(res.DeactivatedAdmins) > 0 {
+				log.Printf("analytics maintenance: deactivated idle admins (inactive_days=%d): %s",
+					res.AdminInactiveDays, strings.Join(res.DeactivatedAdmins, ", "))
 			}
 		}
 
-		run()
-		ticker := time.NewTicker(s.maintenanceInterval)
-		defer ticker.Stop()
+		timer := time.NewTimer(initialMaintenanceDelay(s.maintenanceInterval, s.maintenanceJitter, s.maintenanceOnStart, s.maintenanceRand))
+		defer timer.Stop()
 
 		for {
 			select {
-			case <-ticker.C:
+			case <-timer.C:
 				run()
+				timer.Reset(nextMaintenanceDelay(s.maintenanceInterval, s.maintenanceJitter, s.maintenanceRand))
 			case <-s.maintenanceDone:
 				return
 			}
@@ -152,6 +432,49 @@ func (s *Server) startMaintenanceLoop() {
 	}()
 }
 
+// DefaultMaintenanceJitter is the default fraction of the maintenance interval
+// used as a random offset, so instances started together spread their runs.
+const DefaultMaintenanceJitter = 0.1
+
+// maintenanceJitterOffset returns a random offset in [0, jitter*interval).
+func maintenanceJitterOffset(interval time.Duration, jitter float64, rnd func() float64) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rnd() * jitter * float64(interval))
+}
+
+// initialMaintenanceDelay is zero when the first run is immediate, otherwise
+// a jittered offset.
+func initialMaintenanceDelay(interval time.Duration, jitter float64, immediate bool, rnd func() float64) time.Duration {
+	if immediate {
+		return 0
+	}
+	return maintenanceJitterOffset(interval, jitter, rnd)
+}
+
+// nextMaintenanceDelay is the interval plus a jittered offset.
+func nextMaintenanceDelay(interval time.Duration, jitter float64, rnd func() float64) time.Duration {
+	return interval + maintenanceJitterOffset(interval, jitter, rnd)
+}
+
+// waitBeforeDeadline runs fn and waits for it until ctx is done. It reports
+// whether fn finished; if not, fn keeps running in the background.
+func waitBeforeDeadline(ctx context.Context, name string, fn func()) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		log.Printf("shutdown: gave up waiting for %s: %v", name, ctx.Err())
+		return false
+	}
+}
+
 func (s *Server) stopMaintenanceLoop() {
 	s.maintenanceStopOnce.Do(func() {
 		close(s.maintenanceDone)
@@ -182,6 +505,14 @@ func jsonCreated(w http.ResponseWriter, data interface{}) {
 	}
 }
 
+func jsonStatus(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("json encode error: %v", err)
+	}
+}
+
 func (s *Server) getSessionID(r *http.Request) string {
 	cookie, err := r.Cookie("acetate_session")
 	if err != nil {
@@ -194,6 +525,52 @@ func limitBody(r *http.Request, maxBytes int64) {
 	r.Body = http.MaxBytesReader(nil, r.Body, maxBytes)
 }
 
+// BodyLimits is the request body size policy, in bytes, for routes that
+// accept a body. Zero fields fall back to DefaultBodyLimits.
+type BodyLimits struct {
+	Auth        int64 // listener and admin login
+	Form        int64 // small admin JSON forms (users, passwords, albums, ops)
+	Tracks      int64 // full track list updates
+	TrackImport int64 // CSV/JSON track manifests
+	Cover       int64 // cover image uploads
+	Logo        int64 // brand logo uploads
+}
+
+// DefaultBodyLimits returns the built-in body size policy.
+func DefaultBodyLimits() BodyLimits {
+	return BodyLimits{
+		Auth:        1024,
+		Form:        4096,
+		Tracks:      100 << 10,
+		TrackImport: 1 << 20,
+		Cover:       10 << 20,
+		Logo:        2 << 20,
+	}
+}
+
+func (b BodyLimits) withDefaults() BodyLimits {
+	d := DefaultBodyLimits()
+	if b.Auth <= 0 {
+		b.Auth = d.Auth
+	}
+	if b.Form <= 0 {
+		b.Form = d.Form
+	}
+	if b.Tracks <= 0 {
+		b.Tracks = d.Tracks
+	}
+	if b.TrackImport <= 0 {
+		b.TrackImport = d.TrackImport
+	}
+	if b.Cover <= 0 {
+		b.Cover = d.Cover
+	}
+	if b.Logo <= 0 {
+		b.Logo = d.Logo
+	}
+	return b
+}
+
 // bodyLimiter middleware limits the request body size.
 func bodyLimiter(maxBytes int64) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -204,9 +581,24 @@ func bodyLimiter(maxBytes int64) func(http.Handler) http.Handler {
 	}
 }
 
+// decodeJSONBody decodes a single JSON value, rejecting unknown fields.
 func decodeJSONBody(r *http.Request, dst interface{}) error {
+	return decodeJSON(r, dst, false)
+}
+
+// decodeCompatJSONBody decodes a request body for endpoints where clients
+// of a different version may send fields this server does not know about.
+// Unknown fields are ignored when JSON_ALLOW_UNKNOWN_FIELDS is set; auth,
+// password, and account endpoints always use decodeJSONBody.
+func (s *Server) decodeCompatJSONBody(r *http.Request, dst interface{}) error {
+	return decodeJSON(r, dst, s.jsonAllowUnknownFields)
+}
+
+func decodeJSON(r *http.Request, dst interface{}, allowUnknown bool) error {
 	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
+	if !allowUnknown {
+		dec.DisallowUnknownFields()
+	}
 
 	if err := dec.Decode(dst); err != nil {
 		return err
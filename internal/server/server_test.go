@@ -2,20 +2,39 @@ package server
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"io"
+	"log"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
 	"time"
 
+	"acetate/internal/album"
 	"acetate/internal/albums"
+	"acetate/internal/analytics"
+	"acetate/internal/auth"
+	"acetate/internal/config"
 	"acetate/internal/database"
 
 	"golang.org/x/crypto/bcrypt"
@@ -41,6 +60,30 @@ func setupTest(t *testing.T) *testEnv {
 
 func setupTestWithBootstrap(t *testing.T, username, password, passwordHash string) *testEnv {
 	t.Helper()
+	return setupTestEnv(t, username, password, passwordHash, nil)
+}
+
+// testMP3Frames returns an untagged MP3 of frames MPEG-1 Layer III frames at
+// 128 kbps, 44.1 kHz, joint stereo (about 26 ms each).
+func testMP3Frames(frames int) []byte {
+	var data []byte
+	for i := 0; i < frames; i++ {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xFF, 0xFB, 0x90, 0x40})
+		data = append(data, frame...)
+	}
+	return data
+}
+
+// setupTestWithConfig is setupTest with configure applied to the server
+// Config before the server is built.
+func setupTestWithConfig(t *testing.T, configure func(*Config)) *testEnv {
+	t.Helper()
+	return setupTestEnv(t, testAdminUsername, testAdminPassword, "", configure)
+}
+
+func setupTestEnv(t *testing.T, username, password, passwordHash string, configure func(*Config)) *testEnv {
+	t.Helper()
 
 	albumDir := t.TempDir()
 	dataDir := t.TempDir()
@@ -83,14 +126,18 @@ func setupTestWithBootstrap(t *testing.T, username, password, passwordHash strin
 		t.Fatalf("create password: %v", err)
 	}
 
-	srv := New(Config{
+	cfg := Config{
 		ListenAddr:             ":0",
 		DataPath:               dataDir,
 		AnalyticsRetentionDays: 0,
 		MaintenanceInterval:    time.Hour,
 		DB:                     db,
 		AlbumStore:             store,
-	})
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+	srv := New(cfg)
 
 	ts := httptest.NewServer(srv.routes())
 	t.Cleanup(func() {
@@ -98,6 +145,10 @@ func setupTestWithBootstrap(t *testing.T, username, password, passwordHash strin
 		srv.collector.Close()
 		srv.sessions.Close()
 		srv.rateLimiter.Close()
+		srv.publicLimiter.Close()
+		srv.adminAPILimiter.Close()
+		srv.adminMutationLimiter.Close()
+		srv.feedbackLimiter.Close()
 		srv.cfIPs.Close()
 	})
 
@@ -184,6 +235,33 @@ func (env *testEnv) authenticateAdminAs(t *testing.T, username, password string)
 	return resp.Cookies(), payload, resp.StatusCode
 }
 
+// adminDo sends an admin API request with the given cookies and a same-origin Origin header.
+func (env *testEnv) adminDo(t *testing.T, cookies []*http.Cookie, method, path string, payload interface{}) *http.Response {
+	t.Helper()
+
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatalf("marshal payload: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, env.ts.URL+path, body)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Origin", env.ts.URL)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return resp
+}
+
 func TestAuthFlow(t *testing.T) {
 	env := setupTest(t)
 
@@ -325,1297 +403,5534 @@ func TestStreamTrackStemWithSpaces(t *testing.T) {
 	}
 }
 
-func TestLyrics(t *testing.T) {
+func TestStreamTrackDownloadDisposition(t *testing.T) {
 	env := setupTest(t)
 	cookies := env.authenticate(t)
 
-	req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/lyrics/01-gathering", nil)
-	for _, c := range cookies {
-		req.AddCookie(c)
-	}
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("lyrics request: %v", err)
+	download := func(query string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/stream/01-gathering"+query, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("stream request: %v", err)
+		}
+		resp.Body.Close()
+		return resp
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		t.Errorf("lyrics status = %d, want 200", resp.StatusCode)
+	// Downloads are off by default.
+	if resp := download("?download=true"); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("disabled download status = %d, want 403", resp.StatusCode)
 	}
-
-	var result map[string]string
-	json.NewDecoder(resp.Body).Decode(&result)
-	if result["format"] != "lrc" {
-		t.Errorf("lyrics format = %q, want lrc", result["format"])
+	if resp := download(""); resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Disposition") != "" {
+		t.Fatalf("inline stream = %d, disposition %q", resp.StatusCode, resp.Header.Get("Content-Disposition"))
 	}
-}
-
-func TestCover(t *testing.T) {
-	env := setupTest(t)
-	cookies := env.authenticate(t)
 
-	req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/cover", nil)
-	for _, c := range cookies {
-		req.AddCookie(c)
+	if err := env.srv.albumStore.SetDownloadsEnabled(env.albumID, true); err != nil {
+		t.Fatalf("enable downloads: %v", err)
 	}
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("cover request: %v", err)
+	if err := env.srv.albumStore.SetTracks(env.albumID, []albums.Track{
+		{Stem: "01-gathering", Title: ` ..The "Gathering"/Part\1: Ø?`},
+		{Stem: "02-hollow", Title: "Hollow"},
+	}); err != nil {
+		t.Fatalf("set tracks: %v", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		t.Errorf("cover status = %d, want 200", resp.StatusCode)
-	}
-	if etag := resp.Header.Get("ETag"); etag == "" {
-		t.Error("cover should have ETag")
+	for _, query := range []string{"?download=true", "?dl=1"} {
+		resp := download(query)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s status = %d, want 200", query, resp.StatusCode)
+		}
+		disposition, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition"))
+		if err != nil || disposition != "attachment" {
+			t.Fatalf("%s disposition = %q, %v", query, resp.Header.Get("Content-Disposition"), err)
+		}
+		if want := "The GatheringPart1 Ø.mp3"; params["filename"] != want {
+			t.Fatalf("%s filename = %q, want %q", query, params["filename"], want)
+		}
 	}
 }
 
-func TestCoverSupportsJPEGFallback(t *testing.T) {
+func TestUpdateTracksUnknownFieldsRelaxedMode(t *testing.T) {
 	env := setupTest(t)
-	cookies := env.authenticate(t)
-
-	if err := os.Remove(filepath.Join(env.albumDir, "cover.jpg")); err != nil {
-		t.Fatalf("remove cover.jpg: %v", err)
-	}
-	if err := os.WriteFile(filepath.Join(env.albumDir, "cover.jpeg"), []byte("fake-jpeg-2"), 0644); err != nil {
-		t.Fatalf("write cover.jpeg: %v", err)
+	adminCookies := env.authenticateAdmin(t)
+	path := fmt.Sprintf("/admin/api/albums/%d/tracks", env.albumID)
+	payload := map[string]interface{}{
+		"client_version": "2.0",
+		"tracks": []map[string]interface{}{
+			{"stem": "01-gathering", "title": "Gathering", "waveform": "abc"},
+			{"stem": "02-hollow", "title": "Hollow"},
+		},
 	}
 
-	req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/cover", nil)
-	for _, c := range cookies {
-		req.AddCookie(c)
-	}
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("cover request: %v", err)
+	resp := env.adminDo(t, adminCookies, http.MethodPut, path, payload)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("strict update status = %d, want 400", resp.StatusCode)
 	}
-	defer resp.Body.Close()
 
+	env.srv.jsonAllowUnknownFields = true
+	resp = env.adminDo(t, adminCookies, http.MethodPut, path, payload)
+	resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("cover status = %d, want 200", resp.StatusCode)
+		t.Fatalf("relaxed update status = %d, want 200", resp.StatusCode)
 	}
-	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/jpeg") {
-		t.Fatalf("content-type = %q, want image/jpeg", ct)
+	tracks, err := env.srv.albumStore.GetTracks(env.albumID)
+	if err != nil || len(tracks) != 2 || tracks[0].Title != "Gathering" {
+		t.Fatalf("tracks = %+v, %v", tracks, err)
 	}
-}
-
-func TestAdminAuth(t *testing.T) {
-	env := setupTest(t)
 
-	// Wrong password → 401
-	body, _ := json.Marshal(map[string]string{
-		"username": testAdminUsername,
-		"password": "wrong-password",
-	})
+	// Login stays strict regardless of the setting.
+	body, _ := json.Marshal(map[string]string{"username": testAdminUsername, "password": testAdminPassword, "remember": "yes"})
 	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/auth", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Origin", env.ts.URL)
-	resp, err := env.ts.Client().Do(req)
+	resp, err = env.ts.Client().Do(req)
 	if err != nil {
-		t.Fatalf("admin auth request: %v", err)
+		t.Fatalf("auth request: %v", err)
 	}
 	resp.Body.Close()
-	if resp.StatusCode != http.StatusUnauthorized {
-		t.Errorf("wrong password status = %d, want 401", resp.StatusCode)
-	}
-
-	// Correct credentials → 200
-	cookies := env.authenticateAdmin(t)
-	found := false
-	for _, c := range cookies {
-		if c.Name == "acetate_admin" && c.Value != "" {
-			found = true
-		}
-	}
-	if !found {
-		t.Error("no admin session cookie set")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("auth with unknown field status = %d, want 400", resp.StatusCode)
 	}
 }
 
-func TestAdminSetupFlowWhenNoAdminUsers(t *testing.T) {
-	env := setupTestWithBootstrap(t, "", "", "")
-
-	statusResp, err := env.ts.Client().Get(env.ts.URL + "/admin/api/setup/status")
-	if err != nil {
-		t.Fatalf("setup status request: %v", err)
-	}
-	defer statusResp.Body.Close()
-	if statusResp.StatusCode != http.StatusOK {
-		t.Fatalf("setup status = %d, want 200", statusResp.StatusCode)
+func TestAdminTrackUpdateRecordsChangeAudit(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+	var adminID int64
+	if err := env.srv.db.QueryRow("SELECT id FROM admin_users WHERE username = ?", testAdminUsername).Scan(&adminID); err != nil {
+		t.Fatalf("lookup admin id: %v", err)
 	}
 
-	var statusPayload struct {
-		NeedsSetup bool `json:"needs_setup"`
-	}
-	if err := json.NewDecoder(statusResp.Body).Decode(&statusPayload); err != nil {
-		t.Fatalf("decode setup status: %v", err)
+	path := fmt.Sprintf("/admin/api/albums/%d/tracks", env.albumID)
+	resp := env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{"tracks": []map[string]interface{}{
+		{"stem": "01-gathering", "title": "Gathering"},
+		{"stem": "02-hollow", "title": "Hollow"},
+	}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update tracks status = %d, want 200", resp.StatusCode)
 	}
-	if !statusPayload.NeedsSetup {
-		t.Fatal("expected needs_setup=true")
+	// Rejected changes are not recorded.
+	resp = env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{"tracks": []map[string]interface{}{
+		{"stem": "99-missing", "title": "Missing"},
+	}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid update status = %d, want 400", resp.StatusCode)
 	}
 
-	body, _ := json.Marshal(map[string]string{
-		"username": "firstadmin",
-		"password": "first-admin-pass-123",
-	})
-	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/setup", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Origin", env.ts.URL)
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("setup request: %v", err)
-	}
+	resp = env.adminDo(t, adminCookies, http.MethodGet, fmt.Sprintf("/admin/api/audit/changes?user_id=%d", adminID), nil)
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusCreated {
-		t.Fatalf("setup status = %d, want 201", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("audit status = %d, want 200", resp.StatusCode)
 	}
-
-	foundCookie := false
-	for _, c := range resp.Cookies() {
-		if c.Name == "acetate_admin" && c.Value != "" {
-			foundCookie = true
-			break
-		}
+	var payload struct {
+		Entries []adminChangeAuditEntry `json:"entries"`
 	}
-	if !foundCookie {
-		t.Fatal("expected admin session cookie after setup")
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode audit: %v", err)
 	}
-
-	statusResp2, err := env.ts.Client().Get(env.ts.URL + "/admin/api/setup/status")
-	if err != nil {
-		t.Fatalf("setup status request after create: %v", err)
+	if len(payload.Entries) != 1 {
+		t.Fatalf("entries = %+v, want one", payload.Entries)
 	}
-	defer statusResp2.Body.Close()
-	if err := json.NewDecoder(statusResp2.Body).Decode(&statusPayload); err != nil {
-		t.Fatalf("decode setup status after create: %v", err)
+	e := payload.Entries[0]
+	if e.AdminUserID == nil || *e.AdminUserID != adminID || e.Username != testAdminUsername {
+		t.Fatalf("entry actor = %v %q, want %d %q", e.AdminUserID, e.Username, adminID, testAdminUsername)
 	}
-	if statusPayload.NeedsSetup {
-		t.Fatal("expected needs_setup=false after first admin creation")
+	if e.Method != http.MethodPut || e.Endpoint != "/admin/api/albums/{id}/tracks" || e.Summary != fmt.Sprintf("id=%d", env.albumID) || e.Status != http.StatusOK {
+		t.Fatalf("entry = %+v", e)
 	}
 }
 
-func TestAdminSetupRejectedWhenAlreadyConfigured(t *testing.T) {
+func TestStreamTrackPerTrackDownloadPermission(t *testing.T) {
 	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+	path := fmt.Sprintf("/admin/api/albums/%d/tracks", env.albumID)
 
-	body, _ := json.Marshal(map[string]string{
-		"username": "newadmin",
-		"password": "another-admin-pass-123",
-	})
-	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/setup", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Origin", env.ts.URL)
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("setup request: %v", err)
+	// Album downloads stay disabled; only the bonus track allows it.
+	resp := env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{"tracks": []map[string]interface{}{
+		{"stem": "01-gathering", "title": "Gathering"},
+		{"stem": "02-hollow", "title": "Hollow (Bonus)", "allow_download": true},
+	}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update tracks status = %d, want 200", resp.StatusCode)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusConflict {
-		t.Fatalf("status = %d, want 409", resp.StatusCode)
+	// Omitting the flag keeps the stored value.
+	resp = env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{"tracks": []map[string]interface{}{
+		{"stem": "01-gathering", "title": "Gathering"},
+		{"stem": "02-hollow", "title": "Hollow"},
+	}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update tracks status = %d, want 200", resp.StatusCode)
 	}
-}
 
-func TestAdminRejectsMissingOrigin(t *testing.T) {
-	env := setupTest(t)
+	cookies := env.authenticate(t)
+	get := func(path string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("request %s: %v", path, err)
+		}
+		return resp
+	}
 
-	body, _ := json.Marshal(map[string]string{
-		"username": testAdminUsername,
-		"password": testAdminPassword,
-	})
-	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/auth", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("admin auth request: %v", err)
+	resp = get("/tracks")
+	var payload struct {
+		Tracks []struct {
+			Stem          string `json:"stem"`
+			AllowDownload bool   `json:"allow_download"`
+		} `json:"tracks"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode tracks: %v", err)
 	}
 	resp.Body.Close()
+	if len(payload.Tracks) != 2 || payload.Tracks[0].AllowDownload || !payload.Tracks[1].AllowDownload {
+		t.Fatalf("tracks = %+v, want only 02-hollow downloadable", payload.Tracks)
+	}
 
+	resp = get("/stream/02-hollow?download=true")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Disposition"), "attachment") {
+		t.Fatalf("bonus download = %d, disposition %q", resp.StatusCode, resp.Header.Get("Content-Disposition"))
+	}
+	resp = get("/stream/01-gathering?download=true")
+	resp.Body.Close()
 	if resp.StatusCode != http.StatusForbidden {
-		t.Fatalf("status = %d, want 403", resp.StatusCode)
+		t.Fatalf("album track download status = %d, want 403", resp.StatusCode)
 	}
 }
 
-func TestAdminUpdateTracksValidation(t *testing.T) {
+func TestSignedShareURLStreamsWithoutSession(t *testing.T) {
 	env := setupTest(t)
-	adminCookies := env.authenticateAdmin(t)
+	cookies := env.authenticate(t)
 
-	body, _ := json.Marshal(map[string]interface{}{
-		"tracks": []map[string]string{
-			{"stem": "../bad", "title": "Bad"},
-			{"stem": "02-hollow", "title": "Hollow"},
-		},
-	})
-	req, _ := http.NewRequest(http.MethodPut, env.ts.URL+fmt.Sprintf("/admin/api/albums/%d/tracks", env.albumID), bytes.NewReader(body))
+	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/api/albums/"+env.albumSlug+"/tracks/01-gathering/share", strings.NewReader(`{"ttl_seconds": 99999999}`))
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Origin", env.ts.URL)
-	for _, c := range adminCookies {
+	for _, c := range cookies {
 		req.AddCookie(c)
 	}
 	resp, err := env.ts.Client().Do(req)
 	if err != nil {
-		t.Fatalf("update tracks request: %v", err)
+		t.Fatalf("share request: %v", err)
+	}
+	var share struct {
+		ID        string `json:"id"`
+		URL       string `json:"url"`
+		ExpiresAt string `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&share); err != nil {
+		t.Fatalf("decode share: %v", err)
 	}
 	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("share status = %d, want 201", resp.StatusCode)
+	}
+	expires, err := time.Parse(time.RFC3339, share.ExpiresAt)
+	if err != nil || expires.After(time.Now().Add(DefaultShareMaxTTL)) {
+		t.Fatalf("expires_at = %q, want it clamped to %v", share.ExpiresAt, DefaultShareMaxTTL)
+	}
 
-	if resp.StatusCode != http.StatusBadRequest {
-		t.Fatalf("status = %d, want 400", resp.StatusCode)
+	// No cookies: the signature alone grants access.
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := env.ts.Client().Get(env.ts.URL + path)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	if status, body := get(share.URL); status != http.StatusOK || body != "fake-mp3-data" {
+		t.Fatalf("signed stream = %d %q", status, body)
+	}
+
+	streamPath := "/api/albums/" + env.albumSlug + "/stream/"
+	expired := time.Now().Add(-time.Minute).Unix()
+	tooLong := time.Now().Add(DefaultShareMaxTTL + time.Hour).Unix()
+	for name, path := range map[string]string{
+		"expired":     fmt.Sprintf("%s01-gathering?sid=%s&exp=%d&sig=%s", streamPath, share.ID, expired, env.srv.shareSignature(share.ID, env.albumSlug, "01-gathering", expired)),
+		"tampered":    strings.Replace(share.URL, "sig=", "sig=x", 1),
+		"other track": strings.Replace(share.URL, "01-gathering", "02-hollow", 1),
+		"beyond max":  fmt.Sprintf("%s01-gathering?sid=%s&exp=%d&sig=%s", streamPath, share.ID, tooLong, env.srv.shareSignature(share.ID, env.albumSlug, "01-gathering", tooLong)),
+		"unissued id": fmt.Sprintf("%s01-gathering?sid=forged&exp=%d&sig=%s", streamPath, expired+120, env.srv.shareSignature("forged", env.albumSlug, "01-gathering", expired+120)),
+	} {
+		if status, _ := get(path); status != http.StatusForbidden {
+			t.Fatalf("%s link status = %d, want 403", name, status)
+		}
+	}
+	if status, _ := get(streamPath + "01-gathering"); status != http.StatusUnauthorized {
+		t.Fatalf("unsigned stream without session = %d, want 401", status)
 	}
 }
 
-func TestAdminUploadCoverRejectsNonImage(t *testing.T) {
+func TestShareLinksLimitedPerSession(t *testing.T) {
 	env := setupTest(t)
-	adminCookies := env.authenticateAdmin(t)
+	cookies := env.authenticate(t)
 
-	var body bytes.Buffer
-	writer := multipart.NewWriter(&body)
-	part, err := writer.CreateFormFile("cover", "cover.txt")
-	if err != nil {
-		t.Fatalf("CreateFormFile: %v", err)
+	share := func(cookies []*http.Cookie) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/api/albums/"+env.albumSlug+"/tracks/01-gathering/share", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("share request: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
 	}
-	if _, err := part.Write([]byte("not-an-image")); err != nil {
-		t.Fatalf("Write: %v", err)
+
+	for i := 0; i < shareRateLimit; i++ {
+		if code := share(cookies); code != http.StatusCreated {
+			t.Fatalf("share %d status = %d, want 201", i+1, code)
+		}
+	}
+	if code := share(cookies); code != http.StatusTooManyRequests {
+		t.Fatalf("share past rate limit status = %d, want 429", code)
 	}
-	writer.Close()
 
-	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+fmt.Sprintf("/admin/api/albums/%d/cover", env.albumID), &body)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("Origin", env.ts.URL)
-	for _, c := range adminCookies {
-		req.AddCookie(c)
+	// With the rate limit out of the way, live links are capped per session.
+	env.srv.shareLimiter.Close()
+	env.srv.shareLimiter = auth.NewRateLimiterWithLimit(1000, time.Minute)
+	for i := shareRateLimit; i < maxActiveSharesPerSID; i++ {
+		if code := share(cookies); code != http.StatusCreated {
+			t.Fatalf("share %d status = %d, want 201", i+1, code)
+		}
 	}
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("upload cover request: %v", err)
+	if code := share(cookies); code != http.StatusTooManyRequests {
+		t.Fatalf("share past active cap status = %d, want 429", code)
 	}
-	resp.Body.Close()
 
-	if resp.StatusCode != http.StatusBadRequest {
-		t.Fatalf("status = %d, want 400", resp.StatusCode)
+	// Expired links free their slots; other sessions have their own.
+	if _, err := env.srv.db.Exec("UPDATE share_links SET expires_at = ? WHERE rowid IN (SELECT rowid FROM share_links LIMIT 1)", time.Now().Add(-time.Minute).Unix()); err != nil {
+		t.Fatalf("expire link: %v", err)
+	}
+	if code := share(cookies); code != http.StatusCreated {
+		t.Fatalf("share after expiry status = %d, want 201", code)
+	}
+	if code := share(env.authenticate(t)); code != http.StatusCreated {
+		t.Fatalf("share from another session status = %d, want 201", code)
 	}
 }
 
-func TestSPAFallback(t *testing.T) {
+func TestAdminRevokeShareRejectsOnlyThatLink(t *testing.T) {
 	env := setupTest(t)
+	cookies := env.authenticate(t)
+	adminCookies := env.authenticateAdmin(t)
 
-	resp, err := env.ts.Client().Get(env.ts.URL + "/listening-room")
-	if err != nil {
-		t.Fatalf("SPA fallback request: %v", err)
+	share := func(stem string) (string, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/api/albums/"+env.albumSlug+"/tracks/"+stem+"/share", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("share request: %v", err)
+		}
+		defer resp.Body.Close()
+		var payload struct {
+			ID  string `json:"id"`
+			URL string `json:"url"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil || resp.StatusCode != http.StatusCreated {
+			t.Fatalf("share status = %d, err %v", resp.StatusCode, err)
+		}
+		return payload.ID, payload.URL
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	stream := func(path string) int {
+		t.Helper()
+		resp, err := env.ts.Client().Get(env.ts.URL + path)
+		if err != nil {
+			t.Fatalf("stream: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
 	}
 
-	var body bytes.Buffer
-	if _, err := body.ReadFrom(resp.Body); err != nil {
-		t.Fatalf("read body: %v", err)
+	leakedID, leakedURL := share("01-gathering")
+	_, keptURL := share("01-gathering")
+	_, otherURL := share("02-hollow")
+
+	resp := env.adminDo(t, adminCookies, http.MethodGet, "/admin/api/shares", nil)
+	var listed struct {
+		Shares []struct {
+			ID        string `json:"id"`
+			AlbumSlug string `json:"album_slug"`
+			Stem      string `json:"stem"`
+		} `json:"shares"`
 	}
-	if !strings.Contains(body.String(), "id=\"gate\"") {
-		t.Fatalf("expected index shell, body=%q", body.String())
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		t.Fatalf("decode shares: %v", err)
+	}
+	resp.Body.Close()
+	if len(listed.Shares) != 3 || listed.Shares[0].AlbumSlug != env.albumSlug {
+		t.Fatalf("shares = %+v, want 3 for %s", listed.Shares, env.albumSlug)
 	}
-}
 
-func TestSecurityHeadersDoNotAllowInlineStyles(t *testing.T) {
-	env := setupTest(t)
+	resp = env.adminDo(t, adminCookies, http.MethodDelete, "/admin/api/shares/"+leakedID, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("revoke status = %d, want 200", resp.StatusCode)
+	}
+	resp = env.adminDo(t, adminCookies, http.MethodDelete, "/admin/api/shares/missing", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("revoke unknown status = %d, want 404", resp.StatusCode)
+	}
 
-	resp, err := env.ts.Client().Get(env.ts.URL + "/")
-	if err != nil {
-		t.Fatalf("request: %v", err)
+	if status := stream(leakedURL); status != http.StatusForbidden {
+		t.Fatalf("revoked link status = %d, want 403", status)
+	}
+	if status := stream(keptURL); status != http.StatusOK {
+		t.Fatalf("other link for same track status = %d, want 200", status)
+	}
+	if status := stream(otherURL); status != http.StatusOK {
+		t.Fatalf("other track link status = %d, want 200", status)
 	}
-	defer resp.Body.Close()
 
-	csp := resp.Header.Get("Content-Security-Policy")
-	if csp == "" {
-		t.Fatal("expected Content-Security-Policy header")
+	resp = env.adminDo(t, adminCookies, http.MethodGet, "/admin/api/shares", nil)
+	listed.Shares = nil
+	json.NewDecoder(resp.Body).Decode(&listed)
+	resp.Body.Close()
+	for _, sh := range listed.Shares {
+		if sh.ID == leakedID {
+			t.Fatalf("revoked share still listed as active")
+		}
 	}
-	if strings.Contains(csp, "'unsafe-inline'") {
-		t.Fatalf("unexpected unsafe-inline in CSP: %q", csp)
+	if len(listed.Shares) != 2 {
+		t.Fatalf("active shares = %d, want 2", len(listed.Shares))
 	}
 }
 
-func TestAnalyticsEndpoint(t *testing.T) {
+func TestStreamBytesCountedPerTrack(t *testing.T) {
 	env := setupTest(t)
 	cookies := env.authenticate(t)
 
-	events := []map[string]interface{}{
-		{"event_type": "play", "track_stem": "01-gathering"},
-		{"event_type": "pause", "track_stem": "01-gathering", "position_seconds": 30.5},
+	stream := func(stem, rangeHeader string, optOut bool) int64 {
+		t.Helper()
+		req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/stream/"+stem, nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		if optOut {
+			req.Header.Set("DNT", "1")
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("stream request: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+			t.Fatalf("stream %s status = %d", stem, resp.StatusCode)
+		}
+		return int64(len(body))
 	}
-	body, _ := json.Marshal(events)
 
-	req, _ := http.NewRequest("POST", env.ts.URL+"/api/albums/"+env.albumSlug+"/analytics", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	for _, c := range cookies {
-		req.AddCookie(c)
+	full := stream("01-gathering", "", false)
+	partial := stream("01-gathering", "bytes=0-3", false)
+	if full != int64(len("fake-mp3-data")) || partial != 4 {
+		t.Fatalf("served lengths = %d, %d", full, partial)
 	}
+	hollow := stream("02-hollow", "bytes=5-", false)
+	// Opted-out streams are served but not counted.
+	stream("02-hollow", "", true)
 
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("analytics request: %v", err)
+	// Counts are buffered and written with the collector's next flush.
+	if err := env.srv.collector.FlushNow(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
 	}
-	resp.Body.Close()
 
-	if resp.StatusCode != http.StatusNoContent {
-		t.Errorf("analytics status = %d, want 204", resp.StatusCode)
+	admin := env.authenticateAdmin(t)
+	resp := env.adminDo(t, admin, http.MethodGet, fmt.Sprintf("/admin/api/albums/%d/analytics", env.albumID), nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("analytics status = %d", resp.StatusCode)
+	}
+	var out struct {
+		StreamBytes []struct {
+			Stem       string `json:"stem"`
+			TotalBytes int64  `json:"total_bytes"`
+			Requests   int    `json:"requests"`
+		} `json:"stream_bytes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode analytics: %v", err)
+	}
+	got := map[string][2]int64{}
+	for _, sb := range out.StreamBytes {
+		got[sb.Stem] = [2]int64{sb.TotalBytes, int64(sb.Requests)}
+	}
+	if want := [2]int64{full + partial, 2}; got["01-gathering"] != want {
+		t.Fatalf("01-gathering bytes = %v, want %v", got["01-gathering"], want)
+	}
+	if want := [2]int64{hollow, 1}; got["02-hollow"] != want {
+		t.Fatalf("02-hollow bytes = %v, want %v", got["02-hollow"], want)
 	}
 }
 
-func TestLogout(t *testing.T) {
+func TestStreamScanDetectionFlagsRapidDistinctTracks(t *testing.T) {
 	env := setupTest(t)
+	env.srv.scanGuard = newScanGuard(2, time.Minute, true)
 	cookies := env.authenticate(t)
 
-	req, _ := http.NewRequest("DELETE", env.ts.URL+"/api/auth", nil)
-	for _, c := range cookies {
-		req.AddCookie(c)
-	}
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("logout request: %v", err)
+	stream := func(stem string) int {
+		t.Helper()
+		req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/stream/"+stem, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("stream request: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
 	}
-	resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		t.Errorf("logout status = %d, want 200", resp.StatusCode)
+	// Replaying one track is ordinary listening.
+	for i := 0; i < 3; i++ {
+		if code := stream("01-gathering"); code != http.StatusOK {
+			t.Fatalf("repeat stream status = %d, want 200", code)
+		}
 	}
-
-	// Session should be invalid now
-	req, _ = http.NewRequest("GET", env.ts.URL+"/api/session", nil)
-	for _, c := range cookies {
-		req.AddCookie(c)
+	if code := stream("02-hollow"); code != http.StatusTooManyRequests {
+		t.Fatalf("second distinct track status = %d, want 429", code)
 	}
-	resp, err = env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("post-logout request: %v", err)
+	if code := stream("01-gathering"); code != http.StatusTooManyRequests {
+		t.Fatalf("flagged session status = %d, want 429", code)
 	}
-	resp.Body.Close()
 
-	if resp.StatusCode != http.StatusUnauthorized {
-		t.Errorf("post-logout status = %d, want 401", resp.StatusCode)
+	if err := env.srv.collector.FlushNow(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
 	}
-}
-
-func TestAdminAuthWithHashedBootstrapPassword(t *testing.T) {
-	hash, err := bcrypt.GenerateFromPassword([]byte("hashed-admin-pass-123"), bcrypt.MinCost)
-	if err != nil {
-		t.Fatalf("generate hash: %v", err)
+	var count int
+	var metadata string
+	if err := env.srv.db.QueryRow(
+		"SELECT COUNT(*), COALESCE(MAX(metadata), '') FROM events WHERE event_type = 'scan_suspected' AND album_id = ?",
+		env.albumID,
+	).Scan(&count, &metadata); err != nil {
+		t.Fatalf("query scan events: %v", err)
 	}
-
-	env := setupTestWithBootstrap(t, "admin2", "", string(hash))
-
-	body, _ := json.Marshal(map[string]string{
-		"username": "admin2",
-		"password": "hashed-admin-pass-123",
-	})
-	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/auth", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Origin", env.ts.URL)
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("admin auth request: %v", err)
+	if count != 1 || !strings.Contains(metadata, `"distinct_tracks":2`) {
+		t.Fatalf("scan_suspected events = %d (%s), want one", count, metadata)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	// A different session is unaffected.
+	cookies = env.authenticate(t)
+	if code := stream("02-hollow"); code != http.StatusOK {
+		t.Fatalf("fresh session status = %d, want 200", code)
 	}
 }
 
-func TestAdminUpdateOwnPassword(t *testing.T) {
+func TestStreamScanDetectionCountsShareLinks(t *testing.T) {
 	env := setupTest(t)
-	adminCookies := env.authenticateAdmin(t)
-
-	body, _ := json.Marshal(map[string]string{
-		"current_password": testAdminPassword,
-		"new_password":     "new-admin-pass-456",
-	})
-	req, _ := http.NewRequest(http.MethodPut, env.ts.URL+"/admin/api/admin-password", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Origin", env.ts.URL)
-	for _, c := range adminCookies {
-		req.AddCookie(c)
-	}
+	env.srv.scanGuard = newScanGuard(2, time.Minute, true)
+	cookies := env.authenticate(t)
 
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("update admin password request: %v", err)
+	mint := func(stem string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/api/albums/"+env.albumSlug+"/tracks/"+stem+"/share", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("share request: %v", err)
+		}
+		defer resp.Body.Close()
+		var payload struct {
+			URL string `json:"url"`
+		}
+		json.NewDecoder(resp.Body).Decode(&payload)
+		return resp.StatusCode, payload.URL
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	stream := func(path string) int {
+		t.Helper()
+		resp, err := env.ts.Client().Get(env.ts.URL + path)
+		if err != nil {
+			t.Fatalf("stream: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
 	}
 
-	// Old password should no longer work.
-	oldAuthBody, _ := json.Marshal(map[string]string{
-		"username": testAdminUsername,
-		"password": testAdminPassword,
-	})
-	oldAuthReq, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/auth", bytes.NewReader(oldAuthBody))
-	oldAuthReq.Header.Set("Content-Type", "application/json")
-	oldAuthReq.Header.Set("Origin", env.ts.URL)
-	oldAuthResp, err := env.ts.Client().Do(oldAuthReq)
-	if err != nil {
-		t.Fatalf("old auth request: %v", err)
+	code, link := mint("01-gathering")
+	if code != http.StatusCreated {
+		t.Fatalf("first mint status = %d, want 201", code)
 	}
-	oldAuthResp.Body.Close()
-	if oldAuthResp.StatusCode != http.StatusUnauthorized {
-		t.Fatalf("old password auth status = %d, want 401", oldAuthResp.StatusCode)
+	if code := stream(link); code != http.StatusOK {
+		t.Fatalf("share stream status = %d, want 200", code)
 	}
-
-	// New password should work.
-	newAuthBody, _ := json.Marshal(map[string]string{
-		"username": testAdminUsername,
-		"password": "new-admin-pass-456",
-	})
-	newAuthReq, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/auth", bytes.NewReader(newAuthBody))
-	newAuthReq.Header.Set("Content-Type", "application/json")
-	newAuthReq.Header.Set("Origin", env.ts.URL)
-	newAuthResp, err := env.ts.Client().Do(newAuthReq)
-	if err != nil {
-		t.Fatalf("new auth request: %v", err)
+	// A second distinct track, requested through a new link, flags the
+	// minting session, and its existing links are throttled with it.
+	if code, _ := mint("02-hollow"); code != http.StatusTooManyRequests {
+		t.Fatalf("second distinct mint status = %d, want 429", code)
 	}
-	newAuthResp.Body.Close()
-	if newAuthResp.StatusCode != http.StatusOK {
-		t.Fatalf("new password auth status = %d, want 200", newAuthResp.StatusCode)
+	if code := stream(link); code != http.StatusTooManyRequests {
+		t.Fatalf("share stream of flagged session status = %d, want 429", code)
 	}
 }
 
-func TestAdminAuthLockoutAfterRepeatedFailures(t *testing.T) {
+func TestStreamScanDetectionHonoursAnalyticsOptOut(t *testing.T) {
 	env := setupTest(t)
+	if env.srv.scanGuard != nil {
+		t.Fatal("scan detection should be off by default")
+	}
+	env.srv.scanGuard = newScanGuard(2, time.Minute, false)
+	cookies := env.authenticate(t)
 
-	for i := 0; i < 5; i++ {
-		_, _, status := env.authenticateAdminAs(t, testAdminUsername, "wrong-password")
-		if status != http.StatusUnauthorized {
-			t.Fatalf("attempt %d status = %d, want 401", i+1, status)
+	for _, stem := range []string{"01-gathering", "02-hollow"} {
+		req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/stream/"+stem, nil)
+		req.Header.Set("DNT", "1")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("stream request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("stream %s status = %d, want 200", stem, resp.StatusCode)
 		}
 	}
 
-	_, _, status := env.authenticateAdminAs(t, testAdminUsername, "wrong-password")
-	if status != http.StatusTooManyRequests {
-		t.Fatalf("locked attempt status = %d, want 429", status)
+	if err := env.srv.collector.FlushNow(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
 	}
-
-	_, _, status = env.authenticateAdminAs(t, testAdminUsername, testAdminPassword)
-	if status != http.StatusTooManyRequests {
-		t.Fatalf("locked correct-password attempt status = %d, want 429", status)
+	var count int
+	if err := env.srv.db.QueryRow("SELECT COUNT(*) FROM events WHERE event_type = 'scan_suspected'").Scan(&count); err != nil {
+		t.Fatalf("query scan events: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("scan_suspected events = %d for opted-out session, want 0", count)
 	}
 }
 
-func TestAdminUserManagementAndForcedPasswordResetFlow(t *testing.T) {
-	env := setupTest(t)
-	adminCookies := env.authenticateAdmin(t)
+func TestScanGuardWindowExpires(t *testing.T) {
+	g := newScanGuard(3, time.Minute, false)
+	start := time.Now()
+
+	g.observe("s", "a", start)
+	g.observe("s", "b", start.Add(10*time.Second))
+	if _, flagged, _ := g.observe("s", "c", start.Add(2*time.Minute)); flagged {
+		t.Fatal("tracks outside the window should not count")
+	}
+	if _, flagged, _ := g.observe("s", "d", start.Add(2*time.Minute+time.Second)); flagged {
+		t.Fatal("two tracks in window should not flag")
+	}
+	_, flagged, newly := g.observe("s", "e", start.Add(2*time.Minute+2*time.Second))
+	if !flagged || !newly {
+		t.Fatalf("flagged=%v newly=%v, want both", flagged, newly)
+	}
+	if _, _, newly := g.observe("s", "f", start.Add(2*time.Minute+3*time.Second)); newly {
+		t.Fatal("session should only be newly flagged once per window")
+	}
+	if newScanGuard(0, time.Minute, false) != nil {
+		t.Fatal("zero threshold should disable detection")
+	}
+}
+
+func TestLimitConcurrencyCapsInFlightPerIP(t *testing.T) {
+	env := setupTest(t)
+	env.srv.ipConcurrency = newIPConcurrency(2)
+
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	handler := env.srv.limitConcurrency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started.Done()
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	request := func(ip, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":40000"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var done sync.WaitGroup
+	codes := make(chan int, 2)
+	started.Add(2)
+	done.Add(2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			defer done.Done()
+			codes <- request("192.0.2.10", "/api/session").Code
+		}()
+	}
+	started.Wait()
+
+	if rec := request("192.0.2.10", "/api/session"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("third concurrent request = %d, want 429", rec.Code)
+	}
+
+	// Other clients and the readiness probe are not held back.
+	started.Add(2)
+	done.Add(2)
+	for _, probe := range [][2]string{{"192.0.2.20", "/api/session"}, {"192.0.2.10", "/readyz"}} {
+		go func() {
+			defer done.Done()
+			request(probe[0], probe[1])
+		}()
+	}
+	started.Wait()
+
+	close(release)
+	done.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusNoContent {
+			t.Fatalf("in-flight request = %d, want 204", code)
+		}
+	}
+
+	started.Add(1)
+	if rec := request("192.0.2.10", "/api/session"); rec.Code != http.StatusNoContent {
+		t.Fatalf("request after completion = %d, want 204", rec.Code)
+	}
+	env.srv.ipConcurrency.mu.Lock()
+	left := len(env.srv.ipConcurrency.inflight)
+	env.srv.ipConcurrency.mu.Unlock()
+	if left != 0 {
+		t.Fatalf("in-flight entries after completion = %d, want 0", left)
+	}
+}
+
+func TestConditionalRequestCounters(t *testing.T) {
+	env := setupTest(t)
+	cookies := env.authenticate(t)
+
+	get := func(path, etag string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", env.ts.URL+path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	for _, path := range []string{
+		"/api/albums/" + env.albumSlug + "/cover",
+		"/api/albums/" + env.albumSlug + "/stream/01-gathering",
+	} {
+		first := get(path, "")
+		etag := first.Header.Get("ETag")
+		if first.StatusCode != http.StatusOK || etag == "" {
+			t.Fatalf("GET %s = %d etag %q", path, first.StatusCode, etag)
+		}
+		if resp := get(path, etag); resp.StatusCode != http.StatusNotModified {
+			t.Fatalf("conditional GET %s = %d, want 304", path, resp.StatusCode)
+		}
+	}
+	get("/api/albums/"+env.albumSlug+"/tracks", "")
+
+	admin := env.authenticateAdmin(t)
+	resp := env.adminDo(t, admin, http.MethodGet, "/admin/api/ops/stats", nil)
+	defer resp.Body.Close()
+	var out struct {
+		Conditional map[string]struct {
+			NotModified int64   `json:"not_modified"`
+			Served      int64   `json:"served"`
+			HitRate     float64 `json:"hit_rate"`
+		} `json:"conditional_requests"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode ops stats: %v", err)
+	}
+	for _, class := range []string{"cover", "stream"} {
+		c := out.Conditional[class]
+		if c.NotModified != 1 || c.Served != 1 || c.HitRate != 0.5 {
+			t.Fatalf("%s counters = %+v, want one 304 and one 200", class, c)
+		}
+	}
+	if c := out.Conditional["tracks"]; c.NotModified != 0 || c.Served != 1 {
+		t.Fatalf("tracks counters = %+v", c)
+	}
+}
+
+func TestAdminAlbumFunnelFollowsTrackOrder(t *testing.T) {
+	env := setupTest(t)
+	for _, ev := range []struct{ session, stem string }{
+		{"s1", "01-gathering"},
+		{"s2", "01-gathering"},
+		{"s2", "02-hollow"},
+		{"s3", "02-hollow"},
+	} {
+		if _, err := env.srv.db.Exec(
+			"INSERT INTO events (session_id, event_type, track_stem, album_id) VALUES (?, 'play', ?, ?)",
+			ev.session, ev.stem, env.albumID,
+		); err != nil {
+			t.Fatalf("insert event: %v", err)
+		}
+	}
+
+	cookies := env.authenticateAdmin(t)
+	resp := env.adminDo(t, cookies, http.MethodGet, fmt.Sprintf("/admin/api/albums/%d/analytics/album-funnel", env.albumID), nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("funnel status = %d, want 200", resp.StatusCode)
+	}
+	var out struct {
+		Steps []analytics.FunnelStep `json:"steps"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode funnel: %v", err)
+	}
+	if len(out.Steps) != 2 ||
+		out.Steps[0] != (analytics.FunnelStep{Position: 1, Stem: "01-gathering", Sessions: 3}) ||
+		out.Steps[1] != (analytics.FunnelStep{Position: 2, Stem: "02-hollow", Sessions: 2}) {
+		t.Fatalf("funnel steps = %+v", out.Steps)
+	}
+
+	if resp := env.adminDo(t, cookies, http.MethodGet, "/admin/api/albums/9999/analytics/album-funnel", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown album status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestTrackDurationCachedOnReconcileAndRefreshed(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	path := filepath.Join(env.albumDir, "01-gathering.mp3")
+	if err := os.WriteFile(path, testMP3Frames(200), 0644); err != nil {
+		t.Fatalf("write track: %v", err)
+	}
+
+	resp := env.adminDo(t, adminCookies, http.MethodPost, fmt.Sprintf("/admin/api/albums/%d/reconcile", env.albumID), map[string]interface{}{})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("reconcile status = %d, want 200", resp.StatusCode)
+	}
+	tracks, err := env.srv.albumStore.GetTracks(env.albumID)
+	if err != nil {
+		t.Fatalf("GetTracks: %v", err)
+	}
+	info, _ := os.Stat(path)
+	if tracks[0].DurationSeconds < 5.2 || tracks[0].DurationSeconds > 5.22 || tracks[0].ModTime != info.ModTime().UnixNano() {
+		t.Fatalf("track after reconcile = %+v", tracks[0])
+	}
+
+	// The listener manifest reports the cached duration.
+	cookies := env.authenticate(t)
+	manifestDuration := func() float64 {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/api/albums/"+env.albumSlug+"/tracks", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("tracks request: %v", err)
+		}
+		defer resp.Body.Close()
+		var manifest struct {
+			Tracks []album.TrackInfo `json:"tracks"`
+		}
+		json.NewDecoder(resp.Body).Decode(&manifest)
+		if len(manifest.Tracks) == 0 {
+			t.Fatalf("manifest has no tracks")
+		}
+		return manifest.Tracks[0].DurationSeconds
+	}
+	if d := manifestDuration(); d != tracks[0].DurationSeconds {
+		t.Fatalf("manifest duration = %f, want %f", d, tracks[0].DurationSeconds)
+	}
+
+	// A replaced file invalidates the cached value: the manifest re-reads it
+	// and stores the new length.
+	if err := os.WriteFile(path, testMP3Frames(400), 0644); err != nil {
+		t.Fatalf("rewrite track: %v", err)
+	}
+	later := info.ModTime().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	seconds := manifestDuration()
+	if seconds < 10.4 || seconds > 10.44 {
+		t.Fatalf("manifest duration after rewrite = %f", seconds)
+	}
+	tracks, _ = env.srv.albumStore.GetTracks(env.albumID)
+	if tracks[0].DurationSeconds != seconds || tracks[0].ModTime != later.UnixNano() {
+		t.Fatalf("stored track after refresh = %+v", tracks[0])
+	}
+	if d, ok := env.srv.trackDurationSeconds(env.albumID, "01-gathering"); !ok || d != seconds {
+		t.Fatalf("refreshed duration = %f, %v", d, ok)
+	}
+}
+
+func TestStreamTrackFromDiscSubdir(t *testing.T) {
+	env := setupTest(t)
+
+	discDir := filepath.Join(env.albumDir, "disc2")
+	if err := os.MkdirAll(discDir, 0755); err != nil {
+		t.Fatalf("mkdir disc: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(discDir, "01-return.mp3"), []byte("fake-disc-mp3"), 0644); err != nil {
+		t.Fatalf("write track: %v", err)
+	}
+
+	existingTracks, err := env.srv.albumStore.GetTracks(env.albumID)
+	if err != nil {
+		t.Fatalf("get tracks: %v", err)
+	}
+	existingTracks = append(existingTracks,
+		albums.Track{Stem: "01-return", Title: "Return", Subdir: "disc2"},
+		albums.Track{Stem: "02-escape", Title: "Escape", Subdir: "../outside"},
+	)
+	if err := env.srv.albumStore.SetTracks(env.albumID, existingTracks); err != nil {
+		t.Fatalf("set tracks: %v", err)
+	}
+
+	cookies := env.authenticate(t)
+	for stem, want := range map[string]int{
+		"01-return": http.StatusOK,
+		"02-escape": http.StatusBadRequest,
+	} {
+		req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/stream/"+stem, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("stream request: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != want {
+			t.Fatalf("stream %s status = %d, want %d", stem, resp.StatusCode, want)
+		}
+		if want == http.StatusOK && string(body) != "fake-disc-mp3" {
+			t.Fatalf("stream %s body = %q", stem, body)
+		}
+	}
+}
+
+// fakeTranscoder "transcodes" by prefixing the source bytes.
+type fakeTranscoder struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *fakeTranscoder) TranscodeMP3(ctx context.Context, src, dst string) error {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, append([]byte("mp3:"), data...), 0644)
+}
+
+func TestStreamTrackTranscodesToMP3(t *testing.T) {
+	env := setupTest(t)
+	env.srv.albumExtensions = []string{"mp3", "flac"}
+
+	flacPath := filepath.Join(env.albumDir, "03-drift.flac")
+	if err := os.WriteFile(flacPath, []byte("flac-v1"), 0644); err != nil {
+		t.Fatalf("write track: %v", err)
+	}
+	existingTracks, err := env.srv.albumStore.GetTracks(env.albumID)
+	if err != nil {
+		t.Fatalf("get tracks: %v", err)
+	}
+	existingTracks = append(existingTracks, albums.Track{Stem: "03-drift", Title: "Drift"})
+	if err := env.srv.albumStore.SetTracks(env.albumID, existingTracks); err != nil {
+		t.Fatalf("set tracks: %v", err)
+	}
+
+	cookies := env.authenticate(t)
+	stream := func(stem, query string) (int, string, string) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/stream/"+stem+query, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("stream request: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get("Content-Type"), string(body)
+	}
+
+	// Without ffmpeg (or with the feature off) the request fails cleanly.
+	if status, _, _ := stream("03-drift", "?format=mp3"); status != http.StatusNotImplemented {
+		t.Fatalf("disabled transcode status = %d, want 501", status)
+	}
+
+	fake := &fakeTranscoder{}
+	cacheDir := filepath.Join(env.dataDir, "transcode")
+	env.srv.transcodes = newTranscodeCache(cacheDir, fake)
+
+	for i := 0; i < 2; i++ {
+		status, ct, body := stream("03-drift", "?format=mp3")
+		if status != http.StatusOK || ct != "audio/mpeg" || body != "mp3:flac-v1" {
+			t.Fatalf("transcode = %d %q %q", status, ct, body)
+		}
+	}
+	if fake.calls != 1 {
+		t.Fatalf("transcoder calls = %d, want 1 (second request should hit the cache)", fake.calls)
+	}
+
+	// A changed source is re-transcoded and the stale output pruned.
+	if err := os.WriteFile(flacPath, []byte("flac-v2"), 0644); err != nil {
+		t.Fatalf("rewrite track: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(flacPath, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if _, _, body := stream("03-drift", "?format=mp3"); body != "mp3:flac-v2" {
+		t.Fatalf("retranscode body = %q", body)
+	}
+	if entries, _ := os.ReadDir(cacheDir); fake.calls != 2 || len(entries) != 1 {
+		t.Fatalf("calls = %d, cache entries = %d; want 2 and 1", fake.calls, len(entries))
+	}
+
+	// MP3 sources are streamed as-is; the original format is still available.
+	if status, _, body := stream("01-gathering", "?format=mp3"); status != http.StatusOK || body != "fake-mp3-data" {
+		t.Fatalf("mp3 passthrough = %d %q", status, body)
+	}
+	if _, _, body := stream("03-drift", ""); body != "flac-v2" {
+		t.Fatalf("original stream body = %q", body)
+	}
+	if fake.calls != 2 {
+		t.Fatalf("transcoder calls = %d, want 2", fake.calls)
+	}
+	if status, _, _ := stream("03-drift", "?format=ogg"); status != http.StatusBadRequest {
+		t.Fatalf("unsupported format status = %d, want 400", status)
+	}
+}
+func TestLyrics(t *testing.T) {
+	env := setupTest(t)
+	cookies := env.authenticate(t)
+
+	req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/lyrics/01-gathering", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("lyrics request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("lyrics status = %d, want 200", resp.StatusCode)
+	}
+
+	var result map[string]string
+	json.NewDecoder(resp.Body).Decode(&result)
+	if result["format"] != "lrc" {
+		t.Errorf("lyrics format = %q, want lrc", result["format"])
+	}
+}
+
+func TestCover(t *testing.T) {
+	env := setupTest(t)
+	cookies := env.authenticate(t)
+
+	req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/cover", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("cover request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("cover status = %d, want 200", resp.StatusCode)
+	}
+	if etag := resp.Header.Get("ETag"); etag == "" {
+		t.Error("cover should have ETag")
+	}
+}
+
+func TestCoverSupportsJPEGFallback(t *testing.T) {
+	env := setupTest(t)
+	cookies := env.authenticate(t)
+
+	if err := os.Remove(filepath.Join(env.albumDir, "cover.jpg")); err != nil {
+		t.Fatalf("remove cover.jpg: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(env.albumDir, "cover.jpeg"), []byte("fake-jpeg-2"), 0644); err != nil {
+		t.Fatalf("write cover.jpeg: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/cover", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("cover request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("cover status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/jpeg") {
+		t.Fatalf("content-type = %q, want image/jpeg", ct)
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	env := setupTest(t)
+
+	// Wrong password → 401
+	body, _ := json.Marshal(map[string]string{
+		"username": testAdminUsername,
+		"password": "wrong-password",
+	})
+	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/auth", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", env.ts.URL)
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("admin auth request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong password status = %d, want 401", resp.StatusCode)
+	}
+
+	// Correct credentials → 200
+	cookies := env.authenticateAdmin(t)
+	found := false
+	for _, c := range cookies {
+		if c.Name == "acetate_admin" && c.Value != "" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("no admin session cookie set")
+	}
+}
+
+func TestAdminSetupFlowWhenNoAdminUsers(t *testing.T) {
+	env := setupTestWithBootstrap(t, "", "", "")
+
+	statusResp, err := env.ts.Client().Get(env.ts.URL + "/admin/api/setup/status")
+	if err != nil {
+		t.Fatalf("setup status request: %v", err)
+	}
+	defer statusResp.Body.Close()
+	if statusResp.StatusCode != http.StatusOK {
+		t.Fatalf("setup status = %d, want 200", statusResp.StatusCode)
+	}
+
+	var statusPayload struct {
+		NeedsSetup bool `json:"needs_setup"`
+	}
+	if err := json.NewDecoder(statusResp.Body).Decode(&statusPayload); err != nil {
+		t.Fatalf("decode setup status: %v", err)
+	}
+	if !statusPayload.NeedsSetup {
+		t.Fatal("expected needs_setup=true")
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"username": "firstadmin",
+		"password": "first-admin-pass-123",
+	})
+	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/setup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", env.ts.URL)
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("setup request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("setup status = %d, want 201", resp.StatusCode)
+	}
+
+	foundCookie := false
+	for _, c := range resp.Cookies() {
+		if c.Name == "acetate_admin" && c.Value != "" {
+			foundCookie = true
+			break
+		}
+	}
+	if !foundCookie {
+		t.Fatal("expected admin session cookie after setup")
+	}
+
+	statusResp2, err := env.ts.Client().Get(env.ts.URL + "/admin/api/setup/status")
+	if err != nil {
+		t.Fatalf("setup status request after create: %v", err)
+	}
+	defer statusResp2.Body.Close()
+	if err := json.NewDecoder(statusResp2.Body).Decode(&statusPayload); err != nil {
+		t.Fatalf("decode setup status after create: %v", err)
+	}
+	if statusPayload.NeedsSetup {
+		t.Fatal("expected needs_setup=false after first admin creation")
+	}
+}
+
+func TestAdminSetupRejectedWhenAlreadyConfigured(t *testing.T) {
+	env := setupTest(t)
+
+	body, _ := json.Marshal(map[string]string{
+		"username": "newadmin",
+		"password": "another-admin-pass-123",
+	})
+	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/setup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", env.ts.URL)
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("setup request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("status = %d, want 409", resp.StatusCode)
+	}
+}
+
+func TestAdminRejectsMissingOrigin(t *testing.T) {
+	env := setupTest(t)
+
+	body, _ := json.Marshal(map[string]string{
+		"username": testAdminUsername,
+		"password": testAdminPassword,
+	})
+	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/auth", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("admin auth request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestAdminUpdateTracksValidation(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"tracks": []map[string]string{
+			{"stem": "../bad", "title": "Bad"},
+			{"stem": "02-hollow", "title": "Hollow"},
+		},
+	})
+	req, _ := http.NewRequest(http.MethodPut, env.ts.URL+fmt.Sprintf("/admin/api/albums/%d/tracks", env.albumID), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", env.ts.URL)
+	for _, c := range adminCookies {
+		req.AddCookie(c)
+	}
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("update tracks request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestAdminUpdateTracksReportsPerTrackIssues(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+	path := fmt.Sprintf("/admin/api/albums/%d/tracks", env.albumID)
+
+	type issue struct {
+		Index  int    `json:"index"`
+		Stem   string `json:"stem"`
+		Reason string `json:"reason"`
+	}
+	send := func(tracks []map[string]string) []issue {
+		t.Helper()
+		resp := env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{"tracks": tracks})
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400", resp.StatusCode)
+		}
+		var payload struct {
+			Error   string  `json:"error"`
+			Details []issue `json:"details"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return payload.Details
+	}
+
+	got := send([]map[string]string{
+		{"stem": "01-gathering", "title": strings.Repeat("x", 300)},
+		{"stem": "01-gathering", "title": "Gathering"},
+	})
+	want := []issue{
+		{Index: 0, Stem: "01-gathering", Reason: "title_too_long"},
+		{Index: 1, Stem: "01-gathering", Reason: "duplicate_stem"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("details = %+v, want %+v", got, want)
+	}
+
+	if err := os.Remove(filepath.Join(env.albumDir, "02-hollow.mp3")); err != nil {
+		t.Fatalf("remove track: %v", err)
+	}
+	got = send([]map[string]string{
+		{"stem": "../bad", "title": "Bad"},
+		{"stem": "02-hollow", "title": "Hollow"},
+	})
+	want = []issue{
+		{Index: 0, Stem: "../bad", Reason: "invalid_stem"},
+		{Index: 1, Stem: "02-hollow", Reason: "missing_audio_file"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("details = %+v, want %+v", got, want)
+	}
+}
+
+func TestAdminUpdateTracksAllowNew(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+	path := fmt.Sprintf("/admin/api/albums/%d/tracks", env.albumID)
+
+	if err := os.WriteFile(filepath.Join(env.albumDir, "03-ember.mp3"), []byte("fake-mp3-3"), 0644); err != nil {
+		t.Fatalf("write track: %v", err)
+	}
+	tracks := []map[string]string{
+		{"stem": "03-ember", "title": "Ember"},
+		{"stem": "01-gathering", "title": "Gathering"},
+		{"stem": "02-hollow", "title": "Hollow"},
+	}
+
+	resp := env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{"tracks": tracks})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("without allow_new status = %d, want 400", resp.StatusCode)
+	}
+
+	resp = env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{
+		"allow_new": true,
+		"tracks": append(tracks[:1:1], map[string]string{"stem": "04-absent", "title": "Absent"},
+			tracks[1], tracks[2]),
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("missing new file status = %d, want 400", resp.StatusCode)
+	}
+
+	resp = env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{
+		"allow_new": true,
+		"tracks":    tracks[:2],
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("dropped existing track status = %d, want 400", resp.StatusCode)
+	}
+
+	resp = env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{
+		"allow_new": true,
+		"tracks":    tracks,
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("allow_new status = %d, want 200", resp.StatusCode)
+	}
+
+	got, err := env.srv.albumStore.GetTracks(env.albumID)
+	if err != nil {
+		t.Fatalf("GetTracks: %v", err)
+	}
+	if len(got) != 3 || got[0].Stem != "03-ember" || got[0].Title != "Ember" || got[2].Stem != "02-hollow" {
+		t.Fatalf("tracks = %+v", got)
+	}
+}
+
+func TestAdminUploadCoverRejectsNonImage(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("cover", "cover.txt")
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	if _, err := part.Write([]byte("not-an-image")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	writer.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+fmt.Sprintf("/admin/api/albums/%d/cover", env.albumID), &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Origin", env.ts.URL)
+	for _, c := range adminCookies {
+		req.AddCookie(c)
+	}
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("upload cover request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestSPAFallback(t *testing.T) {
+	env := setupTest(t)
+
+	resp, err := env.ts.Client().Get(env.ts.URL + "/listening-room")
+	if err != nil {
+		t.Fatalf("SPA fallback request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	if !strings.Contains(body.String(), "id=\"gate\"") {
+		t.Fatalf("expected index shell, body=%q", body.String())
+	}
+}
+
+func TestSPAIndexInjectsRuntimeConfig(t *testing.T) {
+	env := setupTest(t)
+	hostile := `Night </script><script>alert(1)</script>`
+	if err := env.srv.albumStore.UpdateAlbum(env.albumID, hostile, "Test Artist"); err != nil {
+		t.Fatalf("update album: %v", err)
+	}
+	alb, err := env.srv.albumStore.GetAlbum(env.albumID)
+	if err != nil || alb == nil {
+		t.Fatalf("get album: %v", err)
+	}
+
+	resp, err := env.ts.Client().Get(env.ts.URL + "/?album=" + url.QueryEscape(alb.Slug))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	page := string(body)
+
+	nonce := cspNonce(t, resp.Header.Get("Content-Security-Policy"))
+	prefix := `<script nonce="` + nonce + `">window.__ACETATE__ = `
+	start := strings.Index(page, prefix)
+	if start < 0 || start > strings.Index(page, "</head>") {
+		t.Fatalf("config script missing from <head>:\n%s", page)
+	}
+	if strings.Contains(page, "<script>alert(1)") {
+		t.Fatal("album title broke out of the config script")
+	}
+	raw := page[start+len(prefix):]
+	raw = strings.TrimSuffix(raw[:strings.Index(raw, "</script>")], ";")
+
+	var cfg map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		t.Fatalf("decode config %q: %v", raw, err)
+	}
+	if cfg["api_base"] != "/api" || cfg["public_base"] != "/api/public" {
+		t.Fatalf("config bases = %v", cfg)
+	}
+	if features, _ := cfg["features"].(map[string]interface{}); features["analytics"] != true {
+		t.Fatalf("features = %v", cfg["features"])
+	}
+	album, _ := cfg["album"].(map[string]interface{})
+	if album["title"] != hostile || album["requires_password"] != true {
+		t.Fatalf("album = %v", album)
+	}
+	for _, leak := range []string{env.albumDir, "album_path", "password_hash", `"id"`} {
+		if strings.Contains(raw, leak) {
+			t.Fatalf("config leaks %q: %s", leak, raw)
+		}
+	}
+
+	// Without ?album= (or for an unknown slug) no album is included.
+	resp, err = env.ts.Client().Get(env.ts.URL + "/?album=no-such-album")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `"api_base":"/api"`) || strings.Contains(string(body), `"album":`) {
+		t.Fatalf("unexpected config for unknown album:\n%s", body)
+	}
+}
+
+func TestSPAIndexAlbumLookupIsRateLimited(t *testing.T) {
+	env := setupTest(t)
+	alb, err := env.srv.albumStore.GetAlbum(env.albumID)
+	if err != nil || alb == nil {
+		t.Fatalf("get album: %v", err)
+	}
+
+	var last string
+	for i := 0; i < 61; i++ {
+		resp, err := env.ts.Client().Get(env.ts.URL + "/?album=" + url.QueryEscape(alb.Slug))
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i, resp.StatusCode)
+		}
+		if i == 0 && !strings.Contains(string(body), `"album":`) {
+			t.Fatalf("first request missing album config:\n%s", body)
+		}
+		last = string(body)
+	}
+	if !strings.Contains(last, `"api_base":"/api"`) || strings.Contains(last, `"album":`) {
+		t.Fatalf("album still looked up after the public rate limit:\n%s", last)
+	}
+
+	resp, err := env.ts.Client().Get(env.ts.URL + "/api/public/" + alb.Slug)
+	if err != nil {
+		t.Fatalf("public request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("public album status = %d, want 429 after page lookups", resp.StatusCode)
+	}
+}
+
+// cspNonce extracts the script-src nonce from a CSP header.
+func cspNonce(t *testing.T, csp string) string {
+	t.Helper()
+	_, rest, ok := strings.Cut(csp, "script-src 'self' 'nonce-")
+	if !ok {
+		t.Fatalf("CSP has no script nonce: %q", csp)
+	}
+	nonce, _, ok := strings.Cut(rest, "'")
+	if !ok || nonce == "" {
+		t.Fatalf("malformed CSP nonce: %q", csp)
+	}
+	return nonce
+}
+
+func TestCSPNonceIsFreshPerRequest(t *testing.T) {
+	env := setupTest(t)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		resp, err := env.ts.Client().Get(env.ts.URL + "/")
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		nonce := cspNonce(t, resp.Header.Get("Content-Security-Policy"))
+		if raw, err := base64.StdEncoding.DecodeString(nonce); err != nil || len(raw) < 16 {
+			t.Fatalf("nonce %q is not 128 random bits", nonce)
+		}
+		if seen[nonce] {
+			t.Fatalf("nonce %q reused across requests", nonce)
+		}
+		seen[nonce] = true
+		if !strings.Contains(string(body), `<script nonce="`+nonce+`">`) {
+			t.Fatalf("index.html inline script does not carry this response's nonce %q", nonce)
+		}
+		if cc := resp.Header.Get("Cache-Control"); cc != "no-cache" {
+			t.Fatalf("index.html Cache-Control = %q, want no-cache", cc)
+		}
+	}
+}
+
+func TestReferrerAndPermissionsPolicyOverrides(t *testing.T) {
+	env := setupTest(t)
+
+	get := func() *http.Response {
+		resp, err := env.ts.Client().Get(env.ts.URL + "/api/public/" + env.albumSlug)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := get()
+	if got := resp.Header.Get("Referrer-Policy"); got != DefaultReferrerPolicy {
+		t.Fatalf("default Referrer-Policy = %q", got)
+	}
+	if got := resp.Header.Get("Permissions-Policy"); got != DefaultPermissionsPolicy {
+		t.Fatalf("default Permissions-Policy = %q", got)
+	}
+
+	env.srv.referrerPolicy = normalizeReferrerPolicy(" Strict-Origin-When-Cross-Origin ")
+	env.srv.permissionsPolicy = "camera=(), picture-in-picture=(self)"
+	resp = get()
+	if got := resp.Header.Get("Referrer-Policy"); got != "strict-origin-when-cross-origin" {
+		t.Fatalf("overridden Referrer-Policy = %q", got)
+	}
+	if got := resp.Header.Get("Permissions-Policy"); got != "camera=(), picture-in-picture=(self)" {
+		t.Fatalf("overridden Permissions-Policy = %q", got)
+	}
+
+	if got := normalizeReferrerPolicy("send-everything"); got != DefaultReferrerPolicy {
+		t.Fatalf("invalid Referrer-Policy normalized to %q, want default", got)
+	}
+}
+
+func TestSecurityHeadersDoNotAllowInlineStyles(t *testing.T) {
+	env := setupTest(t)
+
+	resp, err := env.ts.Client().Get(env.ts.URL + "/")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	csp := resp.Header.Get("Content-Security-Policy")
+	if csp == "" {
+		t.Fatal("expected Content-Security-Policy header")
+	}
+	if strings.Contains(csp, "'unsafe-inline'") {
+		t.Fatalf("unexpected unsafe-inline in CSP: %q", csp)
+	}
+}
+
+func TestCSPReportEndpoint(t *testing.T) {
+	env := setupTest(t)
+	post := func(contentType, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/api/csp-report", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("csp report request: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	legacy := `{"csp-report":{"document-uri":"https://example.com/","blocked-uri":"https://cdn.example.net/x.js","effective-directive":"script-src-elem","original-policy":"default-src 'self'"}}`
+
+	// Off by default: no directive and no endpoint.
+	resp, err := env.ts.Client().Get(env.ts.URL + "/")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if csp := resp.Header.Get("Content-Security-Policy"); strings.Contains(csp, "report-uri") {
+		t.Fatalf("CSP = %q, want no report-uri when disabled", csp)
+	}
+	if status := post("application/csp-report", legacy); status != http.StatusNotFound {
+		t.Fatalf("disabled report status = %d, want 404", status)
+	}
+
+	env.srv.cspReportEnabled = true
+	resp, err = env.ts.Client().Get(env.ts.URL + "/")
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	csp := resp.Header.Get("Content-Security-Policy")
+	if !strings.Contains(csp, "report-uri /api/csp-report") || !strings.Contains(csp, "report-to csp-endpoint") {
+		t.Fatalf("CSP = %q, want report directives", csp)
+	}
+	if got := resp.Header.Get("Reporting-Endpoints"); got != `csp-endpoint="/api/csp-report"` {
+		t.Fatalf("Reporting-Endpoints = %q", got)
+	}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	if status := post("application/csp-report", legacy); status != http.StatusNoContent {
+		t.Fatalf("legacy report status = %d, want 204", status)
+	}
+	reportingAPI := `[{"type":"csp-violation","body":{"documentURL":"https://example.com/admin","blockedURL":"inline","effectiveDirective":"style-src-attr"}},{"type":"deprecation","body":{}}]`
+	if status := post("application/reports+json", reportingAPI); status != http.StatusNoContent {
+		t.Fatalf("reporting API status = %d, want 204", status)
+	}
+	if status := post("application/csp-report", "not json"); status != http.StatusBadRequest {
+		t.Fatalf("malformed report status = %d, want 400", status)
+	}
+	if status := post("application/csp-report", `{"csp-report":{"blocked-uri":"`+strings.Repeat("a", cspReportMaxBodyBytes)+`"}}`); status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversized report status = %d, want 413", status)
+	}
+	if !strings.Contains(logs.String(), `csp violation: directive="script-src-elem" blocked="https://cdn.example.net/x.js"`) {
+		t.Fatalf("log output missing violation: %s", logs.String())
+	}
+
+	adminCookies := env.authenticateAdmin(t)
+	adminResp := env.adminDo(t, adminCookies, http.MethodGet, "/admin/api/ops/csp-reports", nil)
+	defer adminResp.Body.Close()
+	var payload struct {
+		Total   int64          `json:"total"`
+		Reports []cspViolation `json:"reports"`
+	}
+	if err := json.NewDecoder(adminResp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode reports: %v", err)
+	}
+	if payload.Total != 2 || len(payload.Reports) != 2 || payload.Reports[0].EffectiveDirective != "style-src-attr" || payload.Reports[1].DocumentURI != "https://example.com/" {
+		t.Fatalf("stored reports = %+v", payload)
+	}
+}
+
+func TestCSPReportLogIsBounded(t *testing.T) {
+	l := newCSPReportLog()
+	for i := 0; i < cspReportBufferSize+5; i++ {
+		l.add(cspViolation{LineNumber: i})
+	}
+	reports, total := l.snapshot()
+	if total != int64(cspReportBufferSize+5) || len(reports) != cspReportBufferSize {
+		t.Fatalf("total = %d, stored = %d", total, len(reports))
+	}
+	if reports[0].LineNumber != cspReportBufferSize+4 || reports[len(reports)-1].LineNumber != 5 {
+		t.Fatalf("newest = %d, oldest = %d", reports[0].LineNumber, reports[len(reports)-1].LineNumber)
+	}
+}
+
+func TestAnalyticsEndpoint(t *testing.T) {
+	env := setupTest(t)
+	cookies := env.authenticate(t)
+
+	events := []map[string]interface{}{
+		{"event_type": "play", "track_stem": "01-gathering"},
+		{"event_type": "pause", "track_stem": "01-gathering", "position_seconds": 30.5},
+	}
+	body, _ := json.Marshal(events)
+
+	req, _ := http.NewRequest("POST", env.ts.URL+"/api/albums/"+env.albumSlug+"/analytics", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("analytics request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("analytics status = %d, want 204", resp.StatusCode)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	env := setupTest(t)
+	cookies := env.authenticate(t)
+
+	req, _ := http.NewRequest("DELETE", env.ts.URL+"/api/auth", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("logout request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("logout status = %d, want 200", resp.StatusCode)
+	}
+
+	// Session should be invalid now
+	req, _ = http.NewRequest("GET", env.ts.URL+"/api/session", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	resp, err = env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("post-logout request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("post-logout status = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestAdminAuthWithHashedBootstrapPassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hashed-admin-pass-123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("generate hash: %v", err)
+	}
+
+	env := setupTestWithBootstrap(t, "admin2", "", string(hash))
+
+	body, _ := json.Marshal(map[string]string{
+		"username": "admin2",
+		"password": "hashed-admin-pass-123",
+	})
+	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/auth", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", env.ts.URL)
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("admin auth request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestAdminUpdateOwnPassword(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	body, _ := json.Marshal(map[string]string{
+		"current_password": testAdminPassword,
+		"new_password":     "new-admin-pass-456",
+	})
+	req, _ := http.NewRequest(http.MethodPut, env.ts.URL+"/admin/api/admin-password", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", env.ts.URL)
+	for _, c := range adminCookies {
+		req.AddCookie(c)
+	}
+
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("update admin password request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	// Old password should no longer work.
+	oldAuthBody, _ := json.Marshal(map[string]string{
+		"username": testAdminUsername,
+		"password": testAdminPassword,
+	})
+	oldAuthReq, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/auth", bytes.NewReader(oldAuthBody))
+	oldAuthReq.Header.Set("Content-Type", "application/json")
+	oldAuthReq.Header.Set("Origin", env.ts.URL)
+	oldAuthResp, err := env.ts.Client().Do(oldAuthReq)
+	if err != nil {
+		t.Fatalf("old auth request: %v", err)
+	}
+	oldAuthResp.Body.Close()
+	if oldAuthResp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("old password auth status = %d, want 401", oldAuthResp.StatusCode)
+	}
+
+	// New password should work.
+	newAuthBody, _ := json.Marshal(map[string]string{
+		"username": testAdminUsername,
+		"password": "new-admin-pass-456",
+	})
+	newAuthReq, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/auth", bytes.NewReader(newAuthBody))
+	newAuthReq.Header.Set("Content-Type", "application/json")
+	newAuthReq.Header.Set("Origin", env.ts.URL)
+	newAuthResp, err := env.ts.Client().Do(newAuthReq)
+	if err != nil {
+		t.Fatalf("new auth request: %v", err)
+	}
+	newAuthResp.Body.Close()
+	if newAuthResp.StatusCode != http.StatusOK {
+		t.Fatalf("new password auth status = %d, want 200", newAuthResp.StatusCode)
+	}
+}
+
+func TestAdminAuthLockoutAfterRepeatedFailures(t *testing.T) {
+	env := setupTest(t)
+
+	for i := 0; i < 5; i++ {
+		_, _, status := env.authenticateAdminAs(t, testAdminUsername, "wrong-password")
+		if status != http.StatusUnauthorized {
+			t.Fatalf("attempt %d status = %d, want 401", i+1, status)
+		}
+	}
+
+	_, _, status := env.authenticateAdminAs(t, testAdminUsername, "wrong-password")
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("locked attempt status = %d, want 429", status)
+	}
+
+	_, _, status = env.authenticateAdminAs(t, testAdminUsername, testAdminPassword)
+	if status != http.StatusTooManyRequests {
+		t.Fatalf("locked correct-password attempt status = %d, want 429", status)
+	}
+}
+
+func TestAdminUserManagementAndForcedPasswordResetFlow(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
 
 	var baseAdminID int64
 	if err := env.srv.db.QueryRow("SELECT id FROM admin_users WHERE username = ?", testAdminUsername).Scan(&baseAdminID); err != nil {
 		t.Fatalf("query base admin id: %v", err)
 	}
 
-	// Cannot deactivate own account.
-	selfDeactivateBody, _ := json.Marshal(map[string]interface{}{
-		"is_active": false,
+	// Cannot deactivate own account.
+	selfDeactivateBody, _ := json.Marshal(map[string]interface{}{
+		"is_active": false,
+	})
+	selfDeactivateReq, _ := http.NewRequest(http.MethodPut, env.ts.URL+"/admin/api/admin-users/"+strconv.FormatInt(baseAdminID, 10), bytes.NewReader(selfDeactivateBody))
+	selfDeactivateReq.Header.Set("Content-Type", "application/json")
+	selfDeactivateReq.Header.Set("Origin", env.ts.URL)
+	for _, c := range adminCookies {
+		selfDeactivateReq.AddCookie(c)
+	}
+	selfDeactivateResp, err := env.ts.Client().Do(selfDeactivateReq)
+	if err != nil {
+		t.Fatalf("self deactivate request: %v", err)
+	}
+	selfDeactivateResp.Body.Close()
+	if selfDeactivateResp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("self deactivate status = %d, want 400", selfDeactivateResp.StatusCode)
+	}
+
+	// Create second admin and require password reset.
+	createBody, _ := json.Marshal(map[string]interface{}{
+		"username":               "opsadmin",
+		"password":               "ops-admin-pass-123",
+		"require_password_reset": true,
+	})
+	createReq, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/admin-users", bytes.NewReader(createBody))
+	createReq.Header.Set("Content-Type", "application/json")
+	createReq.Header.Set("Origin", env.ts.URL)
+	for _, c := range adminCookies {
+		createReq.AddCookie(c)
+	}
+	createResp, err := env.ts.Client().Do(createReq)
+	if err != nil {
+		t.Fatalf("create admin user request: %v", err)
+	}
+	if createResp.StatusCode != http.StatusCreated {
+		createResp.Body.Close()
+		t.Fatalf("create admin user status = %d, want 201", createResp.StatusCode)
+	}
+	var created struct {
+		ID                   int64  `json:"id"`
+		Username             string `json:"username"`
+		RequirePasswordReset bool   `json:"require_password_reset"`
+	}
+	if err := json.NewDecoder(createResp.Body).Decode(&created); err != nil {
+		createResp.Body.Close()
+		t.Fatalf("decode created admin user: %v", err)
+	}
+	createResp.Body.Close()
+	if created.ID <= 0 || created.Username != "opsadmin" || !created.RequirePasswordReset {
+		t.Fatalf("unexpected created admin user payload: %+v", created)
+	}
+
+	// New admin is forced into password-reset mode.
+	opsCookies, authPayload, status := env.authenticateAdminAs(t, "opsadmin", "ops-admin-pass-123")
+	if status != http.StatusOK {
+		t.Fatalf("ops admin login status = %d, want 200", status)
+	}
+	if resetRaw, ok := authPayload["password_reset_required"].(bool); !ok || !resetRaw {
+		t.Fatalf("expected password_reset_required=true, got payload=%v", authPayload)
+	}
+
+	analyticsReq, _ := http.NewRequest(http.MethodGet, env.ts.URL+fmt.Sprintf("/admin/api/albums/%d/analytics", env.albumID), nil)
+	for _, c := range opsCookies {
+		analyticsReq.AddCookie(c)
+	}
+	analyticsResp, err := env.ts.Client().Do(analyticsReq)
+	if err != nil {
+		t.Fatalf("analytics request in reset mode: %v", err)
+	}
+	analyticsResp.Body.Close()
+	if analyticsResp.StatusCode != http.StatusForbidden {
+		t.Fatalf("analytics status in reset mode = %d, want 403", analyticsResp.StatusCode)
+	}
+
+	configReq, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/admin/api/config", nil)
+	for _, c := range opsCookies {
+		configReq.AddCookie(c)
+	}
+	configResp, err := env.ts.Client().Do(configReq)
+	if err != nil {
+		t.Fatalf("config request in reset mode: %v", err)
+	}
+	if configResp.StatusCode != http.StatusOK {
+		configResp.Body.Close()
+		t.Fatalf("config status in reset mode = %d, want 200", configResp.StatusCode)
+	}
+	var configPayload struct {
+		PasswordResetRequired bool `json:"password_reset_required"`
+	}
+	if err := json.NewDecoder(configResp.Body).Decode(&configPayload); err != nil {
+		configResp.Body.Close()
+		t.Fatalf("decode config payload: %v", err)
+	}
+	configResp.Body.Close()
+	if !configPayload.PasswordResetRequired {
+		t.Fatal("expected password_reset_required=true in config")
+	}
+
+	updatePassBody, _ := json.Marshal(map[string]string{
+		"current_password": "ops-admin-pass-123",
+		"new_password":     "ops-admin-pass-456",
+	})
+	updatePassReq, _ := http.NewRequest(http.MethodPut, env.ts.URL+"/admin/api/admin-password", bytes.NewReader(updatePassBody))
+	updatePassReq.Header.Set("Content-Type", "application/json")
+	updatePassReq.Header.Set("Origin", env.ts.URL)
+	for _, c := range opsCookies {
+		updatePassReq.AddCookie(c)
+	}
+	updatePassResp, err := env.ts.Client().Do(updatePassReq)
+	if err != nil {
+		t.Fatalf("update ops admin password request: %v", err)
+	}
+	updatePassResp.Body.Close()
+	if updatePassResp.StatusCode != http.StatusOK {
+		t.Fatalf("update ops admin password status = %d, want 200", updatePassResp.StatusCode)
+	}
+
+	// Session is revoked after password change.
+	oldSessionConfigReq, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/admin/api/config", nil)
+	for _, c := range opsCookies {
+		oldSessionConfigReq.AddCookie(c)
+	}
+	oldSessionConfigResp, err := env.ts.Client().Do(oldSessionConfigReq)
+	if err != nil {
+		t.Fatalf("old-session config request: %v", err)
+	}
+	oldSessionConfigResp.Body.Close()
+	if oldSessionConfigResp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("old-session config status = %d, want 401", oldSessionConfigResp.StatusCode)
+	}
+
+	newOpsCookies, newAuthPayload, status := env.authenticateAdminAs(t, "opsadmin", "ops-admin-pass-456")
+	if status != http.StatusOK {
+		t.Fatalf("ops admin relogin status = %d, want 200", status)
+	}
+	if resetRaw, ok := newAuthPayload["password_reset_required"].(bool); !ok || resetRaw {
+		t.Fatalf("expected password_reset_required=false, got payload=%v", newAuthPayload)
+	}
+
+	analyticsReq2, _ := http.NewRequest(http.MethodGet, env.ts.URL+fmt.Sprintf("/admin/api/albums/%d/analytics", env.albumID), nil)
+	for _, c := range newOpsCookies {
+		analyticsReq2.AddCookie(c)
+	}
+	analyticsResp2, err := env.ts.Client().Do(analyticsReq2)
+	if err != nil {
+		t.Fatalf("analytics request after reset: %v", err)
+	}
+	analyticsResp2.Body.Close()
+	if analyticsResp2.StatusCode != http.StatusOK {
+		t.Fatalf("analytics status after reset = %d, want 200", analyticsResp2.StatusCode)
+	}
+
+	// Active admin can deactivate another account.
+	deactivateBody, _ := json.Marshal(map[string]interface{}{
+		"is_active": false,
+	})
+	deactivateReq, _ := http.NewRequest(http.MethodPut, env.ts.URL+"/admin/api/admin-users/"+strconv.FormatInt(created.ID, 10), bytes.NewReader(deactivateBody))
+	deactivateReq.Header.Set("Content-Type", "application/json")
+	deactivateReq.Header.Set("Origin", env.ts.URL)
+	for _, c := range adminCookies {
+		deactivateReq.AddCookie(c)
+	}
+	deactivateResp, err := env.ts.Client().Do(deactivateReq)
+	if err != nil {
+		t.Fatalf("deactivate ops admin request: %v", err)
+	}
+	deactivateResp.Body.Close()
+	if deactivateResp.StatusCode != http.StatusOK {
+		t.Fatalf("deactivate ops admin status = %d, want 200", deactivateResp.StatusCode)
+	}
+
+	_, _, status = env.authenticateAdminAs(t, "opsadmin", "ops-admin-pass-456")
+	if status != http.StatusUnauthorized {
+		t.Fatalf("disabled admin login status = %d, want 401", status)
+	}
+}
+
+func TestAdminConfigEndpoint(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	req, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/admin/api/config", nil)
+	for _, c := range adminCookies {
+		req.AddCookie(c)
+	}
+
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("config request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var payload map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode config payload: %v", err)
+	}
+
+	if _, ok := payload["password_hash"]; ok {
+		t.Fatalf("password_hash should not be exposed in config payload: %+v", payload)
+	}
+
+	albumCount, ok := payload["album_count"].(float64)
+	if !ok || albumCount < 1 {
+		t.Fatalf("expected album_count >= 1, got payload=%+v", payload)
+	}
+}
+
+func TestAdminFounderCannotBeDeactivatedByAnotherAdmin(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	var founderID int64
+	if err := env.srv.db.QueryRow("SELECT id FROM admin_users WHERE username = ?", testAdminUsername).Scan(&founderID); err != nil {
+		t.Fatalf("query founder admin id: %v", err)
+	}
+
+	createBody, _ := json.Marshal(map[string]interface{}{
+		"username":               "sreadmin",
+		"password":               "sre-admin-pass-123",
+		"require_password_reset": false,
+	})
+	createReq, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/admin-users", bytes.NewReader(createBody))
+	createReq.Header.Set("Content-Type", "application/json")
+	createReq.Header.Set("Origin", env.ts.URL)
+	for _, c := range adminCookies {
+		createReq.AddCookie(c)
+	}
+	createResp, err := env.ts.Client().Do(createReq)
+	if err != nil {
+		t.Fatalf("create admin user request: %v", err)
+	}
+	createResp.Body.Close()
+	if createResp.StatusCode != http.StatusCreated {
+		t.Fatalf("create admin status = %d, want 201", createResp.StatusCode)
+	}
+
+	otherCookies, _, status := env.authenticateAdminAs(t, "sreadmin", "sre-admin-pass-123")
+	if status != http.StatusOK {
+		t.Fatalf("secondary admin auth status = %d, want 200", status)
+	}
+
+	deactivateFounderBody, _ := json.Marshal(map[string]interface{}{
+		"is_active": false,
+	})
+	deactivateFounderReq, _ := http.NewRequest(http.MethodPut, env.ts.URL+"/admin/api/admin-users/"+strconv.FormatInt(founderID, 10), bytes.NewReader(deactivateFounderBody))
+	deactivateFounderReq.Header.Set("Content-Type", "application/json")
+	deactivateFounderReq.Header.Set("Origin", env.ts.URL)
+	for _, c := range otherCookies {
+		deactivateFounderReq.AddCookie(c)
+	}
+
+	deactivateFounderResp, err := env.ts.Client().Do(deactivateFounderReq)
+	if err != nil {
+		t.Fatalf("founder deactivate request: %v", err)
+	}
+	defer deactivateFounderResp.Body.Close()
+	if deactivateFounderResp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("founder deactivate status = %d, want 400", deactivateFounderResp.StatusCode)
+	}
+
+	var payload map[string]string
+	if err := json.NewDecoder(deactivateFounderResp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode founder deactivate payload: %v", err)
+	}
+	if !strings.Contains(strings.ToLower(payload["error"]), "original admin") {
+		t.Fatalf("unexpected founder protection error payload: %+v", payload)
+	}
+}
+
+func TestAdminCanRenameAnotherUser(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	createBody, _ := json.Marshal(map[string]interface{}{
+		"username":               "oldname",
+		"password":               "rename-pass-123",
+		"require_password_reset": false,
+	})
+	createReq, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/admin-users", bytes.NewReader(createBody))
+	createReq.Header.Set("Content-Type", "application/json")
+	createReq.Header.Set("Origin", env.ts.URL)
+	for _, c := range adminCookies {
+		createReq.AddCookie(c)
+	}
+	createResp, err := env.ts.Client().Do(createReq)
+	if err != nil {
+		t.Fatalf("create admin user request: %v", err)
+	}
+	if createResp.StatusCode != http.StatusCreated {
+		createResp.Body.Close()
+		t.Fatalf("create admin status = %d, want 201", createResp.StatusCode)
+	}
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.NewDecoder(createResp.Body).Decode(&created); err != nil {
+		createResp.Body.Close()
+		t.Fatalf("decode created admin: %v", err)
+	}
+	createResp.Body.Close()
+	if created.ID <= 0 {
+		t.Fatalf("invalid created admin id: %+v", created)
+	}
+
+	renameBody, _ := json.Marshal(map[string]interface{}{
+		"username": "newname",
+	})
+	renameReq, _ := http.NewRequest(http.MethodPut, env.ts.URL+"/admin/api/admin-users/"+strconv.FormatInt(created.ID, 10), bytes.NewReader(renameBody))
+	renameReq.Header.Set("Content-Type", "application/json")
+	renameReq.Header.Set("Origin", env.ts.URL)
+	for _, c := range adminCookies {
+		renameReq.AddCookie(c)
+	}
+
+	renameResp, err := env.ts.Client().Do(renameReq)
+	if err != nil {
+		t.Fatalf("rename admin request: %v", err)
+	}
+	renameResp.Body.Close()
+	if renameResp.StatusCode != http.StatusOK {
+		t.Fatalf("rename admin status = %d, want 200", renameResp.StatusCode)
+	}
+
+	_, _, status := env.authenticateAdminAs(t, "oldname", "rename-pass-123")
+	if status != http.StatusUnauthorized {
+		t.Fatalf("old username auth status = %d, want 401", status)
+	}
+
+	_, _, status = env.authenticateAdminAs(t, "newname", "rename-pass-123")
+	if status != http.StatusOK {
+		t.Fatalf("new username auth status = %d, want 200", status)
+	}
+}
+
+func TestAdminBulkCreateUsers(t *testing.T) {
+	env := setupTest(t)
+	env.srv.passwordPolicy = PasswordPolicy{MinLength: 24, RequireSymbol: true, RequireMixedCase: true}
+	adminCookies := env.authenticateAdmin(t)
+
+	resp := env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/admin-users/bulk", []map[string]interface{}{
+		{"username": "Alice", "role": "admin"},
+		{"username": "bob", "require_password_reset": false},
+		{"username": "carol"},
+	})
+	var created struct {
+		Users []adminBulkUserResult `json:"users"`
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || len(created.Users) != 3 {
+		t.Fatalf("bulk create status = %d, users = %+v", resp.StatusCode, created.Users)
+	}
+	for _, u := range created.Users {
+		if u.ID <= 0 || u.Error != "" || env.srv.passwordPolicy.validate(u.TemporaryPassword) != nil {
+			t.Fatalf("created user = %+v", u)
+		}
+	}
+	if created.Users[0].Username != "alice" || !created.Users[0].RequirePasswordReset || created.Users[1].RequirePasswordReset {
+		t.Fatalf("created users = %+v", created.Users)
+	}
+	// The temporary password works for login.
+	if _, _, status := env.authenticateAdminAs(t, "bob", created.Users[1].TemporaryPassword); status != http.StatusOK {
+		t.Fatalf("temporary password login status = %d, want 200", status)
+	}
+
+	countUsers := func() int {
+		var n int
+		env.srv.db.QueryRow("SELECT COUNT(*) FROM admin_users").Scan(&n)
+		return n
+	}
+	before := countUsers()
+
+	// A duplicate within the batch rejects the whole batch.
+	resp = env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/admin-users/bulk", []map[string]interface{}{
+		{"username": "dave"},
+		{"username": "DAVE"},
+	})
+	var rejected struct {
+		Results []adminBulkUserResult `json:"results"`
+	}
+	json.NewDecoder(resp.Body).Decode(&rejected)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || len(rejected.Results) != 2 || rejected.Results[0].Error != "" || rejected.Results[1].Error == "" {
+		t.Fatalf("duplicate batch status = %d, results = %+v", resp.StatusCode, rejected.Results)
+	}
+
+	// A name already taken rolls back the entries inserted before it.
+	resp = env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/admin-users/bulk", []map[string]interface{}{
+		{"username": "erin"},
+		{"username": "carol"},
+	})
+	rejected.Results = nil
+	json.NewDecoder(resp.Body).Decode(&rejected)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict || len(rejected.Results) != 2 || rejected.Results[1].Error != "username already exists" {
+		t.Fatalf("existing name status = %d, results = %+v", resp.StatusCode, rejected.Results)
+	}
+	if rejected.Results[0].TemporaryPassword != "" || rejected.Results[0].ID != 0 {
+		t.Fatalf("rolled back entry leaked credentials: %+v", rejected.Results[0])
+	}
+	if got := countUsers(); got != before {
+		t.Fatalf("admin users = %d after rejected batches, want %d", got, before)
+	}
+
+	resp = env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/admin-users/bulk", []map[string]interface{}{
+		{"username": "frank", "role": "viewer"},
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unsupported role status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestAdminBulkCreateUsersSkipsBreachCheck(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	var lookups atomic.Int32
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+	}))
+	defer mock.Close()
+	env.srv.pwnedChecker = auth.NewPwnedChecker(mock.URL + "/range/")
+
+	resp := env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/admin-users/bulk", []map[string]interface{}{
+		{"username": "dave"},
+		{"username": "erin"},
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("bulk create status = %d, want 201", resp.StatusCode)
+	}
+	if n := lookups.Load(); n != 0 {
+		t.Fatalf("breach check lookups = %d, want 0 for generated passwords", n)
+	}
+}
+func TestAdminUpdateUserDetectsConcurrentChange(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	resp := env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/admin-users", map[string]interface{}{
+		"username": "tabbed",
+		"password": "tabbed-pass-123",
+	})
+	var loaded adminUserView
+	json.NewDecoder(resp.Body).Decode(&loaded)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || loaded.UpdatedAt == "" {
+		t.Fatalf("create status = %d, user = %+v", resp.StatusCode, loaded)
+	}
+	path := "/admin/api/admin-users/" + strconv.FormatInt(loaded.ID, 10)
+
+	// Two tabs loaded the same version; the first save wins.
+	resp = env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{
+		"username":            "tabbed-one",
+		"expected_updated_at": loaded.UpdatedAt,
+	})
+	var saved adminUserView
+	json.NewDecoder(resp.Body).Decode(&saved)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || saved.Username != "tabbed-one" {
+		t.Fatalf("first update status = %d, user = %+v", resp.StatusCode, saved)
+	}
+
+	resp = env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{
+		"username":            "tabbed-two",
+		"expected_updated_at": loaded.UpdatedAt,
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("stale update status = %d, want 409", resp.StatusCode)
+	}
+	current, err := env.srv.getAdminUserViewByID(loaded.ID)
+	if err != nil || current.Username != "tabbed-one" {
+		t.Fatalf("stored user = %+v, %v; want first update kept", current, err)
+	}
+
+	// Reloading picks up the new version and the retry succeeds.
+	resp = env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{
+		"username":            "tabbed-two",
+		"expected_updated_at": current.UpdatedAt,
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("retried update status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestAdminLogoutUserRevokesSessions(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	resp := env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/admin-users", map[string]interface{}{
+		"username":               "other",
+		"password":               "other-pass-123",
+		"require_password_reset": false,
+	})
+	var created adminUserView
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create status = %d", resp.StatusCode)
+	}
+	otherCookies, _, status := env.authenticateAdminAs(t, "other", "other-pass-123")
+	if status != http.StatusOK {
+		t.Fatalf("other login status = %d", status)
+	}
+	resp = env.adminDo(t, otherCookies, http.MethodGet, "/admin/api/admin-users", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("other session status = %d, want 200", resp.StatusCode)
+	}
+
+	path := "/admin/api/admin-users/" + strconv.FormatInt(created.ID, 10) + "/logout"
+	resp = env.adminDo(t, adminCookies, http.MethodPost, path, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("logout user status = %d, want 200", resp.StatusCode)
+	}
+
+	resp = env.adminDo(t, otherCookies, http.MethodGet, "/admin/api/admin-users", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("revoked session status = %d, want 401", resp.StatusCode)
+	}
+	user, err := env.srv.getAdminUserViewByID(created.ID)
+	if err != nil || !user.IsActive {
+		t.Fatalf("user after logout = %+v, %v; want still active", user, err)
+	}
+	if _, _, status := env.authenticateAdminAs(t, "other", "other-pass-123"); status != http.StatusOK {
+		t.Fatalf("login after logout status = %d, want 200", status)
+	}
+
+	// The caller's own session is untouched, and it cannot target itself.
+	resp = env.adminDo(t, adminCookies, http.MethodGet, "/admin/api/admin-users", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("actor session status = %d, want 200", resp.StatusCode)
+	}
+	var actorID int64
+	if err := env.srv.db.QueryRow("SELECT id FROM admin_users WHERE username = ?", testAdminUsername).Scan(&actorID); err != nil {
+		t.Fatalf("query actor id: %v", err)
+	}
+	resp = env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/admin-users/"+strconv.FormatInt(actorID, 10)+"/logout", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("self logout status = %d, want 400", resp.StatusCode)
+	}
+	resp = env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/admin-users/9999/logout", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown user logout status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestSharedAdminLoginGuardAcrossInstances(t *testing.T) {
+	env := setupTest(t)
+
+	// Two instances sharing one database.
+	first := newSharedAdminLoginGuard(env.srv.db)
+	second := newSharedAdminLoginGuard(env.srv.db)
+	key := "admin|203.0.113.9"
+	now := time.Now().UTC()
+
+	var backoff time.Duration
+	for i := 0; i < adminLoginLockThreshold; i++ {
+		guard := first
+		if i%2 == 1 {
+			guard = second
+		}
+		if allowed, _ := guard.allow(key, now); !allowed {
+			t.Fatalf("attempt %d locked out early", i+1)
+		}
+		backoff = guard.markFailure(key, now)
+	}
+	if backoff != adminLoginBackoffBase {
+		t.Fatalf("backoff after %d failures = %s, want %s", adminLoginLockThreshold, backoff, adminLoginBackoffBase)
+	}
+	for name, guard := range map[string]*adminLoginGuard{"first": first, "second": second} {
+		if allowed, retry := guard.allow(key, now.Add(time.Second)); allowed || retry <= 0 {
+			t.Fatalf("%s instance allowed a locked key (retry %s)", name, retry)
+		}
+		if got := guard.failures(key); got != adminLoginLockThreshold {
+			t.Fatalf("%s instance sees %d failures, want %d", name, got, adminLoginLockThreshold)
+		}
+	}
+	if allowed, _ := second.allow(key, now.Add(adminLoginBackoffBase+time.Second)); !allowed {
+		t.Fatal("key should unlock once the backoff passes")
+	}
+
+	// A success on one instance clears the key everywhere.
+	first.markSuccess(key)
+	if got := second.failures(key); got != 0 {
+		t.Fatalf("failures after success = %d, want 0", got)
+	}
+
+	// Failures older than the reset window start a fresh count.
+	for i := 0; i < adminLoginLockThreshold; i++ {
+		first.markFailure(key, now)
+	}
+	later := now.Add(adminLoginResetWindow + time.Minute)
+	if allowed, _ := second.allow(key, later); !allowed {
+		t.Fatal("stale lockout should be forgotten after the reset window")
+	}
+	if backoff := second.markFailure(key, later); backoff != 0 || second.failures(key) != 1 {
+		t.Fatalf("failure after reset: backoff %s, failures %d; want 0 and 1", backoff, second.failures(key))
+	}
+}
+
+func TestAdminAnalyticsFiltersByStem(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	_, _ = env.srv.db.Exec("INSERT INTO events (session_id, event_type, track_stem, created_at) VALUES ('s1', 'play', '01-gathering', datetime('now'))")
+	_, _ = env.srv.db.Exec("INSERT INTO events (session_id, event_type, track_stem, created_at) VALUES ('s2', 'play', '02-hollow', datetime('now'))")
+
+	req, _ := http.NewRequest(http.MethodGet, env.ts.URL+fmt.Sprintf("/admin/api/albums/%d/analytics?stems=01-gathering", env.albumID), nil)
+	for _, c := range adminCookies {
+		req.AddCookie(c)
+	}
+
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("analytics request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var payload struct {
+		Tracks []struct {
+			Stem string `json:"stem"`
+		} `json:"tracks"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(payload.Tracks) != 1 || payload.Tracks[0].Stem != "01-gathering" {
+		t.Fatalf("unexpected tracks payload: %+v", payload.Tracks)
+	}
+}
+
+func TestAdminReconcilePreview(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	if err := os.WriteFile(filepath.Join(env.albumDir, "03-new-song.mp3"), []byte("fake"), 0644); err != nil {
+		t.Fatalf("write new song: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, env.ts.URL+fmt.Sprintf("/admin/api/albums/%d/reconcile", env.albumID), nil)
+	for _, c := range adminCookies {
+		req.AddCookie(c)
+	}
+
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("reconcile request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var payload struct {
+		AlbumOnly []struct {
+			Stem string `json:"stem"`
+		} `json:"album_only"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(payload.AlbumOnly) != 1 || payload.AlbumOnly[0].Stem != "03-new-song" {
+		t.Fatalf("unexpected album_only payload: %+v", payload.AlbumOnly)
+	}
+}
+
+func TestAdminOpsHealth(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	req, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/admin/api/ops/health", nil)
+	for _, c := range adminCookies {
+		req.AddCookie(c)
+	}
+
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("health request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var payload map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if payload["status"] != "ok" {
+		t.Fatalf("unexpected health status: %v", payload["status"])
+	}
+	analyticsHealth, _ := payload["analytics"].(map[string]interface{})
+	flush, _ := analyticsHealth["flush"].(map[string]interface{})
+	if flush["stalled"] != false || flush["last_flush_at"] == nil {
+		t.Fatalf("unexpected flush health: %v", flush)
+	}
+}
+
+func TestAdminOpsWhoamiUntrustedPeer(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	req, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/admin/api/ops/whoami", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.9")
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	req.Header.Add("X-Forwarded-For", "198.51.100.2")
+	for _, c := range adminCookies {
+		req.AddCookie(c)
+	}
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("whoami request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var payload struct {
+		ClientIP    string            `json:"client_ip"`
+		Source      string            `json:"source"`
+		RemoteAddr  string            `json:"remote_addr"`
+		PeerTrusted bool              `json:"peer_trusted"`
+		Headers     map[string]string `json:"headers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	// The test client is not a Cloudflare address, so its headers are shown
+	// but not honoured.
+	if payload.ClientIP != "127.0.0.1" || payload.PeerTrusted || payload.Source != "remote_addr" {
+		t.Fatalf("payload = %+v", payload)
+	}
+	if !strings.HasPrefix(payload.RemoteAddr, "127.0.0.1:") {
+		t.Fatalf("remote_addr = %q", payload.RemoteAddr)
+	}
+	if payload.Headers["CF-Connecting-IP"] != "203.0.113.9" || payload.Headers["X-Forwarded-For"] != "198.51.100.1, 198.51.100.2" {
+		t.Fatalf("headers = %v", payload.Headers)
+	}
+	if _, ok := payload.Headers["X-Real-IP"]; ok {
+		t.Fatalf("headers include absent X-Real-IP: %v", payload.Headers)
+	}
+}
+
+func TestConfiguredClientIPHeaderIgnoredFromUntrustedPeer(t *testing.T) {
+	env := setupTest(t)
+	env.srv.cfIPs.SetClientIPHeader("X-Real-IP")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "198.51.100.7:4321"
+	req.Header.Set("X-Real-IP", "203.0.113.20")
+	res := env.srv.cfIPs.ResolveClientIP(req)
+	if res.ClientIP != "198.51.100.7" || res.Header != "X-Real-IP" || res.Source != "remote_addr" {
+		t.Fatalf("resolution = %+v", res)
+	}
+}
+
+func TestConfiguredClientIPHeaderHonouredFromTrustedProxy(t *testing.T) {
+	proxies, _ := auth.ParseTrustedProxies("10.0.0.0/8")
+	env := setupTestWithConfig(t, func(cfg *Config) {
+		cfg.ClientIPHeader = "X-Real-IP"
+		cfg.TrustedProxies = proxies
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.5:4321"
+	req.Header.Set("X-Real-IP", "203.0.113.20")
+	res := env.srv.cfIPs.ResolveClientIP(req)
+	if res.ClientIP != "203.0.113.20" || !res.PeerTrusted || res.Source != "header" {
+		t.Fatalf("resolution = %+v", res)
+	}
+}
+func TestAdminOpsHealthCoverCheck(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	health := func() map[string]interface{} {
+		t.Helper()
+		resp := env.adminDo(t, adminCookies, http.MethodGet, "/admin/api/ops/health", nil)
+		defer resp.Body.Close()
+		var payload map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return payload
+	}
+
+	// The fixture cover.jpg is not a real image.
+	payload := health()
+	issues, _ := payload["cover_issues"].([]interface{})
+	if payload["cover_ok"] != false || len(issues) != 1 {
+		t.Fatalf("fake cover: cover_ok = %v, issues = %v", payload["cover_ok"], payload["cover_issues"])
+	}
+
+	os.Remove(filepath.Join(env.albumDir, "cover.jpg"))
+	payload = health()
+	if payload["cover_ok"] != false {
+		t.Fatalf("missing cover: cover_ok = %v, want false", payload["cover_ok"])
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatalf("encode cover: %v", err)
+	}
+	os.WriteFile(filepath.Join(env.albumDir, "cover.png"), buf.Bytes(), 0644)
+	payload = health()
+	if payload["cover_ok"] != true {
+		t.Fatalf("valid cover: cover_ok = %v, issues = %v", payload["cover_ok"], payload["cover_issues"])
+	}
+}
+
+func TestAdminExportEventsCSV(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+	listenerCookies := env.authenticate(t)
+
+	events := []map[string]interface{}{
+		{"event_type": "play", "track_stem": "01-gathering"},
+	}
+	body, _ := json.Marshal(events)
+	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/api/albums/"+env.albumSlug+"/analytics", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for _, c := range listenerCookies {
+		req.AddCookie(c)
+	}
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("analytics request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("analytics status = %d, want 204", resp.StatusCode)
+	}
+
+	exportReq, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/admin/api/export/events?format=csv", nil)
+	for _, c := range adminCookies {
+		exportReq.AddCookie(c)
+	}
+	exportResp, err := env.ts.Client().Do(exportReq)
+	if err != nil {
+		t.Fatalf("export request: %v", err)
+	}
+	defer exportResp.Body.Close()
+
+	if exportResp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", exportResp.StatusCode)
+	}
+	if ct := exportResp.Header.Get("Content-Type"); !strings.Contains(ct, "text/csv") {
+		t.Fatalf("content-type = %q, want text/csv", ct)
+	}
+
+	data, err := io.ReadAll(exportResp.Body)
+	if err != nil {
+		t.Fatalf("read export body: %v", err)
+	}
+	bodyText := string(data)
+	if !strings.Contains(bodyText, "event_type") || !strings.Contains(bodyText, "play") {
+		t.Fatalf("unexpected csv body: %q", bodyText)
+	}
+}
+
+// --- Multi-album tests ---
+
+func TestMultiAlbumAuthReturnsAlbumList(t *testing.T) {
+	env := setupTest(t)
+
+	// Create a second album and link the same password to both
+	album2Dir := t.TempDir()
+	os.WriteFile(filepath.Join(album2Dir, "track-a.mp3"), []byte("fake"), 0644)
+
+	alb2, err := env.srv.albumStore.CreateAlbum("Second Album", "Artist 2", album2Dir)
+	if err != nil {
+		t.Fatalf("create album 2: %v", err)
+	}
+	env.srv.albumStore.SetTracks(alb2.ID, []albums.Track{
+		{Stem: "track-a", Title: "Track A"},
+	})
+
+	// Create a password that grants access to both albums
+	pw, err := env.srv.albumStore.CreatePassword("Multi", "multipass", []int64{env.albumID, alb2.ID})
+	if err != nil {
+		t.Fatalf("create multi password: %v", err)
+	}
+	_ = pw
+
+	// Auth with multi-album password
+	body, _ := json.Marshal(map[string]string{"passphrase": "multipass"})
+	resp, err := env.ts.Client().Post(env.ts.URL+"/api/auth", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("auth: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("auth status = %d, want 200", resp.StatusCode)
+	}
+
+	var authResp struct {
+		Status string `json:"status"`
+		Albums []struct {
+			Slug  string `json:"slug"`
+			Title string `json:"title"`
+		} `json:"albums"`
+	}
+	json.NewDecoder(resp.Body).Decode(&authResp)
+
+	if len(authResp.Albums) != 2 {
+		t.Fatalf("expected 2 albums, got %d", len(authResp.Albums))
+	}
+}
+
+func TestMultiAlbumAccessDeniedForUnauthorizedAlbum(t *testing.T) {
+	env := setupTest(t)
+
+	// Create a second album NOT linked to the default password
+	album2Dir := t.TempDir()
+	os.WriteFile(filepath.Join(album2Dir, "secret-track.mp3"), []byte("fake"), 0644)
+
+	alb2, err := env.srv.albumStore.CreateAlbum("Secret Album", "Secret Artist", album2Dir)
+	if err != nil {
+		t.Fatalf("create secret album: %v", err)
+	}
+	env.srv.albumStore.SetTracks(alb2.ID, []albums.Track{
+		{Stem: "secret-track", Title: "Secret Track"},
+	})
+
+	// Auth with default password (only has access to album 1)
+	cookies := env.authenticate(t)
+
+	// Try to access the secret album's tracks
+	req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+alb2.Slug+"/tracks", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("tracks request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("unauthorized album access status = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestMultiAlbumStreamFromCorrectDirectory(t *testing.T) {
+	env := setupTest(t)
+
+	// Create a second album with its own tracks
+	album2Dir := t.TempDir()
+	os.WriteFile(filepath.Join(album2Dir, "unique-track.mp3"), []byte("album2-audio-data"), 0644)
+
+	alb2, err := env.srv.albumStore.CreateAlbum("Album Two", "Artist Two", album2Dir)
+	if err != nil {
+		t.Fatalf("create album 2: %v", err)
+	}
+	env.srv.albumStore.SetTracks(alb2.ID, []albums.Track{
+		{Stem: "unique-track", Title: "Unique"},
+	})
+
+	// Create password for album 2
+	env.srv.albumStore.CreatePassword("PW2", "album2pass", []int64{alb2.ID})
+
+	// Auth with album 2 password
+	body, _ := json.Marshal(map[string]string{"passphrase": "album2pass"})
+	resp, err := env.ts.Client().Post(env.ts.URL+"/api/auth", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("auth: %v", err)
+	}
+	resp.Body.Close()
+	cookies := resp.Cookies()
+
+	// Stream from album 2
+	req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+alb2.Slug+"/stream/unique-track", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	streamResp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("stream request: %v", err)
+	}
+	defer streamResp.Body.Close()
+
+	if streamResp.StatusCode != http.StatusOK {
+		t.Fatalf("stream status = %d, want 200", streamResp.StatusCode)
+	}
+
+	streamBody, _ := io.ReadAll(streamResp.Body)
+	if string(streamBody) != "album2-audio-data" {
+		t.Fatalf("stream returned wrong data: %q", string(streamBody))
+	}
+
+	// Verify can't access album 1's tracks with album 2's password
+	req2, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/tracks", nil)
+	for _, c := range cookies {
+		req2.AddCookie(c)
+	}
+	resp2, err := env.ts.Client().Do(req2)
+	if err != nil {
+		t.Fatalf("cross-album request: %v", err)
+	}
+	resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusForbidden {
+		t.Fatalf("cross-album access status = %d, want 403", resp2.StatusCode)
+	}
+}
+
+func TestAdminAlbumCRUD(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	// List albums
+	req, _ := http.NewRequest("GET", env.ts.URL+"/admin/api/albums", nil)
+	for _, c := range adminCookies {
+		req.AddCookie(c)
+	}
+	resp, _ := env.ts.Client().Do(req)
+	var listResp struct {
+		Albums []struct {
+			ID    int64  `json:"id"`
+			Title string `json:"title"`
+		} `json:"albums"`
+	}
+	json.NewDecoder(resp.Body).Decode(&listResp)
+	resp.Body.Close()
+	if len(listResp.Albums) != 1 {
+		t.Fatalf("expected 1 album, got %d", len(listResp.Albums))
+	}
+
+	// Create a new album
+	newAlbumDir := t.TempDir()
+	createBody, _ := json.Marshal(map[string]string{
+		"title":      "New Album",
+		"artist":     "New Artist",
+		"album_path": newAlbumDir,
+	})
+	createReq, _ := http.NewRequest("POST", env.ts.URL+"/admin/api/albums", bytes.NewReader(createBody))
+	createReq.Header.Set("Content-Type", "application/json")
+	createReq.Header.Set("Origin", env.ts.URL)
+	for _, c := range adminCookies {
+		createReq.AddCookie(c)
+	}
+	createResp, _ := env.ts.Client().Do(createReq)
+	if createResp.StatusCode != http.StatusCreated {
+		t.Fatalf("create album status = %d, want 201", createResp.StatusCode)
+	}
+	var created struct {
+		ID   int64  `json:"id"`
+		Slug string `json:"slug"`
+	}
+	json.NewDecoder(createResp.Body).Decode(&created)
+	createResp.Body.Close()
+
+	if created.ID == 0 || created.Slug == "" {
+		t.Fatalf("expected created album with ID and slug, got %+v", created)
+	}
+
+	// Delete the album
+	deleteReq, _ := http.NewRequest("DELETE", env.ts.URL+"/admin/api/albums/"+strconv.FormatInt(created.ID, 10), nil)
+	deleteReq.Header.Set("Origin", env.ts.URL)
+	for _, c := range adminCookies {
+		deleteReq.AddCookie(c)
+	}
+	deleteResp, _ := env.ts.Client().Do(deleteReq)
+	deleteResp.Body.Close()
+	if deleteResp.StatusCode != http.StatusOK {
+		t.Fatalf("delete album status = %d, want 200", deleteResp.StatusCode)
+	}
+
+	// Verify it's gone
+	req2, _ := http.NewRequest("GET", env.ts.URL+"/admin/api/albums", nil)
+	for _, c := range adminCookies {
+		req2.AddCookie(c)
+	}
+	resp2, _ := env.ts.Client().Do(req2)
+	json.NewDecoder(resp2.Body).Decode(&listResp)
+	resp2.Body.Close()
+	if len(listResp.Albums) != 1 {
+		t.Fatalf("expected 1 album after delete, got %d", len(listResp.Albums))
+	}
+}
+
+func TestAdminPasswordCRUD(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	// Create a password linked to the test album
+	createBody, _ := json.Marshal(map[string]interface{}{
+		"label":      "Test PW",
+		"passphrase": "new-listener-pass",
+		"album_ids":  []int64{env.albumID},
+	})
+	createReq, _ := http.NewRequest("POST", env.ts.URL+"/admin/api/passwords", bytes.NewReader(createBody))
+	createReq.Header.Set("Content-Type", "application/json")
+	createReq.Header.Set("Origin", env.ts.URL)
+	for _, c := range adminCookies {
+		createReq.AddCookie(c)
+	}
+	createResp, _ := env.ts.Client().Do(createReq)
+	if createResp.StatusCode != http.StatusCreated {
+		t.Fatalf("create password status = %d, want 201", createResp.StatusCode)
+	}
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	json.NewDecoder(createResp.Body).Decode(&created)
+	createResp.Body.Close()
+
+	// Verify the new password works for auth
+	authBody, _ := json.Marshal(map[string]string{"passphrase": "new-listener-pass"})
+	authResp, _ := env.ts.Client().Post(env.ts.URL+"/api/auth", "application/json", bytes.NewReader(authBody))
+	if authResp.StatusCode != http.StatusOK {
+		t.Fatalf("auth with new password status = %d, want 200", authResp.StatusCode)
+	}
+	authResp.Body.Close()
+
+	// List passwords
+	listReq, _ := http.NewRequest("GET", env.ts.URL+"/admin/api/passwords", nil)
+	for _, c := range adminCookies {
+		listReq.AddCookie(c)
+	}
+	listResp, _ := env.ts.Client().Do(listReq)
+	var pwList struct {
+		Passwords []struct {
+			ID    int64  `json:"id"`
+			Label string `json:"label"`
+		} `json:"passwords"`
+	}
+	json.NewDecoder(listResp.Body).Decode(&pwList)
+	listResp.Body.Close()
+	if len(pwList.Passwords) != 2 { // Default + Test PW
+		t.Fatalf("expected 2 passwords, got %d", len(pwList.Passwords))
+	}
+
+	// Delete the new password
+	deleteReq, _ := http.NewRequest("DELETE", env.ts.URL+fmt.Sprintf("/admin/api/passwords/%d", created.ID), nil)
+	deleteReq.Header.Set("Origin", env.ts.URL)
+	for _, c := range adminCookies {
+		deleteReq.AddCookie(c)
+	}
+	deleteResp, _ := env.ts.Client().Do(deleteReq)
+	deleteResp.Body.Close()
+	if deleteResp.StatusCode != http.StatusOK {
+		t.Fatalf("delete password status = %d, want 200", deleteResp.StatusCode)
+	}
+
+	// Verify deleted password no longer works
+	authResp2, _ := env.ts.Client().Post(env.ts.URL+"/api/auth", "application/json", bytes.NewReader(authBody))
+	authResp2.Body.Close()
+	if authResp2.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("deleted password auth status = %d, want 401", authResp2.StatusCode)
+	}
+}
+
+func TestSessionWithoutPasswordIDDenied(t *testing.T) {
+	env := setupTest(t)
+
+	// Manually insert a session without password_id to simulate a legacy session
+	_, err := env.srv.db.Exec(
+		"INSERT INTO sessions (id, started_at, last_seen_at, ip_hash) VALUES (?, ?, ?, ?)",
+		"deadbeef"+strings.Repeat("00", 28), time.Now().UTC(), time.Now().UTC(), "test",
+	)
+	if err != nil {
+		t.Fatalf("insert legacy session: %v", err)
+	}
+
+	// Try to access an album with the legacy session (no password_id)
+	req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/tracks", nil)
+	req.AddCookie(&http.Cookie{Name: "acetate_session", Value: "deadbeef" + strings.Repeat("00", 28)})
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("legacy session access status = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestHeartbeatExtendsListenerSession(t *testing.T) {
+	env := setupTest(t)
+	cookies := env.authenticate(t)
+	var sessionID string
+	for _, c := range cookies {
+		if c.Name == "acetate_session" {
+			sessionID = c.Value
+		}
+	}
+
+	// Age the session to just short of expiry.
+	aged := time.Now().UTC().Add(-auth.SessionExpiry + time.Minute)
+	if _, err := env.srv.db.Exec("UPDATE sessions SET last_seen_at = ? WHERE id = ?", aged, sessionID); err != nil {
+		t.Fatalf("age session: %v", err)
+	}
+	lastSeen := func() time.Time {
+		t.Helper()
+		var ts time.Time
+		if err := env.srv.db.QueryRow("SELECT last_seen_at FROM sessions WHERE id = ?", sessionID).Scan(&ts); err != nil {
+			t.Fatalf("read last_seen_at: %v", err)
+		}
+		return ts
+	}
+	heartbeat := func(cookies []*http.Cookie) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/api/heartbeat", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("heartbeat: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := heartbeat(cookies)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("heartbeat status = %d, want 204", resp.StatusCode)
+	}
+	var refreshed *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == "acetate_session" {
+			refreshed = c
+		}
+	}
+	if refreshed == nil || refreshed.Value != sessionID || refreshed.MaxAge != int(auth.SessionExpiry.Seconds()) {
+		t.Fatalf("heartbeat cookie = %+v, want the same session re-issued", refreshed)
+	}
+	touched := lastSeen()
+	if time.Since(touched) > time.Minute {
+		t.Fatalf("last_seen_at = %v, want it moved to now", touched)
+	}
+
+	// Within SessionTouchWindow a second heartbeat does not write again.
+	if resp := heartbeat(cookies); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("second heartbeat status = %d, want 204", resp.StatusCode)
+	}
+	if again := lastSeen(); !again.Equal(touched) {
+		t.Fatalf("last_seen_at changed within touch window: %v -> %v", touched, again)
+	}
+
+	// The session is now good for another SessionExpiry.
+	if valid, _, err := env.srv.sessions.ValidateSession(sessionID); err != nil || !valid {
+		t.Fatalf("session valid = %v, %v; want valid", valid, err)
+	}
+	if resp := heartbeat(nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("heartbeat without session = %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestPublicAlbumMetadataWithoutSession(t *testing.T) {
+	env := setupTest(t)
+
+	resp, err := env.ts.Client().Get(env.ts.URL + "/api/public/" + env.albumSlug)
+	if err != nil {
+		t.Fatalf("public request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var payload map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	allowed := map[string]bool{"title": true, "artist": true, "requires_password": true, "cover_url": true}
+	for key := range payload {
+		if !allowed[key] {
+			t.Errorf("unexpected public field %q", key)
+		}
+	}
+	if payload["title"] != "Album Title" || payload["artist"] != "Test Artist" {
+		t.Fatalf("unexpected metadata: %+v", payload)
+	}
+	if payload["requires_password"] != true {
+		t.Fatalf("requires_password = %v, want true", payload["requires_password"])
+	}
+	if payload["cover_url"] != "/api/public/"+env.albumSlug+"/cover" {
+		t.Fatalf("cover_url = %v", payload["cover_url"])
+	}
+
+	coverResp, err := env.ts.Client().Get(env.ts.URL + "/api/public/" + env.albumSlug + "/cover")
+	if err != nil {
+		t.Fatalf("public cover request: %v", err)
+	}
+	coverResp.Body.Close()
+	if coverResp.StatusCode != http.StatusOK {
+		t.Fatalf("public cover status = %d, want 200", coverResp.StatusCode)
+	}
+}
+
+func TestPublicAlbumMetadataReflectsSession(t *testing.T) {
+	env := setupTest(t)
+	cookies := env.authenticate(t)
+
+	req, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/api/public/"+env.albumSlug, nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("public request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var payload struct {
+		RequiresPassword bool `json:"requires_password"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if payload.RequiresPassword {
+		t.Fatal("requires_password should be false for an unlocked session")
+	}
+
+	missing, err := env.ts.Client().Get(env.ts.URL + "/api/public/no-such-album")
+	if err != nil {
+		t.Fatalf("missing album request: %v", err)
+	}
+	missing.Body.Close()
+	if missing.StatusCode != http.StatusNotFound {
+		t.Fatalf("missing album status = %d, want 404", missing.StatusCode)
+	}
+}
+
+func TestPublicAlbumMetadataRateLimited(t *testing.T) {
+	env := setupTest(t)
+
+	var lastStatus int
+	for i := 0; i < 61; i++ {
+		resp, err := env.ts.Client().Get(env.ts.URL + "/api/public/" + env.albumSlug)
+		if err != nil {
+			t.Fatalf("public request: %v", err)
+		}
+		resp.Body.Close()
+		lastStatus = resp.StatusCode
+	}
+	if lastStatus != http.StatusTooManyRequests {
+		t.Fatalf("status after limit = %d, want 429", lastStatus)
+	}
+}
+
+func TestAdminRenumberTracks(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	resp := env.adminDo(t, adminCookies, http.MethodPost, fmt.Sprintf("/admin/api/albums/%d/tracks/renumber", env.albumID), map[string]int{
+		"width": 3,
+		"start": 9,
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	tracks, err := env.srv.albumStore.GetTracks(env.albumID)
+	if err != nil {
+		t.Fatalf("get tracks: %v", err)
+	}
+	if len(tracks) != 2 || tracks[0].DisplayIndex != "009" || tracks[1].DisplayIndex != "010" {
+		t.Fatalf("unexpected display indices: %+v", tracks)
+	}
+
+	// Defaults: start at 1, two-digit padding.
+	resp = env.adminDo(t, adminCookies, http.MethodPost, fmt.Sprintf("/admin/api/albums/%d/tracks/renumber", env.albumID), nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("default status = %d, want 200", resp.StatusCode)
+	}
+	tracks, _ = env.srv.albumStore.GetTracks(env.albumID)
+	if tracks[0].DisplayIndex != "01" || tracks[1].DisplayIndex != "02" {
+		t.Fatalf("unexpected default display indices: %+v", tracks)
+	}
+
+	resp = env.adminDo(t, adminCookies, http.MethodPost, fmt.Sprintf("/admin/api/albums/%d/tracks/renumber", env.albumID), map[string]int{"width": 12})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("oversized width status = %d, want 400", resp.StatusCode)
+	}
+
+	resp = env.adminDo(t, adminCookies, http.MethodPost, fmt.Sprintf("/admin/api/albums/%d/tracks/renumber", env.albumID), map[string]int{"padding": 3})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unknown field status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestApplyReconcileTitleModes(t *testing.T) {
+	current := []config.Track{
+		{Stem: "01-gathering", Title: "My Edited Title"},
+		{Stem: "02-hollow", Title: ""},
+		{Stem: "03-echo", Title: "Echo"}, // derived from the stem
+	}
+	disk := []config.Track{
+		{Stem: "01-gathering", Title: "Gathering (ID3)"},
+		{Stem: "02-hollow", Title: "Hollow (ID3)"},
+		{Stem: "03-echo", Title: "Echo (ID3)"},
+	}
+
+	updated, result := applyReconcile(current, disk, reconcileTitlesFillEmpty, false)
+	if updated[0].Title != "My Edited Title" || updated[1].Title != "Hollow (ID3)" || updated[2].Title != "Echo" || result.TitlesUpdated != 1 {
+		t.Fatalf("fill_empty should only fill empty titles: %+v %+v", updated, result)
+	}
+
+	updated, result = applyReconcile(current, disk, reconcileTitlesPreferManual, false)
+	if updated[0].Title != "My Edited Title" {
+		t.Fatalf("prefer_manual overwrote manual title: %q", updated[0].Title)
+	}
+	if updated[1].Title != "Hollow (ID3)" || updated[2].Title != "Echo (ID3)" || result.TitlesUpdated != 2 {
+		t.Fatalf("prefer_manual should replace empty and derived titles: %+v %+v", updated, result)
+	}
+
+	updated, result = applyReconcile(current, disk, reconcileTitlesAdopt, false)
+	if updated[0].Title != "Gathering (ID3)" || updated[1].Title != "Hollow (ID3)" || updated[2].Title != "Echo (ID3)" || result.TitlesUpdated != 3 {
+		t.Fatalf("adopt should overwrite titles: %+v %+v", updated, result)
+	}
+}
+
+func TestAdminReconcileReportsDuplicateStems(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	for _, disc := range []string{"disc1", "disc2"} {
+		os.MkdirAll(filepath.Join(env.albumDir, disc), 0755)
+		os.WriteFile(filepath.Join(env.albumDir, disc, "03-echo.mp3"), []byte("fake-mp3-data"), 0644)
+	}
+
+	resp := env.adminDo(t, adminCookies, http.MethodGet, fmt.Sprintf("/admin/api/albums/%d/reconcile", env.albumID), nil)
+	defer resp.Body.Close()
+	var payload map[string]string
+	json.NewDecoder(resp.Body).Decode(&payload)
+	if resp.StatusCode != http.StatusConflict || !strings.Contains(payload["error"], "disc1/03-echo") || !strings.Contains(payload["error"], "disc2/03-echo") {
+		t.Fatalf("reconcile preview = %d %v, want 409 naming both files", resp.StatusCode, payload)
+	}
+}
+
+func TestAdminReconcileRejectsUnknownTitleMode(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	resp := env.adminDo(t, adminCookies, http.MethodPost, fmt.Sprintf("/admin/api/albums/%d/reconcile", env.albumID), map[string]interface{}{
+		"title_mode": "overwrite_everything",
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestAlbumWatcherAddsNewFilesAndKeepsMissing(t *testing.T) {
+	env := setupTest(t)
+
+	// First poll records the folder; nothing changed, so nothing is written.
+	env.srv.albumWatchTick()
+	env.srv.albumWatchTick()
+
+	os.WriteFile(filepath.Join(env.albumDir, "03-new-song.mp3"), []byte("fake-mp3-data-3"), 0644)
+	os.Remove(filepath.Join(env.albumDir, "02-hollow.mp3"))
+
+	// The change is only applied once the folder is stable across two polls.
+	env.srv.albumWatchTick()
+	tracks, err := env.srv.albumStore.GetTracks(env.albumID)
+	if err != nil {
+		t.Fatalf("get tracks: %v", err)
+	}
+	if len(tracks) != 2 {
+		t.Fatalf("tracks after first poll = %d, want unchanged 2", len(tracks))
+	}
+
+	env.srv.albumWatchTick()
+	tracks, err = env.srv.albumStore.GetTracks(env.albumID)
+	if err != nil {
+		t.Fatalf("get tracks: %v", err)
+	}
+	stems := make([]string, 0, len(tracks))
+	for _, tr := range tracks {
+		stems = append(stems, tr.Stem)
+	}
+	if strings.Join(stems, ",") != "01-gathering,02-hollow,03-new-song" {
+		t.Fatalf("tracks after settle = %v, want new file added and missing one kept", stems)
+	}
+	if tracks[0].Title != "Gathering" || tracks[2].Title == "" {
+		t.Fatalf("titles = %q, %q", tracks[0].Title, tracks[2].Title)
+	}
+
+	// An admin removing the track is respected until the folder changes again.
+	if err := env.srv.albumStore.SetTracks(env.albumID, tracks[:1]); err != nil {
+		t.Fatalf("set tracks: %v", err)
+	}
+	env.srv.albumWatchTick()
+	if tracks, _ = env.srv.albumStore.GetTracks(env.albumID); len(tracks) != 1 {
+		t.Fatalf("watcher re-added tracks without a folder change: %d", len(tracks))
+	}
+}
+
+func TestAlbumWatcherWaitsForFileToStopChanging(t *testing.T) {
+	env := setupTest(t)
+	env.srv.albumWatchTick()
+
+	// A file still being copied keeps its stem between polls but grows.
+	path := filepath.Join(env.albumDir, "03-new-song.mp3")
+	base := time.Now().Add(-time.Hour)
+	write := func(frames int, at time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, testMP3Frames(frames), 0644); err != nil {
+			t.Fatalf("write track: %v", err)
+		}
+		os.Chtimes(path, at, at)
+	}
+	trackCount := func() int {
+		t.Helper()
+		tracks, err := env.srv.albumStore.GetTracks(env.albumID)
+		if err != nil {
+			t.Fatalf("get tracks: %v", err)
+		}
+		return len(tracks)
+	}
+
+	write(10, base)
+	env.srv.albumWatchTick()
+	for i := 1; i <= 3; i++ {
+		write(10+10*i, base.Add(time.Duration(i)*time.Second))
+		env.srv.albumWatchTick()
+		if n := trackCount(); n != 2 {
+			t.Fatalf("poll %d applied a file still being written: %d tracks", i, n)
+		}
+	}
+
+	// Once it stops changing, the next poll picks it up.
+	env.srv.albumWatchTick()
+	if n := trackCount(); n != 3 {
+		t.Fatalf("tracks after the file settled = %d, want 3", n)
+	}
+}
+
+func TestHashEmbeddedFilesChangesOnlyWithContent(t *testing.T) {
+	before, err := hashEmbeddedFiles(fstest.MapFS{
+		"js/app.js":  {Data: []byte("console.log('v1')")},
+		"css/a.css":  {Data: []byte("body{}")},
+		"index.html": {Data: []byte("<html></html>")},
+	})
+	if err != nil {
+		t.Fatalf("hashEmbeddedFiles: %v", err)
+	}
+	after, err := hashEmbeddedFiles(fstest.MapFS{
+		"js/app.js":  {Data: []byte("console.log('v2')")},
+		"css/a.css":  {Data: []byte("body{}"), ModTime: time.Now()},
+		"index.html": {Data: []byte("<html></html>")},
+	})
+	if err != nil {
+		t.Fatalf("hashEmbeddedFiles: %v", err)
+	}
+
+	if before["js/app.js"] == "" || before["js/app.js"] == after["js/app.js"] {
+		t.Fatalf("changed file etag = %q -> %q, want a new value", before["js/app.js"], after["js/app.js"])
+	}
+	if before["css/a.css"] != after["css/a.css"] || before["index.html"] != after["index.html"] {
+		t.Fatal("unchanged files should keep their etag")
+	}
+}
+
+func TestStaticAssetsUseContentETags(t *testing.T) {
+	env := setupTest(t)
+
+	resp, err := http.Get(env.ts.URL + "/js/app.js")
+	if err != nil {
+		t.Fatalf("get asset: %v", err)
+	}
+	resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d, etag = %q", resp.StatusCode, etag)
+	}
+	if etag != env.srv.staticETags["js/app.js"] {
+		t.Fatalf("etag = %q, want %q", etag, env.srv.staticETags["js/app.js"])
+	}
+	if cc := resp.Header.Get("Cache-Control"); cc != "no-cache" {
+		t.Fatalf("Cache-Control = %q, want no-cache", cc)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/js/app.js", nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("conditional get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("conditional status = %d, want 304", resp.StatusCode)
+	}
+
+	env.srv.staticMaxAge = time.Hour
+	resp, err = http.Get(env.ts.URL + "/js/app.js")
+	if err != nil {
+		t.Fatalf("get asset: %v", err)
+	}
+	resp.Body.Close()
+	if cc := resp.Header.Get("Cache-Control"); cc != "public, max-age=3600" {
+		t.Fatalf("Cache-Control with max age = %q", cc)
+	}
+}
+
+func TestEmbeddedFilesServePrecompressedVariants(t *testing.T) {
+	env := setupTest(t)
+
+	var gzBuf bytes.Buffer
+	gz := gzip.NewWriter(&gzBuf)
+	gz.Write([]byte("console.log('bundle')"))
+	gz.Close()
+	fsys := fstest.MapFS{
+		"js/app.js":    {Data: []byte("console.log('bundle')")},
+		"js/app.js.gz": {Data: gzBuf.Bytes()},
+		"js/other.js":  {Data: []byte("console.log('plain')")},
+	}
+	etags, err := hashEmbeddedFiles(fsys)
+	if err != nil {
+		t.Fatalf("hashEmbeddedFiles: %v", err)
+	}
+	env.srv.staticETags = etags
+
+	serve := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/"+path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		env.srv.serveEmbeddedFile(rec, req, fsys, "", path)
+		return rec
+	}
+
+	rec := serve("js/app.js", "br, gzip")
+	if rec.Header().Get("Content-Encoding") != "gzip" || !bytes.Equal(rec.Body.Bytes(), gzBuf.Bytes()) {
+		t.Fatalf("gzip client got encoding %q, %d bytes", rec.Header().Get("Content-Encoding"), rec.Body.Len())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/javascript") {
+		t.Fatalf("Content-Type = %q, want the original file's type", ct)
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" || rec.Header().Get("ETag") != etags["js/app.js.gz"] {
+		t.Fatalf("Vary = %q, ETag = %q", rec.Header().Get("Vary"), rec.Header().Get("ETag"))
+	}
+
+	rec = serve("js/app.js", "gzip;q=0")
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "console.log('bundle')" {
+		t.Fatalf("non-gzip client got encoding %q, body %q", rec.Header().Get("Content-Encoding"), rec.Body.String())
+	}
+	if rec.Header().Get("ETag") != etags["js/app.js"] {
+		t.Fatalf("raw ETag = %q, want %q", rec.Header().Get("ETag"), etags["js/app.js"])
+	}
+
+	rec = serve("js/other.js", "gzip")
+	if rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("Vary") != "" {
+		t.Fatalf("file without variants: encoding %q, vary %q", rec.Header().Get("Content-Encoding"), rec.Header().Get("Vary"))
+	}
+}
+
+func TestHeadLyrics(t *testing.T) {
+	env := setupTest(t)
+	cookies := env.authenticate(t)
+
+	for stem, want := range map[string]int{
+		"01-gathering": http.StatusOK,
+		"02-hollow":    http.StatusNotFound,
+		"..":           http.StatusBadRequest,
+	} {
+		req, _ := http.NewRequest(http.MethodHead, env.ts.URL+"/api/albums/"+env.albumSlug+"/lyrics/"+url.PathEscape(stem), nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("head lyrics request: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != want {
+			t.Fatalf("HEAD lyrics %s status = %d, want %d", stem, resp.StatusCode, want)
+		}
+		if len(body) != 0 {
+			t.Fatalf("HEAD lyrics %s returned body %q", stem, body)
+		}
+		if want == http.StatusOK && resp.Header.Get("X-Lyric-Format") != "lrc" {
+			t.Fatalf("X-Lyric-Format = %q, want lrc", resp.Header.Get("X-Lyric-Format"))
+		}
+	}
+}
+
+func TestAdminOpsAlbumCheck(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	if err := os.WriteFile(filepath.Join(env.albumDir, "02-hollow.mp3"), nil, 0644); err != nil {
+		t.Fatalf("truncate track: %v", err)
+	}
+
+	resp := env.adminDo(t, adminCookies, http.MethodGet, "/admin/api/ops/album-check", nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var payload struct {
+		Albums []struct {
+			FilesChecked int `json:"files_checked"`
+			Issues       []struct {
+				Path    string `json:"path"`
+				Problem string `json:"problem"`
+			} `json:"issues"`
+		} `json:"albums"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(payload.Albums) != 1 || payload.Albums[0].FilesChecked != 2 {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+
+	problems := map[string]string{}
+	for _, issue := range payload.Albums[0].Issues {
+		problems[issue.Path] = issue.Problem
+	}
+	if problems["02-hollow.mp3"] != "empty" {
+		t.Fatalf("02-hollow.mp3 problem = %q, want empty", problems["02-hollow.mp3"])
+	}
+	if problems["01-gathering.mp3"] != "no_mp3_frames" {
+		t.Fatalf("01-gathering.mp3 problem = %q, want no_mp3_frames", problems["01-gathering.mp3"])
+	}
+}
+
+func TestAdminTrackChecksum(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+	path := fmt.Sprintf("/admin/api/albums/%d/tracks/01-gathering/checksum", env.albumID)
+
+	fetch := func() string {
+		t.Helper()
+		resp := env.adminDo(t, adminCookies, http.MethodGet, path, nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("checksum status = %d, want 200", resp.StatusCode)
+		}
+		var payload struct {
+			SHA256 string `json:"sha256"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return payload.SHA256
+	}
+
+	first := fetch()
+	if len(first) != 64 {
+		t.Fatalf("unexpected checksum %q", first)
+	}
+	if second := fetch(); second != first {
+		t.Fatalf("checksum not stable: %q != %q", second, first)
+	}
+
+	trackPath := filepath.Join(env.albumDir, "01-gathering.mp3")
+	if err := os.WriteFile(trackPath, []byte("replaced-audio-bytes"), 0644); err != nil {
+		t.Fatalf("rewrite track: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(trackPath, future, future); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if changed := fetch(); changed == first {
+		t.Fatal("checksum did not change after file was replaced")
+	}
+
+	resp := env.adminDo(t, adminCookies, http.MethodGet, fmt.Sprintf("/admin/api/albums/%d/tracks/99-missing/checksum", env.albumID), nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("missing track status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestAdminTrackInfoReportsCBRDetails(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	if err := os.WriteFile(filepath.Join(env.albumDir, "01-gathering.mp3"), testMP3Frames(200), 0644); err != nil {
+		t.Fatalf("write track: %v", err)
+	}
+
+	resp := env.adminDo(t, adminCookies, http.MethodGet, fmt.Sprintf("/admin/api/albums/%d/tracks/01-gathering/info", env.albumID), nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("info status = %d, want 200", resp.StatusCode)
+	}
+	var payload struct {
+		File string         `json:"file"`
+		Info config.MP3Info `json:"info"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if payload.File != "01-gathering.mp3" || payload.Info.BitrateKbps != 128 || payload.Info.SampleRate != 44100 {
+		t.Fatalf("info = %+v", payload)
+	}
+	if payload.Info.ChannelMode != "joint_stereo" || payload.Info.VBR || payload.Info.DurationSeconds < 5.2 || payload.Info.DurationSeconds > 5.22 {
+		t.Fatalf("info = %+v", payload.Info)
+	}
+
+	// The seeded second track is not audio.
+	resp2 := env.adminDo(t, adminCookies, http.MethodGet, fmt.Sprintf("/admin/api/albums/%d/tracks/02-hollow/info", env.albumID), nil)
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("non-audio status = %d, want 422", resp2.StatusCode)
+	}
+}
+
+func TestAdminRetagTrackWritesConfiguredTitles(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	// An untagged MPEG-1 Layer III file.
+	data := testMP3Frames(10)
+	path := filepath.Join(env.albumDir, "01-gathering.mp3")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write track: %v", err)
+	}
+
+	resp := env.adminDo(t, adminCookies, http.MethodPost, fmt.Sprintf("/admin/api/albums/%d/tracks/01-gathering/retag", env.albumID), nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("retag status = %d, want 200", resp.StatusCode)
+	}
+	var payload struct {
+		Title  string `json:"title"`
+		Album  string `json:"album"`
+		Artist string `json:"artist"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if payload.Title != "Gathering" || payload.Album != "Album Title" || payload.Artist != "Test Artist" {
+		t.Fatalf("payload = %+v", payload)
+	}
+
+	if title, err := config.ReadMetadataTitle(path); err != nil || title != "Gathering" {
+		t.Fatalf("title on disk = %q, %v", title, err)
+	}
+	out, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read track: %v", err)
+	}
+	if !bytes.HasSuffix(out, data) || !bytes.Contains(out, []byte("Test Artist")) {
+		t.Fatalf("retagged file lost audio data or artist")
+	}
+
+	// The seeded second track is not audio and must be left alone.
+	resp2 := env.adminDo(t, adminCookies, http.MethodPost, fmt.Sprintf("/admin/api/albums/%d/tracks/02-hollow/retag", env.albumID), nil)
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("non-audio status = %d, want 422", resp2.StatusCode)
+	}
+	if hollow, _ := os.ReadFile(filepath.Join(env.albumDir, "02-hollow.mp3")); string(hollow) != "fake-mp3-data-2" {
+		t.Fatalf("non-audio file was modified: %q", hollow)
+	}
+}
+
+func TestWriteDeadlineAppliesToAPIHandler(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		jsonOK(w, map[string]string{"status": "ok"})
+	})
+
+	short := httptest.NewServer(requestLogger(writeDeadline(30 * time.Millisecond)(slow)))
+	defer short.Close()
+	if resp, err := short.Client().Get(short.URL); err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected write deadline to abort slow API response, got status %d", resp.StatusCode)
+	}
+
+	// A route-level deadline replaces the global one, as streaming routes do.
+	long := httptest.NewServer(requestLogger(writeDeadline(30 * time.Millisecond)(writeDeadline(5 * time.Second)(slow))))
+	defer long.Close()
+	resp, err := long.Client().Get(long.URL)
+	if err != nil {
+		t.Fatalf("long deadline request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("long deadline status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestReadyzReflectsCloudflareReadiness(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	ranges := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "173.245.48.0/20")
+	}))
+	defer ranges.Close()
+
+	check := func(env *testEnv) (int, map[string]interface{}) {
+		t.Helper()
+		resp, err := env.ts.Client().Get(env.ts.URL + "/readyz")
+		if err != nil {
+			t.Fatalf("readyz request: %v", err)
+		}
+		defer resp.Body.Close()
+		var payload map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp.StatusCode, payload
+	}
+
+	tests := []struct {
+		name       string
+		require    bool
+		rangesURL  string
+		wantStatus int
+	}{
+		{"required and not loaded", true, failing.URL, http.StatusServiceUnavailable},
+		{"required and loaded", true, ranges.URL, http.StatusOK},
+		{"optional and not loaded", false, failing.URL, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := setupTestWithConfig(t, func(cfg *Config) {
+				cfg.RequireCloudflareReady = tt.require
+				cfg.CloudflareIPURLs = []string{tt.rangesURL}
+			})
+			status, payload := check(env)
+			if status != tt.wantStatus {
+				t.Fatalf("readyz status = %d, want %d (payload %v)", status, tt.wantStatus, payload)
+			}
+			cf, _ := payload["cloudflare"].(map[string]interface{})
+			if cf["required"] != tt.require {
+				t.Fatalf("cloudflare.required = %v, want %v", cf["required"], tt.require)
+			}
+		})
+	}
+}
+
+func TestAnalyticsRejectsBodyOverConfiguredLimit(t *testing.T) {
+	env := setupTest(t)
+	cookies := env.authenticate(t)
+
+	env.srv.analyticsMaxBodyBytes = 2048
+	ts := httptest.NewServer(env.srv.routes())
+	defer ts.Close()
+
+	events := make([]map[string]interface{}, 100)
+	for i := range events {
+		events[i] = map[string]interface{}{"event_type": "play", "track_stem": "01-gathering"}
+	}
+	body, _ := json.Marshal(events)
+	if len(body) <= 2048 {
+		t.Fatalf("test body too small: %d bytes", len(body))
+	}
+
+	send := func(r io.Reader) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/albums/"+env.albumSlug+"/analytics", r)
+		req.Header.Set("Content-Type", "application/json")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("analytics request: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Declared Content-Length is rejected up front.
+	if status := send(bytes.NewReader(body)); status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("sized body status = %d, want 413", status)
+	}
+	// Chunked bodies are cut off by the route limiter before parsing.
+	if status := send(io.MultiReader(bytes.NewReader(body))); status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("chunked body status = %d, want 413", status)
+	}
+	if status := send(bytes.NewReader(body[:0:0])); status != http.StatusBadRequest {
+		t.Fatalf("empty body status = %d, want 400", status)
+	}
+}
+
+func TestAdminOpsCollectorStatsAndTune(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	decodeStats := func(resp *http.Response) analytics.CollectorStats {
+		t.Helper()
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("collector status = %d, want 200", resp.StatusCode)
+		}
+		var stats analytics.CollectorStats
+		if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return stats
+	}
+
+	stats := decodeStats(env.adminDo(t, adminCookies, http.MethodGet, "/admin/api/ops/collector", nil))
+	if stats.FlushSize != analytics.FlushSize || stats.BufferCapacity != analytics.ChannelBuffer {
+		t.Fatalf("unexpected initial stats: %+v", stats)
+	}
+	if stats.FlushIntervalMS != analytics.FlushInterval.Milliseconds() {
+		t.Fatalf("flush interval = %d, want %d", stats.FlushIntervalMS, analytics.FlushInterval.Milliseconds())
+	}
+
+	stats = decodeStats(env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/ops/collector", map[string]int{
+		"flush_size":        10,
+		"flush_interval_ms": 1000,
+	}))
+	if stats.FlushSize != 10 || stats.FlushIntervalMS != 1000 {
+		t.Fatalf("tuned stats = %+v, want flush_size 10 and 1000ms", stats)
+	}
+
+	// Invalid values are rejected without partially applying the request.
+	resp := env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/ops/collector", map[string]int{
+		"flush_size":        20,
+		"flush_interval_ms": 1,
 	})
-	selfDeactivateReq, _ := http.NewRequest(http.MethodPut, env.ts.URL+"/admin/api/admin-users/"+strconv.FormatInt(baseAdminID, 10), bytes.NewReader(selfDeactivateBody))
-	selfDeactivateReq.Header.Set("Content-Type", "application/json")
-	selfDeactivateReq.Header.Set("Origin", env.ts.URL)
-	for _, c := range adminCookies {
-		selfDeactivateReq.AddCookie(c)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid tune status = %d, want 400", resp.StatusCode)
 	}
-	selfDeactivateResp, err := env.ts.Client().Do(selfDeactivateReq)
+	if got := env.srv.collector.Stats().FlushSize; got != 10 {
+		t.Fatalf("flush size after rejected tune = %d, want 10", got)
+	}
+}
+
+func TestMaintenanceScheduleJitter(t *testing.T) {
+	interval := time.Hour
+	fixed := func(v float64) func() float64 { return func() float64 { return v } }
+
+	if d := initialMaintenanceDelay(interval, 0.1, true, fixed(0.5)); d != 0 {
+		t.Fatalf("immediate initial delay = %s, want 0", d)
+	}
+	if d := initialMaintenanceDelay(interval, 0.1, false, fixed(0.5)); d != 3*time.Minute {
+		t.Fatalf("jittered initial delay = %s, want 3m", d)
+	}
+	if d := nextMaintenanceDelay(interval, 0.1, fixed(0.5)); d != interval+3*time.Minute {
+		t.Fatalf("next delay = %s, want 1h3m", d)
+	}
+	if d := nextMaintenanceDelay(interval, 0, fixed(0.9)); d != interval {
+		t.Fatalf("unjittered next delay = %s, want 1h", d)
+	}
+
+	// Offsets stay within [0, jitter*interval).
+	for _, v := range []float64{0, 0.25, 0.999} {
+		d := nextMaintenanceDelay(interval, 0.2, fixed(v))
+		if d < interval || d >= interval+12*time.Minute {
+			t.Fatalf("next delay with rnd=%v = %s, out of range", v, d)
+		}
+	}
+}
+
+func TestWaitBeforeDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if !waitBeforeDeadline(ctx, "quick task", func() {}) {
+		t.Fatal("a task finishing in time should be reported as done")
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	if waitBeforeDeadline(ctx, "stuck task", func() { <-release }) {
+		t.Fatal("a task still running at the deadline should not be reported as done")
+	}
+	if waited := time.Since(started); waited > time.Second {
+		t.Fatalf("waited %s past a 50ms deadline", waited)
+	}
+}
+
+func TestAdminOpsMaintenanceReportsDurationAndSerializes(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	// Stop the scheduler (waiting out any run it started) so only the manual
+	// triggers below take the maintenance lock.
+	env.srv.stopMaintenanceLoop()
+
+	resp := env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/ops/maintenance", nil)
+	var payload map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("maintenance status = %d, want 200", resp.StatusCode)
+	}
+	if _, ok := payload["duration_ms"].(float64); !ok {
+		t.Fatalf("duration_ms missing from %v", payload)
+	}
+
+	// While another run holds the lock, a manual trigger must not overlap it.
+	env.srv.maintenanceMu.Lock()
+	resp = env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/ops/maintenance", nil)
+	resp.Body.Close()
+	env.srv.maintenanceMu.Unlock()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("concurrent maintenance status = %d, want 409", resp.StatusCode)
+	}
+
+	resp = env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/ops/maintenance", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("maintenance after release status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestMaintenanceDeactivatesInactiveAdmins(t *testing.T) {
+	env := setupTestWithConfig(t, func(cfg *Config) { cfg.AdminInactiveDays = 90 })
+	env.srv.stopMaintenanceLoop()
+
+	now := time.Now().UTC()
+	seed := []struct {
+		username  string
+		createdAt time.Time
+		lastLogin interface{}
+	}{
+		{"recent", now.AddDate(-1, 0, 0), now.Add(-24 * time.Hour)},
+		{"stale", now.AddDate(-1, 0, 0), now.AddDate(0, 0, -120)},
+		{"never", now.AddDate(0, 0, -200), nil},
+		{"new", now.Add(-24 * time.Hour), nil},
+	}
+	for _, u := range seed {
+		if _, err := env.srv.db.Exec("INSERT INTO admin_users (username, password_hash, created_at, last_login_at) VALUES (?, 'x', ?, ?)", u.username, u.createdAt, u.lastLogin); err != nil {
+			t.Fatalf("seed admin %s: %v", u.username, err)
+		}
+	}
+	// The original admin is idle too, but is never deactivated.
+	if _, err := env.srv.db.Exec("UPDATE admin_users SET created_at = ?, last_login_at = ? WHERE username = ?", now.AddDate(-2, 0, 0), now.AddDate(-1, 0, 0), testAdminUsername); err != nil {
+		t.Fatalf("age founder: %v", err)
+	}
+	var staleID int64
+	if err := env.srv.db.QueryRow("SELECT id FROM admin_users WHERE username = 'stale'").Scan(&staleID); err != nil {
+		t.Fatalf("query stale admin: %v", err)
+	}
+	sessionID, err := env.srv.sessions.CreateAdminSessionWithContext(staleID, "203.0.113.7", "test-agent")
 	if err != nil {
-		t.Fatalf("self deactivate request: %v", err)
+		t.Fatalf("create admin session: %v", err)
 	}
-	selfDeactivateResp.Body.Close()
-	if selfDeactivateResp.StatusCode != http.StatusBadRequest {
-		t.Fatalf("self deactivate status = %d, want 400", selfDeactivateResp.StatusCode)
+
+	report, err := env.srv.runMaintenance(now, env.srv.analyticsRetentionDays)
+	if err != nil {
+		t.Fatalf("runMaintenance: %v", err)
+	}
+	if report.AdminInactiveDays != 90 || strings.Join(report.DeactivatedAdmins, ",") != "stale,never" {
+		t.Fatalf("unexpected maintenance report: %+v", report)
 	}
 
-	// Create second admin and require password reset.
-	createBody, _ := json.Marshal(map[string]interface{}{
-		"username":               "opsadmin",
-		"password":               "ops-admin-pass-123",
-		"require_password_reset": true,
-	})
-	createReq, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/admin-users", bytes.NewReader(createBody))
-	createReq.Header.Set("Content-Type", "application/json")
-	createReq.Header.Set("Origin", env.ts.URL)
-	for _, c := range adminCookies {
-		createReq.AddCookie(c)
+	want := map[string]bool{testAdminUsername: true, "recent": true, "stale": false, "never": false, "new": true}
+	for username, wantActive := range want {
+		var isActive int
+		if err := env.srv.db.QueryRow("SELECT is_active FROM admin_users WHERE username = ?", username).Scan(&isActive); err != nil {
+			t.Fatalf("query %s: %v", username, err)
+		}
+		if (isActive == 1) != wantActive {
+			t.Fatalf("%s active = %v, want %v", username, isActive == 1, wantActive)
+		}
 	}
-	createResp, err := env.ts.Client().Do(createReq)
+	if ok, err := env.srv.sessions.ValidateAdminSession(sessionID); err != nil || ok {
+		t.Fatalf("deactivated admin session valid = %v (%v), want revoked", ok, err)
+	}
+}
+
+func TestMaintenanceKeepsLastActiveAdmin(t *testing.T) {
+	env := setupTestWithConfig(t, func(cfg *Config) { cfg.AdminInactiveDays = 30 })
+	env.srv.stopMaintenanceLoop()
+
+	// The founder is already deactivated, so only idle admins remain active.
+	now := time.Now().UTC()
+	if _, err := env.srv.db.Exec("UPDATE admin_users SET is_active = 0 WHERE username = ?", testAdminUsername); err != nil {
+		t.Fatalf("deactivate founder: %v", err)
+	}
+	for _, u := range []struct {
+		username  string
+		lastLogin time.Time
+	}{
+		{"older", now.AddDate(0, 0, -200)},
+		{"newer", now.AddDate(0, 0, -100)},
+	} {
+		if _, err := env.srv.db.Exec("INSERT INTO admin_users (username, password_hash, created_at, last_login_at) VALUES (?, 'x', ?, ?)", u.username, now.AddDate(-1, 0, 0), u.lastLogin); err != nil {
+			t.Fatalf("seed admin %s: %v", u.username, err)
+		}
+	}
+
+	deactivated, err := env.srv.deactivateInactiveAdmins(now)
 	if err != nil {
-		t.Fatalf("create admin user request: %v", err)
+		t.Fatalf("deactivateInactiveAdmins: %v", err)
 	}
-	if createResp.StatusCode != http.StatusCreated {
-		createResp.Body.Close()
-		t.Fatalf("create admin user status = %d, want 201", createResp.StatusCode)
+	if strings.Join(deactivated, ",") != "older" {
+		t.Fatalf("deactivated = %v, want [older]", deactivated)
 	}
-	var created struct {
-		ID                   int64  `json:"id"`
-		Username             string `json:"username"`
-		RequirePasswordReset bool   `json:"require_password_reset"`
+	var stillActive string
+	if err := env.srv.db.QueryRow("SELECT username FROM admin_users WHERE is_active = 1").Scan(&stillActive); err != nil {
+		t.Fatalf("query active admin: %v", err)
 	}
-	if err := json.NewDecoder(createResp.Body).Decode(&created); err != nil {
-		createResp.Body.Close()
-		t.Fatalf("decode created admin user: %v", err)
+	if stillActive != "newer" {
+		t.Fatalf("active admin = %q, want newer", stillActive)
 	}
-	createResp.Body.Close()
-	if created.ID <= 0 || created.Username != "opsadmin" || !created.RequirePasswordReset {
-		t.Fatalf("unexpected created admin user payload: %+v", created)
+
+	// A second run must not deactivate the last one either.
+	deactivated, err = env.srv.deactivateInactiveAdmins(now)
+	if err != nil {
+		t.Fatalf("deactivateInactiveAdmins: %v", err)
 	}
+	if len(deactivated) != 0 {
+		t.Fatalf("second run deactivated %v", deactivated)
+	}
+}
 
-	// New admin is forced into password-reset mode.
-	opsCookies, authPayload, status := env.authenticateAdminAs(t, "opsadmin", "ops-admin-pass-123")
-	if status != http.StatusOK {
-		t.Fatalf("ops admin login status = %d, want 200", status)
+func TestAdminDeleteTrackConfigOnly(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	resp := env.adminDo(t, adminCookies, http.MethodDelete, fmt.Sprintf("/admin/api/albums/%d/tracks/01-gathering", env.albumID), nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
 	}
-	if resetRaw, ok := authPayload["password_reset_required"].(bool); !ok || !resetRaw {
-		t.Fatalf("expected password_reset_required=true, got payload=%v", authPayload)
+
+	tracks, err := env.srv.albumStore.GetTracks(env.albumID)
+	if err != nil {
+		t.Fatalf("GetTracks: %v", err)
+	}
+	if len(tracks) != 1 || tracks[0].Stem != "02-hollow" {
+		t.Fatalf("tracks = %+v, want only 02-hollow", tracks)
+	}
+	for _, name := range []string{"01-gathering.mp3", "01-gathering.lrc"} {
+		if _, err := os.Stat(filepath.Join(env.albumDir, name)); err != nil {
+			t.Fatalf("%s should remain on disk: %v", name, err)
+		}
 	}
 
-	analyticsReq, _ := http.NewRequest(http.MethodGet, env.ts.URL+fmt.Sprintf("/admin/api/albums/%d/analytics", env.albumID), nil)
-	for _, c := range opsCookies {
-		analyticsReq.AddCookie(c)
+	resp = env.adminDo(t, adminCookies, http.MethodDelete, fmt.Sprintf("/admin/api/albums/%d/tracks/01-gathering", env.albumID), nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("repeat delete status = %d, want 404", resp.StatusCode)
 	}
-	analyticsResp, err := env.ts.Client().Do(analyticsReq)
+}
+
+func TestAdminDeleteTrackWithFiles(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	resp := env.adminDo(t, adminCookies, http.MethodDelete, fmt.Sprintf("/admin/api/albums/%d/tracks/..%%2Fsecret?delete_file=true", env.albumID), nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("traversal status = %d, want 400", resp.StatusCode)
+	}
+
+	resp = env.adminDo(t, adminCookies, http.MethodDelete, fmt.Sprintf("/admin/api/albums/%d/tracks/01-gathering?delete_file=true", env.albumID), nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	var payload struct {
+		DeletedFiles []string `json:"deleted_files"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !reflect.DeepEqual(payload.DeletedFiles, []string{"01-gathering.mp3", "01-gathering.lrc"}) {
+		t.Fatalf("deleted_files = %v", payload.DeletedFiles)
+	}
+	for _, name := range []string{"01-gathering.mp3", "01-gathering.lrc"} {
+		if _, err := os.Stat(filepath.Join(env.albumDir, name)); !os.IsNotExist(err) {
+			t.Fatalf("%s should be removed, stat err = %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(env.albumDir, "02-hollow.mp3")); err != nil {
+		t.Fatalf("other track should remain: %v", err)
+	}
+}
+
+func TestAdminImportTracksCSV(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	if err := os.WriteFile(filepath.Join(env.albumDir, "03-ember.mp3"), []byte("fake-mp3-3"), 0644); err != nil {
+		t.Fatalf("write track: %v", err)
+	}
+	manifest := "stem,title,display_index\n" +
+		"02-hollow,The Hollow,B\n" +
+		"01-gathering,\"Gathering, Pt. 1\"\n" +
+		"03-ember,Ember,C\n" +
+		"09-ghost,Ghost\n"
+
+	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+fmt.Sprintf("/admin/api/albums/%d/tracks/import", env.albumID), strings.NewReader(manifest))
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("Origin", env.ts.URL)
+	for _, c := range adminCookies {
+		req.AddCookie(c)
+	}
+	resp, err := env.ts.Client().Do(req)
 	if err != nil {
-		t.Fatalf("analytics request in reset mode: %v", err)
+		t.Fatalf("import request: %v", err)
 	}
-	analyticsResp.Body.Close()
-	if analyticsResp.StatusCode != http.StatusForbidden {
-		t.Fatalf("analytics status in reset mode = %d, want 403", analyticsResp.StatusCode)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
 	}
 
-	configReq, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/admin/api/config", nil)
-	for _, c := range opsCookies {
-		configReq.AddCookie(c)
+	var payload struct {
+		Applied   int `json:"applied"`
+		Unmatched []struct {
+			Line   int    `json:"line"`
+			Stem   string `json:"stem"`
+			Reason string `json:"reason"`
+		} `json:"unmatched"`
 	}
-	configResp, err := env.ts.Client().Do(configReq)
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if payload.Applied != 3 {
+		t.Fatalf("applied = %d, want 3", payload.Applied)
+	}
+	if len(payload.Unmatched) != 1 || payload.Unmatched[0].Stem != "09-ghost" || payload.Unmatched[0].Line != 5 || payload.Unmatched[0].Reason != "not_on_disk" {
+		t.Fatalf("unmatched = %+v", payload.Unmatched)
+	}
+
+	tracks, err := env.srv.albumStore.GetTracks(env.albumID)
 	if err != nil {
-		t.Fatalf("config request in reset mode: %v", err)
+		t.Fatalf("GetTracks: %v", err)
 	}
-	if configResp.StatusCode != http.StatusOK {
-		configResp.Body.Close()
-		t.Fatalf("config status in reset mode = %d, want 200", configResp.StatusCode)
+	got := make([]string, len(tracks))
+	for i, tr := range tracks {
+		got[i] = tr.Stem + "|" + tr.Title + "|" + tr.DisplayIndex
+	}
+	want := []string{"01-gathering|Gathering, Pt. 1|1", "02-hollow|The Hollow|B", "03-ember|Ember|C"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("tracks = %v, want %v", got, want)
+	}
+}
+
+func TestAdminRawConfigRedactsPasswords(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	resp := env.adminDo(t, adminCookies, http.MethodGet, "/admin/api/config/raw", nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+
+	passwords, err := env.srv.albumStore.ListPasswords()
+	if err != nil || len(passwords) == 0 {
+		t.Fatalf("ListPasswords: %v (%d)", err, len(passwords))
+	}
+	for _, p := range passwords {
+		if strings.Contains(string(raw), p.PasswordHash) {
+			t.Fatalf("raw config contains password hash for %q", p.Label)
+		}
+	}
+	if strings.Contains(string(raw), "password_hash") || strings.Contains(string(raw), "$2a$") {
+		t.Fatalf("raw config leaks hash material: %s", raw)
+	}
+
+	var payload struct {
+		Albums []struct {
+			ID     int64                    `json:"id"`
+			Slug   string                   `json:"slug"`
+			Tracks []map[string]interface{} `json:"tracks"`
+		} `json:"albums"`
+		Passwords []struct {
+			Label       string  `json:"label"`
+			HasPassword bool    `json:"has_password"`
+			AlbumIDs    []int64 `json:"album_ids"`
+		} `json:"passwords"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(payload.Passwords) != 1 || !payload.Passwords[0].HasPassword || len(payload.Passwords[0].AlbumIDs) != 1 {
+		t.Fatalf("passwords = %+v", payload.Passwords)
+	}
+	if len(payload.Albums) != 1 || payload.Albums[0].Slug != env.albumSlug || len(payload.Albums[0].Tracks) != 2 {
+		t.Fatalf("albums = %+v", payload.Albums)
+	}
+	for _, field := range []string{"id", "album_id", "stem", "title", "display_index", "sort_order"} {
+		if _, ok := payload.Albums[0].Tracks[0][field]; !ok {
+			t.Fatalf("track missing field %q: %v", field, payload.Albums[0].Tracks[0])
+		}
+	}
+}
+
+func TestAdminBasicAuth(t *testing.T) {
+	env := setupTest(t)
+
+	get := func(username, password string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/admin/api/config", nil)
+		req.SetBasicAuth(username, password)
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("config request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.Header.Get("WWW-Authenticate") != "" {
+			t.Fatalf("unexpected WWW-Authenticate challenge")
+		}
+		return resp.StatusCode
 	}
-	var configPayload struct {
-		PasswordResetRequired bool `json:"password_reset_required"`
+
+	if code := get(testAdminUsername, testAdminPassword); code != http.StatusUnauthorized {
+		t.Fatalf("basic auth disabled status = %d, want 401", code)
 	}
-	if err := json.NewDecoder(configResp.Body).Decode(&configPayload); err != nil {
-		configResp.Body.Close()
-		t.Fatalf("decode config payload: %v", err)
+
+	env.srv.adminBasicAuth = true
+	if code := get(testAdminUsername, "wrong-password-123"); code != http.StatusUnauthorized {
+		t.Fatalf("bad credentials status = %d, want 401", code)
 	}
-	configResp.Body.Close()
-	if !configPayload.PasswordResetRequired {
-		t.Fatal("expected password_reset_required=true in config")
+	if code := get(testAdminUsername, testAdminPassword); code != http.StatusOK {
+		t.Fatalf("basic auth status = %d, want 200", code)
 	}
 
-	updatePassBody, _ := json.Marshal(map[string]string{
-		"current_password": "ops-admin-pass-123",
-		"new_password":     "ops-admin-pass-456",
-	})
-	updatePassReq, _ := http.NewRequest(http.MethodPut, env.ts.URL+"/admin/api/admin-password", bytes.NewReader(updatePassBody))
-	updatePassReq.Header.Set("Content-Type", "application/json")
-	updatePassReq.Header.Set("Origin", env.ts.URL)
-	for _, c := range opsCookies {
-		updatePassReq.AddCookie(c)
+	var successes, failures int
+	if err := env.srv.db.QueryRow(
+		"SELECT COUNT(*) FROM admin_auth_audit WHERE reason = 'basic_auth' AND outcome = 'success'",
+	).Scan(&successes); err != nil {
+		t.Fatalf("count audit: %v", err)
 	}
-	updatePassResp, err := env.ts.Client().Do(updatePassReq)
-	if err != nil {
-		t.Fatalf("update ops admin password request: %v", err)
+	if err := env.srv.db.QueryRow(
+		"SELECT COUNT(*) FROM admin_auth_audit WHERE reason = 'basic_invalid_credentials'",
+	).Scan(&failures); err != nil {
+		t.Fatalf("count audit: %v", err)
 	}
-	updatePassResp.Body.Close()
-	if updatePassResp.StatusCode != http.StatusOK {
-		t.Fatalf("update ops admin password status = %d, want 200", updatePassResp.StatusCode)
+	if successes != 1 || failures != 1 {
+		t.Fatalf("audit successes=%d failures=%d, want 1 and 1", successes, failures)
 	}
+}
 
-	// Session is revoked after password change.
-	oldSessionConfigReq, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/admin/api/config", nil)
-	for _, c := range opsCookies {
-		oldSessionConfigReq.AddCookie(c)
+func TestAdminAPITokenLifecycle(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	resp := env.adminDo(t, adminCookies, http.MethodPost, "/admin/api/tokens", map[string]string{"label": "ci"})
+	if resp.StatusCode != http.StatusCreated {
+		resp.Body.Close()
+		t.Fatalf("mint status = %d, want 201", resp.StatusCode)
 	}
-	oldSessionConfigResp, err := env.ts.Client().Do(oldSessionConfigReq)
-	if err != nil {
-		t.Fatalf("old-session config request: %v", err)
+	var minted struct {
+		Token   string `json:"token"`
+		Details struct {
+			ID       int64  `json:"id"`
+			Username string `json:"username"`
+			Prefix   string `json:"prefix"`
+		} `json:"details"`
 	}
-	oldSessionConfigResp.Body.Close()
-	if oldSessionConfigResp.StatusCode != http.StatusUnauthorized {
-		t.Fatalf("old-session config status = %d, want 401", oldSessionConfigResp.StatusCode)
+	if err := json.NewDecoder(resp.Body).Decode(&minted); err != nil {
+		t.Fatalf("decode mint response: %v", err)
+	}
+	resp.Body.Close()
+	if !strings.HasPrefix(minted.Token, "act_") || minted.Details.Username != testAdminUsername || !strings.HasPrefix(minted.Token, minted.Details.Prefix) {
+		t.Fatalf("minted = %+v", minted)
 	}
 
-	newOpsCookies, newAuthPayload, status := env.authenticateAdminAs(t, "opsadmin", "ops-admin-pass-456")
-	if status != http.StatusOK {
-		t.Fatalf("ops admin relogin status = %d, want 200", status)
+	var stored int
+	if err := env.srv.db.QueryRow("SELECT COUNT(*) FROM admin_api_tokens WHERE token_hash = ?", minted.Token).Scan(&stored); err != nil {
+		t.Fatalf("query tokens: %v", err)
 	}
-	if resetRaw, ok := newAuthPayload["password_reset_required"].(bool); !ok || resetRaw {
-		t.Fatalf("expected password_reset_required=false, got payload=%v", newAuthPayload)
+	if stored != 0 {
+		t.Fatal("token stored in plaintext")
 	}
 
-	analyticsReq2, _ := http.NewRequest(http.MethodGet, env.ts.URL+fmt.Sprintf("/admin/api/albums/%d/analytics", env.albumID), nil)
-	for _, c := range newOpsCookies {
-		analyticsReq2.AddCookie(c)
+	withToken := func(method, path string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, env.ts.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+minted.Token)
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("token request: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
 	}
-	analyticsResp2, err := env.ts.Client().Do(analyticsReq2)
-	if err != nil {
-		t.Fatalf("analytics request after reset: %v", err)
+
+	if code := withToken(http.MethodGet, "/admin/api/config"); code != http.StatusOK {
+		t.Fatalf("token read status = %d, want 200", code)
 	}
-	analyticsResp2.Body.Close()
-	if analyticsResp2.StatusCode != http.StatusOK {
-		t.Fatalf("analytics status after reset = %d, want 200", analyticsResp2.StatusCode)
+	if code := withToken(http.MethodDelete, fmt.Sprintf("/admin/api/albums/%d/tracks/02-hollow", env.albumID)); code != http.StatusOK {
+		t.Fatalf("token mutation without Origin status = %d, want 200", code)
 	}
 
-	// Active admin can deactivate another account.
-	deactivateBody, _ := json.Marshal(map[string]interface{}{
-		"is_active": false,
-	})
-	deactivateReq, _ := http.NewRequest(http.MethodPut, env.ts.URL+"/admin/api/admin-users/"+strconv.FormatInt(created.ID, 10), bytes.NewReader(deactivateBody))
-	deactivateReq.Header.Set("Content-Type", "application/json")
-	deactivateReq.Header.Set("Origin", env.ts.URL)
-	for _, c := range adminCookies {
-		deactivateReq.AddCookie(c)
-	}
-	deactivateResp, err := env.ts.Client().Do(deactivateReq)
-	if err != nil {
-		t.Fatalf("deactivate ops admin request: %v", err)
+	var audited int
+	if err := env.srv.db.QueryRow("SELECT COUNT(*) FROM admin_auth_audit WHERE reason = 'api_token' AND outcome = 'success'").Scan(&audited); err != nil {
+		t.Fatalf("count audit: %v", err)
 	}
-	deactivateResp.Body.Close()
-	if deactivateResp.StatusCode != http.StatusOK {
-		t.Fatalf("deactivate ops admin status = %d, want 200", deactivateResp.StatusCode)
+	if audited != 2 {
+		t.Fatalf("audited token uses = %d, want 2", audited)
 	}
 
-	_, _, status = env.authenticateAdminAs(t, "opsadmin", "ops-admin-pass-456")
-	if status != http.StatusUnauthorized {
-		t.Fatalf("disabled admin login status = %d, want 401", status)
+	resp = env.adminDo(t, adminCookies, http.MethodDelete, fmt.Sprintf("/admin/api/tokens/%d", minted.Details.ID), nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("revoke status = %d, want 200", resp.StatusCode)
+	}
+	if code := withToken(http.MethodGet, "/admin/api/config"); code != http.StatusUnauthorized {
+		t.Fatalf("revoked token status = %d, want 401", code)
+	}
+	resp = env.adminDo(t, adminCookies, http.MethodDelete, fmt.Sprintf("/admin/api/tokens/%d", minted.Details.ID), nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("repeat revoke status = %d, want 404", resp.StatusCode)
 	}
 }
 
-func TestAdminConfigEndpoint(t *testing.T) {
+func TestAdminMutationRateLimit(t *testing.T) {
 	env := setupTest(t)
 	adminCookies := env.authenticateAdmin(t)
 
-	req, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/admin/api/config", nil)
-	for _, c := range adminCookies {
-		req.AddCookie(c)
+	env.srv.adminMutationLimiter.Close()
+	env.srv.adminMutationLimiter = auth.NewRateLimiterWithLimit(3, time.Minute)
+
+	path := fmt.Sprintf("/admin/api/albums/%d/tracks/renumber", env.albumID)
+	for i := 0; i < 3; i++ {
+		resp := env.adminDo(t, adminCookies, http.MethodPost, path, nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("mutation %d status = %d, want 200", i+1, resp.StatusCode)
+		}
 	}
 
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("config request: %v", err)
+	resp := env.adminDo(t, adminCookies, http.MethodPost, path, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("mutation over limit status = %d, want 429", resp.StatusCode)
 	}
-	defer resp.Body.Close()
 
+	resp = env.adminDo(t, adminCookies, http.MethodGet, fmt.Sprintf("/admin/api/albums/%d/tracks", env.albumID), nil)
+	resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("status = %d, want 200", resp.StatusCode)
+		t.Fatalf("read after limit status = %d, want 200", resp.StatusCode)
 	}
 
-	var payload map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		t.Fatalf("decode config payload: %v", err)
+	resp = env.adminDo(t, adminCookies, http.MethodDelete, "/admin/api/auth", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("logout after limit status = %d, want 200", resp.StatusCode)
 	}
+}
 
-	if _, ok := payload["password_hash"]; ok {
-		t.Fatalf("password_hash should not be exposed in config payload: %+v", payload)
+func TestAdminBodyLimitPolicy(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+
+	// Pad with insignificant whitespace so the payload itself stays valid.
+	payload := `{"tracks": [` + strings.Repeat(" ", 150<<10) +
+		`{"stem": "01-gathering", "title": "Gathering"}, {"stem": "02-hollow", "title": "Hollow"}]}`
+	put := func(baseURL string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPut, baseURL+fmt.Sprintf("/admin/api/albums/%d/tracks", env.albumID), strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Origin", baseURL)
+		for _, c := range adminCookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("update tracks request: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
 	}
 
-	albumCount, ok := payload["album_count"].(float64)
-	if !ok || albumCount < 1 {
-		t.Fatalf("expected album_count >= 1, got payload=%+v", payload)
+	if code := put(env.ts.URL); code != http.StatusBadRequest {
+		t.Fatalf("default limit status = %d, want 400", code)
+	}
+
+	env.srv.bodyLimits = BodyLimits{Tracks: 256 << 10}.withDefaults()
+	ts := httptest.NewServer(env.srv.routes())
+	defer ts.Close()
+	if code := put(ts.URL); code != http.StatusOK {
+		t.Fatalf("raised limit status = %d, want 200", code)
 	}
 }
 
-func TestAdminFounderCannotBeDeactivatedByAnotherAdmin(t *testing.T) {
+func TestAdminDeriveTitle(t *testing.T) {
 	env := setupTest(t)
 	adminCookies := env.authenticateAdmin(t)
 
-	var founderID int64
-	if err := env.srv.db.QueryRow("SELECT id FROM admin_users WHERE username = ?", testAdminUsername).Scan(&founderID); err != nil {
-		t.Fatalf("query founder admin id: %v", err)
+	// Minimal ID3v2.3 tag with a UTF-8 TIT2 frame.
+	payload := append([]byte{0x03}, []byte("Ember In The Dark")...)
+	frame := append([]byte("TIT2"), 0, 0, 0, byte(len(payload)), 0, 0)
+	frame = append(frame, payload...)
+	tagged := append([]byte{'I', 'D', '3', 0x03, 0x00, 0x00, 0, 0, 0, byte(len(frame))}, frame...)
+	if err := os.WriteFile(filepath.Join(env.albumDir, "03-ember.mp3"), append(tagged, 0, 0, 0, 0), 0644); err != nil {
+		t.Fatalf("write tagged track: %v", err)
+	}
+
+	derive := func(query string) map[string]string {
+		t.Helper()
+		resp := env.adminDo(t, adminCookies, http.MethodGet, fmt.Sprintf("/admin/api/albums/%d/derive-title?%s", env.albumID, query), nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("derive %s status = %d, want 200", query, resp.StatusCode)
+		}
+		var out map[string]string
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return out
 	}
 
-	createBody, _ := json.Marshal(map[string]interface{}{
-		"username":               "sreadmin",
-		"password":               "sre-admin-pass-123",
-		"require_password_reset": false,
-	})
-	createReq, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/admin-users", bytes.NewReader(createBody))
-	createReq.Header.Set("Content-Type", "application/json")
-	createReq.Header.Set("Origin", env.ts.URL)
-	for _, c := range adminCookies {
-		createReq.AddCookie(c)
-	}
-	createResp, err := env.ts.Client().Do(createReq)
-	if err != nil {
-		t.Fatalf("create admin user request: %v", err)
+	got := derive("file=03-ember.mp3")
+	if got["stem"] != "03-ember" || got["metadata_title"] != "Ember In The Dark" || got["derived_title"] != "Ember" || got["file"] != "03-ember.mp3" {
+		t.Fatalf("tagged = %v", got)
 	}
-	createResp.Body.Close()
-	if createResp.StatusCode != http.StatusCreated {
-		t.Fatalf("create admin status = %d, want 201", createResp.StatusCode)
+
+	got = derive("stem=02-hollow")
+	if got["metadata_title"] != "" || got["derived_title"] != "Hollow" || got["file"] != "02-hollow.mp3" {
+		t.Fatalf("untagged = %v", got)
 	}
 
-	otherCookies, _, status := env.authenticateAdminAs(t, "sreadmin", "sre-admin-pass-123")
-	if status != http.StatusOK {
-		t.Fatalf("secondary admin auth status = %d, want 200", status)
+	resp := env.adminDo(t, adminCookies, http.MethodGet, fmt.Sprintf("/admin/api/albums/%d/derive-title?stem=..%%2Fx", env.albumID), nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid stem status = %d, want 400", resp.StatusCode)
 	}
+}
 
-	deactivateFounderBody, _ := json.Marshal(map[string]interface{}{
-		"is_active": false,
+func TestAdminAlbumBranding(t *testing.T) {
+	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+	path := fmt.Sprintf("/admin/api/albums/%d", env.albumID)
+
+	resp := env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{
+		"branding": map[string]string{"accent_color": "#FF8800", "logo_url": "/branding/logo.png"},
 	})
-	deactivateFounderReq, _ := http.NewRequest(http.MethodPut, env.ts.URL+"/admin/api/admin-users/"+strconv.FormatInt(founderID, 10), bytes.NewReader(deactivateFounderBody))
-	deactivateFounderReq.Header.Set("Content-Type", "application/json")
-	deactivateFounderReq.Header.Set("Origin", env.ts.URL)
-	for _, c := range otherCookies {
-		deactivateFounderReq.AddCookie(c)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("set branding status = %d, want 200", resp.StatusCode)
 	}
 
-	deactivateFounderResp, err := env.ts.Client().Do(deactivateFounderReq)
-	if err != nil {
-		t.Fatalf("founder deactivate request: %v", err)
+	for _, color := range []string{"orange", "#ff88", "ff8800", "#gg0000"} {
+		resp = env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{
+			"branding": map[string]string{"accent_color": color},
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("accent_color %q status = %d, want 400", color, resp.StatusCode)
+		}
 	}
-	defer deactivateFounderResp.Body.Close()
-	if deactivateFounderResp.StatusCode != http.StatusBadRequest {
-		t.Fatalf("founder deactivate status = %d, want 400", deactivateFounderResp.StatusCode)
+	// Only same-origin paths pass the CSP's img-src, so external URLs are refused.
+	for _, logo := range []string{"javascript:alert(1)", "https://example.com/logo.png", "http://example.com/logo.png", "//example.com/logo.png"} {
+		resp = env.adminDo(t, adminCookies, http.MethodPut, path, map[string]interface{}{
+			"branding": map[string]string{"logo_url": logo},
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("logo_url %q status = %d, want 400", logo, resp.StatusCode)
+		}
 	}
 
-	var payload map[string]string
-	if err := json.NewDecoder(deactivateFounderResp.Body).Decode(&payload); err != nil {
-		t.Fatalf("decode founder deactivate payload: %v", err)
+	pub, err := env.ts.Client().Get(env.ts.URL + "/api/public/" + env.albumSlug)
+	if err != nil {
+		t.Fatalf("public request: %v", err)
 	}
-	if !strings.Contains(strings.ToLower(payload["error"]), "original admin") {
-		t.Fatalf("unexpected founder protection error payload: %+v", payload)
+	defer pub.Body.Close()
+	var payload struct {
+		Branding *struct {
+			AccentColor string `json:"accent_color"`
+			LogoURL     string `json:"logo_url"`
+		} `json:"branding"`
+	}
+	if err := json.NewDecoder(pub.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode public response: %v", err)
+	}
+	if payload.Branding == nil || payload.Branding.AccentColor != "#ff8800" || payload.Branding.LogoURL != "/branding/logo.png" {
+		t.Fatalf("branding = %+v", payload.Branding)
 	}
 }
 
-func TestAdminCanRenameAnotherUser(t *testing.T) {
+func TestAdminAlbumLogoLifecycle(t *testing.T) {
 	env := setupTest(t)
 	adminCookies := env.authenticateAdmin(t)
+	logoPath := fmt.Sprintf("/admin/api/albums/%d/logo", env.albumID)
+	publicLogo := env.ts.URL + "/api/public/" + env.albumSlug + "/logo"
 
-	createBody, _ := json.Marshal(map[string]interface{}{
-		"username":               "oldname",
-		"password":               "rename-pass-123",
-		"require_password_reset": false,
-	})
-	createReq, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/admin-users", bytes.NewReader(createBody))
-	createReq.Header.Set("Content-Type", "application/json")
-	createReq.Header.Set("Origin", env.ts.URL)
+	getLogo := func() *http.Response {
+		t.Helper()
+		resp, err := env.ts.Client().Get(publicLogo)
+		if err != nil {
+			t.Fatalf("logo request: %v", err)
+		}
+		return resp
+	}
+
+	resp := getLogo()
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unset logo status = %d, want 404", resp.StatusCode)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 4))
+	img.Set(1, 1, color.NRGBA{R: 255, A: 128})
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("logo", "logo.png")
+	if err != nil {
+		t.Fatalf("CreateFormFile: %v", err)
+	}
+	part.Write(pngData.Bytes())
+	writer.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+logoPath, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Origin", env.ts.URL)
 	for _, c := range adminCookies {
-		createReq.AddCookie(c)
+		req.AddCookie(c)
 	}
-	createResp, err := env.ts.Client().Do(createReq)
+	upload, err := env.ts.Client().Do(req)
 	if err != nil {
-		t.Fatalf("create admin user request: %v", err)
+		t.Fatalf("upload request: %v", err)
 	}
-	if createResp.StatusCode != http.StatusCreated {
-		createResp.Body.Close()
-		t.Fatalf("create admin status = %d, want 201", createResp.StatusCode)
+	upload.Body.Close()
+	if upload.StatusCode != http.StatusOK {
+		t.Fatalf("upload status = %d, want 200", upload.StatusCode)
 	}
-	var created struct {
-		ID int64 `json:"id"`
+
+	resp = getLogo()
+	served, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" {
+		t.Fatalf("logo status = %d content-type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
 	}
-	if err := json.NewDecoder(createResp.Body).Decode(&created); err != nil {
-		createResp.Body.Close()
-		t.Fatalf("decode created admin: %v", err)
+	decoded, err := png.Decode(bytes.NewReader(served))
+	if err != nil || decoded.Bounds().Dx() != 8 || decoded.Bounds().Dy() != 4 {
+		t.Fatalf("served logo decode err=%v", err)
 	}
-	createResp.Body.Close()
-	if created.ID <= 0 {
-		t.Fatalf("invalid created admin id: %+v", created)
+
+	pub, err := env.ts.Client().Get(env.ts.URL + "/api/public/" + env.albumSlug)
+	if err != nil {
+		t.Fatalf("public request: %v", err)
+	}
+	var info struct {
+		Branding struct {
+			LogoURL string `json:"logo_url"`
+		} `json:"branding"`
+	}
+	json.NewDecoder(pub.Body).Decode(&info)
+	pub.Body.Close()
+	if info.Branding.LogoURL != "/api/public/"+env.albumSlug+"/logo" {
+		t.Fatalf("public logo_url = %q", info.Branding.LogoURL)
 	}
 
-	renameBody, _ := json.Marshal(map[string]interface{}{
-		"username": "newname",
-	})
-	renameReq, _ := http.NewRequest(http.MethodPut, env.ts.URL+"/admin/api/admin-users/"+strconv.FormatInt(created.ID, 10), bytes.NewReader(renameBody))
-	renameReq.Header.Set("Content-Type", "application/json")
-	renameReq.Header.Set("Origin", env.ts.URL)
-	for _, c := range adminCookies {
-		renameReq.AddCookie(c)
+	del := env.adminDo(t, adminCookies, http.MethodDelete, logoPath, nil)
+	del.Body.Close()
+	if del.StatusCode != http.StatusOK {
+		t.Fatalf("delete status = %d, want 200", del.StatusCode)
+	}
+	resp = getLogo()
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("deleted logo status = %d, want 404", resp.StatusCode)
+	}
+	del = env.adminDo(t, adminCookies, http.MethodDelete, logoPath, nil)
+	del.Body.Close()
+	if del.StatusCode != http.StatusNotFound {
+		t.Fatalf("repeat delete status = %d, want 404", del.StatusCode)
+	}
+}
+
+func TestListenerFeedbackModeration(t *testing.T) {
+	env := setupTest(t)
+	cookies := env.authenticate(t)
+	adminCookies := env.authenticateAdmin(t)
+	feedbackPath := env.ts.URL + "/api/albums/" + env.albumSlug + "/feedback"
+
+	submit := func(payload map[string]string) int {
+		t.Helper()
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(http.MethodPost, feedbackPath, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("feedback request: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
 	}
 
-	renameResp, err := env.ts.Client().Do(renameReq)
-	if err != nil {
-		t.Fatalf("rename admin request: %v", err)
+	if status := submit(map[string]string{"message": "hello"}); status != http.StatusNotFound {
+		t.Fatalf("disabled feedback status = %d, want 404", status)
 	}
-	renameResp.Body.Close()
-	if renameResp.StatusCode != http.StatusOK {
-		t.Fatalf("rename admin status = %d, want 200", renameResp.StatusCode)
+
+	albumPath := fmt.Sprintf("/admin/api/albums/%d", env.albumID)
+	update := env.adminDo(t, adminCookies, http.MethodPut, albumPath, map[string]interface{}{"feedback_enabled": true})
+	update.Body.Close()
+	if update.StatusCode != http.StatusOK {
+		t.Fatalf("enable feedback status = %d, want 200", update.StatusCode)
 	}
 
-	_, _, status := env.authenticateAdminAs(t, "oldname", "rename-pass-123")
-	if status != http.StatusUnauthorized {
-		t.Fatalf("old username auth status = %d, want 401", status)
+	if status := submit(map[string]string{"message": strings.Repeat("x", maxFeedbackRunes+1)}); status != http.StatusBadRequest {
+		t.Fatalf("oversized feedback status = %d, want 400", status)
+	}
+	if status := submit(map[string]string{"message": "nice", "track_stem": "99-missing"}); status != http.StatusBadRequest {
+		t.Fatalf("unknown stem feedback status = %d, want 400", status)
+	}
+	if status := submit(map[string]string{"message": "  love\x07 this\r\nbridge  ", "track_stem": "01-gathering"}); status != http.StatusCreated {
+		t.Fatalf("feedback status = %d, want 201", status)
 	}
 
-	_, _, status = env.authenticateAdminAs(t, "newname", "rename-pass-123")
-	if status != http.StatusOK {
-		t.Fatalf("new username auth status = %d, want 200", status)
+	list := env.adminDo(t, adminCookies, http.MethodGet, albumPath+"/feedback", nil)
+	var listed struct {
+		Feedback []feedbackEntry `json:"feedback"`
+	}
+	json.NewDecoder(list.Body).Decode(&listed)
+	list.Body.Close()
+	if len(listed.Feedback) != 1 {
+		t.Fatalf("feedback entries = %d, want 1", len(listed.Feedback))
+	}
+	entry := listed.Feedback[0]
+	if entry.Message != "love this\nbridge" || entry.TrackStem != "01-gathering" {
+		t.Fatalf("feedback entry = %+v", entry)
+	}
+
+	del := env.adminDo(t, adminCookies, http.MethodDelete, fmt.Sprintf("%s/feedback/%d", albumPath, entry.ID), nil)
+	del.Body.Close()
+	if del.StatusCode != http.StatusOK {
+		t.Fatalf("delete feedback status = %d, want 200", del.StatusCode)
+	}
+	del = env.adminDo(t, adminCookies, http.MethodDelete, fmt.Sprintf("%s/feedback/%d", albumPath, entry.ID), nil)
+	del.Body.Close()
+	if del.StatusCode != http.StatusNotFound {
+		t.Fatalf("repeat delete status = %d, want 404", del.StatusCode)
 	}
 }
 
-func TestAdminAnalyticsFiltersByStem(t *testing.T) {
+func TestTrackLikesOncePerSession(t *testing.T) {
 	env := setupTest(t)
+	first := env.authenticate(t)
+	second := env.authenticate(t)
 	adminCookies := env.authenticateAdmin(t)
 
-	_, _ = env.srv.db.Exec("INSERT INTO events (session_id, event_type, track_stem, created_at) VALUES ('s1', 'play', '01-gathering', datetime('now'))")
-	_, _ = env.srv.db.Exec("INSERT INTO events (session_id, event_type, track_stem, created_at) VALUES ('s2', 'play', '02-hollow', datetime('now'))")
+	like := func(cookies []*http.Cookie, stem string) (int, map[string]interface{}) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/api/albums/"+env.albumSlug+"/tracks/"+stem+"/like", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("like request: %v", err)
+		}
+		defer resp.Body.Close()
+		var body map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
 
-	req, _ := http.NewRequest(http.MethodGet, env.ts.URL+fmt.Sprintf("/admin/api/albums/%d/analytics?stems=01-gathering", env.albumID), nil)
-	for _, c := range adminCookies {
-		req.AddCookie(c)
+	if status, _ := like(first, "01-gathering"); status != http.StatusNotFound {
+		t.Fatalf("disabled like status = %d, want 404", status)
 	}
 
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("analytics request: %v", err)
+	update := env.adminDo(t, adminCookies, http.MethodPut, fmt.Sprintf("/admin/api/albums/%d", env.albumID), map[string]interface{}{"likes_enabled": true})
+	update.Body.Close()
+	if update.StatusCode != http.StatusOK {
+		t.Fatalf("enable likes status = %d, want 200", update.StatusCode)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	status, body := like(first, "01-gathering")
+	if status != http.StatusOK || body["liked"] != true || body["likes"] != float64(1) {
+		t.Fatalf("first like status=%d body=%v", status, body)
+	}
+	status, body = like(first, "01-gathering")
+	if status != http.StatusOK || body["liked"] != false || body["likes"] != float64(1) {
+		t.Fatalf("repeat like status=%d body=%v", status, body)
+	}
+	status, body = like(second, "01-gathering")
+	if status != http.StatusOK || body["likes"] != float64(2) {
+		t.Fatalf("second session like status=%d body=%v", status, body)
+	}
+	if status, _ := like(first, "99-missing"); status != http.StatusNotFound {
+		t.Fatalf("unknown stem like status = %d, want 404", status)
 	}
 
-	var payload struct {
+	req, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/api/albums/"+env.albumSlug+"/tracks", nil)
+	for _, c := range first {
+		req.AddCookie(c)
+	}
+	resp, err := env.ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("tracks request: %v", err)
+	}
+	var listed struct {
 		Tracks []struct {
-			Stem string `json:"stem"`
+			Stem  string `json:"stem"`
+			Likes *int64 `json:"likes"`
 		} `json:"tracks"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		t.Fatalf("decode response: %v", err)
+	json.NewDecoder(resp.Body).Decode(&listed)
+	resp.Body.Close()
+
+	counts := map[string]int64{}
+	for _, track := range listed.Tracks {
+		if track.Likes == nil {
+			t.Fatalf("track %s missing likes", track.Stem)
+		}
+		counts[track.Stem] = *track.Likes
 	}
-	if len(payload.Tracks) != 1 || payload.Tracks[0].Stem != "01-gathering" {
-		t.Fatalf("unexpected tracks payload: %+v", payload.Tracks)
+	if counts["01-gathering"] != 2 || counts["02-hollow"] != 0 {
+		t.Fatalf("like counts = %v", counts)
 	}
 }
 
-func TestAdminReconcilePreview(t *testing.T) {
+func TestAnalyticsOptOutDiscardsEvents(t *testing.T) {
 	env := setupTest(t)
-	adminCookies := env.authenticateAdmin(t)
+	cookies := env.authenticate(t)
 
-	if err := os.WriteFile(filepath.Join(env.albumDir, "03-new-song.mp3"), []byte("fake"), 0644); err != nil {
-		t.Fatalf("write new song: %v", err)
+	post := func(ts *httptest.Server, header, value string) {
+		t.Helper()
+		body, _ := json.Marshal([]map[string]interface{}{{"event_type": "play", "track_stem": "01-gathering"}})
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/albums/"+env.albumSlug+"/analytics", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("analytics request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("analytics status = %d, want 204", resp.StatusCode)
+		}
+	}
+	countEvents := func() int {
+		t.Helper()
+		if err := env.srv.collector.FlushNow(context.Background()); err != nil {
+			t.Fatalf("flush: %v", err)
+		}
+		var n int
+		if err := env.srv.db.QueryRow("SELECT COUNT(*) FROM events").Scan(&n); err != nil {
+			t.Fatalf("count events: %v", err)
+		}
+		return n
 	}
 
-	req, _ := http.NewRequest(http.MethodGet, env.ts.URL+fmt.Sprintf("/admin/api/albums/%d/reconcile", env.albumID), nil)
-	for _, c := range adminCookies {
-		req.AddCookie(c)
+	// Login without an opt-out header records session_start.
+	baseline := countEvents()
+	post(env.ts, "DNT", "1")
+	post(env.ts, "Sec-GPC", "1")
+	post(env.ts, "X-Analytics-Opt-Out", "true")
+	if n := countEvents(); n != baseline {
+		t.Fatalf("events after opt-out = %d, want %d", n, baseline)
 	}
 
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("reconcile request: %v", err)
+	post(env.ts, "", "")
+	if n := countEvents(); n != baseline+1 {
+		t.Fatalf("events after normal batch = %d, want %d", n, baseline+1)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	env.srv.analyticsEnabled = false
+	disabled := httptest.NewServer(env.srv.routes())
+	defer disabled.Close()
+	post(disabled, "", "")
+	if n := countEvents(); n != baseline+1 {
+		t.Fatalf("events with analytics disabled = %d, want %d", n, baseline+1)
 	}
+}
 
-	var payload struct {
-		AlbumOnly []struct {
-			Stem string `json:"stem"`
-		} `json:"album_only"`
+func TestAggregateOnlyCountsLoginSessionsPerAlbum(t *testing.T) {
+	env := setupTestWithConfig(t, func(cfg *Config) {
+		cfg.AnalyticsAggregateOnly = true
+	})
+
+	env.authenticate(t)
+	env.authenticate(t)
+	if err := env.srv.collector.FlushNow(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		t.Fatalf("decode response: %v", err)
+
+	var untagged int
+	if err := env.srv.db.QueryRow("SELECT COALESCE(SUM(total_count), 0) FROM analytics_rollups_daily WHERE album_id = 0").Scan(&untagged); err != nil {
+		t.Fatalf("count untagged rollups: %v", err)
 	}
-	if len(payload.AlbumOnly) != 1 || payload.AlbumOnly[0].Stem != "03-new-song" {
-		t.Fatalf("unexpected album_only payload: %+v", payload.AlbumOnly)
+	if untagged != 0 {
+		t.Fatalf("untagged rollup count = %d, want 0", untagged)
+	}
+
+	admin := env.authenticateAdmin(t)
+	resp := env.adminDo(t, admin, http.MethodGet, fmt.Sprintf("/admin/api/albums/%d/analytics", env.albumID), nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("analytics status = %d", resp.StatusCode)
+	}
+	var out struct {
+		AggregateOnly bool `json:"aggregate_only"`
+		Overall       struct {
+			TotalSessions int `json:"total_sessions"`
+		} `json:"overall"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode analytics: %v", err)
+	}
+	if !out.AggregateOnly || out.Overall.TotalSessions != 2 {
+		t.Fatalf("analytics = %+v, want aggregate-only with 2 sessions", out)
 	}
 }
 
-func TestAdminOpsHealth(t *testing.T) {
+func TestAnonymousSessionsStoreNoIPData(t *testing.T) {
 	env := setupTest(t)
-	adminCookies := env.authenticateAdmin(t)
+	env.srv.sessions.Close()
+	env.srv.sessions = auth.NewSessionStoreWithOptions(env.srv.db, auth.SessionStoreOptions{Anonymous: true})
 
-	req, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/admin/api/ops/health", nil)
-	for _, c := range adminCookies {
-		req.AddCookie(c)
+	env.authenticate(t)
+	adminCookies := env.authenticateAdmin(t)
+	if _, _, status := env.authenticateAdminAs(t, testAdminUsername, "wrong-password"); status != http.StatusUnauthorized {
+		t.Fatalf("bad admin login status = %d, want 401", status)
 	}
 
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("health request: %v", err)
+	// Admin sessions still work without an IP binding.
+	resp := env.adminDo(t, adminCookies, http.MethodGet, "/admin/api/albums", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("admin request status = %d, want 200", resp.StatusCode)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	for _, query := range []string{
+		"SELECT COUNT(*) FROM sessions WHERE ip_hash IS NOT NULL",
+		"SELECT COUNT(*) FROM admin_sessions WHERE ip_hash IS NOT NULL",
+		"SELECT COUNT(*) FROM admin_auth_audit WHERE client_ip_hash IS NOT NULL",
+	} {
+		var n int
+		if err := env.srv.db.QueryRow(query).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if n != 0 {
+			t.Fatalf("%s = %d, want 0", query, n)
+		}
 	}
 
-	var payload map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		t.Fatalf("decode response: %v", err)
+	var audited int
+	if err := env.srv.db.QueryRow("SELECT COUNT(*) FROM admin_auth_audit").Scan(&audited); err != nil || audited == 0 {
+		t.Fatalf("audit rows = %d, err = %v", audited, err)
 	}
-	if payload["status"] != "ok" {
-		t.Fatalf("unexpected health status: %v", payload["status"])
+
+	sessions, err := analytics.GetSessionTimeline(env.srv.db, 10)
+	if err != nil || len(sessions) != 1 || sessions[0].IPHash != "" {
+		t.Fatalf("timeline = %+v, err = %v", sessions, err)
 	}
 }
 
-func TestAdminExportEventsCSV(t *testing.T) {
+func TestAdminExportEventsGzip(t *testing.T) {
 	env := setupTest(t)
 	adminCookies := env.authenticateAdmin(t)
-	listenerCookies := env.authenticate(t)
-
-	events := []map[string]interface{}{
-		{"event_type": "play", "track_stem": "01-gathering"},
-	}
-	body, _ := json.Marshal(events)
-	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/api/albums/"+env.albumSlug+"/analytics", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	for _, c := range listenerCookies {
-		req.AddCookie(c)
+	if _, err := env.srv.db.Exec("INSERT INTO events (session_id, event_type, track_stem, created_at) VALUES ('s1', 'play', '01-gathering', datetime('now'))"); err != nil {
+		t.Fatalf("seed event: %v", err)
 	}
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("analytics request: %v", err)
+
+	export := func(query, acceptEncoding string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/admin/api/export/events"+query, nil)
+		// Setting Accept-Encoding explicitly disables the client's transparent decompression.
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		for _, c := range adminCookies {
+			req.AddCookie(c)
+		}
+		resp, err := env.ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("export request: %v", err)
+		}
+		return resp
 	}
-	resp.Body.Close()
-	if resp.StatusCode != http.StatusNoContent {
-		t.Fatalf("analytics status = %d, want 204", resp.StatusCode)
+	gunzip := func(resp *http.Response) string {
+		t.Helper()
+		defer resp.Body.Close()
+		if resp.Header.Get("Content-Encoding") != "gzip" {
+			t.Fatalf("Content-Encoding = %q, want gzip", resp.Header.Get("Content-Encoding"))
+		}
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			t.Fatalf("gzip reader: %v", err)
+		}
+		data, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("gunzip: %v", err)
+		}
+		return string(data)
 	}
 
-	exportReq, _ := http.NewRequest(http.MethodGet, env.ts.URL+"/admin/api/export/events?format=csv", nil)
-	for _, c := range adminCookies {
-		exportReq.AddCookie(c)
-	}
-	exportResp, err := env.ts.Client().Do(exportReq)
-	if err != nil {
-		t.Fatalf("export request: %v", err)
+	csvBody := gunzip(export("?format=csv", "gzip, deflate"))
+	if !strings.HasPrefix(csvBody, "id,session_id,event_type") || !strings.Contains(csvBody, "01-gathering") {
+		t.Fatalf("unexpected gunzipped csv: %q", csvBody)
 	}
-	defer exportResp.Body.Close()
 
-	if exportResp.StatusCode != http.StatusOK {
-		t.Fatalf("status = %d, want 200", exportResp.StatusCode)
+	var events []map[string]interface{}
+	if err := json.Unmarshal([]byte(gunzip(export("?format=json&compress=true", "identity"))), &events); err != nil {
+		t.Fatalf("decode gunzipped json: %v", err)
 	}
-	if ct := exportResp.Header.Get("Content-Type"); !strings.Contains(ct, "text/csv") {
-		t.Fatalf("content-type = %q, want text/csv", ct)
+	if len(events) != 1 || events[0]["track_stem"] != "01-gathering" {
+		t.Fatalf("unexpected gunzipped json: %v", events)
 	}
 
-	data, err := io.ReadAll(exportResp.Body)
-	if err != nil {
-		t.Fatalf("read export body: %v", err)
-	}
-	bodyText := string(data)
-	if !strings.Contains(bodyText, "event_type") || !strings.Contains(bodyText, "play") {
-		t.Fatalf("unexpected csv body: %q", bodyText)
+	plain := export("?format=csv", "gzip;q=0")
+	data, _ := io.ReadAll(plain.Body)
+	plain.Body.Close()
+	if plain.Header.Get("Content-Encoding") != "" || !strings.HasPrefix(string(data), "id,") {
+		t.Fatalf("uncompressed export encoding=%q body=%q", plain.Header.Get("Content-Encoding"), data)
 	}
 }
 
-// --- Multi-album tests ---
-
-func TestMultiAlbumAuthReturnsAlbumList(t *testing.T) {
+func TestAdminExportEventsCursorPaging(t *testing.T) {
 	env := setupTest(t)
+	adminCookies := env.authenticateAdmin(t)
+	for i := 0; i < 5; i++ {
+		if _, err := env.srv.db.Exec(
+			"INSERT INTO events (session_id, event_type, track_stem, created_at) VALUES (?, 'play', '01-gathering', datetime('now'))",
+			fmt.Sprintf("s%d", i),
+		); err != nil {
+			t.Fatalf("seed event: %v", err)
+		}
+	}
 
-	// Create a second album and link the same password to both
-	album2Dir := t.TempDir()
-	os.WriteFile(filepath.Join(album2Dir, "track-a.mp3"), []byte("fake"), 0644)
+	var sessions []string
+	cursor := "0"
+	for pages := 0; cursor != ""; pages++ {
+		if pages > 5 {
+			t.Fatal("paging did not terminate")
+		}
+		resp := env.adminDo(t, adminCookies, http.MethodGet, "/admin/api/export/events?after="+cursor+"&limit=2", nil)
+		var page []struct {
+			ID        int64  `json:"id"`
+			SessionID string `json:"session_id"`
+		}
+		json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("page status = %d, want 200", resp.StatusCode)
+		}
+		for _, e := range page {
+			sessions = append(sessions, e.SessionID)
+		}
+		next := resp.Header.Get("X-Next-Cursor")
+		if next != "" && len(page) > 0 && next != strconv.FormatInt(page[len(page)-1].ID, 10) {
+			t.Fatalf("next cursor = %q, want last id %d", next, page[len(page)-1].ID)
+		}
+		cursor = next
+	}
 
-	alb2, err := env.srv.albumStore.CreateAlbum("Second Album", "Artist 2", album2Dir)
-	if err != nil {
-		t.Fatalf("create album 2: %v", err)
+	if got := strings.Join(sessions, ","); got != "s0,s1,s2,s3,s4" {
+		t.Fatalf("reassembled sessions = %q", got)
 	}
-	env.srv.albumStore.SetTracks(alb2.ID, []albums.Track{
-		{Stem: "track-a", Title: "Track A"},
-	})
 
-	// Create a password that grants access to both albums
-	pw, err := env.srv.albumStore.CreatePassword("Multi", "multipass", []int64{env.albumID, alb2.ID})
-	if err != nil {
-		t.Fatalf("create multi password: %v", err)
+	bad := env.adminDo(t, adminCookies, http.MethodGet, "/admin/api/export/events?after=-1", nil)
+	bad.Body.Close()
+	if bad.StatusCode != http.StatusBadRequest {
+		t.Fatalf("negative cursor status = %d, want 400", bad.StatusCode)
 	}
-	_ = pw
+}
 
-	// Auth with multi-album password
-	body, _ := json.Marshal(map[string]string{"passphrase": "multipass"})
-	resp, err := env.ts.Client().Post(env.ts.URL+"/api/auth", "application/json", bytes.NewReader(body))
-	if err != nil {
-		t.Fatalf("auth: %v", err)
+func TestAdminExportEventsRowCap(t *testing.T) {
+	env := setupTest(t)
+	env.srv.exportMaxRows = 3
+	adminCookies := env.authenticateAdmin(t)
+	for i := 0; i < 4; i++ {
+		if _, err := env.srv.db.Exec("INSERT INTO events (session_id, event_type, track_stem, created_at) VALUES ('s1', 'play', '01-gathering', datetime('now'))"); err != nil {
+			t.Fatalf("seed event: %v", err)
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		t.Fatalf("auth status = %d, want 200", resp.StatusCode)
+	resp := env.adminDo(t, adminCookies, http.MethodGet, "/admin/api/export/events", nil)
+	var body struct {
+		Error   string `json:"error"`
+		Total   int    `json:"total"`
+		MaxRows int    `json:"max_rows"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("over-cap export status = %d, want 413", resp.StatusCode)
+	}
+	if body.Total != 4 || body.MaxRows != 3 || !strings.Contains(body.Error, "after=0") {
+		t.Fatalf("over-cap body = %+v", body)
 	}
 
-	var authResp struct {
-		Status string `json:"status"`
-		Albums []struct {
-			Slug  string `json:"slug"`
-			Title string `json:"title"`
-		} `json:"albums"`
+	// A narrower filter or an explicit page fits under the cap.
+	resp = env.adminDo(t, adminCookies, http.MethodGet, "/admin/api/export/events?after=0", nil)
+	var page []map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(page) != 3 || resp.Header.Get("X-Next-Cursor") == "" {
+		t.Fatalf("paged export status=%d rows=%d cursor=%q", resp.StatusCode, len(page), resp.Header.Get("X-Next-Cursor"))
 	}
-	json.NewDecoder(resp.Body).Decode(&authResp)
 
-	if len(authResp.Albums) != 2 {
-		t.Fatalf("expected 2 albums, got %d", len(authResp.Albums))
+	resp = env.adminDo(t, adminCookies, http.MethodGet, "/admin/api/export/events?limit=4", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("over-cap limit status = %d, want 400", resp.StatusCode)
 	}
 }
 
-func TestMultiAlbumAccessDeniedForUnauthorizedAlbum(t *testing.T) {
+func TestAdminSessionsListAndRevoke(t *testing.T) {
 	env := setupTest(t)
-
-	// Create a second album NOT linked to the default password
-	album2Dir := t.TempDir()
-	os.WriteFile(filepath.Join(album2Dir, "secret-track.mp3"), []byte("fake"), 0644)
-
-	alb2, err := env.srv.albumStore.CreateAlbum("Secret Album", "Secret Artist", album2Dir)
-	if err != nil {
-		t.Fatalf("create secret album: %v", err)
+	first := env.authenticateAdmin(t)
+	second := env.authenticateAdmin(t)
+
+	resp := env.adminDo(t, first, http.MethodGet, "/admin/api/admin-sessions", nil)
+	var listed struct {
+		Sessions []struct {
+			ID      string `json:"id"`
+			Current bool   `json:"current"`
+			IPHash  string `json:"ip_hash"`
+		} `json:"sessions"`
+	}
+	json.NewDecoder(resp.Body).Decode(&listed)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(listed.Sessions) != 2 {
+		t.Fatalf("list status=%d sessions=%+v", resp.StatusCode, listed.Sessions)
 	}
-	env.srv.albumStore.SetTracks(alb2.ID, []albums.Track{
-		{Stem: "secret-track", Title: "Secret Track"},
-	})
 
-	// Auth with default password (only has access to album 1)
-	cookies := env.authenticate(t)
+	var other string
+	for _, sess := range listed.Sessions {
+		for _, c := range append(first, second...) {
+			if c.Name == "acetate_admin" && c.Value == sess.ID {
+				t.Fatal("listing exposed a session secret")
+			}
+		}
+		if !strings.HasSuffix(sess.IPHash, "...") {
+			t.Fatalf("ip hash not truncated: %q", sess.IPHash)
+		}
+		if !sess.Current {
+			other = sess.ID
+		}
+	}
+	if other == "" {
+		t.Fatal("expected exactly one non-current session")
+	}
 
-	// Try to access the secret album's tracks
-	req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+alb2.Slug+"/tracks", nil)
-	for _, c := range cookies {
-		req.AddCookie(c)
+	del := env.adminDo(t, first, http.MethodDelete, "/admin/api/admin-sessions/"+other, nil)
+	del.Body.Close()
+	if del.StatusCode != http.StatusOK {
+		t.Fatalf("revoke status = %d, want 200", del.StatusCode)
 	}
-	resp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("tracks request: %v", err)
+
+	resp = env.adminDo(t, second, http.MethodGet, "/admin/api/admin-sessions", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("revoked session status = %d, want 401", resp.StatusCode)
 	}
+	resp = env.adminDo(t, first, http.MethodGet, "/admin/api/admin-sessions", nil)
 	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("remaining session status = %d, want 200", resp.StatusCode)
+	}
 
-	if resp.StatusCode != http.StatusForbidden {
-		t.Fatalf("unauthorized album access status = %d, want 403", resp.StatusCode)
+	del = env.adminDo(t, first, http.MethodDelete, "/admin/api/admin-sessions/"+other, nil)
+	del.Body.Close()
+	if del.StatusCode != http.StatusNotFound {
+		t.Fatalf("repeat revoke status = %d, want 404", del.StatusCode)
 	}
 }
 
-func TestMultiAlbumStreamFromCorrectDirectory(t *testing.T) {
+func TestAdminAuthFlagsNewDevice(t *testing.T) {
 	env := setupTest(t)
 
-	// Create a second album with its own tracks
-	album2Dir := t.TempDir()
-	os.WriteFile(filepath.Join(album2Dir, "unique-track.mp3"), []byte("album2-audio-data"), 0644)
-
-	alb2, err := env.srv.albumStore.CreateAlbum("Album Two", "Artist Two", album2Dir)
-	if err != nil {
-		t.Fatalf("create album 2: %v", err)
+	_, payload, status := env.authenticateAdminAs(t, testAdminUsername, testAdminPassword)
+	if status != http.StatusOK {
+		t.Fatalf("first login status = %d", status)
+	}
+	if payload["new_device"] != true {
+		t.Fatalf("first login new_device = %v, want true", payload["new_device"])
 	}
-	env.srv.albumStore.SetTracks(alb2.ID, []albums.Track{
-		{Stem: "unique-track", Title: "Unique"},
-	})
 
-	// Create password for album 2
-	env.srv.albumStore.CreatePassword("PW2", "album2pass", []int64{alb2.ID})
+	_, payload, status = env.authenticateAdminAs(t, testAdminUsername, testAdminPassword)
+	if status != http.StatusOK {
+		t.Fatalf("second login status = %d", status)
+	}
+	if payload["new_device"] != false {
+		t.Fatalf("repeat login new_device = %v, want false", payload["new_device"])
+	}
 
-	// Auth with album 2 password
-	body, _ := json.Marshal(map[string]string{"passphrase": "album2pass"})
-	resp, err := env.ts.Client().Post(env.ts.URL+"/api/auth", "application/json", bytes.NewReader(body))
+	body, _ := json.Marshal(map[string]string{
+		"username": testAdminUsername,
+		"password": testAdminPassword,
+	})
+	req, _ := http.NewRequest(http.MethodPost, env.ts.URL+"/admin/api/auth", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", env.ts.URL)
+	req.Header.Set("User-Agent", "other-browser/1.0")
+	resp, err := env.ts.Client().Do(req)
 	if err != nil {
-		t.Fatalf("auth: %v", err)
+		t.Fatalf("admin auth request: %v", err)
 	}
-	resp.Body.Close()
-	cookies := resp.Cookies()
-
-	// Stream from album 2
-	req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+alb2.Slug+"/stream/unique-track", nil)
-	for _, c := range cookies {
-		req.AddCookie(c)
+	defer resp.Body.Close()
+	payload = map[string]interface{}{}
+	_ = json.NewDecoder(resp.Body).Decode(&payload)
+	if resp.StatusCode != http.StatusOK || payload["new_device"] != true {
+		t.Fatalf("other browser: status = %d, new_device = %v", resp.StatusCode, payload["new_device"])
 	}
-	streamResp, err := env.ts.Client().Do(req)
-	if err != nil {
-		t.Fatalf("stream request: %v", err)
+}
+
+func TestForcedPasswordResetReachableEndpoints(t *testing.T) {
+	env := setupTest(t)
+	if _, err := env.srv.db.Exec("UPDATE admin_users SET require_password_reset = 1 WHERE username = ?", testAdminUsername); err != nil {
+		t.Fatalf("flag password reset: %v", err)
 	}
-	defer streamResp.Body.Close()
+	cookies := env.authenticateAdmin(t)
 
-	if streamResp.StatusCode != http.StatusOK {
-		t.Fatalf("stream status = %d, want 200", streamResp.StatusCode)
+	cases := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodGet, "/admin/api/config", http.StatusOK},
+		{http.MethodGet, "/admin/api/admin-sessions", http.StatusOK},
+		{http.MethodDelete, "/admin/api/admin-sessions/0000000000000000", http.StatusNotFound},
+		{http.MethodGet, "/admin/api/albums", http.StatusForbidden},
+		{http.MethodGet, "/admin/api/admin-users", http.StatusForbidden},
+		{http.MethodGet, fmt.Sprintf("/admin/api/albums/%d/analytics", env.albumID), http.StatusForbidden},
+		{http.MethodDelete, "/admin/api/auth", http.StatusOK},
+	}
+	for _, tc := range cases {
+		resp := env.adminDo(t, cookies, tc.method, tc.path, nil)
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s %s during reset = %d, want %d", tc.method, tc.path, resp.StatusCode, tc.want)
+		}
 	}
+}
 
-	streamBody, _ := io.ReadAll(streamResp.Body)
-	if string(streamBody) != "album2-audio-data" {
-		t.Fatalf("stream returned wrong data: %q", string(streamBody))
+func TestAdminPasswordCheckEndpoint(t *testing.T) {
+	env := setupTest(t)
+	cookies := env.authenticateAdmin(t)
+
+	check := func(password string) (int, map[string]interface{}) {
+		t.Helper()
+		resp := env.adminDo(t, cookies, http.MethodPost, "/admin/api/password/check", map[string]string{"password": password})
+		defer resp.Body.Close()
+		payload := map[string]interface{}{}
+		_ = json.NewDecoder(resp.Body).Decode(&payload)
+		return resp.StatusCode, payload
 	}
 
-	// Verify can't access album 1's tracks with album 2's password
-	req2, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/tracks", nil)
-	for _, c := range cookies {
-		req2.AddCookie(c)
+	status, weak := check("letmein")
+	if status != http.StatusOK {
+		t.Fatalf("weak check status = %d", status)
 	}
-	resp2, err := env.ts.Client().Do(req2)
-	if err != nil {
-		t.Fatalf("cross-album request: %v", err)
+	if weak["strength"] != "very_weak" || weak["meets_policy"] != false {
+		t.Fatalf("weak check payload = %v", weak)
+	}
+	if suggestions, _ := weak["suggestions"].([]interface{}); len(suggestions) == 0 {
+		t.Fatalf("weak check suggestions = %v", weak["suggestions"])
 	}
-	resp2.Body.Close()
 
-	if resp2.StatusCode != http.StatusForbidden {
-		t.Fatalf("cross-album access status = %d, want 403", resp2.StatusCode)
+	status, strong := check("Tangerine-Orbit-42-Lantern")
+	if status != http.StatusOK {
+		t.Fatalf("strong check status = %d", status)
+	}
+	if strong["strength"] != "very_strong" || strong["meets_policy"] != true {
+		t.Fatalf("strong check payload = %v", strong)
 	}
 }
 
-func TestAdminAlbumCRUD(t *testing.T) {
+func TestStricterPasswordPolicyAppliesToNewPasswordsOnly(t *testing.T) {
 	env := setupTest(t)
-	adminCookies := env.authenticateAdmin(t)
+	env.srv.passwordPolicy = PasswordPolicy{MinLength: 12, RequireSymbol: true, RequireMixedCase: true}
+	cookies := env.authenticateAdmin(t)
 
-	// List albums
-	req, _ := http.NewRequest("GET", env.ts.URL+"/admin/api/albums", nil)
-	for _, c := range adminCookies {
-		req.AddCookie(c)
-	}
-	resp, _ := env.ts.Client().Do(req)
-	var listResp struct {
-		Albums []struct {
-			ID    int64  `json:"id"`
-			Title string `json:"title"`
-		} `json:"albums"`
-	}
-	json.NewDecoder(resp.Body).Decode(&listResp)
+	resp := env.adminDo(t, cookies, http.MethodPost, "/admin/api/admin-users", map[string]interface{}{
+		"username": "opsadmin",
+		"password": "opsadminpass123",
+	})
 	resp.Body.Close()
-	if len(listResp.Albums) != 1 {
-		t.Fatalf("expected 1 album, got %d", len(listResp.Albums))
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("create with previously valid password status = %d, want 400", resp.StatusCode)
 	}
 
-	// Create a new album
-	newAlbumDir := t.TempDir()
-	createBody, _ := json.Marshal(map[string]string{
-		"title":      "New Album",
-		"artist":     "New Artist",
-		"album_path": newAlbumDir,
+	resp = env.adminDo(t, cookies, http.MethodPost, "/admin/api/admin-users", map[string]interface{}{
+		"username": "opsadmin",
+		"password": "Ops-Admin-Pass-123",
 	})
-	createReq, _ := http.NewRequest("POST", env.ts.URL+"/admin/api/albums", bytes.NewReader(createBody))
-	createReq.Header.Set("Content-Type", "application/json")
-	createReq.Header.Set("Origin", env.ts.URL)
-	for _, c := range adminCookies {
-		createReq.AddCookie(c)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create with compliant password status = %d, want 201", resp.StatusCode)
 	}
-	createResp, _ := env.ts.Client().Do(createReq)
-	if createResp.StatusCode != http.StatusCreated {
-		t.Fatalf("create album status = %d, want 201", createResp.StatusCode)
+
+	// The existing admin's password predates the policy and still works.
+	if _, _, status := env.authenticateAdminAs(t, testAdminUsername, testAdminPassword); status != http.StatusOK {
+		t.Fatalf("existing admin login status = %d, want 200", status)
 	}
-	var created struct {
-		ID   int64  `json:"id"`
-		Slug string `json:"slug"`
+
+	resp = env.adminDo(t, cookies, http.MethodGet, "/admin/api/config", nil)
+	defer resp.Body.Close()
+	var cfg struct {
+		PasswordPolicy PasswordPolicy `json:"password_policy"`
 	}
-	json.NewDecoder(createResp.Body).Decode(&created)
-	createResp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&cfg); err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	if cfg.PasswordPolicy != env.srv.passwordPolicy {
+		t.Fatalf("config password_policy = %+v", cfg.PasswordPolicy)
+	}
+}
 
-	if created.ID == 0 || created.Slug == "" {
-		t.Fatalf("expected created album with ID and slug, got %+v", created)
+func TestBreachedPasswordCheckFailsOpen(t *testing.T) {
+	env := setupTest(t)
+	cookies := env.authenticateAdmin(t)
+
+	const breached = "summer-festival-2019"
+	sum := sha1.Sum([]byte(breached))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	mock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/range/"+digest[:5] {
+			fmt.Fprintf(w, "%s:7\r\n", digest[5:])
+		}
+	}))
+	defer mock.Close()
+	env.srv.pwnedChecker = auth.NewPwnedChecker(mock.URL + "/range/")
+
+	create := func(passphrase string) int {
+		t.Helper()
+		resp := env.adminDo(t, cookies, http.MethodPost, "/admin/api/passwords", map[string]interface{}{
+			"label":      "press",
+			"passphrase": passphrase,
+			"album_ids":  []int64{env.albumID},
+		})
+		resp.Body.Close()
+		return resp.StatusCode
 	}
 
-	// Delete the album
-	deleteReq, _ := http.NewRequest("DELETE", env.ts.URL+"/admin/api/albums/"+strconv.FormatInt(created.ID, 10), nil)
-	deleteReq.Header.Set("Origin", env.ts.URL)
-	for _, c := range adminCookies {
-		deleteReq.AddCookie(c)
+	if status := create(breached); status != http.StatusBadRequest {
+		t.Fatalf("breached album password status = %d, want 400", status)
 	}
-	deleteResp, _ := env.ts.Client().Do(deleteReq)
-	deleteResp.Body.Close()
-	if deleteResp.StatusCode != http.StatusOK {
-		t.Fatalf("delete album status = %d, want 200", deleteResp.StatusCode)
+	if status := create("quiet-harbor-lantern-88"); status != http.StatusCreated {
+		t.Fatalf("clean album password status = %d, want 201", status)
 	}
 
-	// Verify it's gone
-	req2, _ := http.NewRequest("GET", env.ts.URL+"/admin/api/albums", nil)
-	for _, c := range adminCookies {
-		req2.AddCookie(c)
+	resp := env.adminDo(t, cookies, http.MethodPost, "/admin/api/admin-users", map[string]interface{}{
+		"username": "opsadmin",
+		"password": breached,
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("breached admin password status = %d, want 400", resp.StatusCode)
 	}
-	resp2, _ := env.ts.Client().Do(req2)
-	json.NewDecoder(resp2.Body).Decode(&listResp)
-	resp2.Body.Close()
-	if len(listResp.Albums) != 1 {
-		t.Fatalf("expected 1 album after delete, got %d", len(listResp.Albums))
+
+	// An unreachable service must not block password changes.
+	mock.Close()
+	if status := create(breached); status != http.StatusCreated {
+		t.Fatalf("album password with check unavailable status = %d, want 201", status)
 	}
 }
 
-func TestAdminPasswordCRUD(t *testing.T) {
+func TestAdminOpsStatsSummarizesAuthFailures(t *testing.T) {
 	env := setupTest(t)
-	adminCookies := env.authenticateAdmin(t)
+	cookies := env.authenticateAdmin(t)
 
-	// Create a password linked to the test album
-	createBody, _ := json.Marshal(map[string]interface{}{
-		"label":      "Test PW",
-		"passphrase": "new-listener-pass",
-		"album_ids":  []int64{env.albumID},
-	})
-	createReq, _ := http.NewRequest("POST", env.ts.URL+"/admin/api/passwords", bytes.NewReader(createBody))
-	createReq.Header.Set("Content-Type", "application/json")
-	createReq.Header.Set("Origin", env.ts.URL)
-	for _, c := range adminCookies {
-		createReq.AddCookie(c)
+	seed := []struct {
+		username, outcome, age string
+	}{
+		{"root", "rejected", "-1 hours"},
+		{"root", "rejected", "-2 hours"},
+		{"admin", "rejected", "-3 hours"},
+		{"", "rejected", "-4 hours"},
+		{"admin", "success", "-1 hours"},
+		{"admin", "error", "-1 hours"},
+		{"oldname", "rejected", "-30 hours"},
+	}
+	for _, row := range seed {
+		if _, err := env.srv.db.Exec(
+			"INSERT INTO admin_auth_audit (occurred_at, attempted_username, outcome, reason) VALUES (datetime('now', ?), ?, ?, 'seed')",
+			row.age, row.username, row.outcome,
+		); err != nil {
+			t.Fatalf("seed audit row: %v", err)
+		}
 	}
-	createResp, _ := env.ts.Client().Do(createReq)
-	if createResp.StatusCode != http.StatusCreated {
-		t.Fatalf("create password status = %d, want 201", createResp.StatusCode)
+
+	resp := env.adminDo(t, cookies, http.MethodGet, "/admin/api/ops/stats", nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ops stats status = %d", resp.StatusCode)
 	}
-	var created struct {
-		ID int64 `json:"id"`
+	var payload struct {
+		AuthFailures struct {
+			WindowHours       int      `json:"window_hours"`
+			FailedAttempts    int64    `json:"failed_attempts"`
+			DistinctUsernames int64    `json:"distinct_usernames"`
+			Usernames         []string `json:"usernames"`
+		} `json:"auth_failures"`
 	}
-	json.NewDecoder(createResp.Body).Decode(&created)
-	createResp.Body.Close()
-
-	// Verify the new password works for auth
-	authBody, _ := json.Marshal(map[string]string{"passphrase": "new-listener-pass"})
-	authResp, _ := env.ts.Client().Post(env.ts.URL+"/api/auth", "application/json", bytes.NewReader(authBody))
-	if authResp.StatusCode != http.StatusOK {
-		t.Fatalf("auth with new password status = %d, want 200", authResp.StatusCode)
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode ops stats: %v", err)
 	}
-	authResp.Body.Close()
-
-	// List passwords
-	listReq, _ := http.NewRequest("GET", env.ts.URL+"/admin/api/passwords", nil)
-	for _, c := range adminCookies {
-		listReq.AddCookie(c)
+	got := payload.AuthFailures
+	if got.WindowHours != 24 || got.FailedAttempts != 4 || got.DistinctUsernames != 2 {
+		t.Fatalf("auth failure summary = %+v", got)
 	}
-	listResp, _ := env.ts.Client().Do(listReq)
-	var pwList struct {
-		Passwords []struct {
-			ID    int64  `json:"id"`
-			Label string `json:"label"`
-		} `json:"passwords"`
+	if !reflect.DeepEqual(got.Usernames, []string{"root", "admin"}) {
+		t.Fatalf("auth failure usernames = %v, want [root admin]", got.Usernames)
 	}
-	json.NewDecoder(listResp.Body).Decode(&pwList)
-	listResp.Body.Close()
-	if len(pwList.Passwords) != 2 { // Default + Test PW
-		t.Fatalf("expected 2 passwords, got %d", len(pwList.Passwords))
+}
+
+func TestAdminLockoutWebhookFiresOnThreshold(t *testing.T) {
+	env := setupTest(t)
+
+	events := make(chan lockoutEvent, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event lockoutEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("decode webhook body: %v", err)
+		}
+		events <- event
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hook.Close()
+	env.srv.lockoutWebhookURL = hook.URL
+
+	for i := 0; i < adminLoginLockThreshold-1; i++ {
+		if _, _, status := env.authenticateAdminAs(t, testAdminUsername, "wrong-password"); status != http.StatusUnauthorized {
+			t.Fatalf("attempt %d status = %d, want 401", i+1, status)
+		}
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("webhook fired before the threshold: %+v", event)
+	case <-time.After(100 * time.Millisecond):
 	}
 
-	// Delete the new password
-	deleteReq, _ := http.NewRequest("DELETE", env.ts.URL+fmt.Sprintf("/admin/api/passwords/%d", created.ID), nil)
-	deleteReq.Header.Set("Origin", env.ts.URL)
-	for _, c := range adminCookies {
-		deleteReq.AddCookie(c)
+	if _, _, status := env.authenticateAdminAs(t, testAdminUsername, "wrong-password"); status != http.StatusUnauthorized {
+		t.Fatalf("threshold attempt status = %d, want 401", status)
 	}
-	deleteResp, _ := env.ts.Client().Do(deleteReq)
-	deleteResp.Body.Close()
-	if deleteResp.StatusCode != http.StatusOK {
-		t.Fatalf("delete password status = %d, want 200", deleteResp.StatusCode)
+	select {
+	case event := <-events:
+		if event.Event != "admin_lockout" || event.Source != "login" || event.Username != testAdminUsername {
+			t.Fatalf("unexpected lockout event: %+v", event)
+		}
+		if event.Failures != adminLoginLockThreshold || event.DurationSeconds != int(adminLoginBackoffBase/time.Second) {
+			t.Fatalf("lockout event failures/duration = %d/%d", event.Failures, event.DurationSeconds)
+		}
+		if !strings.HasPrefix(event.Key, testAdminUsername+"|") || event.ClientIP == "" {
+			t.Fatalf("lockout event key/ip = %q/%q", event.Key, event.ClientIP)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lockout webhook did not fire")
 	}
 
-	// Verify deleted password no longer works
-	authResp2, _ := env.ts.Client().Post(env.ts.URL+"/api/auth", "application/json", bytes.NewReader(authBody))
-	authResp2.Body.Close()
-	if authResp2.StatusCode != http.StatusUnauthorized {
-		t.Fatalf("deleted password auth status = %d, want 401", authResp2.StatusCode)
+	// Requests rejected while locked do not re-notify.
+	if _, _, status := env.authenticateAdminAs(t, testAdminUsername, "wrong-password"); status != http.StatusTooManyRequests {
+		t.Fatalf("locked attempt status = %d, want 429", status)
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("webhook fired again while locked: %+v", event)
+	case <-time.After(100 * time.Millisecond):
 	}
 }
 
-func TestSessionWithoutPasswordIDDenied(t *testing.T) {
+func TestFindMissingTracksReportsConfiguredStemsWithoutFiles(t *testing.T) {
 	env := setupTest(t)
+	store := env.srv.albumStore
 
-	// Manually insert a session without password_id to simulate a legacy session
-	_, err := env.srv.db.Exec(
-		"INSERT INTO sessions (id, started_at, last_seen_at, ip_hash) VALUES (?, ?, ?, ?)",
-		"deadbeef"+strings.Repeat("00", 28), time.Now().UTC(), time.Now().UTC(), "test",
-	)
+	missing, err := FindMissingTracks(store, nil)
 	if err != nil {
-		t.Fatalf("insert legacy session: %v", err)
+		t.Fatalf("FindMissingTracks: %v", err)
+	}
+	if len(missing) != 0 {
+		t.Fatalf("complete album reported missing tracks: %+v", missing)
 	}
 
-	// Try to access an album with the legacy session (no password_id)
-	req, _ := http.NewRequest("GET", env.ts.URL+"/api/albums/"+env.albumSlug+"/tracks", nil)
-	req.AddCookie(&http.Cookie{Name: "acetate_session", Value: "deadbeef" + strings.Repeat("00", 28)})
-	resp, err := env.ts.Client().Do(req)
+	if err := store.SetTracks(env.albumID, []albums.Track{
+		{Stem: "01-gathering", Title: "Gathering"},
+		{Stem: "02-hollow", Title: "Hollow"},
+		{Stem: "03-absent", Title: "Absent"},
+	}); err != nil {
+		t.Fatalf("set tracks: %v", err)
+	}
+	gone, err := store.CreateAlbum("Gone", "Artist", filepath.Join(env.albumDir, "does-not-exist"))
 	if err != nil {
-		t.Fatalf("request: %v", err)
+		t.Fatalf("create album: %v", err)
+	}
+	if err := store.SetTracks(gone.ID, []albums.Track{{Stem: "01-ghost", Title: "Ghost"}}); err != nil {
+		t.Fatalf("set tracks: %v", err)
 	}
-	resp.Body.Close()
 
-	if resp.StatusCode != http.StatusForbidden {
-		t.Fatalf("legacy session access status = %d, want 403", resp.StatusCode)
+	missing, err = FindMissingTracks(store, nil)
+	if err != nil {
+		t.Fatalf("FindMissingTracks: %v", err)
+	}
+	want := []MissingTracks{
+		{AlbumID: env.albumID, Slug: env.albumSlug, Stems: []string{"03-absent"}},
+		{AlbumID: gone.ID, Slug: gone.Slug, Stems: []string{"01-ghost"}},
+	}
+	if !reflect.DeepEqual(missing, want) {
+		t.Fatalf("missing tracks = %+v, want %+v", missing, want)
 	}
 }
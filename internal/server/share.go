@@ -0,0 +1,351 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"acetate/internal/album"
+)
+
+// Share link lifetimes. Listeners may ask for a shorter or longer link, but
+// never beyond the configured maximum.
+const (
+	DefaultShareTTL    = 24 * time.Hour
+	DefaultShareMaxTTL = 7 * 24 * time.Hour
+	minShareTTL        = time.Minute
+)
+
+// Share link limits per listener session: links minted per minute, and
+// links that may be unrevoked and unexpired at once.
+const (
+	shareRateLimit        = 10
+	maxActiveSharesPerSID = 20
+)
+
+// shareKeyFile holds the generated signing key when SHARE_SIGNING_KEY is not
+// set, so share links survive restarts.
+const shareKeyFile = "share-signing.key"
+
+// loadShareKey returns the configured signing key, or reads (creating on
+// first use) a random key under dataPath. Without a data path the key only
+// lives for this process.
+func loadShareKey(configured, dataPath string) ([]byte, error) {
+	if configured = strings.TrimSpace(configured); configured != "" {
+		return []byte(configured), nil
+	}
+	if dataPath == "" {
+		return randomShareKey()
+	}
+
+	path := filepath.Join(dataPath, shareKeyFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) < 32 {
+			return nil, fmt.Errorf("invalid share signing key in %s", path)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	key, err := randomShareKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("write share signing key: %w", err)
+	}
+	return key, nil
+}
+
+func randomShareKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// shareSignature signs share id for one track stream in one album until exp
+// (unix seconds).
+func (s *Server) shareSignature(id, slug, stem string, exp int64) string {
+	mac := hmac.New(sha256.New, s.shareKey)
+	fmt.Fprintf(mac, "stream\x00%s\x00%s\x00%s\x00%d", id, slug, stem, exp)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyShare checks a signed stream request. Links past their expiry, or
+// expiring further out than the current maximum lifetime allows, are
+// rejected along with bad signatures. Revocation is checked separately.
+func (s *Server) verifyShare(id, slug, stem, rawExp, sig string, now time.Time) bool {
+	exp, err := strconv.ParseInt(rawExp, 10, 64)
+	if err != nil || id == "" || sig == "" {
+		return false
+	}
+	if now.Unix() > exp || exp > now.Add(s.shareMaxTTL).Unix() {
+		return false
+	}
+	want := s.shareSignature(id, slug, stem, exp)
+	return hmac.Equal([]byte(sig), []byte(want))
+}
+
+// shareLinkActive reports whether share id was issued for this album and
+// track and has not been revoked, along with the session that minted it.
+func (s *Server) shareLinkActive(id string, albumID int64, stem string) (owner string, active bool, err error) {
+	var revoked bool
+	err = s.db.QueryRow(
+		"SELECT session_id, revoked_at IS NOT NULL FROM share_links WHERE id = ? AND album_id = ? AND stem = ?",
+		id, albumID, stem,
+	).Scan(&owner, &revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return owner, !revoked, nil
+}
+
+func newShareID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// handleShareTrack returns a signed, expiring stream URL for one track. An
+// optional {"ttl_seconds": n} is clamped to [1 minute, SHARE_MAX_TTL]. Each
+// session is rate limited and may hold at most maxActiveSharesPerSID live
+// links.
+func (s *Server) handleShareTrack(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TTLSeconds int64 `json:"ttl_seconds"`
+	}
+	if r.ContentLength != 0 {
+		if err := s.decodeCompatJSONBody(r, &req); err != nil {
+			jsonError(w, "bad request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	stem, err := normalizeStemParam(chi.URLParam(r, "stem"))
+	if err != nil || !album.ValidateStem(stem) {
+		jsonError(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	alb := albumFromContext(r)
+	tracks, err := s.albumStore.GetTracks(alb.ID)
+	if err != nil {
+		log.Printf("share tracks error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if _, ok := album.FindTrack(stem, tracks); !ok {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	sessionID := s.getSessionID(r)
+	if !s.shareLimiter.Allow("share:" + sessionID) {
+		jsonError(w, "rate limited", http.StatusTooManyRequests)
+		return
+	}
+	// Minting a link counts toward scan detection like streaming the track,
+	// so a session cannot pull an album through share links unnoticed.
+	if !s.checkScan(w, r, sessionID, alb.ID, stem) {
+		return
+	}
+
+	ttl := DefaultShareTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	ttl = max(minShareTTL, min(ttl, s.shareMaxTTL))
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+
+	id, err := newShareID()
+	if err != nil {
+		log.Printf("share id error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	// Counting live links and inserting is one statement, so concurrent
+	// requests from one session cannot both take the last slot.
+	now := time.Now()
+	res, err := s.db.Exec(
+		`INSERT INTO share_links (id, album_id, stem, session_id, created_at, expires_at)
+		SELECT ?, ?, ?, ?, ?, ?
+		WHERE (SELECT COUNT(*) FROM share_links WHERE session_id = ? AND revoked_at IS NULL AND expires_at >= ?) < ?`,
+		id, alb.ID, stem, sessionID, now.UTC(), expires.Unix(),
+		sessionID, now.Unix(), maxActiveSharesPerSID,
+	)
+	if err != nil {
+		log.Printf("insert share error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		jsonError(w, "too many active share links", http.StatusTooManyRequests)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("sid", id)
+	q.Set("exp", strconv.FormatInt(expires.Unix(), 10))
+	q.Set("sig", s.shareSignature(id, alb.Slug, stem, expires.Unix()))
+	shareURL := "/api/albums/" + url.PathEscape(alb.Slug) + "/stream/" + url.PathEscape(stem) + "?" + q.Encode()
+
+	jsonCreated(w, map[string]string{
+		"id":         id,
+		"url":        shareURL,
+		"expires_at": expires.UTC().Format(time.RFC3339),
+	})
+}
+
+// streamAccess admits a stream request that carries a valid share signature
+// without a session; everything else goes through the usual session and
+// album access checks.
+func (s *Server) streamAccess(next http.Handler) http.Handler {
+	sessionGated := s.requireSession(s.requireAlbumAccess(next))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if !q.Has("sig") {
+			sessionGated.ServeHTTP(w, r)
+			return
+		}
+
+		slug := chi.URLParam(r, "slug")
+		stem, err := normalizeStemParam(chi.URLParam(r, "stem"))
+		id := q.Get("sid")
+		if err != nil || !s.verifyShare(id, slug, stem, q.Get("exp"), q.Get("sig"), time.Now()) {
+			jsonError(w, "invalid or expired link", http.StatusForbidden)
+			return
+		}
+		alb, err := s.albumStore.GetAlbumBySlug(slug)
+		if err != nil {
+			log.Printf("share album lookup error: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if alb == nil {
+			jsonError(w, "not found", http.StatusNotFound)
+			return
+		}
+		owner, active, err := s.shareLinkActive(id, alb.ID, stem)
+		if err != nil {
+			log.Printf("share lookup error: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			jsonError(w, "invalid or expired link", http.StatusForbidden)
+			return
+		}
+		// Streams through the link are charged to the minting session for
+		// scan detection; links from before owners were recorded use the
+		// link itself.
+		scanKey := owner
+		if scanKey == "" {
+			scanKey = "share:" + id
+		}
+		ctx := context.WithValue(r.Context(), requestAlbumKey, alb)
+		ctx = context.WithValue(ctx, shareScanKey, scanKey)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// handleAdminListShares lists share links that are neither revoked nor
+// expired, newest first.
+func (s *Server) handleAdminListShares(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.db.Query(`
+		SELECT l.id, l.album_id, a.slug, l.stem, l.created_at, l.expires_at
+		FROM share_links l JOIN albums a ON a.id = l.album_id
+		WHERE l.revoked_at IS NULL AND l.expires_at >= ?
+		ORDER BY l.created_at DESC, l.id`,
+		time.Now().Unix(),
+	)
+	if err != nil {
+		log.Printf("list shares error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	type shareResp struct {
+		ID        string    `json:"id"`
+		AlbumID   int64     `json:"album_id"`
+		AlbumSlug string    `json:"album_slug"`
+		Stem      string    `json:"stem"`
+		CreatedAt time.Time `json:"created_at"`
+		ExpiresAt string    `json:"expires_at"`
+	}
+	shares := []shareResp{}
+	for rows.Next() {
+		var sh shareResp
+		var expires int64
+		if err := rows.Scan(&sh.ID, &sh.AlbumID, &sh.AlbumSlug, &sh.Stem, &sh.CreatedAt, &expires); err != nil {
+			log.Printf("scan share error: %v", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		sh.ExpiresAt = time.Unix(expires, 0).UTC().Format(time.RFC3339)
+		shares = append(shares, sh)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("list shares error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonOK(w, map[string]interface{}{"shares": shares})
+}
+
+// handleAdminRevokeShare kills a share link. Revoking twice is a no-op.
+func (s *Server) handleAdminRevokeShare(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	res, err := s.db.Exec(
+		"UPDATE share_links SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?",
+		time.Now().UTC(), id,
+	)
+	if err != nil {
+		log.Printf("revoke share error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		jsonError(w, "not found", http.StatusNotFound)
+		return
+	}
+	jsonOK(w, map[string]string{"status": "ok"})
+}
+
+// pruneExpiredShares deletes share links that expired before now; they can
+// no longer be used whether or not they were revoked.
+func (s *Server) pruneExpiredShares(now time.Time) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM share_links WHERE expires_at < ?", now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
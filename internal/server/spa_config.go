@@ -0,0 +1,84 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// spaRuntimeConfig is injected into index.html so the SPA can discover API
+// paths and feature flags without an extra request. Everything here is
+// public: no paths on disk, IDs, session or password data.
+type spaRuntimeConfig struct {
+	APIBase    string               `json:"api_base"`
+	PublicBase string               `json:"public_base"`
+	Features   spaFeatures          `json:"features"`
+	Album      *publicAlbumResponse `json:"album,omitempty"`
+}
+
+type spaFeatures struct {
+	Analytics bool `json:"analytics"`
+}
+
+// spaRuntimeConfigFor builds the runtime config for a request. An ?album=
+// query naming an existing album adds its public metadata. The lookup counts
+// against the public metadata rate limit; once that is exhausted the page is
+// still served, just without the album, and the SPA fetches it itself.
+func (s *Server) spaRuntimeConfigFor(r *http.Request) spaRuntimeConfig {
+	cfg := spaRuntimeConfig{
+		APIBase:    "/api",
+		PublicBase: "/api/public",
+		Features:   spaFeatures{Analytics: s.analyticsAllowed(r)},
+	}
+	slug := strings.TrimSpace(r.URL.Query().Get("album"))
+	if slug != "" && s.publicLimiter.Allow("public:"+s.cfIPs.GetClientIP(r)) {
+		alb, err := s.albumStore.GetAlbumBySlug(slug)
+		if err != nil {
+			log.Printf("spa config album lookup error: %v", err)
+		} else if alb != nil {
+			info := s.publicAlbumInfo(r, alb)
+			cfg.Album = &info
+		}
+	}
+	return cfg
+}
+
+// serveSPAIndex serves index.html with the runtime config injected before
+// </head> as an inline script carrying the request's CSP nonce. Without a
+// nonce nothing is injected and the SPA falls back to its defaults.
+// encoding/json escapes <, >, & and the JS line separators, so values cannot
+// close the script element or break the assignment.
+func (s *Server) serveSPAIndex(w http.ResponseWriter, r *http.Request, fsys fs.FS) {
+	data, err := fs.ReadFile(fsys, "index.html")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	payload, err := json.Marshal(s.spaRuntimeConfigFor(r))
+	if err != nil {
+		log.Printf("spa config encode error: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	var block bytes.Buffer
+	if nonce := cspNonceFromContext(r); nonce != "" {
+		block.WriteString(`<script nonce="` + nonce + `">window.__ACETATE__ = `)
+		block.Write(payload)
+		block.WriteString(";</script>\n")
+	}
+
+	if i := bytes.Index(data, []byte("</head>")); i >= 0 && block.Len() > 0 {
+		out := make([]byte, 0, len(data)+block.Len())
+		out = append(out, data[:i]...)
+		out = append(out, block.Bytes()...)
+		data = append(out, data[i:]...)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.ServeContent(w, r, "index.html", time.Time{}, bytes.NewReader(data))
+}
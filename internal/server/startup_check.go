@@ -0,0 +1,57 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"acetate/internal/albums"
+	"acetate/internal/config"
+)
+
+// MissingTracks lists an album's configured tracks that have no audio file
+// on disk, as the reconcile preview's config_only set would report them.
+type MissingTracks struct {
+	AlbumID int64
+	Slug    string
+	Stems   []string
+}
+
+// FindMissingTracks checks every album's configured tracks against its
+// directory. An album whose directory does not exist reports all of its
+// tracks as missing.
+func FindMissingTracks(store *albums.Store, extensions []string) ([]MissingTracks, error) {
+	if len(extensions) == 0 {
+		extensions = config.DefaultAlbumExtensions
+	}
+	allAlbums, err := store.ListAlbums()
+	if err != nil {
+		return nil, fmt.Errorf("list albums: %w", err)
+	}
+
+	var out []MissingTracks
+	for _, alb := range allAlbums {
+		dbTracks, err := store.GetTracks(alb.ID)
+		if err != nil {
+			return nil, fmt.Errorf("get tracks for album %q: %w", alb.Slug, err)
+		}
+		if len(dbTracks) == 0 {
+			continue
+		}
+		diskTracks, err := config.ScanAlbumTracks(alb.AlbumPath, extensions...)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("scan album %q: %w", alb.Slug, err)
+		}
+
+		report := buildReconcileReport(albumTracksToConfigTracks(dbTracks), diskTracks)
+		if len(report.ConfigOnly) == 0 {
+			continue
+		}
+		missing := MissingTracks{AlbumID: alb.ID, Slug: alb.Slug, Stems: make([]string, 0, len(report.ConfigOnly))}
+		for _, t := range report.ConfigOnly {
+			missing.Stems = append(missing.Stems, t.Stem)
+		}
+		out = append(out, missing)
+	}
+	return out, nil
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transcoder converts an audio file to MP3. The ffmpeg implementation is
+// used in production; tests substitute a fake.
+type transcoder interface {
+	TranscodeMP3(ctx context.Context, src, dst string) error
+}
+
+// ffmpegTranscoder shells out to an ffmpeg binary.
+type ffmpegTranscoder struct {
+	path string
+}
+
+// newFFmpegTranscoder returns a transcoder backed by the ffmpeg found on
+// PATH, or nil when there is none.
+func newFFmpegTranscoder() transcoder {
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil
+	}
+	return &ffmpegTranscoder{path: path}
+}
+
+func (t *ffmpegTranscoder) TranscodeMP3(ctx context.Context, src, dst string) error {
+	cmd := exec.CommandContext(ctx, t.path,
+		"-nostdin", "-v", "error", "-y",
+		"-i", src,
+		"-vn", "-map_metadata", "0",
+		"-codec:a", "libmp3lame", "-q:a", "2",
+		"-f", "mp3", dst,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// transcodeTimeout bounds a single ffmpeg run.
+const transcodeTimeout = 5 * time.Minute
+
+// transcodeCache stores transcoded MP3s under DATA_PATH/transcode, named by
+// album, track and source modtime so an edited source is re-transcoded and
+// its stale output removed. Concurrent requests for the same output wait
+// for a single transcode.
+type transcodeCache struct {
+	dir      string
+	tc       transcoder
+	mu       sync.Mutex
+	inflight map[string]*transcodeCall
+}
+
+type transcodeCall struct {
+	done chan struct{}
+	err  error
+}
+
+func newTranscodeCache(dir string, tc transcoder) *transcodeCache {
+	return &transcodeCache{dir: dir, tc: tc, inflight: make(map[string]*transcodeCall)}
+}
+
+// trackPrefix identifies every cached output of one track, whatever its
+// source modtime.
+func transcodeTrackPrefix(albumID int64, subdir, stem string) string {
+	sum := sha256.Sum256([]byte(subdir + "/" + stem))
+	return fmt.Sprintf("%d-%s-", albumID, hex.EncodeToString(sum[:8]))
+}
+
+// get returns the path of the MP3 for src, transcoding it first when no
+// cached copy exists for this modtime.
+func (c *transcodeCache) get(ctx context.Context, prefix, src string, info os.FileInfo) (string, error) {
+	name := fmt.Sprintf("%s%d.mp3", prefix, info.ModTime().UnixNano())
+	dst := filepath.Join(c.dir, name)
+	if _, err := os.Stat(dst); err == nil {
+		return dst, nil
+	}
+
+	c.mu.Lock()
+	if call, ok := c.inflight[name]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return dst, call.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	call := &transcodeCall{done: make(chan struct{})}
+	c.inflight[name] = call
+	c.mu.Unlock()
+
+	// The transcode outlives a cancelled request so the next one finds it.
+	call.err = c.transcode(prefix, src, dst)
+	c.mu.Lock()
+	delete(c.inflight, name)
+	c.mu.Unlock()
+	close(call.done)
+	return dst, call.err
+}
+
+func (c *transcodeCache) transcode(prefix, src, dst string) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	defer os.Remove(tmp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), transcodeTimeout)
+	defer cancel()
+	if err := c.tc.TranscodeMP3(ctx, src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return err
+	}
+
+	stale, _ := filepath.Glob(filepath.Join(c.dir, prefix+"*.mp3"))
+	for _, path := range stale {
+		if path != dst {
+			if err := os.Remove(path); err != nil {
+				log.Printf("transcode cache prune error: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// serveTranscodedMP3 streams an MP3 transcode of src. It answers 501 when
+// transcoding is disabled or ffmpeg is unavailable.
+func (s *Server) serveTranscodedMP3(w http.ResponseWriter, r *http.Request, albumID int64, subdir, stem, src string, info os.FileInfo) {
+	if s.transcodes == nil {
+		jsonError(w, "transcoding unavailable", http.StatusNotImplemented)
+		return
+	}
+
+	path, err := s.transcodes.get(r.Context(), transcodeTrackPrefix(albumID, subdir, stem), src, info)
+	if err != nil {
+		if r.Context().Err() == nil {
+			log.Printf("transcode error: %v", err)
+			jsonError(w, "transcode failed", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("transcode open error: %v", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	sum := sha256.Sum256([]byte(filepath.Base(path)))
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Header().Set("Accept-Ranges", "bytes")
+	http.ServeContent(w, r, stem+".mp3", time.Time{}, f)
+}
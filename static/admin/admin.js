@@ -920,7 +920,7 @@
             }
 
             return '' +
-                '<tr class="admin-user-row ' + (u.is_active ? '' : 'is-inactive') + '" data-user-id="' + Number(u.id) + '">' +
+                '<tr class="admin-user-row ' + (u.is_active ? '' : 'is-inactive') + '" data-user-id="' + Number(u.id) + '" data-updated-at="' + escapeAttr(String(u.updated_at || '')) + '">' +
                 '<td>' +
                 '<input type="text" class="admin-user-username" value="' + escapeAttr(username) + '" autocomplete="off">' +
                 '</td>' +
@@ -1035,6 +1035,7 @@
         var username = usernameEl ? usernameEl.value.trim() : '';
         var isActive = activeEl ? !!activeEl.checked : false;
         var requireReset = resetEl ? !!resetEl.checked : false;
+        var expectedUpdatedAt = row.getAttribute('data-updated-at') || '';
 
         if (!userID || !username) {
             setStatus(status, 'Username is required', 'error');
@@ -1050,7 +1051,8 @@
             body: JSON.stringify({
                 username: username,
                 is_active: isActive,
-                require_password_reset: requireReset
+                require_password_reset: requireReset,
+                expected_updated_at: expectedUpdatedAt
             })
         })
             .then(function (r) {
@@ -1062,6 +1064,19 @@
                         }
                     });
                 }
+                if (r.status === 409) {
+                    return parseErrorResponse(r).then(function (msg) {
+                        if (/already exists/i.test(msg || '')) {
+                            throw new Error(msg);
+                        }
+                        // The account changed since it was loaded: show the
+                        // latest values. The status is set after the reload so
+                        // it is not cleared by it.
+                        return loadAdminUsers().then(function () {
+                            setStatus(status, (msg || 'This admin user was changed elsewhere') + '. The list has been reloaded; review it and save again.', 'error');
+                        });
+                    });
+                }
                 return parseErrorResponse(r).then(function (msg) {
                     throw new Error(msg || 'Failed to update admin user');
                 });
@@ -1369,6 +1384,7 @@
         container.innerHTML =
             '<div class="stat-card"><div class="stat-value">' + (overall.total_sessions || 0) + '</div><div class="stat-label">Sessions</div></div>' +
             '<div class="stat-card"><div class="stat-value">' + (overall.avg_tracks_per_session || 0).toFixed(1) + '</div><div class="stat-label">Avg Tracks/Session</div></div>' +
+            '<div class="stat-card"><div class="stat-value">' + formatDuration(overall.avg_session_seconds) + '</div><div class="stat-label">Avg Session Length</div></div>' +
             '<div class="stat-card"><div class="stat-value">' + escapeHtml(overall.most_completed || '-') + '</div><div class="stat-label">Most Completed</div></div>' +
             '<div class="stat-card"><div class="stat-value">' + escapeHtml(overall.least_completed || '-') + '</div><div class="stat-label">Least Completed</div></div>';
     }
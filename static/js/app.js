@@ -2,6 +2,9 @@
 (function () {
     'use strict';
 
+    // Runtime config the server injects into index.html (see spa_config.go).
+    window.__ACETATE__ = window.__ACETATE__ || {};
+
     window.Acetate = {
         state: 'gate', // 'gate', 'selector', or 'player'
         albums: null,       // list of accessible albums from auth response
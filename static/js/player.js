@@ -21,6 +21,8 @@
     var VOLUME_STEP = 0.05;
     var URL_SYNC_MIN_INTERVAL_MS = 1800;
     var lastURLSyncAt = 0;
+    var HEARTBEAT_MIN_INTERVAL_MS = 60000;
+    var lastHeartbeatAt = 0;
 
     var btnPlay, btnPrev, btnNext, btnMute, progress, volumeSlider, timeCurrent, timeTotal;
 
@@ -160,8 +162,18 @@
         persistPlaybackState(false);
     }
 
+    // Keeps the session alive for listeners who resume after a long idle
+    // stretch; the server throttles the actual touch.
+    function heartbeat() {
+        var now = Date.now();
+        if (now - lastHeartbeatAt < HEARTBEAT_MIN_INTERVAL_MS) return;
+        lastHeartbeatAt = now;
+        fetch('/api/heartbeat', { method: 'POST', credentials: 'same-origin' }).catch(function () { });
+    }
+
     function play() {
         warmUp();
+        heartbeat();
 
         if (typeof AcetateOscilloscope !== 'undefined') {
             AcetateOscilloscope.resumeContext();
@@ -262,7 +274,7 @@
         if (!Acetate.albumData) return;
         var track = Acetate.albumData.tracks[Acetate.currentTrackIndex];
         if (track && typeof AcetateAnalytics !== 'undefined') {
-            AcetateAnalytics.record('complete', track.stem);
+            AcetateAnalytics.record('complete', track.stem, activeDeck.currentTime || activeDeck.duration || 0);
         }
 
         var next = Acetate.currentTrackIndex + 1;
@@ -54,7 +54,7 @@
             li.appendChild(num);
             li.appendChild(title);
 
-            if (downloadsEnabled) {
+            if (downloadsEnabled || track.allow_download) {
                 var dl = document.createElement('a');
                 dl.className = 'track-dl';
                 dl.href = makeDownloadUrl(track.stem);